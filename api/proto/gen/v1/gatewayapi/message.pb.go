@@ -36,29 +36,69 @@ const (
 	Message_COMMAND_TYPE_REDIRECT Message_CommandType = 6
 	// 网关下发给前端的限流指令，前端不需要响应
 	Message_COMMAND_TYPE_RATE_LIMIT_EXCEEDED Message_CommandType = 7
+	// 网关上报给业务后端的会话接管事件：takeover 策略下新连接接管了用户的会话
+	Message_COMMAND_TYPE_SESSION_TAKEOVER Message_CommandType = 8
+	// 会话被接管的通知：网关先下发给被接管的旧连接再关闭它，前端不需要响应、也不应自动重连；
+	// 同时上报给业务后端
+	Message_COMMAND_TYPE_SESSION_TAKEN_OVER Message_CommandType = 9
+	// 加密协商的结果：握手请求携带了客户端公钥时，网关在连接建立后首先下发此消息，此消息本身不加密。
+	// body 为网关的临时公钥，之后双方的数据帧都以协商出的密钥加密；body 为空表示网关不对该业务方启用加密
+	Message_COMMAND_TYPE_KEY_EXCHANGE Message_CommandType = 10
+	// 会话恢复：启用会话恢复时网关在连接建立后下发，key 为恢复令牌，客户端重连时以 ?resume= 查询参数携带；
+	// seq 为补发的起点（客户端已确认的最大序号），之后网关补发缓冲区中序号更大的下行推送。
+	// body 为 "lost" 表示部分未确认的推送已不在缓冲区中（或令牌已失效），客户端需要通过业务接口全量同步
+	Message_COMMAND_TYPE_RESUME Message_CommandType = 11
+	// 加入房间：客户端发送，body 为房间名称；网关以相同的 cmd 和 key 回复，回复的 body 为空表示成功，否则为失败原因。
+	// 房间成员关系随连接存在，连接断开后自动退出房间
+	Message_COMMAND_TYPE_ROOM_JOIN Message_CommandType = 12
+	// 退出房间：客户端发送，body 为房间名称；回复格式与 ROOM_JOIN 相同
+	Message_COMMAND_TYPE_ROOM_LEAVE Message_CommandType = 13
+	// 回看房间存档：客户端发送，body 为房间名称，seq 为需要的最近消息条数（0 表示全部），只能回看已加入的房间。
+	// 网关先按推送的先后逐条下发存档中的消息（cmd 为 ROOM_HISTORY，key 和 body 为原推送的 key 和 body），
+	// 最后以请求的 key 回复，回复的 body 为空表示成功，否则为失败原因
+	Message_COMMAND_TYPE_ROOM_HISTORY Message_CommandType = 14
+	// 会话字段变更：session.notifyFields 中的字段被修改后，网关下发给该用户的所有在线连接，前端不需要响应。
+	// key 为字段名，body 为字段的新值
+	Message_COMMAND_TYPE_SESSION_FIELD_CHANGED Message_CommandType = 15
 )
 
 // Enum value maps for Message_CommandType.
 var (
 	Message_CommandType_name = map[int32]string{
-		0: "COMMAND_TYPE_INVALID_UNSPECIFIED",
-		1: "COMMAND_TYPE_HEARTBEAT",
-		2: "COMMAND_TYPE_UPSTREAM_MESSAGE",
-		3: "COMMAND_TYPE_UPSTREAM_ACK",
-		4: "COMMAND_TYPE_DOWNSTREAM_MESSAGE",
-		5: "COMMAND_TYPE_DOWNSTREAM_ACK",
-		6: "COMMAND_TYPE_REDIRECT",
-		7: "COMMAND_TYPE_RATE_LIMIT_EXCEEDED",
+		0:  "COMMAND_TYPE_INVALID_UNSPECIFIED",
+		1:  "COMMAND_TYPE_HEARTBEAT",
+		2:  "COMMAND_TYPE_UPSTREAM_MESSAGE",
+		3:  "COMMAND_TYPE_UPSTREAM_ACK",
+		4:  "COMMAND_TYPE_DOWNSTREAM_MESSAGE",
+		5:  "COMMAND_TYPE_DOWNSTREAM_ACK",
+		6:  "COMMAND_TYPE_REDIRECT",
+		7:  "COMMAND_TYPE_RATE_LIMIT_EXCEEDED",
+		8:  "COMMAND_TYPE_SESSION_TAKEOVER",
+		9:  "COMMAND_TYPE_SESSION_TAKEN_OVER",
+		10: "COMMAND_TYPE_KEY_EXCHANGE",
+		11: "COMMAND_TYPE_RESUME",
+		12: "COMMAND_TYPE_ROOM_JOIN",
+		13: "COMMAND_TYPE_ROOM_LEAVE",
+		14: "COMMAND_TYPE_ROOM_HISTORY",
+		15: "COMMAND_TYPE_SESSION_FIELD_CHANGED",
 	}
 	Message_CommandType_value = map[string]int32{
-		"COMMAND_TYPE_INVALID_UNSPECIFIED": 0,
-		"COMMAND_TYPE_HEARTBEAT":           1,
-		"COMMAND_TYPE_UPSTREAM_MESSAGE":    2,
-		"COMMAND_TYPE_UPSTREAM_ACK":        3,
-		"COMMAND_TYPE_DOWNSTREAM_MESSAGE":  4,
-		"COMMAND_TYPE_DOWNSTREAM_ACK":      5,
-		"COMMAND_TYPE_REDIRECT":            6,
-		"COMMAND_TYPE_RATE_LIMIT_EXCEEDED": 7,
+		"COMMAND_TYPE_INVALID_UNSPECIFIED":   0,
+		"COMMAND_TYPE_HEARTBEAT":             1,
+		"COMMAND_TYPE_UPSTREAM_MESSAGE":      2,
+		"COMMAND_TYPE_UPSTREAM_ACK":          3,
+		"COMMAND_TYPE_DOWNSTREAM_MESSAGE":    4,
+		"COMMAND_TYPE_DOWNSTREAM_ACK":        5,
+		"COMMAND_TYPE_REDIRECT":              6,
+		"COMMAND_TYPE_RATE_LIMIT_EXCEEDED":   7,
+		"COMMAND_TYPE_SESSION_TAKEOVER":      8,
+		"COMMAND_TYPE_SESSION_TAKEN_OVER":    9,
+		"COMMAND_TYPE_KEY_EXCHANGE":          10,
+		"COMMAND_TYPE_RESUME":                11,
+		"COMMAND_TYPE_ROOM_JOIN":             12,
+		"COMMAND_TYPE_ROOM_LEAVE":            13,
+		"COMMAND_TYPE_ROOM_HISTORY":          14,
+		"COMMAND_TYPE_SESSION_FIELD_CHANGED": 15,
 	}
 )
 
@@ -91,19 +131,26 @@ func (Message_CommandType) EnumDescriptor() ([]byte, []int) {
 
 // 通用协议格式
 // 上行消息说明:
-//    上行请求消息是指前端主动发送给后端的消息
-//    上行确认消息是指网关对上行消息的确认消息，但是是服务端处理完消息并返回响应后，网关才发送给客户端的。
+//
+//	上行请求消息是指前端主动发送给后端的消息
+//	上行确认消息是指网关对上行消息的确认消息，但是是服务端处理完消息并返回响应后，网关才发送给客户端的。
+//
 // 下行消息说明:
-//    下行(推送)请求消息是指业务后端主动发送给网关的消息
-//    下行(推送)确认消息是指前端对收到的"下行(推送)请求消息"的确认消息
+//
+//	下行(推送)请求消息是指业务后端主动发送给网关的消息
+//	下行(推送)确认消息是指前端对收到的"下行(推送)请求消息"的确认消息
+//
 // 以 A -> B 为例
 type Message struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	Cmd   Message_CommandType    `protobuf:"varint,1,opt,name=cmd,proto3,enum=gatewayapi.v1.Message_CommandType" json:"cmd,omitempty"` // 消息类型
 	// A -> gateway，是 A 生成；
 	// bizId（token中获取） + key 唯一
-	Key           string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`   // UUID, 后续当前端支持超时重传,后端需要用此 key 来去重
-	Body          []byte `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"` // 业务相关的具体消息体
+	Key  string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`   // UUID, 后续当前端支持超时重传,后端需要用此 key 来去重
+	Body []byte `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"` // 业务相关的具体消息体
+	// 下行推送的序号，启用会话恢复时由网关按用户递增分配，客户端据此去重；
+	// 客户端以带 seq 的 DOWNSTREAM_ACK 确认收到了序号不超过 seq 的所有推送
+	Seq           uint64 `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -159,6 +206,13 @@ func (x *Message) GetBody() []byte {
 	return nil
 }
 
+func (x *Message) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
 type OnReceiveRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
@@ -356,11 +410,19 @@ func (x *BatchOnReceiveResponse) GetRes() []*OnReceiveResponse {
 // 1. 业务方发送PushMessage消息到Kafka中的指定topic，gateway监听并消费
 // 2. gateway 实现下方 PushService，业务后端通过GRPC客户端发送请求
 type PushMessage struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`                                  // 唯一标识用于去重
-	BizId         int64                  `protobuf:"varint,2,opt,name=biz_id,json=bizId,proto3" json:"biz_id,omitempty"`                // biz_id 记录一下哪个业务方过来的
-	ReceiverId    int64                  `protobuf:"varint,3,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"` // 目前来看，只有用户 ID，要根据这个和 biz_id 来找到 websocket 连接并把消息发送出去
-	Body          []byte                 `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`                                // 业务相关的具体消息体
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Key        string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`                                  // 唯一标识用于去重
+	BizId      int64                  `protobuf:"varint,2,opt,name=biz_id,json=bizId,proto3" json:"biz_id,omitempty"`                // biz_id 记录一下哪个业务方过来的
+	ReceiverId int64                  `protobuf:"varint,3,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"` // 目前来看，只有用户 ID，要根据这个和 biz_id 来找到 websocket 连接并把消息发送出去
+	Body       []byte                 `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`                                // 业务相关的具体消息体
+	// 可替换消息的折叠键，用于比分、状态这类只有最新值有意义的通知
+	// 同一用户尚未送达的推送中，折叠键相同的旧消息被新消息替换，客户端只会收到最新的一条；为空时不折叠
+	CollapseKey string `protobuf:"bytes,5,opt,name=collapse_key,json=collapseKey,proto3" json:"collapse_key,omitempty"`
+	// 网关内部使用：启用会话恢复时网关为推送分配的序号，跨节点转发时携带，业务方推送时无需填写
+	Seq uint64 `protobuf:"varint,6,opt,name=seq,proto3" json:"seq,omitempty"`
+	// 投递截止时间（Unix 毫秒时间戳），为 0 时不限制
+	// 网关在路由、入队、重试、写出以及离线消息和会话恢复补发前检查，超过截止时间的推送直接放弃，不会迟到送达
+	Deadline      int64 `protobuf:"varint,7,opt,name=deadline,proto3" json:"deadline,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -423,6 +485,27 @@ func (x *PushMessage) GetBody() []byte {
 	return nil
 }
 
+func (x *PushMessage) GetCollapseKey() string {
+	if x != nil {
+		return x.CollapseKey
+	}
+	return ""
+}
+
+func (x *PushMessage) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *PushMessage) GetDeadline() int64 {
+	if x != nil {
+		return x.Deadline
+	}
+	return 0
+}
+
 type PushRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Msg           *PushMessage           `protobuf:"bytes,1,opt,name=msg,proto3" json:"msg,omitempty"`
@@ -507,11 +590,12 @@ var File_v1_gatewayapi_message_proto protoreflect.FileDescriptor
 
 const file_v1_gatewayapi_message_proto_rawDesc = "" +
 	"\n" +
-	"\x1bv1/gatewayapi/message.proto\x12\rgatewayapi.v1\"\x80\x03\n" +
+	"\x1bv1/gatewayapi/message.proto\x12\rgatewayapi.v1\"\x92\x05\n" +
 	"\aMessage\x124\n" +
 	"\x03cmd\x18\x01 \x01(\x0e2\".gatewayapi.v1.Message.CommandTypeR\x03cmd\x12\x10\n" +
 	"\x03key\x18\x02 \x01(\tR\x03key\x12\x12\n" +
-	"\x04body\x18\x03 \x01(\fR\x04body\"\x98\x02\n" +
+	"\x04body\x18\x03 \x01(\fR\x04body\x12\x10\n" +
+	"\x03seq\x18\x04 \x01(\x04R\x03seq\"\x98\x04\n" +
 	"\vCommandType\x12$\n" +
 	" COMMAND_TYPE_INVALID_UNSPECIFIED\x10\x00\x12\x1a\n" +
 	"\x16COMMAND_TYPE_HEARTBEAT\x10\x01\x12!\n" +
@@ -520,7 +604,16 @@ const file_v1_gatewayapi_message_proto_rawDesc = "" +
 	"\x1fCOMMAND_TYPE_DOWNSTREAM_MESSAGE\x10\x04\x12\x1f\n" +
 	"\x1bCOMMAND_TYPE_DOWNSTREAM_ACK\x10\x05\x12\x19\n" +
 	"\x15COMMAND_TYPE_REDIRECT\x10\x06\x12$\n" +
-	" COMMAND_TYPE_RATE_LIMIT_EXCEEDED\x10\a\"8\n" +
+	" COMMAND_TYPE_RATE_LIMIT_EXCEEDED\x10\a\x12!\n" +
+	"\x1dCOMMAND_TYPE_SESSION_TAKEOVER\x10\b\x12#\n" +
+	"\x1fCOMMAND_TYPE_SESSION_TAKEN_OVER\x10\t\x12\x1d\n" +
+	"\x19COMMAND_TYPE_KEY_EXCHANGE\x10\n" +
+	"\x12\x17\n" +
+	"\x13COMMAND_TYPE_RESUME\x10\v\x12\x1a\n" +
+	"\x16COMMAND_TYPE_ROOM_JOIN\x10\f\x12\x1b\n" +
+	"\x17COMMAND_TYPE_ROOM_LEAVE\x10\r\x12\x1d\n" +
+	"\x19COMMAND_TYPE_ROOM_HISTORY\x10\x0e\x12&\n" +
+	"\"COMMAND_TYPE_SESSION_FIELD_CHANGED\x10\x0f\"8\n" +
 	"\x10OnReceiveRequest\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x12\n" +
 	"\x04body\x18\x02 \x01(\fR\x04body\"A\n" +
@@ -530,13 +623,16 @@ const file_v1_gatewayapi_message_proto_rawDesc = "" +
 	"\x15BatchOnReceiveRequest\x123\n" +
 	"\x04reqs\x18\x01 \x03(\v2\x1f.gatewayapi.v1.OnReceiveRequestR\x04reqs\"L\n" +
 	"\x16BatchOnReceiveResponse\x122\n" +
-	"\x03res\x18\x01 \x03(\v2 .gatewayapi.v1.OnReceiveResponseR\x03res\"k\n" +
+	"\x03res\x18\x01 \x03(\v2 .gatewayapi.v1.OnReceiveResponseR\x03res\"\xbc\x01\n" +
 	"\vPushMessage\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x15\n" +
 	"\x06biz_id\x18\x02 \x01(\x03R\x05bizId\x12\x1f\n" +
 	"\vreceiver_id\x18\x03 \x01(\x03R\n" +
 	"receiverId\x12\x12\n" +
-	"\x04body\x18\x04 \x01(\fR\x04body\";\n" +
+	"\x04body\x18\x04 \x01(\fR\x04body\x12!\n" +
+	"\fcollapse_key\x18\x05 \x01(\tR\vcollapseKey\x12\x10\n" +
+	"\x03seq\x18\x06 \x01(\x04R\x03seq\x12\x1a\n" +
+	"\bdeadline\x18\a \x01(\x03R\bdeadline\";\n" +
 	"\vPushRequest\x12,\n" +
 	"\x03msg\x18\x01 \x01(\v2\x1a.gatewayapi.v1.PushMessageR\x03msg\"\x0e\n" +
 	"\fPushResponse2`\n" +
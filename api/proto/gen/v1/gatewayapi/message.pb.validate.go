@@ -62,6 +62,8 @@ func (m *Message) validate(all bool) error {
 
 	// no validation rules for Body
 
+	// no validation rules for Seq
+
 	if len(errors) > 0 {
 		return MessageMultiError(errors)
 	}
@@ -651,6 +653,12 @@ func (m *PushMessage) validate(all bool) error {
 
 	// no validation rules for Body
 
+	// no validation rules for CollapseKey
+
+	// no validation rules for Seq
+
+	// no validation rules for Deadline
+
 	if len(errors) > 0 {
 		return PushMessageMultiError(errors)
 	}
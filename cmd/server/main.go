@@ -1,25 +1,70 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/YaoAzure/wsgateway/internal/abuse"
+	"github.com/YaoAzure/wsgateway/internal/admission"
+	"github.com/YaoAzure/wsgateway/internal/audit"
+	"github.com/YaoAzure/wsgateway/internal/api"
+	"github.com/YaoAzure/wsgateway/internal/backend"
+	"github.com/YaoAzure/wsgateway/internal/broker"
+	"github.com/YaoAzure/wsgateway/internal/debug"
+	"github.com/YaoAzure/wsgateway/internal/enrich"
+	"github.com/YaoAzure/wsgateway/internal/guest"
+	"github.com/YaoAzure/wsgateway/internal/history"
+	"github.com/YaoAzure/wsgateway/internal/inbound"
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/internal/limiter"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/offline"
+	"github.com/YaoAzure/wsgateway/internal/push"
+	"github.com/YaoAzure/wsgateway/internal/resume"
+	"github.com/YaoAzure/wsgateway/internal/revocation"
+	"github.com/YaoAzure/wsgateway/internal/rooms"
+	"github.com/YaoAzure/wsgateway/internal/scaling"
+	"github.com/YaoAzure/wsgateway/internal/seed"
+	"github.com/YaoAzure/wsgateway/internal/server"
+	"github.com/YaoAzure/wsgateway/internal/uniques"
+	"github.com/YaoAzure/wsgateway/internal/upgrader"
+	"github.com/YaoAzure/wsgateway/internal/upstream"
+	"github.com/YaoAzure/wsgateway/internal/usage"
+	"github.com/YaoAzure/wsgateway/internal/warmup"
+	"github.com/YaoAzure/wsgateway/internal/webhook"
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/YaoAzure/wsgateway/pkg/encryption"
+	"github.com/YaoAzure/wsgateway/pkg/geoip"
+	"github.com/YaoAzure/wsgateway/pkg/kv"
 	"github.com/YaoAzure/wsgateway/pkg/config"
 	"github.com/YaoAzure/wsgateway/pkg/jwt"
 	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/message"
 	"github.com/YaoAzure/wsgateway/pkg/redis"
 	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/do/v2"
 )
 
 func main() {
 	// Parse command line flags
-	configPath := parseFlags()
+	flags := parseFlags()
 
 	// Load configuration first
-	loader := config.NewLoader(configPath)
+	loader := config.NewLoader(flags.configPath,
+		config.WithStrict(flags.strictConfig),
+		config.WithOverrides(flags.overrides...))
 	conf, err := loader.Load()
 	if err != nil {
 		panic(fmt.Sprintf("Failed to load configuration: %v", err))
@@ -32,6 +77,42 @@ func main() {
 		redis.Package,           // Redis 包 - 使用 Lazy Loading
 		jwt.Package,             // JWT 包 - 使用 Lazy Loading
 		session.Package,         // Session 包 - 使用 Lazy Loading
+		enrich.Package,          // 会话数据补充 包 - 使用 Lazy Loading
+		seed.Package,            // Seed 包 - 使用 Lazy Loading
+		metrics.Package,         // Metrics 包 - 使用 Lazy Loading
+		tracing.Package,         // 链路追踪 包 - 使用 Lazy Loading
+		history.Package,         // 连接历史 包 - 使用 Lazy Loading
+		audit.Package,           // 连接审计日志 包 - 使用 Lazy Loading
+		usage.Package,           // 用量报告 包 - 使用 Lazy Loading
+		debug.Package,           // 调试服务 包 - 使用 Lazy Loading
+		resume.Package,          // 会话恢复 包 - 使用 Lazy Loading
+		offline.Package,         // 离线消息 包 - 使用 Lazy Loading
+		incident.Package,        // 事故记录 包 - 使用 Lazy Loading
+		broker.Package,          // 消息队列 包 - 使用 Lazy Loading
+		backoff.Package,         // 重连退避 包 - 使用 Lazy Loading
+		compression.Package,     // 压缩 包 - 使用 Lazy Loading
+		encryption.Package,      // 消息加密 包 - 使用 Lazy Loading
+		geoip.Package,           // 地理位置解析 包 - 使用 Lazy Loading
+		guest.Package,           // 访客连接 包 - 使用 Lazy Loading
+		kv.Package,              // 集群键值 包 - 使用 Lazy Loading
+		message.Package,         // 消息编解码 包 - 使用 Lazy Loading
+		limiter.Package,         // 限流 包 - 使用 Lazy Loading
+		admission.Package,       // 准入控制 包 - 使用 Lazy Loading
+		revocation.Package,      // 令牌吊销 包 - 使用 Lazy Loading
+		webhook.Package,         // 业务方webhook 包 - 使用 Lazy Loading
+		upgrader.Package,        // Upgrader 包 - 使用 Lazy Loading
+		backend.Package,         // 业务后端 包 - 使用 Lazy Loading
+		abuse.Package,           // 滥用检测 包 - 使用 Lazy Loading
+		uniques.Package,         // 去重用户统计 包 - 使用 Lazy Loading
+		upstream.Package,        // 上行消息 包 - 使用 Lazy Loading
+		link.Package,            // Link 包 - 使用 Lazy Loading
+		server.Package,          // WebSocket 服务 包 - 使用 Lazy Loading
+		warmup.Package,          // 启动预热 包 - 使用 Lazy Loading
+		scaling.Package,         // 自动扩缩容 包 - 使用 Lazy Loading
+		rooms.Package,           // 房间 包 - 使用 Lazy Loading
+		push.Package,            // 下行推送 包 - 使用 Lazy Loading
+		inbound.Package,         // 入站webhook 包 - 使用 Lazy Loading
+		api.Package,             // 管理API 包 - 使用 Lazy Loading
 	)
 	defer injector.Shutdown()
 
@@ -40,6 +121,21 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("Failed to get logger from DI container: %v", err))
 	}
+	if keys := loader.UnknownKeys(); len(keys) > 0 {
+		logger.Warn("Config file contains unknown keys, they are ignored", "keys", keys)
+	}
+	if vars := config.LookupEnvOverrides(); len(vars) > 0 || len(flags.overrides) > 0 {
+		logger.Info("Config overridden by environment and flags", "env", vars, "flags", len(flags.overrides))
+	}
+
+	// Seed mode: mint demo credentials and exit
+	if flags.seed {
+		if err := runSeed(injector, flags.seedPath); err != nil {
+			logger.Error("Failed to seed demo data", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -51,25 +147,264 @@ func main() {
 		return c.SendString("OK")
 	})
 
+	// readiness probe and scaling metrics for HPA/KEDA
+	monitor, err := do.Invoke[*scaling.Monitor](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get scaling monitor from DI container: %v", err))
+	}
+	monitor.Register(app)
+
+	// prometheus metrics
+	if conf.Metrics.Path != "" {
+		registry, err := do.Invoke[*prometheus.Registry](injector)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to get metrics registry from DI container: %v", err))
+		}
+		app.Get(conf.Metrics.Path, adaptor.HTTPHandler(metrics.Handler(registry)))
+	}
+
+	// management api
+	router, err := do.Invoke[*api.Router](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get api router from DI container: %v", err))
+	}
+	router.Mount(app)
+
+	// upstream forwarding: route messages received on links to backend services
+	forwarder, err := do.Invoke[*upstream.Forwarder](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get upstream forwarder from DI container: %v", err))
+	}
+	links, err := do.Invoke[*link.Manager](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get link manager from DI container: %v", err))
+	}
+	// rooms: ROOM_JOIN/ROOM_LEAVE/ROOM_HISTORY control messages are handled by the gateway itself
+	roomSet, err := do.Invoke[*rooms.Rooms](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get rooms from DI container: %v", err))
+	}
+	links.SetHandler(roomSet.Handler(forwarder))
+	links.SetNotifier(forwarder)
+
+	// websocket server
+	wsServer, err := do.Invoke[*server.WebsocketServer](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get websocket server from DI container: %v", err))
+	}
+	if err := wsServer.Start(); err != nil {
+		logger.Error("Failed to start websocket server", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Starting websocket server", "addr", wsServer.Addr())
+
+	// cross-node push: receive pushes relayed by other gateway nodes
+	pushRouter, err := do.Invoke[*push.Router](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get push router from DI container: %v", err))
+	}
+	if err := pushRouter.Start(); err != nil {
+		logger.Error("Failed to subscribe to push channel", "error", err)
+		os.Exit(1)
+	}
+	// preferred node lookup for load balancers when cluster.placement is enabled
+	pushRouter.Register(app)
+
+	// kafka ingestion: backends publish pushes to a topic instead of calling the gateway
+	consumer, err := do.Invoke[*broker.Consumer](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get kafka consumer from DI container: %v", err))
+	}
+	if err := consumer.Start(); err != nil {
+		logger.Error("Failed to start kafka consumer", "error", err)
+		os.Exit(1)
+	}
+
+	// inbound webhooks: third-party services trigger pushes without a backend service
+	receiver, err := do.Invoke[*inbound.Receiver](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get inbound webhook receiver from DI container: %v", err))
+	}
+	receiver.Register(app)
+
+	// token revocation: invalidate cached auth results and kick revoked users on every node
+	revocations, err := do.Invoke[*revocation.Store](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get revocation store from DI container: %v", err))
+	}
+	if err := revocations.Start(); err != nil {
+		logger.Error("Failed to subscribe to revocation channel", "error", err)
+		os.Exit(1)
+	}
+
+	// room membership: apply joins/leaves issued through the admin api and keep memberships alive
+	if err := roomSet.Start(); err != nil {
+		logger.Error("Failed to subscribe to room channel", "error", err)
+		os.Exit(1)
+	}
+
+	// cluster key-value store: drain flags and other cluster-wide switches set on any node
+	kvStore, err := do.Invoke[*kv.Store](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get kv store from DI container: %v", err))
+	}
+	if err := kvStore.Start(); err != nil {
+		logger.Error("Failed to subscribe to kv channel", "error", err)
+		os.Exit(1)
+	}
+
+	// grpc admin api: pushes, kicks, connection and session queries for backend services on a separate port
+	grpcServer, err := do.Invoke[*api.GRPCServer](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get grpc server from DI container: %v", err))
+	}
+	if err := grpcServer.Start(); err != nil {
+		logger.Error("Failed to start grpc server", "error", err)
+		os.Exit(1)
+	}
+	if conf.API.GRPC.Enabled {
+		logger.Info("Starting grpc server", "addr", grpcServer.Addr())
+	}
+
+	// debug listener: pprof, expvar and connection dumps on a separate address
+	debugServer, err := do.Invoke[*debug.Server](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get debug server from DI container: %v", err))
+	}
+	if err := debugServer.Start(); err != nil {
+		logger.Error("Failed to start debug server", "error", err)
+		os.Exit(1)
+	}
+	if conf.Debug.Enabled {
+		logger.Info("Starting debug server", "addr", debugServer.Addr())
+	}
+
+	// warm up caches and connections in the background, the readiness probe fails until it finishes
+	warmer, err := do.Invoke[*warmup.Warmer](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get warmer from DI container: %v", err))
+	}
+	warmer.Start()
+
+	// usage reports: per-biz connections and traffic, flushed once more on shutdown after the links are drained
+	reporter, err := do.Invoke[*usage.Reporter](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get usage reporter from DI container: %v", err))
+	}
+	reporter.Start()
+
+	// ready to accept traffic once the websocket server and the subscribers are up and the warmup has finished
+	monitor.Start()
+	monitor.MarkStarted()
+
 	// Start server
-	logger.Info("Starting server", "service", conf.App.Name, "addr", conf.App.Addr)
-	if err := app.Listen(conf.App.Addr); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// session change notifications: kick connections replaced by a newer connection of the same user on any node
+	watcher, err := do.Invoke[*session.ChangeWatcher](injector)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get session change watcher from DI container: %v", err))
+	}
+	go func() {
+		if err := watcher.Run(ctx); err != nil {
+			logger.Error("Failed to watch session changes", "error", err)
+		}
+	}()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		logger.Info("Starting server", "service", conf.App.Name, "addr", conf.App.Addr)
+		listenErr <- app.Listen(conf.App.Addr)
+	}()
+	select {
+	case err := <-listenErr:
 		logger.Error("Failed to start server", "error", err)
 		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// Graceful shutdown: drain websocket connections first, then stop the grpc and http servers
+	gracePeriod := time.Duration(conf.Server.Shutdown.GracePeriod)
+	logger.Info("Shutting down, draining connections", "gracePeriod", gracePeriod)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	// stop consuming first so that uncommitted pushes are picked up by the remaining nodes
+	consumer.Shutdown()
+	if err := wsServer.Drain(shutdownCtx); err != nil {
+		logger.Warn("Failed to drain websocket connections", "error", err)
+	}
+	if err := grpcServer.Stop(shutdownCtx); err != nil {
+		logger.Warn("Failed to stop grpc server", "error", err)
 	}
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		logger.Warn("Failed to shutdown server", "error", err)
+	}
+	logger.Info("Server stopped")
+}
+
+// cliFlags 命令行参数
+type cliFlags struct {
+	configPath   string   // 配置文件路径
+	strictConfig bool     // 配置文件中存在未知键时是否拒绝启动
+	seed         bool     // 是否以种子模式运行
+	seedPath     string   // 种子数据文件路径
+	overrides    []string // 覆盖配置项的 key=value，优先级高于环境变量和配置文件
+}
+
+// overrideFlags 可重复指定的 -set key=value 参数
+type overrideFlags []string
+
+func (o *overrideFlags) String() string {
+	return strings.Join(*o, ",")
 }
 
-// parseFlags 解析命令行参数并返回配置文件路径
-func parseFlags() string {
+func (o *overrideFlags) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// parseFlags 解析命令行参数
+func parseFlags() cliFlags {
 	var configPath = flag.String("config", "configs/config.yaml", "配置文件路径")
+	var strictConfig = flag.Bool("strict-config", false, "严格模式: 配置文件中存在未知键时拒绝启动")
+	var seedMode = flag.Bool("seed", false, "种子模式: 为演示租户签发示例 token 后退出")
+	var seedPath = flag.String("seed-file", seed.DefaultFixturePath, "种子数据文件路径")
 	var showHelp = flag.Bool("help", false, "显示帮助信息")
+	var overrides overrideFlags
+	flag.Var(&overrides, "set", "覆盖配置项，格式为 key=value，可重复指定，例如 -set redis.addr=redis:6379\n"+
+		"优先级: -set > 环境变量 (例如 "+config.EnvName("redis.addr")+") > 配置文件")
 	flag.Parse()
 
 	// Show help if requested
 	if *showHelp {
 		flag.Usage()
-		return ""
+		return cliFlags{}
+	}
+
+	return cliFlags{
+		configPath:   *configPath,
+		strictConfig: *strictConfig,
+		seed:         *seedMode,
+		seedPath:     *seedPath,
+		overrides:    overrides,
 	}
+}
 
-	return *configPath
+// runSeed 加载种子数据并输出演示凭证
+func runSeed(injector do.Injector, path string) error {
+	fixture, err := seed.LoadFixture(path)
+	if err != nil {
+		return err
+	}
+	seeder, err := do.Invoke[*seed.Seeder](injector)
+	if err != nil {
+		return err
+	}
+	creds, err := seeder.Run(fixture)
+	if err != nil {
+		return err
+	}
+	seed.Print(os.Stdout, creds)
+	return nil
 }
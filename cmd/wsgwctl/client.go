@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiPrefix 管理API的统一路径前缀，与 internal/api.Prefix 一致
+const apiPrefix = "/api/v1"
+
+// client 管理API客户端
+type client struct {
+	endpoint string
+	apiKey   string
+	http     *http.Client
+}
+
+func newClient(endpoint, apiKey string, timeout time.Duration) *client {
+	return &client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		apiKey:   apiKey,
+		http:     &http.Client{Timeout: timeout},
+	}
+}
+
+// apiError 管理API返回的错误
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Message)
+}
+
+// call 调用管理API，body 不为 nil 时以 JSON 发送，返回响应体
+// 非 2xx 响应返回 *apiError，错误信息取自响应体的 error 字段
+func (c *client) call(method, path string, query url.Values, body any) (json.RawMessage, error) {
+	u := c.endpoint + apiPrefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return data, nil
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &e) == nil && e.Error != "" {
+		return nil, &apiError{Status: resp.StatusCode, Message: e.Error}
+	}
+	// 例如推送API在所有连接都投递失败时以 503 返回投递结果，响应体原样返回便于排查
+	if json.Valid(data) {
+		return data, &apiError{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+	return nil, &apiError{Status: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+}
@@ -0,0 +1,392 @@
+// wsgwctl 网关管理API的命令行客户端
+//
+// 运维在排查问题时通过它查询和操作网关节点，而不必手工拼装 curl 命令。
+// 管理API按节点部署，连接、踢下线、摘流、日志级别等操作只作用于 -endpoint 指向的节点。
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultEndpoint = "http://127.0.0.1:8080"
+	defaultTimeout  = 10 * time.Second
+	// envPrefix 环境变量前缀，例如 WSGWCTL_ENDPOINT、WSGWCTL_API_KEY
+	envPrefix = "WSGWCTL_"
+)
+
+var errUsage = errors.New("参数错误")
+
+// command 一个子命令
+type command struct {
+	usage string
+	help  string
+	run   func(c *client, args []string) (json.RawMessage, error)
+}
+
+var commands = map[string]command{
+	"stats": {
+		usage: "stats [close-codes]",
+		help:  "查看节点运行状态，或最近一段时间的连接关闭码分布",
+		run:   runStats,
+	},
+	"conns": {
+		usage: "conns [-biz bizId] [-limit n]",
+		help:  "列出节点上的连接",
+		run:   runConns,
+	},
+	"conn": {
+		usage: "conn <connId>",
+		help:  "查看单个连接的状态",
+		run:   runConn,
+	},
+	"user": {
+		usage: "user <bizId> <userId>",
+		help:  "查看用户在节点上的所有连接",
+		run:   runUser,
+	},
+	"kick": {
+		usage: "kick <bizId> <userId>",
+		help:  "将用户在节点上的所有连接踢下线",
+		run:   runKick,
+	},
+	"push": {
+		usage: "push [-key key] [-collapse key] <bizId> <userId> <message>",
+		help:  "向用户推送一条测试消息，未指定 key 时随机生成；-collapse 指定折叠键，替换尚未送达的同键消息",
+		run:   runPush,
+	},
+	"presence": {
+		usage: "presence <bizId> <userId>",
+		help:  "查询用户的在线状态和所在节点",
+		run:   runPresence,
+	},
+	"drain": {
+		usage: "drain on|off",
+		help:  "手动摘流或恢复节点，摘流时已有连接以 4013 关闭并重连到其它节点",
+		run:   runDrain,
+	},
+	"capacity": {
+		usage: "capacity [n]",
+		help:  "查看或调整节点的连接容量，缩容不关闭已有连接，重启后恢复为配置文件中的设置",
+		run:   runCapacity,
+	},
+	"subsystem": {
+		usage: "subsystem [name] [stop|start]",
+		help:  "查看子系统 (forwarder、push、relay、reaper) 的运行状态，或单独停止和恢复",
+		run:   runSubsystem,
+	},
+	"log-level": {
+		usage: "log-level [debug|info|warn|error]",
+		help:  "查看或调整节点的日志级别，重启后恢复为配置文件中的级别",
+		run:   runLogLevel,
+	},
+}
+
+// commandOrder 帮助信息中子命令的顺序
+var commandOrder = []string{"stats", "conns", "conn", "user", "kick", "push", "presence", "drain", "capacity", "subsystem", "log-level"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("wsgwctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", defaultConfigPath(), "配置文件路径，可以在其中设置 endpoint 和 apiKey")
+	endpoint := fs.String("endpoint", "", "管理API地址，默认 "+defaultEndpoint)
+	apiKey := fs.String("api-key", "", "管理API的API Key")
+	timeout := fs.Duration("timeout", 0, "请求超时时间，默认 "+defaultTimeout.String())
+	fs.Usage = func() { usage(fs, stderr) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "未知的命令: %s\n\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	cfg.override(*endpoint, *apiKey, *timeout)
+
+	data, err := cmd.run(newClient(cfg.Endpoint, cfg.APIKey, cfg.Timeout), fs.Args()[1:])
+	if len(data) > 0 {
+		printJSON(stdout, data)
+	}
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "用法: wsgwctl %s\n", cmd.usage)
+		return 2
+	case err != nil:
+		fmt.Fprintf(stderr, "错误: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "用法: wsgwctl [选项] <命令> [参数]")
+	fmt.Fprintln(w, "\n命令:")
+	for _, name := range commandOrder {
+		cmd := commands[name]
+		fmt.Fprintf(w, "  %-44s %s\n", cmd.usage, cmd.help)
+	}
+	fmt.Fprintln(w, "\n选项:")
+	fs.PrintDefaults()
+	fmt.Fprintf(w, "\n选项也可以通过环境变量 %sENDPOINT、%sAPI_KEY、%sTIMEOUT 设置，优先级: 命令行 > 环境变量 > 配置文件\n", envPrefix, envPrefix, envPrefix)
+}
+
+// config wsgwctl 的配置
+type config struct {
+	Endpoint string        `mapstructure:"endpoint"`
+	APIKey   string        `mapstructure:"apiKey"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// defaultConfigPath 默认配置文件 ~/.wsgwctl.yaml
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".wsgwctl.yaml")
+}
+
+// loadConfig 依次读取配置文件和环境变量，配置文件不存在时忽略
+func loadConfig(path string) (config, error) {
+	cfg := config{Endpoint: defaultEndpoint, Timeout: defaultTimeout}
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			v := viper.New()
+			v.SetConfigFile(path)
+			if err := v.ReadInConfig(); err != nil {
+				return config{}, err
+			}
+			if err := v.Unmarshal(&cfg); err != nil {
+				return config{}, err
+			}
+		}
+	}
+	if s := os.Getenv(envPrefix + "TIMEOUT"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return config{}, fmt.Errorf("%sTIMEOUT: %w", envPrefix, err)
+		}
+		cfg.Timeout = d
+	}
+	cfg.override(os.Getenv(envPrefix+"ENDPOINT"), os.Getenv(envPrefix+"API_KEY"), 0)
+	return cfg, nil
+}
+
+// override 用非零值覆盖配置
+func (c *config) override(endpoint, apiKey string, timeout time.Duration) {
+	if endpoint != "" {
+		c.Endpoint = endpoint
+	}
+	if apiKey != "" {
+		c.APIKey = apiKey
+	}
+	if timeout > 0 {
+		c.Timeout = timeout
+	}
+}
+
+// printJSON 缩进输出JSON响应
+func printJSON(w io.Writer, data json.RawMessage) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		_, _ = w.Write(data)
+		fmt.Fprintln(w)
+		return
+	}
+	buf.WriteByte('\n')
+	_, _ = buf.WriteTo(w)
+}
+
+func runStats(c *client, args []string) (json.RawMessage, error) {
+	switch {
+	case len(args) == 0:
+		return c.call(http.MethodGet, "/node/stats", nil, nil)
+	case len(args) == 1 && args[0] == "close-codes":
+		return c.call(http.MethodGet, "/stats/close-codes", nil, nil)
+	default:
+		return nil, errUsage
+	}
+}
+
+func runConns(c *client, args []string) (json.RawMessage, error) {
+	fs := flag.NewFlagSet("conns", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	biz := fs.Int64("biz", 0, "")
+	limit := fs.Int("limit", 0, "")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return nil, errUsage
+	}
+	query := url.Values{}
+	if *biz != 0 {
+		query.Set("bizId", strconv.FormatInt(*biz, 10))
+	}
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+	return c.call(http.MethodGet, "/connections", query, nil)
+}
+
+func runConn(c *client, args []string) (json.RawMessage, error) {
+	if len(args) != 1 {
+		return nil, errUsage
+	}
+	return c.call(http.MethodGet, "/connections/"+url.PathEscape(args[0]), nil, nil)
+}
+
+func runUser(c *client, args []string) (json.RawMessage, error) {
+	path, err := userPath(args)
+	if err != nil {
+		return nil, err
+	}
+	return c.call(http.MethodGet, path+"/connections", nil, nil)
+}
+
+func runKick(c *client, args []string) (json.RawMessage, error) {
+	path, err := userPath(args)
+	if err != nil {
+		return nil, err
+	}
+	return c.call(http.MethodDelete, path+"/connections", nil, nil)
+}
+
+func runPresence(c *client, args []string) (json.RawMessage, error) {
+	path, err := userPath(args)
+	if err != nil {
+		return nil, err
+	}
+	return c.call(http.MethodGet, path+"/presence", nil, nil)
+}
+
+func runPush(c *client, args []string) (json.RawMessage, error) {
+	fs := flag.NewFlagSet("push", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	key := fs.String("key", "", "")
+	collapseKey := fs.String("collapse", "", "")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 3 {
+		return nil, errUsage
+	}
+	bizID, userID, err := parseUser(fs.Args()[:2])
+	if err != nil {
+		return nil, err
+	}
+	if *key == "" {
+		*key = uuid.NewString()
+	}
+	// 与 internal/api 的推送请求体一致，body 以 base64 编码
+	return c.call(http.MethodPost, "/push", nil, struct {
+		BizID       int64  `json:"bizId"`
+		UserID      int64  `json:"userId"`
+		Key         string `json:"key"`
+		CollapseKey string `json:"collapseKey,omitempty"`
+		Body        []byte `json:"body"`
+	}{BizID: bizID, UserID: userID, Key: *key, CollapseKey: *collapseKey, Body: []byte(fs.Arg(2))})
+}
+
+func runDrain(c *client, args []string) (json.RawMessage, error) {
+	if len(args) != 1 {
+		return nil, errUsage
+	}
+	switch args[0] {
+	case "on":
+		return c.call(http.MethodPost, "/node/drain", nil, nil)
+	case "off":
+		return c.call(http.MethodDelete, "/node/drain", nil, nil)
+	default:
+		return nil, errUsage
+	}
+}
+
+func runCapacity(c *client, args []string) (json.RawMessage, error) {
+	switch len(args) {
+	case 0:
+		return c.call(http.MethodGet, "/node/capacity", nil, nil)
+	case 1:
+		n, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || n < 0 {
+			return nil, errUsage
+		}
+		return c.call(http.MethodPut, "/node/capacity", nil, map[string]int64{"capacity": n})
+	default:
+		return nil, errUsage
+	}
+}
+
+func runSubsystem(c *client, args []string) (json.RawMessage, error) {
+	switch len(args) {
+	case 0:
+		return c.call(http.MethodGet, "/subsystems", nil, nil)
+	case 1:
+		return c.call(http.MethodGet, "/subsystems/"+url.PathEscape(args[0]), nil, nil)
+	case 2:
+		if args[1] != "stop" && args[1] != "start" {
+			return nil, errUsage
+		}
+		return c.call(http.MethodPost, "/subsystems/"+url.PathEscape(args[0])+"/"+args[1], nil, nil)
+	default:
+		return nil, errUsage
+	}
+}
+
+func runLogLevel(c *client, args []string) (json.RawMessage, error) {
+	switch len(args) {
+	case 0:
+		return c.call(http.MethodGet, "/node/log-level", nil, nil)
+	case 1:
+		return c.call(http.MethodPut, "/node/log-level", nil, map[string]string{"level": args[0]})
+	default:
+		return nil, errUsage
+	}
+}
+
+// userPath 解析 <bizId> <userId> 参数，返回用户维度API的路径前缀
+func userPath(args []string) (string, error) {
+	if len(args) != 2 {
+		return "", errUsage
+	}
+	bizID, userID, err := parseUser(args)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/users/%d/%d", bizID, userID), nil
+}
+
+func parseUser(args []string) (bizID, userID int64, err error) {
+	bizID, err = strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: 无效的 bizId %q", errUsage, args[0])
+	}
+	userID, err = strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: 无效的 userId %q", errUsage, args[1])
+	}
+	return bizID, userID, nil
+}
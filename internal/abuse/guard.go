@@ -0,0 +1,246 @@
+package abuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// Redis 键格式，subject 为客户端标识（用户或IP）
+const (
+	scoreKeyFormat = "gateway:abuse:score:%s" // 滥用分，过期即衰减为 0
+	banKeyFormat   = "gateway:abuse:ban:%s"   // 封禁标记，TTL 即剩余封禁时长
+	levelKeyFormat = "gateway:abuse:level:%s" // 封禁次数，用于计算递增的封禁时长
+	bansKey        = "gateway:abuse:bans"     // 封禁名单，有序集合，成员为客户端标识，分数为封禁到期的 Unix 毫秒时间
+)
+
+var ErrDisabled = errors.New("未启用滥用检测")
+
+// Signal 滥用信号
+type Signal string
+
+const (
+	SignalRateLimit     Signal = "rate_limit"     // 触发限流
+	SignalProtocolError Signal = "protocol_error" // 发送了不符合 WebSocket 协议的帧
+	SignalOversized     Signal = "oversized"      // 消息超过大小限制
+	SignalAuthFailure   Signal = "auth_failure"   // 握手认证失败
+)
+
+// UserSubject 已认证用户的客户端标识
+func UserSubject(bizID, userID int64) string {
+	return fmt.Sprintf("user:%d:%d", bizID, userID)
+}
+
+// IPSubject 未认证客户端的标识
+func IPSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// Verdict 上报信号后的判定结果
+type Verdict struct {
+	Score  int64         // 当前滥用分
+	Banned bool          // 本次上报是否导致封禁
+	BanFor time.Duration // 封禁时长
+}
+
+// Guard 客户端滥用检测
+//
+// 各类异常行为按权重累加为滥用分，滥用分在窗口期内没有新信号时自动过期；
+// 滥用分达到阈值后客户端被封禁，封禁时长随封禁次数翻倍递增，直到上限。
+// 状态保存在Redis中，被封禁的客户端重连到任意节点都会被拒绝。
+// Redis 不可用时放行，避免误伤正常客户端。
+type Guard struct {
+	rdb       redis.Cmdable
+	enabled   bool
+	window    time.Duration
+	threshold int64
+	weights   map[Signal]int64
+	banBase   time.Duration
+	banMax    time.Duration
+	levelTTL  time.Duration
+}
+
+func NewGuard(i do.Injector) (*Guard, error) {
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := do.Invoke[config.AbuseConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Guard{
+		rdb:       rdb,
+		enabled:   cfg.Enabled && cfg.Threshold > 0,
+		window:    time.Duration(cfg.Window),
+		threshold: cfg.Threshold,
+		weights: map[Signal]int64{
+			SignalRateLimit:     cfg.Weights.RateLimit,
+			SignalProtocolError: cfg.Weights.ProtocolError,
+			SignalOversized:     cfg.Weights.Oversized,
+			SignalAuthFailure:   cfg.Weights.AuthFailure,
+		},
+		banBase:  time.Duration(cfg.Ban.Base),
+		banMax:   time.Duration(cfg.Ban.Max),
+		levelTTL: time.Duration(cfg.Ban.LevelTTL),
+	}, nil
+}
+
+// Enabled 返回是否启用滥用检测
+func (g *Guard) Enabled() bool {
+	return g.enabled
+}
+
+// Report 上报一次滥用信号，滥用分达到阈值时封禁客户端
+func (g *Guard) Report(ctx context.Context, subject string, signal Signal) (Verdict, error) {
+	weight := g.weights[signal]
+	if !g.enabled || weight <= 0 {
+		return Verdict{}, nil
+	}
+
+	scoreKey := fmt.Sprintf(scoreKeyFormat, subject)
+	var incr *redis.IntCmd
+	_, err := g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(ctx, scoreKey, weight)
+		pipe.Expire(ctx, scoreKey, g.window)
+		return nil
+	})
+	if err != nil {
+		return Verdict{}, err
+	}
+	score := incr.Val()
+	if score < g.threshold {
+		return Verdict{Score: score}, nil
+	}
+
+	banFor, err := g.ban(ctx, subject)
+	if err != nil {
+		return Verdict{Score: score}, err
+	}
+	return Verdict{Score: score, Banned: true, BanFor: banFor}, nil
+}
+
+// ban 封禁客户端并清零滥用分，第 n 次封禁的时长为 banBase*2^(n-1)，不超过 banMax
+func (g *Guard) ban(ctx context.Context, subject string) (time.Duration, error) {
+	levelKey := fmt.Sprintf(levelKeyFormat, subject)
+	var level *redis.IntCmd
+	_, err := g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		level = pipe.Incr(ctx, levelKey)
+		pipe.Expire(ctx, levelKey, g.levelTTL)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	banFor := g.banBase
+	for n := int64(1); n < level.Val() && banFor < g.banMax; n++ {
+		banFor *= 2
+	}
+	if g.banMax > 0 && banFor > g.banMax {
+		banFor = g.banMax
+	}
+
+	_, err = g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, fmt.Sprintf(banKeyFormat, subject), level.Val(), banFor)
+		pipe.Del(ctx, fmt.Sprintf(scoreKeyFormat, subject))
+		g.list(ctx, pipe, subject, banFor)
+		return nil
+	})
+	return banFor, err
+}
+
+// list 把客户端加入封禁名单并清理已到期的项，名单只用于查询，是否封禁以封禁标记为准
+func (g *Guard) list(ctx context.Context, pipe redis.Pipeliner, subject string, banFor time.Duration) {
+	now := time.Now()
+	pipe.ZRemRangeByScore(ctx, bansKey, "-inf", "("+strconv.FormatInt(now.UnixMilli(), 10))
+	pipe.ZAdd(ctx, bansKey, redis.Z{Score: float64(now.Add(banFor).UnixMilli()), Member: subject})
+}
+
+// Ban 手动封禁客户端 banFor 时长，覆盖已有的封禁，不计入封禁次数
+func (g *Guard) Ban(ctx context.Context, subject string, banFor time.Duration) error {
+	if !g.enabled {
+		return ErrDisabled
+	}
+	_, err := g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, fmt.Sprintf(banKeyFormat, subject), 0, banFor)
+		g.list(ctx, pipe, subject, banFor)
+		return nil
+	})
+	return err
+}
+
+// Unban 解除客户端的封禁，同时清零滥用分和封禁次数
+func (g *Guard) Unban(ctx context.Context, subject string) error {
+	if !g.enabled {
+		return ErrDisabled
+	}
+	_, err := g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx,
+			fmt.Sprintf(banKeyFormat, subject),
+			fmt.Sprintf(scoreKeyFormat, subject),
+			fmt.Sprintf(levelKeyFormat, subject))
+		pipe.ZRem(ctx, bansKey, subject)
+		return nil
+	})
+	return err
+}
+
+// BanEntry 封禁名单中的一项
+type BanEntry struct {
+	Subject   string
+	ExpiresAt time.Time
+}
+
+// Bans 返回当前仍在封禁中的客户端，按到期时间从早到晚排序，同时清理名单中已到期的项
+func (g *Guard) Bans(ctx context.Context) ([]BanEntry, error) {
+	if !g.enabled {
+		return nil, ErrDisabled
+	}
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	var members *redis.ZSliceCmd
+	_, err := g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, bansKey, "-inf", "("+now)
+		members = pipe.ZRangeWithScores(ctx, bansKey, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]BanEntry, 0, len(members.Val()))
+	for _, z := range members.Val() {
+		subject, _ := z.Member.(string)
+		entries = append(entries, BanEntry{Subject: subject, ExpiresAt: time.UnixMilli(int64(z.Score))})
+	}
+	return entries, nil
+}
+
+// Banned 返回客户端剩余的封禁时长，未被封禁时返回 0
+// 同时检查多个标识（例如用户和IP）时返回其中最长的剩余时长
+func (g *Guard) Banned(ctx context.Context, subjects ...string) (time.Duration, error) {
+	if !g.enabled || len(subjects) == 0 {
+		return 0, nil
+	}
+	cmds := make([]*redis.DurationCmd, len(subjects))
+	_, err := g.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, s := range subjects {
+			cmds[i] = pipe.PTTL(ctx, fmt.Sprintf(banKeyFormat, s))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	var remaining time.Duration
+	for _, cmd := range cmds {
+		// 键不存在时 PTTL 返回负数
+		remaining = max(remaining, cmd.Val())
+	}
+	return remaining, nil
+}
@@ -0,0 +1,94 @@
+package abuse
+
+import (
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// throttleSweepInterval 清理令牌桶已满的客户端IP的间隔
+const throttleSweepInterval = time.Minute
+
+// HandshakeThrottle 按客户端IP限制握手速率
+//
+// 每个IP一个令牌桶，在接收连接后、获取连接令牌和校验JWT之前检查，
+// 只在本节点内存中计数，不访问Redis，用来低成本地挡住反复请求升级接口的客户端。
+// 同一个IP持续超过限制时由调用方上报 SignalRateLimit，滥用分达到阈值后IP被封禁，封禁对所有节点生效。
+type HandshakeThrottle struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*ipBucket
+	swept   time.Time
+}
+
+type ipBucket struct {
+	tokens  float64
+	last    time.Time
+	limited bool // 上一次握手是否被限流，用于只在进入限流状态时上报一次滥用信号
+}
+
+func NewHandshakeThrottle(i do.Injector) (*HandshakeThrottle, error) {
+	cfg, err := do.Invoke[config.AbuseConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	burst := cfg.Handshake.Burst
+	if burst <= 0 {
+		burst = max(cfg.Handshake.Rate, 1)
+	}
+	return &HandshakeThrottle{
+		rate:    float64(cfg.Handshake.Rate),
+		burst:   float64(burst),
+		buckets: make(map[string]*ipBucket),
+		swept:   time.Now(),
+	}, nil
+}
+
+// Enabled 返回是否限制握手速率
+func (t *HandshakeThrottle) Enabled() bool {
+	return t.rate > 0
+}
+
+// Allow 为来自 ip 的一次握手消耗令牌
+// 超过限制时 ok 为 false，retryAfter 为下一个令牌可用前的等待时长，entered 表示该IP本次刚进入限流状态
+func (t *HandshakeThrottle) Allow(ip string) (ok bool, retryAfter time.Duration, entered bool) {
+	if t.rate <= 0 {
+		return true, 0, false
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sweep(now)
+	b, found := t.buckets[ip]
+	if !found {
+		b = &ipBucket{tokens: t.burst, last: now}
+		t.buckets[ip] = b
+	}
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*t.rate, t.burst)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.limited = false
+		return true, 0, false
+	}
+	entered = !b.limited
+	b.limited = true
+	return false, time.Duration((1 - b.tokens) / t.rate * float64(time.Second)), entered
+}
+
+// sweep 定期删除令牌桶已经补满的IP，这些IP的状态与从未出现过相同
+func (t *HandshakeThrottle) sweep(now time.Time) {
+	if now.Sub(t.swept) < throttleSweepInterval {
+		return
+	}
+	t.swept = now
+	for ip, b := range t.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*t.rate >= t.burst {
+			delete(t.buckets, ip)
+		}
+	}
+}
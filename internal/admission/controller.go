@@ -0,0 +1,298 @@
+package admission
+
+import (
+	"context"
+	"net/http"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/guest"
+	"github.com/YaoAzure/wsgateway/internal/limiter"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	gatewaymetrics "github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// defaultQueueInterval 未配置时排队期间重新检查摘流和内存状态的间隔
+	defaultQueueInterval = 50 * time.Millisecond
+	// memorySampleInterval 读取运行时内存占用的最小间隔，避免每个新连接都采样
+	memorySampleInterval = time.Second
+)
+
+// Action 准入决策的动作
+type Action int
+
+const (
+	Accept Action = iota // 允许建立连接
+	Reject               // 拒绝连接，客户端按退避建议稍后重连
+	Queue                // 暂时没有令牌，调用 Controller.Await 最多等待 Decision.Wait，未等到令牌时重新申请
+)
+
+func (a Action) String() string {
+	switch a {
+	case Accept:
+		return "accept"
+	case Reject:
+		return "reject"
+	case Queue:
+		return "queue"
+	default:
+		return "unknown"
+	}
+}
+
+// Cause 拒绝或排队的原因
+type Cause string
+
+const (
+	CauseDraining  Cause = "draining"  // 节点正在停机摘流
+	CauseMemory    Cause = "memory"    // 节点内存占用超过预算
+	CauseCapacity  Cause = "capacity"  // 连接令牌耗尽
+	CauseQuota     Cause = "quota"     // 业务方连接数达到配额
+	CauseUserQuota Cause = "userQuota" // 用户在本节点上的连接数达到业务方的单用户配额
+	CauseGuests    Cause = "guests"    // 业务方的访客连接数达到访客策略的上限
+)
+
+// Stage 发起准入申请的阶段
+type Stage int
+
+const (
+	// StageConnect 接收TCP连接时，此时还不知道业务方，准入时会获取一个连接令牌
+	StageConnect Stage = iota
+	// StageHandshake 握手认证完成、创建会话之前，此时已持有令牌，按业务方配额检查
+	StageHandshake
+)
+
+// Request 准入申请
+type Request struct {
+	Stage  Stage
+	BizID  int64         // StageHandshake 时有效
+	UserID int64         // StageHandshake 时有效
+	Guest  bool          // StageHandshake 时有效，是否是访客连接
+	Waited time.Duration // 已经排队等待的时长
+}
+
+// Decision 准入决策
+type Decision struct {
+	Action Action
+	Cause  Cause          // Accept 时为空
+	Advice backoff.Advice // Reject 时下发给客户端的退避建议
+	Wait   time.Duration  // Queue 时本次最多等待令牌的时长
+}
+
+// Status 拒绝连接时返回给客户端的HTTP状态码
+func (d Decision) Status() int {
+	if d.Cause == CauseQuota || d.Cause == CauseUserQuota || d.Cause == CauseGuests {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
+}
+
+// RetryAfter 拒绝连接时建议客户端等待的时长
+func (d Decision) RetryAfter() time.Duration {
+	return d.Advice.Delay(0)
+}
+
+// Admitter 对新连接做准入判断
+// Upgrader 通过该接口在握手阶段检查准入，测试环境可以替换为 AcceptAll
+type Admitter interface {
+	Admit(req Request) Decision
+}
+
+// AcceptAll 放行所有连接的准入实现，不获取连接令牌
+type AcceptAll struct{}
+
+func (AcceptAll) Admit(Request) Decision { return Decision{Action: Accept} }
+
+// Controller 新连接的准入控制
+//
+// 把摘流状态、节点内存预算、连接令牌（TokenLimiter）和业务方配额集中在一次调用中判断，
+// 返回接受、带退避建议的拒绝或排队三种决策，调用方只负责执行决策。
+// 检查按代价从低到高进行，令牌放在最后获取，被其它条件拒绝的连接不会占用令牌。
+type Controller struct {
+	limiter  *limiter.TokenLimiter
+	links    *link.Manager
+	policies *backoff.Policies
+	guests   *guest.Policies
+	metrics  *gatewaymetrics.AdmissionMetrics
+
+	memoryLimit      uint64
+	queueTimeout     time.Duration
+	queueInterval    time.Duration
+	defaultBizQuota  int
+	defaultUserQuota int
+	bizQuotas        map[int64]int
+	userQuotas       map[int64]int
+
+	memMu      sync.Mutex
+	memSampled time.Time
+	memUsed    uint64
+	memSamples []metrics.Sample
+}
+
+func NewController(i do.Injector) (*Controller, error) {
+	cfg, err := do.Invoke[config.AdmissionConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	l, err := do.Invoke[*limiter.TokenLimiter](i)
+	if err != nil {
+		return nil, err
+	}
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := do.Invoke[*backoff.Policies](i)
+	if err != nil {
+		return nil, err
+	}
+	guests, err := do.Invoke[*guest.Policies](i)
+	if err != nil {
+		return nil, err
+	}
+	m, err := do.Invoke[*gatewaymetrics.AdmissionMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	c := &Controller{
+		limiter:          l,
+		links:            links,
+		policies:         policies,
+		guests:           guests,
+		metrics:          m,
+		memoryLimit:      uint64(max(cfg.MemoryLimit, 0)),
+		queueTimeout:     time.Duration(cfg.QueueTimeout),
+		queueInterval:    time.Duration(cfg.QueueInterval),
+		defaultBizQuota:  cfg.DefaultBizQuota,
+		defaultUserQuota: cfg.DefaultUserQuota,
+		bizQuotas:        make(map[int64]int, len(cfg.BizQuotas)),
+		userQuotas:       make(map[int64]int, len(cfg.BizQuotas)),
+		memSamples: []metrics.Sample{
+			{Name: "/memory/classes/total:bytes"},
+			{Name: "/memory/classes/heap/released:bytes"},
+		},
+	}
+	if c.queueInterval <= 0 {
+		c.queueInterval = defaultQueueInterval
+	}
+	for _, q := range cfg.BizQuotas {
+		c.bizQuotas[q.BizID] = q.MaxConnections
+		c.userQuotas[q.BizID] = q.MaxUserConnections
+	}
+	m.SetSource(c.quotaUsage)
+	return c, nil
+}
+
+// Admit 对新连接做准入判断
+// StageConnect 阶段返回 Accept 时已获取一个连接令牌，调用方需要在连接关闭后调用 Release 归还
+func (c *Controller) Admit(req Request) Decision {
+	if c.links.Draining() {
+		return c.reject(CauseDraining)
+	}
+	if c.memoryLimit > 0 && c.memoryUsed() > c.memoryLimit {
+		return c.reject(CauseMemory)
+	}
+	if req.Stage == StageHandshake {
+		if quota := c.quota(req.BizID); quota > 0 && c.links.CountByBiz(req.BizID) >= quota {
+			c.metrics.QuotaRejected(req.BizID, "biz")
+			return c.reject(CauseQuota)
+		}
+		if quota := c.userQuota(req.BizID); quota > 0 && c.links.CountByUser(req.BizID, req.UserID) >= quota {
+			c.metrics.QuotaRejected(req.BizID, "user")
+			return c.reject(CauseUserQuota)
+		}
+		if req.Guest {
+			if p, _ := c.guests.Of(req.BizID); p.MaxConnections > 0 && c.links.CountGuestsByBiz(req.BizID) >= p.MaxConnections {
+				return c.reject(CauseGuests)
+			}
+		}
+		return Decision{Action: Accept}
+	}
+	if c.limiter.Acquire() {
+		return Decision{Action: Accept}
+	}
+	// 令牌可能很快被归还或随扩容增加，在排队时限内让连接稍等而不是直接拒绝
+	// 每次最多等待 queueInterval，之后重新申请，使排队期间开始的摘流和内存超限也能及时拒绝
+	if remaining := c.queueTimeout - req.Waited; remaining > 0 {
+		return Decision{Action: Queue, Cause: CauseCapacity, Wait: min(c.queueInterval, remaining)}
+	}
+	return c.reject(CauseCapacity)
+}
+
+// Await 在 Queue 决策后阻塞等待连接令牌，令牌被归还或扩容时立即返回，等待时长由 ctx 控制
+// 返回 true 时已获取令牌，与 Admit 返回 Accept 相同，调用方需要在连接关闭后调用 Release 归还；
+// 等待期间节点开始摘流时归还令牌并返回 false，调用方重新申请后会被拒绝
+func (c *Controller) Await(ctx context.Context) bool {
+	if err := c.limiter.AcquireWithContext(ctx); err != nil {
+		return false
+	}
+	if c.links.Draining() {
+		c.limiter.Release()
+		return false
+	}
+	return true
+}
+
+// Release 归还 StageConnect 阶段准入时获取的连接令牌
+func (c *Controller) Release() {
+	c.limiter.Release()
+}
+
+func (c *Controller) reject(cause Cause) Decision {
+	c.metrics.Rejected(string(cause))
+	reason := backoff.ReasonCapacity
+	if cause == CauseDraining {
+		reason = backoff.ReasonDrain
+	}
+	return Decision{Action: Reject, Cause: cause, Advice: c.policies.Advice(reason)}
+}
+
+// quota 返回业务方在本节点上的最大连接数，0 表示不限制
+func (c *Controller) quota(bizID int64) int {
+	if q, ok := c.bizQuotas[bizID]; ok {
+		return q
+	}
+	return c.defaultBizQuota
+}
+
+// userQuota 返回业务方的单个用户在本节点上的最大连接数，0 表示不限制
+// 与 session.devices 的设备数上限不同，这里按连接计数，同一设备的多个连接也会计入
+func (c *Controller) userQuota(bizID int64) int {
+	if q, ok := c.userQuotas[bizID]; ok && q > 0 {
+		return q
+	}
+	return c.defaultUserQuota
+}
+
+// quotaUsage 为指标产出配置了业务方配额的业务方的连接数和配额
+// 配置了默认配额时产出本节点上所有有连接的业务方，以及单独配置了配额的业务方
+func (c *Controller) quotaUsage(yield func(bizID int64, connections, quota int)) {
+	counts := c.links.CountsByBiz()
+	for bizID := range c.bizQuotas {
+		if _, ok := counts[bizID]; !ok {
+			counts[bizID] = 0
+		}
+	}
+	for bizID, n := range counts {
+		if quota := c.quota(bizID); quota > 0 {
+			yield(bizID, n, quota)
+		}
+	}
+}
+
+// memoryUsed 返回Go运行时向操作系统申请且未归还的内存，最多每秒采样一次
+func (c *Controller) memoryUsed() uint64 {
+	c.memMu.Lock()
+	defer c.memMu.Unlock()
+	if now := time.Now(); now.Sub(c.memSampled) >= memorySampleInterval {
+		metrics.Read(c.memSamples)
+		c.memUsed = c.memSamples[0].Value.Uint64() - c.memSamples[1].Value.Uint64()
+		c.memSampled = now
+	}
+	return c.memUsed
+}
@@ -0,0 +1,14 @@
+package admission
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Admission 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewController),
+	// 握手阶段的准入检查由同一个 Controller 完成
+	do.Lazy(func(i do.Injector) (Admitter, error) {
+		return do.Invoke[*Controller](i)
+	}),
+)
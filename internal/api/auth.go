@@ -0,0 +1,84 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"slices"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/keyauth"
+	"github.com/samber/do/v2"
+)
+
+// apiKeyLocalsKey 认证通过后，匹配到的API Key配置在请求上下文中的键
+const apiKeyLocalsKey = "api.key"
+
+// allFields 表示允许访问全部会话字段的通配符
+const allFields = "*"
+
+var (
+	ErrInvalidAPIKey   = errors.New("无效的API Key")
+	ErrFieldNotAllowed = errors.New("无权访问该会话字段")
+)
+
+// apiKeyAuth 管理API的API Key认证
+type apiKeyAuth struct {
+	keys []config.APIKeyConfig
+}
+
+func newAPIKeyAuth(i do.Injector) (*apiKeyAuth, error) {
+	cfg, err := do.Invoke[config.APIConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	return &apiKeyAuth{keys: cfg.Keys}, nil
+}
+
+// middleware 返回认证中间件，支持 X-API-Key 头和 Authorization: Bearer 两种携带方式
+func (a *apiKeyAuth) middleware() fiber.Handler {
+	return keyauth.New(keyauth.Config{
+		Extractor: keyauth.Chain(
+			keyauth.FromHeader("X-API-Key"),
+			keyauth.FromAuthHeader(fiber.HeaderAuthorization, "Bearer"),
+		),
+		Validator: func(c fiber.Ctx, key string) (bool, error) {
+			k, ok := a.lookup(key)
+			if !ok {
+				return false, ErrInvalidAPIKey
+			}
+			c.Locals(apiKeyLocalsKey, k)
+			return true, nil
+		},
+		ErrorHandler: func(c fiber.Ctx, _ error) error {
+			return fail(c, fiber.StatusUnauthorized, ErrInvalidAPIKey)
+		},
+	})
+}
+
+// lookup 查找与 key 匹配的API Key配置
+func (a *apiKeyAuth) lookup(key string) (config.APIKeyConfig, bool) {
+	for _, k := range a.keys {
+		// 使用常量时间比较，避免时序攻击
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			return k, true
+		}
+	}
+	return config.APIKeyConfig{}, false
+}
+
+// apiKeyFrom 返回当前请求认证通过的API Key配置
+func apiKeyFrom(c fiber.Ctx) config.APIKeyConfig {
+	k, _ := c.Locals(apiKeyLocalsKey).(config.APIKeyConfig)
+	return k
+}
+
+// allowSessionField 判断API Key是否允许访问指定的会话字段
+// 网关自己维护的字段 (session.ReservedField) 即使API Key配置了 "*" 也不允许访问
+func allowSessionField(k config.APIKeyConfig, field string) bool {
+	if session.ReservedField(field) {
+		return false
+	}
+	return slices.Contains(k.SessionFields, allFields) || slices.Contains(k.SessionFields, field)
+}
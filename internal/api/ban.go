@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/abuse"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+var (
+	ErrInvalidBanSubject  = errors.New("需要指定有效的 ip，或 bizId 和 userId")
+	ErrInvalidBanDuration = errors.New("封禁时长必须大于 0")
+)
+
+// BanHandler 客户端封禁名单API
+// 封禁状态由滥用检测维护，在握手前后检查，对所有节点生效；被封禁的客户端收到剩余封禁时长作为重连退避
+type BanHandler struct {
+	guard  *abuse.Guard
+	logger *log.Logger
+}
+
+func NewBanHandler(i do.Injector) (*BanHandler, error) {
+	guard, err := do.Invoke[*abuse.Guard](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &BanHandler{guard: guard, logger: logger}, nil
+}
+
+func (h *BanHandler) Register(r fiber.Router) {
+	r.Get("/bans", h.list)
+	r.Post("/bans", h.ban)
+	r.Delete("/bans/ip/:ip", h.unbanIP)
+	r.Delete("/bans/users/:bizId/:userId", h.unbanUser)
+}
+
+// banRequest 手动封禁请求体，duration 为封禁时长 (秒)
+type banRequest struct {
+	IP       string `json:"ip"`
+	BizID    int64  `json:"bizId"`
+	UserID   int64  `json:"userId"`
+	Duration int64  `json:"duration"`
+}
+
+// banEntry 封禁名单中的一项，expiresAt 为 Unix 秒
+type banEntry struct {
+	Subject   string `json:"subject"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// list 返回当前仍在封禁中的客户端，包括自动封禁和手动封禁
+// GET /api/v1/bans
+func (h *BanHandler) list(c fiber.Ctx) error {
+	bans, err := h.guard.Bans(c)
+	if err != nil {
+		return h.fail(c, err)
+	}
+	entries := make([]banEntry, 0, len(bans))
+	for _, b := range bans {
+		entries = append(entries, banEntry{Subject: b.Subject, ExpiresAt: b.ExpiresAt.Unix()})
+	}
+	return c.JSON(fiber.Map{"bans": entries})
+}
+
+// ban 手动封禁客户端IP或用户，覆盖已有的封禁
+// POST /api/v1/bans  body: {"ip": "203.0.113.7", "duration": 3600}
+// POST /api/v1/bans  body: {"bizId": 1, "userId": 2, "duration": 3600}
+// 封禁只拒绝新的握手，用户已有的连接需要通过连接API踢下线
+func (h *BanHandler) ban(c fiber.Ctx) error {
+	var req banRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if req.Duration <= 0 {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidBanDuration)
+	}
+	var subject string
+	switch {
+	case req.IP != "" && net.ParseIP(req.IP) != nil:
+		subject = abuse.IPSubject(req.IP)
+	case req.IP == "" && req.BizID > 0 && req.UserID > 0:
+		subject = abuse.UserSubject(req.BizID, req.UserID)
+	default:
+		return fail(c, fiber.StatusBadRequest, ErrInvalidBanSubject)
+	}
+	banFor := time.Duration(req.Duration) * time.Second
+	if err := h.guard.Ban(c, subject, banFor); err != nil {
+		return h.fail(c, err)
+	}
+	h.logger.Info("客户端已通过管理API封禁",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.String("subject", subject),
+		slog.Duration("banFor", banFor))
+	return c.JSON(banEntry{Subject: subject, ExpiresAt: time.Now().Add(banFor).Unix()})
+}
+
+// unbanIP 解除客户端IP的封禁
+// DELETE /api/v1/bans/ip/{ip}
+func (h *BanHandler) unbanIP(c fiber.Ctx) error {
+	ip := c.Params("ip")
+	if net.ParseIP(ip) == nil {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidBanSubject)
+	}
+	return h.unban(c, abuse.IPSubject(ip))
+}
+
+// unbanUser 解除用户的封禁
+// DELETE /api/v1/bans/users/{bizId}/{userId}
+func (h *BanHandler) unbanUser(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	return h.unban(c, abuse.UserSubject(bizID, userID))
+}
+
+// unban 解除封禁，同时清零滥用分和封禁次数
+func (h *BanHandler) unban(c fiber.Ctx, subject string) error {
+	if err := h.guard.Unban(c, subject); err != nil {
+		return h.fail(c, err)
+	}
+	h.logger.Info("客户端已通过管理API解除封禁",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.String("subject", subject))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *BanHandler) fail(c fiber.Ctx, err error) error {
+	if errors.Is(err, abuse.ErrDisabled) {
+		return fail(c, fiber.StatusNotImplemented, err)
+	}
+	h.logger.Error("访问封禁名单失败", slog.Any("error", err))
+	return fail(c, fiber.StatusInternalServerError, err)
+}
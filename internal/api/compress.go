@@ -0,0 +1,252 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+const (
+	encodingGzip     = "gzip"
+	encodingDeflate  = "deflate"
+	encodingIdentity = "identity"
+
+	// defaultCompressMinSize 未配置时压缩响应的最小字节数，更小的响应压缩后收益不大，反而浪费CPU
+	defaultCompressMinSize = 1024
+	// defaultMaxDecompressedSize 未配置时请求体解压后的最大字节数，防止压缩炸弹
+	defaultMaxDecompressedSize = 32 << 20
+)
+
+var (
+	ErrUnsupportedEncoding = errors.New("不支持的请求体编码")
+	ErrRequestTooLarge     = errors.New("请求体解压后超过大小限制")
+	ErrInvalidCompression  = errors.New("请求体解压失败")
+)
+
+// defaultCompressTypes 未配置时允许压缩的响应内容类型
+var defaultCompressTypes = []string{"application/json", "text/"}
+
+// httpCompression 管理API的 gzip/deflate 压缩
+// 对请求体按 Content-Encoding 解压（限制解压后的大小），对响应按 Accept-Encoding 压缩，
+// 只压缩超过最小字节数且内容类型在允许列表中的响应。与WebSocket的 permessage-deflate 无关。
+type httpCompression struct {
+	enabled        bool
+	level          int
+	minSize        int
+	maxBodySize    int64
+	contentTypes   []string
+	gzipWriters    sync.Pool
+	deflateWriters sync.Pool
+}
+
+func newHTTPCompression(i do.Injector) (*httpCompression, error) {
+	cfg, err := do.Invoke[config.APIConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	cc := cfg.Compression
+	h := &httpCompression{
+		enabled:      cc.Enabled,
+		level:        cc.Level,
+		minSize:      cc.MinSize,
+		maxBodySize:  cc.MaxRequestSize,
+		contentTypes: cc.ContentTypes,
+	}
+	if h.level == 0 {
+		h.level = gzip.DefaultCompression
+	}
+	if h.level < gzip.HuffmanOnly || h.level > gzip.BestCompression {
+		return nil, fmt.Errorf("无效的管理API压缩级别: %d", cc.Level)
+	}
+	if h.minSize <= 0 {
+		h.minSize = defaultCompressMinSize
+	}
+	if h.maxBodySize <= 0 {
+		h.maxBodySize = defaultMaxDecompressedSize
+	}
+	if len(h.contentTypes) == 0 {
+		h.contentTypes = defaultCompressTypes
+	}
+	return h, nil
+}
+
+// middleware 返回压缩中间件，未启用时直接放行
+func (h *httpCompression) middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !h.enabled {
+			return c.Next()
+		}
+		if err := h.decompressRequest(c); err != nil {
+			status := fiber.StatusBadRequest
+			switch {
+			case errors.Is(err, ErrUnsupportedEncoding):
+				status = fiber.StatusUnsupportedMediaType
+			case errors.Is(err, ErrRequestTooLarge):
+				status = fiber.StatusRequestEntityTooLarge
+			}
+			return fail(c, status, err)
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+		return h.compressResponse(c)
+	}
+}
+
+// decompressRequest 解压请求体并移除 Content-Encoding，后续处理器读到的是原始请求体
+// 在这里解压而不是交给 fiber 的 Ctx.Body，是为了限制解压后的大小
+func (h *httpCompression) decompressRequest(c fiber.Ctx) error {
+	req := c.Request()
+	encoding := strings.ToLower(strings.TrimSpace(string(req.Header.ContentEncoding())))
+	if encoding == "" || encoding == encodingIdentity {
+		return nil
+	}
+
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	body := bytes.NewReader(req.Body())
+	switch encoding {
+	case encodingGzip:
+		r, err = gzip.NewReader(body)
+	case encodingDeflate:
+		r, err = zlib.NewReader(body)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCompression, err)
+	}
+	defer r.Close()
+
+	// 多读一个字节用于判断是否超限
+	plain, err := io.ReadAll(io.LimitReader(r, h.maxBodySize+1))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCompression, err)
+	}
+	if int64(len(plain)) > h.maxBodySize {
+		return ErrRequestTooLarge
+	}
+	req.SetBodyRaw(plain)
+	req.Header.Del(fiber.HeaderContentEncoding)
+	return nil
+}
+
+// compressResponse 按客户端接受的编码压缩响应体
+func (h *httpCompression) compressResponse(c fiber.Ctx) error {
+	resp := c.Response()
+	c.Vary(fiber.HeaderAcceptEncoding)
+	if len(resp.Header.ContentEncoding()) > 0 || len(resp.Body()) < h.minSize {
+		return nil
+	}
+	switch resp.StatusCode() {
+	case fiber.StatusNoContent, fiber.StatusNotModified:
+		return nil
+	}
+	if !h.compressible(string(resp.Header.ContentType())) {
+		return nil
+	}
+	encoding := negotiateEncoding(c.Get(fiber.HeaderAcceptEncoding))
+	if encoding == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := h.encode(&buf, encoding, resp.Body()); err != nil {
+		return err
+	}
+	if buf.Len() >= len(resp.Body()) {
+		// 压缩后反而更大（例如已经压缩过的数据），保留原始响应
+		return nil
+	}
+	resp.SetBodyRaw(buf.Bytes())
+	resp.Header.Set(fiber.HeaderContentEncoding, encoding)
+	return nil
+}
+
+func (h *httpCompression) encode(w io.Writer, encoding string, p []byte) error {
+	var (
+		pool *sync.Pool
+		zw   interface {
+			io.WriteCloser
+			Reset(io.Writer)
+		}
+	)
+	if encoding == encodingGzip {
+		pool = &h.gzipWriters
+		if v := pool.Get(); v != nil {
+			zw = v.(*gzip.Writer)
+		} else {
+			// 级别已在创建时校验，这里不会出错
+			zw, _ = gzip.NewWriterLevel(w, h.level)
+		}
+	} else {
+		pool = &h.deflateWriters
+		if v := pool.Get(); v != nil {
+			zw = v.(*zlib.Writer)
+		} else {
+			zw, _ = zlib.NewWriterLevel(w, h.level)
+		}
+	}
+	defer pool.Put(zw)
+	zw.Reset(w)
+	if _, err := zw.Write(p); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// compressible 返回该内容类型的响应是否允许压缩
+func (h *httpCompression) compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(contentType)
+	for _, t := range h.contentTypes {
+		// 以 / 结尾的配置项按前缀匹配，例如 text/ 匹配所有文本类型
+		if strings.HasSuffix(t, "/") && strings.HasPrefix(contentType, t) || contentType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding 从 Accept-Encoding 中选择压缩编码，优先 gzip，q=0 表示明确拒绝
+func negotiateEncoding(accept string) string {
+	var gzipOK, deflateOK bool
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		switch name {
+		case encodingGzip, "*":
+			gzipOK = true
+		case encodingDeflate:
+			deflateOK = true
+		}
+	}
+	switch {
+	case gzipOK:
+		return encodingGzip
+	case deflateOK:
+		return encodingDeflate
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gobwas/ws"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+var (
+	ErrConnectionNotFound = errors.New("连接不存在")
+	ErrInvalidASN         = errors.New("无效的自治系统号")
+	ErrInvalidUserID      = errors.New("无效的userId")
+	ErrInvalidIdle        = errors.New("无效的空闲时长")
+	ErrInvalidCloseCode   = errors.New("无效的关闭码，只能使用 1000、1001、1008、1011、1013 或 3000-4999")
+	ErrInvalidCloseReason = errors.New("无效的关闭原因，必须是不超过123字节的UTF-8文本")
+)
+
+const (
+	// defaultConnectionListLimit 列出连接时默认返回的最大条数
+	defaultConnectionListLimit = 100
+	// maxConnectionListLimit 列出连接时允许请求的最大条数
+	maxConnectionListLimit = 1000
+	// maxCloseReasonSize 关闭帧中关闭原因的最大字节数，控制帧的负载不能超过125字节，其中2字节为关闭码
+	maxCloseReasonSize = 123
+)
+
+// ConnectionHandler 本节点连接状态查询和踢下线API
+type ConnectionHandler struct {
+	links  *link.Manager
+	logger *log.Logger
+}
+
+func NewConnectionHandler(i do.Injector) (*ConnectionHandler, error) {
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &ConnectionHandler{links: links, logger: logger}, nil
+}
+
+func (h *ConnectionHandler) Register(r fiber.Router) {
+	r.Get("/connections", h.list)
+	r.Get("/connections/:id", h.get)
+	r.Get("/connections/:id/session", h.session)
+	r.Post("/connections/:id/close", h.close)
+	r.Get("/users/:bizId/:userId/connections", h.listByUser)
+	r.Delete("/users/:bizId/:userId/connections", h.kick)
+}
+
+// list 返回本节点上的连接状态，可以按业务方、用户、空闲时长、客户端所在国家和自治系统过滤
+// GET /api/v1/connections?bizId=1&userId=42&idle=5m&country=CN&asn=4134&limit=100
+// idle 为最短空闲时长，只返回至少这么久没有收发消息的连接；total 为满足过滤条件的连接总数，connections 最多返回 limit 条
+func (h *ConnectionHandler) list(c fiber.Ctx) error {
+	var bizID, userID int64
+	if s := c.Query("bizId"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fail(c, fiber.StatusBadRequest, ErrInvalidBizID)
+		}
+		bizID = id
+	}
+	if s := c.Query("userId"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fail(c, fiber.StatusBadRequest, ErrInvalidUserID)
+		}
+		userID = id
+	}
+	var idle time.Duration
+	if s := c.Query("idle"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return fail(c, fiber.StatusBadRequest, ErrInvalidIdle)
+		}
+		idle = d
+	}
+	country := strings.ToUpper(c.Query("country"))
+	var asn uint
+	if s := c.Query("asn"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return fail(c, fiber.StatusBadRequest, ErrInvalidASN)
+		}
+		asn = uint(n)
+	}
+	limit := fiber.Query[int](c, "limit", defaultConnectionListLimit)
+	if limit <= 0 || limit > maxConnectionListLimit {
+		limit = maxConnectionListLimit
+	}
+
+	total := 0
+	stats := make([]link.Stats, 0, min(limit, h.links.Count()))
+	h.links.Range(func(l *link.Link) bool {
+		info := l.Session().UserInfo()
+		if (bizID != 0 && info.BizID != bizID) || (userID != 0 && info.UserID != userID) {
+			return true
+		}
+		if idle > 0 && time.Since(l.LastActiveTime()) < idle {
+			return true
+		}
+		if geo := l.Geo(); (country != "" && geo.Country != country) || (asn != 0 && geo.ASN != asn) {
+			return true
+		}
+		total++
+		if len(stats) < limit {
+			stats = append(stats, l.Stats())
+		}
+		return true
+	})
+	return c.JSON(fiber.Map{
+		"total":       total,
+		"connections": stats,
+	})
+}
+
+// get 返回单个连接的状态，包括发送队列的长度和队头延迟
+// GET /api/v1/connections/{id}
+func (h *ConnectionHandler) get(c fiber.Ctx) error {
+	l, ok := h.links.Get(c.Params("id"))
+	if !ok {
+		return fail(c, fiber.StatusNotFound, ErrConnectionNotFound)
+	}
+	return c.JSON(l.Stats())
+}
+
+// session 返回连接所属会话的字段，字段的访问权限与会话字段API相同
+// GET /api/v1/connections/{id}/session?fields=role,features
+// 同一用户的所有连接共享一个会话；会话已不存在时 fields 为空
+func (h *ConnectionHandler) session(c fiber.Ctx) error {
+	l, ok := h.links.Get(c.Params("id"))
+	if !ok {
+		return fail(c, fiber.StatusNotFound, ErrConnectionNotFound)
+	}
+	fields, status, err := requestedFields(c)
+	if err != nil {
+		return fail(c, status, err)
+	}
+	values, err := readFields(c, l.Session(), fields)
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(fiber.Map{
+		"id":       l.ID(),
+		"userInfo": l.Session().UserInfo(),
+		"fields":   values,
+	})
+}
+
+// close 以指定的关闭码和原因关闭本节点上的一个连接
+// POST /api/v1/connections/{id}/close  body: {"code": 4000, "reason": "maintenance"}
+// 未指定关闭码时为 1000；客户端收到 4409 时不会自动重连，需要让客户端稍后重连时使用 4013 并附带退避建议
+func (h *ConnectionHandler) close(c fiber.Ctx) error {
+	var req struct {
+		Code   int    `json:"code"`
+		Reason string `json:"reason"`
+	}
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	code := ws.StatusCode(req.Code)
+	if req.Code == 0 {
+		code = ws.StatusNormalClosure
+	}
+	if !adminCloseCode(code) {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidCloseCode)
+	}
+	if len(req.Reason) > maxCloseReasonSize || !utf8.ValidString(req.Reason) {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidCloseReason)
+	}
+	id := c.Params("id")
+	found, err := h.links.Disconnect(id, code, req.Reason)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if !found {
+		return fail(c, fiber.StatusNotFound, ErrConnectionNotFound)
+	}
+	h.logger.Info("连接已通过管理API关闭",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.String("linkId", id),
+		slog.Int("code", int(code)),
+		slog.String("reason", req.Reason))
+	return c.JSON(fiber.Map{
+		"id":     id,
+		"code":   code,
+		"reason": req.Reason,
+	})
+}
+
+// adminCloseCode 判断管理API能否使用该关闭码
+// 1002、1007 等表示协议或数据错误的关闭码只应由协议处理本身使用，1005、1006、1015 等只用于本地报告的关闭码不能发送
+func adminCloseCode(code ws.StatusCode) bool {
+	switch code {
+	case ws.StatusNormalClosure, ws.StatusGoingAway, ws.StatusPolicyViolation,
+		ws.StatusInternalServerError, ws.StatusCode(1013):
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+// listByUser 返回用户在本节点上所有连接的状态
+// GET /api/v1/users/{bizId}/{userId}/connections
+func (h *ConnectionHandler) listByUser(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	links := h.links.GetByUser(bizID, userID)
+	stats := make([]link.Stats, 0, len(links))
+	for _, l := range links {
+		stats = append(stats, l.Stats())
+	}
+	return c.JSON(fiber.Map{
+		"bizId":       bizID,
+		"userId":      userID,
+		"connections": stats,
+	})
+}
+
+// kick 将用户在本节点上的所有连接踢下线，返回被关闭的连接数
+// DELETE /api/v1/users/{bizId}/{userId}/connections
+// 只作用于本节点；需要让用户在所有节点上下线时使用吊销API并指定 kick
+func (h *ConnectionHandler) kick(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	kicked := h.links.Kick(bizID, userID)
+	h.logger.Info("用户已通过管理API踢下线",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.Int64("bizId", bizID),
+		slog.Int64("userId", userID),
+		slog.Int("kicked", kicked))
+	return c.JSON(fiber.Map{
+		"bizId":  bizID,
+		"userId": userID,
+		"kicked": kicked,
+	})
+}
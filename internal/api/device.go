@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+var ErrInvalidFingerprint = errors.New("无效的设备指纹")
+
+// DeviceHandler 用户在线设备查询和移除API
+// 设备按握手时确定的设备指纹区分，由会话中的连接记录汇总得到，不依赖用户连接所在的节点
+type DeviceHandler struct {
+	devices session.DeviceManager
+	logger  *log.Logger
+}
+
+func NewDeviceHandler(i do.Injector) (*DeviceHandler, error) {
+	devices, err := do.Invoke[session.DeviceManager](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceHandler{devices: devices, logger: logger}, nil
+}
+
+func (h *DeviceHandler) Register(r fiber.Router) {
+	r.Get("/users/:bizId/:userId/devices", h.list)
+	r.Delete("/users/:bizId/:userId/devices/:fingerprint", h.revoke)
+}
+
+// list 返回用户的在线设备，按上线时间排序
+// GET /api/v1/users/{bizId}/{userId}/devices
+func (h *DeviceHandler) list(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	devices, err := h.devices.Devices(c, bizID, userID)
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(fiber.Map{
+		"bizId":   bizID,
+		"userId":  userID,
+		"devices": devices,
+	})
+}
+
+// revoke 移除用户的一个设备，所有节点上该设备的连接都会被踢下线
+// DELETE /api/v1/users/{bizId}/{userId}/devices/{fingerprint}
+// 指纹需要URL编码；设备之后仍然可以用有效的令牌重新连接，需要禁止时同时使用吊销API
+func (h *DeviceHandler) revoke(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	fingerprint, err := url.PathUnescape(c.Params("fingerprint"))
+	if err != nil || fingerprint == "" {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidFingerprint)
+	}
+	removed, err := h.devices.Revoke(c, bizID, userID, fingerprint)
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	h.logger.Info("设备已通过管理API移除",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.Int64("bizId", bizID),
+		slog.Int64("userId", userID),
+		slog.String("fingerprint", fingerprint),
+		slog.Int("removed", removed))
+	return c.JSON(fiber.Map{
+		"bizId":       bizID,
+		"userId":      userID,
+		"fingerprint": fingerprint,
+		"removed":     removed,
+	})
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
+	"github.com/samber/do/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var ErrGRPCServerStarted = errors.New("gRPC服务已启动")
+
+// GRPCServer 管理API的gRPC服务，监听 api.grpc.addr，提供 AdminService 和 PushService
+// 与HTTP管理API共用API Key和会话字段权限，业务后端的微服务可以不经过HTTP直接推送、踢下线和查询连接与会话
+type GRPCServer struct {
+	enabled bool
+	addr    string
+	auth    *apiKeyAuth
+	server  *grpc.Server
+	logger  *log.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	done     chan struct{}
+}
+
+func NewGRPCServer(i do.Injector) (*GRPCServer, error) {
+	cfg, err := do.Invoke[config.APIConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	s := &GRPCServer{
+		enabled: cfg.GRPC.Enabled,
+		addr:    cfg.GRPC.Addr,
+		logger:  logger,
+		done:    make(chan struct{}),
+	}
+	if !s.enabled {
+		return s, nil
+	}
+	if s.auth, err = newAPIKeyAuth(i); err != nil {
+		return nil, err
+	}
+	admin, err := newAdminService(i)
+	if err != nil {
+		return nil, err
+	}
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor, s.authenticate))
+	gatewayapiv1.RegisterAdminServiceServer(s.server, admin)
+	gatewayapiv1.RegisterPushServiceServer(s.server, &pushService{admin: admin})
+	return s, nil
+}
+
+// Addr 返回实际监听的地址，未启动时返回配置的地址
+func (s *GRPCServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Start 开始监听并在后台处理请求，未启用时不做任何事
+func (s *GRPCServer) Start() error {
+	if !s.enabled {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return ErrGRPCServerStarted
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("监听 %s 失败: %w", s.addr, err)
+	}
+	s.listener = ln
+	go func() {
+		defer close(s.done)
+		if err := s.server.Serve(ln); err != nil {
+			s.logger.Error("gRPC服务异常退出", slog.Any("error", err))
+		}
+	}()
+	return nil
+}
+
+// Stop 优雅停机：停止接收新请求，等待处理中的请求完成；ctx 结束时仍未完成的请求被强制中断
+func (s *GRPCServer) Stop(ctx context.Context) error {
+	if !s.started() {
+		return nil
+	}
+	go s.server.GracefulStop()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		<-s.done
+		return fmt.Errorf("停机宽限期内仍有gRPC请求未完成: %w", ctx.Err())
+	}
+}
+
+// Shutdown 立即停止服务，正常停机应先调用 Stop，Shutdown 作为容器销毁时的兜底
+func (s *GRPCServer) Shutdown() error {
+	if s.started() {
+		s.server.Stop()
+		<-s.done
+	}
+	return nil
+}
+
+func (s *GRPCServer) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listener != nil
+}
+
+// apiKeyContextKey 认证通过后，匹配到的API Key配置在请求上下文中的键
+type apiKeyContextKey struct{}
+
+// authenticate 校验 metadata 中携带的API Key，与HTTP管理API一样支持 x-api-key 和 authorization: Bearer 两种方式
+func (s *GRPCServer) authenticate(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	key := grpcAPIKey(ctx)
+	if key == "" {
+		return nil, status.Error(codes.Unauthenticated, ErrInvalidAPIKey.Error())
+	}
+	k, ok := s.auth.lookup(key)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, ErrInvalidAPIKey.Error())
+	}
+	return handler(context.WithValue(ctx, apiKeyContextKey{}, k), req)
+}
+
+// grpcAPIKey 从 metadata 中取出API Key
+func grpcAPIKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("x-api-key"); len(v) > 0 && v[0] != "" {
+		return v[0]
+	}
+	for _, v := range md.Get("authorization") {
+		if scheme, token, ok := strings.Cut(v, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// grpcAPIKeyFrom 返回当前请求认证通过的API Key配置
+func grpcAPIKeyFrom(ctx context.Context) config.APIKeyConfig {
+	k, _ := ctx.Value(apiKeyContextKey{}).(config.APIKeyConfig)
+	return k
+}
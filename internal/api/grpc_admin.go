@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/push"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrPushMessageRequired = errors.New("必须指定推送消息msg")
+	ErrNotDelivered        = errors.New("推送没有投递到任何连接")
+)
+
+// adminService gatewayapiv1.AdminService 的实现，各方法的语义与对应的HTTP管理API相同
+type adminService struct {
+	gatewayapiv1.UnimplementedAdminServiceServer
+	router *push.Router
+	links  *link.Manager
+	finder session.Finder
+	logger *log.Logger
+}
+
+func newAdminService(i do.Injector) (*adminService, error) {
+	router, err := do.Invoke[*push.Router](i)
+	if err != nil {
+		return nil, err
+	}
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	finder, err := do.Invoke[session.Finder](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &adminService{router: router, links: links, finder: finder, logger: logger}, nil
+}
+
+// PushToUser 与 POST /api/v1/push 相同，HTTP接口返回 404/503/410 的情况分别返回 NOT_FOUND、UNAVAILABLE 和 FAILED_PRECONDITION
+func (s *adminService) PushToUser(ctx context.Context, req *gatewayapiv1.PushToUserRequest) (*gatewayapiv1.PushToUserResponse, error) {
+	msg, err := pushMessage(req.GetMsg())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if msg.GetReceiverId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidUserIdentity.Error())
+	}
+	res, err := s.router.Push(ctx, msg)
+	if err != nil {
+		return nil, pushStatus(err)
+	}
+	if res.Delivered == 0 && res.Retrying == 0 && res.Replaced == 0 && res.Throttled == 0 && res.Relayed == 0 && !res.Stored {
+		if res.Expired > 0 {
+			return nil, status.Error(codes.FailedPrecondition, push.ErrExpired.Error())
+		}
+		return nil, status.Error(codes.Unavailable, ErrNotDelivered.Error())
+	}
+	return &gatewayapiv1.PushToUserResponse{Result: pushResult(res)}, nil
+}
+
+// Broadcast 与 POST /api/v1/push/broadcast 相同，没有投递到任何连接不视为错误
+func (s *adminService) Broadcast(ctx context.Context, req *gatewayapiv1.BroadcastRequest) (*gatewayapiv1.BroadcastResponse, error) {
+	msg, err := pushMessage(req.GetMsg())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	msg.ReceiverId = 0
+	res, err := s.router.Broadcast(ctx, msg)
+	if err != nil {
+		return nil, pushStatus(err)
+	}
+	return &gatewayapiv1.BroadcastResponse{Result: pushResult(res)}, nil
+}
+
+// KickUser 与 DELETE /api/v1/users/{bizId}/{userId}/connections 相同，只作用于本节点
+func (s *adminService) KickUser(ctx context.Context, req *gatewayapiv1.KickUserRequest) (*gatewayapiv1.KickUserResponse, error) {
+	if req.GetBizId() <= 0 || req.GetUserId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidUserIdentity.Error())
+	}
+	kicked := s.links.Kick(req.GetBizId(), req.GetUserId())
+	s.logger.Info("用户已通过gRPC管理API踢下线",
+		slog.String("apiKey", grpcAPIKeyFrom(ctx).Name),
+		slog.Int64("bizId", req.GetBizId()),
+		slog.Int64("userId", req.GetUserId()),
+		slog.Int("kicked", kicked))
+	return &gatewayapiv1.KickUserResponse{Kicked: int32(kicked)}, nil
+}
+
+// ListConnections 与 GET /api/v1/connections 相同，指定了 user_id 时与 GET /api/v1/users/{bizId}/{userId}/connections 相同
+func (s *adminService) ListConnections(_ context.Context, req *gatewayapiv1.ListConnectionsRequest) (*gatewayapiv1.ListConnectionsResponse, error) {
+	bizID, userID := req.GetBizId(), req.GetUserId()
+	if bizID < 0 || userID < 0 || (userID > 0 && bizID == 0) {
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidUserIdentity.Error())
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultConnectionListLimit
+	}
+	if limit < 0 || limit > maxConnectionListLimit {
+		limit = maxConnectionListLimit
+	}
+
+	resp := &gatewayapiv1.ListConnectionsResponse{}
+	add := func(l *link.Link) {
+		resp.Total++
+		if len(resp.Connections) < limit {
+			resp.Connections = append(resp.Connections, connection(l.Stats()))
+		}
+	}
+	if userID > 0 {
+		for _, l := range s.links.GetByUser(bizID, userID) {
+			add(l)
+		}
+		return resp, nil
+	}
+	s.links.Range(func(l *link.Link) bool {
+		if bizID == 0 || l.Session().UserInfo().BizID == bizID {
+			add(l)
+		}
+		return true
+	})
+	return resp, nil
+}
+
+// GetSession 与 GET /api/v1/sessions/{bizId}/{userId}/fields 相同，字段权限按调用方的API Key检查
+func (s *adminService) GetSession(ctx context.Context, req *gatewayapiv1.GetSessionRequest) (*gatewayapiv1.GetSessionResponse, error) {
+	bizID, userID := req.GetBizId(), req.GetUserId()
+	key := grpcAPIKeyFrom(ctx)
+	fields := req.GetFields()
+	if len(fields) == 0 {
+		if slices.Contains(key.SessionFields, allFields) {
+			return nil, status.Error(codes.InvalidArgument, ErrFieldsRequired.Error())
+		}
+		fields = key.SessionFields
+	}
+	for _, f := range fields {
+		if !allowSessionField(key, f) {
+			return nil, status.Error(codes.PermissionDenied, fmt.Errorf("%w: %s", ErrFieldNotAllowed, f).Error())
+		}
+	}
+
+	ss, err := s.finder.Find(ctx, bizID, userID)
+	if err != nil {
+		return nil, s.sessionStatus(err)
+	}
+	pipe := ss.Pipeline()
+	cmds := make([]*session.Cmd, len(fields))
+	for i, f := range fields {
+		cmds[i] = pipe.Get(f)
+	}
+	if err := pipe.Exec(ctx); err != nil {
+		return nil, s.sessionStatus(err)
+	}
+	resp := &gatewayapiv1.GetSessionResponse{BizId: bizID, UserId: userID, Fields: make(map[string]string, len(fields))}
+	for i, f := range fields {
+		v, err := cmds[i].Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		resp.Fields[f] = v
+	}
+	return resp, nil
+}
+
+// sessionStatus 将会话操作错误转换为gRPC状态
+func (s *adminService) sessionStatus(err error) error {
+	if errors.Is(err, session.ErrSessionNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	s.logger.Error("会话操作失败", slog.String("method", "GetSession"), slog.Any("error", err))
+	return status.Error(codes.Internal, err.Error())
+}
+
+// pushService gatewayapiv1.PushService 的实现，与 AdminService.PushToUser 相同，只是不返回投递结果
+type pushService struct {
+	gatewayapiv1.UnimplementedPushServiceServer
+	admin *adminService
+}
+
+func (s *pushService) Push(ctx context.Context, req *gatewayapiv1.PushRequest) (*gatewayapiv1.PushResponse, error) {
+	if _, err := s.admin.PushToUser(ctx, &gatewayapiv1.PushToUserRequest{Msg: req.GetMsg()}); err != nil {
+		return nil, err
+	}
+	return &gatewayapiv1.PushResponse{}, nil
+}
+
+// pushMessage 校验推送消息的公共字段，返回只包含业务方可以指定的字段的副本
+func pushMessage(m *gatewayapiv1.PushMessage) (*gatewayapiv1.PushMessage, error) {
+	if m == nil {
+		return nil, ErrPushMessageRequired
+	}
+	if m.GetBizId() <= 0 {
+		return nil, ErrInvalidBizID
+	}
+	if m.GetKey() == "" {
+		return nil, ErrPushKeyRequired
+	}
+	if m.GetDeadline() < 0 {
+		return nil, ErrInvalidDeadline
+	}
+	return &gatewayapiv1.PushMessage{
+		Key:         m.GetKey(),
+		BizId:       m.GetBizId(),
+		ReceiverId:  m.GetReceiverId(),
+		Body:        m.GetBody(),
+		CollapseKey: m.GetCollapseKey(),
+		Deadline:    m.GetDeadline(),
+	}, nil
+}
+
+// pushStatus 将推送错误转换为gRPC状态
+func pushStatus(err error) error {
+	switch {
+	case errors.Is(err, push.ErrUserOffline):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, push.ErrPushPaused):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, push.ErrExpired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func pushResult(r push.Result) *gatewayapiv1.PushResult {
+	return &gatewayapiv1.PushResult{
+		Links:     int32(r.Links),
+		Delivered: int32(r.Delivered),
+		Retrying:  int32(r.Retrying),
+		Dropped:   int32(r.Dropped),
+		Replaced:  int32(r.Replaced),
+		Expired:   int32(r.Expired),
+		Relayed:   int32(r.Relayed),
+		Seq:       r.Seq,
+		Stored:    r.Stored,
+		Users:     int32(r.Users),
+		Degraded:  int32(r.Degraded),
+		Throttled: int32(r.Throttled),
+	}
+}
+
+func connection(st link.Stats) *gatewayapiv1.Connection {
+	return &gatewayapiv1.Connection{
+		Id:                 st.ID,
+		BizId:              st.BizID,
+		UserId:             st.UserID,
+		DeviceId:           st.DeviceID,
+		RemoteAddr:         st.RemoteAddr,
+		Codec:              st.Codec,
+		Encrypted:          st.Encrypted,
+		Bandwidth:          st.Bandwidth,
+		ConnectedAt:        st.ConnectedAt.UnixMilli(),
+		LastActive:         st.LastActive.UnixMilli(),
+		SendQueueLen:       int32(st.SendQueue.Len),
+		SendQueueCap:       int32(st.SendQueue.Cap),
+		SendQueueOldestAge: st.SendQueue.OldestAge.Milliseconds(),
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/YaoAzure/wsgateway/internal/history"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+// HistoryHandler 用户连接历史查询API
+type HistoryHandler struct {
+	store  *history.Store
+	logger *log.Logger
+}
+
+func NewHistoryHandler(i do.Injector) (*HistoryHandler, error) {
+	store, err := do.Invoke[*history.Store](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryHandler{
+		store:  store,
+		logger: logger,
+	}, nil
+}
+
+func (h *HistoryHandler) Register(r fiber.Router) {
+	r.Get("/users/:bizId/:userId/history", h.list)
+}
+
+// list 返回用户最近的连接/断开记录，最新的在前
+// GET /api/v1/users/{bizId}/{userId}/history
+func (h *HistoryHandler) list(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	events, err := h.store.List(c, bizID, userID)
+	if err != nil {
+		h.logger.Error("查询连接历史失败", slog.Int64("bizId", bizID), slog.Int64("userId", userID), slog.Any("error", err))
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(fiber.Map{
+		"bizId":  bizID,
+		"userId": userID,
+		"events": events,
+	})
+}
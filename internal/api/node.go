@@ -0,0 +1,345 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/limiter"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/scaling"
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/kv"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+var ErrInvalidLogLevel = errors.New("无效的日志级别，可选值为 debug、info、warn、error")
+
+// NodeHandler 本节点运维API
+// 查询节点运行状态、手动摘流和恢复、在故障期间调整连接容量、在排查问题时临时调整日志级别和日志脱敏规则，以及 Kubernetes 的 preStop 钩子；
+// 集群摘流标记可以在任意节点上设置，由目标节点自己摘流
+type NodeHandler struct {
+	links     *link.Manager
+	limiter   *limiter.TokenLimiter
+	monitor   *scaling.Monitor
+	policies  *backoff.Policies
+	level     *log.Level
+	redactor  *log.Redactor
+	flags     *kv.Store
+	nodeID    string
+	startedAt time.Time
+	logger    *log.Logger
+}
+
+func NewNodeHandler(i do.Injector) (*NodeHandler, error) {
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	l, err := do.Invoke[*limiter.TokenLimiter](i)
+	if err != nil {
+		return nil, err
+	}
+	monitor, err := do.Invoke[*scaling.Monitor](i)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := do.Invoke[*backoff.Policies](i)
+	if err != nil {
+		return nil, err
+	}
+	level, err := do.Invoke[*log.Level](i)
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := do.Invoke[*log.Redactor](i)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := do.Invoke[*kv.Store](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &NodeHandler{
+		links:     links,
+		limiter:   l,
+		monitor:   monitor,
+		policies:  policies,
+		level:     level,
+		redactor:  redactor,
+		flags:     flags,
+		nodeID:    appCfg.InstanceID(),
+		startedAt: time.Now(),
+		logger:    logger,
+	}, nil
+}
+
+func (h *NodeHandler) Register(r fiber.Router) {
+	r.Get("/node/stats", h.stats)
+	r.Post("/node/drain", h.drain)
+	r.Delete("/node/drain", h.undrain)
+	// kubelet 的 httpGet 钩子只能发送 GET 请求
+	r.Get("/node/prestop", h.preStop)
+	r.Post("/node/prestop", h.preStop)
+	r.Get("/node/capacity", h.getCapacity)
+	r.Put("/node/capacity", h.setCapacity)
+	r.Get("/node/log-level", h.getLogLevel)
+	r.Put("/node/log-level", h.setLogLevel)
+	r.Get("/node/log-redaction", h.getLogRedaction)
+	r.Put("/node/log-redaction", h.setLogRedaction)
+	r.Get("/cluster/drain", h.listDrainFlags)
+	r.Put("/cluster/drain/:nodeId", h.setDrainFlag)
+	r.Delete("/cluster/drain/:nodeId", h.deleteDrainFlag)
+}
+
+// nodeStats 节点运行状态
+type nodeStats struct {
+	NodeID      string          `json:"nodeId"`
+	StartedAt   time.Time       `json:"startedAt"`
+	Uptime      string          `json:"uptime"`
+	Draining    bool            `json:"draining"`
+	Connections int             `json:"connections"`
+	Users       int             `json:"users"`
+	ByBiz       map[int64]int   `json:"byBiz"`
+	Goroutines  int             `json:"goroutines"`
+	Memory      nodeMemoryStats `json:"memory"`
+	LogLevel    string          `json:"logLevel"`
+}
+
+type nodeMemoryStats struct {
+	HeapAlloc uint64 `json:"heapAlloc"`
+	HeapInuse uint64 `json:"heapInuse"`
+	Sys       uint64 `json:"sys"`
+	NumGC     uint32 `json:"numGC"`
+}
+
+// stats 返回本节点的连接数、摘流状态和运行时状态
+// GET /api/v1/node/stats
+func (h *NodeHandler) stats(c fiber.Ctx) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return c.JSON(nodeStats{
+		NodeID:      h.nodeID,
+		StartedAt:   h.startedAt,
+		Uptime:      time.Since(h.startedAt).Round(time.Second).String(),
+		Draining:    h.links.Draining(),
+		Connections: h.links.Count(),
+		Users:       h.links.CountUsers(),
+		ByBiz:       h.links.CountsByBiz(),
+		Goroutines:  runtime.NumGoroutine(),
+		Memory: nodeMemoryStats{
+			HeapAlloc: mem.HeapAlloc,
+			HeapInuse: mem.HeapInuse,
+			Sys:       mem.Sys,
+			NumGC:     mem.NumGC,
+		},
+		LogLevel: strings.ToLower(h.level.Level().String()),
+	})
+}
+
+// drain 手动摘流：拒绝新连接，并以 4013 和重连退避建议关闭所有已有连接，客户端重连到其它节点
+// POST /api/v1/node/drain
+// 与停机摘流不同，节点继续运行，可以通过 DELETE 恢复
+func (h *NodeHandler) drain(c fiber.Ctx) error {
+	h.links.Drain(h.policies.Advice(backoff.ReasonDrain))
+	h.logger.Warn("节点已通过管理API摘流",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.Int("connections", h.links.Count()))
+	return c.JSON(fiber.Map{"draining": true, "connections": h.links.Count()})
+}
+
+// undrain 退出摘流状态，重新接受新连接
+// DELETE /api/v1/node/drain
+func (h *NodeHandler) undrain(c fiber.Ctx) error {
+	h.links.Undrain()
+	h.logger.Warn("节点已通过管理API恢复接收连接", slog.String("apiKey", apiKeyFrom(c).Name))
+	return c.JSON(fiber.Map{"draining": false, "connections": h.links.Count()})
+}
+
+// listDrainFlags 返回设置了集群摘流标记的节点，值为设置标记的API Key
+// GET /api/v1/cluster/drain
+func (h *NodeHandler) listDrainFlags(c fiber.Ctx) error {
+	nodes := make(map[string]string)
+	for k, v := range h.flags.List(scaling.DrainFlagPrefix) {
+		nodes[strings.TrimPrefix(k, scaling.DrainFlagPrefix)] = v
+	}
+	return c.JSON(fiber.Map{"nodes": nodes})
+}
+
+// setDrainFlag 为节点设置集群摘流标记，目标节点收到后摘流，标记保留到被删除，节点重启后仍然生效
+// PUT /api/v1/cluster/drain/{nodeId}
+// 与 POST /node/drain 不同，可以在任意节点上调用，不需要直接访问目标节点
+func (h *NodeHandler) setDrainFlag(c fiber.Ctx) error {
+	nodeID := c.Params("nodeId")
+	if err := h.flags.Set(c, scaling.DrainFlagKey(nodeID), apiKeyFrom(c).Name); err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	h.logger.Warn("已通过管理API设置集群摘流标记",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.String("nodeId", nodeID))
+	return c.JSON(fiber.Map{"nodeId": nodeID, "draining": true})
+}
+
+// deleteDrainFlag 删除节点的集群摘流标记，目标节点恢复接收连接
+// DELETE /api/v1/cluster/drain/{nodeId}
+func (h *NodeHandler) deleteDrainFlag(c fiber.Ctx) error {
+	nodeID := c.Params("nodeId")
+	if err := h.flags.Delete(c, scaling.DrainFlagKey(nodeID)); err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	h.logger.Warn("已通过管理API删除集群摘流标记",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.String("nodeId", nodeID))
+	return c.JSON(fiber.Map{"nodeId": nodeID, "draining": false})
+}
+
+// preStop 供 Kubernetes preStop 钩子调用：就绪探针先失败，等待 Service 摘除本节点后摘流，
+// 阻塞到所有连接关闭或超过停机宽限期；节点随后会收到 SIGTERM，不能再恢复
+// GET|POST /api/v1/node/prestop
+func (h *NodeHandler) preStop(c fiber.Ctx) error {
+	h.logger.Warn("preStop 钩子已触发", slog.String("apiKey", apiKeyFrom(c).Name))
+	err := h.monitor.PreStop(c)
+	switch {
+	case errors.Is(err, scaling.ErrPreStopInProgress):
+		return fail(c, fiber.StatusConflict, err)
+	case err != nil:
+		// 宽限期内未关闭的连接已被强制关闭，摘流本身已经完成
+		h.logger.Warn("preStop 摘流未能在宽限期内完成", slog.Any("error", err))
+	}
+	return c.JSON(fiber.Map{"draining": true, "connections": h.links.Count()})
+}
+
+// nodeCapacity 连接容量
+type nodeCapacity struct {
+	Capacity    int64 `json:"capacity"`    // 当前容量
+	MaxCapacity int64 `json:"maxCapacity"` // 配置的最大容量
+	Available   int64 `json:"available"`   // 还能接受的新连接数
+	Reclaiming  int64 `json:"reclaiming"`  // 缩容后仍被已有连接占用、关闭时收回的令牌数
+	Overridden  bool  `json:"overridden"`  // 容量已被手动调整，不再预热
+	Connections int   `json:"connections"`
+}
+
+func (h *NodeHandler) capacity() nodeCapacity {
+	return nodeCapacity{
+		Capacity:    h.limiter.CurrentCapacity(),
+		MaxCapacity: h.limiter.MaxCapacity(),
+		Available:   h.limiter.Available(),
+		Reclaiming:  h.limiter.Reclaiming(),
+		Overridden:  h.limiter.Overridden(),
+		Connections: h.links.Count(),
+	}
+}
+
+// getCapacity 返回连接令牌的容量和使用情况
+// GET /api/v1/node/capacity
+func (h *NodeHandler) getCapacity(c fiber.Ctx) error {
+	return c.JSON(h.capacity())
+}
+
+// setCapacity 调整本节点可同时持有的连接数，只在本节点上生效，重启后恢复为配置文件中的预热设置
+// PUT /api/v1/node/capacity  body: {"capacity": 500}
+// 缩容不关闭已有连接，超出新容量的连接关闭后不再接受新连接补位；需要立即迁走连接时使用摘流
+func (h *NodeHandler) setCapacity(c fiber.Ctx) error {
+	var req struct {
+		Capacity *int64 `json:"capacity"`
+	}
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if req.Capacity == nil {
+		return fail(c, fiber.StatusBadRequest, limiter.ErrInvalidCapacity)
+	}
+	previous := h.limiter.CurrentCapacity()
+	if err := h.limiter.SetCapacity(*req.Capacity); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	h.logger.Warn("连接容量已通过管理API调整",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.Int64("from", previous),
+		slog.Int64("to", *req.Capacity),
+		slog.Int("connections", h.links.Count()))
+	return c.JSON(h.capacity())
+}
+
+// logLevel 日志级别的请求和响应体
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// getLogLevel 返回当前的日志级别
+// GET /api/v1/node/log-level
+func (h *NodeHandler) getLogLevel(c fiber.Ctx) error {
+	return c.JSON(logLevel{Level: strings.ToLower(h.level.Level().String())})
+}
+
+// setLogLevel 调整日志级别，只在本节点上生效，重启后恢复为配置文件中的级别
+// PUT /api/v1/node/log-level  body: {"level": "debug"}
+func (h *NodeHandler) setLogLevel(c fiber.Ctx) error {
+	var req logLevel
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	level, ok := log.ParseLevel(req.Level)
+	if !ok {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidLogLevel)
+	}
+	previous := h.level.Level()
+	h.level.Set(level)
+	h.logger.Warn("日志级别已通过管理API调整",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.String("from", strings.ToLower(previous.String())),
+		slog.String("to", req.Level))
+	return c.JSON(logLevel{Level: req.Level})
+}
+
+// logRedaction 日志脱敏规则的请求和响应体，字段含义与配置文件中的 log.redaction 相同
+type logRedaction struct {
+	Mask        string   `json:"mask"`
+	QueryParams []string `json:"queryParams"`
+	Headers     []string `json:"headers"`
+	Fields      []string `json:"fields"`
+}
+
+// getLogRedaction 返回当前的日志脱敏规则
+// GET /api/v1/node/log-redaction
+func (h *NodeHandler) getLogRedaction(c fiber.Ctx) error {
+	r := h.redactor.Rules()
+	return c.JSON(logRedaction{Mask: r.Mask, QueryParams: r.QueryParams, Headers: r.Headers, Fields: r.Fields})
+}
+
+// setLogRedaction 整体替换日志脱敏规则，只在本节点上生效，重启后恢复为配置文件中的规则
+// PUT /api/v1/node/log-redaction  body: {"queryParams": ["token"], "headers": ["authorization"], "fields": ["user.phone"]}
+func (h *NodeHandler) setLogRedaction(c fiber.Ctx) error {
+	var req logRedaction
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	err := h.redactor.Set(config.LogRedactionConfig{
+		Mask:        req.Mask,
+		QueryParams: req.QueryParams,
+		Headers:     req.Headers,
+		Fields:      req.Fields,
+	})
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	h.logger.Warn("日志脱敏规则已通过管理API调整",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.Any("queryParams", req.QueryParams),
+		slog.Any("headers", req.Headers),
+		slog.Any("fields", req.Fields))
+	return c.JSON(req)
+}
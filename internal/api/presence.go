@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+// maxPresenceUsers 一次批量查询在线状态最多指定的用户数
+const maxPresenceUsers = 1000
+
+var ErrTooManyPresenceUsers = fmt.Errorf("一次最多查询%d个用户的在线状态", maxPresenceUsers)
+
+// PresenceHandler 用户在线状态查询API
+//   - /presence：从会话中的连接记录查询用户是否在线、在哪些节点上、何时上线、有几个设备，不依赖用户连接所在的节点
+//   - /users/{bizId}/{userId}/presence：多节点部署时按会话中记录的节点查询，并返回用户在本节点上的连接详情
+type PresenceHandler struct {
+	links   *link.Manager
+	finder  session.Finder
+	reader  session.PresenceReader
+	locator session.Locator // 未启用多节点部署时为 nil
+	nodeID  string
+}
+
+func NewPresenceHandler(i do.Injector) (*PresenceHandler, error) {
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	finder, err := do.Invoke[session.Finder](i)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := do.Invoke[session.PresenceReader](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	clusterCfg, err := do.Invoke[config.ClusterConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	h := &PresenceHandler{
+		links:  links,
+		finder: finder,
+		reader: reader,
+		nodeID: appCfg.InstanceID(),
+	}
+	if clusterCfg.Enabled {
+		if h.locator, err = do.Invoke[session.Locator](i); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func (h *PresenceHandler) Register(r fiber.Router) {
+	r.Get("/users/:bizId/:userId/presence", h.get)
+	r.Get("/presence/:bizId/:userId", h.query)
+	r.Get("/presence/:bizId", h.queryMany)
+}
+
+// query 从会话记录返回用户的在线状态
+// GET /api/v1/presence/{bizId}/{userId}
+func (h *PresenceHandler) query(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	p, err := h.reader.Presence(c, bizID, userID)
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(p)
+}
+
+// presences 批量查询在线状态的响应体
+type presences struct {
+	BizID  int64              `json:"bizId"`
+	Online int                `json:"online"` // 在线的用户数
+	Users  []session.Presence `json:"users"`  // 与请求的 userIds 顺序一致
+}
+
+// queryMany 批量返回同一业务方多个用户的在线状态，所有用户在一次Redis往返中查询
+// GET /api/v1/presence/{bizId}?userIds=2,3,4
+func (h *PresenceHandler) queryMany(c fiber.Ctx) error {
+	bizID, err := strconv.ParseInt(c.Params("bizId"), 10, 64)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidUserIdentity)
+	}
+	ids := splitFields(c.Query("userIds"))
+	userIDs := make([]int64, 0, len(ids))
+	for _, s := range ids {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			return fail(c, fiber.StatusBadRequest, ErrUserIDsRequired)
+		}
+		userIDs = append(userIDs, id)
+	}
+	if len(userIDs) == 0 {
+		return fail(c, fiber.StatusBadRequest, ErrUserIDsRequired)
+	}
+	if len(userIDs) > maxPresenceUsers {
+		return fail(c, fiber.StatusBadRequest, ErrTooManyPresenceUsers)
+	}
+	users, err := h.reader.Presences(c, bizID, userIDs)
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	resp := presences{BizID: bizID, Users: users}
+	for _, p := range users {
+		if p.Online {
+			resp.Online++
+		}
+	}
+	return c.JSON(resp)
+}
+
+// presence 用户的在线状态
+type presence struct {
+	BizID   int64        `json:"bizId"`
+	UserID  int64        `json:"userId"`
+	Online  bool         `json:"online"`
+	Session bool         `json:"session"` // 会话是否存在，用户离线后会话可能保留到过期
+	Nodes   []string     `json:"nodes"`   // 持有用户连接的节点
+	Local   []link.Stats `json:"local"`   // 用户在本节点上的连接
+}
+
+// get 返回用户的在线状态
+// GET /api/v1/users/{bizId}/{userId}/presence
+func (h *PresenceHandler) get(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	p := presence{BizID: bizID, UserID: userID, Nodes: []string{}, Local: []link.Stats{}}
+	for _, l := range h.links.GetByUser(bizID, userID) {
+		p.Local = append(p.Local, l.Stats())
+	}
+
+	switch _, err := h.finder.Find(c, bizID, userID); {
+	case err == nil:
+		p.Session = true
+	case !errors.Is(err, session.ErrSessionNotFound):
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	if h.locator != nil && p.Session {
+		nodes, err := h.locator.Nodes(c, bizID, userID)
+		if err != nil {
+			return fail(c, fiber.StatusInternalServerError, err)
+		}
+		p.Nodes = nodes
+	}
+	// 未启用多节点部署时会话中没有节点记录；节点记录写入前也可能已经有本地连接
+	if len(p.Local) > 0 && !slices.Contains(p.Nodes, h.nodeID) {
+		p.Nodes = append(p.Nodes, h.nodeID)
+	}
+	p.Online = len(p.Nodes) > 0
+	return c.JSON(p)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义管理API包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewSessionHandler),
+	do.Lazy(NewStatsHandler),
+	do.Lazy(NewHistoryHandler),
+	do.Lazy(NewPushHandler),
+	do.Lazy(NewRoomHandler),
+	do.Lazy(NewConnectionHandler),
+	do.Lazy(NewRevocationHandler),
+	do.Lazy(NewBanHandler),
+	do.Lazy(NewPresenceHandler),
+	do.Lazy(NewDeviceHandler),
+	do.Lazy(NewNodeHandler),
+	do.Lazy(NewSubsystemHandler),
+	do.Lazy(NewRouter),
+	do.Lazy(NewGRPCServer),
+)
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/push"
+	"github.com/YaoAzure/wsgateway/internal/rooms"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+var (
+	ErrPushKeyRequired = errors.New("必须指定推送消息的key，用于客户端去重")
+	ErrUserIDsRequired = errors.New("必须指定userIds，且每个userId都必须大于0")
+	ErrTooManyUserIDs  = fmt.Errorf("一次最多指定%d个userId", maxMulticastUsers)
+	ErrInvalidDeadline = errors.New("deadline 必须是Unix毫秒时间戳，不限制时为0或不指定")
+)
+
+// maxMulticastUsers 一次多用户推送或分组成员变更最多指定的用户数
+const maxMulticastUsers = 10000
+
+// PushHandler 下行推送API
+// 业务后端通过该API向在线用户推送消息，用户连接在其它节点上时由 push.Router 转发
+type PushHandler struct {
+	router *push.Router
+}
+
+func NewPushHandler(i do.Injector) (*PushHandler, error) {
+	router, err := do.Invoke[*push.Router](i)
+	if err != nil {
+		return nil, err
+	}
+	return &PushHandler{router: router}, nil
+}
+
+func (h *PushHandler) Register(r fiber.Router) {
+	r.Post("/push", h.push)
+	r.Post("/push/broadcast", h.broadcast)
+	r.Post("/push/multicast", h.multicast)
+	r.Post("/push/group", h.pushGroup)
+	r.Post("/push/room", h.pushRoom)
+	r.Get("/push/groups/:bizId/:name", h.getGroup)
+	r.Delete("/push/groups/:bizId/:name", h.deleteGroup)
+	r.Post("/push/groups/:bizId/:name/members", h.addMembers)
+	r.Delete("/push/groups/:bizId/:name/members", h.removeMembers)
+}
+
+// pushRequest 推送请求体，body 为 base64 编码的业务消息体
+// collapseKey 可选，折叠键相同、尚未送达的旧消息会被这条消息替换；
+// deadline 可选，为投递截止时间（Unix 毫秒时间戳），验证码、竞价这类过期后送达反而有害的消息应当指定
+type pushRequest struct {
+	BizID       int64  `json:"bizId"`
+	UserID      int64  `json:"userId"`
+	Key         string `json:"key"`
+	CollapseKey string `json:"collapseKey"`
+	Body        []byte `json:"body"`
+	Deadline    int64  `json:"deadline"`
+}
+
+// push 向用户推送一条下行消息
+// POST /api/v1/push  body: {"bizId": 1, "userId": 2, "key": "uuid", "collapseKey": "score:42", "body": "base64"}
+// 消息放入发送缓冲区或转发到持有连接的节点即返回；缓冲区已满的连接在后台重试，用户在所有节点上都不在线时返回 404。
+// 启用会话恢复时离线用户的推送同样写入缓冲区，用户在有效期内重连后补发；
+// 启用离线消息时用户不在线的推送保存为离线消息，返回 200 且 stored 为 true。
+// 指定了 deadline 时，推送在到达截止时间前仍未写出的不再投递：请求时已过期，或本节点上的连接都因过期而没有投递时返回 410，
+// 之后在重试、发送队列、其它节点、离线消息和会话恢复补发中过期的推送同样被放弃，计入 gateway_push_expired_total 指标
+func (h *PushHandler) push(c fiber.Ctx) error {
+	var req pushRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if req.BizID <= 0 || req.UserID <= 0 {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidUserIdentity)
+	}
+	if req.Key == "" {
+		return fail(c, fiber.StatusBadRequest, ErrPushKeyRequired)
+	}
+	if req.Deadline < 0 {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidDeadline)
+	}
+
+	res, err := h.router.Push(c, &gatewayapiv1.PushMessage{
+		Key:         req.Key,
+		BizId:       req.BizID,
+		ReceiverId:  req.UserID,
+		Body:        req.Body,
+		CollapseKey: req.CollapseKey,
+		Deadline:    req.Deadline,
+	})
+	if errors.Is(err, push.ErrUserOffline) {
+		return fail(c, fiber.StatusNotFound, err)
+	}
+	if errors.Is(err, push.ErrPushPaused) {
+		return fail(c, fiber.StatusServiceUnavailable, err)
+	}
+	if errors.Is(err, push.ErrExpired) {
+		return fail(c, fiber.StatusGone, err)
+	}
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	if res.Delivered == 0 && res.Retrying == 0 && res.Replaced == 0 && res.Throttled == 0 && res.Relayed == 0 && !res.Stored {
+		if res.Expired > 0 {
+			return c.Status(fiber.StatusGone).JSON(res)
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(res)
+	}
+	return c.JSON(res)
+}
+
+// fanoutRequest 群发请求体，bizId 和 deadline 之外的字段与 pushRequest 相同
+// 多用户推送时 userIds 为目标用户，分组推送时 group 为分组名称，房间推送时 room 为房间名称，广播时都不需要
+type fanoutRequest struct {
+	BizID       int64   `json:"bizId"`
+	UserIDs     []int64 `json:"userIds"`
+	Group       string  `json:"group"`
+	Room        string  `json:"room"`
+	Key         string  `json:"key"`
+	CollapseKey string  `json:"collapseKey"`
+	Body        []byte  `json:"body"`
+	Deadline    int64   `json:"deadline"`
+}
+
+func (req *fanoutRequest) message() *gatewayapiv1.PushMessage {
+	return &gatewayapiv1.PushMessage{
+		Key:         req.Key,
+		BizId:       req.BizID,
+		Body:        req.Body,
+		CollapseKey: req.CollapseKey,
+		Deadline:    req.Deadline,
+	}
+}
+
+// bindFanout 解析并校验群发请求的公共字段
+func bindFanout(c fiber.Ctx) (fanoutRequest, error) {
+	var req fanoutRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return req, err
+	}
+	if req.BizID <= 0 {
+		return req, ErrInvalidBizID
+	}
+	if req.Key == "" {
+		return req, ErrPushKeyRequired
+	}
+	if req.Deadline < 0 {
+		return req, ErrInvalidDeadline
+	}
+	return req, nil
+}
+
+// broadcast 向业务方在所有节点上的所有连接推送一条下行消息
+// POST /api/v1/push/broadcast  body: {"bizId": 1, "key": "uuid", "collapseKey": "notice", "body": "base64"}
+// 群发不写入会话恢复缓冲区和离线消息，只投递给当前在线的连接。响应中的连接计数只包含处理请求的节点，
+// relayed 为收到群发的其它节点数，failures 列出本节点上投递失败的连接（最多100个）
+func (h *PushHandler) broadcast(c fiber.Ctx) error {
+	req, err := bindFanout(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	res, err := h.router.Broadcast(c, req.message())
+	return fanoutResponse(c, res, err)
+}
+
+// multicast 向业务方的一组用户在所有节点上的连接推送一条下行消息，重复的userId只推送一次
+// POST /api/v1/push/multicast  body: {"bizId": 1, "userIds": [2, 3], "key": "uuid", "body": "base64"}
+// 响应与 broadcast 相同，users 为在本节点上有连接的目标用户数
+func (h *PushHandler) multicast(c fiber.Ctx) error {
+	req, err := bindFanout(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if err := checkUserIDs(req.UserIDs); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	res, err := h.router.Multicast(c, req.message(), req.UserIDs)
+	return fanoutResponse(c, res, err)
+}
+
+// pushGroup 向业务方的命名分组中的所有成员推送一条下行消息
+// POST /api/v1/push/group  body: {"bizId": 1, "group": "vip", "key": "uuid", "body": "base64"}
+// 分组不存在或没有成员时返回 200 且各项计数为 0
+func (h *PushHandler) pushGroup(c fiber.Ctx) error {
+	req, err := bindFanout(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if !push.ValidGroupName(req.Group) {
+		return fail(c, fiber.StatusBadRequest, push.ErrInvalidGroupName)
+	}
+	res, err := h.router.PushGroup(c, req.message(), req.Group)
+	return fanoutResponse(c, res, err)
+}
+
+// pushRoom 向业务方的房间中所有节点上的连接推送一条下行消息，未启用房间时返回 404
+// POST /api/v1/push/room  body: {"bizId": 1, "room": "live:42", "key": "uuid", "body": "base64"}
+func (h *PushHandler) pushRoom(c fiber.Ctx) error {
+	req, err := bindFanout(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if !rooms.ValidName(req.Room) {
+		return fail(c, fiber.StatusBadRequest, rooms.ErrInvalidName)
+	}
+	res, err := h.router.PushRoom(c, req.message(), req.Room)
+	if errors.Is(err, rooms.ErrDisabled) {
+		return fail(c, fiber.StatusNotFound, err)
+	}
+	return fanoutResponse(c, res, err)
+}
+
+// fanoutResponse 群发的目标用户可能都不在线，没有投递到任何连接不视为错误；请求时已超过投递截止时间返回 410
+func fanoutResponse(c fiber.Ctx, res push.Result, err error) error {
+	if errors.Is(err, push.ErrPushPaused) {
+		return fail(c, fiber.StatusServiceUnavailable, err)
+	}
+	if errors.Is(err, push.ErrExpired) {
+		return fail(c, fiber.StatusGone, err)
+	}
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(res)
+}
+
+func checkUserIDs(userIDs []int64) error {
+	if len(userIDs) == 0 {
+		return ErrUserIDsRequired
+	}
+	if len(userIDs) > maxMulticastUsers {
+		return ErrTooManyUserIDs
+	}
+	for _, id := range userIDs {
+		if id <= 0 {
+			return ErrUserIDsRequired
+		}
+	}
+	return nil
+}
+
+// groupMembers 分组成员的请求和响应体
+type groupMembers struct {
+	BizID   int64   `json:"bizId"`
+	Name    string  `json:"name"`
+	UserIDs []int64 `json:"userIds"`
+	Changed int64   `json:"changed,omitempty"` // 实际加入或移出的用户数
+}
+
+// groupIdentity 解析路径中的业务方和分组名称
+func groupIdentity(c fiber.Ctx) (int64, string, error) {
+	bizID, err := strconv.ParseInt(c.Params("bizId"), 10, 64)
+	if err != nil || bizID <= 0 {
+		return 0, "", ErrInvalidBizID
+	}
+	name := c.Params("name")
+	if !push.ValidGroupName(name) {
+		return 0, "", push.ErrInvalidGroupName
+	}
+	return bizID, name, nil
+}
+
+// getGroup 返回分组的成员，分组不存在时成员列表为空
+// GET /api/v1/push/groups/{bizId}/{name}
+func (h *PushHandler) getGroup(c fiber.Ctx) error {
+	bizID, name, err := groupIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	userIDs, err := h.router.Groups().Members(c, bizID, name)
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(groupMembers{BizID: bizID, Name: name, UserIDs: userIDs})
+}
+
+// deleteGroup 删除整个分组
+// DELETE /api/v1/push/groups/{bizId}/{name}
+func (h *PushHandler) deleteGroup(c fiber.Ctx) error {
+	bizID, name, err := groupIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if err := h.router.Groups().Delete(c, bizID, name); err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// addMembers 把用户加入分组，分组不存在时自动创建
+// POST /api/v1/push/groups/{bizId}/{name}/members  body: {"userIds": [2, 3]}
+func (h *PushHandler) addMembers(c fiber.Ctx) error {
+	return h.changeMembers(c, h.router.Groups().Add)
+}
+
+// removeMembers 把用户移出分组
+// DELETE /api/v1/push/groups/{bizId}/{name}/members  body: {"userIds": [2, 3]}
+func (h *PushHandler) removeMembers(c fiber.Ctx) error {
+	return h.changeMembers(c, h.router.Groups().Remove)
+}
+
+func (h *PushHandler) changeMembers(c fiber.Ctx, change func(ctx context.Context, bizID int64, name string, userIDs ...int64) (int64, error)) error {
+	bizID, name, err := groupIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	var req groupMembers
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if err := checkUserIDs(req.UserIDs); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	changed, err := change(c, bizID, name, req.UserIDs...)
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(groupMembers{BizID: bizID, Name: name, UserIDs: req.UserIDs, Changed: changed})
+}
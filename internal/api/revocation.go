@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/revocation"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+// RevocationHandler 令牌吊销API
+// 令牌被盗用或用户修改密码、被封禁时，业务后端通过该API让尚未过期的令牌立即失效
+type RevocationHandler struct {
+	store  *revocation.Store
+	logger *log.Logger
+}
+
+func NewRevocationHandler(i do.Injector) (*RevocationHandler, error) {
+	store, err := do.Invoke[*revocation.Store](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &RevocationHandler{store: store, logger: logger}, nil
+}
+
+func (h *RevocationHandler) Register(r fiber.Router) {
+	r.Post("/revocations", h.revoke)
+}
+
+// revocationRequest 吊销请求体，时间均为 Unix 秒
+type revocationRequest struct {
+	JTI       string `json:"jti"`
+	ExpiresAt int64  `json:"expiresAt"`
+	BizID     int64  `json:"bizId"`
+	UserID    int64  `json:"userId"`
+	Before    int64  `json:"before"`
+	Kick      bool   `json:"kick"`
+}
+
+// revoke 添加一条吊销记录
+// POST /api/v1/revocations  body: {"jti": "token-id", "expiresAt": 1700000000}
+// POST /api/v1/revocations  body: {"bizId": 1, "userId": 2, "before": 1700000000, "kick": true}
+// 按 jti 吊销单个令牌；或按用户吊销 before (默认当前时间) 及之前签发的全部令牌，kick 为 true 时同时踢下线用户已有的连接
+func (h *RevocationHandler) revoke(c fiber.Ctx) error {
+	var req revocationRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	r := revocation.Revocation{JTI: req.JTI}
+	if req.JTI != "" {
+		if req.ExpiresAt > 0 {
+			r.ExpiresAt = time.Unix(req.ExpiresAt, 0)
+		}
+	} else {
+		r.BizID, r.UserID, r.Kick = req.BizID, req.UserID, req.Kick
+		if req.Before <= 0 {
+			req.Before = time.Now().Unix()
+		}
+		r.Before = time.Unix(req.Before, 0)
+	}
+
+	err := h.store.Revoke(c, r)
+	switch {
+	case errors.Is(err, revocation.ErrInvalidRevocation):
+		return fail(c, fiber.StatusBadRequest, err)
+	case errors.Is(err, revocation.ErrDisabled):
+		return fail(c, fiber.StatusNotImplemented, err)
+	case err != nil:
+		h.logger.Error("添加吊销记录失败", slog.Any("error", err))
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	h.logger.Info("令牌吊销已通过管理API添加",
+		slog.String("apiKey", apiKeyFrom(c).Name),
+		slog.String("jti", r.JTI),
+		slog.Int64("bizId", r.BizID),
+		slog.Int64("userId", r.UserID))
+	return c.JSON(req)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/YaoAzure/wsgateway/internal/rooms"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+// RoomHandler 房间管理API
+// 业务后端通过该API查询房间成员，或把用户当前在所有节点上的连接加入、移出房间
+type RoomHandler struct {
+	rooms *rooms.Rooms
+}
+
+func NewRoomHandler(i do.Injector) (*RoomHandler, error) {
+	r, err := do.Invoke[*rooms.Rooms](i)
+	if err != nil {
+		return nil, err
+	}
+	return &RoomHandler{rooms: r}, nil
+}
+
+func (h *RoomHandler) Register(r fiber.Router) {
+	r.Get("/rooms/:bizId/:name", h.get)
+	r.Get("/rooms/:bizId/:name/messages", h.messages)
+	r.Post("/rooms/:bizId/:name/members", h.join)
+	r.Delete("/rooms/:bizId/:name/members", h.leave)
+}
+
+// roomMembers 房间成员的请求和响应体
+type roomMembers struct {
+	BizID   int64   `json:"bizId"`
+	Name    string  `json:"name"`
+	UserIDs []int64 `json:"userIds"`
+	Nodes   int64   `json:"nodes,omitempty"` // 收到加入或退出事件的节点数
+}
+
+// roomIdentity 解析路径中的业务方和房间名称
+func roomIdentity(c fiber.Ctx) (int64, string, error) {
+	bizID, err := strconv.ParseInt(c.Params("bizId"), 10, 64)
+	if err != nil || bizID <= 0 {
+		return 0, "", ErrInvalidBizID
+	}
+	name := c.Params("name")
+	if !rooms.ValidName(name) {
+		return 0, "", rooms.ErrInvalidName
+	}
+	return bizID, name, nil
+}
+
+// get 返回房间在所有节点上的成员用户
+// GET /api/v1/rooms/{bizId}/{name}
+func (h *RoomHandler) get(c fiber.Ctx) error {
+	bizID, name, err := roomIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	userIDs, err := h.rooms.Members(c, bizID, name)
+	if errors.Is(err, rooms.ErrDisabled) {
+		return fail(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(roomMembers{BizID: bizID, Name: name, UserIDs: userIDs})
+}
+
+// messages 返回房间存档中最近的推送，按推送的先后排序；limit 为返回的条数，默认为业务方存档的所有消息
+// 未启用房间或业务方未启用存档时返回 404
+// GET /api/v1/rooms/{bizId}/{name}/messages?limit=20
+func (h *RoomHandler) messages(c fiber.Ctx) error {
+	bizID, name, err := roomIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	messages, err := h.rooms.History(c, bizID, name, fiber.Query[int](c, "limit"))
+	if errors.Is(err, rooms.ErrDisabled) || errors.Is(err, rooms.ErrNoArchive) {
+		return fail(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(fiber.Map{
+		"bizId":    bizID,
+		"name":     name,
+		"messages": messages,
+	})
+}
+
+// join 把用户当前在所有节点上的连接加入房间，用户之后建立的连接不会自动加入
+// POST /api/v1/rooms/{bizId}/{name}/members  body: {"userIds": [2, 3]}
+func (h *RoomHandler) join(c fiber.Ctx) error {
+	return h.publish(c, rooms.OpJoin)
+}
+
+// leave 把用户在所有节点上的连接移出房间
+// DELETE /api/v1/rooms/{bizId}/{name}/members  body: {"userIds": [2, 3]}
+func (h *RoomHandler) leave(c fiber.Ctx) error {
+	return h.publish(c, rooms.OpLeave)
+}
+
+// publish 广播房间事件，由各节点应用到本节点上的连接后异步生效
+func (h *RoomHandler) publish(c fiber.Ctx, op string) error {
+	bizID, name, err := roomIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	var req roomMembers
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	if err := checkUserIDs(req.UserIDs); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	nodes, err := h.rooms.Publish(c, rooms.Event{Op: op, BizID: bizID, Room: name, UserIDs: req.UserIDs})
+	if errors.Is(err, rooms.ErrDisabled) {
+		return fail(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(roomMembers{BizID: bizID, Name: name, UserIDs: req.UserIDs, Nodes: nodes})
+}
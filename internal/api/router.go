@@ -0,0 +1,117 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+// Prefix 管理API的统一路径前缀
+const Prefix = "/api/v1"
+
+// Handler 一组管理API接口，负责在路由分组上注册自己的路由
+type Handler interface {
+	Register(r fiber.Router)
+}
+
+// Router 管理API路由器
+// 汇总所有管理API接口，统一挂载到 Prefix 下并施加HTTP压缩和API Key认证
+type Router struct {
+	compress *httpCompression
+	auth     *apiKeyAuth
+	handlers []Handler
+}
+
+func NewRouter(i do.Injector) (*Router, error) {
+	compress, err := newHTTPCompression(i)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := newAPIKeyAuth(i)
+	if err != nil {
+		return nil, err
+	}
+	sessionHandler, err := do.Invoke[*SessionHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	statsHandler, err := do.Invoke[*StatsHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	historyHandler, err := do.Invoke[*HistoryHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	pushHandler, err := do.Invoke[*PushHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	roomHandler, err := do.Invoke[*RoomHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	connectionHandler, err := do.Invoke[*ConnectionHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	revocationHandler, err := do.Invoke[*RevocationHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	banHandler, err := do.Invoke[*BanHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	presenceHandler, err := do.Invoke[*PresenceHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	deviceHandler, err := do.Invoke[*DeviceHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	nodeHandler, err := do.Invoke[*NodeHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	subsystemHandler, err := do.Invoke[*SubsystemHandler](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Router{
+		compress: compress,
+		auth:     auth,
+		handlers: []Handler{
+			sessionHandler,
+			statsHandler,
+			historyHandler,
+			pushHandler,
+			roomHandler,
+			connectionHandler,
+			revocationHandler,
+			banHandler,
+			presenceHandler,
+			deviceHandler,
+			nodeHandler,
+			subsystemHandler,
+		},
+	}, nil
+}
+
+// Mount 将所有管理API挂载到应用上
+func (r *Router) Mount(app *fiber.App) {
+	group := app.Group(Prefix, r.compress.middleware(), r.auth.middleware())
+	for _, h := range r.handlers {
+		h.Register(group)
+	}
+}
+
+// errorResponse 管理API统一的错误响应格式
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// fail 以统一格式返回错误响应
+func fail(c fiber.Ctx, status int, err error) error {
+	return c.Status(status).JSON(errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+var (
+	ErrInvalidUserIdentity = errors.New("无效的bizId或userId")
+	ErrFieldsRequired      = errors.New("必须通过fields参数指定要读取的字段")
+)
+
+// SessionHandler 会话字段管理API
+// 后端服务通过该API读写用户会话字段，而不必直接操作网关的Redis键、耦合其键格式
+type SessionHandler struct {
+	finder session.Finder
+	logger *log.Logger
+}
+
+func NewSessionHandler(i do.Injector) (*SessionHandler, error) {
+	finder, err := do.Invoke[session.Finder](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionHandler{
+		finder: finder,
+		logger: logger,
+	}, nil
+}
+
+func (h *SessionHandler) Register(r fiber.Router) {
+	r.Get("/sessions/:bizId/:userId/fields", h.getFields)
+	r.Put("/sessions/:bizId/:userId/fields", h.putFields)
+}
+
+// sessionFields 会话字段的请求和响应体
+type sessionFields struct {
+	BizID  int64             `json:"bizId"`
+	UserID int64             `json:"userId"`
+	Fields map[string]string `json:"fields"`
+}
+
+// getFields 读取会话字段
+// GET /api/v1/sessions/{bizId}/{userId}/fields?fields=role,features
+// 未指定fields时返回API Key允许访问的全部字段；不存在的字段不会出现在响应中
+func (h *SessionHandler) getFields(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+
+	fields, status, err := requestedFields(c)
+	if err != nil {
+		return fail(c, status, err)
+	}
+
+	ss, err := h.finder.Find(c, bizID, userID)
+	if err != nil {
+		return h.sessionError(c, err)
+	}
+	values, err := readFields(c, ss, fields)
+	if err != nil {
+		return h.sessionError(c, err)
+	}
+	return c.JSON(sessionFields{BizID: bizID, UserID: userID, Fields: values})
+}
+
+// requestedFields 返回 fields 参数指定的会话字段，未指定时为API Key允许访问的全部字段
+// 字段不在API Key的允许列表中时返回 403 对应的错误
+func requestedFields(c fiber.Ctx) ([]string, int, error) {
+	key := apiKeyFrom(c)
+	fields := splitFields(c.Query("fields"))
+	if len(fields) == 0 {
+		if slices.Contains(key.SessionFields, allFields) {
+			return nil, fiber.StatusBadRequest, ErrFieldsRequired
+		}
+		fields = key.SessionFields
+	}
+	for _, f := range fields {
+		if !allowSessionField(key, f) {
+			return nil, fiber.StatusForbidden, fmt.Errorf("%w: %s", ErrFieldNotAllowed, f)
+		}
+	}
+	return fields, 0, nil
+}
+
+// readFields 在一次Redis往返中读取会话字段，不存在的字段不会出现在结果中
+func readFields(c fiber.Ctx, ss session.Session, fields []string) (map[string]string, error) {
+	pipe := ss.Pipeline()
+	cmds := make([]*session.Cmd, len(fields))
+	for i, f := range fields {
+		cmds[i] = pipe.Get(f)
+	}
+	if err := pipe.Exec(c); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(fields))
+	for i, f := range fields {
+		v, err := cmds[i].Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		values[f] = v
+	}
+	return values, nil
+}
+
+// putFields 更新会话字段
+// PUT /api/v1/sessions/{bizId}/{userId}/fields  body: {"fields": {"role": "admin"}}
+// 只要有一个字段不在API Key的允许列表中，整个请求都会被拒绝
+func (h *SessionHandler) putFields(c fiber.Ctx) error {
+	bizID, userID, err := userIdentity(c)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+
+	var req sessionFields
+	if err := c.Bind().Body(&req); err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	key := apiKeyFrom(c)
+	for f := range req.Fields {
+		if !allowSessionField(key, f) {
+			return fail(c, fiber.StatusForbidden, fmt.Errorf("%w: %s", ErrFieldNotAllowed, f))
+		}
+	}
+
+	ss, err := h.finder.Find(c, bizID, userID)
+	if err != nil {
+		return h.sessionError(c, err)
+	}
+	pipe := ss.Pipeline()
+	for f, v := range req.Fields {
+		pipe.Set(f, v)
+	}
+	if err := pipe.Exec(c); err != nil {
+		return h.sessionError(c, err)
+	}
+	h.logger.Info("会话字段已通过管理API更新",
+		slog.String("apiKey", key.Name),
+		slog.Int64("bizId", bizID),
+		slog.Int64("userId", userID),
+		slog.Int("fields", len(req.Fields)))
+	return c.JSON(sessionFields{BizID: bizID, UserID: userID, Fields: req.Fields})
+}
+
+// sessionError 将会话操作错误转换为HTTP响应
+func (h *SessionHandler) sessionError(c fiber.Ctx, err error) error {
+	if errors.Is(err, session.ErrSessionNotFound) {
+		return fail(c, fiber.StatusNotFound, err)
+	}
+	h.logger.Error("会话操作失败", slog.String("path", c.Path()), slog.Any("error", err))
+	return fail(c, fiber.StatusInternalServerError, err)
+}
+
+// userIdentity 从路径参数中解析 bizId 和 userId
+func userIdentity(c fiber.Ctx) (bizID, userID int64, err error) {
+	bizID, err = strconv.ParseInt(c.Params("bizId"), 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidUserIdentity
+	}
+	userID, err = strconv.ParseInt(c.Params("userId"), 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidUserIdentity
+	}
+	return bizID, userID, nil
+}
+
+// splitFields 解析逗号分隔的字段列表，忽略空白项
+func splitFields(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
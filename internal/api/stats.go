@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/uniques"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+var (
+	ErrInvalidBizID = errors.New("无效的bizId")
+	ErrInvalidTime  = errors.New("无效的时间，格式应为 RFC3339 或 2006-01-02")
+)
+
+// StatsHandler 运行统计查询API
+type StatsHandler struct {
+	closeMetrics *metrics.CloseMetrics
+	uniques      uniques.Counter
+	logger       *log.Logger
+}
+
+func NewStatsHandler(i do.Injector) (*StatsHandler, error) {
+	closeMetrics, err := do.Invoke[*metrics.CloseMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	counter, err := do.Invoke[uniques.Counter](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsHandler{
+		closeMetrics: closeMetrics,
+		uniques:      counter,
+		logger:       logger,
+	}, nil
+}
+
+func (h *StatsHandler) Register(r fiber.Router) {
+	r.Get("/stats/close-codes", h.closeCodes)
+	r.Get("/stats/unique-users/:bizId", h.uniqueUsers)
+}
+
+// closeCodes 返回最近一段时间内连接关闭码和断开原因的分布
+// GET /api/v1/stats/close-codes
+func (h *StatsHandler) closeCodes(c fiber.Ctx) error {
+	return c.JSON(h.closeMetrics.Report())
+}
+
+// uniqueUsers 返回业务方在一段时间内各小时/各天的去重用户数，以及整个时间范围内的去重用户数
+// GET /api/v1/stats/unique-users/{bizId}?period=day&from=2026-10-01&to=2026-10-31
+// period 默认为 day，to 默认为当前时间，from 默认与 to 相同；时间按 UTC 划分窗口
+func (h *StatsHandler) uniqueUsers(c fiber.Ctx) error {
+	bizID, err := strconv.ParseInt(c.Params("bizId"), 10, 64)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, ErrInvalidBizID)
+	}
+	period, err := uniques.ParsePeriod(c.Query("period", string(uniques.PeriodDay)))
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	to, err := parseTime(c.Query("to"), time.Now())
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+	from, err := parseTime(c.Query("from"), to)
+	if err != nil {
+		return fail(c, fiber.StatusBadRequest, err)
+	}
+
+	report, err := h.uniques.Count(c, bizID, period, from, to)
+	switch {
+	case errors.Is(err, uniques.ErrDisabled):
+		return fail(c, fiber.StatusNotFound, err)
+	case errors.Is(err, uniques.ErrRangeTooLarge):
+		return fail(c, fiber.StatusBadRequest, err)
+	case err != nil:
+		h.logger.Error("查询去重用户数失败", slog.Int64("bizId", bizID), slog.Any("error", err))
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(report)
+}
+
+// parseTime 解析 RFC3339 时间或 UTC 日期，为空时返回默认值
+func parseTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, ErrInvalidTime
+	}
+	return t, nil
+}
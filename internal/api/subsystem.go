@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/push"
+	"github.com/YaoAzure/wsgateway/internal/subsystem"
+	"github.com/YaoAzure/wsgateway/internal/upstream"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+var ErrUnknownSubsystem = errors.New("未知的子系统，可选值为 forwarder、push、relay、reaper")
+
+// SubsystemHandler 子系统启停API
+// 局部故障时单独停止和恢复某个子系统，不影响其它子系统和已建立的连接，只在本节点上生效，重启后恢复运行：
+//   - forwarder: 上行消息转发，停止期间缓存上行消息，恢复后按顺序补发
+//   - push: 下行推送，停止期间拒绝推送API和其它节点转发来的推送
+//   - relay: 跨节点推送转发，停止期间丢弃其它节点转发来的推送
+//   - reaper: 空闲连接回收，停止期间不关闭空闲连接
+type SubsystemHandler struct {
+	subsystems map[string]subsystem.Controllable
+	order      []string
+	logger     *log.Logger
+}
+
+func NewSubsystemHandler(i do.Injector) (*SubsystemHandler, error) {
+	forwarder, err := do.Invoke[*upstream.Forwarder](i)
+	if err != nil {
+		return nil, err
+	}
+	pusher, err := do.Invoke[*push.Pusher](i)
+	if err != nil {
+		return nil, err
+	}
+	router, err := do.Invoke[*push.Router](i)
+	if err != nil {
+		return nil, err
+	}
+	reaper, err := do.Invoke[*link.Reaper](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	h := &SubsystemHandler{
+		subsystems: make(map[string]subsystem.Controllable),
+		logger:     logger,
+	}
+	for _, s := range []subsystem.Controllable{forwarder, pusher, router, reaper} {
+		name := s.Status().Name
+		h.subsystems[name] = s
+		h.order = append(h.order, name)
+	}
+	return h, nil
+}
+
+func (h *SubsystemHandler) Register(r fiber.Router) {
+	r.Get("/subsystems", h.list)
+	r.Get("/subsystems/:name", h.get)
+	r.Post("/subsystems/:name/stop", h.stop)
+	r.Post("/subsystems/:name/start", h.start)
+}
+
+// list 返回所有子系统的运行状态
+// GET /api/v1/subsystems
+func (h *SubsystemHandler) list(c fiber.Ctx) error {
+	statuses := make([]subsystem.Status, 0, len(h.order))
+	for _, name := range h.order {
+		statuses = append(statuses, h.subsystems[name].Status())
+	}
+	return c.JSON(fiber.Map{"subsystems": statuses})
+}
+
+// get 返回单个子系统的运行状态
+// GET /api/v1/subsystems/{name}
+func (h *SubsystemHandler) get(c fiber.Ctx) error {
+	s, ok := h.subsystems[c.Params("name")]
+	if !ok {
+		return fail(c, fiber.StatusNotFound, ErrUnknownSubsystem)
+	}
+	return c.JSON(s.Status())
+}
+
+// stop 停止子系统，已停止时不做任何操作
+// POST /api/v1/subsystems/{name}/stop
+func (h *SubsystemHandler) stop(c fiber.Ctx) error {
+	return h.toggle(c, false)
+}
+
+// start 恢复子系统，运行中时不做任何操作
+// POST /api/v1/subsystems/{name}/start
+func (h *SubsystemHandler) start(c fiber.Ctx) error {
+	return h.toggle(c, true)
+}
+
+func (h *SubsystemHandler) toggle(c fiber.Ctx, running bool) error {
+	s, ok := h.subsystems[c.Params("name")]
+	if !ok {
+		return fail(c, fiber.StatusNotFound, ErrUnknownSubsystem)
+	}
+	var changed bool
+	if running {
+		changed = s.Resume()
+	} else {
+		changed = s.Pause()
+	}
+	status := s.Status()
+	if changed {
+		h.logger.Warn("子系统已通过管理API切换",
+			slog.String("apiKey", apiKeyFrom(c).Name),
+			slog.String("subsystem", status.Name),
+			slog.Bool("running", status.Running))
+	}
+	return c.JSON(status)
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"os"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// recordMessage 审计记录的日志消息，审计日志中只有这一种记录
+const recordMessage = "connection"
+
+// Record 一个连接的审计记录，在连接关闭时写出
+// 字节数按连接上实际读写的字节统计（包括帧头、控制帧和压缩后的数据，不包括握手），消息数只统计数据消息
+type Record struct {
+	ConnID         string
+	Node           string
+	BizID          int64
+	UserID         int64
+	DeviceID       string
+	Guest          bool
+	RemoteAddr     string
+	Codec          string
+	ConnectedAt    time.Time
+	DisconnectedAt time.Time
+	MessagesIn     int64
+	MessagesOut    int64
+	BytesIn        int64
+	BytesOut       int64
+	Code           int
+	Reason         string
+	ByPeer         bool // 是否由客户端发起关闭
+}
+
+// Log 连接审计日志，每个连接一条 JSON 记录，独立于运行日志写出，用于离线分析和计费
+// 记录与运行日志使用同一个脱敏规则，客户端发来的关闭原因等内容在写出前同样会被遮盖
+type Log struct {
+	logger      *slog.Logger // 未启用时为 nil
+	closer      io.Closer    // 写入文件时为日志文件，输出到标准输出时为 nil
+	sampleRatio float64
+}
+
+func NewLog(i do.Injector) (*Log, error) {
+	cfg, err := do.Invoke[config.AuditConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return &Log{}, nil
+	}
+	redactor, err := do.Invoke[*log.Redactor](i)
+	if err != nil {
+		return nil, err
+	}
+	a := &Log{sampleRatio: min(max(cfg.SampleRatio, 0), 1)}
+	var w io.Writer = os.Stdout
+	if cfg.Output != "console" {
+		file := &lumberjack.Logger{
+			Filename:   cfg.Path,
+			MaxSize:    cfg.Rotation.MaxSize,
+			MaxBackups: cfg.Rotation.MaxBackups,
+			MaxAge:     cfg.Rotation.MaxAge,
+			Compress:   cfg.Rotation.Compress,
+		}
+		w, a.closer = file, file
+	}
+	a.logger = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{ReplaceAttr: redactor.ReplaceAttr}))
+	return a, nil
+}
+
+// Enabled 返回是否启用了连接审计日志
+func (a *Log) Enabled() bool {
+	return a.logger != nil
+}
+
+// Write 写出一个连接的审计记录，按采样率决定是否写出
+func (a *Log) Write(r Record) {
+	if !a.Enabled() || (a.sampleRatio < 1 && rand.Float64() >= a.sampleRatio) {
+		return
+	}
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	attrs := []slog.Attr{
+		slog.String("connId", r.ConnID),
+		slog.String("node", r.Node),
+		slog.Int64("bizId", r.BizID),
+		slog.Int64("userId", r.UserID),
+		slog.String("deviceId", r.DeviceID),
+		slog.Bool("guest", r.Guest),
+		slog.String("ip", ip),
+		slog.String("codec", r.Codec),
+		slog.Time("connectedAt", r.ConnectedAt),
+		slog.Time("disconnectedAt", r.DisconnectedAt),
+		slog.Int64("durationMs", r.DisconnectedAt.Sub(r.ConnectedAt).Milliseconds()),
+		slog.Int64("messagesIn", r.MessagesIn),
+		slog.Int64("messagesOut", r.MessagesOut),
+		slog.Int64("bytesIn", r.BytesIn),
+		slog.Int64("bytesOut", r.BytesOut),
+		slog.Int("code", r.Code),
+		slog.String("reason", r.Reason),
+		slog.Bool("byPeer", r.ByPeer),
+	}
+	a.logger.LogAttrs(context.Background(), slog.LevelInfo, recordMessage, attrs...)
+}
+
+// Shutdown 关闭审计日志文件
+func (a *Log) Shutdown() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
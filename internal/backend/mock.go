@@ -0,0 +1,114 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+)
+
+// ProtocolMock 模拟业务后端，按规则以预设的响应回复，不发起网络调用
+const ProtocolMock = "mock"
+
+// 模拟业务后端注入的错误类型
+const (
+	MockErrorTimeout     = "timeout"
+	MockErrorUnavailable = "unavailable"
+	MockErrorRejected    = "rejected"
+)
+
+// mockFuncs 响应模板中可以使用的函数
+var mockFuncs = template.FuncMap{
+	// json 把值编码为 JSON，用于在 JSON 响应中嵌入字符串等值
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// MockData 渲染响应模板时可以引用的请求数据，例如 {"echo":{{json .Body}},"user":{{.UserID}}}
+type MockData struct {
+	BizID  int64
+	UserID int64
+	Cmd    string
+	Key    string
+	Body   string
+	Now    int64 // 当前时间 (Unix 毫秒)
+}
+
+// Mock 模拟业务后端的响应规则
+type Mock struct {
+	rules []*MockRule
+}
+
+// MockRule 一条编译后的响应规则
+type MockRule struct {
+	cmd        string
+	match      *regexp.Regexp // 为 nil 时匹配所有消息体
+	response   *template.Template
+	Delay      time.Duration
+	Error      string
+	errorRatio float64
+}
+
+func newMock(name string, cfg config.MockBackendConfig) (*Mock, error) {
+	m := &Mock{rules: make([]*MockRule, 0, len(cfg.Rules))}
+	for i, rc := range cfg.Rules {
+		r := &MockRule{
+			cmd:        rc.Cmd,
+			Delay:      time.Duration(rc.Delay),
+			Error:      rc.Error,
+			errorRatio: rc.ErrorRatio,
+		}
+		if rc.Match != "" {
+			re, err := regexp.Compile(rc.Match)
+			if err != nil {
+				return nil, fmt.Errorf("模拟业务后端 %s 的第 %d 条规则: %w", name, i, err)
+			}
+			r.match = re
+		}
+		tmpl, err := template.New(name).Funcs(mockFuncs).Parse(rc.Response)
+		if err != nil {
+			return nil, fmt.Errorf("模拟业务后端 %s 的第 %d 条规则: %w", name, i, err)
+		}
+		r.response = tmpl
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// Match 返回第一条匹配该消息类型和消息体的规则
+func (m *Mock) Match(cmd string, body []byte) (*MockRule, bool) {
+	for _, r := range m.rules {
+		if r.cmd != "" && r.cmd != cmd {
+			continue
+		}
+		if r.match != nil && !r.match.Match(body) {
+			continue
+		}
+		return r, true
+	}
+	return nil, false
+}
+
+// InjectError 返回这次调用要注入的错误类型，不注入时返回空字符串
+func (r *MockRule) InjectError() string {
+	if r.Error == "" || (r.errorRatio > 0 && rand.Float64() >= r.errorRatio) {
+		return ""
+	}
+	return r.Error
+}
+
+// Render 渲染响应消息体
+func (r *MockRule) Render(data MockData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.response.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
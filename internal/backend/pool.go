@@ -0,0 +1,248 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var (
+	ErrDuplicateService = errors.New("业务后端重复配置")
+	ErrUnknownProtocol  = errors.New("未知的业务后端协议")
+)
+
+// 业务后端的调用协议
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+)
+
+// Service 一个业务后端及其专属的客户端
+// HTTP 后端使用 Client，gRPC 后端使用 Conn（URL 为拨号目标），模拟业务后端使用 Mock
+type Service struct {
+	Name     string
+	BizID    int64
+	URL      string
+	Protocol string
+	Client   *http.Client
+	Conn     *grpc.ClientConn
+	Mock     *Mock
+}
+
+// Pools 每个业务后端一个独立调优的HTTP连接池
+// 不使用 http.DefaultClient：默认每个主机只保留 2 个空闲连接，高上行消息速率下会频繁新建连接并耗尽临时端口
+type Pools struct {
+	services map[string]*Service
+	byBiz    map[int64]*Service
+}
+
+func NewPools(i do.Injector) (*Pools, error) {
+	cfg, err := do.Invoke[config.BackendConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	m, err := do.Invoke[*metrics.PoolMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pools{
+		services: make(map[string]*Service, len(cfg.Services)),
+		byBiz:    make(map[int64]*Service, len(cfg.Services)),
+	}
+	for _, sc := range cfg.Services {
+		if _, ok := p.services[sc.Name]; ok {
+			return nil, fmt.Errorf("%w: name=%s", ErrDuplicateService, sc.Name)
+		}
+		if _, ok := p.byBiz[sc.BizID]; ok {
+			return nil, fmt.Errorf("%w: bizId=%d", ErrDuplicateService, sc.BizID)
+		}
+		s := &Service{
+			Name:     sc.Name,
+			BizID:    sc.BizID,
+			URL:      sc.URL,
+			Protocol: sc.Protocol,
+		}
+		switch s.Protocol {
+		case "", ProtocolHTTP:
+			s.Protocol = ProtocolHTTP
+			s.Client = newClient(sc.Name, mergePool(cfg.Pool, sc.Pool), m)
+		case ProtocolGRPC:
+			// grpc.NewClient 不会立即建连，首次调用时才会连接后端
+			conn, err := grpc.NewClient(sc.URL, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				p.Shutdown()
+				return nil, fmt.Errorf("业务后端 %s: %w", sc.Name, err)
+			}
+			s.Conn = conn
+		case ProtocolMock:
+			mock, err := newMock(sc.Name, sc.Mock)
+			if err != nil {
+				p.Shutdown()
+				return nil, err
+			}
+			s.Mock = mock
+		default:
+			p.Shutdown()
+			return nil, fmt.Errorf("%w: name=%s protocol=%s", ErrUnknownProtocol, sc.Name, sc.Protocol)
+		}
+		p.services[s.Name] = s
+		p.byBiz[s.BizID] = s
+	}
+	return p, nil
+}
+
+// Service 按名称查找业务后端
+func (p *Pools) Service(name string) (*Service, bool) {
+	s, ok := p.services[name]
+	return s, ok
+}
+
+// ServiceForBiz 查找业务方对应的业务后端
+func (p *Pools) ServiceForBiz(bizID int64) (*Service, bool) {
+	s, ok := p.byBiz[bizID]
+	return s, ok
+}
+
+// WarmUp 让所有 gRPC 业务后端立即建连并等待连接就绪，返回已就绪的后端数
+// HTTP 后端的连接在首个请求时建立，不做预热；模拟业务后端没有连接
+func (p *Pools) WarmUp(ctx context.Context) (int, error) {
+	var ready int
+	for _, s := range p.services {
+		if s.Conn == nil {
+			continue
+		}
+		s.Conn.Connect()
+		for state := s.Conn.GetState(); state != connectivity.Ready; state = s.Conn.GetState() {
+			if !s.Conn.WaitForStateChange(ctx, state) {
+				return ready, fmt.Errorf("业务后端 %s 未就绪 (%s): %w", s.Name, state, ctx.Err())
+			}
+		}
+		ready++
+	}
+	return ready, nil
+}
+
+// Shutdown 关闭所有连接池中的空闲连接和gRPC连接
+func (p *Pools) Shutdown() {
+	for _, s := range p.services {
+		if s.Client != nil {
+			s.Client.CloseIdleConnections()
+		}
+		if s.Conn != nil {
+			_ = s.Conn.Close()
+		}
+	}
+}
+
+// newClient 创建业务后端专属的HTTP客户端
+// 不设置整体超时，请求超时由调用方通过 context 控制
+func newClient(name string, cfg config.HTTPPoolConfig, m *metrics.PoolMetrics) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.DialTimeout),
+		KeepAlive: time.Duration(cfg.KeepAlive),
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			m.Dialed(name, err)
+			if err != nil {
+				return nil, err
+			}
+			return &trackedConn{Conn: conn, onClose: func() { m.Closed(name) }}, nil
+		},
+		ForceAttemptHTTP2:   cfg.ForceHTTP2 != nil && *cfg.ForceHTTP2,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(cfg.IdleConnTimeout),
+		TLSHandshakeTimeout: time.Duration(cfg.TLSHandshakeTimeout),
+	}
+	if cfg.TLSSessionCacheSize > 0 {
+		transport.TLSClientConfig = &tls.Config{
+			ClientSessionCache: tls.NewLRUClientSessionCache(cfg.TLSSessionCacheSize),
+		}
+	}
+	return &http.Client{
+		Transport: &tracedTransport{base: transport, name: name, metrics: m},
+	}
+}
+
+// mergePool 用业务后端的配置覆盖全局配置中的对应项
+func mergePool(base, override config.HTTPPoolConfig) config.HTTPPoolConfig {
+	if override.MaxIdleConns > 0 {
+		base.MaxIdleConns = override.MaxIdleConns
+	}
+	if override.MaxIdleConnsPerHost > 0 {
+		base.MaxIdleConnsPerHost = override.MaxIdleConnsPerHost
+	}
+	if override.MaxConnsPerHost > 0 {
+		base.MaxConnsPerHost = override.MaxConnsPerHost
+	}
+	if override.IdleConnTimeout > 0 {
+		base.IdleConnTimeout = override.IdleConnTimeout
+	}
+	if override.DialTimeout > 0 {
+		base.DialTimeout = override.DialTimeout
+	}
+	if override.KeepAlive > 0 {
+		base.KeepAlive = override.KeepAlive
+	}
+	if override.TLSHandshakeTimeout > 0 {
+		base.TLSHandshakeTimeout = override.TLSHandshakeTimeout
+	}
+	if override.TLSSessionCacheSize > 0 {
+		base.TLSSessionCacheSize = override.TLSSessionCacheSize
+	}
+	if override.ForceHTTP2 != nil {
+		base.ForceHTTP2 = override.ForceHTTP2
+	}
+	return base
+}
+
+// tracedTransport 记录每次请求是否复用了连接池中的连接
+type tracedTransport struct {
+	base    *http.Transport
+	name    string
+	metrics *metrics.PoolMetrics
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.metrics.Acquired(t.name, info.Reused)
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// CloseIdleConnections 使 http.Client.CloseIdleConnections 能够作用到底层连接池
+func (t *tracedTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// trackedConn 在连接关闭时更新打开连接数
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
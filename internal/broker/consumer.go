@@ -0,0 +1,287 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/push"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// 消息的编码
+const (
+	EncodingProto = "proto" // protobuf 二进制编码
+	EncodingJSON  = "json"  // protojson 编码
+)
+
+// 写入死信主题时附加的消息头，原消息的消息头原样保留
+const (
+	HeaderError     = "gateway-error"            // 写入死信主题的原因
+	HeaderTopic     = "gateway-source-topic"     // 原消息所在的主题
+	HeaderPartition = "gateway-source-partition" // 原消息所在的分区
+	HeaderOffset    = "gateway-source-offset"    // 原消息的位点
+)
+
+// initialRetryBackoff 临时错误后第一次重试前的等待时间
+const initialRetryBackoff = 100 * time.Millisecond
+
+var (
+	ErrInvalidMessage = errors.New("无法解析的推送消息")
+	ErrInvalidTarget  = errors.New("推送消息必须指定biz_id、receiver_id和key，deadline不能为负数")
+)
+
+// Consumer 从 Kafka 主题消费业务后端发布的下行推送 (gatewayapiv1.PushMessage)，通过 push.Router 推送给用户
+//
+// 所有节点加入同一个消费组，每条消息只由一个节点处理，用户连接在其它节点上时由 push.Router 转发。
+// 消息按分区顺序逐条处理，处理完成后才提交位点，节点崩溃时未提交的消息由消费组中的其它节点重新消费（至少一次），
+// 客户端按消息的 key 去重：
+//   - 推送成功（包括转发给其它节点、保存为离线消息）或已超过投递截止时间：提交位点
+//   - 用户在所有节点上都不在线、消息无法解析：写入死信主题后提交位点，未配置死信主题时直接丢弃
+//   - 推送暂停、Redis 不可用等临时错误：原地重试直到成功，期间不处理同一分区的后续消息
+type Consumer struct {
+	enabled    bool
+	cfg        config.BrokerConsumerConfig
+	maxBackoff time.Duration
+	router     *push.Router
+	metrics    *metrics.ConsumerMetrics
+	logger     *log.Logger
+	reader     *kafka.Reader
+	writer     *kafka.Writer
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewConsumer(i do.Injector) (*Consumer, error) {
+	cfg, err := do.Invoke[config.BrokerConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	c := &Consumer{
+		enabled:    cfg.Consumer.Enabled,
+		cfg:        cfg.Consumer,
+		maxBackoff: time.Duration(cfg.Consumer.MaxRetryBackoff),
+		logger:     logger,
+		done:       make(chan struct{}),
+	}
+	if !c.enabled {
+		return c, nil
+	}
+	if c.cfg.Encoding == "" {
+		c.cfg.Encoding = EncodingProto
+	}
+	if c.router, err = do.Invoke[*push.Router](i); err != nil {
+		return nil, err
+	}
+	if c.metrics, err = do.Invoke[*metrics.ConsumerMetrics](i); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Start 加入消费组并在后台消费，未启用时不做任何事
+func (c *Consumer) Start() error {
+	c.startOnce.Do(func() {
+		if !c.enabled {
+			close(c.done)
+			return
+		}
+		c.reader = kafka.NewReader(kafka.ReaderConfig{
+			Brokers: c.cfg.Brokers,
+			GroupID: c.cfg.GroupID,
+			Topic:   c.cfg.Topic,
+			// 新的消费组从最早的消息开始消费，避免首次部署时丢失已发布的推送
+			StartOffset: kafka.FirstOffset,
+		})
+		if c.cfg.DeadLetterTopic != "" {
+			c.writer = &kafka.Writer{
+				Addr:         kafka.TCP(c.cfg.Brokers...),
+				Topic:        c.cfg.DeadLetterTopic,
+				Balancer:     &kafka.Hash{},
+				RequiredAcks: kafka.RequireAll,
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		c.cancel = cancel
+		go c.run(ctx)
+	})
+	return nil
+}
+
+// Shutdown 停止消费并等待正在处理的消息完成，正在重试的消息不提交位点，由消费组中的其它节点重新消费
+func (c *Consumer) Shutdown() {
+	c.stopOnce.Do(func() {
+		if c.cancel != nil {
+			c.cancel()
+		}
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	c.startOnce.Do(func() { close(c.done) })
+	<-c.done
+}
+
+func (c *Consumer) run(ctx context.Context) {
+	defer close(c.done)
+	defer func() {
+		if err := c.reader.Close(); err != nil {
+			c.logger.Warn("关闭Kafka消费者失败", slog.Any("error", err))
+		}
+		if c.writer != nil {
+			if err := c.writer.Close(); err != nil {
+				c.logger.Warn("关闭Kafka死信生产者失败", slog.Any("error", err))
+			}
+		}
+	}()
+	for {
+		m, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.Warn("读取Kafka消息失败", slog.String("topic", c.cfg.Topic), slog.Any("error", err))
+			if !c.wait(ctx, c.maxBackoff) {
+				return
+			}
+			continue
+		}
+		if !c.handle(ctx, m) {
+			return
+		}
+		if err := c.reader.CommitMessages(ctx, m); err != nil && ctx.Err() == nil {
+			// 位点提交失败时消息可能被重新消费，客户端按 key 去重
+			c.logger.Warn("提交Kafka位点失败",
+				slog.String("topic", m.Topic),
+				slog.Int("partition", m.Partition),
+				slog.Int64("offset", m.Offset),
+				slog.Any("error", err))
+		}
+	}
+}
+
+// handle 处理一条消息，返回 false 表示在重试期间停止了消费，消息没有处理完，不能提交位点
+func (c *Consumer) handle(ctx context.Context, m kafka.Message) bool {
+	msg, err := c.decode(m.Value)
+	if err != nil {
+		c.logger.Warn("丢弃无法处理的Kafka消息",
+			slog.String("topic", m.Topic),
+			slog.Int("partition", m.Partition),
+			slog.Int64("offset", m.Offset),
+			slog.Any("error", err))
+		return c.deadLetter(ctx, m, err)
+	}
+	var offline bool
+	ok := c.retry(ctx, "推送Kafka消息失败", func() error {
+		_, err := c.router.Push(ctx, msg)
+		switch {
+		case err == nil:
+			c.metrics.Consumed(metrics.ConsumeDelivered)
+		case errors.Is(err, push.ErrExpired):
+			c.metrics.Consumed(metrics.ConsumeExpired)
+		case errors.Is(err, push.ErrUserOffline):
+			offline = true
+		default:
+			return err
+		}
+		return nil
+	})
+	if ok && offline {
+		return c.deadLetter(ctx, m, push.ErrUserOffline)
+	}
+	return ok
+}
+
+// decode 解析推送消息，只保留业务方可以指定的字段
+func (c *Consumer) decode(value []byte) (*gatewayapiv1.PushMessage, error) {
+	msg := &gatewayapiv1.PushMessage{}
+	var err error
+	if c.cfg.Encoding == EncodingJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(value, msg)
+	} else {
+		err = proto.Unmarshal(value, msg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if msg.GetBizId() <= 0 || msg.GetReceiverId() <= 0 || msg.GetKey() == "" || msg.GetDeadline() < 0 {
+		return nil, ErrInvalidTarget
+	}
+	// seq 由网关分配
+	msg.Seq = 0
+	return msg, nil
+}
+
+// deadLetter 把消息连同失败原因写入死信主题，未配置死信主题时直接丢弃
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, reason error) bool {
+	if c.writer == nil {
+		c.metrics.Consumed(metrics.ConsumeDropped)
+		return true
+	}
+	dead := kafka.Message{
+		Key:   m.Key,
+		Value: m.Value,
+		Headers: append(slices.Clone(m.Headers),
+			kafka.Header{Key: HeaderError, Value: []byte(reason.Error())},
+			kafka.Header{Key: HeaderTopic, Value: []byte(m.Topic)},
+			kafka.Header{Key: HeaderPartition, Value: []byte(strconv.Itoa(m.Partition))},
+			kafka.Header{Key: HeaderOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		),
+	}
+	ok := c.retry(ctx, "写入Kafka死信主题失败", func() error {
+		return c.writer.WriteMessages(ctx, dead)
+	})
+	if ok {
+		c.metrics.Consumed(metrics.ConsumeDeadLettered)
+	}
+	return ok
+}
+
+// retry 调用 fn 直到成功，失败时按指数退避等待；ctx 结束时返回 false
+func (c *Consumer) retry(ctx context.Context, msg string, fn func() error) bool {
+	backoff := initialRetryBackoff
+	for {
+		err := fn()
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		c.metrics.Retried()
+		c.logger.Warn(msg, slog.Duration("backoff", backoff), slog.Any("error", err))
+		if !c.wait(ctx, backoff) {
+			return false
+		}
+		backoff = min(backoff*2, c.maxBackoff)
+	}
+}
+
+// wait 等待 d，ctx 结束时返回 false
+func (c *Consumer) wait(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
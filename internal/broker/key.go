@@ -0,0 +1,101 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrUnknownKeyStrategy = errors.New("未知的路由键策略")
+
+// 内置的路由键策略名称
+const (
+	StrategyUserID     = "userId"     // 按用户路由：同一用户的消息进入同一分区，保证用户维度有序
+	StrategyBizID      = "bizId"      // 按业务方路由：同一业务方的消息进入同一分区
+	StrategyRoom       = "room"       // 按房间路由：同一房间的消息进入同一分区，保证房间维度有序
+	StrategyRoundRobin = "roundRobin" // 轮询：不保证顺序，消息均匀分布到各分区
+)
+
+// RoutingInfo 计算路由键所需的消息元数据
+type RoutingInfo struct {
+	BizID  int64
+	UserID int64
+	Room   string
+}
+
+// KeyStrategy 路由键策略
+// 发布到 Kafka 时作为消息 key 决定分区，发布到 NATS 时作为 subject 后缀
+type KeyStrategy interface {
+	Key(info RoutingInfo) []byte
+}
+
+// KeyStrategyFunc 函数形式的路由键策略
+type KeyStrategyFunc func(info RoutingInfo) []byte
+
+func (f KeyStrategyFunc) Key(info RoutingInfo) []byte {
+	return f(info)
+}
+
+// StrategyFactory 创建路由键策略的工厂函数
+// 每个主题使用独立的策略实例，有状态的策略（如轮询）不会在主题间共享状态
+type StrategyFactory func() KeyStrategy
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]StrategyFactory{
+		StrategyUserID: func() KeyStrategy {
+			return KeyStrategyFunc(func(info RoutingInfo) []byte {
+				return []byte(strconv.FormatInt(info.BizID, 10) + ":" + strconv.FormatInt(info.UserID, 10))
+			})
+		},
+		StrategyBizID: func() KeyStrategy {
+			return KeyStrategyFunc(func(info RoutingInfo) []byte {
+				return []byte(strconv.FormatInt(info.BizID, 10))
+			})
+		},
+		StrategyRoom: func() KeyStrategy {
+			return KeyStrategyFunc(func(info RoutingInfo) []byte {
+				return []byte(strconv.FormatInt(info.BizID, 10) + ":" + info.Room)
+			})
+		},
+		StrategyRoundRobin: func() KeyStrategy {
+			var counter atomic.Uint64
+			return KeyStrategyFunc(func(RoutingInfo) []byte {
+				return strconv.AppendUint(nil, counter.Add(1), 10)
+			})
+		},
+	}
+)
+
+// Register 注册自定义路由键策略，同名策略会被覆盖
+// 嵌入方可以在启动前注册自己的策略，然后在配置中按名称引用
+func Register(name string, factory StrategyFactory) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = factory
+}
+
+// NewKeyStrategy 按名称创建路由键策略
+func NewKeyStrategy(name string) (KeyStrategy, error) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	factory, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyStrategy, name)
+	}
+	return factory(), nil
+}
+
+// Partition 将路由键映射到 [0, n) 范围内的分区号
+// 用于本身不按 key 分区的消息系统（如按 subject 分片的 NATS）
+func Partition(key []byte, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,11 @@
+package broker
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Broker 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewKeyRouter),
+	do.Lazy(NewConsumer),
+)
@@ -0,0 +1,56 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// KeyRouter 按主题选择路由键策略
+// 未在配置中声明的主题使用默认策略
+type KeyRouter struct {
+	topics   map[string]KeyStrategy
+	fallback KeyStrategy
+}
+
+func NewKeyRouter(i do.Injector) (*KeyRouter, error) {
+	cfg, err := do.Invoke[config.BrokerConfig](i)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultStrategy := cfg.DefaultKeyStrategy
+	if defaultStrategy == "" {
+		defaultStrategy = StrategyUserID
+	}
+	fallback, err := NewKeyStrategy(defaultStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make(map[string]KeyStrategy, len(cfg.Topics))
+	for _, t := range cfg.Topics {
+		name := t.KeyStrategy
+		if name == "" {
+			name = defaultStrategy
+		}
+		s, err := NewKeyStrategy(name)
+		if err != nil {
+			return nil, fmt.Errorf("主题 %s: %w", t.Name, err)
+		}
+		topics[t.Name] = s
+	}
+	return &KeyRouter{
+		topics:   topics,
+		fallback: fallback,
+	}, nil
+}
+
+// Key 计算消息发布到指定主题时使用的路由键
+func (r *KeyRouter) Key(topic string, info RoutingInfo) []byte {
+	if s, ok := r.topics[topic]; ok {
+		return s.Key(info)
+	}
+	return r.fallback.Key(info)
+}
@@ -0,0 +1,206 @@
+// Package debug 调试服务，在独立的地址上提供 pprof、expvar 和连接状态转储
+//
+// 线上出现容量问题时不需要重新构建即可采集 CPU、内存、协程和阻塞剖析，定位积压的发送队列和卡住的读写协程。
+// 调试服务不做认证，只应监听本机或内网地址。
+package debug
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+)
+
+var ErrDebugServerStarted = errors.New("调试服务已启动")
+
+// shutdownTimeout 停机时等待处理中的请求完成的最长时间，CPU 剖析等长请求会被中断
+const shutdownTimeout = 3 * time.Second
+
+// links expvar 中网关变量的数据来源，expvar 的变量是全局的，只能注册一次
+var links atomic.Pointer[link.Manager]
+
+func init() {
+	expvar.Publish("gateway", expvar.Func(func() any {
+		vars := map[string]any{"goroutines": runtime.NumGoroutine()}
+		if m := links.Load(); m != nil {
+			vars["connections"] = m.Count()
+			vars["users"] = m.CountUsers()
+			vars["connectionsByBiz"] = m.CountsByBiz()
+		}
+		return vars
+	}))
+}
+
+// Server 调试服务，监听 debug.addr
+//
+//	/debug/pprof/        net/http/pprof 的剖析数据，协程的完整调用栈为 /debug/pprof/goroutine?debug=2
+//	/debug/vars          expvar，包括运行时内存统计和网关的连接数、协程数
+//	/debug/connections   所有连接的状态，按发送队列的队头延迟从大到小排序，可以按 bizId 过滤
+type Server struct {
+	enabled bool
+	addr    string
+	links   *link.Manager
+	server  *http.Server
+	logger  *log.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	done     chan struct{}
+}
+
+func NewServer(i do.Injector) (*Server, error) {
+	cfg, err := do.Invoke[config.DebugConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		enabled: cfg.Enabled,
+		addr:    cfg.Addr,
+		logger:  logger,
+		done:    make(chan struct{}),
+	}
+	if !s.enabled {
+		return s, nil
+	}
+	if s.links, err = do.Invoke[*link.Manager](i); err != nil {
+		return nil, err
+	}
+	links.Store(s.links)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/connections", s.connections)
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	return s, nil
+}
+
+// Addr 返回实际监听的地址，未启动时返回配置的地址
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Start 开始监听并在后台处理请求，未启用时不做任何事
+func (s *Server) Start() error {
+	if !s.enabled {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return ErrDebugServerStarted
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("监听 %s 失败: %w", s.addr, err)
+	}
+	s.listener = ln
+	go func() {
+		defer close(s.done)
+		if err := s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("调试服务异常退出", slog.Any("error", err))
+		}
+	}()
+	return nil
+}
+
+// Shutdown 停止调试服务，等待处理中的请求完成，超过 shutdownTimeout 时强制关闭
+func (s *Server) Shutdown() error {
+	s.mu.Lock()
+	started := s.listener != nil
+	s.mu.Unlock()
+	if !started {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		_ = s.server.Close()
+	}
+	<-s.done
+	return nil
+}
+
+// connectionDump 连接状态转储
+type connectionDump struct {
+	Goroutines  int          `json:"goroutines"`
+	Connections int          `json:"connections"` // 满足过滤条件的连接数
+	Queued      int          `json:"queued"`      // 这些连接的发送队列中等待的消息总数
+	Links       []link.Stats `json:"links"`
+}
+
+// connections 返回所有连接的状态，发送队列积压最久的连接排在最前面
+// GET /debug/connections?bizId=1&limit=100
+// limit 未指定时返回全部连接，连接数很多时应指定
+func (s *Server) connections(w http.ResponseWriter, r *http.Request) {
+	var bizID int64
+	if v := r.URL.Query().Get("bizId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "无效的bizId", http.StatusBadRequest)
+			return
+		}
+		bizID = id
+	}
+	limit := -1
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "无效的limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	dump := connectionDump{Goroutines: runtime.NumGoroutine(), Links: []link.Stats{}}
+	s.links.Range(func(l *link.Link) bool {
+		stats := l.Stats()
+		if bizID == 0 || stats.BizID == bizID {
+			dump.Links = append(dump.Links, stats)
+			dump.Queued += stats.SendQueue.Len
+		}
+		return true
+	})
+	dump.Connections = len(dump.Links)
+	slices.SortFunc(dump.Links, func(a, b link.Stats) int {
+		if c := cmp.Compare(b.SendQueue.OldestAge, a.SendQueue.OldestAge); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if limit >= 0 && len(dump.Links) > limit {
+		dump.Links = dump.Links[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dump)
+}
@@ -0,0 +1,95 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/YaoAzure/wsgateway/pkg/types"
+)
+
+// maxUserServiceResponseSize 用户服务响应体的最大读取字节数
+const maxUserServiceResponseSize = 64 << 10
+
+// metadataEnricher 写入最近一次连接的元数据
+type metadataEnricher struct {
+	nodeID string
+}
+
+func (metadataEnricher) Name() string { return "metadata" }
+
+func (e metadataEnricher) Enrich(_ context.Context, hc *types.HandshakeContext) (map[string]string, error) {
+	fields := map[string]string{
+		"ip":     hc.RemoteIP,
+		"nodeId": e.nodeID,
+	}
+	if hc.UserAgent != "" {
+		fields["userAgent"] = hc.UserAgent
+	}
+	return fields, nil
+}
+
+// geoEnricher 写入客户端IP解析得到的国家、行政区和自治系统
+type geoEnricher struct{}
+
+func (geoEnricher) Name() string { return "geo" }
+
+func (geoEnricher) Enrich(_ context.Context, hc *types.HandshakeContext) (map[string]string, error) {
+	return hc.Geo.Fields(), nil
+}
+
+// userServiceRequest 发送给用户服务的请求体
+type userServiceRequest struct {
+	BizID  int64 `json:"bizId"`
+	UserID int64 `json:"userId"`
+}
+
+// userServiceResponse 用户服务的响应体，fields 中的字段写入会话
+type userServiceResponse struct {
+	Fields map[string]string `json:"fields"`
+}
+
+// userServiceEnricher 从用户服务查询用户资料（角色、标签等）写入会话
+type userServiceEnricher struct {
+	url    string
+	client *http.Client
+}
+
+func newUserServiceEnricher(url string) *userServiceEnricher {
+	// 超时由 Pipeline 的 ctx 控制
+	return &userServiceEnricher{url: url, client: &http.Client{}}
+}
+
+func (*userServiceEnricher) Name() string { return "userService" }
+
+func (e *userServiceEnricher) Enrich(ctx context.Context, hc *types.HandshakeContext) (map[string]string, error) {
+	body, err := json.Marshal(userServiceRequest{BizID: hc.UserInfo.BizID, UserID: hc.UserInfo.UserID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// 用户服务中没有该用户的资料
+		return nil, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("用户服务返回 HTTP %d", resp.StatusCode)
+	}
+	var r userServiceResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxUserServiceResponseSize)).Decode(&r); err != nil {
+		return nil, err
+	}
+	return r.Fields, nil
+}
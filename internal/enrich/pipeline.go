@@ -0,0 +1,138 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/geoip"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/YaoAzure/wsgateway/pkg/types"
+	"github.com/samber/do/v2"
+)
+
+// defaultTimeout 未配置时补充会话数据的超时时间
+const defaultTimeout = 2 * time.Second
+
+// Enricher 握手完成后为会话补充数据的扩展点，例如连接元数据、标签、从用户服务查询的资料
+// 返回的字段写入会话，多个 Enricher 返回同名字段时以后注册的为准
+type Enricher interface {
+	Name() string
+	Enrich(ctx context.Context, hc *types.HandshakeContext) (map[string]string, error)
+}
+
+// Pipeline 在握手返回 101 之后异步执行所有 Enricher 并把结果写入会话
+//
+// 握手的关键路径上只同步创建会话（抢占会话和 loginTime），其余数据在这里补充，
+// Redis 或用户服务变慢时只会推迟数据就绪而不会拖慢握手。
+// 写入使用 Session.Update，只在会话仍然存在时生效：连接在补充完成前关闭、会话已被删除时，
+// 补充的数据被丢弃而不会把会话重新创建出来。
+type Pipeline struct {
+	timeout time.Duration
+	logger  *log.Logger
+
+	mu        sync.RWMutex
+	enrichers []Enricher
+	wg        sync.WaitGroup
+}
+
+func NewPipeline(i do.Injector) (*Pipeline, error) {
+	cfg, err := do.Invoke[config.SessionConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := do.Invoke[*geoip.Resolver](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pipeline{
+		timeout: time.Duration(cfg.Enrichment.Timeout),
+		logger:  logger,
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultTimeout
+	}
+	if cfg.Enrichment.Metadata {
+		p.Register(metadataEnricher{nodeID: appCfg.InstanceID()})
+	}
+	if resolver.Enabled() {
+		p.Register(geoEnricher{})
+	}
+	if cfg.Enrichment.UserServiceURL != "" {
+		p.Register(newUserServiceEnricher(cfg.Enrichment.UserServiceURL))
+	}
+	return p, nil
+}
+
+// Register 注册一个 Enricher，需要在开始接收连接前调用
+func (p *Pipeline) Register(e Enricher) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enrichers = append(p.enrichers, e)
+}
+
+// Enrich 在后台为会话补充数据，立即返回
+func (p *Pipeline) Enrich(ss session.Session, hc *types.HandshakeContext) {
+	p.mu.RLock()
+	enrichers := p.enrichers
+	p.mu.RUnlock()
+	if len(enrichers) == 0 {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.run(enrichers, ss, hc)
+	}()
+}
+
+func (p *Pipeline) run(enrichers []Enricher, ss session.Session, hc *types.HandshakeContext) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	info := ss.UserInfo()
+	fields := make(map[string]string)
+	for _, e := range enrichers {
+		f, err := e.Enrich(ctx, hc)
+		if err != nil {
+			// 单个 Enricher 失败不影响其它数据的补充
+			p.logger.Warn("补充会话数据失败",
+				slog.String("enricher", e.Name()),
+				slog.Int64("bizId", info.BizID),
+				slog.Int64("userId", info.UserID),
+				slog.Any("error", err))
+			continue
+		}
+		maps.Copy(fields, f)
+	}
+	if len(fields) == 0 {
+		return
+	}
+	if err := ss.Update(ctx, fields); err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			p.logger.Debug("会话已删除，丢弃补充的会话数据", slog.Int64("bizId", info.BizID), slog.Int64("userId", info.UserID))
+			return
+		}
+		p.logger.Warn("写入补充的会话数据失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+	}
+}
+
+// Shutdown 等待正在进行的补充完成，每次补充都有超时，不会无限等待
+func (p *Pipeline) Shutdown() {
+	p.wg.Wait()
+}
@@ -0,0 +1,52 @@
+// Package guest 访客（匿名）连接的策略
+//
+// 配置了策略的业务方允许客户端不携带令牌握手，例如营销页、落地页只需要接收实时更新，不需要为其签发令牌。
+// 访客的用户ID由网关生成，能力受限：只能接收推送，有单独的连接数上限和最长存活时间。
+package guest
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// ErrNotAllowed 业务方没有配置访客策略，不允许不携带令牌的连接
+var ErrNotAllowed = errors.New("业务方不允许访客连接")
+
+// Policy 单个业务方的访客策略
+type Policy struct {
+	MaxConnections int           // 本节点上该业务方的访客连接数上限，0 表示不单独限制
+	MaxLifetime    time.Duration // 访客连接的最长存活时间，0 表示不限制
+}
+
+// Policies 按业务方的访客策略
+type Policies struct {
+	policies map[int64]Policy
+}
+
+func NewPolicies(i do.Injector) (*Policies, error) {
+	cfg, err := do.Invoke[config.GuestConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policies{policies: make(map[int64]Policy, len(cfg.Policies))}
+	for _, c := range cfg.Policies {
+		p.policies[c.BizID] = Policy{MaxConnections: c.MaxConnections, MaxLifetime: time.Duration(c.MaxLifetime)}
+	}
+	return p, nil
+}
+
+// Of 返回业务方的访客策略，业务方不允许访客连接时返回 false
+func (p *Policies) Of(bizID int64) (Policy, bool) {
+	policy, ok := p.policies[bizID]
+	return policy, ok
+}
+
+// NewUserID 生成访客的用户ID，取值为负数，不会与业务方签发的用户ID冲突
+func NewUserID() int64 {
+	return -1 - rand.Int64N(math.MaxInt64)
+}
@@ -0,0 +1,116 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// keyFormat 用户连接历史在Redis中的存储键格式
+const keyFormat = "gateway:history:bizId:%d:userId:%d"
+
+// EventType 连接历史事件类型
+type EventType string
+
+const (
+	EventConnect    EventType = "connect"
+	EventDisconnect EventType = "disconnect"
+)
+
+// Event 一条连接历史记录
+type Event struct {
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	Node   string    `json:"node"`             // 处理该连接的网关节点
+	ConnID string    `json:"connId,omitempty"` // 连接ID，用于关联同一连接的建立和断开
+	IP     string    `json:"ip,omitempty"`     // 客户端地址
+	Code   int       `json:"code,omitempty"`   // 关闭码，仅断开事件有效
+	Reason string    `json:"reason,omitempty"` // 断开原因，仅断开事件有效
+}
+
+// Store 每个用户最近若干次连接/断开记录的环形缓冲区
+// 使用Redis列表实现：LPUSH写入最新记录，LTRIM截断到固定长度，EXPIRE在每次写入时刷新过期时间。
+// 用于回答"这个用户为什么每30秒掉线一次"之类的问题，而不必翻查分布在各节点上的日志
+type Store struct {
+	rdb  redis.Cmdable
+	node string
+	size int64
+	ttl  time.Duration
+}
+
+func NewStore(i do.Injector) (*Store, error) {
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	appConfig, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := do.Invoke[config.HistoryConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		rdb:  rdb,
+		node: appConfig.InstanceID(),
+		size: cfg.Size,
+		ttl:  time.Duration(cfg.TTL),
+	}, nil
+}
+
+// Enabled 返回是否启用了连接历史记录
+func (s *Store) Enabled() bool {
+	return s.size > 0
+}
+
+// Record 追加一条连接历史记录，未设置的时间和节点会自动补全
+func (s *Store) Record(ctx context.Context, bizID, userID int64, e Event) error {
+	if !s.Enabled() {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Node == "" {
+		e.Node = s.node
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf(keyFormat, bizID, userID)
+	_, err = s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, payload)
+		pipe.LTrim(ctx, key, 0, s.size-1)
+		if s.ttl > 0 {
+			pipe.Expire(ctx, key, s.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// List 返回用户的连接历史，最新的记录在前
+func (s *Store) List(ctx context.Context, bizID, userID int64) ([]Event, error) {
+	items, err := s.rdb.LRange(ctx, fmt.Sprintf(keyFormat, bizID, userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(items))
+	for _, item := range items {
+		var e Event
+		if err := json.Unmarshal([]byte(item), &e); err != nil {
+			// 忽略无法解析的记录，不影响其他记录的查询
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
@@ -0,0 +1,321 @@
+// Package inbound 接收第三方服务（代码托管平台、告警系统、内容发布系统等）调用的入站webhook，把请求映射为下行推送
+//
+// 每个入站webhook在配置中声明共享密钥、目标业务方、推送目标和一组 text/template 模板，
+// 第三方服务以 POST {webhook.inbound.path}/{name} 调用，请求体必须是JSON。网关用共享密钥校验请求体的
+// HMAC-SHA256 签名（十六进制，可以带 "sha256=" 前缀，与 GitHub 的 X-Hub-Signature-256 相同），
+// 再以解析后的请求体渲染模板得到接收用户、推送的 key、折叠键和消息体，简单的集成不需要再编写后端服务。
+//
+// 模板中可以引用：
+//
+//	.payload  解析后的JSON请求体，数字保留原始文本，如 {{.payload.repository.full_name}}
+//	.headers  请求头，名称为小写，同名请求头取第一个值，如 {{index .headers "x-github-delivery"}}
+//	.hook     入站webhook的名称
+//
+// 以及函数 json，把任意值编码为JSON，如 {{json .payload.alerts}}。
+// key 模板为空时使用请求体的 SHA-256，第三方服务重试同一请求时客户端可以据此去重；body 模板为空时原样推送请求体。
+package inbound
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"text/template"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/push"
+	"github.com/YaoAzure/wsgateway/internal/rooms"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gofiber/fiber/v3"
+	"github.com/samber/do/v2"
+)
+
+// 推送目标
+const (
+	TargetUser      = "user"      // 一个用户，与 POST /api/v1/push 相同，会写入会话恢复缓冲区和离线消息
+	TargetUsers     = "users"     // 一组用户，userIds 模板渲染为以逗号或空白分隔的用户ID
+	TargetGroup     = "group"     // 命名分组的所有成员
+	TargetRoom      = "room"      // 房间中的所有连接
+	TargetBroadcast = "broadcast" // 业务方的所有连接
+)
+
+// defaultSignatureHeader 未配置时携带签名的请求头，与 GitHub 相同
+const defaultSignatureHeader = "X-Hub-Signature-256"
+
+var (
+	ErrUnknownHook      = errors.New("入站webhook不存在")
+	ErrInvalidSignature = errors.New("签名缺失或不正确")
+	ErrInvalidPayload   = errors.New("请求体不是合法的JSON")
+	ErrInvalidUserID    = errors.New("模板渲染出的用户ID无效")
+)
+
+// hook 一个入站webhook，空模板为 nil
+type hook struct {
+	cfg         config.InboundHookConfig
+	userID      *template.Template
+	userIDs     *template.Template
+	group       *template.Template
+	room        *template.Template
+	key         *template.Template
+	collapseKey *template.Template
+	body        *template.Template
+}
+
+// Receiver 入站webhook接收器
+type Receiver struct {
+	path   string
+	hooks  map[string]*hook
+	router *push.Router
+	logger *log.Logger
+}
+
+func NewReceiver(i do.Injector) (*Receiver, error) {
+	cfg, err := do.Invoke[config.WebhookConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	router, err := do.Invoke[*push.Router](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	r := &Receiver{
+		path:   strings.TrimSuffix(cfg.Inbound.Path, "/"),
+		hooks:  make(map[string]*hook, len(cfg.Inbound.Hooks)),
+		router: router,
+		logger: logger,
+	}
+	for _, hc := range cfg.Inbound.Hooks {
+		h, err := newHook(hc)
+		if err != nil {
+			return nil, fmt.Errorf("入站webhook %s: %w", hc.Name, err)
+		}
+		r.hooks[hc.Name] = h
+	}
+	return r, nil
+}
+
+func newHook(cfg config.InboundHookConfig) (*hook, error) {
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = defaultSignatureHeader
+	}
+	h := &hook{cfg: cfg}
+	for _, t := range []struct {
+		name string
+		text string
+		dst  **template.Template
+	}{
+		{"userId", cfg.UserID, &h.userID},
+		{"userIds", cfg.UserIDs, &h.userIDs},
+		{"group", cfg.Group, &h.group},
+		{"room", cfg.Room, &h.room},
+		{"key", cfg.Key, &h.key},
+		{"collapseKey", cfg.CollapseKey, &h.collapseKey},
+		{"body", cfg.Body, &h.body},
+	} {
+		if t.text == "" {
+			continue
+		}
+		tmpl, err := template.New(t.name).Funcs(funcs).Option("missingkey=error").Parse(t.text)
+		if err != nil {
+			return nil, err
+		}
+		*t.dst = tmpl
+	}
+	return h, nil
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Register 配置了入站webhook时注册接收接口
+// 与 /placement 一样不经过管理API的 API Key 认证，每个webhook用自己的共享密钥校验签名
+func (r *Receiver) Register(app fiber.Router) {
+	if len(r.hooks) > 0 {
+		app.Post(r.path+"/:name", r.handle)
+	}
+}
+
+// handle 校验签名、渲染模板并推送
+// POST {webhook.inbound.path}/{name}
+// 签名错误返回 401，请求体或模板渲染失败返回 422；目标用户不在线不视为错误，返回 200 和推送结果，
+// 避免第三方服务反复重试，只有推送暂停 (503) 和内部错误 (500) 值得重试
+func (r *Receiver) handle(c fiber.Ctx) error {
+	h, ok := r.hooks[c.Params("name")]
+	if !ok {
+		return fail(c, fiber.StatusNotFound, ErrUnknownHook)
+	}
+	body := c.Body()
+	if !verify(h.cfg.Secret, c.Get(h.cfg.SignatureHeader), body) {
+		return fail(c, fiber.StatusUnauthorized, ErrInvalidSignature)
+	}
+
+	var payload any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return fail(c, fiber.StatusUnprocessableEntity, ErrInvalidPayload)
+	}
+	headers := make(map[string]string)
+	for name, values := range c.GetReqHeaders() {
+		if len(values) > 0 {
+			headers[strings.ToLower(name)] = values[0]
+		}
+	}
+	data := map[string]any{"payload": payload, "headers": headers, "hook": h.cfg.Name}
+
+	res, err := r.dispatch(c, h, data, body)
+	var renderErr *renderError
+	switch {
+	case errors.As(err, &renderErr):
+		return fail(c, fiber.StatusUnprocessableEntity, err)
+	case errors.Is(err, rooms.ErrDisabled):
+		return fail(c, fiber.StatusNotFound, err)
+	case errors.Is(err, push.ErrPushPaused):
+		return fail(c, fiber.StatusServiceUnavailable, err)
+	case err != nil && !errors.Is(err, push.ErrUserOffline):
+		r.logger.Warn("入站webhook推送失败", slog.String("hook", h.cfg.Name), slog.Any("error", err))
+		return fail(c, fiber.StatusInternalServerError, err)
+	}
+	return c.JSON(res)
+}
+
+// dispatch 渲染模板并按推送目标推送
+func (r *Receiver) dispatch(c fiber.Ctx, h *hook, data map[string]any, body []byte) (push.Result, error) {
+	msg := &gatewayapiv1.PushMessage{BizId: h.cfg.BizID}
+	var err error
+	if msg.Key, err = render(h.key, data); err != nil {
+		return push.Result{}, err
+	}
+	if msg.Key == "" {
+		sum := sha256.Sum256(body)
+		msg.Key = hex.EncodeToString(sum[:])
+	}
+	if msg.CollapseKey, err = render(h.collapseKey, data); err != nil {
+		return push.Result{}, err
+	}
+	if h.body == nil {
+		msg.Body = bytes.Clone(body)
+	} else {
+		s, err := render(h.body, data)
+		if err != nil {
+			return push.Result{}, err
+		}
+		msg.Body = []byte(s)
+	}
+
+	switch h.cfg.Target {
+	case TargetUser:
+		s, err := render(h.userID, data)
+		if err != nil {
+			return push.Result{}, err
+		}
+		ids, err := parseUserIDs(s)
+		if err != nil || len(ids) != 1 {
+			return push.Result{}, &renderError{field: "userId", err: ErrInvalidUserID}
+		}
+		msg.ReceiverId = ids[0]
+		return r.router.Push(c, msg)
+	case TargetUsers:
+		s, err := render(h.userIDs, data)
+		if err != nil {
+			return push.Result{}, err
+		}
+		ids, err := parseUserIDs(s)
+		if err != nil {
+			return push.Result{}, &renderError{field: "userIds", err: err}
+		}
+		return r.router.Multicast(c, msg, ids)
+	case TargetGroup:
+		group, err := render(h.group, data)
+		if err != nil {
+			return push.Result{}, err
+		}
+		if !push.ValidGroupName(group) {
+			return push.Result{}, &renderError{field: "group", err: push.ErrInvalidGroupName}
+		}
+		return r.router.PushGroup(c, msg, group)
+	case TargetRoom:
+		room, err := render(h.room, data)
+		if err != nil {
+			return push.Result{}, err
+		}
+		if !rooms.ValidName(room) {
+			return push.Result{}, &renderError{field: "room", err: rooms.ErrInvalidName}
+		}
+		return r.router.PushRoom(c, msg, room)
+	default:
+		return r.router.Broadcast(c, msg)
+	}
+}
+
+// renderError 模板渲染失败，通常是请求体缺少模板引用的字段
+type renderError struct {
+	field string
+	err   error
+}
+
+func (e *renderError) Error() string {
+	return fmt.Sprintf("渲染 %s 失败: %v", e.field, e.err)
+}
+
+func (e *renderError) Unwrap() error {
+	return e.err
+}
+
+// render 渲染模板并去掉首尾空白，模板为 nil 时返回空字符串
+func render(t *template.Template, data map[string]any) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	var buf strings.Builder
+	if err := t.Execute(&buf, data); err != nil {
+		return "", &renderError{field: t.Name(), err: err}
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// parseUserIDs 解析以逗号或空白分隔的用户ID
+func parseUserIDs(s string) ([]int64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	ids := make([]int64, 0, len(fields))
+	for _, f := range fields {
+		id, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, ErrInvalidUserID
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// verify 校验请求体的 HMAC-SHA256 签名
+func verify(secret, signature string, body []byte) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func fail(c fiber.Ctx, status int, err error) error {
+	return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,97 @@
+package incident
+
+import (
+	"sync"
+	"time"
+)
+
+// Kind 事故类型
+type Kind string
+
+const (
+	KindPanic Kind = "panic" // 协程中捕获到的 panic
+	KindError Kind = "error" // 不应该发生的错误，通常意味着代码缺陷
+)
+
+// Incident 一条事故记录，以 JSON 写入诊断目录下的 <ID>.json
+// 包含复现问题所需的上下文：发生位置、调用栈、涉及的连接及其最近的事件
+type Incident struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Node     string    `json:"node"`
+	Kind     Kind      `json:"kind"`
+	Where    string    `json:"where"`   // 发生位置，例如 link.handler
+	Message  string    `json:"message"` // panic 的值或错误信息
+	Stack    string    `json:"stack"`   // 发生事故的协程的调用栈
+	Subject  any       `json:"subject,omitempty"`
+	Trail    []Event   `json:"trail,omitempty"` // 涉及的连接最近的事件，最旧的在前
+	Revision string    `json:"revision,omitempty"`
+}
+
+// Subject 事故涉及的对象，通常是一个连接
+type Subject interface {
+	// IncidentMetadata 返回写入事故记录的元数据，需要能编码为 JSON
+	IncidentMetadata() any
+	// IncidentTrail 返回对象最近的事件，未记录时返回 nil
+	IncidentTrail() *Trail
+}
+
+// Event 连接上发生的一个事件
+type Event struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Cmd    string    `json:"cmd,omitempty"`
+	Key    string    `json:"key,omitempty"`
+	Bytes  int       `json:"bytes,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Trail 固定大小的环形缓冲区，保留一个连接最近的事件，写满后覆盖最旧的事件
+// 所有方法都可以在 nil 接收者上安全调用，未启用事故记录时连接不持有 Trail
+type Trail struct {
+	mu     sync.Mutex
+	events []Event
+	next   int
+	full   bool
+}
+
+// NewTrail 创建保留最近 size 个事件的 Trail，size 不大于 0 时返回 nil
+func NewTrail(size int) *Trail {
+	if size <= 0 {
+		return nil
+	}
+	return &Trail{events: make([]Event, size)}
+}
+
+// Add 追加一个事件，未设置时间时使用当前时间
+func (t *Trail) Add(e Event) {
+	if t == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	t.mu.Lock()
+	t.events[t.next] = e
+	t.next++
+	if t.next == len(t.events) {
+		t.next = 0
+		t.full = true
+	}
+	t.mu.Unlock()
+}
+
+// Events 返回事件的副本，最旧的在前
+func (t *Trail) Events() []Event {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.full {
+		return append([]Event(nil), t.events[:t.next]...)
+	}
+	events := make([]Event, 0, len(t.events))
+	events = append(events, t.events[t.next:]...)
+	return append(events, t.events[:t.next]...)
+}
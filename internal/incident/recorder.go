@@ -0,0 +1,239 @@
+package incident
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// defaultDir 未配置诊断目录时使用的默认值
+	defaultDir = "./log/incidents"
+	// fileSuffix 事故文件的扩展名，清理旧文件时只处理该扩展名的文件
+	fileSuffix = ".json"
+)
+
+// Recorder 在捕获到 panic 或意外错误时生成事故记录
+//
+// 事故记录写入诊断目录，日志中只输出事故ID (incident 字段) 和摘要，
+// 生产环境的问题报告附上事故文件就带有足够复现问题的上下文。
+// 诊断目录中最多保留 maxFiles 个文件，每分钟最多写入 maxPerMinute 个，
+// 超出时只记录日志，避免 panic 风暴写满磁盘。未启用时同样记录日志，日志中带上调用栈
+type Recorder struct {
+	enabled      bool
+	dir          string
+	maxFiles     int
+	maxPerMinute int
+	trailSize    int
+	node         string
+	revision     string
+	logger       *log.Logger
+
+	mu          sync.Mutex // 串行化写入和清理
+	windowStart time.Time
+	written     int // 当前一分钟窗口内写入的文件数
+}
+
+func NewRecorder(i do.Injector) (*Recorder, error) {
+	cfg, err := do.Invoke[config.IncidentConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		enabled:      cfg.Enabled,
+		dir:          cfg.Dir,
+		maxFiles:     cfg.MaxFiles,
+		maxPerMinute: cfg.MaxPerMinute,
+		trailSize:    cfg.TrailSize,
+		node:         appCfg.InstanceID(),
+		revision:     revision(),
+		logger:       logger,
+	}
+	if r.dir == "" {
+		r.dir = defaultDir
+	}
+	return r, nil
+}
+
+// revision 返回构建时的代码版本，便于按事故记录找到对应的代码
+func revision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return info.Main.Version
+}
+
+// NewTrail 为一个连接创建事件缓冲区，未启用事故记录时返回 nil
+func (r *Recorder) NewTrail() *Trail {
+	if !r.enabled {
+		return nil
+	}
+	return NewTrail(r.trailSize)
+}
+
+// Recover 捕获当前协程的 panic 并生成事故记录，必须直接以 defer 调用：
+//
+//	defer r.Recover("link.handler", l, func() { l.Close() })
+//
+// subject 为事故涉及的对象，可以为 nil；onPanic 在记录完成后调用，用于释放资源，可以为 nil。
+// panic 被捕获后不再向上传播，调用方需要保证协程退出后状态一致
+func (r *Recorder) Recover(where string, subject Subject, onPanic func()) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	r.record(KindPanic, where, fmt.Sprint(v), subject)
+	if onPanic != nil {
+		onPanic()
+	}
+}
+
+// Error 为不应该发生的错误生成事故记录，返回事故ID，未写入文件时返回空字符串
+// 调用栈为调用 Error 的位置
+func (r *Recorder) Error(where string, err error, subject Subject) string {
+	return r.record(KindError, where, err.Error(), subject)
+}
+
+func (r *Recorder) record(kind Kind, where, message string, subject Subject) string {
+	inc := Incident{
+		ID:       newID(),
+		Time:     time.Now(),
+		Node:     r.node,
+		Kind:     kind,
+		Where:    where,
+		Message:  message,
+		Stack:    string(debug.Stack()),
+		Revision: r.revision,
+	}
+	if subject != nil {
+		inc.Subject = subject.IncidentMetadata()
+		inc.Trail = subject.IncidentTrail().Events()
+	}
+
+	attrs := []any{
+		slog.String("kind", string(kind)),
+		slog.String("where", where),
+		slog.String("error", message),
+	}
+	if !r.enabled {
+		r.logger.Error("捕获到异常", append(attrs, slog.String("stack", inc.Stack))...)
+		return ""
+	}
+	path, err := r.write(inc)
+	switch {
+	case errors.Is(err, errRateLimited):
+		r.logger.Error("捕获到异常，事故记录过多，未写入文件", append(attrs, slog.String("stack", inc.Stack))...)
+		return ""
+	case err != nil:
+		r.logger.Error("捕获到异常，写入事故记录失败",
+			append(attrs, slog.String("stack", inc.Stack), slog.Any("writeError", err))...)
+		return ""
+	}
+	r.logger.Error("捕获到异常", append(attrs, slog.String("incident", inc.ID), slog.String("file", path))...)
+	return inc.ID
+}
+
+// errRateLimited 当前一分钟内写入的事故文件已达上限
+var errRateLimited = errors.New("事故记录写入过于频繁")
+
+// write 把事故记录写入诊断目录并清理多余的旧文件，返回文件路径
+func (r *Recorder) write(inc Incident) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxPerMinute > 0 {
+		if inc.Time.Sub(r.windowStart) >= time.Minute {
+			r.windowStart = inc.Time
+			r.written = 0
+		}
+		if r.written >= r.maxPerMinute {
+			return "", errRateLimited
+		}
+		r.written++
+	}
+
+	data, err := json.MarshalIndent(inc, "", "  ")
+	if err != nil {
+		// Subject 的元数据无法编码时仍然保留调用栈
+		inc.Subject = fmt.Sprintf("%+v", inc.Subject)
+		if data, err = json.MarshalIndent(inc, "", "  "); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(r.dir, inc.ID+fileSuffix)
+	// 先写临时文件再重命名，收集问题报告的人不会拿到写了一半的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	r.prune()
+	return path, nil
+}
+
+// prune 删除超出 maxFiles 的最旧的事故文件，调用方持有 mu
+// 事故ID以时间开头，文件名的字典序就是写入顺序
+func (r *Recorder) prune() {
+	if r.maxFiles <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), fileSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= r.maxFiles {
+		return
+	}
+	slices.Sort(names)
+	for _, name := range names[:len(names)-r.maxFiles] {
+		if err := os.Remove(filepath.Join(r.dir, name)); err != nil {
+			r.logger.Warn("删除旧的事故记录失败", slog.String("file", name), slog.Any("error", err))
+		}
+	}
+}
+
+// newID 生成事故ID：UTC 时间加随机后缀，例如 20240102T150405.123Z-1a2b3c4d
+func newID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return time.Now().UTC().Format("20060102T150405.000Z") + "-" + hex.EncodeToString(b[:])
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -11,6 +12,13 @@ import (
 	"github.com/samber/do/v2"
 )
 
+var (
+	// ErrLimiterClosed 限流器已关闭，AcquireWithContext 不再等待
+	ErrLimiterClosed = errors.New("限流器已关闭")
+	// ErrInvalidCapacity SetCapacity 的容量不在 [0, MaxCapacity] 范围内
+	ErrInvalidCapacity = errors.New("容量必须在 0 到 MaxCapacity 之间")
+)
+
 // TokenLimiterConfig 结构体用于配置 TokenLimiter。
 // 这个配置结构体定义了令牌桶限流器的所有关键参数，支持动态容量增长的配置
 type TokenLimiterConfig struct {
@@ -59,6 +67,19 @@ type TokenLimiter struct {
 	// 获取令牌就是从channel中读取，归还令牌就是向channel中写入
 	tokens chan struct{}
 
+	// mu 串行化容量调整，预热和 SetCapacity 不会同时增减令牌
+	// Acquire 和 Release 不加锁
+	mu sync.Mutex
+
+	// reclaiming 缩容后尚未收回的令牌数
+	// 缩容时令牌桶中的空闲令牌立即收回；正在使用的令牌超出新容量的部分无法收回，
+	// 记为待收回，这些令牌被 Release 归还时直接丢弃，不再放回令牌桶，
+	// 已有连接不受影响，实际并发数随连接关闭逐步降到新容量
+	reclaiming atomic.Int64
+
+	// overridden 容量已被 SetCapacity 手动调整，预热不再增加容量
+	overridden atomic.Bool
+
 	// 组件内部的 context，用于通过 Close 方法从外部控制其生命周期。
 	// ctx 内部上下文，当调用Close()方法时会被取消
 	// 用于通知所有相关的goroutine停止运行
@@ -198,36 +219,132 @@ func (t *TokenLimiter) StartRampUp(ctx context.Context) {
 			return
 			
 		case <-ticker.C: // 定时器触发，执行容量增长逻辑
-			// 原子性地读取当前容量，确保并发安全
-			current := t.currentCapacity.Load()
-			
-			// 检查是否已经达到最大容量
-			if current >= t.config.MaxCapacity {
-				// 容量已达到最大值，记录日志并退出
+			if !t.rampUpStep() {
+				// 容量已达到最大值，或已被手动调整
 				// 这个goroutine的使命已经完成，可以安全退出了
 				return
 			}
+		}
+	}
+}
+
+// rampUpStep 执行一次预热扩容，返回是否需要继续预热
+func (t *TokenLimiter) rampUpStep() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// 运维手动调整过容量时以手动设置为准，预热不能把缩容后的容量又加回去
+	if t.overridden.Load() {
+		return false
+	}
+
+	current := t.currentCapacity.Load()
+	
+	// 检查是否已经达到最大容量
+	if current >= t.config.MaxCapacity {
+		return false
+	}
+
+	// 计算本次增长后的新容量
+	// 确保不会超过最大容量限制
+	newCapacity := current + t.config.IncreaseStep
+	if newCapacity > t.config.MaxCapacity {
+		// 如果计算出的新容量超过了最大容量，则设置为最大容量
+		newCapacity = t.config.MaxCapacity
+	}
+
+	t.resize(newCapacity)
+	return newCapacity < t.config.MaxCapacity
+}
+
+// SetCapacity 手动把容量调整为 n，用于在故障期间不重启节点就降低可接受的并发数，或在故障结束后恢复。
+//
+// 调整后预热停止，容量保持为 n，直到再次调用 SetCapacity。
+//
+// 扩容：先抵消尚未收回的令牌，剩余部分作为新令牌放入令牌桶，排队等待的调用方立即可以获取。
+// 缩容：令牌桶中的空闲令牌立即收回；如果正在使用的令牌超过 n，超出部分在归还时收回，
+// 已建立的连接不会被关闭，并发数随连接自然关闭逐步降到 n。
+//
+// n 为 0 时不再发放新令牌，但与摘流不同，不会关闭已有连接。
+// n 不在 [0, MaxCapacity] 范围内时返回 ErrInvalidCapacity。
+func (t *TokenLimiter) SetCapacity(n int64) error {
+	if n < 0 || n > t.config.MaxCapacity {
+		return fmt.Errorf("%w: %d (MaxCapacity %d)", ErrInvalidCapacity, n, t.config.MaxCapacity)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.overridden.Store(true)
+	t.resize(n)
+	return nil
+}
 
-			// 计算本次增长后的新容量
-			// 确保不会超过最大容量限制
-			newCapacity := current + t.config.IncreaseStep
-			if newCapacity > t.config.MaxCapacity {
-				// 如果计算出的新容量超过了最大容量，则设置为最大容量
-				newCapacity = t.config.MaxCapacity
+// resize 把容量调整为 target，调用方持有 mu
+//
+// 始终满足：令牌桶中的令牌数 + 正在使用的令牌数 = 当前容量 + 待收回的令牌数，
+// 而当前容量和待收回的令牌数之和不超过 MaxCapacity，向令牌桶放入令牌不会阻塞
+func (t *TokenLimiter) resize(target int64) {
+	current := t.currentCapacity.Load()
+	switch {
+	case target > current:
+		// 待收回的令牌直接抵消扩容，不需要先收回再发放
+		added := target - current - t.forgive(target-current)
+		for i := int64(0); i < added; i++ {
+			select {
+			case t.tokens <- struct{}{}:
+			default:
+				// 只有 Release 调用次数多于 Acquire 时令牌桶才会满
 			}
+		}
+	case target < current:
+		t.reclaiming.Add(current - target)
+		t.reclaimIdle()
+	}
+	// 原子性地更新当前容量
+	t.currentCapacity.Store(target)
+}
 
-			// 向令牌桶中添加增量令牌
-			// 计算需要添加的令牌数量
-			addedTokens := newCapacity - current
-			
-			// 逐个添加令牌到channel中
-			// 每个struct{}{}代表一个可用的令牌
-			for i := int64(0); i < addedTokens; i++ {
-				t.tokens <- struct{}{}
+// forgive 抵消最多 n 个待收回的令牌，返回实际抵消的数量
+func (t *TokenLimiter) forgive(n int64) int64 {
+	for {
+		r := t.reclaiming.Load()
+		if r <= 0 {
+			return 0
+		}
+		m := min(r, n)
+		if t.reclaiming.CompareAndSwap(r, r-m) {
+			return m
+		}
+	}
+}
+
+// reclaimIdle 收回令牌桶中的空闲令牌，直到没有待收回的令牌或令牌桶为空
+func (t *TokenLimiter) reclaimIdle() {
+	for {
+		select {
+		case <-t.tokens:
+			if !t.reclaimOne() {
+				// 并发的 Release 已经还清，把多取的令牌放回去
+				select {
+				case t.tokens <- struct{}{}:
+				default:
+				}
+				return
 			}
-			
-			// 原子性地更新当前容量
-			t.currentCapacity.Store(newCapacity)
+		default:
+			return
+		}
+	}
+}
+
+// reclaimOne 有待收回的令牌时收回一个，返回是否收回
+func (t *TokenLimiter) reclaimOne() bool {
+	for {
+		r := t.reclaiming.Load()
+		if r <= 0 {
+			return false
+		}
+		if t.reclaiming.CompareAndSwap(r, r-1) {
+			return true
 		}
 	}
 }
@@ -263,6 +380,32 @@ func (t *TokenLimiter) Acquire() bool {
 	}
 }
 
+// AcquireWithContext 阻塞获取一个令牌，直到获取成功、ctx 结束或限流器关闭。
+//
+// 与 Acquire 不同，令牌耗尽时调用方在令牌被归还或扩容的瞬间就能拿到令牌，
+// 不必按固定间隔轮询；等待时长由 ctx 的超时控制。
+//
+// 返回值：
+// - nil：成功获取令牌，之后必须调用 Release 归还
+// - ctx.Err()：ctx 结束前没有可用令牌
+// - ErrLimiterClosed：等待期间限流器被关闭，停机时排队的调用方不会一直阻塞
+func (t *TokenLimiter) AcquireWithContext(ctx context.Context) error {
+	// 有令牌时直接返回，即使 ctx 已经结束
+	select {
+	case <-t.tokens:
+		return nil
+	default:
+	}
+	select {
+	case <-t.tokens:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.ctx.Done():
+		return ErrLimiterClosed
+	}
+}
+
 // Release 归还一个令牌。非阻塞。
 //
 // 工作原理：
@@ -288,6 +431,10 @@ func (t *TokenLimiter) Acquire() bool {
 // - 只有在成功调用Acquire()后才应该调用此方法
 // - 不要重复归还同一个令牌
 func (t *TokenLimiter) Release() bool {
+	// 缩容后超出新容量的令牌在归还时收回，不放回令牌桶
+	if t.reclaimOne() {
+		return true
+	}
 	select {
 	case t.tokens <- struct{}{}:
 		return true
@@ -312,6 +459,7 @@ func (t *TokenLimiter) Release() bool {
 // - 正在运行的StartRampUp goroutine会停止容量增长
 // - 不会影响已经获取的令牌，它们仍然有效
 // - 不会影响Acquire()和Release()的正常使用
+// - 正在 AcquireWithContext 中等待的调用方会返回 ErrLimiterClosed
 //
 // 使用场景：
 // - 应用程序关闭时
@@ -338,7 +486,7 @@ func (t *TokenLimiter) Close() error {
 //
 // 返回值：
 // - 当前令牌桶的实际容量（不是可用令牌数量）
-// - 这个值会从InitialCapacity逐步增长到MaxCapacity
+// - 这个值会从InitialCapacity逐步增长到MaxCapacity，或是 SetCapacity 设置的值
 //
 // 使用场景：
 // - 监控系统当前的处理能力
@@ -352,3 +500,23 @@ func (t *TokenLimiter) Close() error {
 func (t *TokenLimiter) CurrentCapacity() int64 {
 	return t.currentCapacity.Load()
 }
+
+// MaxCapacity 返回令牌桶的最大容量，即预热完成后能够同时持有的令牌数
+func (t *TokenLimiter) MaxCapacity() int64 {
+	return t.config.MaxCapacity
+}
+
+// Available 返回令牌桶中的空闲令牌数，即当前还能接受的新连接数
+func (t *TokenLimiter) Available() int64 {
+	return int64(len(t.tokens))
+}
+
+// Reclaiming 返回缩容后尚未收回的令牌数，为 0 时正在使用的令牌数已不超过当前容量
+func (t *TokenLimiter) Reclaiming() int64 {
+	return t.reclaiming.Load()
+}
+
+// Overridden 返回容量是否已被 SetCapacity 手动调整
+func (t *TokenLimiter) Overridden() bool {
+	return t.overridden.Load()
+}
@@ -0,0 +1,61 @@
+package link
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// bandwidthWindow 积压期间累计的写入耗时达到该值时产生一个吞吐量样本
+	bandwidthWindow = 100 * time.Millisecond
+	// bandwidthStale 超过该时长没有新样本时估计值清零，链路可能已经恢复
+	bandwidthStale = 30 * time.Second
+	// bandwidthAlpha 指数加权移动平均中新样本的权重
+	bandwidthAlpha = 0.25
+)
+
+// bandwidth 连接有效下行吞吐量的估计，由写协程更新
+//
+// 只有发送队列有积压时写协程才会连续写入，此时 写出的字节数/写入耗时 就是链路实际能达到的吞吐量
+// （内核发送缓冲区写满后每次写入都要等链路把等量的数据发出去，包括压缩和加密的开销）；
+// 队列没有积压时写入速度取决于业务推送的频率，反映不出链路带宽，不作为样本。
+// 积压期间每累计 bandwidthWindow 的写入耗时产生一个样本，以指数加权移动平均平滑后作为估计值；
+// 估计值为 0 表示链路最近没有成为瓶颈
+type bandwidth struct {
+	estimate atomic.Uint64 // 字节/秒，float64 的二进制表示
+
+	// 以下字段只在写协程中访问
+	bytes      int           // 当前窗口写出的字节数
+	busy       time.Duration // 当前窗口的写入耗时
+	lastSample time.Time
+}
+
+// observe 记录一次写入的字节数和耗时，backlogged 表示写入开始时发送队列中还有其它消息
+func (b *bandwidth) observe(n int, d time.Duration, backlogged bool) {
+	now := time.Now()
+	if !backlogged {
+		if !b.lastSample.IsZero() && now.Sub(b.lastSample) > bandwidthStale {
+			b.lastSample = time.Time{}
+			b.estimate.Store(0)
+		}
+		b.bytes, b.busy = 0, 0
+		return
+	}
+	b.bytes += n
+	b.busy += d
+	if b.busy < bandwidthWindow {
+		return
+	}
+	rate := float64(b.bytes) / b.busy.Seconds()
+	if old := math.Float64frombits(b.estimate.Load()); old > 0 {
+		rate = old + bandwidthAlpha*(rate-old)
+	}
+	b.estimate.Store(math.Float64bits(rate))
+	b.bytes, b.busy, b.lastSample = 0, 0, now
+}
+
+// bytesPerSecond 返回估计的吞吐量，链路最近没有成为瓶颈时为 0
+func (b *bandwidth) bytesPerSecond() int64 {
+	return int64(math.Float64frombits(b.estimate.Load()))
+}
@@ -0,0 +1,179 @@
+package link
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+)
+
+// defaultBatchBytes link.batch.maxBytes 未配置时一次合并写入的最大字节数
+const defaultBatchBytes = 32 << 10
+
+// batchBuffers 合并写入使用的缓冲区，只在写出一批消息期间从池中取出，空闲的连接不占用
+var batchBuffers = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// batchConfig 解析后的写合并配置
+type batchConfig struct {
+	window   time.Duration
+	maxBytes int
+	biz      map[int64]time.Duration
+}
+
+func newBatchConfig(cfg config.BatchConfig) batchConfig {
+	c := batchConfig{
+		window:   time.Duration(cfg.Window),
+		maxBytes: cfg.MaxBytes,
+		biz:      make(map[int64]time.Duration, len(cfg.BizPolicies)),
+	}
+	if c.maxBytes <= 0 {
+		c.maxBytes = defaultBatchBytes
+	}
+	for _, p := range cfg.BizPolicies {
+		c.biz[p.BizID] = time.Duration(p.Window)
+	}
+	return c
+}
+
+// newCoalescer 按业务方的合并窗口为连接创建写合并器，业务方不合并时返回 nil
+func (c batchConfig) newCoalescer(conn net.Conn, bizID int64) *coalescer {
+	window, ok := c.biz[bizID]
+	if !ok {
+		window = c.window
+	}
+	if window <= 0 {
+		return nil
+	}
+	return &coalescer{conn: conn, window: window, maxBytes: c.maxBytes}
+}
+
+// coalescer 写合并期间暂存写协程写出的帧，一批消息写完后一次写入连接；不在合并期间时写入直接透传给连接
+// 作为 wswrapper.Writer 的输出目标，所有方法都在持有 Link.writeMu 时调用
+type coalescer struct {
+	conn     net.Conn
+	window   time.Duration
+	maxBytes int
+
+	buf     *bytes.Buffer // 合并期间从池中取出，否则为 nil
+	written int           // 这一批写入连接的字节数
+	busy    time.Duration // 这一批写入连接的耗时
+}
+
+func (c *coalescer) Write(p []byte) (int, error) {
+	if c.buf == nil {
+		return c.conn.Write(p)
+	}
+	if c.buf.Len() > 0 && c.buf.Len()+len(p) > c.maxBytes {
+		if err := c.flush(); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) >= c.maxBytes {
+		// 单独就超过上限的数据不经过缓冲区
+		return c.writeConn(p)
+	}
+	return c.buf.Write(p)
+}
+
+// begin 开始合并一批消息
+func (c *coalescer) begin() {
+	c.buf = batchBuffers.Get().(*bytes.Buffer)
+	c.written, c.busy = 0, 0
+}
+
+// end 写出暂存的帧并归还缓冲区，之后的写入直接透传给连接
+func (c *coalescer) end() error {
+	err := c.flush()
+	if c.buf.Cap() <= 2*c.maxBytes {
+		c.buf.Reset()
+		batchBuffers.Put(c.buf)
+	}
+	c.buf = nil
+	return err
+}
+
+// flush 把暂存的帧写入连接，不在合并期间时什么也不做
+func (c *coalescer) flush() error {
+	if c.buf == nil || c.buf.Len() == 0 {
+		return nil
+	}
+	_, err := c.writeConn(c.buf.Bytes())
+	c.buf.Reset()
+	return err
+}
+
+func (c *coalescer) writeConn(p []byte) (int, error) {
+	start := time.Now()
+	n, err := c.conn.Write(p)
+	c.written += n
+	c.busy += time.Since(start)
+	return n, err
+}
+
+// sendBatch 写出 first；启用了写合并时在合并窗口内继续取出发送队列中的消息，
+// 它们的帧暂存在一起，窗口结束或暂存的字节数达到上限时一次写入连接
+// 吞吐量估计和队头延迟按实际写入连接的时间统计
+func (l *Link) sendBatch(first outbound) error {
+	c := l.batch
+	if c == nil {
+		return l.send(first)
+	}
+	l.inflightSince.Store(first.enqueuedAt)
+	defer l.inflightSince.Store(0)
+	l.writeMu.Lock()
+	c.begin()
+	l.writeMu.Unlock()
+
+	enqueued := l.batched[:0]
+	stage := func(msg outbound) error {
+		payload, ok := l.take(msg)
+		if !ok {
+			return nil
+		}
+		if err := l.write(payload); err != nil {
+			return err
+		}
+		enqueued = append(enqueued, msg.enqueuedAt)
+		l.trail.Add(incident.Event{Type: eventSend, Bytes: len(payload)})
+		return nil
+	}
+	err := stage(first)
+	timer := time.NewTimer(c.window)
+	defer timer.Stop()
+collect:
+	for err == nil {
+		select {
+		case msg := <-l.sendCh:
+			err = stage(msg)
+		case <-timer.C:
+			break collect
+		case <-l.closeCh:
+			break collect
+		}
+	}
+
+	l.writeMu.Lock()
+	backlogged := len(l.sendCh) > 0
+	if l.writeTimeout > 0 {
+		_ = l.conn.SetWriteDeadline(time.Now().Add(l.writeTimeout))
+	}
+	if endErr := c.end(); err == nil {
+		err = endErr
+	}
+	l.writeMu.Unlock()
+	if err == nil {
+		l.bandwidth.observe(c.written, c.busy, backlogged)
+		for _, t := range enqueued {
+			l.queue.Waited(time.Since(time.Unix(0, t)))
+		}
+	}
+	l.batched = enqueued[:0]
+	return err
+}
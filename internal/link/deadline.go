@@ -0,0 +1,33 @@
+package link
+
+import (
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+)
+
+// expired 返回推送是否已超过投递截止时间
+func expired(p *gatewayapiv1.PushMessage) bool {
+	d := p.GetDeadline()
+	return d > 0 && time.Now().UnixMilli() >= d
+}
+
+// sendPush 把离线消息或补发的推送作为下行消息放入发送缓冲区，错误语义与 Send 相同
+// 推送带有投递截止时间时一并交给写协程，在发送队列中等待到过期的不再写出
+func sendPush(l *Link, p *gatewayapiv1.PushMessage) error {
+	payload, err := l.codec.Marshal(&gatewayapiv1.Message{
+		Cmd:  gatewayapiv1.Message_COMMAND_TYPE_DOWNSTREAM_MESSAGE,
+		Key:  p.GetKey(),
+		Body: p.GetBody(),
+		Seq:  p.GetSeq(),
+	})
+	if err != nil {
+		return err
+	}
+	var deadline time.Time
+	if d := p.GetDeadline(); d > 0 {
+		deadline = time.UnixMilli(d)
+	}
+	_, err = l.SendBefore(payload, "", deadline)
+	return err
+}
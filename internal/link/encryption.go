@@ -0,0 +1,53 @@
+package link
+
+import (
+	"errors"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/wswrapper"
+	"github.com/YaoAzure/wsgateway/pkg/encryption"
+	"github.com/gobwas/ws"
+)
+
+// ErrKeyExchange 表示向客户端下发加密协商结果失败，连接无法使用
+var ErrKeyExchange = errors.New("下发加密协商结果失败")
+
+// CloseReasonDecrypt 收到的消息无法解密时关闭连接的原因，关闭码为 1007
+const CloseReasonDecrypt = "decrypt failed"
+
+// exchangeKey 在读写协程启动前下发不加密的 KEY_EXCHANGE 消息，协商了加密时之后的数据帧都加密
+// 密文不是合法的 UTF-8，加密后统一使用二进制帧；密文无法压缩，加密后不再压缩下行消息，避免白白消耗压缩的CPU预算
+func (l *Link) exchangeKey(enc *encryption.State) error {
+	payload, err := l.codec.Marshal(&gatewayapiv1.Message{
+		Cmd:  gatewayapiv1.Message_COMMAND_TYPE_KEY_EXCHANGE,
+		Body: enc.PublicKey,
+	})
+	if err != nil {
+		return err
+	}
+	if err := l.write(payload); err != nil {
+		return err
+	}
+	if enc.Enabled {
+		l.cipher = enc.Cipher
+		l.writer.SetOpCode(ws.OpBinary)
+		l.writer.SetCompressed(false)
+	}
+	return nil
+}
+
+// Encrypted 返回连接是否协商了消息加密
+func (l *Link) Encrypted() bool {
+	return l.cipher != nil
+}
+
+// decrypt 解密收到的消息，未协商加密时原样返回
+func (l *Link) decrypt(payload []byte) ([]byte, error) {
+	if l.cipher == nil {
+		return payload, nil
+	}
+	plaintext, err := l.cipher.Open(payload)
+	// 解密结果是新分配的，密文的缓冲区可以立即归还
+	wswrapper.Release(payload)
+	return plaintext, err
+}
@@ -0,0 +1,197 @@
+package link
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/wswrapper"
+	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/encryption"
+	"github.com/YaoAzure/wsgateway/pkg/geoip"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/message"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/google/uuid"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// defaultBufferSize 未配置缓冲区大小时使用的默认值
+	defaultBufferSize = 256
+)
+
+// Factory Link工厂，持有创建连接所需的公共配置
+type Factory struct {
+	cfg       config.LinkConfig
+	overflow  overflowConfig
+	batch     batchConfig
+	level     int // 下行消息的deflate压缩级别
+	guard     *compression.Guard
+	outbound  *metrics.CompressionDirection // 下行消息的压缩效果指标
+	inbound   *metrics.CompressionDirection // 上行消息的压缩效果指标
+	codecs    *message.Negotiator
+	queue     *metrics.QueueMetrics
+	push      *metrics.PushMetrics
+	incidents *incident.Recorder
+	logger    *log.Logger
+}
+
+func NewFactory(i do.Injector) (*Factory, error) {
+	cfg, err := do.Invoke[config.LinkConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	codecs, err := do.Invoke[*message.Negotiator](i)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := do.Invoke[*metrics.QueueMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	pushMetrics, err := do.Invoke[*metrics.PushMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := do.Invoke[*incident.Recorder](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	compressionCfg, err := do.Invoke[compression.Config](i)
+	if err != nil {
+		return nil, err
+	}
+	guard, err := do.Invoke[*compression.Guard](i)
+	if err != nil {
+		return nil, err
+	}
+	compressionMetrics, err := do.Invoke[*metrics.CompressionMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	overflow, err := newOverflowConfig(cfg.Buffer)
+	if err != nil {
+		return nil, err
+	}
+	return &Factory{
+		cfg:       cfg,
+		overflow:  overflow,
+		batch:     newBatchConfig(cfg.Batch),
+		level:     compressionCfg.Level,
+		guard:     guard,
+		outbound:  compressionMetrics.Direction(metrics.DirectionOutbound),
+		inbound:   compressionMetrics.Direction(metrics.DirectionInbound),
+		codecs:    codecs,
+		queue:     queue,
+		push:      pushMetrics,
+		incidents: incidents,
+		logger:    logger,
+	}, nil
+}
+
+// New 基于升级后的连接创建 Link 并启动读写协程
+// state 为升级时的压缩协商结果，为 nil 表示未启用压缩；enc 为加密协商结果，为 nil 表示客户端没有请求加密；
+// geo 为握手时解析得到的客户端地理位置。
+// 消息编解码器取自会话中握手时协商的名称，握手后编解码器被注销时返回错误；
+// 客户端请求了加密时先下发 KEY_EXCHANGE 消息，下发失败时返回 ErrKeyExchange
+func (f *Factory) New(conn net.Conn, ss session.Session, state *compression.State, enc *encryption.State, geo geoip.Location) (*Link, error) {
+	codec, err := f.codecs.Negotiate(ss.UserInfo().Codec)
+	if err != nil {
+		return nil, err
+	}
+	compressed := state != nil && state.Enabled
+	// 连接上的所有读写都经过流量统计
+	counters := new(traffic)
+	conn = &countingConn{Conn: conn, traffic: counters}
+	// 启用写合并时写协程写出的帧先经过合并器
+	batch := f.batch.newCoalescer(conn, ss.UserInfo().BizID)
+	var dest io.Writer = conn
+	if batch != nil {
+		dest = batch
+	}
+	writer := wswrapper.NewServerSideWriter(dest, compressed)
+	writer.SetOpCode(codec.OpCode())
+	reader := f.newReader(conn)
+	// 协商了压缩时统计每条消息压缩前后的大小
+	var stats *compression.Stats
+	if compressed {
+		writer.SetCompressionLevel(f.level)
+		writer.SetCompressionGuard(f.guard)
+		stats = compression.NewStats(state.Parameters, f.outbound, f.inbound)
+		writer.SetCompressionObserver(stats.Outbound())
+		reader.SetCompressionObserver(stats.Inbound())
+	}
+	// 连接ID与会话中记录的连接ID一致，多连接策略据此识别被取代的连接
+	id := ss.UserInfo().ConnID
+	if id == "" {
+		id = uuid.NewString()
+	}
+	l := &Link{
+		id:           id,
+		conn:         conn,
+		session:      ss,
+		codec:        codec,
+		reader:       reader,
+		writer:       writer,
+		compression:  stats,
+		batch:        batch,
+		geo:          geo,
+		logger:       f.logger,
+		queue:        f.queue,
+		push:         f.push,
+		incidents:    f.incidents,
+		trail:        f.incidents.NewTrail(),
+		writeTimeout: time.Duration(f.cfg.Timeout.Write),
+		overflowCfg:  f.overflow,
+		traffic:      counters,
+		connectedAt:  time.Now(),
+		sendCh:       make(chan outbound, bufferSize(f.cfg.Buffer.SendBufferSize)),
+		receiveCh:    make(chan []byte, bufferSize(f.cfg.Buffer.ReceiveBufferSize)),
+		drainCh:      make(chan CloseInfo, 1),
+		closeCh:      make(chan struct{}),
+	}
+	if enc != nil {
+		if err := l.exchangeKey(enc); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeyExchange, err)
+		}
+	}
+	l.UpdateActiveTime()
+	l.trail.Add(incident.Event{
+		Type: eventConnect,
+		Detail: conn.RemoteAddr().String() + " codec=" + codec.Name() + " compressed=" + strconv.FormatBool(compressed) +
+			" encrypted=" + strconv.FormatBool(l.cipher != nil),
+	})
+
+	go l.readLoop()
+	go l.writeLoop()
+	return l, nil
+}
+
+// newReader 创建按 link.inbound 限制上行消息的读取器
+func (f *Factory) newReader(conn net.Conn) *wswrapper.Reader {
+	r := wswrapper.NewServerSideReader(conn)
+	r.SetLimits(wswrapper.Limits{
+		MaxMessageSize:      f.cfg.Inbound.MaxMessageSize,
+		MaxDecompressedSize: f.cfg.Inbound.MaxDecompressedSize,
+		MaxFragments:        f.cfg.Inbound.MaxFragments,
+		FragmentTimeout:     time.Duration(f.cfg.Inbound.FragmentTimeout),
+	})
+	return r
+}
+
+func bufferSize(size int) int {
+	if size <= 0 {
+		return defaultBufferSize
+	}
+	return size
+}
@@ -0,0 +1,40 @@
+package link
+
+import (
+	"context"
+	"log/slog"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+)
+
+func newFieldSet(fields []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		set[f] = struct{}{}
+	}
+	return set
+}
+
+// pushFieldChange 处理会话字段变更通知，把 session.notifyFields 中字段的新值下发给本节点上该用户的所有连接
+// 字段可能在任意节点上被修改（管理API或业务处理器），通知通过 session.ChangeWatcher 广播到所有节点，
+// 每个节点只下发给自己持有的连接。连接槽位等网关内部字段的变更由 kickReplaced 处理，不会下发给客户端
+func (m *Manager) pushFieldChange(_ context.Context, change session.FieldChange) {
+	if _, ok := m.notifyFields[change.Key]; !ok {
+		return
+	}
+	msg := &gatewayapiv1.Message{
+		Cmd:  gatewayapiv1.Message_COMMAND_TYPE_SESSION_FIELD_CHANGED,
+		Key:  change.Key,
+		Body: []byte(change.Value),
+	}
+	for _, l := range m.GetByUser(change.BizID, change.UserID) {
+		// 变更通知在 ChangeWatcher 的协程中依次处理，不能等待慢速连接
+		if err := l.TrySendMessage(msg); err != nil {
+			m.logger.Debug("下发会话字段变更失败",
+				slog.String("linkId", l.ID()),
+				slog.String("key", change.Key),
+				slog.Any("error", err))
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package link
+
+import (
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+// CloseReasonGuestExpired 访客连接达到业务方访客策略的最长存活时间，关闭码为 1000，客户端可以立即以访客身份重连
+const CloseReasonGuestExpired = "guest expired"
+
+// limitGuestLifetime 按业务方的访客策略限制访客连接的存活时间，到期后发送完缓冲区中的消息再关闭连接
+// 返回的函数在连接关闭后调用，停止计时
+func (m *Manager) limitGuestLifetime(l *Link) func() {
+	policy, _ := m.guest.Of(l.Session().UserInfo().BizID)
+	if policy.MaxLifetime <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(policy.MaxLifetime, func() {
+		l.Drain(CloseInfo{Code: ws.StatusNormalClosure, Reason: CloseReasonGuestExpired})
+	})
+	return func() { timer.Stop() }
+}
@@ -0,0 +1,42 @@
+package link
+
+import (
+	"log/slog"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+)
+
+// Handler 上行消息处理器
+// 收到的消息已经按连接协商的编解码器解码，回复消息时使用 Link.SendMessage 以相同的编解码器编码
+type Handler interface {
+	Handle(l *Link, msg *gatewayapiv1.Message)
+}
+
+// HandlerFunc 函数形式的上行消息处理器
+type HandlerFunc func(l *Link, msg *gatewayapiv1.Message)
+
+func (f HandlerFunc) Handle(l *Link, msg *gatewayapiv1.Message) {
+	f(l, msg)
+}
+
+// defaultHandler 默认的上行消息处理器
+// 只负责原样返回心跳消息，其它消息在配置业务处理器之前直接丢弃
+func defaultHandler(logger *log.Logger) Handler {
+	return HandlerFunc(func(l *Link, msg *gatewayapiv1.Message) {
+		if msg.GetCmd() == gatewayapiv1.Message_COMMAND_TYPE_HEARTBEAT {
+			if err := l.SendMessage(msg); err != nil {
+				logger.Debug("回复心跳失败", slog.String("linkId", l.ID()), slog.Any("error", err))
+			}
+			return
+		}
+		logger.Debug("未配置上行消息处理器，丢弃消息", slog.String("linkId", l.ID()), slog.String("cmd", msg.GetCmd().String()))
+	})
+}
+
+// Notifier 把网关产生的事件（例如会话接管）上报业务后端
+// Notify 在连接的读协程或会话变更通知的处理协程中调用，实现不能阻塞，上报失败时只记录日志
+type Notifier interface {
+	Notify(info session.UserInfo, msg *gatewayapiv1.Message)
+}
@@ -0,0 +1,67 @@
+package link
+
+import (
+	"strconv"
+
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/gobwas/ws"
+)
+
+// 连接事件类型，记录在连接的事件缓冲区中，事故记录中带上最近的事件
+const (
+	eventConnect     = "connect"     // 连接建立
+	eventReceive     = "receive"     // 收到上行消息
+	eventDecodeError = "decodeError" // 上行消息无法解析
+	eventRateLimited = "rateLimited" // 上行消息被限流
+	eventSend        = "send"        // 下行消息写入连接
+	eventSendFull    = "sendFull"    // 发送缓冲区已满
+	eventSendDropped = "sendDropped" // 发送缓冲区已满，丢弃最老的未写出消息
+	eventExpired     = "expired"     // 下行消息超过投递截止时间，没有写出
+	eventDrain       = "drain"       // 开始优雅关闭
+	eventClose       = "close"       // 连接关闭
+)
+
+// CloseReasonInternalError 处理连接时发生 panic，以 1011 关闭连接
+const CloseReasonInternalError = "internal error"
+
+var _ incident.Subject = &Link{}
+
+// IncidentMetadata 事故记录中连接的元数据
+func (l *Link) IncidentMetadata() any {
+	info := l.CloseInfo()
+	return struct {
+		Stats
+		Closed      bool   `json:"closed"`
+		CloseByPeer bool   `json:"closeByPeer,omitempty"`
+		CloseCode   int    `json:"closeCode,omitempty"`
+		CloseReason string `json:"closeReason,omitempty"`
+	}{
+		Stats:       l.Stats(),
+		Closed:      l.closed(),
+		CloseByPeer: info.ByPeer,
+		CloseCode:   int(info.Code),
+		CloseReason: info.Reason,
+	}
+}
+
+// IncidentTrail 返回连接最近的事件
+func (l *Link) IncidentTrail() *incident.Trail {
+	return l.trail
+}
+
+// closeInternalError 捕获到 panic 后以 1011 关闭连接
+func (l *Link) closeInternalError() {
+	l.close(CloseInfo{Code: ws.StatusInternalServerError, Reason: CloseReasonInternalError}, true)
+}
+
+// closeDetail 事件中关闭信息的摘要
+func closeDetail(info CloseInfo) string {
+	detail := strconv.Itoa(int(info.Code))
+	if info.Reason != "" {
+		detail += " " + info.Reason
+	}
+	if info.ByPeer {
+		detail += " (by peer)"
+	}
+	return detail
+}
@@ -0,0 +1,625 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/wswrapper"
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/YaoAzure/wsgateway/pkg/encryption"
+	"github.com/YaoAzure/wsgateway/pkg/geoip"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/message"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/YaoAzure/wsgateway/pkg/types"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+var (
+	_ types.Link = &Link{}
+
+	ErrLinkClosed       = errors.New("连接已关闭")
+	ErrSendBufferIsFull = errors.New("发送缓冲区已满")
+	ErrLinkDraining     = errors.New("连接正在关闭")
+	ErrInvalidCloseCode = errors.New("关闭码不能在关闭帧中发送")
+)
+
+// CloseInfo 连接关闭信息
+type CloseInfo = types.CloseInfo
+
+// maxCloseReasonSize 关闭帧中关闭原因的最大字节数：控制帧的负载不超过125字节，其中2字节为关闭码
+const maxCloseReasonSize = 123
+
+// closeReasonWriteFailed 写入连接失败时记录的关闭原因，连接已不可写，不会发送关闭帧
+const closeReasonWriteFailed = "write failed"
+
+// outbound 发送队列中的一条消息
+type outbound struct {
+	payload    []byte
+	enqueuedAt int64      // 入队时间（UnixNano），用于统计队头延迟
+	deadline   int64      // 投递截止时间（UnixNano），为 0 时不限制，写协程不再写出已过期的消息
+	collapsed  *collapsed // 可替换消息，写协程以其中的最新内容为准，payload 和 deadline 不使用
+}
+
+// collapsed 发送队列中一条可替换消息的最新内容，由 collapseMu 保护
+type collapsed struct {
+	key      string
+	payload  []byte
+	deadline int64
+}
+
+// SendQueueStats 发送队列状态
+type SendQueueStats struct {
+	Len       int           `json:"len"`
+	Cap       int           `json:"cap"`
+	OldestAge time.Duration `json:"oldestAge"` // 最老的未发送完成消息已等待的时长，队列为空时为 0
+}
+
+// Stats 连接的运行状态，供管理API查询
+type Stats struct {
+	ID          string          `json:"id"`
+	BizID       int64           `json:"bizId"`
+	UserID      int64           `json:"userId"`
+	DeviceID    string          `json:"deviceId,omitempty"`
+	Guest       bool            `json:"guest,omitempty"` // 是否是未认证的访客连接
+	RemoteAddr  string          `json:"remoteAddr"`
+	Codec       string          `json:"codec"`
+	Encrypted   bool            `json:"encrypted,omitempty"`
+	Geo         *geoip.Location `json:"geo,omitempty"`       // 客户端IP解析得到的地理位置，未解析出时为空
+	Bandwidth   int64           `json:"bandwidth,omitempty"` // 估计的有效下行吞吐量（字节/秒），链路最近没有成为瓶颈时为 0
+	ConnectedAt time.Time       `json:"connectedAt"`
+	LastActive  time.Time       `json:"lastActive"`
+	SendQueue   SendQueueStats  `json:"sendQueue"`
+	Traffic     Traffic         `json:"traffic"`
+	// Compression 协商的压缩参数和两个方向的压缩效果，未协商压缩时为空
+	Compression *compression.Snapshot `json:"compression,omitempty"`
+}
+
+// Link 基于 WebSocket 连接的 types.Link 实现
+//
+// 每个 Link 启动两个 goroutine：
+//   - 读协程：循环从连接中读取完整消息，投递到接收通道；接收通道满时阻塞，把背压传导给TCP
+//   - 写协程：从发送通道中取出消息写入连接，每次写入都设置写超时，避免慢客户端拖住写协程
+//
+// 任意一个协程遇到错误，或者调用方主动调用 Close，都会关闭整个连接。
+type Link struct {
+	id      string
+	conn    net.Conn
+	session session.Session
+	codec   message.Codec
+	reader  *wswrapper.Reader
+	writer  *wswrapper.Writer
+	logger  *log.Logger
+	queue   *metrics.QueueMetrics
+	push    *metrics.PushMetrics
+
+	// geo 握手时解析得到的客户端地理位置
+	geo geoip.Location
+
+	// cipher 协商了加密时的加解密器，在读写协程启动前设置，未加密时为 nil
+	cipher *encryption.Cipher
+
+	// incidents 读写协程发生 panic 时生成事故记录，trail 为事故记录保留最近的事件，未启用时为 nil
+	incidents *incident.Recorder
+	trail     *incident.Trail
+
+	writeTimeout time.Duration
+	overflowCfg  overflowConfig
+	// overflowClosing close 溢出策略已经决定关闭连接，关闭在后台进行
+	overflowClosing atomic.Bool
+	connectedAt     time.Time
+
+	// writeMu 串行化所有对连接的写操作（数据消息和关闭帧）
+	writeMu sync.Mutex
+
+	// batch 启用写合并时暂存写协程写出的帧，未启用时为 nil；batched 为这一批消息的入队时间，只在写协程中访问
+	batch   *coalescer
+	batched []int64
+
+	sendCh    chan outbound
+	receiveCh chan []byte
+
+	// collapsing 发送队列中尚未写出的可替换消息，按折叠键索引；写协程取出消息时移除
+	collapseMu sync.Mutex
+	collapsing map[string]*collapsed
+
+	// bandwidth 根据写入耗时估计的有效下行吞吐量
+	bandwidth bandwidth
+
+	// traffic 连接收发的消息数和字节数
+	traffic *traffic
+
+	// compression 协商了压缩时的压缩效果统计，未协商压缩时为 nil
+	compression *compression.Stats
+	// reported 上次计入用量报告时的流量，由 usageMeter.mu 保护
+	reported Traffic
+
+	// inflightSince 写协程正在写入的消息的入队时间（UnixNano），没有正在写入的消息时为 0
+	// 队列是先进先出的，正在写入的消息就是最老的未发送完成的消息
+	inflightSince atomic.Int64
+
+	// drainCh 通知写协程发送完缓冲区中的消息后关闭连接
+	drainCh   chan CloseInfo
+	drainOnce sync.Once
+	draining  atomic.Bool
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	closeMu   sync.Mutex
+	closeInfo CloseInfo
+
+	// lastActive 最后活跃时间（UnixNano），用于空闲连接检测
+	lastActive atomic.Int64
+
+	// resumeToken 启用会话恢复时连接的恢复令牌，acked 为客户端已确认的最大推送序号
+	resumeToken string
+	acked       atomic.Uint64
+}
+
+// ID 返回连接的唯一标识
+func (l *Link) ID() string {
+	return l.id
+}
+
+// Session 返回连接绑定的用户会话
+func (l *Link) Session() session.Session {
+	return l.session
+}
+
+// Codec 返回握手时协商的消息编解码器
+func (l *Link) Codec() message.Codec {
+	return l.codec
+}
+
+// SendMessage 使用连接的编解码器编码消息信封后放入发送缓冲区，错误语义与 Send 相同
+func (l *Link) SendMessage(msg *gatewayapiv1.Message) error {
+	payload, err := l.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return l.Send(payload)
+}
+
+// Send 将消息放入发送缓冲区，由写协程异步发送
+// 缓冲区已满时按 link.buffer.overflow 策略处理：默认不阻塞、返回 ErrSendBufferIsFull；
+// block 策略下最多阻塞 blockTimeout。连接已关闭时返回 ErrLinkClosed，连接正在优雅关闭时返回 ErrLinkDraining
+func (l *Link) Send(msg []byte) error {
+	return l.enqueue(outbound{payload: msg}, true)
+}
+
+// SendCollapsible 放入一条可替换消息，key 为空时与 Send 相同
+// 发送队列中已有折叠键相同、尚未写出的消息时，直接替换其内容并返回 replaced 为 true，
+// 替换后的消息保持原来的位置，不占用新的缓冲区，缓冲区已满时同样可以替换；
+// 正在写出或已经写出的消息不能替换，新消息照常入队。错误语义与 Send 相同
+func (l *Link) SendCollapsible(msg []byte, key string) (replaced bool, err error) {
+	return l.SendBefore(msg, key, time.Time{})
+}
+
+// SendBefore 与 SendCollapsible 相同，deadline 不为零值时为消息的投递截止时间：
+// 消息在发送队列中等到截止时间仍未写出的，写协程直接丢弃，不会迟到送达。替换可替换消息时截止时间一并替换
+func (l *Link) SendBefore(msg []byte, key string, deadline time.Time) (replaced bool, err error) {
+	return l.sendBefore(msg, key, deadline, true)
+}
+
+// TrySendBefore 与 SendBefore 相同，但 block 策略下缓冲区已满时也不等待，按 dropNewest 处理
+// 推送转发、群发等多个连接共用一个协程的路径使用，避免一个慢速连接拖慢其它连接的推送
+func (l *Link) TrySendBefore(msg []byte, key string, deadline time.Time) (replaced bool, err error) {
+	return l.sendBefore(msg, key, deadline, false)
+}
+
+// TrySendMessage 与 SendMessage 相同，但缓冲区已满时不等待，见 TrySendBefore
+func (l *Link) TrySendMessage(msg *gatewayapiv1.Message) error {
+	payload, err := l.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = l.TrySendBefore(payload, "", time.Time{})
+	return err
+}
+
+// sendBefore wait 为 false 时 block 策略下也不等待缓冲区的空位
+func (l *Link) sendBefore(msg []byte, key string, deadline time.Time, wait bool) (replaced bool, err error) {
+	var dl int64
+	if !deadline.IsZero() {
+		dl = deadline.UnixNano()
+	}
+	if key == "" {
+		return false, l.enqueue(outbound{payload: msg, deadline: dl}, wait)
+	}
+	// 先登记再入队：block 策略下入队可能等待 blockTimeout，等待期间不能持有 collapseMu，
+	// 否则写协程取出可替换消息时拿不到锁，队列无法腾出空位。等待期间同一折叠键的新消息直接替换这条消息
+	l.collapseMu.Lock()
+	if c, ok := l.collapsing[key]; ok {
+		c.payload, c.deadline = msg, dl
+		l.collapseMu.Unlock()
+		return true, nil
+	}
+	c := &collapsed{key: key, payload: msg, deadline: dl}
+	if l.collapsing == nil {
+		l.collapsing = make(map[string]*collapsed)
+	}
+	l.collapsing[key] = c
+	l.collapseMu.Unlock()
+	if err := l.enqueue(outbound{collapsed: c}, wait); err != nil {
+		l.collapseMu.Lock()
+		if l.collapsing[key] == c {
+			delete(l.collapsing, key)
+		}
+		l.collapseMu.Unlock()
+		return false, err
+	}
+	return false, nil
+}
+
+// enqueue 把消息放入发送队列，wait 为 false 时 block 策略下也不等待
+func (l *Link) enqueue(msg outbound, wait bool) error {
+	select {
+	case <-l.closeCh:
+		return ErrLinkClosed
+	default:
+	}
+	if l.draining.Load() {
+		return ErrLinkDraining
+	}
+	msg.enqueuedAt = time.Now().UnixNano()
+	select {
+	case l.sendCh <- msg:
+		return nil
+	case <-l.closeCh:
+		return ErrLinkClosed
+	default:
+		return l.overflow(msg, wait)
+	}
+}
+
+// SendQueueStats 返回发送队列的长度、容量和队头延迟
+func (l *Link) SendQueueStats() SendQueueStats {
+	stats := SendQueueStats{Len: len(l.sendCh), Cap: cap(l.sendCh)}
+	if since := l.inflightSince.Load(); since > 0 {
+		stats.OldestAge = time.Since(time.Unix(0, since))
+	}
+	return stats
+}
+
+// Stats 返回连接的运行状态
+func (l *Link) Stats() Stats {
+	info := l.session.UserInfo()
+	return Stats{
+		ID:          l.id,
+		BizID:       info.BizID,
+		UserID:      info.UserID,
+		DeviceID:    info.DeviceID,
+		Guest:       info.Guest,
+		RemoteAddr:  l.conn.RemoteAddr().String(),
+		Codec:       l.codec.Name(),
+		Encrypted:   l.cipher != nil,
+		Bandwidth:   l.Bandwidth(),
+		ConnectedAt: l.connectedAt,
+		LastActive:  l.LastActiveTime(),
+		SendQueue:   l.SendQueueStats(),
+		Traffic:     l.Traffic(),
+		Geo:         l.geoStats(),
+		Compression: l.compressionStats(),
+	}
+}
+
+func (l *Link) compressionStats() *compression.Snapshot {
+	if l.compression == nil {
+		return nil
+	}
+	snapshot := l.compression.Snapshot()
+	return &snapshot
+}
+
+// Geo 返回握手时解析得到的客户端地理位置，业务处理器可以据此就近路由，未启用解析时为零值
+func (l *Link) Geo() geoip.Location {
+	return l.geo
+}
+
+func (l *Link) geoStats() *geoip.Location {
+	if !l.geo.Known() {
+		return nil
+	}
+	geo := l.geo
+	return &geo
+}
+
+// Bandwidth 返回估计的有效下行吞吐量（字节/秒）
+// 只有发送队列出现积压时才能估计，链路最近没有成为瓶颈时返回 0
+func (l *Link) Bandwidth() int64 {
+	return l.bandwidth.bytesPerSecond()
+}
+
+// Traffic 返回连接建立以来收发的消息数和字节数
+func (l *Link) Traffic() Traffic {
+	return l.traffic.snapshot()
+}
+
+// Receive 返回接收客户端上行消息的通道，连接关闭后该通道会被关闭
+func (l *Link) Receive() <-chan []byte {
+	return l.receiveCh
+}
+
+// Close 主动关闭连接，会先尽力向客户端发送正常关闭帧
+func (l *Link) Close() error {
+	l.close(CloseInfo{Code: ws.StatusNormalClosure}, true)
+	return nil
+}
+
+// CloseWithReason 以指定的关闭码和原因关闭连接，原因超过 maxCloseReasonSize 时按UTF-8字符边界截断
+func (l *Link) CloseWithReason(code ws.StatusCode, reason string) error {
+	if !sendableCloseCode(code) {
+		return fmt.Errorf("%w: %d", ErrInvalidCloseCode, code)
+	}
+	l.close(CloseInfo{Code: code, Reason: truncateCloseReason(reason)}, true)
+	return nil
+}
+
+// sendableCloseCode 判断关闭码能否出现在关闭帧中：RFC 6455 定义的和 IANA 注册的关闭码，以及 3000-4999
+// 1004、1005、1006、1015 等保留或只用于本地报告的关闭码不能发送
+func sendableCloseCode(code ws.StatusCode) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	default:
+		return code >= 3000 && code <= 4999
+	}
+}
+
+// truncateCloseReason 把关闭原因截断到 maxCloseReasonSize 字节以内，不截断在UTF-8字符中间
+func truncateCloseReason(reason string) string {
+	if len(reason) <= maxCloseReasonSize {
+		return reason
+	}
+	n := maxCloseReasonSize
+	for n > 0 && !utf8.RuneStart(reason[n]) {
+		n--
+	}
+	return reason[:n]
+}
+
+// CloseWithBackoff 因负载原因关闭连接，以 4013 关闭码和关闭帧 reason 下发重连退避建议
+func (l *Link) CloseWithBackoff(advice backoff.Advice) {
+	l.close(CloseInfo{Code: backoff.StatusTryAgainLater, Reason: advice.Encode()}, true)
+}
+
+// Drain 优雅关闭连接：不再接受新的发送请求，写协程发送完缓冲区中已有的消息后再发送关闭帧
+// 该方法不会阻塞，调用方通过 HasClose 等待连接关闭
+func (l *Link) Drain(info CloseInfo) {
+	l.drainOnce.Do(func() {
+		l.trail.Add(incident.Event{Type: eventDrain, Detail: closeDetail(info)})
+		l.draining.Store(true)
+		l.drainCh <- info
+	})
+}
+
+// HasClose 返回一个在连接关闭时被关闭的通道
+func (l *Link) HasClose() <-chan struct{} {
+	return l.closeCh
+}
+
+// UpdateActiveTime 更新最后活跃时间
+func (l *Link) UpdateActiveTime() {
+	l.lastActive.Store(time.Now().UnixNano())
+}
+
+// LastActiveTime 返回最后活跃时间
+func (l *Link) LastActiveTime() time.Time {
+	return time.Unix(0, l.lastActive.Load())
+}
+
+// TryCloseIfIdle 如果连接空闲时间超过 timeout 则关闭连接并返回 true
+func (l *Link) TryCloseIfIdle(timeout time.Duration) bool {
+	if time.Since(l.LastActiveTime()) <= timeout {
+		return false
+	}
+	l.close(CloseInfo{Code: ws.StatusNormalClosure, Reason: CloseReasonIdle}, true)
+	return true
+}
+
+// closed 返回连接是否已经关闭
+func (l *Link) closed() bool {
+	select {
+	case <-l.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// CloseInfo 返回连接的关闭信息，连接未关闭时返回零值
+func (l *Link) CloseInfo() CloseInfo {
+	l.closeMu.Lock()
+	defer l.closeMu.Unlock()
+	return l.closeInfo
+}
+
+// close 关闭连接，只有第一次调用生效
+// sendFrame 为 true 时先尽力向客户端发送关闭帧；客户端已经断开或已回应关闭帧时无需发送
+func (l *Link) close(info CloseInfo, sendFrame bool) {
+	l.closeOnce.Do(func() {
+		l.closeMu.Lock()
+		l.closeInfo = info
+		l.closeMu.Unlock()
+		l.trail.Add(incident.Event{Type: eventClose, Detail: closeDetail(info)})
+
+		close(l.closeCh)
+		if sendFrame {
+			l.writeCloseFrame(info.Code, info.Reason)
+		}
+		if err := l.conn.Close(); err != nil {
+			l.logger.Debug("关闭底层连接失败", slog.String("linkId", l.id), slog.Any("error", err))
+		}
+	})
+}
+
+// writeCloseFrame 尽力发送一个关闭帧，失败时仅记录日志
+func (l *Link) writeCloseFrame(code ws.StatusCode, reason string) {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	if l.writeTimeout > 0 {
+		_ = l.conn.SetWriteDeadline(time.Now().Add(l.writeTimeout))
+	}
+	if l.batch != nil {
+		// 写合并期间暂存的消息先于关闭帧写出
+		_ = l.batch.flush()
+	}
+	frame := ws.NewCloseFrame(ws.NewCloseFrameBody(code, reason))
+	if err := ws.WriteFrame(l.conn, frame); err != nil {
+		l.logger.Debug("发送关闭帧失败", slog.String("linkId", l.id), slog.Any("error", err))
+	}
+}
+
+// readLoop 读协程，持续读取客户端消息直到连接关闭
+func (l *Link) readLoop() {
+	defer close(l.receiveCh)
+	defer l.incidents.Recover("link.read", l, l.closeInternalError)
+	for {
+		payload, err := l.reader.Read()
+		if err != nil {
+			l.handleReadError(err)
+			return
+		}
+		l.traffic.messagesIn.Add(1)
+		if payload, err = l.decrypt(payload); err != nil {
+			l.close(CloseInfo{Code: ws.StatusInvalidFramePayloadData, Reason: CloseReasonDecrypt}, true)
+			return
+		}
+		l.UpdateActiveTime()
+		select {
+		case l.receiveCh <- payload:
+		case <-l.closeCh:
+			wswrapper.Release(payload)
+			return
+		}
+	}
+}
+
+func (l *Link) handleReadError(err error) {
+	var closedErr wsutil.ClosedError
+	var protocolErr ws.ProtocolError
+	switch {
+	case errors.As(err, &closedErr):
+		// 客户端发送了关闭帧，控制帧处理器已经回应了关闭帧
+		l.close(CloseInfo{ByPeer: true, Code: closedErr.Code, Reason: closedErr.Reason}, false)
+	case errors.As(err, &protocolErr):
+		// 客户端发送了不符合协议的帧，错误描述是库中定义的固定文本
+		l.close(CloseInfo{Code: ws.StatusProtocolError, Reason: protocolErr.Error()}, true)
+	case errors.Is(err, wswrapper.ErrMessageTooLarge), errors.Is(err, wswrapper.ErrTooManyFragments):
+		l.close(CloseInfo{Code: ws.StatusMessageTooBig, Reason: CloseReasonMessageTooBig}, true)
+	case errors.Is(err, wswrapper.ErrFragmentTimeout):
+		l.close(CloseInfo{Code: ws.StatusPolicyViolation, Reason: CloseReasonFragmentTimeout}, true)
+	default:
+		select {
+		case <-l.closeCh:
+			// 本端主动关闭导致的读错误，无需处理
+		default:
+			l.logger.Debug("读取消息失败，连接异常断开", slog.String("linkId", l.id), slog.Any("error", err))
+			l.close(CloseInfo{ByPeer: true, Code: ws.StatusAbnormalClosure}, false)
+		}
+	}
+}
+
+// writeLoop 写协程，持续发送缓冲区中的消息直到连接关闭
+func (l *Link) writeLoop() {
+	defer l.incidents.Recover("link.write", l, l.closeInternalError)
+	for {
+		select {
+		case <-l.closeCh:
+			return
+		case info := <-l.drainCh:
+			l.flush()
+			l.close(info, true)
+			return
+		case msg := <-l.sendCh:
+			if err := l.sendBatch(msg); err != nil {
+				l.logger.Debug("发送消息失败", slog.String("linkId", l.id), slog.Any("error", err))
+				l.close(CloseInfo{Code: ws.StatusAbnormalClosure, Reason: closeReasonWriteFailed}, false)
+				return
+			}
+			l.UpdateActiveTime()
+		}
+	}
+}
+
+// flush 发送缓冲区中剩余的消息，遇到错误时放弃剩余消息
+func (l *Link) flush() {
+	for {
+		select {
+		case msg := <-l.sendCh:
+			if err := l.send(msg); err != nil {
+				l.logger.Debug("关闭前发送剩余消息失败", slog.String("linkId", l.id), slog.Any("error", err))
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// send 写入队列中的一条消息，并记录其从入队到写入完成的时长；已超过投递截止时间的消息直接丢弃
+func (l *Link) send(msg outbound) error {
+	payload, ok := l.take(msg)
+	if !ok {
+		return nil
+	}
+	l.inflightSince.Store(msg.enqueuedAt)
+	backlogged, start := len(l.sendCh) > 0, time.Now()
+	err := l.write(payload)
+	l.inflightSince.Store(0)
+	if err == nil {
+		l.bandwidth.observe(len(payload), time.Since(start), backlogged)
+		l.queue.Waited(time.Since(time.Unix(0, msg.enqueuedAt)))
+		l.trail.Add(incident.Event{Type: eventSend, Bytes: len(payload)})
+	}
+	return err
+}
+
+// take 返回队列中的一条消息要写出的内容，已超过投递截止时间时返回 false
+func (l *Link) take(msg outbound) ([]byte, bool) {
+	payload, deadline := msg.payload, msg.deadline
+	if c := msg.collapsed; c != nil {
+		// 取出后不能再被替换，之后同一折叠键的消息重新入队
+		l.collapseMu.Lock()
+		payload, deadline = c.payload, c.deadline
+		if l.collapsing[c.key] == c {
+			delete(l.collapsing, c.key)
+		}
+		l.collapseMu.Unlock()
+	}
+	if deadline > 0 && time.Now().UnixNano() >= deadline {
+		l.push.Expired(metrics.StageWrite, 1)
+		l.trail.Add(incident.Event{Type: eventExpired, Bytes: len(payload)})
+		return nil, false
+	}
+	return payload, true
+}
+
+func (l *Link) write(msg []byte) error {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	if l.writeTimeout > 0 {
+		if err := l.conn.SetWriteDeadline(time.Now().Add(l.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	if l.cipher != nil {
+		msg = l.cipher.Seal(msg)
+	}
+	if _, err := l.writer.Write(msg); err != nil {
+		return err
+	}
+	l.traffic.messagesOut.Add(1)
+	return nil
+}
@@ -0,0 +1,694 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/abuse"
+	"github.com/YaoAzure/wsgateway/internal/audit"
+	"github.com/YaoAzure/wsgateway/internal/guest"
+	"github.com/YaoAzure/wsgateway/internal/history"
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/offline"
+	"github.com/YaoAzure/wsgateway/internal/resume"
+	"github.com/YaoAzure/wsgateway/internal/uniques"
+	"github.com/YaoAzure/wsgateway/internal/webhook"
+	"github.com/YaoAzure/wsgateway/internal/wswrapper"
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/geoip"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/YaoAzure/wsgateway/pkg/types"
+	"github.com/gobwas/ws"
+	"github.com/samber/do/v2"
+)
+
+// redisTimeout 连接建立和回收过程中访问Redis的超时时间，避免Redis抖动拖住连接的建立和回收
+const redisTimeout = 3 * time.Second
+
+// Manager 管理本节点上所有已建立的连接
+// 负责连接的注册与注销、上行消息的分发，以及连接生命周期相关的指标和历史记录
+type Manager struct {
+	factory   *Factory
+	abuse     *abuse.Guard
+	messages  *metrics.MessageMetrics
+	closes    *metrics.CloseMetrics
+	reconnect *metrics.ReconnectMetrics
+	history   *history.Store
+	audit     *audit.Log
+	usage     *usageMeter // 未启用用量报告时为 nil
+	uniques   uniques.Counter
+	events    *webhook.Events
+	geo       *geoip.Resolver
+	geoConns  *metrics.GeoMetrics
+	guest     *guest.Policies
+	locator   session.Locator // 未启用多节点部署时为 nil
+	nodeID    string
+	logger    *log.Logger
+
+	handler   Handler
+	notifier  Notifier // 未设置时不上报网关事件
+	resumer   *resume.Resumer
+	offline   *offline.Store
+	rateLimit rateLimitConfig
+	loss      lossPolicies
+	// notifyFields 变更后需要下发给用户在线连接的会话字段
+	notifyFields map[string]struct{}
+	// touchInterval 收到上行消息时续期会话的最小间隔，未配置会话过期时间时为 0
+	touchInterval time.Duration
+
+	mu        sync.RWMutex
+	links     map[string]*Link             // 按连接ID索引
+	byUser    map[userKey]map[string]*Link // 按用户索引，同一用户可能有多个连接
+	byBiz     map[int64]int                // 每个业务方的连接数
+	guests    map[int64]int                // 每个业务方的访客连接数
+	draining  bool                         // 正在停机摘流，新连接建立后立即关闭
+	drainInfo CloseInfo                    // 摘流时使用的关闭信息
+}
+
+func NewManager(i do.Injector) (*Manager, error) {
+	factory, err := do.Invoke[*Factory](i)
+	if err != nil {
+		return nil, err
+	}
+	guard, err := do.Invoke[*abuse.Guard](i)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := do.Invoke[*metrics.MessageMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	closes, err := do.Invoke[*metrics.CloseMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	reconnect, err := do.Invoke[*metrics.ReconnectMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := do.Invoke[*metrics.QueueMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	store, err := do.Invoke[*history.Store](i)
+	if err != nil {
+		return nil, err
+	}
+	auditLog, err := do.Invoke[*audit.Log](i)
+	if err != nil {
+		return nil, err
+	}
+	counter, err := do.Invoke[uniques.Counter](i)
+	if err != nil {
+		return nil, err
+	}
+	linkCfg, err := do.Invoke[config.LinkConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	rateLimit, err := newRateLimitConfig(linkCfg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	sessionCfg, err := do.Invoke[config.SessionConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	usageCfg, err := do.Invoke[config.UsageConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	clusterCfg, err := do.Invoke[config.ClusterConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	var locator session.Locator
+	if clusterCfg.Enabled {
+		if locator, err = do.Invoke[session.Locator](i); err != nil {
+			return nil, err
+		}
+	}
+	watcher, err := do.Invoke[*session.ChangeWatcher](i)
+	if err != nil {
+		return nil, err
+	}
+	resumer, err := do.Invoke[*resume.Resumer](i)
+	if err != nil {
+		return nil, err
+	}
+	offlineStore, err := do.Invoke[*offline.Store](i)
+	if err != nil {
+		return nil, err
+	}
+	events, err := do.Invoke[*webhook.Events](i)
+	if err != nil {
+		return nil, err
+	}
+	geo, err := do.Invoke[*geoip.Resolver](i)
+	if err != nil {
+		return nil, err
+	}
+	geoConns, err := do.Invoke[*metrics.GeoMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	guestPolicies, err := do.Invoke[*guest.Policies](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		factory:   factory,
+		abuse:     guard,
+		messages:  messages,
+		closes:    closes,
+		reconnect: reconnect,
+		history:   store,
+		audit:     auditLog,
+		usage:     newUsageMeter(usageCfg.Enabled),
+		uniques:   counter,
+		events:    events,
+		geo:       geo,
+		geoConns:  geoConns,
+		guest:     guestPolicies,
+		locator:   locator,
+		resumer:   resumer,
+		offline:   offlineStore,
+		nodeID:    appCfg.InstanceID(),
+		logger:    logger,
+		handler:   defaultHandler(logger),
+		rateLimit: rateLimit,
+		loss:      newLossPolicies(sessionCfg.Loss),
+		// 每个过期周期内续期约三次，个别续期失败也不会导致会话过期
+		touchInterval: time.Duration(sessionCfg.TTL) / 3,
+		notifyFields:  newFieldSet(sessionCfg.NotifyFields),
+		links:         make(map[string]*Link),
+		byUser:        make(map[userKey]map[string]*Link),
+		byBiz:         make(map[int64]int),
+		guests:        make(map[int64]int),
+	}
+	queue.SetSource(m.queueAges)
+	watcher.OnChange(m.kickReplaced)
+	watcher.OnChange(m.pushFieldChange)
+	return m, nil
+}
+
+// queueAges 遍历所有发送队列非空的连接的队头延迟，供指标抓取使用
+func (m *Manager) queueAges(yield func(age time.Duration)) {
+	m.Range(func(l *Link) bool {
+		if age := l.SendQueueStats().OldestAge; age > 0 {
+			yield(age)
+		}
+		return true
+	})
+}
+
+// SetHandler 设置上行消息处理器，需要在开始接收连接前调用
+func (m *Manager) SetHandler(h Handler) {
+	m.handler = h
+}
+
+// Serve 接管一个升级成功的连接，阻塞直到连接关闭
+// release 在连接关闭 (HasClose) 时立即调用，先于会话清理等收尾工作，用于归还连接令牌；
+// 连接未能建立时不调用，可以为 nil
+// hc 为握手上下文，压缩和加密的协商结果、恢复令牌等取自其中
+func (m *Manager) Serve(conn net.Conn, ss session.Session, hc *types.HandshakeContext, release func()) {
+	info := ss.UserInfo()
+	l, err := m.factory.New(conn, ss, hc.Compression, hc.Encryption, hc.Geo)
+	if errors.Is(err, ErrKeyExchange) {
+		m.logger.Debug("下发加密协商结果失败", slog.Int64("bizId", info.BizID), slog.Int64("userId", info.UserID), slog.Any("error", err))
+		_ = conn.Close()
+		return
+	}
+	if err != nil {
+		// 握手之后编解码器被注销，只能以 1003 关闭连接，由客户端换用其它编解码器重连
+		m.logger.Warn("创建连接失败", slog.String("codec", info.Codec), slog.Any("error", err))
+		_ = ws.WriteFrame(conn, ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusUnsupportedData, "unsupported codec")))
+		_ = conn.Close()
+		return
+	}
+
+	if draining, drainInfo := m.register(l); draining {
+		// 停机开始前已经进入握手流程的连接
+		l.Drain(drainInfo)
+	}
+	m.attach(info)
+	if m.geo.Enabled() {
+		label := m.geo.Label(l.Geo())
+		m.geoConns.Connected(label)
+		defer m.geoConns.Disconnected(label)
+	}
+	if previous := ss.TakenOver(); previous != "" {
+		m.tookOver(l, previous)
+	}
+	if info.Guest {
+		defer m.limitGuestLifetime(l)()
+	}
+	// 访客的用户ID每次连接都不同，没有可以恢复的会话和离线消息
+	if m.resumer.Enabled() && !info.Guest {
+		m.resume(l, hc.URI)
+	}
+	if m.offline.Enabled() && !info.Guest {
+		m.deliverOffline(l)
+	}
+
+	m.reconnect.Reconnected(info.BizID, info.UserID)
+	m.countUnique(info)
+	m.recordHistory(info, history.Event{
+		Type:   history.EventConnect,
+		ConnID: l.ID(),
+		IP:     conn.RemoteAddr().String(),
+	})
+	m.notifyConnect(l)
+
+	limiter := m.rateLimit.newLimiter()
+	// 创建会话时已经设置了过期时间
+	touched := time.Now()
+	// Receive 通道在读协程退出时关闭
+	for payload := range l.Receive() {
+		if m.touchInterval > 0 && time.Since(touched) >= m.touchInterval {
+			touched = time.Now()
+			m.touchSession(l)
+		}
+		msg := &gatewayapiv1.Message{}
+		err := l.Codec().Unmarshal(payload, msg)
+		// 编解码器解码时复制了需要的内容，读取缓冲区归还给读取器复用
+		size := len(payload)
+		wswrapper.Release(payload)
+		if err != nil {
+			_, done := m.messages.Track(info.BizID, nil, size)
+			done()
+			l.trail.Add(incident.Event{Type: eventDecodeError, Bytes: size, Detail: err.Error()})
+			m.logger.Debug("解析上行消息失败", slog.String("linkId", l.ID()), slog.String("codec", l.Codec().Name()), slog.Any("error", err))
+			continue
+		}
+		_, done := m.messages.Track(info.BizID, msg, size)
+		l.trail.Add(incident.Event{Type: eventReceive, Cmd: msg.GetCmd().String(), Key: msg.GetKey(), Bytes: size})
+		if msg.GetCmd() == gatewayapiv1.Message_COMMAND_TYPE_DOWNSTREAM_ACK && msg.GetSeq() > 0 {
+			l.ack(msg.GetSeq())
+		}
+		if m.admit(l, limiter, msg) {
+			m.handle(l, msg)
+		}
+		done()
+	}
+	<-l.HasClose()
+	if release != nil {
+		release()
+	}
+
+	draining := m.unregister(l)
+	m.detach(info)
+	m.recordClose(l)
+	m.writeAudit(l)
+	m.notifyClose(l)
+	m.saveResumePosition(l)
+	if draining || info.Guest || m.idleClosed(l) {
+		// 访客不会以同一个用户ID重连，会话不需要保留到过期
+		m.destroySession(ss)
+	} else {
+		m.releaseSession(ss)
+	}
+}
+
+// handle 把上行消息交给处理器，处理器发生 panic 时记录事故并以 1011 关闭连接，
+// 接收通道随之关闭，Serve 照常完成连接的收尾工作
+func (m *Manager) handle(l *Link, msg *gatewayapiv1.Message) {
+	defer l.incidents.Recover("link.handler", l, l.closeInternalError)
+	m.handler.Handle(l, msg)
+}
+
+// admit 按连接的令牌桶决定是否处理一条上行消息
+// queue 策略下会阻塞等待令牌，此时不再从连接的接收通道取消息，读协程随之阻塞，对客户端形成TCP背压
+func (m *Manager) admit(l *Link, limiter *rateLimiter, msg *gatewayapiv1.Message) bool {
+	if limiter == nil {
+		return true
+	}
+	wait, ok := limiter.reserve(time.Now())
+	if ok {
+		limiter.limited = false
+		if wait <= 0 {
+			return true
+		}
+		m.messages.RateLimited("queued")
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-l.HasClose():
+			return false
+		case <-timer.C:
+			return true
+		}
+	}
+
+	l.trail.Add(incident.Event{Type: eventRateLimited, Cmd: msg.GetCmd().String(), Key: msg.GetKey(), Detail: string(limiter.policy)})
+	if !limiter.limited {
+		limiter.limited = true
+		m.ReportAbuse(l, abuse.SignalRateLimit)
+	}
+	if limiter.policy == RateLimitClose {
+		m.messages.RateLimited("closed")
+		l.close(CloseInfo{Code: ws.StatusPolicyViolation, Reason: CloseReasonRateLimit}, true)
+		return false
+	}
+	m.messages.RateLimited("dropped")
+	if err := l.SendMessage(&gatewayapiv1.Message{
+		Cmd: gatewayapiv1.Message_COMMAND_TYPE_RATE_LIMIT_EXCEEDED,
+		Key: msg.GetKey(),
+	}); err != nil {
+		m.logger.Debug("回复限流消息失败", slog.String("linkId", l.ID()), slog.Any("error", err))
+	}
+	return false
+}
+
+// idleClosed 返回连接是否因空闲超时被关闭，且用户在本节点上已经没有其它连接
+// 空闲回收的用户不会立即重连，需要删除其会话，避免在线状态残留到会话过期
+func (m *Manager) idleClosed(l *Link) bool {
+	if l.CloseInfo().Reason != CloseReasonIdle {
+		return false
+	}
+	info := l.Session().UserInfo()
+	return len(m.GetByUser(info.BizID, info.UserID)) == 0
+}
+
+// Drain 进入停机摘流状态：以 4013 关闭码和重连退避建议优雅关闭所有连接
+// 每个连接会先发送完缓冲区中的消息再发送关闭帧，连接关闭后删除其Redis会话。
+// 该方法不会等待连接关闭，调用方通过 Count 或等待 Serve 返回确认摘流完成
+func (m *Manager) Drain(advice backoff.Advice) {
+	m.mu.Lock()
+	m.draining = true
+	m.drainInfo = CloseInfo{Code: backoff.StatusTryAgainLater, Reason: advice.Encode()}
+	info := m.drainInfo
+	m.mu.Unlock()
+
+	for _, l := range m.snapshot() {
+		l.Drain(info)
+	}
+}
+
+// Undrain 退出摘流状态，重新接受新连接
+// 用于运维手动摘流后恢复节点；已经开始摘流的连接仍会关闭，客户端按退避建议重连
+func (m *Manager) Undrain() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.draining = false
+}
+
+// closeReasonCustom 管理API或业务处理器通过 CloseWithReason 传入的关闭原因在指标中的取值
+// 这些原因是任意文本，完整的原因只记录在连接历史和审计日志中
+const closeReasonCustom = "custom"
+
+// closeReasonProtocol 连接因协议错误被关闭时在指标中的原因，关闭帧中是具体的协议错误
+const closeReasonProtocol = "protocol error"
+
+// metricCloseReasons 网关内置的关闭原因，可以直接作为指标标签
+var metricCloseReasons = map[string]struct{}{
+	"":                         {},
+	CloseReasonKick:            {},
+	CloseReasonIdle:            {},
+	CloseReasonRateLimit:       {},
+	CloseReasonMessageTooBig:   {},
+	CloseReasonFragmentTimeout: {},
+	CloseReasonReplaced:        {},
+	CloseReasonDeviceLimit:     {},
+	CloseReasonDeviceRevoked:   {},
+	CloseReasonTakenOver:       {},
+	CloseReasonGuestExpired:    {},
+	CloseReasonDecrypt:         {},
+	CloseReasonSlowConsumer:    {},
+	CloseReasonSessionLost:     {},
+	CloseReasonInternalError:   {},
+	closeReasonWriteFailed:     {},
+}
+
+// metricCloseReason 返回网关主动关闭连接时指标的 reason 标签，保证标签的取值有限
+func metricCloseReason(ci CloseInfo) string {
+	if _, ok := metricCloseReasons[ci.Reason]; ok {
+		return ci.Reason
+	}
+	if ci.Code == ws.StatusProtocolError {
+		return closeReasonProtocol
+	}
+	return closeReasonCustom
+}
+
+// recordClose 记录连接关闭的指标和历史
+func (m *Manager) recordClose(l *Link) {
+	ci := l.CloseInfo()
+	info := l.Session().UserInfo()
+
+	initiator := metrics.InitiatorServer
+	// 客户端发来的关闭原因是任意文本，不能作为指标标签
+	reason := ""
+	if ci.ByPeer {
+		initiator = metrics.InitiatorClient
+	} else if advice, ok := backoff.Parse(ci.Reason); ok {
+		reason = string(advice.Reason)
+		m.reconnect.Advised(info.BizID, info.UserID, advice)
+	} else {
+		reason = metricCloseReason(ci)
+	}
+	m.closes.Record(initiator, ci.Code, reason)
+
+	switch ci.Code {
+	case ws.StatusProtocolError:
+		m.ReportAbuse(l, abuse.SignalProtocolError)
+	case ws.StatusMessageTooBig:
+		m.ReportAbuse(l, abuse.SignalOversized)
+	}
+
+	m.recordHistory(info, history.Event{
+		Type:   history.EventDisconnect,
+		ConnID: l.ID(),
+		IP:     l.conn.RemoteAddr().String(),
+		Code:   int(ci.Code),
+		Reason: ci.Reason,
+	})
+}
+
+// writeAudit 写出连接的审计记录
+func (m *Manager) writeAudit(l *Link) {
+	if !m.audit.Enabled() {
+		return
+	}
+	ci := l.CloseInfo()
+	info := l.Session().UserInfo()
+	traffic := l.Traffic()
+	m.audit.Write(audit.Record{
+		ConnID:         l.ID(),
+		Node:           m.nodeID,
+		BizID:          info.BizID,
+		UserID:         info.UserID,
+		DeviceID:       info.DeviceID,
+		Guest:          info.Guest,
+		RemoteAddr:     l.conn.RemoteAddr().String(),
+		Codec:          l.Codec().Name(),
+		ConnectedAt:    l.connectedAt,
+		DisconnectedAt: time.Now(),
+		MessagesIn:     traffic.MessagesIn,
+		MessagesOut:    traffic.MessagesOut,
+		BytesIn:        traffic.BytesIn,
+		BytesOut:       traffic.BytesOut,
+		Code:           int(ci.Code),
+		Reason:         ci.Reason,
+		ByPeer:         ci.ByPeer,
+	})
+}
+
+// notifyConnect 向业务方的生命周期webhook发送连接建立事件
+func (m *Manager) notifyConnect(l *Link) {
+	if !m.events.Enabled() {
+		return
+	}
+	info := l.Session().UserInfo()
+	m.events.Notify(webhook.Event{
+		Type:     webhook.EventConnect,
+		BizID:    info.BizID,
+		UserID:   info.UserID,
+		ConnID:   l.ID(),
+		DeviceID: info.DeviceID,
+		Guest:    info.Guest,
+		Node:     m.nodeID,
+		IP:       l.conn.RemoteAddr().String(),
+		Time:     l.connectedAt.UnixMilli(),
+	})
+}
+
+// notifyClose 向业务方的生命周期webhook发送连接关闭事件，空闲回收的连接发送 idleClose 事件
+func (m *Manager) notifyClose(l *Link) {
+	if !m.events.Enabled() {
+		return
+	}
+	ci := l.CloseInfo()
+	info := l.Session().UserInfo()
+	typ := webhook.EventDisconnect
+	if ci.Reason == CloseReasonIdle {
+		typ = webhook.EventIdleClose
+	}
+	now := time.Now()
+	m.events.Notify(webhook.Event{
+		Type:     typ,
+		BizID:    info.BizID,
+		UserID:   info.UserID,
+		ConnID:   l.ID(),
+		DeviceID: info.DeviceID,
+		Guest:    info.Guest,
+		Node:     m.nodeID,
+		IP:       l.conn.RemoteAddr().String(),
+		Code:     int(ci.Code),
+		Reason:   ci.Reason,
+		ByPeer:   ci.ByPeer,
+		Duration: now.Sub(l.connectedAt).Milliseconds(),
+		Time:     now.UnixMilli(),
+	})
+}
+
+// ReportAbuse 上报连接的滥用信号，导致封禁时关闭该用户在本节点上的所有连接
+func (m *Manager) ReportAbuse(l *Link, signal abuse.Signal) {
+	if !m.abuse.Enabled() {
+		return
+	}
+	info := l.Session().UserInfo()
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	verdict, err := m.abuse.Report(ctx, abuse.UserSubject(info.BizID, info.UserID), signal)
+	if err != nil {
+		m.logger.Warn("上报滥用信号失败", slog.String("linkId", l.ID()), slog.String("signal", string(signal)), slog.Any("error", err))
+		return
+	}
+	if !verdict.Banned {
+		return
+	}
+	m.logger.Warn("客户端滥用分达到阈值，已封禁",
+		slog.Int64("bizId", info.BizID),
+		slog.Int64("userId", info.UserID),
+		slog.String("signal", string(signal)),
+		slog.Int64("score", verdict.Score),
+		slog.Duration("banFor", verdict.BanFor))
+	advice := backoff.BanAdvice(verdict.BanFor)
+	for _, ul := range m.GetByUser(info.BizID, info.UserID) {
+		ul.CloseWithBackoff(advice)
+	}
+}
+
+// countUnique 把用户计入业务方的去重用户统计
+func (m *Manager) countUnique(info session.UserInfo) {
+	if info.Guest {
+		// 访客的用户ID每次连接都不同，计入去重用户数会虚高
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := m.uniques.Add(ctx, info.BizID, info.UserID, time.Now()); err != nil {
+		m.logger.Warn("更新去重用户统计失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+	}
+}
+
+// attach 在会话中记录用户在本节点上新建立了一个连接，供其它节点路由推送
+func (m *Manager) attach(info session.UserInfo) {
+	if m.locator == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := m.locator.Attach(ctx, info.BizID, info.UserID, m.nodeID); err != nil {
+		m.logger.Warn("记录连接所在节点失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+	}
+}
+
+// detach 在会话中记录用户在本节点上关闭了一个连接
+func (m *Manager) detach(info session.UserInfo) {
+	if m.locator == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := m.locator.Detach(ctx, info.BizID, info.UserID, m.nodeID); err != nil {
+		m.logger.Warn("清除连接所在节点失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+	}
+}
+
+// touchSession 续期连接的Redis会话，连接持续活跃时会话不会过期；发现会话已丢失时按业务方的策略处理
+func (m *Manager) touchSession(l *Link) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	err := l.Session().Touch(ctx)
+	if errors.Is(err, session.ErrSessionNotFound) {
+		m.sessionLost(l)
+		return
+	}
+	if err != nil {
+		info := l.Session().UserInfo()
+		m.logger.Warn("续期会话失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+	}
+}
+
+// releaseSession 释放连接在会话中占用的槽位，rejectNew 策略下用户随后可以重新建立连接
+func (m *Manager) releaseSession(ss session.Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := ss.Release(ctx); err != nil {
+		info := ss.UserInfo()
+		m.logger.Warn("释放会话槽位失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+	}
+}
+
+// destroySession 删除连接的Redis会话，避免节点下线后残留过期的会话
+// 用户在其它连接（包括其它节点上的连接）上仍在线时只释放当前连接的槽位和记录，保留会话和其它节点的推送路由
+// 客户端按退避建议至少等待一段时间才会重连到其它节点，因此这里不会误删新建立的会话
+func (m *Manager) destroySession(ss session.Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := ss.Destroy(ctx); err != nil {
+		info := ss.UserInfo()
+		m.logger.Warn("删除会话失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+	}
+}
+
+func (m *Manager) recordHistory(info session.UserInfo, e history.Event) {
+	if !m.history.Enabled() || info.Guest {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := m.history.Record(ctx, info.BizID, info.UserID, e); err != nil {
+		m.logger.Warn("写入连接历史失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.String("type", string(e.Type)),
+			slog.Any("error", err))
+	}
+}
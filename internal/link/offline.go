@@ -0,0 +1,46 @@
+package link
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+)
+
+// deliverOffline 连接建立后投递用户的离线消息
+// 发送缓冲区满时把剩余的消息放回离线消息列表，等下次建立连接时再投递；超过投递截止时间的消息直接丢弃
+func (m *Manager) deliverOffline(l *Link) {
+	info := l.Session().UserInfo()
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	msgs, err := m.offline.Take(ctx, info.BizID, info.UserID)
+	if err != nil {
+		m.logger.Warn("读取离线消息失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+		return
+	}
+	for i, p := range msgs {
+		if expired(p) {
+			m.factory.push.Expired(metrics.StageOffline, 1)
+			continue
+		}
+		err := sendPush(l, p)
+		if err == nil {
+			continue
+		}
+		m.logger.Debug("投递离线消息失败，放回剩余的消息",
+			slog.String("linkId", l.ID()),
+			slog.Int("remaining", len(msgs)-i),
+			slog.Any("error", err))
+		if err := m.offline.Restore(ctx, info.BizID, info.UserID, msgs[i:]); err != nil {
+			m.logger.Warn("放回离线消息失败",
+				slog.Int64("bizId", info.BizID),
+				slog.Int64("userId", info.UserID),
+				slog.Int("dropped", len(msgs)-i),
+				slog.Any("error", err))
+		}
+		return
+	}
+}
@@ -0,0 +1,116 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/gobwas/ws"
+)
+
+var ErrUnknownOverflowPolicy = errors.New("未知的发送队列溢出策略")
+
+// CloseReasonSlowConsumer close 溢出策略下发送队列已满时关闭连接的原因，关闭码为 1008
+const CloseReasonSlowConsumer = "slow consumer"
+
+// defaultBlockTimeout block 策略未配置等待时间时使用的默认值
+const defaultBlockTimeout = 100 * time.Millisecond
+
+// OverflowPolicy 下行消息的发送队列已满时的处理策略
+type OverflowPolicy string
+
+const (
+	OverflowDropNewest OverflowPolicy = "dropNewest" // 丢弃新消息，发送方收到 ErrSendBufferIsFull
+	OverflowDropOldest OverflowPolicy = "dropOldest" // 丢弃队列中最老的未写出消息，为新消息腾出位置
+	OverflowBlock      OverflowPolicy = "block"      // 发送方最多等待 blockTimeout，仍然放不下时返回 ErrSendBufferIsFull
+	OverflowClose      OverflowPolicy = "close"      // 以 1008 关闭跟不上的连接，发送方收到 ErrLinkClosed
+)
+
+// overflowConfig 解析后的发送队列溢出策略
+type overflowConfig struct {
+	policy       OverflowPolicy
+	blockTimeout time.Duration
+}
+
+func newOverflowConfig(cfg config.BufferConfig) (overflowConfig, error) {
+	policy := OverflowPolicy(cfg.Overflow)
+	switch policy {
+	case "":
+		policy = OverflowDropNewest
+	case OverflowDropNewest, OverflowDropOldest, OverflowBlock, OverflowClose:
+	default:
+		return overflowConfig{}, fmt.Errorf("%w: %s", ErrUnknownOverflowPolicy, cfg.Overflow)
+	}
+	timeout := time.Duration(cfg.BlockTimeout)
+	if timeout <= 0 {
+		timeout = defaultBlockTimeout
+	}
+	return overflowConfig{policy: policy, blockTimeout: timeout}, nil
+}
+
+// overflow 发送队列已满时按溢出策略处理 msg，wait 为 false 时 block 策略按 dropNewest 处理
+// 调用方不能持有 collapseMu：写协程取出可替换消息和丢弃队列中的可替换消息都需要这把锁
+func (l *Link) overflow(msg outbound, wait bool) error {
+	policy := l.overflowCfg.policy
+	if policy == OverflowBlock && !wait {
+		policy = OverflowDropNewest
+	}
+	switch policy {
+	case OverflowDropOldest:
+		// 写协程可能同时取走消息，腾出的位置也可能被其它发送方抢先占用，因此有限次重试
+		for range 3 {
+			select {
+			case old := <-l.sendCh:
+				l.discard(old)
+			default:
+			}
+			select {
+			case l.sendCh <- msg:
+				return nil
+			default:
+			}
+		}
+	case OverflowBlock:
+		timer := time.NewTimer(l.overflowCfg.blockTimeout)
+		defer timer.Stop()
+		select {
+		case l.sendCh <- msg:
+			return nil
+		case <-l.closeCh:
+			return ErrLinkClosed
+		case <-timer.C:
+			l.queue.Overflowed(metrics.OverflowTimeout)
+			l.trail.Add(incident.Event{Type: eventSendFull, Bytes: len(msg.payload)})
+			return ErrSendBufferIsFull
+		}
+	case OverflowClose:
+		if l.overflowClosing.CompareAndSwap(false, true) {
+			l.queue.Overflowed(metrics.OverflowClosed)
+			l.trail.Add(incident.Event{Type: eventSendFull, Bytes: len(msg.payload)})
+			// 写协程可能正卡在写超时上，关闭帧要等它释放写锁，不能让发送方一起等待
+			go l.close(CloseInfo{Code: ws.StatusPolicyViolation, Reason: CloseReasonSlowConsumer}, true)
+		}
+		return ErrLinkClosed
+	}
+	l.queue.Overflowed(metrics.OverflowDroppedNewest)
+	l.trail.Add(incident.Event{Type: eventSendFull, Bytes: len(msg.payload)})
+	return ErrSendBufferIsFull
+}
+
+// discard 丢弃从发送队列中取出的一条未写出的消息
+func (l *Link) discard(msg outbound) {
+	bytes := len(msg.payload)
+	if c := msg.collapsed; c != nil {
+		l.collapseMu.Lock()
+		bytes = len(c.payload)
+		if l.collapsing[c.key] == c {
+			delete(l.collapsing, c.key)
+		}
+		l.collapseMu.Unlock()
+	}
+	l.queue.Overflowed(metrics.OverflowDroppedOldest)
+	l.trail.Add(incident.Event{Type: eventSendDropped, Bytes: bytes})
+}
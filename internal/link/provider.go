@@ -0,0 +1,13 @@
+package link
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Link 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewFactory),
+	do.Lazy(NewManager),
+	do.Lazy(NewReaper),
+	do.Lazy(NewSessionChecker),
+)
@@ -0,0 +1,89 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+)
+
+var ErrUnknownRateLimitPolicy = errors.New("未知的限流策略")
+
+// RateLimitPolicy 上行消息超过单连接速率限制时的处理策略
+type RateLimitPolicy string
+
+const (
+	RateLimitDrop  RateLimitPolicy = "drop"  // 丢弃消息并回复 RATE_LIMIT_EXCEEDED
+	RateLimitQueue RateLimitPolicy = "queue" // 暂停读取直到令牌可用，等待超过上限时丢弃
+	RateLimitClose RateLimitPolicy = "close" // 以 1008 关闭连接
+)
+
+// rateLimitConfig 解析后的限流配置，每个连接据此创建自己的令牌桶
+type rateLimitConfig struct {
+	rate    float64
+	burst   float64
+	policy  RateLimitPolicy
+	maxWait time.Duration
+}
+
+func newRateLimitConfig(cfg config.LimitConfig) (rateLimitConfig, error) {
+	policy := RateLimitPolicy(cfg.Policy)
+	switch policy {
+	case "":
+		policy = RateLimitDrop
+	case RateLimitDrop, RateLimitQueue, RateLimitClose:
+	default:
+		return rateLimitConfig{}, fmt.Errorf("%w: %s", ErrUnknownRateLimitPolicy, cfg.Policy)
+	}
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = max(cfg.Rate, 1)
+	}
+	return rateLimitConfig{
+		rate:    float64(cfg.Rate),
+		burst:   float64(burst),
+		policy:  policy,
+		maxWait: time.Duration(cfg.MaxWait),
+	}, nil
+}
+
+// newLimiter 为一个连接创建令牌桶，未配置速率时返回 nil 表示不限流
+func (c rateLimitConfig) newLimiter() *rateLimiter {
+	if c.rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rateLimitConfig: c, tokens: c.burst, last: time.Now()}
+}
+
+// rateLimiter 单个连接的上行消息令牌桶
+// 只在连接的消息分发协程中使用，不需要加锁
+type rateLimiter struct {
+	rateLimitConfig
+	tokens float64
+	last   time.Time
+
+	// limited 上一条消息是否被限流，用于只在进入限流状态时上报一次滥用信号
+	limited bool
+}
+
+// reserve 为一条消息预留令牌，返回需要等待的时长；ok 为 false 表示超过限制，没有消耗令牌
+// 只有 queue 策略会返回大于 0 的等待时长
+func (r *rateLimiter) reserve(now time.Time) (wait time.Duration, ok bool) {
+	r.tokens = min(r.tokens+now.Sub(r.last).Seconds()*r.rate, r.burst)
+	r.last = now
+	if r.tokens >= 1 {
+		r.tokens--
+		return 0, true
+	}
+	if r.policy != RateLimitQueue {
+		return 0, false
+	}
+	wait = time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+	if wait > r.maxWait {
+		return 0, false
+	}
+	// 预支令牌，令牌数为负时后续消息需要等待更久
+	r.tokens--
+	return wait, true
+}
@@ -0,0 +1,138 @@
+package link
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/subsystem"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// minReapInterval 扫描间隔的下限，避免空闲超时配置得很短时频繁遍历所有连接
+	minReapInterval = time.Second
+	// maxReapInterval 扫描间隔的上限，空闲超时很长时也能及时回收
+	maxReapInterval = 30 * time.Second
+)
+
+// Reaper 空闲连接回收器
+// 定期遍历所有连接，关闭握手时声明了 AutoClose 且空闲超过 LinkConfig.Timeout.Idle 的连接；
+// 连接关闭后由 Manager 删除其会话（用户在本节点没有其它连接时）
+// 可以通过管理API暂停回收，例如客户端因网络故障大面积无法发送心跳时避免误关连接
+type Reaper struct {
+	links   *Manager
+	timeout time.Duration
+	toggle  *subsystem.Toggle
+	logger  *log.Logger
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	done      chan struct{}
+}
+
+func NewReaper(i do.Injector) (*Reaper, error) {
+	cfg, err := do.Invoke[config.LinkConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	links, err := do.Invoke[*Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Reaper{
+		links:   links,
+		timeout: time.Duration(cfg.Timeout.Idle),
+		toggle:  subsystem.NewToggle(),
+		logger:  logger,
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Start 在后台启动回收协程，未配置空闲超时时不启动；重复调用无效
+func (r *Reaper) Start() {
+	r.startOnce.Do(func() {
+		if r.timeout <= 0 {
+			close(r.done)
+			return
+		}
+		go r.run()
+	})
+}
+
+// Shutdown 停止回收协程并等待其退出
+func (r *Reaper) Shutdown() {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	r.startOnce.Do(func() { close(r.done) })
+	<-r.done
+}
+
+func (r *Reaper) run() {
+	defer close(r.done)
+	interval := min(max(r.timeout/4, minReapInterval), maxReapInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case <-ticker.C:
+			r.reap()
+		}
+	}
+}
+
+// Pause 暂停回收，暂停期间空闲的连接在恢复后的下一轮扫描中关闭
+func (r *Reaper) Pause() bool {
+	return r.toggle.Pause()
+}
+
+// Resume 恢复回收
+func (r *Reaper) Resume() bool {
+	return r.toggle.Resume()
+}
+
+// Status 返回回收器的运行状态，未配置空闲超时时回收器不会运行
+func (r *Reaper) Status() subsystem.Status {
+	return r.toggle.Status("reaper", map[string]any{
+		"enabled":     r.timeout > 0,
+		"idleTimeout": r.timeout.String(),
+	})
+}
+
+// reap 扫描一轮，关闭空闲超时的连接
+func (r *Reaper) reap() {
+	if r.toggle.Paused() {
+		return
+	}
+	closed := 0
+	r.links.Range(func(l *Link) bool {
+		if !l.Session().UserInfo().AutoClose {
+			return true
+		}
+		if l.TryCloseIfIdle(r.timeout) {
+			closed++
+			info := l.Session().UserInfo()
+			r.logger.Info("关闭空闲连接",
+				slog.String("linkId", l.ID()),
+				slog.Int64("bizId", info.BizID),
+				slog.Int64("userId", info.UserID),
+				slog.Time("lastActive", l.LastActiveTime()))
+		}
+		return true
+	})
+	if closed > 0 {
+		r.logger.Debug("空闲连接回收完成", slog.Int("closed", closed), slog.Duration("timeout", r.timeout))
+	}
+}
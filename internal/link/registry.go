@@ -0,0 +1,243 @@
+package link
+
+import (
+	"context"
+	"maps"
+
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/gobwas/ws"
+)
+
+// 网关主动关闭连接时关闭帧中的原因
+const (
+	CloseReasonKick = "kick"         // 被踢下线
+	CloseReasonIdle = "idle timeout" // 空闲超时
+
+	CloseReasonRateLimit = "rate limit exceeded" // 上行消息超过速率限制
+
+	CloseReasonMessageTooBig   = "message too big"  // 上行消息的大小或分片数超过 link.inbound 的限制
+	CloseReasonFragmentTimeout = "fragment timeout" // 分片消息未在 link.inbound.fragmentTimeout 内收完
+
+	CloseReasonReplaced = "replaced" // 被同一用户 (同一设备) 的新连接取代
+
+	CloseReasonDeviceLimit   = "device limit"   // 用户同时在线的设备数超过上限，最早上线的设备被踢下线
+	CloseReasonDeviceRevoked = "device revoked" // 设备通过管理API被移除
+)
+
+// StatusReplaced 连接被同一用户的新连接取代时的关闭码，客户端收到后不应自动重连，否则会与新连接相互踢下线
+// 设备因设备数上限被踢下线或被移除时同样使用该关闭码，关闭原因不同
+const StatusReplaced ws.StatusCode = 4409
+
+// userKey 用户维度的连接索引键
+type userKey struct {
+	bizID  int64
+	userID int64
+}
+
+func userKeyOf(info session.UserInfo) userKey {
+	return userKey{bizID: info.BizID, userID: info.UserID}
+}
+
+// register 把连接加入索引，返回当前是否处于摘流状态
+func (m *Manager) register(l *Link) (bool, CloseInfo) {
+	key := userKeyOf(l.Session().UserInfo())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links[l.ID()] = l
+	userLinks, ok := m.byUser[key]
+	if !ok {
+		userLinks = make(map[string]*Link, 1)
+		m.byUser[key] = userLinks
+	}
+	userLinks[l.ID()] = l
+	m.byBiz[key.bizID]++
+	if l.Session().UserInfo().Guest {
+		m.guests[key.bizID]++
+	}
+	m.usage.opened(key.bizID, m.byBiz[key.bizID])
+	return m.draining, m.drainInfo
+}
+
+// unregister 把连接从索引中移除，返回当前是否处于摘流状态
+func (m *Manager) unregister(l *Link) bool {
+	key := userKeyOf(l.Session().UserInfo())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.links[l.ID()]; !ok {
+		return m.draining
+	}
+	delete(m.links, l.ID())
+	m.usage.closed(l)
+	if userLinks, ok := m.byUser[key]; ok {
+		delete(userLinks, l.ID())
+		if len(userLinks) == 0 {
+			delete(m.byUser, key)
+		}
+	}
+	if m.byBiz[key.bizID]--; m.byBiz[key.bizID] <= 0 {
+		delete(m.byBiz, key.bizID)
+	}
+	if l.Session().UserInfo().Guest {
+		if m.guests[key.bizID]--; m.guests[key.bizID] <= 0 {
+			delete(m.guests, key.bizID)
+		}
+	}
+	return m.draining
+}
+
+// Get 按连接ID查找连接
+func (m *Manager) Get(id string) (*Link, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	l, ok := m.links[id]
+	return l, ok
+}
+
+// GetByUser 返回用户在本节点上的所有连接（多端登录时可能有多个）
+func (m *Manager) GetByUser(bizID, userID int64) []*Link {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	userLinks := m.byUser[userKey{bizID: bizID, userID: userID}]
+	links := make([]*Link, 0, len(userLinks))
+	for _, l := range userLinks {
+		links = append(links, l)
+	}
+	return links
+}
+
+// GetByBiz 返回业务方在本节点上的所有连接
+func (m *Manager) GetByBiz(bizID int64) []*Link {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	links := make([]*Link, 0, m.byBiz[bizID])
+	for key, userLinks := range m.byUser {
+		if key.bizID != bizID {
+			continue
+		}
+		for _, l := range userLinks {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// Range 遍历所有连接，fn 返回 false 时停止遍历
+// 遍历的是调用时刻的快照，fn 中可以安全地关闭连接或调用 Manager 的其它方法
+func (m *Manager) Range(fn func(l *Link) bool) {
+	for _, l := range m.snapshot() {
+		if !fn(l) {
+			return
+		}
+	}
+}
+
+// Count 返回当前连接数
+func (m *Manager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.links)
+}
+
+// CountUsers 返回当前在线的用户数
+func (m *Manager) CountUsers() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.byUser)
+}
+
+// CountByBiz 返回业务方在本节点上的连接数
+func (m *Manager) CountByBiz(bizID int64) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.byBiz[bizID]
+}
+
+// CountByUser 返回用户在本节点上的连接数
+func (m *Manager) CountByUser(bizID, userID int64) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.byUser[userKey{bizID: bizID, userID: userID}])
+}
+
+// CountGuestsByBiz 返回业务方在本节点上的访客连接数
+func (m *Manager) CountGuestsByBiz(bizID int64) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.guests[bizID]
+}
+
+// CountsByBiz 返回每个业务方的连接数
+func (m *Manager) CountsByBiz() map[int64]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return maps.Clone(m.byBiz)
+}
+
+// Draining 返回是否处于停机摘流状态
+func (m *Manager) Draining() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.draining
+}
+
+// Kick 将用户在本节点上的所有连接踢下线，返回被关闭的连接数
+func (m *Manager) Kick(bizID, userID int64) int {
+	links := m.GetByUser(bizID, userID)
+	for _, l := range links {
+		l.close(CloseInfo{Code: ws.StatusPolicyViolation, Reason: CloseReasonKick}, true)
+	}
+	return len(links)
+}
+
+// Disconnect 以指定的关闭码和原因关闭本节点上的一个连接，连接不存在时返回 false
+// 连接先尽力向客户端发送关闭帧，随后按正常的关闭流程释放会话和记录历史
+func (m *Manager) Disconnect(id string, code ws.StatusCode, reason string) (bool, error) {
+	l, ok := m.Get(id)
+	if !ok {
+		return false, nil
+	}
+	return true, l.CloseWithReason(code, reason)
+}
+
+// kickReplaced 处理会话槽位变更通知，关闭本节点上被同一用户的新连接取代的连接
+// 新连接可能建立在任意节点上，通知通过 session.ChangeWatcher 广播到所有节点。
+// takeover 策略下旧连接先收到接管通知再关闭，其它策略下直接关闭。
+// 设备因设备数上限被踢下线或被移除时，关闭该设备上的所有连接
+func (m *Manager) kickReplaced(_ context.Context, change session.FieldChange) {
+	reason := CloseReasonReplaced
+	switch {
+	case change.DeviceEvicted():
+		reason = CloseReasonDeviceLimit
+	case change.DeviceRevoked():
+		reason = CloseReasonDeviceRevoked
+	}
+	for _, l := range m.GetByUser(change.BizID, change.UserID) {
+		if !change.Supersedes(l.Session().UserInfo()) {
+			continue
+		}
+		if change.Takeover {
+			m.takenOver(l, change)
+			continue
+		}
+		l.close(CloseInfo{Code: StatusReplaced, Reason: reason}, true)
+	}
+}
+
+// CloseAll 关闭所有连接
+func (m *Manager) CloseAll() {
+	for _, l := range m.snapshot() {
+		_ = l.Close()
+	}
+}
+
+func (m *Manager) snapshot() []*Link {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	links := make([]*Link, 0, len(m.links))
+	for _, l := range m.links {
+		links = append(links, l)
+	}
+	return links
+}
@@ -0,0 +1,94 @@
+package link
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+)
+
+// resumeLost RESUME 消息的 body，表示有推送已无法补发，客户端需要全量同步
+var resumeLost = []byte("lost")
+
+// resume 下发携带恢复令牌的 RESUME 消息，并补发客户端断线期间未确认的推送
+// 补发与实时推送可能重复，由客户端按序号去重；发送缓冲区满时放弃剩余的补发，下次恢复时仍会补发；
+// 超过投递截止时间的推送不再补发
+func (m *Manager) resume(l *Link, uri string) {
+	info := l.Session().UserInfo()
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	res, err := m.resumer.Resume(ctx, info.BizID, info.UserID, resumeToken(uri))
+	if err != nil {
+		m.logger.Warn("恢复会话失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+		return
+	}
+	l.resumeToken = res.Token
+	l.acked.Store(res.After)
+
+	msg := &gatewayapiv1.Message{
+		Cmd: gatewayapiv1.Message_COMMAND_TYPE_RESUME,
+		Key: res.Token,
+		Seq: res.After,
+	}
+	if res.Lost {
+		msg.Body = resumeLost
+	}
+	if err := l.SendMessage(msg); err != nil {
+		m.logger.Debug("下发恢复令牌失败", slog.String("linkId", l.ID()), slog.Any("error", err))
+		return
+	}
+	for i, p := range res.Replay {
+		if expired(p) {
+			// 客户端按序号去重，跳过的序号不影响之后的确认
+			m.factory.push.Expired(metrics.StageResume, 1)
+			continue
+		}
+		if err := sendPush(l, p); err != nil {
+			m.logger.Debug("补发推送失败，放弃剩余的补发",
+				slog.String("linkId", l.ID()),
+				slog.Int("remaining", len(res.Replay)-i),
+				slog.Any("error", err))
+			return
+		}
+	}
+}
+
+// saveResumePosition 连接关闭时保存恢复令牌和客户端已确认的序号
+func (m *Manager) saveResumePosition(l *Link) {
+	if l.resumeToken == "" {
+		return
+	}
+	info := l.Session().UserInfo()
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := m.resumer.Save(ctx, l.resumeToken, info.BizID, info.UserID, l.acked.Load()); err != nil {
+		m.logger.Warn("保存恢复令牌失败",
+			slog.Int64("bizId", info.BizID),
+			slog.Int64("userId", info.UserID),
+			slog.Any("error", err))
+	}
+}
+
+// ack 记录客户端确认的推送序号，确认是累积的，只保留最大值
+func (l *Link) ack(seq uint64) {
+	for {
+		acked := l.acked.Load()
+		if seq <= acked || l.acked.CompareAndSwap(acked, seq) {
+			return
+		}
+	}
+}
+
+// resumeToken 从握手请求的URI中取出 ?resume= 恢复令牌
+func resumeToken(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("resume")
+}
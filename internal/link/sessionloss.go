@@ -0,0 +1,207 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/gobwas/ws"
+	"github.com/samber/do/v2"
+)
+
+// CloseReasonSessionLost 连接的会话从Redis中丢失，按策略关闭连接时的原因
+const CloseReasonSessionLost = "session lost"
+
+// StatusReauthenticate 连接需要重新认证时的关闭码，客户端应重新获取令牌后重连，握手时重新创建会话
+const StatusReauthenticate ws.StatusCode = 4401
+
+// checkBatchSize 一次检查会话是否存在的流水线中最多包含的用户数
+const checkBatchSize = 1000
+
+// 会话丢失时的处理策略
+const (
+	lossRebuild = "rebuild" // 重建会话并重新占用连接的槽位
+	lossClose   = "close"   // 以 4401 关闭连接
+)
+
+// lossPolicies 按业务方解析的会话丢失处理策略
+type lossPolicies struct {
+	defaultPolicy string
+	biz           map[int64]string
+}
+
+func newLossPolicies(cfg config.SessionLossConfig) lossPolicies {
+	p := lossPolicies{defaultPolicy: lossRebuild, biz: make(map[int64]string, len(cfg.BizPolicies))}
+	if cfg.Policy != "" {
+		p.defaultPolicy = cfg.Policy
+	}
+	for _, b := range cfg.BizPolicies {
+		p.biz[b.BizID] = b.Policy
+	}
+	return p
+}
+
+func (p lossPolicies) of(bizID int64) string {
+	if policy, ok := p.biz[bizID]; ok {
+		return policy
+	}
+	return p.defaultPolicy
+}
+
+// sessionLost 按业务方的策略处理会话已从Redis中丢失的连接：重建会话，或以 4401 关闭连接
+// 会话由握手时的用户信息重建，业务写入的字段和握手后补充的会话数据不会恢复；
+// 重建时槽位已被用户的新连接占用的连接视为被取代，超过设备数上限等其它原因无法重建时关闭连接；
+// 访问Redis失败时只记录日志，留给下一次检查
+func (m *Manager) sessionLost(l *Link) {
+	if l.closed() {
+		return
+	}
+	info := l.Session().UserInfo()
+	attrs := []any{
+		slog.String("linkId", l.ID()),
+		slog.Int64("bizId", info.BizID),
+		slog.Int64("userId", info.UserID),
+	}
+	if m.loss.of(info.BizID) == lossClose {
+		m.logger.Info("会话已丢失，关闭连接", attrs...)
+		l.close(CloseInfo{Code: StatusReauthenticate, Reason: CloseReasonSessionLost}, true)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	restored, err := l.Session().Restore(ctx)
+	switch {
+	case err == nil:
+		if restored {
+			// 节点记录与会话存储在同一个哈希中，随会话一起丢失，重新记录连接所在的节点
+			m.attach(info)
+			m.logger.Info("会话已丢失，已重建", attrs...)
+		}
+	case errors.Is(err, session.ErrDeviceConflict):
+		m.logger.Info("会话已丢失，连接已被新连接取代", append(attrs, slog.Any("error", err))...)
+		l.close(CloseInfo{Code: StatusReplaced, Reason: CloseReasonReplaced}, true)
+	case errors.Is(err, session.ErrDeviceLimit):
+		m.logger.Info("会话已丢失，重建时超过设备数上限，关闭连接", append(attrs, slog.Any("error", err))...)
+		l.close(CloseInfo{Code: StatusReauthenticate, Reason: CloseReasonSessionLost}, true)
+	default:
+		m.logger.Warn("重建会话失败", append(attrs, slog.Any("error", err))...)
+	}
+}
+
+// SessionChecker 定期检查本节点连接的会话是否仍然存在
+// 会话可能在连接存续期间从Redis中消失：被手动删除、过期，或者Redis故障切换时丢失数据；
+// 不检查时连接仍然在线，但在线状态、设备查询和跨节点推送都找不到它。
+// 每轮检查按用户去重，用流水线批量查询，发现丢失的会话后按业务方的策略重建会话或关闭连接
+type SessionChecker struct {
+	links    *Manager
+	checker  session.Checker
+	interval time.Duration
+	logger   *log.Logger
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	done      chan struct{}
+}
+
+func NewSessionChecker(i do.Injector) (*SessionChecker, error) {
+	cfg, err := do.Invoke[config.SessionConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	links, err := do.Invoke[*Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	checker, err := do.Invoke[session.Checker](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionChecker{
+		links:    links,
+		checker:  checker,
+		interval: time.Duration(cfg.Loss.CheckInterval),
+		logger:   logger,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start 在后台启动检查协程，未配置检查间隔时不启动；重复调用无效
+func (c *SessionChecker) Start() {
+	c.startOnce.Do(func() {
+		if c.interval <= 0 {
+			close(c.done)
+			return
+		}
+		go c.run()
+	})
+}
+
+// Shutdown 停止检查协程并等待其退出
+func (c *SessionChecker) Shutdown() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	c.startOnce.Do(func() { close(c.done) })
+	<-c.done
+}
+
+func (c *SessionChecker) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+			c.check()
+		}
+	}
+}
+
+// check 检查一轮，处理会话已丢失的连接
+func (c *SessionChecker) check() {
+	byUser := make(map[userKey][]*Link)
+	var users []session.UserInfo
+	c.links.Range(func(l *Link) bool {
+		info := l.Session().UserInfo()
+		key := userKeyOf(info)
+		if _, ok := byUser[key]; !ok {
+			users = append(users, info)
+		}
+		byUser[key] = append(byUser[key], l)
+		return true
+	})
+	lost := 0
+	for batch := range slices.Chunk(users, checkBatchSize) {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		missing, err := c.checker.Missing(ctx, batch)
+		cancel()
+		if err != nil {
+			c.logger.Warn("检查会话是否存在失败", slog.Int("users", len(batch)), slog.Any("error", err))
+			continue
+		}
+		for _, info := range missing {
+			for _, l := range byUser[userKeyOf(info)] {
+				c.links.sessionLost(l)
+				lost++
+			}
+		}
+	}
+	if lost > 0 {
+		c.logger.Warn("发现会话已丢失的连接", slog.Int("links", lost))
+	}
+}
@@ -0,0 +1,85 @@
+package link
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+)
+
+// CloseReasonTakenOver takeover 策略下会话被同一用户的新连接接管，关闭码同样为 StatusReplaced
+const CloseReasonTakenOver = "taken over"
+
+// Takeover 会话接管事件，以 JSON 作为 SESSION_TAKEOVER 和 SESSION_TAKEN_OVER 消息的消息体
+//
+// 新连接所在的节点上报 SESSION_TAKEOVER，旧连接所在的节点向旧连接下发并上报 SESSION_TAKEN_OVER，
+// 两个事件可能来自不同的节点，业务后端可以按 connId 和 previousConnId 关联
+type Takeover struct {
+	BizID          int64  `json:"bizId"`
+	UserID         int64  `json:"userId"`
+	ConnID         string `json:"connId"`             // 接管会话的新连接
+	DeviceID       string `json:"deviceId,omitempty"` // 新连接的设备ID
+	PreviousConnID string `json:"previousConnId"`     // 被接管的旧连接
+	// PreviousDeviceID 旧连接的设备ID，只有旧连接所在的节点知道，SESSION_TAKEOVER 中为空
+	PreviousDeviceID string `json:"previousDeviceId,omitempty"`
+	Node             string `json:"node"` // 产生事件的节点
+	Time             int64  `json:"time"` // 事件发生的时间 (Unix 毫秒)
+}
+
+// SetNotifier 设置网关事件的上报器，需要在开始接收连接前调用，未设置时不上报
+func (m *Manager) SetNotifier(n Notifier) {
+	m.notifier = n
+}
+
+// notify 把会话接管事件上报业务后端
+func (m *Manager) notify(info session.UserInfo, cmd gatewayapiv1.Message_CommandType, t Takeover) {
+	if m.notifier == nil {
+		return
+	}
+	m.notifier.Notify(info, takeoverMessage(cmd, t))
+}
+
+// tookOver 新连接建立后上报它接管了旧连接的会话
+func (m *Manager) tookOver(l *Link, previous string) {
+	info := l.Session().UserInfo()
+	m.logger.Info("新连接接管了用户的会话",
+		slog.String("linkId", l.ID()), slog.String("previousConnId", previous),
+		slog.Int64("bizId", info.BizID), slog.Int64("userId", info.UserID))
+	m.notify(info, gatewayapiv1.Message_COMMAND_TYPE_SESSION_TAKEOVER, Takeover{
+		BizID:          info.BizID,
+		UserID:         info.UserID,
+		ConnID:         l.ID(),
+		DeviceID:       info.DeviceID,
+		PreviousConnID: previous,
+		Node:           m.nodeID,
+		Time:           time.Now().UnixMilli(),
+	})
+}
+
+// takenOver 向被接管的旧连接下发接管通知，发送完缓冲区中的消息后以 StatusReplaced 关闭连接，并上报业务后端
+func (m *Manager) takenOver(l *Link, change session.FieldChange) {
+	info := l.Session().UserInfo()
+	t := Takeover{
+		BizID:            info.BizID,
+		UserID:           info.UserID,
+		ConnID:           change.Value,
+		DeviceID:         change.DeviceID,
+		PreviousConnID:   l.ID(),
+		PreviousDeviceID: info.DeviceID,
+		Node:             m.nodeID,
+		Time:             time.Now().UnixMilli(),
+	}
+	if err := l.SendMessage(takeoverMessage(gatewayapiv1.Message_COMMAND_TYPE_SESSION_TAKEN_OVER, t)); err != nil {
+		m.logger.Debug("下发会话接管通知失败", slog.String("linkId", l.ID()), slog.Any("error", err))
+	}
+	l.Drain(CloseInfo{Code: StatusReplaced, Reason: CloseReasonTakenOver})
+	m.notify(info, gatewayapiv1.Message_COMMAND_TYPE_SESSION_TAKEN_OVER, t)
+}
+
+func takeoverMessage(cmd gatewayapiv1.Message_CommandType, t Takeover) *gatewayapiv1.Message {
+	// 只包含基本类型的字段，编码不会失败
+	body, _ := json.Marshal(t)
+	return &gatewayapiv1.Message{Cmd: cmd, Key: t.ConnID, Body: body}
+}
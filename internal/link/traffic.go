@@ -0,0 +1,50 @@
+package link
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// Traffic 连接建立以来收发的消息数和字节数
+// 字节数按连接上实际读写的字节统计，包括帧头、控制帧和压缩后的数据，不包括握手；消息数只统计数据消息
+type Traffic struct {
+	MessagesIn  int64 `json:"messagesIn"`
+	MessagesOut int64 `json:"messagesOut"`
+	BytesIn     int64 `json:"bytesIn"`
+	BytesOut    int64 `json:"bytesOut"`
+}
+
+// traffic 连接的流量计数，读写协程和管理API并发访问
+type traffic struct {
+	messagesIn  atomic.Int64
+	messagesOut atomic.Int64
+	bytesIn     atomic.Int64
+	bytesOut    atomic.Int64
+}
+
+func (t *traffic) snapshot() Traffic {
+	return Traffic{
+		MessagesIn:  t.messagesIn.Load(),
+		MessagesOut: t.messagesOut.Load(),
+		BytesIn:     t.bytesIn.Load(),
+		BytesOut:    t.bytesOut.Load(),
+	}
+}
+
+// countingConn 统计读写字节数的连接，Link 的所有读写都经过它
+type countingConn struct {
+	net.Conn
+	traffic *traffic
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.traffic.bytesIn.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.traffic.bytesOut.Add(int64(n))
+	return n, err
+}
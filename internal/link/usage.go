@@ -0,0 +1,121 @@
+package link
+
+import "sync"
+
+// Usage 业务方在一个统计周期内在本节点上的用量
+// 流量按周期内实际收发的消息和字节统计，跨越多个周期的连接分别计入各个周期
+type Usage struct {
+	BizID           int64 `json:"bizId"`
+	Connections     int64 `json:"connections"`     // 周期内新建的连接数
+	PeakConnections int   `json:"peakConnections"` // 周期内的最大并发连接数
+	Traffic
+}
+
+func (t *Traffic) add(d Traffic) {
+	t.MessagesIn += d.MessagesIn
+	t.MessagesOut += d.MessagesOut
+	t.BytesIn += d.BytesIn
+	t.BytesOut += d.BytesOut
+}
+
+func (t Traffic) sub(d Traffic) Traffic {
+	return Traffic{
+		MessagesIn:  t.MessagesIn - d.MessagesIn,
+		MessagesOut: t.MessagesOut - d.MessagesOut,
+		BytesIn:     t.BytesIn - d.BytesIn,
+		BytesOut:    t.BytesOut - d.BytesOut,
+	}
+}
+
+// usageMeter 按业务方累计当前统计周期的用量，未启用用量报告时为 nil，方法都可以在 nil 接收者上安全调用
+// 每个连接记录上次计入用量时的流量 (Link.reported)，连接关闭或周期结束时只计入此后的增量，读写路径上没有额外开销
+type usageMeter struct {
+	mu  sync.Mutex
+	biz map[int64]*Usage
+}
+
+func newUsageMeter(enabled bool) *usageMeter {
+	if !enabled {
+		return nil
+	}
+	return &usageMeter{biz: make(map[int64]*Usage)}
+}
+
+// usageOf 返回 biz 中业务方的用量，不存在时创建
+func usageOf(biz map[int64]*Usage, bizID int64) *Usage {
+	usage, ok := biz[bizID]
+	if !ok {
+		usage = &Usage{BizID: bizID}
+		biz[bizID] = usage
+	}
+	return usage
+}
+
+// opened 记录新建的连接，current 为业务方包括该连接在内的连接数；在 Manager.mu 中调用
+func (u *usageMeter) opened(bizID int64, current int) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usage := usageOf(u.biz, bizID)
+	usage.Connections++
+	usage.PeakConnections = max(usage.PeakConnections, current)
+}
+
+// closed 把已关闭连接尚未计入的流量计入当前周期
+func (u *usageMeter) closed(l *Link) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.account(u.biz, l)
+}
+
+// account 把连接上次计入以来的流量计入 biz 中的用量，调用方需持有 mu
+func (u *usageMeter) account(biz map[int64]*Usage, l *Link) {
+	current := l.traffic.snapshot()
+	delta := current.sub(l.reported)
+	l.reported = current
+	if delta == (Traffic{}) {
+		return
+	}
+	usageOf(biz, l.Session().UserInfo().BizID).add(delta)
+}
+
+// CollectUsage 结束当前统计周期，返回周期内各业务方在本节点上的用量，没有连接和流量的业务方不出现在结果中
+// 新周期的峰值并发连接数从业务方当前的连接数开始；未启用用量报告时返回 nil
+func (m *Manager) CollectUsage() []Usage {
+	u := m.usage
+	if u == nil {
+		return nil
+	}
+	m.mu.RLock()
+	links := make([]*Link, 0, len(m.links))
+	for _, l := range m.links {
+		links = append(links, l)
+	}
+	u.mu.Lock()
+	ended := u.biz
+	u.biz = make(map[int64]*Usage, len(m.byBiz))
+	for bizID, n := range m.byBiz {
+		u.biz[bizID] = &Usage{BizID: bizID, PeakConnections: n}
+	}
+	u.mu.Unlock()
+	m.mu.RUnlock()
+
+	u.mu.Lock()
+	for _, l := range links {
+		u.account(ended, l)
+	}
+	u.mu.Unlock()
+
+	usages := make([]Usage, 0, len(ended))
+	for _, usage := range ended {
+		if usage.Connections > 0 || usage.PeakConnections > 0 || usage.Traffic != (Traffic{}) {
+			usages = append(usages, *usage)
+		}
+	}
+	return usages
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// BizQuotaSource 产出各业务方在本节点上的连接数和连接数配额，只需要产出配置了配额的业务方
+type BizQuotaSource func(yield func(bizID int64, connections, quota int))
+
+// AdmissionMetrics 新连接准入控制的指标
+//
+//   - 按原因统计的被拒绝连接数
+//   - 按业务方统计的因配额被拒绝的握手数，scope 为 biz（业务方配额）或 user（单个用户的连接数配额）
+//   - 抓取时刻各业务方的连接数和配额，用于观察哪个业务方接近配额
+//
+// biz_id 标签只出现在配置了配额的业务方上，基数与业务方数量相同
+type AdmissionMetrics struct {
+	rejected      *prometheus.CounterVec
+	quotaRejected *prometheus.CounterVec
+	connsDesc     *prometheus.Desc
+	quotaDesc     *prometheus.Desc
+
+	mu     sync.RWMutex
+	source BizQuotaSource
+}
+
+func NewAdmissionMetrics(i do.Injector) (*AdmissionMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &AdmissionMetrics{
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "admission",
+			Name:      "rejected_total",
+			Help:      "未通过准入被拒绝的连接数，按拒绝原因统计",
+		}, []string{"cause"}),
+		quotaRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "admission",
+			Name:      "quota_rejected_total",
+			Help:      "因连接数配额被拒绝的握手数，按业务方和配额范围统计",
+		}, []string{"biz_id", "scope"}),
+		connsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "admission", "biz_connections"),
+			"抓取时刻配置了配额的业务方在本节点上的连接数",
+			[]string{"biz_id"}, nil,
+		),
+		quotaDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "admission", "biz_quota"),
+			"业务方在本节点上的最大连接数",
+			[]string{"biz_id"}, nil,
+		),
+	}
+	reg.MustRegister(m.rejected, m.quotaRejected, m)
+	return m, nil
+}
+
+// Rejected 记录一次因 cause 被拒绝的连接
+func (m *AdmissionMetrics) Rejected(cause string) {
+	m.rejected.WithLabelValues(cause).Inc()
+}
+
+// QuotaRejected 记录一次业务方的握手因配额被拒绝，scope 为 biz 或 user
+func (m *AdmissionMetrics) QuotaRejected(bizID int64, scope string) {
+	m.quotaRejected.WithLabelValues(strconv.FormatInt(bizID, 10), scope).Inc()
+}
+
+// SetSource 设置抓取时查询业务方连接数和配额的数据源，由准入控制器在创建时设置
+func (m *AdmissionMetrics) SetSource(source BizQuotaSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.source = source
+}
+
+// Describe 实现 prometheus.Collector
+func (m *AdmissionMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.connsDesc
+	ch <- m.quotaDesc
+}
+
+// Collect 实现 prometheus.Collector
+func (m *AdmissionMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	source := m.source
+	m.mu.RUnlock()
+	if source == nil {
+		return
+	}
+	source(func(bizID int64, connections, quota int) {
+		biz := strconv.FormatInt(bizID, 10)
+		ch <- prometheus.MustNewConstMetric(m.connsDesc, prometheus.GaugeValue, float64(connections), biz)
+		ch <- prometheus.MustNewConstMetric(m.quotaDesc, prometheus.GaugeValue, float64(quota), biz)
+	})
+}
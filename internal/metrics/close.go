@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// Initiator 连接关闭的发起方
+type Initiator string
+
+const (
+	InitiatorClient Initiator = "client" // 客户端发送关闭帧或断开连接
+	InitiatorServer Initiator = "server" // 网关主动关闭（空闲、踢出、限流、停机等）
+)
+
+const (
+	// closeBucketSize 滚动窗口中每个桶覆盖的时长
+	closeBucketSize = time.Minute
+	// closeBucketCount 滚动窗口的桶数量，窗口总时长 = closeBucketSize * closeBucketCount
+	closeBucketCount = 60
+)
+
+// CloseKey 关闭事件的聚合维度
+// Reason 应当是有限取值的原因分类（如 idle、kick、shutdown），而不是任意的关闭描述文本，以控制标签基数
+type CloseKey struct {
+	Initiator Initiator     `json:"initiator"`
+	Code      ws.StatusCode `json:"code"`
+	Reason    string        `json:"reason"`
+}
+
+// CloseStat 滚动窗口内某个维度组合的关闭次数
+type CloseStat struct {
+	CloseKey
+	Count int64 `json:"count"`
+}
+
+// CloseReport 滚动窗口内的关闭统计报告
+type CloseReport struct {
+	Window time.Duration `json:"window"`
+	Since  time.Time     `json:"since"`
+	Total  int64         `json:"total"`
+	Stats  []CloseStat   `json:"stats"` // 按次数降序排列
+}
+
+type closeBucket struct {
+	start  time.Time
+	counts map[CloseKey]int64
+}
+
+// CloseMetrics 连接关闭码和断开原因统计
+// 一方面以带标签的计数器导出到Prometheus，另一方面在内存中维护一个按分钟滚动的窗口，
+// 用于管理API快速查看最近一段时间的关闭分布（例如发布后 1006 异常关闭是否激增）
+type CloseMetrics struct {
+	closed *prometheus.CounterVec
+
+	mu      sync.Mutex
+	buckets [closeBucketCount]closeBucket
+}
+
+func NewCloseMetrics(i do.Injector) (*CloseMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &CloseMetrics{
+		closed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "connection",
+			Name:      "closed_total",
+			Help:      "按发起方、关闭码和原因分类统计的连接关闭次数",
+		}, []string{"initiator", "code", "reason"}),
+	}
+	reg.MustRegister(m.closed)
+	return m, nil
+}
+
+// Record 记录一次连接关闭
+func (m *CloseMetrics) Record(initiator Initiator, code ws.StatusCode, reason string) {
+	m.closed.WithLabelValues(string(initiator), strconv.Itoa(int(code)), reason).Inc()
+
+	now := time.Now().Truncate(closeBucketSize)
+	key := CloseKey{Initiator: initiator, Code: code, Reason: reason}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := &m.buckets[(now.Unix()/int64(closeBucketSize.Seconds()))%closeBucketCount]
+	if !b.start.Equal(now) {
+		// 桶中是上一轮窗口的旧数据，重置后复用
+		b.start = now
+		b.counts = make(map[CloseKey]int64)
+	}
+	b.counts[key]++
+}
+
+// Report 生成滚动窗口内的关闭统计报告
+func (m *CloseMetrics) Report() CloseReport {
+	window := closeBucketSize * closeBucketCount
+	since := time.Now().Truncate(closeBucketSize).Add(-window + closeBucketSize)
+
+	totals := make(map[CloseKey]int64)
+	m.mu.Lock()
+	for _, b := range m.buckets {
+		if b.start.Before(since) {
+			continue
+		}
+		for k, v := range b.counts {
+			totals[k] += v
+		}
+	}
+	m.mu.Unlock()
+
+	report := CloseReport{Window: window, Since: since, Stats: make([]CloseStat, 0, len(totals))}
+	for k, v := range totals {
+		report.Total += v
+		report.Stats = append(report.Stats, CloseStat{CloseKey: k, Count: v})
+	}
+	slices.SortFunc(report.Stats, func(a, b CloseStat) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return report
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// compressionRatioBuckets 单条压缩消息线路上大小与原始大小之比的直方图桶，大于 1 表示压缩后反而变大
+var compressionRatioBuckets = []float64{.05, .1, .2, .3, .4, .5, .6, .7, .8, .9, 1, 1.1}
+
+// 消息的方向，作为压缩效果指标的 direction 标签
+const (
+	DirectionOutbound = "outbound" // 网关发给客户端的下行消息
+	DirectionInbound  = "inbound"  // 客户端发给网关的上行消息
+)
+
+// CompressionMetrics 消息压缩的CPU预算和压缩效果指标
+//
+// 压缩效果只统计协商了 permessage-deflate 的连接：
+//   - 按方向和是否压缩统计的消息数，用于观察有多少消息因太短、已压缩过或CPU预算耗尽而没有压缩
+//   - 压缩消息的原始字节数和线路上的字节数，两者之比即整体压缩率
+//   - 单条压缩消息的压缩率分布，用于判断窗口大小和压缩级别是否合适
+type CompressionMetrics struct {
+	seconds      prometheus.Counter
+	skipped      prometheus.Counter
+	degraded     prometheus.Gauge
+	degradations prometheus.Counter
+
+	messages  *prometheus.CounterVec
+	rawBytes  *prometheus.CounterVec
+	wireBytes *prometheus.CounterVec
+	ratio     *prometheus.HistogramVec
+}
+
+func NewCompressionMetrics(i do.Injector) (*CompressionMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &CompressionMetrics{
+		seconds: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "compression",
+			Name:      "seconds_total",
+			Help:      "压缩下行消息累计耗费的时间",
+		}),
+		skipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "compression",
+			Name:      "skipped_total",
+			Help:      "压缩预算耗尽时不压缩直接发送的下行消息数",
+		}),
+		degraded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "compression",
+			Name:      "degraded",
+			Help:      "压缩预算是否已耗尽 (1 表示正在发送未压缩的消息)",
+		}),
+		degradations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "compression",
+			Name:      "degradations_total",
+			Help:      "压缩预算耗尽、进入降级状态的次数",
+		}),
+	}
+	m.messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "compression",
+		Name:      "messages_total",
+		Help:      "协商了压缩的连接上收发的数据消息数，按方向和是否压缩统计",
+	}, []string{"direction", "compressed"})
+	m.rawBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "compression",
+		Name:      "raw_bytes_total",
+		Help:      "压缩消息压缩前（解压后）的字节数，按方向统计",
+	}, []string{"direction"})
+	m.wireBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "compression",
+		Name:      "wire_bytes_total",
+		Help:      "压缩消息在线路上的负载字节数，按方向统计",
+	}, []string{"direction"})
+	m.ratio = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "compression",
+		Name:      "ratio",
+		Help:      "单条压缩消息线路上的大小与原始大小之比，按方向统计",
+		Buckets:   compressionRatioBuckets,
+	}, []string{"direction"})
+	reg.MustRegister(m.seconds, m.skipped, m.degraded, m.degradations, m.messages, m.rawBytes, m.wireBytes, m.ratio)
+	return m, nil
+}
+
+// CompressionDirection 一个方向上的压缩效果指标，预先取出各标签的指标，逐条消息记录时不再查找标签
+type CompressionDirection struct {
+	compressed   prometheus.Counter
+	uncompressed prometheus.Counter
+	rawBytes     prometheus.Counter
+	wireBytes    prometheus.Counter
+	ratio        prometheus.Observer
+}
+
+// Direction 返回 direction 方向（DirectionOutbound 或 DirectionInbound）的压缩效果指标
+func (m *CompressionMetrics) Direction(direction string) *CompressionDirection {
+	return &CompressionDirection{
+		compressed:   m.messages.WithLabelValues(direction, "true"),
+		uncompressed: m.messages.WithLabelValues(direction, "false"),
+		rawBytes:     m.rawBytes.WithLabelValues(direction),
+		wireBytes:    m.wireBytes.WithLabelValues(direction),
+		ratio:        m.ratio.WithLabelValues(direction),
+	}
+}
+
+// Observe 记录一条消息，raw 为压缩前（解压后）的大小，wire 为线路上的负载大小
+func (d *CompressionDirection) Observe(raw, wire int, compressed bool) {
+	if !compressed {
+		d.uncompressed.Inc()
+		return
+	}
+	d.compressed.Inc()
+	d.rawBytes.Add(float64(raw))
+	d.wireBytes.Add(float64(wire))
+	if raw > 0 {
+		d.ratio.Observe(float64(wire) / float64(raw))
+	}
+}
+
+// Compressed 记录一次耗时 d 的压缩
+func (m *CompressionMetrics) Compressed(d time.Duration) {
+	m.seconds.Add(d.Seconds())
+}
+
+// Skipped 记录一条因预算耗尽没有压缩的消息
+func (m *CompressionMetrics) Skipped() {
+	m.skipped.Inc()
+}
+
+// SetDegraded 记录进入或退出降级状态
+func (m *CompressionMetrics) SetDegraded(degraded bool) {
+	if degraded {
+		m.degraded.Set(1)
+		m.degradations.Inc()
+		return
+	}
+	m.degraded.Set(0)
+}
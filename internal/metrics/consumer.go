@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// 从 Kafka 消费的下行推送的处理结果，作为指标的 result 标签
+const (
+	ConsumeDelivered    = "delivered"     // 已推送给在线连接、转发给其它节点或保存为离线消息
+	ConsumeDeadLettered = "dead_lettered" // 用户不在线或消息无法解析，已写入死信主题
+	ConsumeDropped      = "dropped"       // 用户不在线或消息无法解析，未配置死信主题而直接丢弃
+	ConsumeExpired      = "expired"       // 超过投递截止时间而放弃
+)
+
+// ConsumerMetrics 从消息队列消费下行推送的指标
+type ConsumerMetrics struct {
+	consumed *prometheus.CounterVec
+	retries  prometheus.Counter
+}
+
+func NewConsumerMetrics(i do.Injector) (*ConsumerMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &ConsumerMetrics{
+		consumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "messages_total",
+			Help:      "从 Kafka 消费的下行推送数，按处理结果统计",
+		}, []string{"result"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "retries_total",
+			Help:      "推送暂停、Redis 不可用等临时错误导致的原地重试次数",
+		}),
+	}
+	reg.MustRegister(m.consumed, m.retries)
+	return m, nil
+}
+
+// Consumed 记录一条处理结果为 result 的消息
+func (m *ConsumerMetrics) Consumed(result string) {
+	m.consumed.WithLabelValues(result).Inc()
+}
+
+// Retried 记录一次原地重试
+func (m *ConsumerMetrics) Retried() {
+	m.retries.Inc()
+}
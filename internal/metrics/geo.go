@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// GeoMetrics 按客户端所在国家统计的连接指标
+// country 标签只取 geoip.metricCountries 中的国家代码、other 和 unknown，基数有界
+type GeoMetrics struct {
+	connections *prometheus.GaugeVec
+	rejected    *prometheus.CounterVec
+}
+
+func NewGeoMetrics(i do.Injector) (*GeoMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &GeoMetrics{
+		connections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "geo",
+			Name:      "connections",
+			Help:      "本节点按客户端所在国家统计的当前连接数",
+		}, []string{"country"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "geo",
+			Name:      "rejected_total",
+			Help:      "因业务方的地区策略被拒绝的握手数",
+		}, []string{"country"}),
+	}
+	reg.MustRegister(m.connections, m.rejected)
+	return m, nil
+}
+
+// Connected 记录来自 country 的连接建立
+func (m *GeoMetrics) Connected(country string) {
+	m.connections.WithLabelValues(country).Inc()
+}
+
+// Disconnected 记录来自 country 的连接关闭
+func (m *GeoMetrics) Disconnected(country string) {
+	m.connections.WithLabelValues(country).Dec()
+}
+
+// Rejected 记录一次来自 country 的握手被地区策略拒绝
+func (m *GeoMetrics) Rejected(country string) {
+	m.rejected.WithLabelValues(country).Inc()
+}
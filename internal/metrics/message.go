@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// PayloadType 上行消息的载荷分类，作为指标的 type 标签
+// 分类数量固定且很少，不会造成标签基数膨胀
+type PayloadType string
+
+const (
+	PayloadHeartbeat PayloadType = "heartbeat" // 心跳消息
+	PayloadAck       PayloadType = "ack"       // 上行/下行确认消息
+	PayloadBusiness  PayloadType = "business"  // 需要转发给业务后端的上行消息
+	PayloadRPC       PayloadType = "rpc"       // 按 rpc.routes 路由到指定业务后端的上行消息，不论消息类型
+	PayloadControl   PayloadType = "control"   // 重定向、限流等控制指令
+	PayloadUnknown   PayloadType = "unknown"   // 无法解析或未定义的消息
+)
+
+// ClassifyCommand 根据消息类型对上行消息进行分类，不区分是否按 rpc.routes 路由
+func ClassifyCommand(cmd gatewayapiv1.Message_CommandType) PayloadType {
+	switch cmd {
+	case gatewayapiv1.Message_COMMAND_TYPE_HEARTBEAT:
+		return PayloadHeartbeat
+	case gatewayapiv1.Message_COMMAND_TYPE_UPSTREAM_ACK, gatewayapiv1.Message_COMMAND_TYPE_DOWNSTREAM_ACK:
+		return PayloadAck
+	case gatewayapiv1.Message_COMMAND_TYPE_UPSTREAM_MESSAGE, gatewayapiv1.Message_COMMAND_TYPE_DOWNSTREAM_MESSAGE:
+		return PayloadBusiness
+	case gatewayapiv1.Message_COMMAND_TYPE_REDIRECT, gatewayapiv1.Message_COMMAND_TYPE_RATE_LIMIT_EXCEEDED,
+		gatewayapiv1.Message_COMMAND_TYPE_SESSION_TAKEOVER, gatewayapiv1.Message_COMMAND_TYPE_SESSION_TAKEN_OVER,
+		gatewayapiv1.Message_COMMAND_TYPE_KEY_EXCHANGE, gatewayapiv1.Message_COMMAND_TYPE_RESUME,
+		gatewayapiv1.Message_COMMAND_TYPE_ROOM_JOIN, gatewayapiv1.Message_COMMAND_TYPE_ROOM_LEAVE, gatewayapiv1.Message_COMMAND_TYPE_ROOM_HISTORY,
+		gatewayapiv1.Message_COMMAND_TYPE_SESSION_FIELD_CHANGED:
+		return PayloadControl
+	default:
+		return PayloadUnknown
+	}
+}
+
+// MessageMetrics 读取路径上的消息指标
+// 计数类指标对每条消息都会记录；处理耗时直方图按采样率记录，以降低热路径开销
+type MessageMetrics struct {
+	received   *prometheus.CounterVec   // 按类型统计的上行消息数
+	bytes      *prometheus.CounterVec   // 按类型统计的上行消息字节数
+	latency    *prometheus.HistogramVec // 按类型统计的消息处理耗时（采样）
+	limited    *prometheus.CounterVec   // 按处理方式统计的被限流的上行消息数
+	sampleRate float64                  // 耗时采样率，取值范围 [0, 1]
+	rpcRoutes  map[rpcRoute]struct{}    // rpc.routes 中配置的路由
+	total      atomic.Uint64            // 上行消息总数，供扩缩容计算消息速率
+}
+
+func NewMessageMetrics(i do.Injector) (*MessageMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := do.Invoke[config.MetricsConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	rpcCfg, err := do.Invoke[config.RPCConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	// 消息类型无效的路由由 upstream.Forwarder 在启动时报错，这里直接忽略
+	rpcRoutes := make(map[rpcRoute]struct{}, len(rpcCfg.Routes))
+	for _, r := range rpcCfg.Routes {
+		if cmd, ok := gatewayapiv1.Message_CommandType_value[r.Cmd]; ok {
+			rpcRoutes[rpcRoute{bizID: r.BizID, cmd: gatewayapiv1.Message_CommandType(cmd)}] = struct{}{}
+		}
+	}
+
+	m := &MessageMetrics{
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "upstream",
+			Name:      "messages_total",
+			Help:      "按载荷类型统计的上行消息数量",
+		}, []string{"type"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "upstream",
+			Name:      "message_bytes_total",
+			Help:      "按载荷类型统计的上行消息字节数（解压后）",
+		}, []string{"type"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "upstream",
+			Name:      "message_handle_seconds",
+			Help:      "按载荷类型统计的上行消息处理耗时（按采样率记录）",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"type"}),
+		limited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "upstream",
+			Name:      "rate_limited_total",
+			Help:      "超过单连接速率限制的上行消息数，action 为 queued（延迟处理）、dropped 或 closed",
+		}, []string{"action"}),
+		sampleRate: min(max(cfg.MessageSampleRate, 0), 1),
+		rpcRoutes:  rpcRoutes,
+	}
+	reg.MustRegister(m.received, m.bytes, m.latency, m.limited)
+	return m, nil
+}
+
+// rpcRoute rpc.routes 中的一条路由，bizID 为 0 表示对所有业务方生效
+type rpcRoute struct {
+	bizID int64
+	cmd   gatewayapiv1.Message_CommandType
+}
+
+// Track 记录业务方 bizID 的一条已解码的上行消息，size 为解码前的字节数，返回其分类以及在处理结束时调用的完成函数
+// msg 为 nil 表示消息无法解码，按 unknown 统计；未被采样的消息返回空操作的完成函数，调用方无需区分
+//
+// 使用方式：
+//
+//	typ, done := m.Track(bizID, msg, len(payload))
+//	defer done()
+func (m *MessageMetrics) Track(bizID int64, msg *gatewayapiv1.Message, size int) (PayloadType, func()) {
+	typ := m.classify(bizID, msg)
+	label := string(typ)
+	m.received.WithLabelValues(label).Inc()
+	m.total.Add(1)
+	m.bytes.WithLabelValues(label).Add(float64(size))
+
+	if m.sampleRate <= 0 || rand.Float64() >= m.sampleRate {
+		return typ, func() {}
+	}
+	start := time.Now()
+	return typ, func() {
+		m.latency.WithLabelValues(label).Observe(time.Since(start).Seconds())
+	}
+}
+
+// classify 按 rpc.routes 路由的消息归为 rpc，其余按消息类型分类；心跳始终由网关直接回复，不会被路由
+func (m *MessageMetrics) classify(bizID int64, msg *gatewayapiv1.Message) PayloadType {
+	if msg == nil {
+		return PayloadUnknown
+	}
+	cmd := msg.GetCmd()
+	if cmd != gatewayapiv1.Message_COMMAND_TYPE_HEARTBEAT {
+		if _, ok := m.rpcRoutes[rpcRoute{bizID: bizID, cmd: cmd}]; ok {
+			return PayloadRPC
+		}
+		if _, ok := m.rpcRoutes[rpcRoute{cmd: cmd}]; ok {
+			return PayloadRPC
+		}
+	}
+	return ClassifyCommand(cmd)
+}
+
+// RateLimited 记录一条超过单连接速率限制的上行消息
+func (m *MessageMetrics) RateLimited(action string) {
+	m.limited.WithLabelValues(action).Inc()
+}
+
+// Received 返回启动以来的上行消息总数
+func (m *MessageMetrics) Received() uint64 {
+	return m.total.Load()
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// PoolMetrics 网关到业务后端的HTTP连接池指标
+// 用于观察连接复用率：复用率低、新建连接多通常意味着连接池过小，高上行消息速率下会耗尽临时端口
+type PoolMetrics struct {
+	open     *prometheus.GaugeVec
+	dials    *prometheus.CounterVec
+	acquired *prometheus.CounterVec
+}
+
+func NewPoolMetrics(i do.Injector) (*PoolMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &PoolMetrics{
+		open: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "backend_pool",
+			Name:      "open_connections",
+			Help:      "到业务后端当前打开的TCP连接数（含空闲连接）",
+		}, []string{"backend"}),
+		dials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "backend_pool",
+			Name:      "dials_total",
+			Help:      "到业务后端的建连次数",
+		}, []string{"backend", "result"}),
+		acquired: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "backend_pool",
+			Name:      "acquired_total",
+			Help:      "请求从连接池获取连接的次数，reused 表示是否复用了已有连接",
+		}, []string{"backend", "reused"}),
+	}
+	reg.MustRegister(m.open, m.dials, m.acquired)
+	return m, nil
+}
+
+// Dialed 记录一次建连
+func (m *PoolMetrics) Dialed(backend string, err error) {
+	if err != nil {
+		m.dials.WithLabelValues(backend, "error").Inc()
+		return
+	}
+	m.dials.WithLabelValues(backend, "success").Inc()
+	m.open.WithLabelValues(backend).Inc()
+}
+
+// Closed 记录一次连接关闭
+func (m *PoolMetrics) Closed(backend string) {
+	m.open.WithLabelValues(backend).Dec()
+}
+
+// Acquired 记录一次从连接池获取连接
+func (m *PoolMetrics) Acquired(backend string, reused bool) {
+	m.acquired.WithLabelValues(backend, strconv.FormatBool(reused)).Inc()
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Metrics 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewRegistry),
+	do.Lazy(NewMessageMetrics),
+	do.Lazy(NewCloseMetrics),
+	do.Lazy(NewReconnectMetrics),
+	do.Lazy(NewPoolMetrics),
+	do.Lazy(NewRPCMetrics),
+	do.Lazy(NewQueueMetrics),
+	do.Lazy(NewUniqueUserMetrics),
+	do.Lazy(NewScalingMetrics),
+	do.Lazy(NewPushMetrics),
+	do.Lazy(NewConsumerMetrics),
+	do.Lazy(NewWebhookMetrics),
+	do.Lazy(NewGeoMetrics),
+	do.Lazy(NewCompressionMetrics),
+	do.Lazy(NewAdmissionMetrics),
+)
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// 推送因超过投递截止时间而被放弃的阶段，作为指标的 stage 标签
+const (
+	StageRouter  = "router"  // 调用方发起推送时已经过期
+	StageRelay   = "relay"   // 其它节点转发来的推送到达时已经过期
+	StageQueue   = "queue"   // 放入连接发送缓冲区前已经过期
+	StageRetry   = "retry"   // 发送缓冲区已满、后台重试期间过期
+	StageWrite   = "write"   // 在连接发送队列中等待期间过期，写协程不再写出
+	StageOffline = "offline" // 离线消息在用户重新连接前过期
+	StageResume  = "resume"  // 会话恢复缓冲区中的推送在补发前过期
+)
+
+// maxExemplarID exemplar 中群发ID的最大长度，exemplar 的标签总长不能超过 128 个字符，超过时不记录 exemplar
+const maxExemplarID = 100
+
+// PushMetrics 下行推送的投递指标
+type PushMetrics struct {
+	expired *prometheus.CounterVec
+	fanout  *prometheus.HistogramVec
+}
+
+func NewPushMetrics(i do.Injector) (*PushMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &PushMetrics{
+		expired: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "push",
+			Name:      "expired_total",
+			Help:      "超过投递截止时间而被放弃的推送数，按放弃时所处的阶段统计，queue 阶段按连接计数",
+		}, []string{"stage"}),
+		fanout: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "push",
+			Name:      "fanout_seconds",
+			Help:      "群发推送在本节点投递完成的耗时（包括排队等待调度的时间），按群发的优先级统计，群发ID记录在 exemplar 中",
+			Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"priority"}),
+	}
+	reg.MustRegister(m.expired, m.fanout)
+	return m, nil
+}
+
+// Expired 记录在 stage 阶段因过期而放弃的 n 个推送
+func (m *PushMetrics) Expired(stage string, n int) {
+	m.expired.WithLabelValues(stage).Add(float64(n))
+}
+
+// FanoutCompleted 记录一个群发在本节点投递完成的耗时
+// 群发ID（推送的 key）没有上限，不作为标签，而是作为 exemplar 记录，可以从耗时异常的桶找到具体的群发
+func (m *PushMetrics) FanoutCompleted(priority, broadcastID string, elapsed time.Duration) {
+	obs := m.fanout.WithLabelValues(priority)
+	if eo, ok := obs.(prometheus.ExemplarObserver); ok && broadcastID != "" && len(broadcastID) <= maxExemplarID && utf8.ValidString(broadcastID) {
+		eo.ObserveWithExemplar(elapsed.Seconds(), prometheus.Labels{"broadcast_id": broadcastID})
+		return
+	}
+	obs.Observe(elapsed.Seconds())
+}
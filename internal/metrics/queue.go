@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// queueAgeBuckets 发送队列等待时长的直方图桶，覆盖从正常的亚毫秒级到慢客户端卡住写超时的范围
+var queueAgeBuckets = []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// 发送队列溢出的处理结果，作为指标的 action 标签
+const (
+	OverflowDroppedNewest = "droppedNewest" // 丢弃了新消息
+	OverflowDroppedOldest = "droppedOldest" // 丢弃了队列中最老的消息
+	OverflowTimeout       = "timeout"       // 等待队列空位超时
+	OverflowClosed        = "closed"        // 关闭了跟不上的连接
+)
+
+// QueueAgeSource 遍历所有连接发送队列中最老消息的等待时长，队列为空的连接不需要产出
+type QueueAgeSource func(yield func(age time.Duration))
+
+// QueueMetrics 连接发送队列的队头延迟指标
+//
+// 队列深度无法反映单条大消息卡住整个队列的情况，因此额外统计：
+//   - 每条消息从入队到写入完成的等待时长（直方图，按消息统计）
+//   - 抓取时刻各连接队列中最老消息的等待时长（直方图，按连接统计，在抓取时现场计算）
+type QueueMetrics struct {
+	wait       prometheus.Histogram
+	overflows  *prometheus.CounterVec
+	oldestDesc *prometheus.Desc
+	written    atomic.Uint64 // 写入完成的下行消息总数，供扩缩容计算消息速率
+
+	mu     sync.RWMutex
+	source QueueAgeSource
+}
+
+func NewQueueMetrics(i do.Injector) (*QueueMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &QueueMetrics{
+		wait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "send_queue",
+			Name:      "wait_seconds",
+			Help:      "下行消息从进入连接发送队列到写入完成的时长",
+			Buckets:   queueAgeBuckets,
+		}),
+		oldestDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "send_queue", "oldest_age_seconds"),
+			"抓取时刻各连接发送队列中最老消息已等待的时长，队列为空的连接不计入",
+			nil, nil,
+		),
+	}
+	m.overflows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "send_queue",
+		Name:      "overflows_total",
+		Help:      "发送队列已满时按溢出策略处理的次数，按处理结果统计",
+	}, []string{"action"})
+	reg.MustRegister(m.wait, m.overflows, m)
+	return m, nil
+}
+
+// Waited 记录一条消息从入队到写入完成的时长
+func (m *QueueMetrics) Waited(d time.Duration) {
+	m.wait.Observe(d.Seconds())
+	m.written.Add(1)
+}
+
+// Overflowed 记录一次发送队列溢出，action 为 Overflow* 常量之一
+func (m *QueueMetrics) Overflowed(action string) {
+	m.overflows.WithLabelValues(action).Inc()
+}
+
+// Written 返回启动以来写入完成的下行消息总数
+func (m *QueueMetrics) Written() uint64 {
+	return m.written.Load()
+}
+
+// SetSource 设置抓取时遍历连接队列的数据源，由连接管理器在创建时设置
+func (m *QueueMetrics) SetSource(source QueueAgeSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.source = source
+}
+
+// Describe 实现 prometheus.Collector
+func (m *QueueMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.oldestDesc
+}
+
+// Collect 实现 prometheus.Collector，在抓取时汇总各连接的队头等待时长
+func (m *QueueMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	source := m.source
+	m.mu.RUnlock()
+
+	var (
+		count   uint64
+		sum     float64
+		buckets = make(map[float64]uint64, len(queueAgeBuckets))
+	)
+	if source != nil {
+		source(func(age time.Duration) {
+			v := age.Seconds()
+			count++
+			sum += v
+			for _, b := range queueAgeBuckets {
+				if v <= b {
+					buckets[b]++
+				}
+			}
+		})
+	}
+	ch <- prometheus.MustNewConstHistogram(m.oldestDesc, count, sum, buckets)
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// pruneEvery 每记录多少次退避建议清理一次过期条目
+const pruneEvery = 1024
+
+type advisedClose struct {
+	advice    backoff.Advice
+	closedAt  time.Time
+	expiresAt time.Time
+}
+
+// ReconnectMetrics 统计客户端对重连退避建议的遵守情况
+//
+// 网关下发退避建议关闭连接时调用 Advised 记录，同一用户再次连上本节点时调用 Reconnected，
+// 以两者的时间差作为重连间隔：小于建议的最小间隔视为未遵守。
+// 只能观察到重连回本节点的客户端，重连到其它节点的由对应节点各自统计。
+type ReconnectMetrics struct {
+	delay     *prometheus.HistogramVec
+	reconnect *prometheus.CounterVec
+
+	mu      sync.Mutex
+	pending map[[2]int64]advisedClose
+	records int
+}
+
+func NewReconnectMetrics(i do.Injector) (*ReconnectMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &ReconnectMetrics{
+		delay: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "reconnect",
+			Name:      "delay_seconds",
+			Help:      "携带退避建议关闭后客户端的重连间隔",
+			Buckets:   []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120, 300},
+		}, []string{"reason"}),
+		reconnect: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "reconnect",
+			Name:      "total",
+			Help:      "携带退避建议关闭后的重连次数，compliant 表示重连间隔是否不小于建议的最小间隔",
+		}, []string{"reason", "compliant"}),
+		pending: make(map[[2]int64]advisedClose),
+	}
+	reg.MustRegister(m.delay, m.reconnect)
+	return m, nil
+}
+
+// Advised 记录一次携带退避建议的关闭
+func (m *ReconnectMetrics) Advised(bizID, userID int64, advice backoff.Advice) {
+	now := time.Now()
+	// 超过建议的最大间隔两倍仍未重连的客户端不再等待
+	wait := max(2*advice.Max, time.Minute)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[[2]int64{bizID, userID}] = advisedClose{advice: advice, closedAt: now, expiresAt: now.Add(wait)}
+	m.records++
+	if m.records%pruneEvery == 0 {
+		for k, v := range m.pending {
+			if now.After(v.expiresAt) {
+				delete(m.pending, k)
+			}
+		}
+	}
+}
+
+// Reconnected 记录一次重连，如果该用户之前收到过退避建议则统计重连间隔
+func (m *ReconnectMetrics) Reconnected(bizID, userID int64) {
+	key := [2]int64{bizID, userID}
+	now := time.Now()
+
+	m.mu.Lock()
+	c, ok := m.pending[key]
+	if ok {
+		delete(m.pending, key)
+	}
+	m.mu.Unlock()
+	if !ok || now.After(c.expiresAt) {
+		return
+	}
+
+	delay := now.Sub(c.closedAt)
+	reason := string(c.advice.Reason)
+	m.delay.WithLabelValues(reason).Observe(delay.Seconds())
+	// 客户端按建议的抖动最多可以提前 Jitter 比例
+	minDelay := time.Duration(float64(c.advice.Min) * (1 - min(max(c.advice.Jitter, 0), 1)))
+	m.reconnect.WithLabelValues(reason, strconv.FormatBool(delay >= minDelay)).Inc()
+}
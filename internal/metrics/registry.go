@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/samber/do/v2"
+)
+
+// namespace 所有网关指标的统一前缀
+const namespace = "wsgateway"
+
+// NewRegistry 创建网关专用的指标注册表，并注册 Go 运行时和进程指标
+// 使用独立的注册表而不是全局默认注册表，避免第三方库的指标污染输出
+func NewRegistry(i do.Injector) (*prometheus.Registry, error) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg, nil
+}
+
+// Handler 返回暴露注册表中所有指标的 HTTP 处理器
+// 抓取方请求 OpenMetrics 格式时同时输出 exemplar（例如群发耗时对应的群发ID）
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg, EnableOpenMetrics: true})
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// RPCMetrics 上行RPC请求的指标
+type RPCMetrics struct {
+	synthesized *prometheus.CounterVec
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	retries     *prometheus.CounterVec
+}
+
+func NewRPCMetrics(i do.Injector) (*RPCMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &RPCMetrics{
+		synthesized: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "timeout_responses_total",
+			Help:      "业务后端超时时网关代为生成的响应次数，policy 为配置的策略，result 为实际下发的响应",
+		}, []string{"policy", "result"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "requests_total",
+			Help:      "转发到业务后端的上行请求数，result 为 ok、timeout 或 error（含重试后的最终结果）",
+		}, []string{"service", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "request_duration_seconds",
+			Help:      "转发到业务后端的上行请求耗时，包含全部重试",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "retries_total",
+			Help:      "转发到业务后端的上行请求重试次数",
+		}, []string{"service"}),
+	}
+	reg.MustRegister(m.synthesized, m.requests, m.duration, m.retries)
+	return m, nil
+}
+
+// Synthesized 记录一次超时响应的生成
+func (m *RPCMetrics) Synthesized(policy, result string) {
+	m.synthesized.WithLabelValues(policy, result).Inc()
+}
+
+// Forwarded 记录一次上行请求转发的最终结果和总耗时
+func (m *RPCMetrics) Forwarded(service, result string, d time.Duration) {
+	m.requests.WithLabelValues(service, result).Inc()
+	m.duration.WithLabelValues(service).Observe(d.Seconds())
+}
+
+// Retried 记录一次上行请求重试
+func (m *RPCMetrics) Retried(service string) {
+	m.retries.WithLabelValues(service).Inc()
+}
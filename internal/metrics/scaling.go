@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// ScalingSample 抓取时刻本节点用于扩缩容的状态
+type ScalingSample struct {
+	Connections         int     // 当前连接数
+	Capacity            int64   // 令牌桶的最大容量，即本节点最多承载的连接数
+	CurrentCapacity     int64   // 令牌桶当前的容量，启动预热期间小于 Capacity
+	UpstreamPerSecond   float64 // 滑动窗口内平均每秒的上行消息数
+	DownstreamPerSecond float64 // 滑动窗口内平均每秒写入完成的下行消息数
+	Ready               bool    // 就绪探针的结果
+}
+
+// ScalingSource 在抓取时产出本节点的扩缩容状态
+type ScalingSource func() ScalingSample
+
+// ScalingMetrics 供 HPA (Prometheus Adapter) 使用的扩缩容指标
+// 消息速率可以由 messages_total 计数器在 PromQL 中计算，这里额外导出网关按滑动窗口算好的速率，
+// 便于不支持 rate 查询的适配器直接使用
+type ScalingMetrics struct {
+	connections *prometheus.Desc
+	capacity    *prometheus.Desc
+	current     *prometheus.Desc
+	utilization *prometheus.Desc
+	rate        *prometheus.Desc
+	ready       *prometheus.Desc
+
+	mu     sync.RWMutex
+	source ScalingSource
+}
+
+func NewScalingMetrics(i do.Injector) (*ScalingMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &ScalingMetrics{
+		connections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "connections"),
+			"本节点当前的连接数",
+			nil, nil,
+		),
+		capacity: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "connection_capacity"),
+			"本节点最多承载的连接数 (令牌桶的最大容量)",
+			nil, nil,
+		),
+		current: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "connection_capacity_current"),
+			"令牌桶当前的容量，启动预热期间逐步增长到 connection_capacity",
+			nil, nil,
+		),
+		utilization: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "connection_utilization"),
+			"连接数占最大容量的比例，取值范围 0-1",
+			nil, nil,
+		),
+		rate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "messages_per_second"),
+			"滑动窗口内平均每秒的消息数，direction 为 upstream 或 downstream",
+			[]string{"direction"}, nil,
+		),
+		ready: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "ready"),
+			"本节点是否就绪 (1 就绪，0 启动中、摘流或 preStop 期间)",
+			nil, nil,
+		),
+	}
+	reg.MustRegister(m)
+	return m, nil
+}
+
+// SetSource 设置抓取时查询扩缩容状态的数据源
+func (m *ScalingMetrics) SetSource(source ScalingSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.source = source
+}
+
+// Describe 实现 prometheus.Collector
+func (m *ScalingMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.connections
+	ch <- m.capacity
+	ch <- m.current
+	ch <- m.utilization
+	ch <- m.rate
+	ch <- m.ready
+}
+
+// Collect 实现 prometheus.Collector
+func (m *ScalingMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	source := m.source
+	m.mu.RUnlock()
+	if source == nil {
+		return
+	}
+	s := source()
+	ready := 0.0
+	if s.Ready {
+		ready = 1
+	}
+	utilization := 0.0
+	if s.Capacity > 0 {
+		utilization = float64(s.Connections) / float64(s.Capacity)
+	}
+	ch <- prometheus.MustNewConstMetric(m.connections, prometheus.GaugeValue, float64(s.Connections))
+	ch <- prometheus.MustNewConstMetric(m.capacity, prometheus.GaugeValue, float64(s.Capacity))
+	ch <- prometheus.MustNewConstMetric(m.current, prometheus.GaugeValue, float64(s.CurrentCapacity))
+	ch <- prometheus.MustNewConstMetric(m.utilization, prometheus.GaugeValue, utilization)
+	ch <- prometheus.MustNewConstMetric(m.rate, prometheus.GaugeValue, s.UpstreamPerSecond, "upstream")
+	ch <- prometheus.MustNewConstMetric(m.rate, prometheus.GaugeValue, s.DownstreamPerSecond, "downstream")
+	ch <- prometheus.MustNewConstMetric(m.ready, prometheus.GaugeValue, ready)
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// UniqueUserSource 产出各业务方当前时间窗口内的去重用户数，period 为窗口类型（hour、day）
+type UniqueUserSource func(yield func(bizID int64, period string, count int64))
+
+// UniqueUserMetrics 各业务方的去重在线用户数
+// 数值来自全集群共享的 HyperLogLog，在抓取时现场查询；各节点导出的是同一个值，聚合时应取 max 而不是 sum
+type UniqueUserMetrics struct {
+	desc *prometheus.Desc
+
+	mu     sync.RWMutex
+	source UniqueUserSource
+}
+
+func NewUniqueUserMetrics(i do.Injector) (*UniqueUserMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &UniqueUserMetrics{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "unique_users"),
+			"当前小时/自然日内建立过连接的去重用户数（近似值），重连不会重复计数",
+			[]string{"biz_id", "period"}, nil,
+		),
+	}
+	reg.MustRegister(m)
+	return m, nil
+}
+
+// SetSource 设置抓取时查询去重用户数的数据源
+func (m *UniqueUserMetrics) SetSource(source UniqueUserSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.source = source
+}
+
+// Describe 实现 prometheus.Collector
+func (m *UniqueUserMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.desc
+}
+
+// Collect 实现 prometheus.Collector
+func (m *UniqueUserMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	source := m.source
+	m.mu.RUnlock()
+	if source == nil {
+		return
+	}
+	source(func(bizID int64, period string, count int64) {
+		ch <- prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, float64(count),
+			strconv.FormatInt(bizID, 10), period)
+	})
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/do/v2"
+)
+
+// 连接生命周期事件webhook的投递结果，作为指标的 result 标签
+const (
+	EventDelivered = "delivered" // 业务方的webhook返回了 2xx
+	EventFailed    = "failed"    // 重试耗尽、webhook拒绝或停机时仍未送达
+	EventDropped   = "dropped"   // 待发送的事件过多而被丢弃
+)
+
+// WebhookMetrics 连接生命周期事件webhook的指标
+type WebhookMetrics struct {
+	events  *prometheus.CounterVec
+	retries prometheus.Counter
+}
+
+func NewWebhookMetrics(i do.Injector) (*WebhookMetrics, error) {
+	reg, err := do.Invoke[*prometheus.Registry](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &WebhookMetrics{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhook",
+			Name:      "events_total",
+			Help:      "发送给业务方webhook的连接生命周期事件数，按事件类型和投递结果统计",
+		}, []string{"type", "result"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhook",
+			Name:      "retries_total",
+			Help:      "连接生命周期事件webhook的重试次数",
+		}),
+	}
+	reg.MustRegister(m.events, m.retries)
+	return m, nil
+}
+
+// Sent 记录一个类型为 typ、投递结果为 result 的事件
+func (m *WebhookMetrics) Sent(typ, result string) {
+	m.events.WithLabelValues(typ, result).Inc()
+}
+
+// Retried 记录一次重试
+func (m *WebhookMetrics) Retried() {
+	m.retries.Inc()
+}
@@ -0,0 +1,172 @@
+// Package offline 保存推送给离线用户的消息，在用户下次建立连接时投递
+//
+// 每个用户的离线消息是一个Redis列表，元素为 8 字节的保存时间（毫秒时间戳，大端序）加上 protobuf 编码的推送。
+// 写入时截断到 maxMessages 条并刷新列表的过期时间；取出时在同一个事务中读取并删除整个列表，
+// 同一用户同时建立的多个连接中只有一个会收到离线消息，超过保留时长的消息在取出时丢弃。
+package offline
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+// keyFormat 用户离线消息列表的存储键格式
+const keyFormat = "gateway:offline:bizId:%d:userId:%d"
+
+// errMalformed 表示列表中的元素无法解析
+var errMalformed = errors.New("离线消息格式错误")
+
+// Store 离线消息存储，未启用时所有方法都不做任何事
+type Store struct {
+	enabled   bool
+	rdb       redis.Cmdable
+	retention time.Duration
+	max       int64
+}
+
+func NewStore(i do.Injector) (*Store, error) {
+	cfg, err := do.Invoke[config.OfflineConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return &Store{}, nil
+	}
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		enabled:   true,
+		rdb:       rdb,
+		retention: time.Duration(cfg.Retention),
+		max:       cfg.MaxMessages,
+	}, nil
+}
+
+// Enabled 返回是否启用了离线消息
+func (s *Store) Enabled() bool {
+	return s.enabled
+}
+
+// Save 把推送追加到接收用户的离线消息列表末尾，超出条数上限时丢弃最老的消息
+func (s *Store) Save(ctx context.Context, msg *gatewayapiv1.PushMessage) error {
+	if !s.enabled {
+		return nil
+	}
+	item, err := encode(msg, time.Now())
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf(keyFormat, msg.GetBizId(), msg.GetReceiverId())
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, item)
+		pipe.LTrim(ctx, key, -s.max, -1)
+		pipe.PExpire(ctx, key, s.retention)
+		return nil
+	})
+	return err
+}
+
+// Take 取出并删除用户的离线消息，按保存顺序返回
+// 超过保留时长的消息被丢弃，折叠键相同的消息只保留最新的一条
+func (s *Store) Take(ctx context.Context, bizID, userID int64) ([]*gatewayapiv1.PushMessage, error) {
+	if !s.enabled {
+		return nil, nil
+	}
+	key := fmt.Sprintf(keyFormat, bizID, userID)
+	var items *redis.StringSliceCmd
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		items = pipe.LRange(ctx, key, 0, -1)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	oldest := time.Now().Add(-s.retention)
+	msgs := make([]*gatewayapiv1.PushMessage, 0, len(items.Val()))
+	for _, item := range items.Val() {
+		msg, savedAt, err := decode(item)
+		if err != nil {
+			return nil, err
+		}
+		if savedAt.Before(oldest) {
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return collapse(msgs), nil
+}
+
+// Restore 把未能投递的消息放回用户的离线消息列表头部，保持原有顺序
+// 放回的消息重新计算保留时长
+func (s *Store) Restore(ctx context.Context, bizID, userID int64, msgs []*gatewayapiv1.PushMessage) error {
+	if !s.enabled || len(msgs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	items := make([]any, 0, len(msgs))
+	// LPUSH 依次插入到头部，倒序插入后保持原有顺序
+	for i := len(msgs) - 1; i >= 0; i-- {
+		item, err := encode(msgs[i], now)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+	key := fmt.Sprintf(keyFormat, bizID, userID)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, items...)
+		pipe.LTrim(ctx, key, -s.max, -1)
+		pipe.PExpire(ctx, key, s.retention)
+		return nil
+	})
+	return err
+}
+
+func encode(msg *gatewayapiv1.PushMessage, savedAt time.Time) ([]byte, error) {
+	b := binary.BigEndian.AppendUint64(make([]byte, 0, 8+proto.Size(msg)), uint64(savedAt.UnixMilli()))
+	return proto.MarshalOptions{}.MarshalAppend(b, msg)
+}
+
+func decode(item string) (*gatewayapiv1.PushMessage, time.Time, error) {
+	if len(item) < 8 {
+		return nil, time.Time{}, errMalformed
+	}
+	savedAt := time.UnixMilli(int64(binary.BigEndian.Uint64([]byte(item[:8]))))
+	msg := &gatewayapiv1.PushMessage{}
+	if err := proto.Unmarshal([]byte(item[8:]), msg); err != nil {
+		return nil, time.Time{}, err
+	}
+	return msg, savedAt, nil
+}
+
+// collapse 只保留折叠键相同的消息中最后保存的一条，位置也取最后一条的位置
+func collapse(msgs []*gatewayapiv1.PushMessage) []*gatewayapiv1.PushMessage {
+	last := make(map[string]int)
+	for i, m := range msgs {
+		if k := m.GetCollapseKey(); k != "" {
+			last[k] = i
+		}
+	}
+	if len(last) == 0 {
+		return msgs
+	}
+	out := msgs[:0]
+	for i, m := range msgs {
+		if k := m.GetCollapseKey(); k == "" || last[k] == i {
+			out = append(out, m)
+		}
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+)
+
+// pruneEvery 每记录多少次慢速连接的发送时间清理一次过期条目
+const pruneEvery = 1024
+
+// degrader 推送的降级阶段，在编码前按目标连接的有效吞吐量调整推送
+//
+// 估计吞吐量 (link.Link.Bandwidth) 低于 slowBandwidth 的连接视为慢速连接，按业务方配置的策略降级：
+//   - dropFields：从JSON消息体中删除这些字段（如图片预览）后再发送，消息体不是JSON对象时原样发送
+//   - minInterval：带折叠键的推送在该间隔内只发送给慢速连接一次，间隔内的更新直接跳过，
+//     适用于比分、行情这类只有最新值有意义、客户端会持续收到后续更新的消息
+//
+// 降级不需要客户端配合，客户端收到的仍是合法的业务消息，只是内容更少或更新更慢
+type degrader struct {
+	slowBandwidth int64
+	policies      map[int64]*degradePolicy
+
+	mu       sync.Mutex
+	lastSent map[throttleKey]time.Time // 慢速连接上每个折叠键最近一次发送的时间
+	records  int
+}
+
+// degradePolicy 一个业务方的降级策略
+type degradePolicy struct {
+	dropFields  [][]string
+	minInterval time.Duration
+}
+
+// throttleKey 按连接和折叠键记录发送时间
+type throttleKey struct {
+	linkID      string
+	collapseKey string
+}
+
+// newDegrader 未启用降级时返回 nil
+func newDegrader(cfg config.DegradeConfig) *degrader {
+	if !cfg.Enabled || len(cfg.Policies) == 0 {
+		return nil
+	}
+	d := &degrader{
+		slowBandwidth: cfg.SlowBandwidth,
+		policies:      make(map[int64]*degradePolicy, len(cfg.Policies)),
+		lastSent:      make(map[throttleKey]time.Time),
+	}
+	for _, p := range cfg.Policies {
+		policy := &degradePolicy{minInterval: time.Duration(p.MinInterval)}
+		for _, f := range p.DropFields {
+			policy.dropFields = append(policy.dropFields, strings.Split(f, "."))
+		}
+		d.policies[p.BizID] = policy
+	}
+	return d
+}
+
+// policy 返回业务方的降级策略，d 为 nil 或业务方没有配置策略时返回 nil
+func (d *degrader) policy(bizID int64) *degradePolicy {
+	if d == nil {
+		return nil
+	}
+	return d.policies[bizID]
+}
+
+// slow 返回连接是否为慢速连接
+func (d *degrader) slow(l *link.Link) bool {
+	bw := l.Bandwidth()
+	return bw > 0 && bw < d.slowBandwidth
+}
+
+// throttle 返回是否跳过发送给慢速连接 l 的这条推送，不跳过时记录发送时间
+func (d *degrader) throttle(p *degradePolicy, l *link.Link, msg *gatewayapiv1.PushMessage) bool {
+	if p.minInterval <= 0 || msg.GetCollapseKey() == "" {
+		return false
+	}
+	key := throttleKey{linkID: l.ID(), collapseKey: msg.GetCollapseKey()}
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.lastSent[key]; ok && now.Sub(last) < p.minInterval {
+		return true
+	}
+	d.lastSent[key] = now
+	d.records++
+	if d.records%pruneEvery == 0 {
+		d.prune(now)
+	}
+	return false
+}
+
+// prune 清理已经不会再限制发送的条目，包括已关闭连接的条目；调用方持有锁
+func (d *degrader) prune(now time.Time) {
+	var longest time.Duration
+	for _, p := range d.policies {
+		longest = max(longest, p.minInterval)
+	}
+	for k, t := range d.lastSent {
+		if now.Sub(t) >= longest {
+			delete(d.lastSent, k)
+		}
+	}
+}
+
+// body 返回降级后的消息体，消息体不是JSON对象或没有需要删除的字段时返回 false
+func (p *degradePolicy) body(body []byte) ([]byte, bool) {
+	if len(p.dropFields) == 0 {
+		return nil, false
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '{' {
+		return nil, false
+	}
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, false
+	}
+	changed := false
+	for _, path := range p.dropFields {
+		if drop(obj, path) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+	// 不转义 HTML 字符，除了删除的字段外消息体的内容保持不变
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(obj); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// drop 删除 obj 中 path 指向的字段，返回字段是否存在
+func drop(obj map[string]any, path []string) bool {
+	for _, name := range path[:len(path)-1] {
+		next, ok := obj[name].(map[string]any)
+		if !ok {
+			return false
+		}
+		obj = next
+	}
+	last := path[len(path)-1]
+	if _, ok := obj[last]; !ok {
+		return false
+	}
+	delete(obj, last)
+	return true
+}
@@ -0,0 +1,179 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/rooms"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/protobuf/proto"
+)
+
+// fanoutChannelSuffix 群发频道的后缀，加在节点频道前缀之后；主机名不会包含 '#'，配置节点ID时也应避免，以免与节点频道冲突
+const fanoutChannelSuffix = "#fanout"
+
+// fanoutEvent 通过群发频道发给所有节点的群发推送
+// Room 不为空时发给房间中的连接，否则发给 UserIDs 中的用户，两者都为空表示发给业务方的所有连接；
+// Origin 为发起群发的节点，该节点已在本地投递，收到自己的事件时忽略
+type fanoutEvent struct {
+	Origin  string  `json:"origin"`
+	UserIDs []int64 `json:"userIds,omitempty"`
+	Room    string  `json:"room,omitempty"`
+	Message []byte  `json:"message"` // protobuf 编码的 PushMessage
+}
+
+// Broadcast 把推送消息发送给业务方 msg.BizId 在所有节点上的所有连接
+func (r *Router) Broadcast(ctx context.Context, msg *gatewayapiv1.PushMessage) (Result, error) {
+	return r.fanout(ctx, msg, fanoutEvent{})
+}
+
+// Multicast 把推送消息发送给业务方 msg.BizId 的一组用户在所有节点上的连接，userIDs 不能为空
+func (r *Router) Multicast(ctx context.Context, msg *gatewayapiv1.PushMessage, userIDs []int64) (Result, error) {
+	if len(userIDs) == 0 {
+		return Result{}, nil
+	}
+	return r.fanout(ctx, msg, fanoutEvent{UserIDs: slices.Compact(slices.Sorted(slices.Values(userIDs)))})
+}
+
+// PushGroup 把推送消息发送给业务方 msg.BizId 的命名分组中的所有成员，分组不存在或没有成员时返回零值的 Result
+func (r *Router) PushGroup(ctx context.Context, msg *gatewayapiv1.PushMessage, group string) (Result, error) {
+	userIDs, err := r.groups.Members(ctx, msg.GetBizId(), group)
+	if err != nil {
+		return Result{}, err
+	}
+	return r.Multicast(ctx, msg, userIDs)
+}
+
+// PushRoom 把推送消息发送给业务方 msg.BizId 的房间中所有节点上的连接，未启用房间时返回 rooms.ErrDisabled
+// 业务方启用了房间存档时，发出的推送同时写入房间的存档，存档失败不影响推送结果
+func (r *Router) PushRoom(ctx context.Context, msg *gatewayapiv1.PushMessage, room string) (Result, error) {
+	if !r.rooms.Enabled() {
+		return Result{}, rooms.ErrDisabled
+	}
+	res, err := r.fanout(ctx, msg, fanoutEvent{Room: room})
+	if err != nil {
+		return res, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, routeTimeout)
+	defer cancel()
+	if err := r.rooms.Archive(ctx, msg, room); err != nil {
+		r.logger.Warn("写入房间消息存档失败",
+			slog.Int64("bizId", msg.GetBizId()),
+			slog.String("room", room),
+			slog.String("key", msg.GetKey()),
+			slog.Any("error", err))
+	}
+	return res, nil
+}
+
+// Groups 返回推送分组的存储
+func (r *Router) Groups() *Groups {
+	return r.groups
+}
+
+// fanout 在本节点投递群发推送，再通过群发频道一次发布给所有节点，由各节点在本地筛选目标连接
+//
+// 群发不查询每个用户所在的节点，也不写入会话恢复缓冲区或离线消息：目标用户不在线时推送直接丢弃，
+// Result 中的连接计数只包含本节点，Relayed 为收到群发的其它节点数；推送已超过投递截止时间时返回 ErrExpired
+func (r *Router) fanout(ctx context.Context, msg *gatewayapiv1.PushMessage, event fanoutEvent) (res Result, err error) {
+	ctx, span := r.tracer.Start(ctx, "push.fanout",
+		tracing.AttrBizID.Int64(msg.GetBizId()),
+		tracing.AttrKey.String(msg.GetKey()),
+		attribute.String("gateway.room", event.Room),
+		attribute.Int("gateway.users", len(event.UserIDs)))
+	defer func() {
+		span.SetAttributes(resultAttributes(res)...)
+		tracing.End(span, err)
+	}()
+	if expired(msg) {
+		r.metrics.Expired(metrics.StageRouter, 1)
+		return Result{}, ErrExpired
+	}
+	_, deliver := r.tracer.Start(ctx, "push.deliver")
+	res, err = r.deliverFanout(msg, event)
+	deliver.SetAttributes(attribute.Int("gateway.links", res.Links))
+	tracing.End(deliver, err)
+	if err != nil || !r.enabled {
+		return res, err
+	}
+
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return res, err
+	}
+	event.Origin, event.Message = r.nodeID, payload
+	data, err := json.Marshal(event)
+	if err != nil {
+		return res, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, routeTimeout)
+	defer cancel()
+	receivers, err := r.rdb.Publish(ctx, r.fanoutChannel(), data).Result()
+	if err != nil {
+		r.logger.Warn("发布群发推送失败", slog.Int64("bizId", msg.GetBizId()), slog.String("key", msg.GetKey()), slog.Any("error", err))
+		if res.Links > 0 {
+			// 本节点已经投递，不因为跨节点发布失败而让整个群发失败
+			return res, nil
+		}
+		return res, err
+	}
+	// 本节点也订阅了群发频道
+	res.Relayed = max(int(receivers)-1, 0)
+	return res, nil
+}
+
+// handleFanout 投递其它节点发布的群发推送
+func (r *Router) handleFanout(payload string) {
+	var event fanoutEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		r.logger.Warn("无法解析其它节点发布的群发推送", slog.Any("error", err))
+		return
+	}
+	if event.Origin == r.nodeID {
+		return
+	}
+	msg := &gatewayapiv1.PushMessage{}
+	if err := proto.Unmarshal(event.Message, msg); err != nil {
+		r.logger.Warn("无法解析其它节点发布的群发推送", slog.Any("error", err))
+		return
+	}
+	if expired(msg) {
+		r.metrics.Expired(metrics.StageRelay, 1)
+		return
+	}
+	res, err := r.deliverFanout(msg, event)
+	if err != nil && !errors.Is(err, ErrPushPaused) {
+		r.logger.Warn("投递其它节点发布的群发推送失败", slog.String("key", msg.GetKey()), slog.Any("error", err))
+		return
+	}
+	if res.Dropped > 0 {
+		r.logger.Debug("群发推送有连接投递失败",
+			slog.String("key", msg.GetKey()),
+			slog.Int("links", res.Links),
+			slog.Int("dropped", res.Dropped))
+	}
+}
+
+// handleFanoutAsync 在单独的协程中投递其它节点发布的群发推送
+func (r *Router) handleFanoutAsync(payload string) {
+	defer r.pusher.incidents.Recover("push.fanout", nil, nil)
+	r.handleFanout(payload)
+}
+
+// deliverFanout 在本节点投递群发推送
+func (r *Router) deliverFanout(msg *gatewayapiv1.PushMessage, event fanoutEvent) (Result, error) {
+	if event.Room != "" {
+		return r.pusher.FanoutLinks(msg, r.rooms.Links(msg.GetBizId(), event.Room))
+	}
+	return r.pusher.Fanout(msg, event.UserIDs)
+}
+
+func (r *Router) fanoutChannel() string {
+	return r.prefix + fanoutChannelSuffix
+}
@@ -0,0 +1,88 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// groupKeyFormat 推送分组成员集合的存储键格式
+const groupKeyFormat = "gateway:push:group:bizId:%d:name:%s"
+
+// maxGroupNameLen 分组名称的最大长度
+const maxGroupNameLen = 128
+
+var ErrInvalidGroupName = errors.New("分组名称不能为空且不能超过128个字符")
+
+// Groups 业务方命名的推送分组
+// 每个分组是 Redis 中的一个用户ID集合，由业务后端通过管理API维护，向分组推送时展开为成员列表群发
+type Groups struct {
+	rdb redis.Cmdable
+}
+
+func NewGroups(i do.Injector) (*Groups, error) {
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Groups{rdb: rdb}, nil
+}
+
+// ValidGroupName 返回分组名称是否合法
+func ValidGroupName(name string) bool {
+	return name != "" && len(name) <= maxGroupNameLen
+}
+
+// Members 返回分组的成员，分组不存在时返回空列表
+func (g *Groups) Members(ctx context.Context, bizID int64, name string) ([]int64, error) {
+	vals, err := g.rdb.SMembers(ctx, groupKey(bizID, name)).Result()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]int64, 0, len(vals))
+	for _, v := range vals {
+		userID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		members = append(members, userID)
+	}
+	return members, nil
+}
+
+// Add 把用户加入分组，返回新加入的用户数
+func (g *Groups) Add(ctx context.Context, bizID int64, name string, userIDs ...int64) (int64, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+	return g.rdb.SAdd(ctx, groupKey(bizID, name), members(userIDs)...).Result()
+}
+
+// Remove 把用户移出分组，返回实际移出的用户数；成员全部移出后分组随之删除
+func (g *Groups) Remove(ctx context.Context, bizID int64, name string, userIDs ...int64) (int64, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+	return g.rdb.SRem(ctx, groupKey(bizID, name), members(userIDs)...).Result()
+}
+
+// Delete 删除整个分组
+func (g *Groups) Delete(ctx context.Context, bizID int64, name string) error {
+	return g.rdb.Del(ctx, groupKey(bizID, name)).Err()
+}
+
+func groupKey(bizID int64, name string) string {
+	return fmt.Sprintf(groupKeyFormat, bizID, name)
+}
+
+func members(userIDs []int64) []any {
+	out := make([]any, len(userIDs))
+	for i, id := range userIDs {
+		out[i] = id
+	}
+	return out
+}
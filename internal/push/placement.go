@@ -0,0 +1,194 @@
+package push
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/hashring"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// membersKey 存活节点的有序集合，分值为节点最近一次上报的毫秒时间戳
+	membersKey = "gateway:cluster:members"
+	// memberStaleAfter 超过多少个刷新周期没有上报的节点移出哈希环
+	memberStaleAfter = 3
+	// placementCandidates 查询首选节点时一并返回的候选节点数，首选节点不可用时负载均衡依次尝试
+	placementCandidates = 3
+)
+
+// Placement 用户到首选节点的一致性哈希放置
+//
+// 每个节点按 refreshInterval 把自己写入 Redis 中的存活节点集合，并以集合中未过期的节点重建哈希环，
+// 所有节点看到相同的节点集合，因此对同一用户算出相同的首选节点。放置只是建议：负载均衡按首选节点路由建连时，
+// 同一用户的连接集中在同一节点上，会话数据的本地缓存命中率更高、跨节点转发的推送更少；不按建议路由也不影响投递。
+type Placement struct {
+	rdb      redis.UniversalClient
+	nodeID   string
+	interval time.Duration
+	ring     *hashring.Ring
+	logger   *log.Logger
+
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newPlacement(rdb redis.UniversalClient, nodeID string, replicas int, interval time.Duration, logger *log.Logger) *Placement {
+	return &Placement{
+		rdb:      rdb,
+		nodeID:   nodeID,
+		interval: interval,
+		ring:     hashring.New(replicas),
+		logger:   logger,
+		done:     make(chan struct{}),
+	}
+}
+
+// start 上报本节点并加载节点集合，之后在后台周期性刷新；首次上报失败时返回错误
+func (p *Placement) start() error {
+	if err := p.refresh(); err != nil {
+		close(p.done)
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go p.run(ctx)
+	return nil
+}
+
+// stop 停止刷新并把本节点移出存活节点集合，其它节点在下一次刷新时重新分配本节点的用户
+func (p *Placement) stop() {
+	p.stopOnce.Do(func() {
+		if p.cancel == nil {
+			return
+		}
+		p.cancel()
+		<-p.done
+		ctx, cancel := context.WithTimeout(context.Background(), routeTimeout)
+		defer cancel()
+		if err := p.rdb.ZRem(ctx, membersKey, p.nodeID).Err(); err != nil {
+			p.logger.Warn("移出存活节点集合失败", slog.Any("error", err))
+		}
+	})
+}
+
+func (p *Placement) run(ctx context.Context) {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 刷新失败时沿用上一次的节点集合
+			if err := p.refresh(); err != nil && ctx.Err() == nil {
+				p.logger.Warn("刷新存活节点集合失败", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// refresh 上报本节点存活、清理过期节点并以存活节点重建哈希环
+func (p *Placement) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), routeTimeout)
+	defer cancel()
+	now := time.Now()
+	stale := now.Add(-memberStaleAfter * p.interval).UnixMilli()
+	var members *redis.StringSliceCmd
+	_, err := p.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, membersKey, redis.Z{Score: float64(now.UnixMilli()), Member: p.nodeID})
+		pipe.ZRemRangeByScore(ctx, membersKey, "-inf", "("+strconv.FormatInt(stale, 10))
+		members = pipe.ZRange(ctx, membersKey, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if change := p.ring.Set(members.Val()...); change.Changed() {
+		p.logger.Info("存活节点集合变化，重新分配首选节点",
+			slog.Any("added", change.Added),
+			slog.Any("removed", change.Removed),
+			slog.Int("nodes", p.ring.Len()),
+			slog.Float64("moved", change.Moved))
+	}
+	return nil
+}
+
+// Preferred 返回用户的首选节点，节点集合为空时返回 false
+func (p *Placement) Preferred(bizID, userID int64) (string, bool) {
+	return p.ring.Get(placementKey(bizID, userID))
+}
+
+// Candidates 按优先级返回用户的前 n 个候选节点
+func (p *Placement) Candidates(bizID, userID int64, n int) []string {
+	return p.ring.GetN(placementKey(bizID, userID), n)
+}
+
+// Nodes 返回当前哈希环中的节点
+func (p *Placement) Nodes() []string {
+	return p.ring.Nodes()
+}
+
+func placementKey(bizID, userID int64) string {
+	return strconv.FormatInt(bizID, 10) + ":" + strconv.FormatInt(userID, 10)
+}
+
+// placementResponse 首选节点查询的响应体
+type placementResponse struct {
+	NodeID     string   `json:"nodeId"`
+	Local      bool     `json:"local"`      // 首选节点是否为响应查询的节点
+	Candidates []string `json:"candidates"` // 按优先级排列的候选节点，第一个即首选节点
+}
+
+// handlePlacement 返回用户的首选节点和候选节点，节点集合为空时返回 503
+// GET {cluster.placement.path}?bizId=&userId=
+func (r *Router) handlePlacement(c fiber.Ctx) error {
+	bizID, err := strconv.ParseInt(c.Query("bizId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bizId 无效"})
+	}
+	userID, err := strconv.ParseInt(c.Query("userId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "userId 无效"})
+	}
+	candidates := r.placement.Candidates(bizID, userID, placementCandidates)
+	if len(candidates) == 0 {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "没有存活的节点"})
+	}
+	return c.JSON(placementResponse{
+		NodeID:     candidates[0],
+		Local:      candidates[0] == r.nodeID,
+		Candidates: candidates,
+	})
+}
+
+// Register 启用首选节点放置时注册首选节点查询接口
+// 与 /ready 一样不需要认证，供负载均衡在转发握手请求前查询
+func (r *Router) Register(app fiber.Router) {
+	if r.placement != nil && r.placementPath != "" {
+		app.Get(r.placementPath, r.handlePlacement)
+	}
+}
+
+// PreferredNode 返回用户的首选节点，未启用首选节点放置或节点集合为空时返回 false
+func (r *Router) PreferredNode(bizID, userID int64) (string, bool) {
+	if r.placement == nil {
+		return "", false
+	}
+	return r.placement.Preferred(bizID, userID)
+}
+
+// placementNodes 返回哈希环中的节点，未启用时为 nil
+func (r *Router) placementNodes() []string {
+	if r.placement == nil {
+		return nil
+	}
+	return r.placement.Nodes()
+}
@@ -0,0 +1,12 @@
+package push
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Push 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewPusher),
+	do.Lazy(NewGroups),
+	do.Lazy(NewRouter),
+)
@@ -0,0 +1,462 @@
+package push
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/subsystem"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+)
+
+var (
+	ErrUserOffline = errors.New("用户不在线")
+	ErrPushPaused  = errors.New("下行推送已暂停")
+	ErrExpired     = errors.New("推送已超过投递截止时间")
+
+	// errRetrying 发送缓冲区已满，推送已转入后台重试
+	errRetrying = errors.New("推送正在后台重试")
+)
+
+// Result 一次下行推送的结果
+type Result struct {
+	Links     int `json:"links"`     // 用户在本节点上的连接数
+	Delivered int `json:"delivered"` // 已放入发送缓冲区的连接数
+	Retrying  int `json:"retrying"`  // 发送缓冲区已满、正在后台重试的连接数
+	Dropped   int `json:"dropped"`   // 连接已关闭或正在关闭而放弃推送的连接数
+	Replaced  int `json:"replaced"`  // 替换了折叠键相同、尚未送达的旧消息的连接数
+	Expired   int `json:"expired"`   // 放入发送缓冲区前已超过投递截止时间而放弃推送的连接数
+	Relayed   int `json:"relayed"`   // 转发到的其它节点数，由 Router 填写，其它节点上的投递结果不回传
+	// Seq 启用会话恢复时为推送分配的序号，由 Router 填写
+	Seq uint64 `json:"seq,omitempty"`
+	// Stored 用户不在线、推送已保存为离线消息，由 Router 填写
+	Stored bool `json:"stored,omitempty"`
+	// Users 群发时在本节点上有连接的目标用户数
+	Users int `json:"users,omitempty"`
+	// Degraded 慢速连接按业务方的降级策略收到了删减后的消息体的连接数，这些连接同时计入 Delivered 等计数
+	Degraded int `json:"degraded,omitempty"`
+	// Throttled 慢速连接在降级策略的最小间隔内已经收到过同一折叠键的推送、跳过发送的连接数
+	Throttled int `json:"throttled,omitempty"`
+	// Failures 本节点上投递失败的连接，最多记录 maxFailures 个
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// fanoutBatchSize 群发时每批写入的连接数
+const fanoutBatchSize = 256
+
+// maxFailures Result 中最多记录的失败连接数，群发给大量连接时避免响应体过大，总数见 Dropped
+const maxFailures = 100
+
+// Failure 一个连接投递失败的原因
+type Failure struct {
+	LinkID string `json:"linkId"`
+	UserID int64  `json:"userId"`
+	Error  string `json:"error"`
+}
+
+// merge 把一批连接的投递结果累加到 r 上
+func (r *Result) merge(o Result) {
+	r.Delivered += o.Delivered
+	r.Retrying += o.Retrying
+	r.Dropped += o.Dropped
+	r.Replaced += o.Replaced
+	r.Expired += o.Expired
+	r.Degraded += o.Degraded
+	r.Throttled += o.Throttled
+	for _, f := range o.Failures {
+		if len(r.Failures) >= maxFailures {
+			break
+		}
+		r.Failures = append(r.Failures, f)
+	}
+}
+
+func (r *Result) fail(l *link.Link, err error) {
+	if len(r.Failures) >= maxFailures {
+		return
+	}
+	r.Failures = append(r.Failures, Failure{LinkID: l.ID(), UserID: l.Session().UserInfo().UserID, Error: err.Error()})
+}
+
+// Pusher 向本节点上的用户连接推送下行消息
+// 发送缓冲区已满时按 PushMessage 配置的间隔和次数在后台重试，不阻塞调用方。
+// 推送转发和群发在同一个协程中依次投递，连接的 block 溢出策略在这里按 dropNewest 处理，
+// 一个慢速连接不会拖慢其它连接的推送；不带折叠键的推送在后台重试时才按 block 策略等待
+// 可以通过管理API暂停推送，暂停期间的推送（包括其它节点转发来的推送）以 ErrPushPaused 拒绝
+//
+// 带折叠键的推送替换同一连接上折叠键相同、仍在发送缓冲区或后台重试中的旧消息，
+// 客户端恢复接收时只会收到最新的一条
+//
+// 推送带有投递截止时间 (PushMessage.Deadline) 时，入队、后台重试和连接写出前都会检查，
+// 过期的推送直接放弃并计入 Result.Expired 或过期指标，不会迟到送达
+//
+// 启用慢速连接降级 (degrade) 时，估计吞吐量低于阈值的连接按业务方的策略收到删减后的消息体或更低频的更新，见 degrader
+//
+// 启用群发分时调度 (fanout) 时，群发按时间片和优先级的预算与其它群发轮流写入，见 fanoutScheduler
+type Pusher struct {
+	links         *link.Manager
+	retryInterval time.Duration
+	maxRetries    int
+	retryMu       sync.Mutex
+	retrying      map[retryKey]*pendingRetry // 后台重试中的可替换消息
+	degrader      *degrader                  // 未启用降级时为 nil
+	scheduler     *fanoutScheduler           // 未启用群发分时调度时为 nil
+	toggle        *subsystem.Toggle
+	rejected      atomic.Int64 // 暂停期间拒绝的推送数
+	incidents     *incident.Recorder
+	metrics       *metrics.PushMetrics
+	logger        *log.Logger
+}
+
+func NewPusher(i do.Injector) (*Pusher, error) {
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := do.Invoke[config.LinkConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := do.Invoke[*incident.Recorder](i)
+	if err != nil {
+		return nil, err
+	}
+	pushMetrics, err := do.Invoke[*metrics.PushMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	degradeCfg, err := do.Invoke[config.DegradeConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	fanoutCfg, err := do.Invoke[config.FanoutConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Pusher{
+		links:         links,
+		retryInterval: time.Duration(cfg.EventHandler.PushMessage.RetryInterval),
+		maxRetries:    cfg.EventHandler.PushMessage.MaxRetries,
+		retrying:      make(map[retryKey]*pendingRetry),
+		degrader:      newDegrader(degradeCfg),
+		scheduler:     newFanoutScheduler(fanoutCfg),
+		toggle:        subsystem.NewToggle(),
+		incidents:     incidents,
+		metrics:       pushMetrics,
+		logger:        logger,
+	}, nil
+}
+
+// Push 把推送消息发送给接收用户在本节点上的所有连接
+// 推送已暂停时返回 ErrPushPaused
+func (p *Pusher) Push(msg *gatewayapiv1.PushMessage) (Result, error) {
+	if p.toggle.Paused() {
+		p.rejected.Add(1)
+		return Result{}, ErrPushPaused
+	}
+	links := p.links.GetByUser(msg.GetBizId(), msg.GetReceiverId())
+	if len(links) == 0 {
+		return Result{}, ErrUserOffline
+	}
+	return p.deliver(links, msg), nil
+}
+
+// Fanout 把推送消息发送给业务方 msg.BizId 在本节点上的多个用户的所有连接，忽略 msg.ReceiverId
+// userIDs 为空时发送给该业务方的所有连接，不能有重复的用户；目标用户都不在本节点时返回零值的 Result。
+// 推送已暂停时返回 ErrPushPaused
+func (p *Pusher) Fanout(msg *gatewayapiv1.PushMessage, userIDs []int64) (Result, error) {
+	if p.toggle.Paused() {
+		p.rejected.Add(1)
+		return Result{}, ErrPushPaused
+	}
+	var links []*link.Link
+	if len(userIDs) == 0 {
+		links = p.links.GetByBiz(msg.GetBizId())
+	} else {
+		for _, userID := range userIDs {
+			links = append(links, p.links.GetByUser(msg.GetBizId(), userID)...)
+		}
+	}
+	return p.fanout(links, msg), nil
+}
+
+// FanoutLinks 把推送消息发送给指定的连接（例如房间中的连接），推送已暂停时返回 ErrPushPaused
+func (p *Pusher) FanoutLinks(msg *gatewayapiv1.PushMessage, links []*link.Link) (Result, error) {
+	if p.toggle.Paused() {
+		p.rejected.Add(1)
+		return Result{}, ErrPushPaused
+	}
+	return p.fanout(links, msg), nil
+}
+
+// fanout 群发给 links 中的连接，links 中不能有重复的连接
+// 所有目标连接共用每种编解码器的一次编码，按 fanoutBatchSize 分批写入发送缓冲区。
+// 启用分时调度时由 fanoutScheduler 安排写入的轮次，否则批与批之间让出CPU，
+// 避免向大量连接群发时长时间占用处理器而拖慢其它连接的读写。完成耗时按群发ID (msg.Key) 记入指标
+func (p *Pusher) fanout(links []*link.Link, msg *gatewayapiv1.PushMessage) Result {
+	start := time.Now()
+	users := make(map[int64]struct{})
+	for _, l := range links {
+		users[l.Session().UserInfo().UserID] = struct{}{}
+	}
+	res := Result{Links: len(links), Users: len(users)}
+	priority := PriorityNormal
+	if p.scheduler != nil {
+		priority = p.scheduler.priority(msg.GetBizId())
+		p.scheduler.run(priority, links, func(batch []*link.Link) {
+			res.merge(p.deliver(batch, msg))
+		})
+	} else {
+		for batch := range slices.Chunk(links, fanoutBatchSize) {
+			if res.Delivered+res.Retrying+res.Dropped+res.Replaced+res.Expired+res.Throttled > 0 {
+				runtime.Gosched()
+			}
+			res.merge(p.deliver(batch, msg))
+		}
+	}
+	if len(links) > 0 {
+		elapsed := time.Since(start)
+		p.metrics.FanoutCompleted(priority, msg.GetKey(), elapsed)
+		p.logger.Debug("群发推送投递完成",
+			slog.Int64("bizId", msg.GetBizId()),
+			slog.String("key", msg.GetKey()),
+			slog.String("priority", priority),
+			slog.Int("links", len(links)),
+			slog.Duration("elapsed", elapsed))
+	}
+	return res
+}
+
+// deliver 把推送发送给 links 中的每个连接并统计结果，失败的连接记入 Result.Failures
+// 目标连接可能协商了不同的编解码器，每种编解码器（以及降级与否）只编码一次；推送已过期时不再发送给任何连接
+func (p *Pusher) deliver(links []*link.Link, msg *gatewayapiv1.PushMessage) Result {
+	if expired(msg) {
+		p.metrics.Expired(metrics.StageQueue, len(links))
+		return Result{Links: len(links), Expired: len(links)}
+	}
+	envelope := &gatewayapiv1.Message{
+		Cmd:  gatewayapiv1.Message_COMMAND_TYPE_DOWNSTREAM_MESSAGE,
+		Key:  msg.GetKey(),
+		Body: msg.GetBody(),
+		Seq:  msg.GetSeq(),
+	}
+	payloads := make(map[payloadKey][]byte, 1)
+	policy := p.degrader.policy(msg.GetBizId())
+	var degraded *gatewayapiv1.Message // 降级后的信封，第一次遇到慢速连接时生成，消息体不需要删减时为 envelope
+
+	res := Result{Links: len(links)}
+	for _, l := range links {
+		env, key := envelope, payloadKey{codec: l.Codec().Name()}
+		if policy != nil && p.degrader.slow(l) {
+			if p.degrader.throttle(policy, l, msg) {
+				res.Throttled++
+				continue
+			}
+			if degraded == nil {
+				degraded = envelope
+				if body, ok := policy.body(msg.GetBody()); ok {
+					degraded = &gatewayapiv1.Message{Cmd: envelope.Cmd, Key: envelope.Key, Body: body, Seq: envelope.Seq}
+				}
+			}
+			if degraded != envelope {
+				env, key.degraded = degraded, true
+			}
+		}
+		codec := l.Codec()
+		payload, ok := payloads[key]
+		if !ok {
+			var err error
+			if payload, err = codec.Marshal(env); err != nil {
+				// 下行消息信封的字段都由网关填写，编码失败意味着编解码器有缺陷
+				p.incidents.Error("push.marshal", fmt.Errorf("编码推送消息失败 (codec=%s, key=%s): %w", codec.Name(), msg.GetKey(), err), l)
+				res.Dropped++
+				res.fail(l, err)
+				continue
+			}
+			payloads[key] = payload
+		}
+		if key.degraded {
+			res.Degraded++
+		}
+		replaced, err := p.send(l, msg, payload)
+		switch {
+		case err == nil && replaced:
+			res.Replaced++
+		case err == nil:
+			res.Delivered++
+		case errors.Is(err, errRetrying):
+			res.Retrying++
+		default:
+			res.Dropped++
+			res.fail(l, err)
+		}
+	}
+	return res
+}
+
+// payloadKey 编码结果按编解码器和是否降级缓存
+type payloadKey struct {
+	codec    string
+	degraded bool
+}
+
+// retryKey 后台重试中的可替换消息按连接和折叠键索引
+type retryKey struct {
+	linkID      string
+	collapseKey string
+}
+
+// pendingRetry 后台重试中的可替换消息的最新内容和投递截止时间，由 retryMu 保护
+type pendingRetry struct {
+	payload  []byte
+	deadline time.Time
+}
+
+// send 把推送放入连接的发送缓冲区，缓冲区已满时转入后台重试并返回 errRetrying
+// replaced 为 true 表示替换了折叠键相同、尚未送达的旧消息
+func (p *Pusher) send(l *link.Link, msg *gatewayapiv1.PushMessage, payload []byte) (replaced bool, err error) {
+	collapseKey := msg.GetCollapseKey()
+	deadline := deadlineOf(msg)
+	if collapseKey == "" {
+		_, err := l.TrySendBefore(payload, "", deadline)
+		if errors.Is(err, link.ErrSendBufferIsFull) && p.maxRetries > 0 {
+			send := func() error {
+				if pastDeadline(deadline) {
+					return ErrExpired
+				}
+				// 重试在连接自己的协程中进行，block 策略下可以等待缓冲区的空位
+				_, err := l.SendBefore(payload, "", deadline)
+				return err
+			}
+			go p.retry(l, msg.GetKey(), send, nil)
+			return false, errRetrying
+		}
+		return false, err
+	}
+
+	// 同一折叠键的消息正在重试时只替换重试的内容，避免旧消息在新消息之后送达
+	rk := retryKey{linkID: l.ID(), collapseKey: collapseKey}
+	p.retryMu.Lock()
+	defer p.retryMu.Unlock()
+	if r, ok := p.retrying[rk]; ok {
+		r.payload, r.deadline = payload, deadline
+		return true, nil
+	}
+	replaced, err = l.TrySendBefore(payload, collapseKey, deadline)
+	if !errors.Is(err, link.ErrSendBufferIsFull) || p.maxRetries <= 0 {
+		return replaced, err
+	}
+	r := &pendingRetry{payload: payload, deadline: deadline}
+	p.retrying[rk] = r
+	send := func() error {
+		p.retryMu.Lock()
+		defer p.retryMu.Unlock()
+		if pastDeadline(r.deadline) {
+			return ErrExpired
+		}
+		// 持有 retryMu 时不能等待，否则会拖慢所有连接的可替换推送
+		_, err := l.TrySendBefore(r.payload, collapseKey, r.deadline)
+		if err == nil {
+			delete(p.retrying, rk)
+		}
+		return err
+	}
+	cleanup := func() {
+		p.retryMu.Lock()
+		defer p.retryMu.Unlock()
+		if p.retrying[rk] == r {
+			delete(p.retrying, rk)
+		}
+	}
+	go p.retry(l, msg.GetKey(), send, cleanup)
+	return false, errRetrying
+}
+
+// Pause 暂停下行推送，已经在后台重试的推送不受影响
+func (p *Pusher) Pause() bool {
+	if !p.toggle.Pause() {
+		return false
+	}
+	p.rejected.Store(0)
+	return true
+}
+
+// Resume 恢复下行推送
+func (p *Pusher) Resume() bool {
+	return p.toggle.Resume()
+}
+
+// Status 返回推送的运行状态，rejected 为最近一次暂停以来拒绝的推送数
+func (p *Pusher) Status() subsystem.Status {
+	return p.toggle.Status("push", map[string]any{"rejected": p.rejected.Load()})
+}
+
+// retry 在后台按固定间隔重试推送，连接关闭、推送过期 (send 返回 ErrExpired) 或达到最大重试次数时放弃
+// cleanup 不为 nil 时在退出前调用
+func (p *Pusher) retry(l *link.Link, key string, send func() error, cleanup func()) {
+	if cleanup != nil {
+		defer cleanup()
+	}
+	defer p.incidents.Recover("push.retry", l, nil)
+	timer := time.NewTimer(p.retryInterval)
+	defer timer.Stop()
+	for attempt := 1; attempt <= p.maxRetries; attempt++ {
+		select {
+		case <-l.HasClose():
+			return
+		case <-timer.C:
+		}
+		err := send()
+		if err == nil {
+			return
+		}
+		if errors.Is(err, ErrExpired) {
+			p.metrics.Expired(metrics.StageRetry, 1)
+			p.logger.Debug("推送在重试期间超过投递截止时间，放弃推送", slog.String("linkId", l.ID()), slog.String("key", key))
+			return
+		}
+		if !errors.Is(err, link.ErrSendBufferIsFull) {
+			break
+		}
+		timer.Reset(p.retryInterval)
+	}
+	info := l.Session().UserInfo()
+	p.logger.Warn("推送消息重试失败，放弃推送",
+		slog.Int64("bizId", info.BizID),
+		slog.Int64("userId", info.UserID),
+		slog.String("linkId", l.ID()),
+		slog.String("key", key))
+}
+
+// deadlineOf 返回推送的投递截止时间，没有截止时间时返回零值
+func deadlineOf(msg *gatewayapiv1.PushMessage) time.Time {
+	if d := msg.GetDeadline(); d > 0 {
+		return time.UnixMilli(d)
+	}
+	return time.Time{}
+}
+
+// pastDeadline 返回是否已超过投递截止时间，零值表示没有截止时间
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+// expired 返回推送是否已超过投递截止时间
+func expired(msg *gatewayapiv1.PushMessage) bool {
+	return pastDeadline(deadlineOf(msg))
+}
@@ -0,0 +1,373 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/offline"
+	"github.com/YaoAzure/wsgateway/internal/resume"
+	"github.com/YaoAzure/wsgateway/internal/rooms"
+	"github.com/YaoAzure/wsgateway/internal/subsystem"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// defaultChannelPrefix 未配置时节点推送频道的前缀
+	defaultChannelPrefix = "gateway:push:node:"
+	// routeTimeout 查询连接所在节点和发布推送的超时时间
+	routeTimeout = 3 * time.Second
+)
+
+// Router 多节点部署时的下行推送路由
+//
+// 推送先投递给本节点上的连接，再按会话中记录的节点（session.Locator）通过 Redis Pub/Sub
+// 转发给持有该用户连接的其它节点；每个节点订阅自己的频道，收到后交给 Pusher 在本地投递。
+// Pub/Sub 不保证送达，其它节点上的投递结果也不会回传，调用方只能得到转发到的节点数。
+// 未启用多节点部署时 Router 只在本节点投递，行为与 Pusher 相同。
+// 可以通过管理API暂停处理其它节点转发来的推送，Pub/Sub 不会保留消息，暂停期间收到的推送直接丢弃。
+// 群发（业务方的所有连接、一组用户、命名分组或房间）不逐个查询用户所在的节点，而是发布到所有节点共同订阅的群发频道，见 fanout。
+// 启用首选节点放置 (cluster.placement) 时还维护存活节点的一致性哈希环，供负载均衡按用户选择建连的节点，见 Placement。
+// 推送带有投递截止时间时，发起推送和收到其它节点转发时都会检查，已过期的推送不再投递或转发。
+type Router struct {
+	pusher  *Pusher
+	resumer *resume.Resumer
+	offline *offline.Store
+	groups  *Groups
+	rooms   *rooms.Rooms
+	enabled bool
+	nodeID  string
+	prefix  string
+	rdb     redis.UniversalClient
+	locator session.Locator
+	metrics *metrics.PushMetrics
+	tracer  *tracing.Tracer
+	logger  *log.Logger
+
+	placement     *Placement // 未启用首选节点放置时为 nil
+	placementPath string
+
+	toggle  *subsystem.Toggle
+	dropped atomic.Int64 // 暂停期间丢弃的转发推送数
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewRouter(i do.Injector) (*Router, error) {
+	pusher, err := do.Invoke[*Pusher](i)
+	if err != nil {
+		return nil, err
+	}
+	resumer, err := do.Invoke[*resume.Resumer](i)
+	if err != nil {
+		return nil, err
+	}
+	store, err := do.Invoke[*offline.Store](i)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := do.Invoke[*Groups](i)
+	if err != nil {
+		return nil, err
+	}
+	roomSet, err := do.Invoke[*rooms.Rooms](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := do.Invoke[config.ClusterConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	pushMetrics, err := do.Invoke[*metrics.PushMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	tracer, err := do.Invoke[*tracing.Tracer](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	r := &Router{
+		pusher:  pusher,
+		resumer: resumer,
+		offline: store,
+		groups:  groups,
+		rooms:   roomSet,
+		enabled: cfg.Enabled,
+		nodeID:  appCfg.InstanceID(),
+		prefix:  cfg.ChannelPrefix,
+		metrics: pushMetrics,
+		tracer:  tracer,
+		logger:  logger,
+		toggle:  subsystem.NewToggle(),
+		done:    make(chan struct{}),
+	}
+	if r.prefix == "" {
+		r.prefix = defaultChannelPrefix
+	}
+	if !r.enabled {
+		return r, nil
+	}
+	if r.rdb, err = do.Invoke[redis.UniversalClient](i); err != nil {
+		return nil, err
+	}
+	if r.locator, err = do.Invoke[session.Locator](i); err != nil {
+		return nil, err
+	}
+	if p := cfg.Placement; p.Enabled {
+		r.placement = newPlacement(r.rdb, r.nodeID, p.Replicas, time.Duration(p.RefreshInterval), logger)
+		r.placementPath = p.Path
+	}
+	return r, nil
+}
+
+// Start 订阅本节点的推送频道和群发频道并在后台处理其它节点转发来的推送，未启用多节点部署时不订阅；重复调用无效
+// 启用首选节点放置时同时加入存活节点集合；订阅确认或首次上报失败时返回错误
+func (r *Router) Start() error {
+	var err error
+	r.startOnce.Do(func() {
+		if !r.enabled {
+			close(r.done)
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		pubsub := r.rdb.Subscribe(ctx, r.channel(r.nodeID), r.fanoutChannel())
+		// 等待两个频道的订阅确认，确保订阅建立失败时能及时返回错误
+		for range 2 {
+			if _, err = pubsub.Receive(ctx); err != nil {
+				cancel()
+				_ = pubsub.Close()
+				close(r.done)
+				return
+			}
+		}
+		if r.placement != nil {
+			if err = r.placement.start(); err != nil {
+				cancel()
+				_ = pubsub.Close()
+				close(r.done)
+				return
+			}
+		}
+		r.cancel = cancel
+		go r.run(ctx, pubsub)
+	})
+	return err
+}
+
+// Shutdown 取消订阅、退出存活节点集合并等待后台协程退出
+func (r *Router) Shutdown() {
+	r.stopOnce.Do(func() {
+		if r.cancel != nil {
+			r.cancel()
+		}
+		if r.placement != nil {
+			r.placement.stop()
+		}
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	r.startOnce.Do(func() { close(r.done) })
+	<-r.done
+}
+
+func (r *Router) run(ctx context.Context, pubsub *redis.PubSub) {
+	defer close(r.done)
+	defer pubsub.Close()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			if r.toggle.Paused() {
+				r.dropped.Add(1)
+				continue
+			}
+			if m.Channel == r.fanoutChannel() {
+				if r.pusher.scheduler != nil {
+					// 群发可能要排队等待调度，不能阻塞后面的单用户推送
+					go r.handleFanoutAsync(m.Payload)
+				} else {
+					r.handleFanout(m.Payload)
+				}
+				continue
+			}
+			msg := &gatewayapiv1.PushMessage{}
+			if err := proto.Unmarshal([]byte(m.Payload), msg); err != nil {
+				r.logger.Warn("无法解析其它节点转发的推送", slog.Any("error", err))
+				continue
+			}
+			if expired(msg) {
+				r.metrics.Expired(metrics.StageRelay, 1)
+				continue
+			}
+			// 转发与用户断开连接之间存在竞争，用户已不在本节点时直接丢弃；推送暂停时 Pusher 自己计数
+			if _, err := r.pusher.Push(msg); err != nil && !errors.Is(err, ErrUserOffline) && !errors.Is(err, ErrPushPaused) {
+				r.logger.Warn("投递其它节点转发的推送失败", slog.String("key", msg.GetKey()), slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Pause 暂停处理其它节点转发来的推送，订阅保持不变
+func (r *Router) Pause() bool {
+	if !r.toggle.Pause() {
+		return false
+	}
+	r.dropped.Store(0)
+	return true
+}
+
+// Resume 恢复处理其它节点转发来的推送
+func (r *Router) Resume() bool {
+	return r.toggle.Resume()
+}
+
+// Status 返回跨节点推送转发的运行状态，dropped 为最近一次暂停以来丢弃的推送数
+func (r *Router) Status() subsystem.Status {
+	return r.toggle.Status("relay", map[string]any{
+		"enabled":        r.enabled,
+		"dropped":        r.dropped.Load(),
+		"placementNodes": r.placementNodes(),
+	})
+}
+
+// Push 把推送消息投递给接收用户在所有节点上的连接
+// 用户在本节点和其它节点上都没有连接时返回 ErrUserOffline，启用离线消息时改为保存推送并在 Result.Stored 中标记；
+// 启用会话恢复时推送先写入用户的缓冲区，即使用户暂时离线，在有效期内重连的客户端也会收到补发；
+// 推送已超过投递截止时间时返回 ErrExpired，不写入缓冲区和离线消息
+// 整个推送是一个 span，ctx 中带有调用方的链路上下文时接在调用方的链路之后
+func (r *Router) Push(ctx context.Context, msg *gatewayapiv1.PushMessage) (res Result, err error) {
+	ctx, span := r.tracer.Start(ctx, "push.route",
+		tracing.AttrBizID.Int64(msg.GetBizId()),
+		tracing.AttrUserID.Int64(msg.GetReceiverId()),
+		tracing.AttrKey.String(msg.GetKey()))
+	defer func() {
+		span.SetAttributes(resultAttributes(res)...)
+		if errors.Is(err, ErrUserOffline) {
+			// 用户不在线是正常的投递结果，不标记为失败
+			span.SetAttributes(tracing.AttrResult.String("offline"))
+			span.End()
+			return
+		}
+		tracing.End(span, err)
+	}()
+	if expired(msg) {
+		r.metrics.Expired(metrics.StageRouter, 1)
+		return Result{}, ErrExpired
+	}
+	if err := r.resumer.Stamp(ctx, msg); err != nil {
+		// 缓冲区不可用时照常投递，只是这条推送无法补发
+		r.logger.Warn("写入会话恢复缓冲区失败",
+			slog.Int64("bizId", msg.GetBizId()),
+			slog.Int64("userId", msg.GetReceiverId()),
+			slog.String("key", msg.GetKey()),
+			slog.Any("error", err))
+	}
+	res, err = r.route(ctx, msg)
+	res.Seq = msg.GetSeq()
+	if !errors.Is(err, ErrUserOffline) || !r.offline.Enabled() {
+		return res, err
+	}
+	if err := r.offline.Save(ctx, msg); err != nil {
+		return res, err
+	}
+	res.Stored = true
+	return res, nil
+}
+
+// route 在本节点投递推送并转发给持有接收用户连接的其它节点
+func (r *Router) route(ctx context.Context, msg *gatewayapiv1.PushMessage) (Result, error) {
+	_, span := r.tracer.Start(ctx, "push.deliver")
+	res, err := r.pusher.Push(msg)
+	span.SetAttributes(attribute.Int("gateway.links", res.Links))
+	span.End()
+	if err != nil && !errors.Is(err, ErrUserOffline) {
+		return res, err
+	}
+	if !r.enabled {
+		return res, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, routeTimeout)
+	defer cancel()
+	nodes, lookupErr := r.locator.Nodes(ctx, msg.GetBizId(), msg.GetReceiverId())
+	if lookupErr != nil {
+		r.logger.Warn("查询用户连接所在节点失败",
+			slog.Int64("bizId", msg.GetBizId()),
+			slog.Int64("userId", msg.GetReceiverId()),
+			slog.Any("error", lookupErr))
+		if res.Links > 0 {
+			// 本节点已经投递，不因为跨节点路由失败而让整个推送失败
+			return res, nil
+		}
+		return res, lookupErr
+	}
+
+	var payload []byte
+	for _, nodeID := range nodes {
+		if nodeID == r.nodeID {
+			continue
+		}
+		if payload == nil {
+			if payload, err = proto.Marshal(msg); err != nil {
+				return res, err
+			}
+		}
+		receivers, err := r.rdb.Publish(ctx, r.channel(nodeID), payload).Result()
+		if err != nil {
+			r.logger.Warn("转发推送到其它节点失败", slog.String("node", nodeID), slog.String("key", msg.GetKey()), slog.Any("error", err))
+			continue
+		}
+		if receivers == 0 {
+			// 节点已下线但会话中的记录还没有清除
+			r.logger.Debug("推送的目标节点未订阅", slog.String("node", nodeID), slog.String("key", msg.GetKey()))
+			continue
+		}
+		res.Relayed++
+	}
+	if res.Links == 0 && res.Relayed == 0 {
+		return res, ErrUserOffline
+	}
+	return res, nil
+}
+
+// resultAttributes 推送 span 的投递结果属性
+func resultAttributes(res Result) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("gateway.links", res.Links),
+		attribute.Int("gateway.delivered", res.Delivered),
+		attribute.Int("gateway.dropped", res.Dropped),
+		attribute.Int("gateway.relayed", res.Relayed),
+		attribute.Bool("gateway.stored", res.Stored),
+	}
+}
+
+func (r *Router) channel(nodeID string) string {
+	return r.prefix + nodeID
+}
@@ -0,0 +1,124 @@
+package push
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+)
+
+// 群发的优先级，决定群发每轮最多写入的连接数
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// fanoutScheduler 群发推送的分时调度器
+//
+// 群发在调用方的协程中分批写入连接的发送缓冲区，几个同时进行的大群发会占满处理器，拖慢单用户推送和其它连接的读写。
+// 调度器把群发切成轮次交替执行：
+//   - 同时写入的群发不超过 workers 个，其余群发按到达顺序排队
+//   - 群发每轮最多连续写入 slice 时间，且不超过其优先级的预算（连接数），用完后让出处理器并排到队尾
+//   - 单用户推送不经过调度器，在群发的两轮之间得到执行
+//
+// 高优先级的群发每轮写入更多连接而较快完成，低优先级的群发每轮仍然能写入，不会被饿死
+type fanoutScheduler struct {
+	slice      time.Duration
+	workers    int
+	budgets    map[string]int
+	priorities map[int64]string // 按业务方配置的优先级，未配置的业务方为 PriorityNormal
+
+	mu      sync.Mutex
+	running int             // 正在写入的群发数
+	waiting []chan struct{} // 排队等待写入的群发，按到达顺序
+}
+
+// newFanoutScheduler 未启用分时调度时返回 nil
+func newFanoutScheduler(cfg config.FanoutConfig) *fanoutScheduler {
+	if !cfg.Enabled {
+		return nil
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = max(runtime.GOMAXPROCS(0)/2, 1)
+	}
+	s := &fanoutScheduler{
+		slice:   time.Duration(cfg.Slice),
+		workers: workers,
+		budgets: map[string]int{
+			PriorityHigh:   cfg.Budgets.High,
+			PriorityNormal: cfg.Budgets.Normal,
+			PriorityLow:    cfg.Budgets.Low,
+		},
+		priorities: make(map[int64]string, len(cfg.BizPriorities)),
+	}
+	for _, p := range cfg.BizPriorities {
+		s.priorities[p.BizID] = p.Priority
+	}
+	return s
+}
+
+// priority 返回业务方群发的优先级
+func (s *fanoutScheduler) priority(bizID int64) string {
+	if p, ok := s.priorities[bizID]; ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+// run 按轮次把 links 分批交给 deliver 写入，全部写入后返回
+// 每批最多 fanoutBatchSize 个连接，一轮写满时间片或用完预算后结束
+func (s *fanoutScheduler) run(priority string, links []*link.Link, deliver func([]*link.Link)) {
+	budget := s.budgets[priority]
+	for len(links) > 0 {
+		links = s.turn(links, budget, deliver)
+		if len(links) > 0 {
+			// 排到队尾之前让出处理器，让单用户推送和连接的读写协程先执行
+			runtime.Gosched()
+		}
+	}
+}
+
+// turn 执行一轮写入，返回剩余未写入的连接
+func (s *fanoutScheduler) turn(links []*link.Link, budget int, deliver func([]*link.Link)) []*link.Link {
+	s.acquire()
+	defer s.release()
+	end := time.Now().Add(s.slice)
+	for written := 0; len(links) > 0 && written < budget && time.Now().Before(end); {
+		n := min(fanoutBatchSize, budget-written, len(links))
+		deliver(links[:n])
+		links, written = links[n:], written+n
+	}
+	return links
+}
+
+// acquire 等待轮到当前群发写入
+func (s *fanoutScheduler) acquire() {
+	s.mu.Lock()
+	if s.running < s.workers && len(s.waiting) == 0 {
+		s.running++
+		s.mu.Unlock()
+		return
+	}
+	ready := make(chan struct{})
+	s.waiting = append(s.waiting, ready)
+	s.mu.Unlock()
+	<-ready
+}
+
+// release 结束一轮写入，有群发在排队时把写入的名额直接交给队首的群发
+func (s *fanoutScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiting) == 0 {
+		s.running--
+		return
+	}
+	next := s.waiting[0]
+	s.waiting[0] = nil
+	s.waiting = s.waiting[1:]
+	close(next)
+}
@@ -0,0 +1,142 @@
+package resume
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"google.golang.org/protobuf/proto"
+)
+
+// userKey 按业务方和用户索引缓冲区
+type userKey struct {
+	bizID  int64
+	userID int64
+}
+
+// ring 一个用户的推送环形缓冲区
+type ring struct {
+	last    uint64
+	msgs    []*gatewayapiv1.PushMessage // 按序号排列，长度不超过 size
+	expires time.Time
+}
+
+type savedPosition struct {
+	pos     Position
+	expires time.Time
+}
+
+// memoryStore 基于本节点内存的 Store 实现，只适用于单节点部署
+// 过期的缓冲区和令牌在写入时按有效期周期性地清理
+type memoryStore struct {
+	size int
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	rings     map[userKey]*ring
+	positions map[string]savedPosition
+	swept     time.Time
+}
+
+func newMemoryStore(size int64, ttl time.Duration) *memoryStore {
+	return &memoryStore{
+		size:      int(size),
+		ttl:       ttl,
+		rings:     make(map[userKey]*ring),
+		positions: make(map[string]savedPosition),
+		swept:     time.Now(),
+	}
+}
+
+func (s *memoryStore) Append(_ context.Context, msg *gatewayapiv1.PushMessage) (uint64, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	k := userKey{bizID: msg.GetBizId(), userID: msg.GetReceiverId()}
+	r := s.ring(k, now)
+	r.last++
+	msg.Seq = r.last
+	// 缓冲区中的推送在补发时会被多个连接读取，保存副本以免调用方之后修改
+	r.msgs = append(r.msgs, proto.CloneOf(msg))
+	if len(r.msgs) > s.size {
+		r.msgs = append(r.msgs[:0], r.msgs[len(r.msgs)-s.size:]...)
+	}
+	r.expires = now.Add(s.ttl)
+	return r.last, nil
+}
+
+func (s *memoryStore) Since(_ context.Context, bizID, userID int64, after uint64) ([]*gatewayapiv1.PushMessage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.ring(userKey{bizID: bizID, userID: userID}, time.Now())
+	var oldest uint64
+	if len(r.msgs) > 0 {
+		oldest = r.msgs[0].GetSeq()
+	}
+	var msgs []*gatewayapiv1.PushMessage
+	for _, m := range r.msgs {
+		if m.GetSeq() > after {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs, complete(after, r.last, oldest), nil
+}
+
+func (s *memoryStore) Last(_ context.Context, bizID, userID int64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ring(userKey{bizID: bizID, userID: userID}, time.Now()).last, nil
+}
+
+func (s *memoryStore) SavePosition(_ context.Context, token string, pos Position) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	s.positions[token] = savedPosition{pos: pos, expires: now.Add(s.ttl)}
+	return nil
+}
+
+func (s *memoryStore) TakePosition(_ context.Context, token string) (Position, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved, ok := s.positions[token]
+	if !ok {
+		return Position{}, false, nil
+	}
+	delete(s.positions, token)
+	if time.Now().After(saved.expires) {
+		return Position{}, false, nil
+	}
+	return saved.pos, true, nil
+}
+
+// ring 返回用户的缓冲区，已过期的缓冲区与 Redis 键过期一样从序号 0 重新开始；调用方持有 mu
+func (s *memoryStore) ring(k userKey, now time.Time) *ring {
+	r, ok := s.rings[k]
+	if !ok || now.After(r.expires) {
+		r = &ring{expires: now.Add(s.ttl)}
+		s.rings[k] = r
+	}
+	return r
+}
+
+// sweep 每隔一个有效期清理一次过期的缓冲区和令牌；调用方持有 mu
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < s.ttl {
+		return
+	}
+	s.swept = now
+	for k, r := range s.rings {
+		if now.After(r.expires) {
+			delete(s.rings, k)
+		}
+	}
+	for token, saved := range s.positions {
+		if now.After(saved.expires) {
+			delete(s.positions, token)
+		}
+	}
+}
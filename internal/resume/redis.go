@@ -0,0 +1,128 @@
+package resume
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// seqKeyFormat 用户推送序号计数的存储键格式
+	seqKeyFormat = "gateway:resume:seq:bizId:%d:userId:%d"
+	// bufferKeyFormat 用户推送缓冲区的存储键格式，有序集合的分值为序号
+	bufferKeyFormat = "gateway:resume:buffer:bizId:%d:userId:%d"
+	// tokenKeyFormat 恢复令牌的存储键格式，值为 "bizId:userId:acked"
+	tokenKeyFormat = "gateway:resume:token:%s"
+)
+
+// redisStore 基于Redis的 Store 实现，多节点部署时客户端重连到任意节点都能恢复
+// 序号由 INCR 分配，缓冲区是以序号为分值的有序集合，每次写入时截断到固定长度并刷新过期时间
+type redisStore struct {
+	rdb  redis.Cmdable
+	size int64
+	ttl  time.Duration
+}
+
+func newRedisStore(rdb redis.Cmdable, size int64, ttl time.Duration) *redisStore {
+	return &redisStore{rdb: rdb, size: size, ttl: ttl}
+}
+
+func (s *redisStore) Append(ctx context.Context, msg *gatewayapiv1.PushMessage) (uint64, error) {
+	seqKey := fmt.Sprintf(seqKeyFormat, msg.GetBizId(), msg.GetReceiverId())
+	bufferKey := fmt.Sprintf(bufferKeyFormat, msg.GetBizId(), msg.GetReceiverId())
+	seq, err := s.rdb.Incr(ctx, seqKey).Uint64()
+	if err != nil {
+		return 0, err
+	}
+	msg.Seq = seq
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, bufferKey, redis.Z{Score: float64(seq), Member: payload})
+		pipe.ZRemRangeByRank(ctx, bufferKey, 0, -s.size-1)
+		pipe.PExpire(ctx, bufferKey, s.ttl)
+		pipe.PExpire(ctx, seqKey, s.ttl)
+		return nil
+	})
+	return seq, err
+}
+
+func (s *redisStore) Since(ctx context.Context, bizID, userID int64, after uint64) ([]*gatewayapiv1.PushMessage, bool, error) {
+	var lastCmd *redis.StringCmd
+	var bufferCmd *redis.StringSliceCmd
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		lastCmd = pipe.Get(ctx, fmt.Sprintf(seqKeyFormat, bizID, userID))
+		bufferCmd = pipe.ZRange(ctx, fmt.Sprintf(bufferKeyFormat, bizID, userID), 0, -1)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, err
+	}
+	last, err := lastCmd.Uint64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, false, err
+	}
+	var oldest uint64
+	var msgs []*gatewayapiv1.PushMessage
+	for _, member := range bufferCmd.Val() {
+		msg := &gatewayapiv1.PushMessage{}
+		if err := proto.Unmarshal([]byte(member), msg); err != nil {
+			return nil, false, err
+		}
+		if oldest == 0 {
+			oldest = msg.GetSeq()
+		}
+		if msg.GetSeq() > after {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, complete(after, last, oldest), nil
+}
+
+func (s *redisStore) Last(ctx context.Context, bizID, userID int64) (uint64, error) {
+	last, err := s.rdb.Get(ctx, fmt.Sprintf(seqKeyFormat, bizID, userID)).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return last, err
+}
+
+func (s *redisStore) SavePosition(ctx context.Context, token string, pos Position) error {
+	value := fmt.Sprintf("%d:%d:%d", pos.BizID, pos.UserID, pos.Acked)
+	return s.rdb.Set(ctx, fmt.Sprintf(tokenKeyFormat, token), value, s.ttl).Err()
+}
+
+func (s *redisStore) TakePosition(ctx context.Context, token string) (Position, bool, error) {
+	value, err := s.rdb.GetDel(ctx, fmt.Sprintf(tokenKeyFormat, token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return Position{}, false, nil
+	}
+	if err != nil {
+		return Position{}, false, err
+	}
+	pos, ok := parsePosition(value)
+	return pos, ok, nil
+}
+
+func parsePosition(value string) (Position, bool) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return Position{}, false
+	}
+	bizID, err1 := strconv.ParseInt(parts[0], 10, 64)
+	userID, err2 := strconv.ParseInt(parts[1], 10, 64)
+	acked, err3 := strconv.ParseUint(parts[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return Position{}, false
+	}
+	return Position{BizID: bizID, UserID: userID, Acked: acked}, true
+}
@@ -0,0 +1,182 @@
+// Package resume 实现断线重连后的会话恢复
+//
+// 启用后网关为每个用户的下行推送分配递增的序号并缓存最近的推送（Redis 或本节点内存的环形缓冲区）。
+// 连接建立时网关下发 RESUME 消息携带恢复令牌，客户端以带 seq 的 DOWNSTREAM_ACK 确认收到的推送；
+// 连接关闭时网关把令牌和已确认的序号一起保存，客户端重连时以 ?resume=<令牌> 携带令牌，
+// 网关补发缓冲区中序号更大的推送。补发与实时推送可能重复，客户端按 seq 去重；
+// 收到 lost 时说明有推送已无法补发，客户端应全量同步并重置已收到的最大序号。
+package resume
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+const (
+	StoreRedis  = "redis"
+	StoreMemory = "memory"
+
+	// maxTokenLength 恢复令牌的最大长度，令牌会作为Redis键的一部分，超长的令牌视为无效
+	maxTokenLength = 64
+)
+
+// ErrUnknownStore 表示配置了不支持的缓冲区存储
+var ErrUnknownStore = errors.New("未知的会话恢复存储")
+
+// Position 恢复令牌记录的补发位置
+type Position struct {
+	BizID  int64
+	UserID int64
+	Acked  uint64 // 客户端已确认的最大序号
+}
+
+// Store 推送缓冲区和恢复令牌的存储
+type Store interface {
+	// Append 为推送分配用户内递增的序号（写入 msg.Seq）并追加到用户的缓冲区，缓冲区满时丢弃最老的推送
+	Append(ctx context.Context, msg *gatewayapiv1.PushMessage) (uint64, error)
+	// Since 按序号顺序返回缓冲区中序号大于 after 的推送
+	// complete 为 false 表示序号大于 after 的推送有一部分已不在缓冲区中
+	Since(ctx context.Context, bizID, userID int64, after uint64) (msgs []*gatewayapiv1.PushMessage, complete bool, err error)
+	// Last 返回最近一次分配给用户的序号，没有缓存的推送时为 0
+	Last(ctx context.Context, bizID, userID int64) (uint64, error)
+	// SavePosition 保存恢复令牌的补发位置
+	SavePosition(ctx context.Context, token string, pos Position) error
+	// TakePosition 取出并删除恢复令牌的补发位置，令牌只能使用一次
+	TakePosition(ctx context.Context, token string) (Position, bool, error)
+}
+
+// Resumption 一次连接建立时的恢复结果
+type Resumption struct {
+	Token  string                      // 新连接的恢复令牌
+	After  uint64                      // 补发的起点，即客户端已确认的最大序号
+	Replay []*gatewayapiv1.PushMessage // 需要补发的推送，按序号排列
+	Lost   bool                        // 有推送已无法补发，或客户端携带的令牌已失效
+}
+
+// Resumer 会话恢复，未启用时所有方法都不做任何事
+type Resumer struct {
+	enabled bool
+	store   Store
+}
+
+func NewResumer(i do.Injector) (*Resumer, error) {
+	cfg, err := do.Invoke[config.ResumeConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return &Resumer{}, nil
+	}
+	ttl := time.Duration(cfg.TTL)
+	var store Store
+	switch cfg.Store {
+	case StoreRedis:
+		rdb, err := do.Invoke[redis.Cmdable](i)
+		if err != nil {
+			return nil, err
+		}
+		store = newRedisStore(rdb, cfg.BufferSize, ttl)
+	case StoreMemory:
+		store = newMemoryStore(cfg.BufferSize, ttl)
+	default:
+		return nil, ErrUnknownStore
+	}
+	return &Resumer{enabled: true, store: store}, nil
+}
+
+// Enabled 返回是否启用了会话恢复
+func (r *Resumer) Enabled() bool {
+	return r.enabled
+}
+
+// Stamp 为推送分配序号并写入缓冲区，已经分配过序号（其它节点转发来的）的推送不再分配
+func (r *Resumer) Stamp(ctx context.Context, msg *gatewayapiv1.PushMessage) error {
+	if !r.enabled || msg.GetSeq() != 0 {
+		return nil
+	}
+	_, err := r.store.Append(ctx, msg)
+	return err
+}
+
+// Resume 在连接建立时调用，token 为客户端携带的恢复令牌，首次连接时为空
+// 令牌有效时返回缓冲区中未确认的推送，无论是否恢复成功都会为新连接签发新的令牌
+func (r *Resumer) Resume(ctx context.Context, bizID, userID int64, token string) (Resumption, error) {
+	var res Resumption
+	pos, ok, err := r.take(ctx, token)
+	if err != nil {
+		return res, err
+	}
+	if ok && pos.BizID == bizID && pos.UserID == userID {
+		msgs, whole, err := r.store.Since(ctx, bizID, userID, pos.Acked)
+		if err != nil {
+			return res, err
+		}
+		res.After, res.Replay, res.Lost = pos.Acked, collapse(msgs), !whole
+	} else {
+		// 首次连接只接收之后的推送；令牌失效时断线期间的推送无从得知，客户端需要全量同步
+		if res.After, err = r.store.Last(ctx, bizID, userID); err != nil {
+			return res, err
+		}
+		res.Lost = token != ""
+	}
+	res.Token = uuid.NewString()
+	if err := r.store.SavePosition(ctx, res.Token, Position{BizID: bizID, UserID: userID, Acked: res.After}); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func (r *Resumer) take(ctx context.Context, token string) (Position, bool, error) {
+	if token == "" || len(token) > maxTokenLength {
+		return Position{}, false, nil
+	}
+	return r.store.TakePosition(ctx, token)
+}
+
+// Save 在连接关闭时保存令牌和客户端已确认的序号，客户端在有效期内携带令牌重连即可恢复
+func (r *Resumer) Save(ctx context.Context, token string, bizID, userID int64, acked uint64) error {
+	if !r.enabled || token == "" {
+		return nil
+	}
+	return r.store.SavePosition(ctx, token, Position{BizID: bizID, UserID: userID, Acked: acked})
+}
+
+// collapse 只保留折叠键相同的推送中最新的一条，补发时客户端同样只需要最新值
+func collapse(msgs []*gatewayapiv1.PushMessage) []*gatewayapiv1.PushMessage {
+	latest := make(map[string]uint64)
+	for _, m := range msgs {
+		if k := m.GetCollapseKey(); k != "" {
+			latest[k] = m.GetSeq()
+		}
+	}
+	if len(latest) == 0 {
+		return msgs
+	}
+	out := msgs[:0]
+	for _, m := range msgs {
+		if k := m.GetCollapseKey(); k == "" || latest[k] == m.GetSeq() {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// complete 判断序号大于 after 的推送是否都还在缓冲区中，oldest 为缓冲区中最老推送的序号，缓冲区为空时为 0
+// 序号计数随缓冲区一起过期，after 大于 last 说明计数已经重新开始
+func complete(after, last, oldest uint64) bool {
+	switch {
+	case after == last:
+		return true
+	case after > last || oldest == 0:
+		return false
+	default:
+		return oldest <= after+1
+	}
+}
@@ -0,0 +1,13 @@
+package revocation
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义令牌吊销包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewStore),
+	do.Lazy(func(i do.Injector) (Checker, error) {
+		return do.Invoke[*Store](i)
+	}),
+)
@@ -0,0 +1,281 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/jwt"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// tokenKeyFormat 按 jti 吊销的令牌在Redis中的存储键格式
+	tokenKeyFormat = "gateway:revoked:jti:%s"
+	// userKeyFormat 按用户吊销的记录在Redis中的存储键格式，值为吊销时间点 (Unix 秒)
+	userKeyFormat = "gateway:revoked:bizId:%d:userId:%d"
+	// Channel 吊销事件的广播频道，各节点收到后使本地认证缓存失效并按需踢下线
+	Channel = "gateway:revocations"
+	// defaultMaxTokenLifetime 未配置时令牌的最长有效期，与签发令牌的默认有效期一致
+	defaultMaxTokenLifetime = 24 * time.Hour
+)
+
+var (
+	ErrTokenRevoked      = errors.New("令牌已被吊销")
+	ErrDisabled          = errors.New("未启用令牌吊销")
+	ErrInvalidRevocation = errors.New("必须指定jti，或者bizId和userId")
+)
+
+// luaRevokeUser 只在新的吊销时间点更晚时覆盖，并续期到令牌的最长有效期
+// 之前签发的令牌在续期后仍然可能有效，吊销记录需要保留到这些令牌全部过期
+var luaRevokeUser = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tonumber(ARGV[1]) > current then
+    redis.call('SET', KEYS[1], ARGV[1])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// Scripts 返回吊销使用的 Lua 脚本，启动预热时提前加载到 Redis
+func Scripts() []*redis.Script {
+	return []*redis.Script{luaRevokeUser}
+}
+
+// Checker 握手时检查令牌是否已被吊销
+type Checker interface {
+	// Check 令牌已被吊销时返回 ErrTokenRevoked，查询失败时返回其它错误
+	Check(ctx context.Context, claims jwt.UserClaims) error
+}
+
+// Disabled 不做吊销检查的 Checker，用于测试或不需要吊销的部署
+type Disabled struct{}
+
+func (Disabled) Check(context.Context, jwt.UserClaims) error { return nil }
+
+// Revocation 一条吊销记录，JTI 不为空时吊销单个令牌，否则吊销用户在 Before 及之前签发的全部令牌
+type Revocation struct {
+	JTI       string    `json:"jti,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"` // 被吊销令牌的过期时间，吊销记录保留到这个时间
+	BizID     int64     `json:"bizId,omitempty"`
+	UserID    int64     `json:"userId,omitempty"`
+	Before    time.Time `json:"before,omitzero"`
+	Kick      bool      `json:"kick,omitempty"` // 同时将用户在所有节点上的连接踢下线，只对按用户吊销有效
+}
+
+// matches 返回认证结果是否被这条记录吊销
+func (r Revocation) matches(claims jwt.UserClaims) bool {
+	if r.JTI != "" {
+		return claims.ID == r.JTI
+	}
+	if claims.BizID != r.BizID || claims.UserID != r.UserID {
+		return false
+	}
+	return claims.IssuedAt == nil || claims.IssuedAt.Unix() <= r.Before.Unix()
+}
+
+// Store 基于Redis的令牌吊销记录
+//
+// JWT 在过期前一直有效，令牌被盗用时只能等它过期。吊销记录保存在Redis中，握手解析令牌后查询：
+// 按 jti 吊销单个令牌，记录保留到令牌过期；按用户吊销某时间点及之前签发的全部令牌 (包括没有 iat 的令牌)，
+// 记录保留 maxTokenLifetime。吊销时通过 Channel 广播事件，各节点使本地的认证结果缓存失效，
+// 并按需把用户已经建立的连接踢下线。
+type Store struct {
+	enabled     bool
+	maxLifetime time.Duration
+	rdb         redis.UniversalClient
+	token       *jwt.UserToken
+	links       *link.Manager
+	logger      *log.Logger
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewStore(i do.Injector) (*Store, error) {
+	cfg, err := do.Invoke[config.RevocationConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		enabled:     cfg.Enabled,
+		maxLifetime: time.Duration(cfg.MaxTokenLifetime),
+		logger:      logger,
+		done:        make(chan struct{}),
+	}
+	if s.maxLifetime <= 0 {
+		s.maxLifetime = defaultMaxTokenLifetime
+	}
+	if !s.enabled {
+		return s, nil
+	}
+	if s.rdb, err = do.Invoke[redis.UniversalClient](i); err != nil {
+		return nil, err
+	}
+	if s.token, err = do.Invoke[*jwt.UserToken](i); err != nil {
+		return nil, err
+	}
+	if s.links, err = do.Invoke[*link.Manager](i); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Check 查询令牌的吊销记录，未启用时总是返回 nil
+func (s *Store) Check(ctx context.Context, claims jwt.UserClaims) error {
+	if !s.enabled {
+		return nil
+	}
+	keys := []string{fmt.Sprintf(userKeyFormat, claims.BizID, claims.UserID)}
+	if claims.ID != "" {
+		keys = append(keys, fmt.Sprintf(tokenKeyFormat, claims.ID))
+	}
+	values, err := s.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return err
+	}
+	if len(values) > 1 && values[1] != nil {
+		return fmt.Errorf("%w: jti=%s", ErrTokenRevoked, claims.ID)
+	}
+	if v, ok := values[0].(string); ok {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		if claims.IssuedAt == nil || claims.IssuedAt.Unix() <= before {
+			return fmt.Errorf("%w: 用户的令牌在 %s 及之前签发的令牌均已吊销", ErrTokenRevoked, time.Unix(before, 0).Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// Revoke 添加一条吊销记录并广播给所有节点
+// 按用户吊销时 Before 为空表示吊销到当前时间；令牌已经过期时不需要记录，直接返回
+func (s *Store) Revoke(ctx context.Context, r Revocation) error {
+	if !s.enabled {
+		return ErrDisabled
+	}
+	now := time.Now()
+	switch {
+	case r.JTI != "":
+		ttl := s.maxLifetime
+		if !r.ExpiresAt.IsZero() {
+			ttl = r.ExpiresAt.Sub(now)
+		}
+		if ttl <= 0 {
+			return nil
+		}
+		if err := s.rdb.Set(ctx, fmt.Sprintf(tokenKeyFormat, r.JTI), 1, ttl).Err(); err != nil {
+			return err
+		}
+	case r.BizID > 0 && r.UserID > 0:
+		if r.Before.IsZero() {
+			r.Before = now
+		}
+		key := fmt.Sprintf(userKeyFormat, r.BizID, r.UserID)
+		if err := luaRevokeUser.Run(ctx, s.rdb, []string{key}, r.Before.Unix(), s.maxLifetime.Milliseconds()).Err(); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidRevocation
+	}
+
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	// 吊销记录已经生效，广播失败只影响本地缓存失效和踢下线，握手时仍会查询到吊销记录
+	if err := s.rdb.Publish(ctx, Channel, payload).Err(); err != nil {
+		s.logger.Warn("广播吊销事件失败", slog.Any("error", err))
+		s.apply(r)
+	}
+	return nil
+}
+
+// Start 订阅吊销事件并在后台处理，未启用时不订阅；重复调用无效
+// 订阅确认失败时返回错误
+func (s *Store) Start() error {
+	var err error
+	s.startOnce.Do(func() {
+		if !s.enabled {
+			close(s.done)
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		pubsub := s.rdb.Subscribe(ctx, Channel)
+		// 等待订阅确认，确保订阅建立失败时能及时返回错误
+		if _, err = pubsub.Receive(ctx); err != nil {
+			cancel()
+			_ = pubsub.Close()
+			close(s.done)
+			return
+		}
+		s.cancel = cancel
+		go s.run(ctx, pubsub)
+	})
+	return err
+}
+
+// Shutdown 取消订阅并等待后台协程退出
+func (s *Store) Shutdown() {
+	s.stopOnce.Do(func() {
+		if s.cancel != nil {
+			s.cancel()
+		}
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	s.startOnce.Do(func() { close(s.done) })
+	<-s.done
+}
+
+func (s *Store) run(ctx context.Context, pubsub *redis.PubSub) {
+	defer close(s.done)
+	defer pubsub.Close()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var r Revocation
+			if err := json.Unmarshal([]byte(m.Payload), &r); err != nil {
+				s.logger.Warn("无法解析吊销事件", slog.String("payload", m.Payload), slog.Any("error", err))
+				continue
+			}
+			s.apply(r)
+		}
+	}
+}
+
+// apply 在本节点上执行吊销：使认证结果缓存失效，按需把用户的连接踢下线
+func (s *Store) apply(r Revocation) {
+	n := s.token.Cache().InvalidateFunc(r.matches)
+	kicked := 0
+	if r.Kick && r.JTI == "" {
+		kicked = s.links.Kick(r.BizID, r.UserID)
+	}
+	s.logger.Info("令牌已吊销",
+		slog.String("jti", r.JTI),
+		slog.Int64("bizId", r.BizID),
+		slog.Int64("userId", r.UserID),
+		slog.Int("invalidated", n),
+		slog.Int("kicked", kicked))
+}
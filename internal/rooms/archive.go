@@ -0,0 +1,118 @@
+package rooms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// archiveKeyFormat 房间近期消息存档的存储键格式，每个房间一个 Stream；
+// 与成员有序集合的键使用不同的前缀，任何房间名称都不会让两者冲突
+const archiveKeyFormat = "gateway:rooms:archive:bizId:%d:room:%s"
+
+var (
+	ErrNoArchive = errors.New("业务方未启用房间消息存档")
+	ErrNotInRoom = errors.New("连接不在房间中")
+)
+
+// ArchivedMessage 房间存档中的一条推送
+type ArchivedMessage struct {
+	ID   string `json:"id"`   // Stream 中的条目ID
+	Key  string `json:"key"`  // 推送的 key
+	Body []byte `json:"body"` // 推送的消息体
+	Time int64  `json:"time"` // 写入存档的时间 (Unix 毫秒)
+}
+
+// archiveConfig 解析后的房间存档配置
+type archiveConfig struct {
+	maxLen int
+	ttl    time.Duration
+	biz    map[int64]int
+}
+
+func newArchiveConfig(cfg config.RoomArchiveConfig) archiveConfig {
+	c := archiveConfig{
+		maxLen: cfg.MaxLen,
+		ttl:    time.Duration(cfg.TTL),
+		biz:    make(map[int64]int, len(cfg.BizPolicies)),
+	}
+	for _, p := range cfg.BizPolicies {
+		c.biz[p.BizID] = p.MaxLen
+	}
+	return c
+}
+
+// of 返回业务方每个房间存档的消息数，0 表示不存档
+func (c archiveConfig) of(bizID int64) int {
+	if n, ok := c.biz[bizID]; ok {
+		return n
+	}
+	return c.maxLen
+}
+
+// Archived 返回业务方的房间推送是否写入存档
+func (r *Rooms) Archived(bizID int64) bool {
+	return r.enabled && r.archive.of(bizID) > 0
+}
+
+// Archive 把一条房间推送写入房间的存档，只保留最近的消息；业务方不存档时什么也不做
+// 由发起房间推送的节点写入一次，存档失败不影响推送本身
+func (r *Rooms) Archive(ctx context.Context, msg *gatewayapiv1.PushMessage, name string) error {
+	if !r.enabled {
+		return ErrDisabled
+	}
+	maxLen := r.archive.of(msg.GetBizId())
+	if maxLen <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf(archiveKeyFormat, msg.GetBizId(), name)
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: key,
+			MaxLen: int64(maxLen),
+			Approx: true,
+			Values: []any{"key", msg.GetKey(), "body", msg.GetBody()},
+		})
+		if r.archive.ttl > 0 {
+			pipe.PExpire(ctx, key, r.archive.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// History 返回房间存档中最近的 limit 条推送，按推送的先后排序
+// limit 不大于 0 或超过业务方存档的消息数时按存档的消息数返回；业务方不存档时返回 ErrNoArchive
+func (r *Rooms) History(ctx context.Context, bizID int64, name string, limit int) ([]ArchivedMessage, error) {
+	if !r.enabled {
+		return nil, ErrDisabled
+	}
+	maxLen := r.archive.of(bizID)
+	if maxLen <= 0 {
+		return nil, ErrNoArchive
+	}
+	if limit <= 0 || limit > maxLen {
+		limit = maxLen
+	}
+	entries, err := r.rdb.XRevRangeN(ctx, fmt.Sprintf(archiveKeyFormat, bizID, name), "+", "-", int64(limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]ArchivedMessage, 0, len(entries))
+	for _, e := range slices.Backward(entries) {
+		key, _ := e.Values["key"].(string)
+		body, _ := e.Values["body"].(string)
+		ms, _, _ := strings.Cut(e.ID, "-")
+		t, _ := strconv.ParseInt(ms, 10, 64)
+		messages = append(messages, ArchivedMessage{ID: e.ID, Key: key, Body: []byte(body), Time: t})
+	}
+	return messages, nil
+}
@@ -0,0 +1,80 @@
+package rooms
+
+import (
+	"context"
+	"log/slog"
+	"math"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/link"
+)
+
+// Handler 包装上行消息处理器，由网关处理 ROOM_JOIN/ROOM_LEAVE/ROOM_HISTORY 控制消息，其它消息交给 next
+// 控制消息以相同的 cmd 和 key 回复，body 为空表示成功，否则为失败原因
+func (r *Rooms) Handler(next link.Handler) link.Handler {
+	return link.HandlerFunc(func(l *link.Link, msg *gatewayapiv1.Message) {
+		switch msg.GetCmd() {
+		case gatewayapiv1.Message_COMMAND_TYPE_ROOM_JOIN, gatewayapiv1.Message_COMMAND_TYPE_ROOM_LEAVE:
+			r.control(l, msg)
+		case gatewayapiv1.Message_COMMAND_TYPE_ROOM_HISTORY:
+			r.history(l, msg)
+		default:
+			next.Handle(l, msg)
+		}
+	})
+}
+
+func (r *Rooms) control(l *link.Link, msg *gatewayapiv1.Message) {
+	err := r.clientOp(l, msg.GetCmd(), string(msg.GetBody()))
+	reply := &gatewayapiv1.Message{Cmd: msg.GetCmd(), Key: msg.GetKey()}
+	if err != nil {
+		reply.Body = []byte(err.Error())
+	}
+	if err := l.SendMessage(reply); err != nil {
+		r.logger.Debug("回复房间操作失败", slog.String("linkId", l.ID()), slog.Any("error", err))
+	}
+}
+
+// history 先逐条下发房间存档中最近的推送（cmd 为 ROOM_HISTORY，key 和 body 为原推送的 key 和 body），
+// 最后以请求的 key 回复；请求的 seq 为需要的条数，0 表示业务方存档的所有消息
+func (r *Rooms) history(l *link.Link, msg *gatewayapiv1.Message) {
+	reply := &gatewayapiv1.Message{Cmd: msg.GetCmd(), Key: msg.GetKey()}
+	messages, err := r.clientHistory(l, string(msg.GetBody()), msg.GetSeq())
+	for _, m := range messages {
+		if err = l.SendMessage(&gatewayapiv1.Message{Cmd: msg.GetCmd(), Key: m.Key, Body: m.Body}); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		reply.Body = []byte(err.Error())
+	}
+	if err := l.SendMessage(reply); err != nil {
+		r.logger.Debug("回复房间消息存档失败", slog.String("linkId", l.ID()), slog.Any("error", err))
+	}
+}
+
+func (r *Rooms) clientHistory(l *link.Link, name string, limit uint64) ([]ArchivedMessage, error) {
+	if !r.enabled {
+		return nil, ErrDisabled
+	}
+	if !r.In(l, name) {
+		return nil, ErrNotInRoom
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return r.History(ctx, l.Session().UserInfo().BizID, name, int(min(limit, math.MaxInt32)))
+}
+
+func (r *Rooms) clientOp(l *link.Link, cmd gatewayapiv1.Message_CommandType, name string) error {
+	if !r.enabled {
+		return ErrDisabled
+	}
+	if !r.clientJoin {
+		return ErrClientJoin
+	}
+	if cmd == gatewayapiv1.Message_COMMAND_TYPE_ROOM_JOIN {
+		return r.Join(l, name)
+	}
+	r.Leave(l, name)
+	return nil
+}
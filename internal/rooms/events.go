@@ -0,0 +1,126 @@
+package rooms
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Channel 管理API加入、退出房间的事件广播频道，各节点把事件应用到本节点上目标用户的连接
+const Channel = "gateway:rooms:events"
+
+// 房间事件的操作
+const (
+	OpJoin  = "join"
+	OpLeave = "leave"
+)
+
+// Event 通过 Channel 广播的房间事件
+type Event struct {
+	Op      string  `json:"op"`
+	BizID   int64   `json:"bizId"`
+	Room    string  `json:"room"`
+	UserIDs []int64 `json:"userIds"`
+}
+
+// Publish 广播房间事件，返回收到事件的节点数
+// 事件只作用于发布时在线的连接，用户之后建立的连接不会自动加入房间
+func (r *Rooms) Publish(ctx context.Context, e Event) (int64, error) {
+	if !r.enabled {
+		return 0, ErrDisabled
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	return r.rdb.Publish(ctx, Channel, payload).Result()
+}
+
+// Start 订阅房间事件并在后台定期续期成员记录，未启用时不订阅；重复调用无效
+// 订阅确认失败时返回错误
+func (r *Rooms) Start() error {
+	var err error
+	r.startOnce.Do(func() {
+		if !r.enabled {
+			close(r.done)
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		pubsub := r.rdb.Subscribe(ctx, Channel)
+		// 等待订阅确认，确保订阅建立失败时能及时返回错误
+		if _, err = pubsub.Receive(ctx); err != nil {
+			cancel()
+			_ = pubsub.Close()
+			close(r.done)
+			return
+		}
+		r.cancel = cancel
+		go r.run(ctx, pubsub)
+	})
+	return err
+}
+
+// Shutdown 取消订阅并等待后台协程退出
+// 本节点的成员记录不主动删除，连接关闭时各自退出房间，残留的记录在 ttl 内过期
+func (r *Rooms) Shutdown() {
+	r.stopOnce.Do(func() {
+		if r.cancel != nil {
+			r.cancel()
+		}
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	r.startOnce.Do(func() { close(r.done) })
+	<-r.done
+}
+
+func (r *Rooms) run(ctx context.Context, pubsub *redis.PubSub) {
+	defer close(r.done)
+	defer pubsub.Close()
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rctx, cancel := context.WithTimeout(ctx, redisTimeout)
+			if err := r.refresh(rctx); err != nil && ctx.Err() == nil {
+				r.logger.Warn("续期房间成员失败", slog.Any("error", err))
+			}
+			cancel()
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(m.Payload), &e); err != nil {
+				r.logger.Warn("无法解析房间事件", slog.String("payload", m.Payload), slog.Any("error", err))
+				continue
+			}
+			r.apply(e)
+		}
+	}
+}
+
+// apply 把房间事件应用到本节点上目标用户的所有连接
+func (r *Rooms) apply(e Event) {
+	for _, userID := range e.UserIDs {
+		for _, l := range r.links.GetByUser(e.BizID, userID) {
+			switch e.Op {
+			case OpJoin:
+				if err := r.Join(l, e.Room); err != nil {
+					r.logger.Debug("连接加入房间失败",
+						slog.String("linkId", l.ID()),
+						slog.String("room", e.Room),
+						slog.Any("error", err))
+				}
+			case OpLeave:
+				r.Leave(l, e.Room)
+			}
+		}
+	}
+}
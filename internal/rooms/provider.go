@@ -0,0 +1,10 @@
+package rooms
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Rooms 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewRooms),
+)
@@ -0,0 +1,347 @@
+// Package rooms 维护连接的房间成员关系，推送可以以房间为目标，只投递给加入了房间的连接
+//
+// 连接通过 ROOM_JOIN/ROOM_LEAVE 控制消息自行加入、退出房间，也可以由业务后端通过管理API把用户的所有连接加入房间。
+// 每个节点在内存中维护本节点连接的房间索引，向房间推送时各节点只投递给本节点索引中的连接；
+// 成员关系同时写入Redis（每个房间一个有序集合，成员为 userId@nodeId，分值为过期时间），供跨节点查询房间成员。
+// 成员关系随连接存在：连接关闭时自动退出所有房间，持有连接的节点每 ttl/3 续期一次，节点崩溃后其成员记录在 ttl 内过期，
+// 不会残留在房间中。
+// 启用存档的业务方的房间推送还会写入房间的 Redis Stream，只保留最近的消息，加入房间的连接通过 ROOM_HISTORY 控制消息回看。
+package rooms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// keyFormat 房间成员有序集合的存储键格式
+	keyFormat = "gateway:rooms:bizId:%d:room:%s"
+	// redisTimeout 访问Redis的超时时间
+	redisTimeout = 3 * time.Second
+	// maxNameLen 房间名称的最大长度
+	maxNameLen = 128
+)
+
+var (
+	ErrDisabled     = errors.New("未启用房间")
+	ErrClientJoin   = errors.New("不允许客户端自行加入或退出房间")
+	ErrInvalidName  = errors.New("房间名称不能为空且不能超过128个字符")
+	ErrTooManyRooms = errors.New("连接加入的房间数已达上限")
+)
+
+// roomKey 本节点房间索引的键，房间名称在业务方内唯一
+type roomKey struct {
+	bizID int64
+	name  string
+}
+
+// Rooms 房间成员关系，未启用时 Join 返回 ErrDisabled，其它方法不做任何事
+type Rooms struct {
+	enabled    bool
+	clientJoin bool
+	ttl        time.Duration
+	maxPerLink int
+	archive    archiveConfig
+	nodeID     string
+	rdb        redis.UniversalClient
+	links      *link.Manager
+	logger     *log.Logger
+
+	mu     sync.RWMutex
+	local  map[roomKey]map[string]*link.Link // 房间中本节点上的连接，按连接ID索引
+	byLink map[string]map[string]struct{}    // 连接加入的房间名称
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewRooms(i do.Injector) (*Rooms, error) {
+	cfg, err := do.Invoke[config.RoomsConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	r := &Rooms{
+		enabled:    cfg.Enabled,
+		clientJoin: cfg.ClientJoin,
+		ttl:        time.Duration(cfg.TTL),
+		maxPerLink: cfg.MaxRoomsPerLink,
+		archive:    newArchiveConfig(cfg.Archive),
+		nodeID:     appCfg.InstanceID(),
+		logger:     logger,
+		local:      make(map[roomKey]map[string]*link.Link),
+		byLink:     make(map[string]map[string]struct{}),
+		done:       make(chan struct{}),
+	}
+	if !r.enabled {
+		return r, nil
+	}
+	if r.rdb, err = do.Invoke[redis.UniversalClient](i); err != nil {
+		return nil, err
+	}
+	if r.links, err = do.Invoke[*link.Manager](i); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Enabled 返回是否启用了房间
+func (r *Rooms) Enabled() bool {
+	return r.enabled
+}
+
+// ValidName 返回房间名称是否合法
+func ValidName(name string) bool {
+	return name != "" && len(name) <= maxNameLen
+}
+
+// Join 把连接加入房间，已在房间中时直接返回
+// 写入Redis失败时本节点上的成员关系照常生效（推送只依赖本节点的索引），成员记录在下一次续期时补写
+func (r *Rooms) Join(l *link.Link, name string) error {
+	if !r.enabled {
+		return ErrDisabled
+	}
+	if !ValidName(name) {
+		return ErrInvalidName
+	}
+	info := l.Session().UserInfo()
+	key := roomKey{bizID: info.BizID, name: name}
+
+	r.mu.Lock()
+	joined, watched := r.byLink[l.ID()]
+	if _, ok := joined[name]; ok {
+		r.mu.Unlock()
+		return nil
+	}
+	if len(joined) >= r.maxPerLink {
+		r.mu.Unlock()
+		return ErrTooManyRooms
+	}
+	if !watched {
+		joined = make(map[string]struct{}, 1)
+		r.byLink[l.ID()] = joined
+	}
+	joined[name] = struct{}{}
+	members, ok := r.local[key]
+	if !ok {
+		members = make(map[string]*link.Link, 1)
+		r.local[key] = members
+	}
+	members[l.ID()] = l
+	r.mu.Unlock()
+
+	if !watched {
+		go r.watch(l)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.add(ctx, key, info.UserID); err != nil {
+		r.logger.Warn("写入房间成员失败", slog.Int64("bizId", key.bizID), slog.String("room", name), slog.Any("error", err))
+	}
+	return nil
+}
+
+// Leave 把连接移出房间，连接不在房间中时返回 false
+func (r *Rooms) Leave(l *link.Link, name string) bool {
+	if !r.enabled {
+		return false
+	}
+	info := l.Session().UserInfo()
+	key := roomKey{bizID: info.BizID, name: name}
+	r.mu.Lock()
+	joined := r.byLink[l.ID()]
+	if _, ok := joined[name]; !ok {
+		r.mu.Unlock()
+		return false
+	}
+	delete(joined, name)
+	last := r.removeLocked(key, l)
+	r.mu.Unlock()
+
+	if last {
+		r.remove(key, info.UserID)
+	}
+	return true
+}
+
+// watch 在连接关闭时让它退出所有房间
+func (r *Rooms) watch(l *link.Link) {
+	<-l.HasClose()
+	info := l.Session().UserInfo()
+	r.mu.Lock()
+	joined := r.byLink[l.ID()]
+	delete(r.byLink, l.ID())
+	var gone []roomKey
+	for name := range joined {
+		key := roomKey{bizID: info.BizID, name: name}
+		if r.removeLocked(key, l) {
+			gone = append(gone, key)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, key := range gone {
+		r.remove(key, info.UserID)
+	}
+}
+
+// removeLocked 把连接从本节点的房间索引中移除，返回该用户在本节点上是否已经没有连接在房间中；调用方持有写锁
+func (r *Rooms) removeLocked(key roomKey, l *link.Link) bool {
+	members := r.local[key]
+	delete(members, l.ID())
+	if len(members) == 0 {
+		delete(r.local, key)
+		return true
+	}
+	userID := l.Session().UserInfo().UserID
+	for _, other := range members {
+		if other.Session().UserInfo().UserID == userID {
+			return false
+		}
+	}
+	return true
+}
+
+// Links 返回房间中本节点上的连接
+func (r *Rooms) Links(bizID int64, name string) []*link.Link {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	members := r.local[roomKey{bizID: bizID, name: name}]
+	links := make([]*link.Link, 0, len(members))
+	for _, l := range members {
+		links = append(links, l)
+	}
+	return links
+}
+
+// In 返回连接是否在房间中
+func (r *Rooms) In(l *link.Link, name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.byLink[l.ID()][name]
+	return ok
+}
+
+// RoomsOf 返回连接加入的房间
+func (r *Rooms) RoomsOf(l *link.Link) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.byLink[l.ID()]))
+	for name := range r.byLink[l.ID()] {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Members 返回房间在所有节点上的成员用户，顺带清理已过期的成员记录
+func (r *Rooms) Members(ctx context.Context, bizID int64, name string) ([]int64, error) {
+	if !r.enabled {
+		return nil, ErrDisabled
+	}
+	key := fmt.Sprintf(keyFormat, bizID, name)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	var members *redis.StringSliceCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+now)
+		members = pipe.ZRange(ctx, key, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]struct{}, len(members.Val()))
+	userIDs := make([]int64, 0, len(members.Val()))
+	for _, m := range members.Val() {
+		uid, _, _ := strings.Cut(m, "@")
+		userID, err := strconv.ParseInt(uid, 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[userID]; !ok {
+			seen[userID] = struct{}{}
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+// add 写入或续期一个成员记录
+func (r *Rooms) add(ctx context.Context, key roomKey, userID int64) error {
+	rk := fmt.Sprintf(keyFormat, key.bizID, key.name)
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, rk, redis.Z{Score: r.expiry(), Member: r.member(userID)})
+		pipe.PExpire(ctx, rk, r.ttl)
+		return nil
+	})
+	return err
+}
+
+// remove 删除用户在本节点上的成员记录，失败时记录在 ttl 内过期
+func (r *Rooms) remove(key roomKey, userID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.rdb.ZRem(ctx, fmt.Sprintf(keyFormat, key.bizID, key.name), r.member(userID)).Err(); err != nil {
+		r.logger.Warn("删除房间成员失败", slog.Int64("bizId", key.bizID), slog.String("room", key.name), slog.Any("error", err))
+	}
+}
+
+// refresh 续期本节点上所有连接的成员记录
+func (r *Rooms) refresh(ctx context.Context) error {
+	r.mu.RLock()
+	users := make(map[roomKey]map[int64]struct{}, len(r.local))
+	for key, members := range r.local {
+		ids := make(map[int64]struct{}, len(members))
+		for _, l := range members {
+			ids[l.Session().UserInfo().UserID] = struct{}{}
+		}
+		users[key] = ids
+	}
+	r.mu.RUnlock()
+	if len(users) == 0 {
+		return nil
+	}
+
+	expiry := r.expiry()
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, ids := range users {
+			rk := fmt.Sprintf(keyFormat, key.bizID, key.name)
+			zs := make([]redis.Z, 0, len(ids))
+			for id := range ids {
+				zs = append(zs, redis.Z{Score: expiry, Member: r.member(id)})
+			}
+			pipe.ZAdd(ctx, rk, zs...)
+			pipe.PExpire(ctx, rk, r.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *Rooms) member(userID int64) string {
+	return strconv.FormatInt(userID, 10) + "@" + r.nodeID
+}
+
+func (r *Rooms) expiry() float64 {
+	return float64(time.Now().Add(r.ttl).UnixMilli())
+}
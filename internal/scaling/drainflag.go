@@ -0,0 +1,34 @@
+package scaling
+
+import (
+	"log/slog"
+
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/kv"
+)
+
+// DrainFlagPrefix 集群摘流标记在 kv.Store 中的键前缀，键为前缀加节点ID，值为设置标记的操作者
+// 任意节点上设置标记后，目标节点摘流并保持到标记被删除，节点重启后仍然生效
+const DrainFlagPrefix = "drain/"
+
+// DrainFlagKey 返回节点的摘流标记键
+func DrainFlagKey(nodeID string) string {
+	return DrainFlagPrefix + nodeID
+}
+
+// onDrainFlag 本节点的摘流标记被设置时摘流，被删除时恢复接收连接
+func (m *Monitor) onDrainFlag(e kv.Event) {
+	if e.Key != DrainFlagKey(m.nodeID) {
+		// 前缀匹配到了其它节点，例如 gw-1 匹配到 gw-10
+		return
+	}
+	if e.Deleted {
+		m.links.Undrain()
+		m.logger.Warn("集群摘流标记已删除，节点恢复接收连接")
+		return
+	}
+	m.links.Drain(m.policies.Advice(backoff.ReasonDrain))
+	m.logger.Warn("节点已被集群摘流标记摘流",
+		slog.String("by", e.Value),
+		slog.Int("connections", m.links.Count()))
+}
@@ -0,0 +1,46 @@
+package scaling
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ReadyPath 就绪探针的路径
+const ReadyPath = "/ready"
+
+// snapshot 扩缩容指标 JSON 的响应体
+type snapshot struct {
+	Node    NodeSnapshot    `json:"node"`
+	Cluster ClusterSnapshot `json:"cluster"`
+}
+
+// Register 在 HTTP 服务上注册就绪探针和扩缩容指标 JSON
+// 与 /health、/metrics 一样不需要认证，供 kubelet、Prometheus 和 KEDA 直接访问
+func (m *Monitor) Register(r fiber.Router) {
+	r.Get(ReadyPath, m.ready)
+	if m.path != "" {
+		r.Get(m.path, m.snapshot)
+	}
+}
+
+// ready 就绪时返回 200，否则返回 503，响应体为节点状态
+// GET /ready
+func (m *Monitor) ready(c fiber.Ctx) error {
+	state := m.State()
+	if state != StateReady {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.SendString(string(state))
+}
+
+// snapshot 返回本节点和全集群的连接数、容量和消息速率
+// GET {scaling.path}
+func (m *Monitor) snapshot(c fiber.Ctx) error {
+	cluster, err := m.Cluster(c)
+	if err != nil {
+		m.logger.Warn("汇总集群扩缩容状态失败", slog.Any("error", err))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(snapshot{Node: m.Node(), Cluster: cluster})
+}
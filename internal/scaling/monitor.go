@@ -0,0 +1,468 @@
+package scaling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/limiter"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/server"
+	"github.com/YaoAzure/wsgateway/internal/warmup"
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/kv"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// nodesKey 各节点上报扩缩容状态的 Hash，field 为节点ID，value 为 JSON 编码的 NodeSnapshot
+	nodesKey = "gateway:scaling:nodes"
+	// staleAfter 超过多少个采样周期没有上报的节点视为已下线
+	staleAfter = 3
+	// reportTimeout 上报和汇总集群状态的超时时间
+	reportTimeout = 3 * time.Second
+
+	defaultSampleInterval = 5 * time.Second
+	defaultRateWindow     = time.Minute
+)
+
+// State 节点在扩缩容生命周期中的状态
+type State string
+
+const (
+	StateStarting  State = "starting"  // 尚未开始接收连接
+	StateWarmingUp State = "warmingUp" // 启动预热中，缓存和连接尚未就绪 (warmup.Warmer)
+	StateRampingUp State = "rampingUp" // 令牌桶预热中，容量未达到 minReadyCapacity
+	StateReady     State = "ready"     // 正常接收连接
+	StatePreStop   State = "preStop"   // preStop 钩子已触发，等待 Service 摘除本节点
+	StateDraining  State = "draining"  // 摘流中，拒绝新连接并关闭已有连接
+)
+
+var ErrPreStopInProgress = errors.New("preStop 已在进行中")
+
+// MessageRate 滑动窗口内平均每秒的消息数
+type MessageRate struct {
+	Upstream   float64 `json:"upstream"`
+	Downstream float64 `json:"downstream"`
+	Total      float64 `json:"total"`
+}
+
+// NodeSnapshot 本节点的扩缩容状态
+type NodeSnapshot struct {
+	NodeID            string      `json:"nodeId"`
+	State             State       `json:"state"`
+	Ready             bool        `json:"ready"`
+	Connections       int         `json:"connections"`
+	Users             int         `json:"users"`
+	Capacity          int64       `json:"capacity"`
+	CurrentCapacity   int64       `json:"currentCapacity"`
+	Utilization       float64     `json:"utilization"`
+	MessagesPerSecond MessageRate `json:"messagesPerSecond"`
+	UpdatedAt         time.Time   `json:"updatedAt"`
+}
+
+// ClusterSnapshot 全集群的扩缩容状态，由各节点上报的 NodeSnapshot 汇总而来
+type ClusterSnapshot struct {
+	Nodes             int         `json:"nodes"`
+	ReadyNodes        int         `json:"readyNodes"`
+	Connections       int         `json:"connections"`
+	Capacity          int64       `json:"capacity"`
+	Utilization       float64     `json:"utilization"`
+	MessagesPerSecond MessageRate `json:"messagesPerSecond"`
+}
+
+// sample 某一时刻的消息累计数
+type sample struct {
+	at         time.Time
+	upstream   uint64
+	downstream uint64
+}
+
+// Monitor 节点的扩缩容状态
+//
+//   - 按 sampleInterval 采样上行、下行消息累计数，在 rateWindow 滑动窗口内计算消息速率
+//   - 汇总连接数、令牌桶容量和就绪状态，导出为 Prometheus 指标 (metrics.ScalingMetrics)
+//   - 启用多节点部署时每个采样周期把本节点状态写入 Redis，供扩缩容指标 JSON 汇总全集群数据
+//   - 就绪探针：开始接收连接、启动预热结束且令牌桶预热到 minReadyCapacity 后就绪，preStop 和摘流期间不就绪
+//   - preStop：先让就绪探针失败，等待 Service 摘除本节点，再摘流并等待连接关闭
+type Monitor struct {
+	links    *link.Manager
+	limiter  *limiter.TokenLimiter
+	server   *server.WebsocketServer
+	warmer   *warmup.Warmer
+	messages *metrics.MessageMetrics
+	queue    *metrics.QueueMetrics
+	flags    *kv.Store
+	policies *backoff.Policies
+	rdb      redis.UniversalClient // 未启用多节点部署时为 nil
+	nodeID   string
+	path     string
+	logger   *log.Logger
+
+	interval     time.Duration
+	window       time.Duration
+	minReady     int64
+	preStopDelay time.Duration
+	gracePeriod  time.Duration
+
+	started  atomic.Bool
+	stopping atomic.Bool
+	preStop  sync.Mutex
+
+	mu      sync.RWMutex
+	samples []sample
+	rate    MessageRate
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewMonitor(i do.Injector) (*Monitor, error) {
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	l, err := do.Invoke[*limiter.TokenLimiter](i)
+	if err != nil {
+		return nil, err
+	}
+	srv, err := do.Invoke[*server.WebsocketServer](i)
+	if err != nil {
+		return nil, err
+	}
+	warmer, err := do.Invoke[*warmup.Warmer](i)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := do.Invoke[*metrics.MessageMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := do.Invoke[*metrics.QueueMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	scalingMetrics, err := do.Invoke[*metrics.ScalingMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := do.Invoke[config.ScalingConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	serverCfg, err := do.Invoke[config.ServerConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	clusterCfg, err := do.Invoke[config.ClusterConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := do.Invoke[*kv.Store](i)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := do.Invoke[*backoff.Policies](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	m := &Monitor{
+		links:        links,
+		limiter:      l,
+		server:       srv,
+		warmer:       warmer,
+		messages:     messages,
+		queue:        queue,
+		flags:        flags,
+		policies:     policies,
+		nodeID:       appCfg.InstanceID(),
+		path:         cfg.Path,
+		logger:       logger,
+		interval:     time.Duration(cfg.SampleInterval),
+		window:       time.Duration(cfg.RateWindow),
+		minReady:     cfg.MinReadyCapacity,
+		preStopDelay: time.Duration(cfg.PreStop.Delay),
+		gracePeriod:  time.Duration(serverCfg.Shutdown.GracePeriod),
+		done:         make(chan struct{}),
+	}
+	if m.interval <= 0 {
+		m.interval = defaultSampleInterval
+	}
+	if m.window <= 0 {
+		m.window = defaultRateWindow
+	}
+	m.window = max(m.window, m.interval)
+	if clusterCfg.Enabled {
+		if m.rdb, err = do.Invoke[redis.UniversalClient](i); err != nil {
+			return nil, err
+		}
+	}
+	scalingMetrics.SetSource(m.metricsSample)
+	return m, nil
+}
+
+// Start 开始在后台采样消息速率并上报本节点状态，并按集群摘流标记摘流，重复调用无效
+func (m *Monitor) Start() {
+	m.startOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		unwatch := m.flags.Watch(DrainFlagKey(m.nodeID), m.onDrainFlag)
+		m.cancel = func() {
+			unwatch()
+			cancel()
+		}
+		m.record(time.Now())
+		go m.run(ctx)
+	})
+}
+
+// Shutdown 停止后台采样，并从 Redis 中删除本节点的上报记录，使集群汇总立即排除本节点
+func (m *Monitor) Shutdown() {
+	m.stopOnce.Do(func() {
+		if m.cancel != nil {
+			m.cancel()
+		}
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	m.startOnce.Do(func() { close(m.done) })
+	<-m.done
+}
+
+func (m *Monitor) run(ctx context.Context) {
+	defer close(m.done)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			m.unregister()
+			return
+		case now := <-ticker.C:
+			m.record(now)
+			m.report(ctx)
+		}
+	}
+}
+
+// MarkStarted 标记节点已经开始接收连接，此后就绪探针才可能成功
+func (m *Monitor) MarkStarted() {
+	m.started.Store(true)
+}
+
+// State 返回节点当前的状态
+func (m *Monitor) State() State {
+	switch {
+	case !m.started.Load():
+		return StateStarting
+	case m.links.Draining():
+		return StateDraining
+	case m.stopping.Load():
+		return StatePreStop
+	case !m.warmer.Ready():
+		return StateWarmingUp
+	case m.limiter.CurrentCapacity() < m.minReady:
+		return StateRampingUp
+	default:
+		return StateReady
+	}
+}
+
+// Ready 返回就绪探针的结果
+func (m *Monitor) Ready() bool {
+	return m.State() == StateReady
+}
+
+// PreStop 处理 Kubernetes 的 preStop 钩子：就绪探针立即失败，等待 preStopDelay 让 Endpoints 摘除本节点，
+// 期间已有连接和新连接照常处理；随后摘流，阻塞到所有连接关闭、超过停机宽限期或 ctx 结束
+// 摘流后节点不再恢复，之后收到的 SIGTERM 只需要关闭 HTTP 服务；同一时间只允许一次 preStop
+func (m *Monitor) PreStop(ctx context.Context) error {
+	if !m.preStop.TryLock() {
+		return ErrPreStopInProgress
+	}
+	defer m.preStop.Unlock()
+
+	m.stopping.Store(true)
+	m.logger.Warn("收到 preStop，等待 Service 摘除本节点后摘流",
+		slog.Duration("delay", m.preStopDelay),
+		slog.Int("connections", m.links.Count()))
+	select {
+	case <-time.After(m.preStopDelay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if m.gracePeriod > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.gracePeriod)
+		defer cancel()
+	}
+	if err := m.server.Drain(ctx); err != nil {
+		return err
+	}
+	m.logger.Info("preStop 摘流完成")
+	return nil
+}
+
+// record 记录一次消息累计数，并按滑动窗口重新计算消息速率
+func (m *Monitor) record(now time.Time) {
+	s := sample{at: now, upstream: m.messages.Received(), downstream: m.queue.Written()}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, s)
+	// 保留窗口起点之前的最后一个样本，使速率覆盖完整的窗口
+	cutoff := now.Add(-m.window)
+	drop := 0
+	for drop+1 < len(m.samples) && !m.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	m.samples = m.samples[drop:]
+
+	first := m.samples[0]
+	elapsed := s.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	m.rate.Upstream = float64(s.upstream-first.upstream) / elapsed
+	m.rate.Downstream = float64(s.downstream-first.downstream) / elapsed
+	m.rate.Total = m.rate.Upstream + m.rate.Downstream
+}
+
+// Node 返回本节点的扩缩容状态
+func (m *Monitor) Node() NodeSnapshot {
+	m.mu.RLock()
+	rate := m.rate
+	m.mu.RUnlock()
+
+	state := m.State()
+	s := NodeSnapshot{
+		NodeID:            m.nodeID,
+		State:             state,
+		Ready:             state == StateReady,
+		Connections:       m.links.Count(),
+		Users:             m.links.CountUsers(),
+		Capacity:          m.limiter.MaxCapacity(),
+		CurrentCapacity:   m.limiter.CurrentCapacity(),
+		MessagesPerSecond: rate,
+		UpdatedAt:         time.Now(),
+	}
+	if s.Capacity > 0 {
+		s.Utilization = float64(s.Connections) / float64(s.Capacity)
+	}
+	return s
+}
+
+// Cluster 汇总各节点上报的状态，未启用多节点部署时只包含本节点
+// 超过 staleAfter 个采样周期没有上报的节点视为已下线，不计入汇总并从 Redis 中删除
+func (m *Monitor) Cluster(ctx context.Context) (ClusterSnapshot, error) {
+	if m.rdb == nil {
+		return aggregate([]NodeSnapshot{m.Node()}), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	entries, err := m.rdb.HGetAll(ctx, nodesKey).Result()
+	if err != nil {
+		return ClusterSnapshot{}, fmt.Errorf("查询集群扩缩容状态失败: %w", err)
+	}
+
+	deadline := time.Now().Add(-staleAfter * m.interval)
+	nodes := make([]NodeSnapshot, 0, len(entries))
+	var stale []string
+	for nodeID, data := range entries {
+		if nodeID == m.nodeID {
+			// 本节点使用实时状态，不依赖上一次上报
+			continue
+		}
+		var s NodeSnapshot
+		if err := json.Unmarshal([]byte(data), &s); err != nil || s.UpdatedAt.Before(deadline) {
+			stale = append(stale, nodeID)
+			continue
+		}
+		nodes = append(nodes, s)
+	}
+	nodes = append(nodes, m.Node())
+	if len(stale) > 0 {
+		if err := m.rdb.HDel(ctx, nodesKey, stale...).Err(); err != nil {
+			m.logger.Warn("删除已下线节点的扩缩容状态失败", slog.Any("nodes", stale), slog.Any("error", err))
+		}
+	}
+	return aggregate(nodes), nil
+}
+
+func aggregate(nodes []NodeSnapshot) ClusterSnapshot {
+	var c ClusterSnapshot
+	for _, n := range nodes {
+		c.Nodes++
+		if n.Ready {
+			c.ReadyNodes++
+		}
+		c.Connections += n.Connections
+		c.Capacity += n.Capacity
+		c.MessagesPerSecond.Upstream += n.MessagesPerSecond.Upstream
+		c.MessagesPerSecond.Downstream += n.MessagesPerSecond.Downstream
+		c.MessagesPerSecond.Total += n.MessagesPerSecond.Total
+	}
+	if c.Capacity > 0 {
+		c.Utilization = float64(c.Connections) / float64(c.Capacity)
+	}
+	return c
+}
+
+// report 把本节点状态写入 Redis，未启用多节点部署时不上报
+func (m *Monitor) report(ctx context.Context) {
+	if m.rdb == nil {
+		return
+	}
+	data, err := json.Marshal(m.Node())
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	if err := m.rdb.HSet(ctx, nodesKey, m.nodeID, data).Err(); err != nil && ctx.Err() == nil {
+		m.logger.Warn("上报扩缩容状态失败", slog.Any("error", err))
+	}
+}
+
+// unregister 删除本节点的上报记录
+func (m *Monitor) unregister() {
+	if m.rdb == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+	if err := m.rdb.HDel(ctx, nodesKey, m.nodeID).Err(); err != nil {
+		m.logger.Warn("删除本节点的扩缩容状态失败", slog.Any("error", err))
+	}
+}
+
+// metricsSample 供 Prometheus 抓取的本节点状态
+func (m *Monitor) metricsSample() metrics.ScalingSample {
+	s := m.Node()
+	return metrics.ScalingSample{
+		Connections:         s.Connections,
+		Capacity:            s.Capacity,
+		CurrentCapacity:     s.CurrentCapacity,
+		UpstreamPerSecond:   s.MessagesPerSecond.Upstream,
+		DownstreamPerSecond: s.MessagesPerSecond.Downstream,
+		Ready:               s.Ready,
+	}
+}
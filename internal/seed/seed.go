@@ -0,0 +1,249 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/rooms"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/jwt"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/samber/do/v2"
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultFixturePath 默认的种子数据文件路径
+	DefaultFixturePath = "configs/seed.yaml"
+	// defaultTokenTTL 未配置有效期时签发的示例 token 默认有效 24 小时
+	defaultTokenTTL = 24 * time.Hour
+	// redisTimeout 写入演示房间存档的超时时间
+	redisTimeout = 5 * time.Second
+)
+
+var (
+	ErrEmptyFixture    = errors.New("种子数据为空")
+	ErrInvalidRoomName = errors.New("演示房间名称不能为空且不能超过128个字符")
+)
+
+// Fixture 演示数据定义，描述需要准备的租户和用户
+type Fixture struct {
+	TokenTTL int64    `yaml:"tokenTTL" mapstructure:"tokenTTL"` // 示例 token 有效期 (纳秒)
+	Tenants  []Tenant `yaml:"tenants" mapstructure:"tenants"`
+}
+
+// Tenant 演示租户
+type Tenant struct {
+	BizID   int64   `yaml:"bizId" mapstructure:"bizId"`
+	Name    string  `yaml:"name" mapstructure:"name"`
+	UserIDs []int64 `yaml:"userIds" mapstructure:"userIds"`
+	Rooms   []Room  `yaml:"rooms" mapstructure:"rooms"`
+}
+
+// Room 演示房间
+// 房间成员关系随连接存在，无法在连接建立前写入；种子模式预先把 Messages 写入房间的存档，
+// 演示用户连接后通过 ROOM_JOIN 加入房间，再通过 ROOM_HISTORY 就能看到这些消息
+type Room struct {
+	Name     string   `yaml:"name" mapstructure:"name"`
+	Messages []string `yaml:"messages" mapstructure:"messages"` // 预置的房间消息，按顺序写入存档
+}
+
+// LoadFixture 从 YAML 文件加载种子数据
+func LoadFixture(path string) (Fixture, error) {
+	if path == "" {
+		path = DefaultFixturePath
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return Fixture{}, fmt.Errorf("failed to read seed file: %w", err)
+	}
+	var f Fixture
+	if err := v.Unmarshal(&f); err != nil {
+		return Fixture{}, fmt.Errorf("failed to unmarshal seed file: %w", err)
+	}
+	if len(f.Tenants) == 0 {
+		return Fixture{}, ErrEmptyFixture
+	}
+	return f, nil
+}
+
+// Credential 为单个演示用户签发的凭证
+type Credential struct {
+	BizID     int64
+	Tenant    string
+	UserID    int64
+	Token     string
+	URL       string   // 建立 WebSocket 连接的地址
+	Header    string   // 禁用查询参数令牌时握手需要携带的请求头，为空时令牌已经包含在 URL 中
+	Rooms     []string // 租户的演示房间，连接后可以加入
+	ExpiresAt time.Time
+}
+
+// Seeder 演示数据播种器
+// 负责按照 Fixture 为演示租户签发示例 token 并预置演示房间，让使用者在克隆仓库后可以直接连接网关体验功能
+type Seeder struct {
+	token     *jwt.UserToken
+	rooms     *rooms.Rooms
+	wsConfig  config.WebsocketConfig
+	jwtConfig config.JWTConfig
+	logger    *log.Logger
+}
+
+func NewSeeder(i do.Injector) (*Seeder, error) {
+	token, err := do.Invoke[*jwt.UserToken](i)
+	if err != nil {
+		return nil, err
+	}
+	serverConfig, err := do.Invoke[config.ServerConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	jwtConfig, err := do.Invoke[config.JWTConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	roomSet, err := do.Invoke[*rooms.Rooms](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Seeder{
+		token:     token,
+		rooms:     roomSet,
+		wsConfig:  serverConfig.Websocket,
+		jwtConfig: jwtConfig,
+		logger:    logger,
+	}, nil
+}
+
+// Run 按照种子数据签发示例凭证并预置演示房间
+func (s *Seeder) Run(f Fixture) ([]Credential, error) {
+	ttl := time.Duration(f.TokenTTL)
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	creds := make([]Credential, 0)
+	for _, tenant := range f.Tenants {
+		roomNames, err := s.seedRooms(tenant)
+		if err != nil {
+			return nil, err
+		}
+		for _, userID := range tenant.UserIDs {
+			token, err := s.token.Encode(jwt.UserClaims{
+				UserID: userID,
+				BizID:  tenant.BizID,
+				RegisteredClaims: jwtv5.RegisteredClaims{
+					Issuer:    s.jwtConfig.Issuer,
+					IssuedAt:  jwtv5.NewNumericDate(now),
+					ExpiresAt: jwtv5.NewNumericDate(expiresAt),
+				},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("签发示例token失败 bizId=%d userId=%d: %w", tenant.BizID, userID, err)
+			}
+			url, header := s.connectInfo(token)
+			creds = append(creds, Credential{
+				BizID:     tenant.BizID,
+				Tenant:    tenant.Name,
+				UserID:    userID,
+				Token:     token,
+				URL:       url,
+				Header:    header,
+				Rooms:     roomNames,
+				ExpiresAt: expiresAt,
+			})
+		}
+		s.logger.Info("演示租户已准备", slog.Int64("bizId", tenant.BizID), slog.String("name", tenant.Name), slog.Int("users", len(tenant.UserIDs)))
+	}
+	return creds, nil
+}
+
+// connectInfo 按照网关的 TLS 和令牌配置生成连接地址
+// 禁用查询参数令牌时令牌改由请求头携带：配置了子协议约定时给出浏览器可用的子协议形式，否则给出 Authorization 请求头
+func (s *Seeder) connectInfo(token string) (url, header string) {
+	scheme := "ws"
+	if s.wsConfig.TLS.Enabled {
+		scheme = "wss"
+	}
+	host := s.wsConfig.Host
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		// 监听所有网卡时 0.0.0.0 不能作为连接地址，本机演示使用 localhost
+		host = "localhost"
+	}
+	url = fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(host, strconv.Itoa(s.wsConfig.Port)))
+	switch {
+	case !s.wsConfig.Token.DisableQuery:
+		url += "?token=" + token
+	case s.wsConfig.Token.SubprotocolMarker != "":
+		header = fmt.Sprintf("Sec-WebSocket-Protocol: %s, %s", s.wsConfig.Token.SubprotocolMarker, token)
+	default:
+		header = "Authorization: Bearer " + token
+	}
+	return url, header
+}
+
+// seedRooms 把演示房间的预置消息写入房间存档，返回租户的演示房间名称
+// 未启用房间或业务方未启用存档时只记录警告，房间仍然可以在连接后加入
+func (s *Seeder) seedRooms(tenant Tenant) ([]string, error) {
+	if len(tenant.Rooms) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(tenant.Rooms))
+	for _, room := range tenant.Rooms {
+		if !rooms.ValidName(room.Name) {
+			return nil, fmt.Errorf("%w: bizId=%d room=%q", ErrInvalidRoomName, tenant.BizID, room.Name)
+		}
+		names = append(names, room.Name)
+	}
+	if !s.rooms.Enabled() {
+		s.logger.Warn("未启用房间 (rooms.enabled)，演示房间不可用", slog.Int64("bizId", tenant.BizID))
+		return nil, nil
+	}
+	if !s.rooms.Archived(tenant.BizID) {
+		s.logger.Warn("业务方未启用房间存档 (rooms.archive)，不预置房间消息", slog.Int64("bizId", tenant.BizID))
+		return names, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	for _, room := range tenant.Rooms {
+		for _, body := range room.Messages {
+			msg := &gatewayapiv1.PushMessage{Key: uuid.NewString(), BizId: tenant.BizID, Body: []byte(body)}
+			if err := s.rooms.Archive(ctx, msg, room.Name); err != nil {
+				return nil, fmt.Errorf("写入演示房间消息失败 bizId=%d room=%s: %w", tenant.BizID, room.Name, err)
+			}
+		}
+		s.logger.Info("演示房间已准备", slog.Int64("bizId", tenant.BizID), slog.String("room", room.Name), slog.Int("messages", len(room.Messages)))
+	}
+	return names, nil
+}
+
+// Print 以人类可读的方式输出演示凭证
+func Print(w io.Writer, creds []Credential) {
+	for _, c := range creds {
+		fmt.Fprintf(w, "[%s] bizId=%d userId=%d expiresAt=%s\n  %s\n",
+			c.Tenant, c.BizID, c.UserID, c.ExpiresAt.Format(time.RFC3339), c.URL)
+		if c.Header != "" {
+			fmt.Fprintf(w, "  %s\n", c.Header)
+		}
+		if len(c.Rooms) > 0 {
+			fmt.Fprintf(w, "  rooms: %s\n", strings.Join(c.Rooms, ", "))
+		}
+	}
+}
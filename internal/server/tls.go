@@ -0,0 +1,212 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+)
+
+// defaultTLSWatchInterval 检查证书文件变化的默认间隔
+const defaultTLSWatchInterval = 10 * time.Second
+
+var ErrNoClientCA = errors.New("客户端 CA 文件中没有可用的证书")
+
+// tlsReloader 持有当前生效的 TLS 配置，证书文件变化或收到 SIGHUP 时重新加载
+//
+// 每次握手通过 GetConfigForClient 取当前配置，重新加载只影响之后的握手，已建立的连接不受影响。
+// 重新加载失败时继续使用原配置，证书轮换时不会因为文件只写了一半而中断服务
+type tlsReloader struct {
+	cfg      config.TLSConfig
+	interval time.Duration
+	logger   *log.Logger
+
+	current atomic.Pointer[tls.Config]
+	mu      sync.Mutex // 串行化重新加载
+	stamps  map[string]fileStamp
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// fileStamp 用于判断文件是否变化，Kubernetes 更新 Secret 时替换符号链接，Stat 跟随链接能看到新文件
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// newTLSReloader 加载证书并返回 reloader，证书无效时返回错误，避免带着错误的配置启动
+func newTLSReloader(cfg config.TLSConfig, logger *log.Logger) (*tlsReloader, error) {
+	r := &tlsReloader{
+		cfg:      cfg,
+		interval: time.Duration(cfg.WatchInterval),
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if r.interval <= 0 {
+		r.interval = defaultTLSWatchInterval
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config 返回监听器使用的 TLS 配置
+func (r *tlsReloader) Config() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// files 返回需要监视的文件
+func (r *tlsReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+// reload 重新读取证书文件并替换当前配置
+func (r *tlsReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamps := r.stat()
+	c, err := r.build()
+	if err != nil {
+		return err
+	}
+	r.current.Store(c)
+	r.stamps = stamps
+	return nil
+}
+
+// build 按配置构建 TLS 配置
+func (r *tlsReloader) build() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载 TLS 证书失败: %w", err)
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   r.cfg.ALPN,
+	}
+	if r.cfg.MinVersion == "1.3" {
+		c.MinVersion = tls.VersionTLS13
+	}
+	if len(c.NextProtos) == 0 {
+		c.NextProtos = []string{"http/1.1"}
+	}
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端 CA 失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrNoClientCA, r.cfg.ClientCAFile)
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	switch r.cfg.ClientAuth {
+	case "none":
+		c.ClientAuth = tls.NoClientCert
+	case "request":
+		c.ClientAuth = tls.RequestClientCert
+	case "verifyIfGiven":
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
+
+// stat 返回证书文件的当前状态，无法读取的文件不计入
+func (r *tlsReloader) stat() map[string]fileStamp {
+	stamps := make(map[string]fileStamp, 3)
+	for _, f := range r.files() {
+		if fi, err := os.Stat(f); err == nil {
+			stamps[f] = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+		}
+	}
+	return stamps
+}
+
+// changed 返回证书文件自上次加载以来是否变化
+func (r *tlsReloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamps := r.stat()
+	if len(stamps) != len(r.stamps) {
+		return true
+	}
+	for f, s := range stamps {
+		if r.stamps[f] != s {
+			return true
+		}
+	}
+	return false
+}
+
+// Start 在后台监视 SIGHUP 和证书文件变化，重复调用无效
+func (r *tlsReloader) Start() {
+	if !r.started.CompareAndSwap(false, true) {
+		return
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer close(r.done)
+		defer signal.Stop(hup)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-hup:
+				r.reloadAndLog("SIGHUP")
+			case <-ticker.C:
+				if r.changed() {
+					r.reloadAndLog("证书文件变化")
+				}
+			}
+		}
+	}()
+}
+
+func (r *tlsReloader) reloadAndLog(trigger string) {
+	if err := r.reload(); err != nil {
+		r.logger.Error("重新加载 TLS 证书失败，继续使用原证书", slog.String("trigger", trigger), slog.Any("error", err))
+		return
+	}
+	r.logger.Info("已重新加载 TLS 证书", slog.String("trigger", trigger), slog.String("certFile", r.cfg.CertFile))
+}
+
+// Shutdown 停止监视，可以重复调用
+func (r *tlsReloader) Shutdown() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		if r.started.Load() {
+			<-r.done
+		}
+	})
+}
@@ -0,0 +1,370 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/abuse"
+	"github.com/YaoAzure/wsgateway/internal/admission"
+	"github.com/YaoAzure/wsgateway/internal/enrich"
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/internal/limiter"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/upgrader"
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+)
+
+// handshakeTimeout WebSocket 握手的超时时间，防止客户端建立TCP连接后迟迟不发送升级请求占用令牌
+const handshakeTimeout = 5 * time.Second
+
+var ErrServerStarted = errors.New("WebSocket 服务已启动")
+
+// WebsocketServer WebSocket 接入服务
+//
+// 直接监听TCP端口接收原始连接，启用 TLS 时由网关终止 TLS，每个连接：
+//  1. 先按客户端IP限制握手速率 (abuse.HandshakeThrottle)，再检查IP是否被封禁，超过限制或被封禁时直接返回 429，不占用连接令牌
+//  2. 再经过 admission.Controller 准入（摘流状态、内存预算、连接令牌），令牌耗尽时短暂排队，
+//     被拒绝时返回 503 并建议客户端稍后重试
+//  3. 通过 Upgrader 完成握手、认证、业务方配额检查、压缩协商和会话创建，被封禁的用户在认证后创建会话前被拒绝
+//  4. 在后台通过 enrich.Pipeline 补充会话数据，同时交给 link.Manager 管理，直到连接关闭后归还令牌
+type WebsocketServer struct {
+	addr      string
+	abuse     *abuse.Guard
+	throttle  *abuse.HandshakeThrottle
+	admission *admission.Controller
+	enrich    *enrich.Pipeline
+	upgrader  *upgrader.Upgrader
+	limiter   *limiter.TokenLimiter
+	links     *link.Manager
+	reaper    *link.Reaper
+	checker   *link.SessionChecker
+	backoff   *backoff.Policies
+	tls       *tlsReloader // 未启用 TLS 时为 nil
+	incidents *incident.Recorder
+	logger    *log.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+func NewWebsocketServer(i do.Injector) (*WebsocketServer, error) {
+	cfg, err := do.Invoke[config.ServerConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	guard, err := do.Invoke[*abuse.Guard](i)
+	if err != nil {
+		return nil, err
+	}
+	throttle, err := do.Invoke[*abuse.HandshakeThrottle](i)
+	if err != nil {
+		return nil, err
+	}
+	u, err := do.Invoke[*upgrader.Upgrader](i)
+	if err != nil {
+		return nil, err
+	}
+	controller, err := do.Invoke[*admission.Controller](i)
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := do.Invoke[*enrich.Pipeline](i)
+	if err != nil {
+		return nil, err
+	}
+	l, err := do.Invoke[*limiter.TokenLimiter](i)
+	if err != nil {
+		return nil, err
+	}
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	reaper, err := do.Invoke[*link.Reaper](i)
+	if err != nil {
+		return nil, err
+	}
+	checker, err := do.Invoke[*link.SessionChecker](i)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := do.Invoke[*backoff.Policies](i)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := do.Invoke[*incident.Recorder](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	var reloader *tlsReloader
+	if cfg.Websocket.TLS.Enabled {
+		if reloader, err = newTLSReloader(cfg.Websocket.TLS, logger); err != nil {
+			return nil, err
+		}
+	}
+	return &WebsocketServer{
+		addr:      net.JoinHostPort(cfg.Websocket.Host, strconv.Itoa(cfg.Websocket.Port)),
+		abuse:     guard,
+		throttle:  throttle,
+		admission: controller,
+		enrich:    pipeline,
+		upgrader:  u,
+		limiter:   l,
+		links:     links,
+		reaper:    reaper,
+		checker:   checker,
+		backoff:   policies,
+		tls:       reloader,
+		incidents: incidents,
+		logger:    logger,
+	}, nil
+}
+
+// Addr 返回实际监听的地址，未启动时返回配置的地址
+func (s *WebsocketServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Start 开始监听并在后台接收连接
+func (s *WebsocketServer) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return ErrServerStarted
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("监听 %s 失败: %w", s.addr, err)
+	}
+	if s.tls != nil {
+		// TLS 握手在升级时第一次读写连接时进行，受握手超时约束
+		ln = tls.NewListener(ln, s.tls.Config())
+		s.tls.Start()
+	}
+	s.listener = ln
+
+	// 令牌桶从初始容量逐步扩容，避免刚启动的节点被重连风暴打满
+	go s.limiter.StartRampUp(context.Background())
+	s.reaper.Start()
+	s.checker.Start()
+	s.wg.Add(1)
+	go s.acceptLoop(ln)
+	return nil
+}
+
+// Drain 优雅停机：停止接收新连接，以摘流退避建议关闭所有连接并等待其关闭
+// 连接会先发送完缓冲区中的消息再关闭；ctx 结束时仍未关闭的连接会被强制关闭
+func (s *WebsocketServer) Drain(ctx context.Context) error {
+	if err := s.closeListener(); err != nil {
+		return err
+	}
+	s.links.Drain(s.backoff.Advice(backoff.ReasonDrain))
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		remaining := s.links.Count()
+		s.links.CloseAll()
+		<-done
+		return fmt.Errorf("停机宽限期内仍有 %d 个连接未关闭: %w", remaining, ctx.Err())
+	}
+}
+
+// Shutdown 停止接收新连接并立即关闭所有已建立的连接
+// 正常停机应先调用 Drain，Shutdown 作为容器销毁时的兜底
+func (s *WebsocketServer) Shutdown() error {
+	err := s.closeListener()
+	if s.tls != nil {
+		s.tls.Shutdown()
+	}
+	s.reaper.Shutdown()
+	s.checker.Shutdown()
+	s.links.CloseAll()
+	s.wg.Wait()
+	_ = s.limiter.Close()
+	return err
+}
+
+// closeListener 关闭监听器，可以重复调用
+func (s *WebsocketServer) closeListener() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	if err := s.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *WebsocketServer) acceptLoop(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Error("接收连接失败", slog.Any("error", err))
+			// 文件描述符耗尽等临时错误，稍后重试，避免空转
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle 处理单个连接的完整生命周期，持有的令牌在连接关闭后归还
+// 握手和连接管理过程中发生 panic 时记录事故并关闭连接，单个连接的问题不会导致整个节点崩溃
+func (s *WebsocketServer) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.incidents.Recover("server.handle", nil, func() { _ = conn.Close() })
+	ip := remoteIP(conn)
+	if ok, retryAfter, entered := s.throttle.Allow(ip); !ok {
+		s.rejectHTTP(conn, http.StatusTooManyRequests, retryAfter)
+		if entered {
+			s.logger.Warn("客户端握手过于频繁，拒绝握手", slog.String("ip", ip))
+			s.report(ip, abuse.SignalRateLimit)
+		}
+		return
+	}
+	if remaining := s.banned(abuse.IPSubject(ip)); remaining > 0 {
+		s.rejectHTTP(conn, http.StatusTooManyRequests, remaining)
+		return
+	}
+	if !s.admit(conn) {
+		return
+	}
+	// 令牌在连接关闭时立即归还，握手失败等提前返回的情况由 defer 归还，OnceFunc 保证只归还一次
+	release := sync.OnceFunc(s.admission.Release)
+	defer release()
+
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	ss, hc, err := s.upgrader.Upgrade(conn)
+	if err != nil {
+		s.logger.Debug("WebSocket 升级失败", slog.String("remoteAddr", conn.RemoteAddr().String()), slog.Any("error", err))
+		_ = conn.Close()
+		if errors.Is(err, upgrader.ErrInvalidUserToken) {
+			s.report(ip, abuse.SignalAuthFailure)
+		}
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	// 101 已经返回，会话的其余数据在后台补充
+	s.enrich.Enrich(ss, hc)
+	s.links.Serve(conn, ss, hc, release)
+}
+
+// admit 申请连接准入，令牌耗尽时按决策阻塞等待令牌，被拒绝时以HTTP状态码和 Retry-After 拒绝连接
+func (s *WebsocketServer) admit(conn net.Conn) bool {
+	req := admission.Request{Stage: admission.StageConnect}
+	for {
+		d := s.admission.Admit(req)
+		switch d.Action {
+		case admission.Accept:
+			return true
+		case admission.Queue:
+			ctx, cancel := context.WithTimeout(context.Background(), d.Wait)
+			acquired := s.admission.Await(ctx)
+			cancel()
+			if acquired {
+				return true
+			}
+			req.Waited += d.Wait
+		default:
+			s.reject(conn, d)
+			return false
+		}
+	}
+}
+
+// banned 返回客户端剩余的封禁时长，查询失败时放行
+func (s *WebsocketServer) banned(subject string) time.Duration {
+	if !s.abuse.Enabled() {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	remaining, err := s.abuse.Banned(ctx, subject)
+	if err != nil {
+		s.logger.Warn("查询封禁状态失败", slog.String("subject", subject), slog.Any("error", err))
+		return 0
+	}
+	return remaining
+}
+
+// report 上报握手阶段按客户端IP统计的滥用信号（认证失败、握手过于频繁）
+func (s *WebsocketServer) report(ip string, signal abuse.Signal) {
+	if !s.abuse.Enabled() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	verdict, err := s.abuse.Report(ctx, abuse.IPSubject(ip), signal)
+	if err != nil {
+		s.logger.Warn("上报滥用信号失败", slog.String("ip", ip), slog.String("signal", string(signal)), slog.Any("error", err))
+		return
+	}
+	if verdict.Banned {
+		s.logger.Warn("客户端滥用分达到阈值，已封禁", slog.String("ip", ip), slog.String("signal", string(signal)), slog.Duration("banFor", verdict.BanFor))
+	}
+}
+
+// reject 按准入决策拒绝连接
+func (s *WebsocketServer) reject(conn net.Conn, d admission.Decision) {
+	s.rejectHTTP(conn, d.Status(), d.RetryAfter())
+	s.logger.Warn("新连接未通过准入，拒绝连接",
+		slog.String("remoteAddr", conn.RemoteAddr().String()),
+		slog.String("cause", string(d.Cause)),
+		slog.Int64("capacity", s.limiter.CurrentCapacity()))
+}
+
+// rejectHTTP 在握手前拒绝连接
+// 此时还未完成握手，无法发送关闭帧，只能以HTTP状态码和 Retry-After 建议客户端退避
+func (s *WebsocketServer) rejectHTTP(conn net.Conn, status int, retryAfter time.Duration) {
+	defer conn.Close()
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	_ = conn.SetWriteDeadline(time.Now().Add(handshakeTimeout))
+	_, _ = fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nRetry-After: %d\r\nContent-Length: 0\r\nConnection: close\r\n\r\n",
+		status, http.StatusText(status), seconds)
+}
+
+// remoteIP 返回客户端IP，不含端口
+func remoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,73 @@
+// Package subsystem 提供在运行时单独停止和恢复网关子系统的开关
+//
+// 局部故障时运维可以只停掉出问题的一环（例如业务后端故障时暂停上行转发），
+// 而不必重启进程断开所有连接。停止只是暂停处理，子系统的协程和订阅保持不变，恢复后立即生效。
+package subsystem
+
+import (
+	"sync"
+	"time"
+)
+
+// Status 子系统的运行状态
+type Status struct {
+	Name    string         `json:"name"`
+	Running bool           `json:"running"`
+	Since   time.Time      `json:"since"`            // 最近一次停止或恢复的时间，从未切换过时为创建时间
+	Detail  map[string]any `json:"detail,omitempty"` // 子系统特有的状态，例如暂停期间缓存或丢弃的消息数
+}
+
+// Controllable 可以在运行时停止和恢复的子系统
+// Pause 和 Resume 返回状态是否发生了变化，重复调用无效
+type Controllable interface {
+	Pause() bool
+	Resume() bool
+	Status() Status
+}
+
+// Toggle 子系统的运行开关，零值表示运行中，并发安全
+type Toggle struct {
+	mu     sync.RWMutex
+	paused bool
+	since  time.Time
+}
+
+// NewToggle 创建一个处于运行状态的开关
+func NewToggle() *Toggle {
+	return &Toggle{since: time.Now()}
+}
+
+// Pause 停止子系统，返回状态是否发生了变化
+func (t *Toggle) Pause() bool {
+	return t.set(true)
+}
+
+// Resume 恢复子系统，返回状态是否发生了变化
+func (t *Toggle) Resume() bool {
+	return t.set(false)
+}
+
+func (t *Toggle) set(paused bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.paused == paused {
+		return false
+	}
+	t.paused = paused
+	t.since = time.Now()
+	return true
+}
+
+// Paused 返回子系统是否已停止
+func (t *Toggle) Paused() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.paused
+}
+
+// Status 返回开关的状态，detail 为子系统特有的状态
+func (t *Toggle) Status(name string, detail map[string]any) Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return Status{Name: name, Running: !t.paused, Since: t.since, Detail: detail}
+}
@@ -0,0 +1,228 @@
+package uniques
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// keyFormat 去重用户统计在Redis中的存储键格式，最后一段为时间窗口的起点（UTC）
+const keyFormat = "gateway:uniques:bizId:%d:%s:%s"
+
+const (
+	// maxBuckets 一次查询最多覆盖的时间窗口数，约一个月的小时数
+	maxBuckets = 31 * 24
+	// collectTimeout 指标抓取时查询Redis的超时时间
+	collectTimeout = time.Second
+)
+
+var (
+	ErrDisabled      = errors.New("未启用去重用户统计")
+	ErrUnknownPeriod = errors.New("未知的统计周期")
+	ErrRangeTooLarge = errors.New("查询的时间范围过大")
+)
+
+// Period 统计的时间窗口
+type Period string
+
+const (
+	PeriodHour Period = "hour"
+	PeriodDay  Period = "day"
+)
+
+// ParsePeriod 解析统计周期
+func ParsePeriod(s string) (Period, error) {
+	switch p := Period(s); p {
+	case PeriodHour, PeriodDay:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownPeriod, s)
+	}
+}
+
+// Truncate 返回 t 所在时间窗口的起点（UTC）
+func (p Period) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	if p == PeriodDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// next 返回下一个时间窗口的起点
+func (p Period) next(t time.Time) time.Time {
+	if p == PeriodDay {
+		return t.AddDate(0, 0, 1)
+	}
+	return t.Add(time.Hour)
+}
+
+// label 时间窗口在存储键中的标识
+func (p Period) label(t time.Time) string {
+	if p == PeriodDay {
+		return t.Format("20060102")
+	}
+	return t.Format("2006010215")
+}
+
+// Bucket 一个时间窗口内的去重用户数
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// Report 一段时间内的去重用户统计
+type Report struct {
+	BizID   int64    `json:"bizId"`
+	Period  Period   `json:"period"`
+	Buckets []Bucket `json:"buckets"`
+	Total   int64    `json:"total"` // 整个时间范围内的去重用户数，不等于各窗口之和
+}
+
+// Counter 按业务方统计去重用户数
+// 用于按活跃用户而不是按连接数计费和监控，连接数会被重连放大
+type Counter interface {
+	// Add 记录用户在 at 时刻建立了连接
+	Add(ctx context.Context, bizID, userID int64, at time.Time) error
+	// Count 统计 [from, to] 覆盖的各个时间窗口内的去重用户数
+	Count(ctx context.Context, bizID int64, period Period, from, to time.Time) (Report, error)
+}
+
+// NewCounter 按配置创建去重用户计数器，未启用时返回不做任何事的实现
+func NewCounter(i do.Injector) (Counter, error) {
+	cfg, err := do.Invoke[config.UniquesConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return disabledCounter{}, nil
+	}
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	m, err := do.Invoke[*metrics.UniqueUserMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	c := &RedisCounter{
+		rdb: rdb,
+		retention: map[Period]time.Duration{
+			PeriodHour: time.Duration(cfg.HourlyRetention),
+			PeriodDay:  time.Duration(cfg.DailyRetention),
+		},
+	}
+	m.SetSource(c.collect)
+	return c, nil
+}
+
+// RedisCounter 基于 Redis HyperLogLog 的去重用户计数器
+// 每个业务方每个时间窗口一个 HyperLogLog，固定约 12KB，误差约 0.81%，全集群共享
+type RedisCounter struct {
+	rdb       redis.Cmdable
+	retention map[Period]time.Duration
+
+	// bizIDs 本节点上出现过的业务方，指标抓取时只查询这些业务方
+	bizIDs sync.Map
+}
+
+func (c *RedisCounter) Add(ctx context.Context, bizID, userID int64, at time.Time) error {
+	c.bizIDs.Store(bizID, struct{}{})
+	member := strconv.FormatInt(userID, 10)
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, p := range []Period{PeriodHour, PeriodDay} {
+			key := c.key(bizID, p, p.Truncate(at))
+			pipe.PFAdd(ctx, key, member)
+			if ttl := c.retention[p]; ttl > 0 {
+				pipe.Expire(ctx, key, ttl)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *RedisCounter) Count(ctx context.Context, bizID int64, period Period, from, to time.Time) (Report, error) {
+	var keys []string
+	report := Report{BizID: bizID, Period: period}
+	for t := period.Truncate(from); !t.After(to); t = period.next(t) {
+		if len(keys) >= maxBuckets {
+			return Report{}, fmt.Errorf("%w: 最多 %d 个时间窗口", ErrRangeTooLarge, maxBuckets)
+		}
+		keys = append(keys, c.key(bizID, period, t))
+		report.Buckets = append(report.Buckets, Bucket{Start: t})
+	}
+	if len(keys) == 0 {
+		return report, nil
+	}
+
+	counts := make([]*redis.IntCmd, len(keys))
+	var total *redis.IntCmd
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			counts[i] = pipe.PFCount(ctx, key)
+		}
+		// 多个 HyperLogLog 的 PFCOUNT 返回并集的基数
+		total = pipe.PFCount(ctx, keys...)
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	for i, cmd := range counts {
+		report.Buckets[i].Count = cmd.Val()
+	}
+	report.Total = total.Val()
+	return report, nil
+}
+
+// collect 查询本节点上出现过的业务方在当前小时和当天的去重用户数，供指标抓取使用
+func (c *RedisCounter) collect(yield func(bizID int64, period string, count int64)) {
+	type query struct {
+		bizID  int64
+		period Period
+		cmd    *redis.IntCmd
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	now := time.Now()
+	var queries []query
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		c.bizIDs.Range(func(k, _ any) bool {
+			bizID := k.(int64)
+			for _, p := range []Period{PeriodHour, PeriodDay} {
+				queries = append(queries, query{bizID: bizID, period: p, cmd: pipe.PFCount(ctx, c.key(bizID, p, p.Truncate(now)))})
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		// 抓取时Redis不可用，本次不导出该指标
+		return
+	}
+	for _, q := range queries {
+		yield(q.bizID, string(q.period), q.cmd.Val())
+	}
+}
+
+func (c *RedisCounter) key(bizID int64, period Period, start time.Time) string {
+	return fmt.Sprintf(keyFormat, bizID, period, period.label(start))
+}
+
+// disabledCounter 未启用去重用户统计时使用
+type disabledCounter struct{}
+
+func (disabledCounter) Add(context.Context, int64, int64, time.Time) error { return nil }
+
+func (disabledCounter) Count(context.Context, int64, Period, time.Time, time.Time) (Report, error) {
+	return Report{}, ErrDisabled
+}
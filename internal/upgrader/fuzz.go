@@ -0,0 +1,114 @@
+//go:build gofuzz
+
+package upgrader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+
+	"github.com/YaoAzure/wsgateway/internal/guest"
+	"github.com/YaoAzure/wsgateway/internal/revocation"
+	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/jwt"
+	"github.com/YaoAzure/wsgateway/pkg/message"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/samber/do/v2"
+)
+
+// 以下函数供 go-fuzz 使用，种子语料位于 testdata/fuzz/<函数名>，例如：
+//
+//	go-fuzz-build -func FuzzGetUserInfo ./internal/upgrader
+//	go-fuzz -bin upgrader-fuzz.zip -func FuzzGetUserInfo -workdir internal/upgrader/testdata/fuzz/FuzzGetUserInfo
+//
+// 握手请求来自公网，任意 URI 和扩展协商头都不能导致 panic 或阻塞。
+// 语料中的令牌使用 fuzzKey 签名，过期时间为 2100 年。
+
+const fuzzKey = "gofuzz"
+
+var fuzzUpgrader = newFuzzUpgrader()
+
+func newFuzzUpgrader() *Upgrader {
+	i := do.New()
+	do.ProvideValue(i, config.JWTConfig{Key: fuzzKey})
+	do.ProvideValue(i, config.MessageConfig{})
+	do.ProvideValue(i, config.GuestConfig{Policies: []config.GuestPolicyConfig{{BizID: 1}}})
+	do.Provide(i, jwt.NewToken)
+	do.Provide(i, jwt.NewUserToken)
+	do.Provide(i, message.NewNegotiator)
+	do.Provide(i, guest.NewPolicies)
+	return &Upgrader{
+		token:      do.MustInvoke[*jwt.UserToken](i),
+		codecs:     do.MustInvoke[*message.Negotiator](i),
+		guests:     do.MustInvoke[*guest.Policies](i),
+		revocation: revocation.Disabled{},
+		compressionConfig: compression.Config{
+			Enabled:         true,
+			ServerMaxWindow: 15,
+			ClientMaxWindow: 15,
+			Level:           6,
+		},
+	}
+}
+
+// FuzzGetUserInfo 以任意字符串作为握手请求的 URI 解析用户信息
+func FuzzGetUserInfo(data []byte) int {
+	var token string
+	if uu, err := url.Parse(string(data)); err == nil {
+		token = uu.Query().Get("token")
+	}
+	info, err := fuzzUpgrader.getUserInfo(context.Background(), string(data), token)
+	if err != nil {
+		return 0
+	}
+	if len(info.DeviceID) > maxDeviceIDLength {
+		panic("设备ID超过长度限制")
+	}
+	if _, ok := message.Lookup(info.Codec); !ok {
+		panic("协商出未注册的编解码器: " + info.Codec)
+	}
+	return 1
+}
+
+// fuzzHandshakeHead 除扩展协商头外的握手请求
+const fuzzHandshakeHead = "GET /ws HTTP/1.1\r\n" +
+	"Host: gateway\r\n" +
+	"Upgrade: websocket\r\n" +
+	"Connection: Upgrade\r\n" +
+	"Sec-WebSocket-Version: 13\r\n" +
+	"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+	"Sec-WebSocket-Extensions: "
+
+// FuzzNegotiate 以任意字节作为 Sec-WebSocket-Extensions 头完成一次握手，覆盖压缩参数协商
+// 协商成功时，客户端要求的服务端窗口不能超过配置的上限
+func FuzzNegotiate(data []byte) int {
+	ext := fuzzUpgrader.compressionExtension()
+	upgrader := ws.Upgrader{
+		Negotiate: func(opt httphead.Option) (httphead.Option, error) {
+			return ext.Negotiate(opt)
+		},
+	}
+	var req bytes.Buffer
+	req.WriteString(fuzzHandshakeHead)
+	req.Write(data)
+	req.WriteString("\r\n\r\n")
+	conn := struct {
+		io.Reader
+		io.Writer
+	}{bufio.NewReader(&req), io.Discard}
+	if _, err := upgrader.Upgrade(conn); err != nil {
+		return 0
+	}
+	params, accepted := ext.Accepted()
+	if !accepted {
+		return 0
+	}
+	if params.ServerMaxWindowBits > ext.Parameters.ServerMaxWindowBits {
+		panic("协商出的服务端窗口超出配置的上限")
+	}
+	return 1
+}
@@ -0,0 +1,116 @@
+package upgrader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// lockKeyFormat 集群握手锁在Redis中的存储键格式
+	lockKeyFormat = "gateway:upgrade:lock:bizId:%d:userId:%d:deviceId:%s"
+	// defaultLockTTL 未配置时集群握手锁的过期时间，持有锁的节点崩溃时锁最多残留这么久
+	defaultLockTTL = 5 * time.Second
+)
+
+var ErrAlreadyConnecting = errors.New("用户正在建立连接")
+
+// luaUnlock 只删除自己持有的锁，避免锁过期后误删其它握手重新获取的锁
+var luaUnlock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Scripts 返回握手使用的 Lua 脚本，启动预热时提前加载到 Redis
+func Scripts() []*redis.Script {
+	return []*redis.Script{luaUnlock}
+}
+
+// upgradeLock 同一用户同一设备的握手互斥锁
+//
+// 客户端快速重试时，同一用户的多个握手可能同时进入会话创建流程并相互竞争。
+// 握手在创建会话前获取用户设备维度的锁 (不同设备之间由多连接策略处理)：先在本节点内去重，启用集群锁时再通过 Redis SET NX 在节点间去重，
+// 获取失败的握手以 409 拒绝，客户端稍后重试时前一个握手已经完成。
+type upgradeLock struct {
+	rdb     redis.Cmdable // 未启用集群锁时为 nil
+	ttl     time.Duration
+	mu      sync.Mutex
+	pending map[lockKey]struct{}
+}
+
+// lockKey 握手锁的粒度，同一用户的不同设备可以同时握手
+type lockKey struct {
+	bizID    int64
+	userID   int64
+	deviceID string
+}
+
+func newUpgradeLock(cfg config.UpgradeLockConfig, rdb redis.Cmdable) *upgradeLock {
+	l := &upgradeLock{
+		ttl:     time.Duration(cfg.TTL),
+		pending: make(map[lockKey]struct{}),
+	}
+	if l.ttl <= 0 {
+		l.ttl = defaultLockTTL
+	}
+	if cfg.Cluster {
+		l.rdb = rdb
+	}
+	return l
+}
+
+// lock 获取用户设备的握手锁，已有握手在进行时返回 ErrAlreadyConnecting
+// 集群锁访问Redis失败时同时返回错误和只释放本节点锁的 unlock，由调用方决定是否放行
+func (l *upgradeLock) lock(ctx context.Context, bizID, userID int64, deviceID string) (unlock func(), err error) {
+	key := lockKey{bizID: bizID, userID: userID, deviceID: deviceID}
+	l.mu.Lock()
+	if _, ok := l.pending[key]; ok {
+		l.mu.Unlock()
+		return nil, ErrAlreadyConnecting
+	}
+	l.pending[key] = struct{}{}
+	l.mu.Unlock()
+
+	unlockLocal := func() {
+		l.mu.Lock()
+		delete(l.pending, key)
+		l.mu.Unlock()
+	}
+	if l.rdb == nil {
+		return unlockLocal, nil
+	}
+
+	redisKey := fmt.Sprintf(lockKeyFormat, bizID, userID, deviceID)
+	token := newLockToken()
+	ok, err := l.rdb.SetNX(ctx, redisKey, token, l.ttl).Result()
+	if err != nil {
+		return unlockLocal, err
+	}
+	if !ok {
+		unlockLocal()
+		return nil, ErrAlreadyConnecting
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		// 释放失败时锁在过期后自动删除
+		_ = luaUnlock.Run(ctx, l.rdb, []string{redisKey}, token).Err()
+		unlockLocal()
+	}, nil
+}
+
+// newLockToken 生成锁的持有者标识
+func newLockToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
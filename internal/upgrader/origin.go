@@ -0,0 +1,130 @@
+package upgrader
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/gobwas/httphead"
+)
+
+var (
+	ErrOriginNotAllowed       = errors.New("不允许的 Origin")        // 浏览器页面的来源不在白名单中
+	ErrMissingOrigin          = errors.New("缺少 Origin")          // 配置要求 Origin 但请求中没有
+	ErrUnsupportedSubprotocol = errors.New("不支持的 WebSocket 子协议") // 客户端请求的子协议都不在配置的列表中
+)
+
+// originPolicy Origin 白名单
+// 浏览器跨站发起的 WebSocket 握手同样会带上 Cookie，且不受同源策略限制，校验 Origin 防止其它站点的页面冒用用户身份连接。
+// 非浏览器客户端通常不发送 Origin，默认放行，rejectMissing 为 true 时拒绝。零值不做任何校验
+type originPolicy struct {
+	restricted    bool // 配置了白名单且不含 "*"
+	exact         map[string]struct{}
+	wildcards     []originWildcard
+	rejectMissing bool
+}
+
+// originWildcard 形如 https://*.example.com 的通配规则，匹配 example.com 的任意一级或多级子域名
+type originWildcard struct {
+	scheme string // 含 "://"
+	suffix string // 含前导 "."，可以带端口
+}
+
+func newOriginPolicy(cfg config.OriginConfig) originPolicy {
+	p := originPolicy{
+		restricted:    len(cfg.Allowed) > 0,
+		exact:         make(map[string]struct{}, len(cfg.Allowed)),
+		rejectMissing: cfg.RejectMissing,
+	}
+	for _, o := range cfg.Allowed {
+		o = normalizeOrigin(o)
+		if o == "*" {
+			p.restricted = false
+			break
+		}
+		if scheme, host, ok := strings.Cut(o, "://*."); ok {
+			p.wildcards = append(p.wildcards, originWildcard{scheme: scheme + "://", suffix: "." + host})
+			continue
+		}
+		p.exact[o] = struct{}{}
+	}
+	return p
+}
+
+// check 校验请求的 Origin，origin 为空表示请求中没有 Origin
+func (p originPolicy) check(origin string) error {
+	if origin == "" {
+		if p.rejectMissing {
+			return ErrMissingOrigin
+		}
+		return nil
+	}
+	if !p.restricted {
+		return nil
+	}
+	origin = normalizeOrigin(origin)
+	if _, ok := p.exact[origin]; ok {
+		return nil
+	}
+	for _, w := range p.wildcards {
+		host, ok := strings.CutPrefix(origin, w.scheme)
+		if ok && len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix) && !strings.Contains(host, "/") {
+			return nil
+		}
+	}
+	return ErrOriginNotAllowed
+}
+
+// normalizeOrigin Origin 的 scheme 和 host 不区分大小写，去掉配置中可能多写的结尾斜杠
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+// subprotocolPolicy Sec-WebSocket-Protocol 协商
+// 按客户端给出的顺序选择第一个受支持的子协议；未配置子协议时不协商，与之前的行为一致
+type subprotocolPolicy struct {
+	supported map[string]struct{}
+	required  bool
+}
+
+func newSubprotocolPolicy(cfg config.SubprotocolConfig) subprotocolPolicy {
+	p := subprotocolPolicy{
+		supported: make(map[string]struct{}, len(cfg.Supported)),
+		required:  cfg.Required,
+	}
+	for _, name := range cfg.Supported {
+		p.supported[name] = struct{}{}
+	}
+	return p
+}
+
+func (p subprotocolPolicy) enabled() bool {
+	return len(p.supported) > 0
+}
+
+// selectFrom 从一个 Sec-WebSocket-Protocol 头部中选择子协议，没有受支持的子协议时返回空字符串
+// 子协议名区分大小写；返回值是副本，在握手结束后仍然有效
+func (p subprotocolPolicy) selectFrom(header []byte) string {
+	var selected string
+	httphead.ScanTokens(header, func(token []byte) bool {
+		if _, ok := p.supported[string(token)]; ok {
+			selected = string(token)
+			return false
+		}
+		return true
+	})
+	return selected
+}
+
+// check 在所有头部解析完后校验协商结果
+// 客户端请求了子协议但都不受支持时拒绝，这种连接即使建立也无法按客户端期望的协议通信；
+// 客户端没有请求子协议时，只有 required 为 true 才拒绝
+func (p subprotocolPolicy) check(offered bool, selected string) error {
+	if !p.enabled() || selected != "" {
+		return nil
+	}
+	if offered || p.required {
+		return ErrUnsupportedSubprotocol
+	}
+	return nil
+}
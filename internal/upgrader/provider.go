@@ -0,0 +1,13 @@
+package upgrader
+
+import (
+	"github.com/YaoAzure/wsgateway/pkg/types"
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Upgrader 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(New),
+	// 握手扩展的注册表，第三方代码在服务开始接受连接前注册自定义检查
+	do.Lazy(types.NewHandshakeHooks),
+)
@@ -0,0 +1,93 @@
+package upgrader
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/types"
+	"github.com/gobwas/httphead"
+)
+
+var ErrQueryTokenDisallowed = errors.New("不允许通过查询参数携带令牌") // 配置禁用了 ?token=，令牌会被代理的访问日志记录
+
+// 令牌的来源，记录在日志中便于排查客户端的接入方式
+const (
+	tokenFromQuery       = "query"
+	tokenFromHeader      = "header"
+	tokenFromCookie      = "cookie"
+	tokenFromSubprotocol = "subprotocol"
+)
+
+// subprotocolTokenKey 在握手上下文中挂载从 Sec-WebSocket-Protocol 中取出的令牌
+type subprotocolTokenKey struct{}
+
+// tokenPolicy 握手令牌的传递方式
+//
+// 除 ?token= 查询参数外，按以下顺序查找令牌：
+//  1. Authorization: Bearer <token> 请求头，非浏览器客户端使用
+//  2. 配置的 Cookie，浏览器页面与网关同站部署时使用
+//  3. Sec-WebSocket-Protocol 子协议约定：客户端请求子协议 [<marker>, <token>]，网关选择 <marker> 作为子协议，
+//     浏览器的 WebSocket API 不能设置请求头，只能通过子协议携带令牌
+//
+// 查询参数会出现在代理和负载均衡的访问日志中，disableQuery 为 true 时拒绝携带 ?token= 的握手
+type tokenPolicy struct {
+	disableQuery bool
+	cookie       string // 为空时不从 Cookie 读取
+	marker       string // 为空时不从子协议读取
+}
+
+func newTokenPolicy(cfg config.TokenConfig) tokenPolicy {
+	return tokenPolicy{
+		disableQuery: cfg.DisableQuery,
+		cookie:       cfg.Cookie,
+		marker:       cfg.SubprotocolMarker,
+	}
+}
+
+// fromHeaders 在所有请求头解析完后按优先级查找令牌，没有找到时返回空字符串
+func (p tokenPolicy) fromHeaders(hc *types.HandshakeContext) (token, source string) {
+	if scheme, credentials, ok := strings.Cut(hc.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		if token = strings.TrimSpace(credentials); token != "" {
+			return token, tokenFromHeader
+		}
+	}
+	if p.cookie != "" {
+		r := http.Request{Header: hc.Header}
+		if c, err := r.Cookie(p.cookie); err == nil && c.Value != "" {
+			return c.Value, tokenFromCookie
+		}
+	}
+	if token, _ := hc.Value(subprotocolTokenKey{}).(string); token != "" {
+		return token, tokenFromSubprotocol
+	}
+	return "", ""
+}
+
+// extractSubprotocol 从一个 Sec-WebSocket-Protocol 头部中取出子协议约定携带的令牌，
+// 返回去掉标记和令牌后的子协议列表，只有其余的子协议参与协商和记录，令牌不会出现在日志中
+func (p tokenPolicy) extractSubprotocol(header []byte) (token string, rest []byte) {
+	if p.marker == "" {
+		return "", header
+	}
+	var (
+		protocols []string
+		next      bool
+	)
+	httphead.ScanTokens(header, func(t []byte) bool {
+		switch {
+		case next:
+			token, next = string(t), false
+		case string(t) == p.marker && token == "":
+			next = true
+		default:
+			protocols = append(protocols, string(t))
+		}
+		return true
+	})
+	if token == "" {
+		return "", header
+	}
+	return token, []byte(strings.Join(protocols, ", "))
+}
@@ -5,27 +5,57 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/YaoAzure/wsgateway/internal/abuse"
+	"github.com/YaoAzure/wsgateway/internal/admission"
+	"github.com/YaoAzure/wsgateway/internal/guest"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/revocation"
+	"github.com/YaoAzure/wsgateway/internal/webhook"
 	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/encryption"
+	"github.com/YaoAzure/wsgateway/pkg/geoip"
 	"github.com/YaoAzure/wsgateway/pkg/session"
 	"github.com/YaoAzure/wsgateway/pkg/jwt"
 	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/message"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
+	"github.com/YaoAzure/wsgateway/pkg/types"
 	"github.com/redis/go-redis/v9"
 	"github.com/samber/do/v2"
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/httphead"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
 	ErrInvalidURI       = errors.New("无效的URI")       // URI格式错误或解析失败
 	ErrInvalidUserToken = errors.New("无效的UserToken") // JWT token无效、过期或解析失败
 	ErrExistedUser      = errors.New("用户已存在")       // 用户已经建立连接，可能是重连或多端登录
+	ErrUnsupportedCodec = errors.New("不支持的消息编解码器") // 客户端请求的编解码器未注册
+	ErrInvalidDeviceID  = errors.New("无效的设备ID")     // 客户端上报的设备ID过长
+	ErrInvalidBizID     = errors.New("无效的业务ID")     // 访客握手的 bizId 参数缺失或不是正整数
+	ErrInvalidFingerprint = errors.New("无效的设备指纹") // 客户端上报的设备指纹过长
 )
 
+// maxDeviceIDLength 设备ID的最大长度，设备ID会作为会话字段名的一部分
+const maxDeviceIDLength = 128
+
+// maxFingerprintLength 设备指纹的最大长度，通常是客户端计算的摘要
+const maxFingerprintLength = 128
+
+// geoBlockedCode 地区策略拒绝握手时通过 X-Reject-Code 返回的拒绝码
+const geoBlockedCode = "GEO_BLOCKED"
+
 // Upgrader WebSocket连接升级器
 // 负责将HTTP连接升级为WebSocket连接，并处理用户认证、压缩协商、会话管理等功能
 type Upgrader struct {
@@ -33,6 +63,22 @@ type Upgrader struct {
 	token             *jwt.UserToken       // JWT token处理器，用于验证和解析用户身份信息
 	compressionConfig compression.Config   // 压缩配置，定义WebSocket压缩参数和策略
 	sessionBuilder    session.Builder      // 会话构建器，用于创建和管理用户会话
+	codecs            *message.Negotiator  // 消息编解码器协商器，按客户端请求选择编解码器
+	vetoer            *webhook.Vetoer      // 准入webhook，业务方可以在创建会话前拒绝连接
+	admission         admission.Admitter   // 准入控制，认证后按业务方配额拒绝连接
+	lock              *upgradeLock         // 同一用户同一设备的握手互斥锁
+	revocation        revocation.Checker   // 令牌吊销检查，拒绝已被吊销的令牌
+	abuse             *abuse.Guard         // 滥用检测，拒绝被封禁用户的握手
+	origins           originPolicy         // Origin 白名单，防止跨站页面冒用用户身份连接
+	subprotocols      subprotocolPolicy    // Sec-WebSocket-Protocol 协商
+	tokens            tokenPolicy          // 握手令牌的传递方式
+	encryption        *encryption.Negotiator // 逐连接消息加密的协商
+	geo               *geoip.Resolver      // 按客户端IP解析地理位置，执行业务方的地区策略
+	geoMetrics        *metrics.GeoMetrics  // 地区策略拒绝的握手数
+	guests            *guest.Policies      // 访客策略，配置了策略的业务方允许不携带令牌握手
+	fingerprintHeader string               // 客户端上报设备指纹的请求头
+	tracer            *tracing.Tracer      // 握手各步骤的 span
+	hooks             *types.HandshakeHooks // 第三方注册的握手扩展
 	logger            *log.Logger      // 日志组件，用于记录升级过程中的操作和错误信息
 }
 
@@ -53,6 +99,58 @@ func New(i do.Injector) (*Upgrader,error) {
 	if err!= nil {
 		return nil,err
 	}
+	codecs,err := do.Invoke[*message.Negotiator](i)
+	if err!= nil {
+		return nil,err
+	}
+	vetoer,err := do.Invoke[*webhook.Vetoer](i)
+	if err!= nil {
+		return nil,err
+	}
+	serverConfig,err := do.Invoke[config.ServerConfig](i)
+	if err!= nil {
+		return nil,err
+	}
+	controller,err := do.Invoke[admission.Admitter](i)
+	if err!= nil {
+		return nil,err
+	}
+	revoked,err := do.Invoke[revocation.Checker](i)
+	if err!= nil {
+		return nil,err
+	}
+	guard,err := do.Invoke[*abuse.Guard](i)
+	if err!= nil {
+		return nil,err
+	}
+	encryptions,err := do.Invoke[*encryption.Negotiator](i)
+	if err!= nil {
+		return nil,err
+	}
+	geo,err := do.Invoke[*geoip.Resolver](i)
+	if err!= nil {
+		return nil,err
+	}
+	geoMetrics,err := do.Invoke[*metrics.GeoMetrics](i)
+	if err!= nil {
+		return nil,err
+	}
+	guests,err := do.Invoke[*guest.Policies](i)
+	if err!= nil {
+		return nil,err
+	}
+	sessionConfig,err := do.Invoke[config.SessionConfig](i)
+	if err!= nil {
+		return nil,err
+	}
+	tracer,err := do.Invoke[*tracing.Tracer](i)
+	if err!= nil {
+		return nil,err
+	}
+	hooks,err := do.Invoke[*types.HandshakeHooks](i)
+	if err!= nil {
+		return nil,err
+	}
 	logger,err := do.Invoke[*log.Logger](i)
 	if err!= nil {
 		return nil,err
@@ -63,6 +161,22 @@ func New(i do.Injector) (*Upgrader,error) {
 		token:             token,
 		compressionConfig: compressionConfig,
 		sessionBuilder:    sessionBuilder,
+		codecs:            codecs,
+		vetoer:            vetoer,
+		admission:         controller,
+		lock:              newUpgradeLock(serverConfig.Websocket.UpgradeLock, rdb),
+		revocation:        revoked,
+		abuse:             guard,
+		origins:           newOriginPolicy(serverConfig.Websocket.Origin),
+		subprotocols:      newSubprotocolPolicy(serverConfig.Websocket.Subprotocol),
+		tokens:            newTokenPolicy(serverConfig.Websocket.Token),
+		encryption:        encryptions,
+		geo:               geo,
+		geoMetrics:        geoMetrics,
+		guests:            guests,
+		fingerprintHeader: sessionConfig.Devices.FingerprintHeader,
+		tracer:            tracer,
+		hooks:             hooks,
 		logger:            logger,
 	}, nil
 }
@@ -72,73 +186,71 @@ func (u *Upgrader) Name() string {
 }
 
 // Upgrade 将HTTP连接升级为WebSocket连接并支持压缩协商
-func (u *Upgrader) Upgrade(conn net.Conn) (session.Session, *compression.State, error) {
-	var ss session.Session           // 用户会话对象
-	var compressionState *compression.State  // 压缩状态对象
-	var autoClose bool               // 是否自动关闭连接的标志
-	var userInfo session.UserInfo    // 用户信息结构体
+// 握手过程中收集的数据保存在 HandshakeContext 中，升级成功后与会话一起返回
+// 整个握手是一个 span，JWT 解码、会话创建和压缩协商是它的子 span
+func (u *Upgrader) Upgrade(conn net.Conn) (session.Session, *types.HandshakeContext, error) {
+	hc := types.NewHandshakeContext(conn)
+	ctx, span := u.tracer.Start(context.Background(), "gateway.handshake", attribute.String("client.address", hc.RemoteIP))
+	hc.SetContext(ctx)
+	ss, err := u.upgrade(hc)
+	span.SetAttributes(
+		tracing.AttrBizID.Int64(hc.UserInfo.BizID),
+		tracing.AttrUserID.Int64(hc.UserInfo.UserID),
+		tracing.AttrDeviceID.String(hc.UserInfo.DeviceID),
+		attribute.String("gateway.codec", hc.UserInfo.Codec),
+		attribute.Bool("gateway.compression", hc.Compression != nil))
+	tracing.End(span, err)
+	return ss, hc, err
+}
+
+func (u *Upgrader) upgrade(hc *types.HandshakeContext) (session.Session, error) {
+	var ss session.Session // 用户会话对象
+	var unlock func()      // 握手锁在升级结束后释放
+	var deferredAuth bool  // 令牌不在查询参数中，所有请求头解析完之后再认证
+	defer func() {
+		if unlock != nil {
+			unlock()
+		}
+	}()
 
 	// 只有配置启用时才创建压缩扩展
 	// 压缩扩展用于与客户端协商WebSocket压缩参数
-	var ext *wsflate.Extension
-	if u.compressionConfig.Enabled {
-		params := u.compressionConfig.ToParameters()
-		ext = &wsflate.Extension{Parameters: params}
-		u.logger.Info("压缩扩展已启用", slog.Any("params", params))	
+	ext := u.compressionExtension()
+	if ext != nil {
+		u.logger.Info("压缩扩展已启用", slog.Any("params", ext.Parameters))	
 	}
-	// 创建WebSocket升级器，配置各种回调函数处理升级过程
+	// 创建WebSocket升级器，各个回调共享同一个握手上下文
 	upgrader := ws.Upgrader{
 		// Negotiate 压缩协商回调
 		// 在WebSocket握手过程中与客户端协商压缩参数
 		Negotiate: func(opt httphead.Option) (httphead.Option, error) {
 			if ext != nil {
-				return ext.Negotiate(opt)  // 执行压缩参数协商
+				return u.negotiateCompression(hc, ext, opt)  // 执行压缩参数协商
 			}
 			return httphead.Option{}, nil  // 不启用压缩时返回空选项
 		},
-
-		// OnRequest 请求处理回调
-		// 在接收到WebSocket升级请求时调用，主要用于用户认证
-		OnRequest: func(uri []byte) error {
-			var err error
-			// 从请求URI中解析用户信息（包含JWT token）
-			userInfo, err = u.getUserInfo(string(uri))
-			if err != nil {
-				u.logger.Error("获取用户信息失败",slog.String("uri", string(uri)),slog.Any("error", err),)
-				return fmt.Errorf("%w", err)
-			}
-			return nil
+		OnRequest: func(uri []byte) (err error) {
+			deferredAuth, err = u.onRequest(hc, uri)
+			return err
 		},
-
-		// OnHeader HTTP头部处理回调
-		// 解析自定义HTTP头部，如X-AutoClose等配置参数
 		OnHeader: func(key, value []byte) error {
-			// 解析 X-AutoClose header (大小写不敏感)
-			// 该头部用于指示连接是否应该自动关闭
-			if strings.EqualFold(string(key), "X-AutoClose") {
-				autoClose = string(value) == "true"
-				u.logger.Warn("解析到AutoClose header",slog.String("key", string(key)),slog.String("value", string(value)),slog.Any("autoClose", autoClose))
-			}
-			return nil
+			return u.onHeader(hc, key, value)
+		},
+		// ProtocolCustom 子协议协商回调，Sec-WebSocket-Protocol 头部不会传给 OnHeader
+		ProtocolCustom: func(value []byte) (string, bool) {
+			return u.onProtocol(hc, value), true
 		},
-
-		// OnBeforeUpgrade 升级前处理回调
-		// 在实际升级连接前执行，主要用于创建用户会话
 		OnBeforeUpgrade: func() (ws.HandshakeHeader, error) {
-			// 在升级前设置autoClose并创建session
-			userInfo.AutoClose = autoClose
-
-			// 使用Redis会话构建器创建或获取用户会话
-			builder := u.sessionBuilder
-			s, isNew, err := builder.Build(context.Background(), userInfo)
-			if err != nil {
-				return nil, fmt.Errorf("%w", err)
+			if deferredAuth {
+				token, source := u.tokens.fromHeaders(hc)
+				if err := u.authenticate(hc, token, source); err != nil {
+					return nil, err
+				}
 			}
-			if !isNew {
-				// 可能是重连，也可能是多次登录
-				// 这种情况下会返回警告但不阻止连接建立
-				err = ErrExistedUser
-				u.logger.Warn("用户已存在",slog.Any("error", err))
+			s, release, err := u.onBeforeUpgrade(hc)
+			unlock = release
+			if err != nil {
+				return nil, err
 			}
 			ss = s
 			return ws.HandshakeHeaderString(""), nil  // 返回空的握手头部
@@ -147,16 +259,16 @@ func (u *Upgrader) Upgrade(conn net.Conn) (session.Session, *compression.State,
 
 	// 执行WebSocket连接升级
 	// 这里会触发上面定义的所有回调函数
-	_, err := upgrader.Upgrade(conn)
+	_, err := upgrader.Upgrade(hc.Conn)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// 检查压缩协商结果
 	// 如果客户端支持压缩且协商成功，则创建压缩状态对象
 	if ext != nil {
 		if params, accepted := ext.Accepted(); accepted {
-			compressionState = &compression.State{
+			hc.Compression = &compression.State{
 				Enabled:    true,
 				Extension:  ext,
 				Parameters: params,
@@ -166,14 +278,417 @@ func (u *Upgrader) Upgrade(conn net.Conn) (session.Session, *compression.State,
 			u.logger.Warn("压缩协商失败，降级到无压缩模式")
 		}
 	}
-	return ss, compressionState, nil
+	if err := u.hooks.Run(types.AfterUpgrade, hc); err != nil {
+		u.logger.Warn("握手扩展执行失败", slog.Int64("bizId", hc.UserInfo.BizID), slog.Int64("userId", hc.UserInfo.UserID), slog.Any("error", err))
+	}
+	return ss, nil
+}
+
+// negotiateCompression 与客户端提供的一个扩展选项协商压缩参数
+func (u *Upgrader) negotiateCompression(hc *types.HandshakeContext, ext *wsflate.Extension, opt httphead.Option) (httphead.Option, error) {
+	_, span := u.tracer.Start(hc.Context(), "compression.negotiate", attribute.String("gateway.offer", opt.String()))
+	accepted, err := ext.Negotiate(opt)
+	span.SetAttributes(attribute.Bool("gateway.accepted", accepted.Size() > 0))
+	tracing.End(span, err)
+	return accepted, err
+}
+
+// compressionExtension 为一次握手创建压缩扩展，未启用压缩时返回 nil
+// 扩展记录协商结果，不能在连接之间共享
+func (u *Upgrader) compressionExtension() *wsflate.Extension {
+	if !u.compressionConfig.Enabled {
+		return nil
+	}
+	return &wsflate.Extension{Parameters: u.compressionConfig.ToParameters()}
+}
+
+// onRequest 请求处理回调
+// 在接收到WebSocket升级请求时调用，令牌通过 ?token= 携带或访客握手时在这里认证；
+// 否则令牌在请求头、Cookie或子协议中，返回 deferred 为 true，认证推迟到所有请求头解析完之后
+func (u *Upgrader) onRequest(hc *types.HandshakeContext, uri []byte) (deferred bool, err error) {
+	hc.URI = string(uri)
+	if err := u.runHooks(types.BeforeAuth, hc); err != nil {
+		return false, err
+	}
+	uu, err := url.Parse(hc.URI)
+	if err != nil {
+		u.logger.Error("获取用户信息失败", slog.String("uri", hc.URI), slog.Any("error", err))
+		return false, ErrInvalidURI
+	}
+	params := uu.Query()
+	token := params.Get("token")
+	if token != "" && u.tokens.disableQuery {
+		u.logger.Info("令牌通过查询参数携带，拒绝握手", slog.String("ip", hc.RemoteIP))
+		return false, ws.RejectConnectionError(ws.RejectionStatus(http.StatusBadRequest), ws.RejectionReason(ErrQueryTokenDisallowed.Error()))
+	}
+	if isGuest, _ := strconv.ParseBool(params.Get("guest")); token == "" && !isGuest {
+		return true, nil
+	}
+	return false, u.authenticate(hc, token, tokenFromQuery)
+}
+
+// authenticate 按令牌认证用户并执行认证后的握手扩展和加密协商，令牌为空且请求了访客身份时按访客认证
+func (u *Upgrader) authenticate(hc *types.HandshakeContext, token, source string) error {
+	userInfo, err := u.getUserInfo(hc.Context(), hc.URI, token)
+	if err != nil {
+		u.logger.Error("获取用户信息失败",slog.String("uri", hc.URI),slog.String("tokenSource", source),slog.Any("error", err),)
+		if errors.Is(err, ErrUnsupportedCodec) || errors.Is(err, ErrInvalidDeviceID) || errors.Is(err, ErrInvalidBizID) {
+			// 客户端请求的参数有误，以 400 告知客户端，而不是默认的 500
+			return ws.RejectConnectionError(ws.RejectionStatus(http.StatusBadRequest), ws.RejectionReason(err.Error()))
+		}
+		if errors.Is(err, revocation.ErrTokenRevoked) {
+			// 令牌本身有效，以 401 告知客户端需要重新获取令牌
+			return ws.RejectConnectionError(ws.RejectionStatus(http.StatusUnauthorized), ws.RejectionReason("token revoked"))
+		}
+		if errors.Is(err, guest.ErrNotAllowed) {
+			return ws.RejectConnectionError(ws.RejectionStatus(http.StatusForbidden), ws.RejectionReason("guests not allowed"))
+		}
+		return fmt.Errorf("%w", err)
+	}
+	// 推迟认证时 X-AutoClose 请求头已经解析
+	userInfo.AutoClose = hc.UserInfo.AutoClose
+	hc.UserInfo = userInfo
+	if !userInfo.Guest {
+		hc.Token = token
+	}
+	if err := u.runHooks(types.AfterAuth, hc); err != nil {
+		return err
+	}
+	return u.negotiateEncryption(hc)
 }
 
-// getUserInfo 从请求URI中解析用户信息
-// 该方法负责从WebSocket升级请求的URI中提取JWT token并解析用户身份信息
+// runHooks 执行指定阶段的握手扩展，扩展拒绝时按其返回的错误拒绝握手
+func (u *Upgrader) runHooks(stage types.HookStage, hc *types.HandshakeContext) error {
+	err := u.hooks.Run(stage, hc)
+	var hookErr *types.HookError
+	if !errors.As(err, &hookErr) {
+		return err
+	}
+	u.logger.Info("握手扩展拒绝握手",
+		slog.String("hook", hookErr.Name),
+		slog.String("stage", stage.String()),
+		slog.String("ip", hc.RemoteIP),
+		slog.Int64("bizId", hc.UserInfo.BizID),
+		slog.Int64("userId", hc.UserInfo.UserID),
+		slog.Any("error", hookErr.Err))
+	return hookErr.Rejection()
+}
+
+// negotiateEncryption 按业务方的加密策略和 ?encKey= 查询参数协商加密，参数有误或缺少必需的公钥时以 400 拒绝
+func (u *Upgrader) negotiateEncryption(hc *types.HandshakeContext) error {
+	uu, err := url.Parse(hc.URI)
+	if err != nil {
+		return ErrInvalidURI
+	}
+	state, err := u.encryption.Negotiate(hc.UserInfo.BizID, uu.Query().Get("encKey"))
+	if err != nil {
+		u.logger.Info("加密协商失败，拒绝握手",
+			slog.Int64("bizId", hc.UserInfo.BizID),
+			slog.Int64("userId", hc.UserInfo.UserID),
+			slog.Any("error", err))
+		return ws.RejectConnectionError(ws.RejectionStatus(http.StatusBadRequest), ws.RejectionReason(err.Error()))
+	}
+	hc.Encryption = state
+	return nil
+}
+
+// onHeader HTTP头部处理回调
+// 记录所有请求头，并解析自定义HTTP头部，如X-AutoClose等配置参数
+func (u *Upgrader) onHeader(hc *types.HandshakeContext, key, value []byte) error {
+	hc.Header.Add(string(key), string(value))
+	// 解析 X-AutoClose header (大小写不敏感)
+	// 该头部用于指示连接是否应该自动关闭
+	if strings.EqualFold(string(key), "X-AutoClose") {
+		hc.UserInfo.AutoClose = string(value) == "true"
+		u.logger.Warn("解析到AutoClose header",slog.String("key", string(key)),slog.String("value", string(value)),slog.Any("autoClose", hc.UserInfo.AutoClose))
+	}
+	if strings.EqualFold(string(key), "User-Agent") {
+		hc.UserAgent = string(value)
+	}
+	if strings.EqualFold(string(key), "Origin") {
+		hc.Origin = string(value)
+		if err := u.origins.check(hc.Origin); err != nil {
+			u.logger.Warn("Origin 不在白名单中，拒绝握手",
+				slog.String("origin", hc.Origin),
+				slog.Int64("bizId", hc.UserInfo.BizID),
+				slog.Int64("userId", hc.UserInfo.UserID))
+			return ws.RejectConnectionError(ws.RejectionStatus(http.StatusForbidden), ws.RejectionReason(err.Error()))
+		}
+	}
+	return nil
+}
+
+// onProtocol 记录客户端请求的子协议并选择受支持的子协议，未配置子协议时不选择
+// 子协议中携带了令牌时取出令牌，没有选中其它子协议时选择令牌的标记；返回的子协议会写入 101 响应
+func (u *Upgrader) onProtocol(hc *types.HandshakeContext, value []byte) string {
+	token, value := u.tokens.extractSubprotocol(value)
+	if len(value) > 0 {
+		hc.Header.Add("Sec-WebSocket-Protocol", string(value))
+		if u.subprotocols.enabled() {
+			hc.Subprotocol = u.subprotocols.selectFrom(value)
+		}
+	}
+	if token == "" {
+		return hc.Subprotocol
+	}
+	hc.Set(subprotocolTokenKey{}, token)
+	if hc.Subprotocol != "" {
+		return hc.Subprotocol
+	}
+	// 浏览器要求网关选择它请求的子协议之一，否则会关闭连接
+	return u.tokens.marker
+}
+
+// checkHandshakeHeaders 在所有头部解析完后校验 Origin 是否缺失以及子协议的协商结果
+func (u *Upgrader) checkHandshakeHeaders(hc *types.HandshakeContext) error {
+	if err := u.origins.check(hc.Origin); err != nil {
+		return ws.RejectConnectionError(ws.RejectionStatus(http.StatusForbidden), ws.RejectionReason(err.Error()))
+	}
+	offered := hc.Header.Get("Sec-WebSocket-Protocol") != ""
+	if err := u.subprotocols.check(offered, hc.Subprotocol); err != nil {
+		u.logger.Info("客户端请求的子协议不受支持，拒绝握手",
+			slog.Any("offered", hc.Header.Values("Sec-WebSocket-Protocol")),
+			slog.Int64("bizId", hc.UserInfo.BizID),
+			slog.Int64("userId", hc.UserInfo.UserID))
+		return ws.RejectConnectionError(ws.RejectionStatus(http.StatusBadRequest), ws.RejectionReason(err.Error()))
+	}
+	return nil
+}
+
+// onBeforeUpgrade 升级前处理回调
+// 在实际升级连接前执行，主要用于准入校验和创建用户会话
+// 返回的 unlock 用于在升级结束后释放握手锁，未获取到锁时为 nil
+func (u *Upgrader) onBeforeUpgrade(hc *types.HandshakeContext) (s session.Session, unlock func(), err error) {
+	// 缺少 Origin 或子协议不匹配的握手不占用配额
+	if err := u.checkHandshakeHeaders(hc); err != nil {
+		return nil, nil, err
+	}
+	if err := u.resolveFingerprint(hc); err != nil {
+		return nil, nil, err
+	}
+	// 按客户端所在地区执行业务方的地区策略，被拒绝的连接不占用配额
+	if err := u.checkGeo(hc); err != nil {
+		return nil, nil, err
+	}
+	// 被封禁的用户在创建会话之前拒绝，不会占用设备槽位，也不会按多连接策略踢掉用户的其它连接
+	if err := u.checkBanned(hc); err != nil {
+		return nil, nil, err
+	}
+	// 业务方配额等准入检查，在调用业务方webhook之前进行，超出配额的连接不会打到业务方
+	if err := u.checkAdmission(hc); err != nil {
+		return nil, nil, err
+	}
+	// 同一用户同一设备的并发握手只放行一个，重复的握手不会打到业务方
+	unlock, err = u.acquireLock(hc)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 业务方准入校验，被拒绝时不创建会话
+	if err := u.checkVeto(hc); err != nil {
+		return nil, unlock, err
+	}
+
+	// 第三方注册的检查在内置的准入检查都通过之后、创建会话之前执行
+	if err := u.runHooks(types.BeforeSession, hc); err != nil {
+		return nil, unlock, err
+	}
+
+	// 使用Redis会话构建器创建或获取用户会话
+	// 多连接策略由会话构建器执行：rejectNew 策略下已有连接时拒绝，其它策略下由新连接取代旧连接
+	ctx, span := u.tracer.Start(hc.Context(), "session.build")
+	s, isNew, err := u.sessionBuilder.Build(ctx, hc.UserInfo)
+	span.SetAttributes(attribute.Bool("gateway.new_session", isNew))
+	tracing.End(span, err)
+	if errors.Is(err, session.ErrDeviceConflict) {
+		u.logger.Info("用户已有连接，拒绝新连接",
+			slog.Int64("bizId", hc.UserInfo.BizID),
+			slog.Int64("userId", hc.UserInfo.UserID))
+		return nil, unlock, ws.RejectConnectionError(ws.RejectionStatus(http.StatusConflict), ws.RejectionReason("already connected"))
+	}
+	if errors.Is(err, session.ErrDeviceLimit) {
+		u.logger.Info("用户同时在线的设备数已达上限，拒绝新设备的连接",
+			slog.Int64("bizId", hc.UserInfo.BizID),
+			slog.Int64("userId", hc.UserInfo.UserID),
+			slog.String("fingerprint", hc.UserInfo.Fingerprint))
+		return nil, unlock, ws.RejectConnectionError(ws.RejectionStatus(http.StatusConflict), ws.RejectionReason("device limit exceeded"))
+	}
+	if err != nil {
+		return nil, unlock, fmt.Errorf("%w", err)
+	}
+	if !isNew {
+		// 可能是重连，也可能是多端登录，已按多连接策略处理
+		u.logger.Debug("用户已存在",slog.Any("error", ErrExistedUser),slog.Int64("bizId", hc.UserInfo.BizID),slog.Int64("userId", hc.UserInfo.UserID),slog.String("deviceId", hc.UserInfo.DeviceID))
+	}
+	return s, unlock, nil
+}
+
+// resolveFingerprint 确定连接的设备指纹：依次取令牌中的指纹、客户端通过请求头上报的指纹和设备ID
+// 令牌中的指纹由认证服务签发，客户端无法伪造；上报的指纹过长时以 400 拒绝握手
+func (u *Upgrader) resolveFingerprint(hc *types.HandshakeContext) error {
+	if hc.UserInfo.Fingerprint != "" {
+		return nil
+	}
+	fingerprint := hc.Header.Get(u.fingerprintHeader)
+	if len(fingerprint) > maxFingerprintLength {
+		return ws.RejectConnectionError(ws.RejectionStatus(http.StatusBadRequest), ws.RejectionReason(ErrInvalidFingerprint.Error()))
+	}
+	if fingerprint == "" {
+		fingerprint = hc.UserInfo.DeviceID
+	}
+	hc.UserInfo.Fingerprint = fingerprint
+	return nil
+}
+
+// checkGeo 解析客户端IP的地理位置并检查业务方的地区策略，被拒绝时以 403 和拒绝码 GEO_BLOCKED 拒绝握手
+func (u *Upgrader) checkGeo(hc *types.HandshakeContext) error {
+	if !u.geo.Enabled() {
+		return nil
+	}
+	hc.Geo = u.geo.Lookup(hc.RemoteIP)
+	err := u.geo.Check(hc.UserInfo.BizID, hc.Geo)
+	if err == nil {
+		return nil
+	}
+	u.geoMetrics.Rejected(u.geo.Label(hc.Geo))
+	u.logger.Info("客户端所在地区不允许连接，拒绝握手",
+		slog.Int64("bizId", hc.UserInfo.BizID),
+		slog.Int64("userId", hc.UserInfo.UserID),
+		slog.String("ip", hc.RemoteIP),
+		slog.Any("error", err))
+	return ws.RejectConnectionError(
+		ws.RejectionStatus(http.StatusForbidden),
+		ws.RejectionReason("geo blocked"),
+		ws.RejectionHeader(ws.HandshakeHeaderHTTP(http.Header{webhook.RejectCodeHeader: []string{geoBlockedCode}})),
+	)
+}
+
+// acquireLock 获取用户的握手锁，已有握手在进行时以 409 拒绝
+// 集群锁访问Redis失败时只在本节点内去重，不因为Redis抖动拒绝连接
+func (u *Upgrader) acquireLock(hc *types.HandshakeContext) (func(), error) {
+	userInfo := hc.UserInfo
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err := u.lock.lock(ctx, userInfo.BizID, userInfo.UserID, userInfo.DeviceID)
+	switch {
+	case err == nil:
+		return unlock, nil
+	case errors.Is(err, ErrAlreadyConnecting):
+		u.logger.Info("用户已有握手在进行，拒绝重复的握手",
+			slog.Int64("bizId", userInfo.BizID),
+			slog.Int64("userId", userInfo.UserID))
+		return nil, ws.RejectConnectionError(
+			ws.RejectionStatus(http.StatusConflict),
+			ws.RejectionReason("already connecting"),
+			ws.RejectionHeader(ws.HandshakeHeaderHTTP(http.Header{"Retry-After": []string{"1"}})),
+		)
+	default:
+		u.logger.Warn("获取集群握手锁失败，只在本节点内去重",
+			slog.Int64("bizId", userInfo.BizID),
+			slog.Int64("userId", userInfo.UserID),
+			slog.Any("error", err))
+		return unlock, nil
+	}
+}
+
+// checkBanned 检查用户是否被封禁，被封禁时以 429 和剩余封禁时长作为 Retry-After 拒绝握手
+// 查询封禁状态失败时放行，不因为Redis抖动拒绝所有连接
+func (u *Upgrader) checkBanned(hc *types.HandshakeContext) error {
+	if !u.abuse.Enabled() {
+		return nil
+	}
+	userInfo := hc.UserInfo
+	subject := abuse.UserSubject(userInfo.BizID, userInfo.UserID)
+	ctx, cancel := context.WithTimeout(hc.Context(), time.Second)
+	defer cancel()
+	remaining, err := u.abuse.Banned(ctx, subject)
+	if err != nil {
+		u.logger.Warn("查询封禁状态失败，放行连接", slog.String("subject", subject), slog.Any("error", err))
+		return nil
+	}
+	if remaining <= 0 {
+		return nil
+	}
+	u.logger.Info("用户已被封禁，拒绝握手",
+		slog.Int64("bizId", userInfo.BizID),
+		slog.Int64("userId", userInfo.UserID),
+		slog.Duration("remaining", remaining))
+	seconds := max(int(math.Ceil(remaining.Seconds())), 1)
+	return ws.RejectConnectionError(
+		ws.RejectionStatus(http.StatusTooManyRequests),
+		ws.RejectionReason("banned"),
+		ws.RejectionHeader(ws.HandshakeHeaderHTTP(http.Header{"Retry-After": []string{strconv.Itoa(seconds)}})),
+	)
+}
+
+// checkAdmission 认证后的准入检查，被拒绝时以 503/429 和 Retry-After 拒绝握手
+func (u *Upgrader) checkAdmission(hc *types.HandshakeContext) error {
+	userInfo := hc.UserInfo
+	d := u.admission.Admit(admission.Request{Stage: admission.StageHandshake, BizID: userInfo.BizID, UserID: userInfo.UserID, Guest: userInfo.Guest})
+	if d.Action == admission.Accept {
+		return nil
+	}
+	u.logger.Warn("连接未通过准入",
+		slog.Int64("bizId", userInfo.BizID),
+		slog.Int64("userId", userInfo.UserID),
+		slog.String("cause", string(d.Cause)))
+	seconds := max(int(math.Ceil(d.RetryAfter().Seconds())), 1)
+	return ws.RejectConnectionError(
+		ws.RejectionStatus(d.Status()),
+		ws.RejectionReason(string(d.Cause)),
+		ws.RejectionHeader(ws.HandshakeHeaderHTTP(http.Header{"Retry-After": []string{strconv.Itoa(seconds)}})),
+	)
+}
+
+// checkRevoked 检查令牌是否已被吊销
+// 查询吊销记录失败时放行，不因为Redis抖动拒绝所有连接
+func (u *Upgrader) checkRevoked(claims jwt.UserClaims) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := u.revocation.Check(ctx, claims)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, revocation.ErrTokenRevoked):
+		return err
+	default:
+		u.logger.Warn("查询令牌吊销记录失败，放行连接",
+			slog.Int64("bizId", claims.BizID),
+			slog.Int64("userId", claims.UserID),
+			slog.Any("error", err))
+		return nil
+	}
+}
+
+// checkVeto 调用业务方的准入webhook，被拒绝时返回携带业务拒绝码的握手拒绝错误
+func (u *Upgrader) checkVeto(hc *types.HandshakeContext) error {
+	userInfo := hc.UserInfo
+	d := u.vetoer.Check(hc.Context(), webhook.VetoRequest{
+		BizID:     userInfo.BizID,
+		UserID:    userInfo.UserID,
+		IP:        hc.RemoteIP,
+		UserAgent: hc.UserAgent,
+	})
+	if d.Allow {
+		return nil
+	}
+	u.logger.Info("业务方拒绝建立连接",
+		slog.Int64("bizId", userInfo.BizID),
+		slog.Int64("userId", userInfo.UserID),
+		slog.Int("status", d.Status),
+		slog.String("code", d.Code))
+	opts := []ws.RejectOption{ws.RejectionStatus(d.Status), ws.RejectionReason(d.Reason)}
+	if d.Code != "" {
+		opts = append(opts, ws.RejectionHeader(ws.HandshakeHeaderHTTP(http.Header{webhook.RejectCodeHeader: []string{d.Code}})))
+	}
+	return ws.RejectConnectionError(opts...)
+}
+
+// getUserInfo 按令牌和请求URI中的参数解析用户信息
+// 令牌由调用方从查询参数、请求头、Cookie或子协议中取出，该方法负责验证JWT token并解析用户身份信息
 // 
-// URI格式示例: ws://localhost:8080/ws?token=eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...
-func (u *Upgrader) getUserInfo(uri string) (session.UserInfo, error) {
+// URI格式示例: ws://localhost:8080/ws?token=eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...&codec=json&deviceId=ios-1
+// 访客不携带 token: ws://localhost:8080/ws?guest=true&bizId=1&codec=json
+func (u *Upgrader) getUserInfo(ctx context.Context, uri, token string) (session.UserInfo, error) {
 	// 解析URI字符串，提取查询参数
 	uu, err := url.Parse(uri)
 	if err != nil {
@@ -182,13 +697,40 @@ func (u *Upgrader) getUserInfo(uri string) (session.UserInfo, error) {
 
 	// 获取查询参数
 	params := uu.Query()
-	token := params.Get("token")  // 提取token参数
-	
-	// 使用JWT处理器解码和验证token
-	userClaims, err := u.token.Decode(token)
+
+	var userClaims jwt.UserClaims
+	// 携带了 token 时按 token 认证，忽略 guest 参数
+	isGuest, _ := strconv.ParseBool(params.Get("guest"))
+	isGuest = isGuest && token == ""
+	if isGuest {
+		// 访客由网关生成用户ID，业务方必须配置了访客策略
+		if userClaims, err = u.guestClaims(params.Get("bizId")); err != nil {
+			return session.UserInfo{}, err
+		}
+	} else {
+		// 使用JWT处理器解码和验证token
+		_, span := u.tracer.Start(ctx, "jwt.decode")
+		userClaims, err = u.token.Decode(token)
+		tracing.End(span, err)
+		if err != nil {
+			// token无效、过期或格式错误
+			return session.UserInfo{}, fmt.Errorf("%w: %w", ErrInvalidUserToken, err)
+		}
+		if err := u.checkRevoked(userClaims); err != nil {
+			return session.UserInfo{}, err
+		}
+	}
+
+	// 协商消息编解码器，未指定 codec 参数时使用默认编解码器
+	codec, err := u.codecs.Negotiate(params.Get("codec"))
 	if err != nil {
-		// token无效、过期或格式错误
-		return session.UserInfo{}, fmt.Errorf("%w: %w", ErrInvalidUserToken, err)
+		return session.UserInfo{}, fmt.Errorf("%w: %w", ErrUnsupportedCodec, err)
+	}
+
+	// 设备ID用于多连接策略，未上报时视为同一个设备
+	deviceID := params.Get("deviceId")
+	if len(deviceID) > maxDeviceIDLength {
+		return session.UserInfo{}, fmt.Errorf("%w: 长度超过 %d", ErrInvalidDeviceID, maxDeviceIDLength)
 	}
 
 	// 构造用户信息对象
@@ -196,6 +738,22 @@ func (u *Upgrader) getUserInfo(uri string) (session.UserInfo, error) {
 	return session.UserInfo{
 		BizID:  userClaims.BizID,   // 业务ID，用于区分不同的业务域
 		UserID: userClaims.UserID,  // 用户ID，唯一标识用户
+		Codec:  codec.Name(),       // 消息编解码器名称，Link 据此编解码消息
+		DeviceID: deviceID,         // 设备ID，多设备策略下同一设备只保留一个连接
+		Guest:    isGuest,          // 访客的用户ID由网关生成
+		Fingerprint: userClaims.Fingerprint, // 令牌中的设备指纹，未签发时在升级前按请求头和设备ID确定
 		// AutoClose将在OnHeader回调中设置
 	}, nil
+}
+
+// guestClaims 为访客握手生成身份，bizId 参数无效时返回 ErrInvalidBizID，业务方没有配置访客策略时返回 guest.ErrNotAllowed
+func (u *Upgrader) guestClaims(bizIDParam string) (jwt.UserClaims, error) {
+	bizID, err := strconv.ParseInt(bizIDParam, 10, 64)
+	if err != nil || bizID <= 0 {
+		return jwt.UserClaims{}, fmt.Errorf("%w: %q", ErrInvalidBizID, bizIDParam)
+	}
+	if _, ok := u.guests.Of(bizID); !ok {
+		return jwt.UserClaims{}, fmt.Errorf("%w: bizId=%d", guest.ErrNotAllowed, bizID)
+	}
+	return jwt.UserClaims{BizID: bizID, UserID: guest.NewUserID()}, nil
 }
\ No newline at end of file
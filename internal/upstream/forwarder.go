@@ -0,0 +1,486 @@
+package upstream
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/backend"
+	"github.com/YaoAzure/wsgateway/internal/incident"
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/subsystem"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
+	"github.com/samber/do/v2"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	ErrUnknownService = errors.New("路由引用了未配置的业务后端")
+	ErrUnknownCommand = errors.New("路由引用了未知的消息类型")
+)
+
+// 转发失败时回复给客户端的错误码，错误帧的消息体格式与超时错误帧一致
+const (
+	ErrorCodeNoBackend     = "NO_BACKEND"
+	ErrorCodeUnavailable   = "BACKEND_UNAVAILABLE"
+	ErrorCodeRejected      = "BACKEND_REJECTED"
+	ErrorCodeGuestReadOnly = "GUEST_READ_ONLY" // 访客连接只能接收推送，上行消息不转发
+)
+
+// maxHeldMessages 转发暂停期间最多缓存的上行消息数，超过后的消息按业务后端不可用回复
+const maxHeldMessages = 10000
+
+type routeKey struct {
+	bizID int64
+	cmd   gatewayapiv1.Message_CommandType
+}
+
+// Forwarder 把连接上收到的上行消息按消息类型转发到业务后端，并把业务后端的响应写回同一个连接
+//
+// 路由按以下顺序匹配：业务方+消息类型的路由、所有业务方（bizId=0）+消息类型的路由；
+// 上行消息请求都未匹配时使用业务方对应的业务后端。心跳始终由网关直接回复。
+// 访客连接只能接收推送，除心跳外的消息都不转发，上行消息请求以 GUEST_READ_ONLY 错误回复。
+// 只有上行消息请求会收到 UPSTREAM_ACK 回复，其它类型的消息（例如下行推送的确认）只转发、不回复。
+// 网关产生的事件（例如会话接管）由 Notify 上报，路由规则相同。
+//
+// 每次调用以 LinkConfig.EventHandler.RequestTimeout 为超时时间，超时或业务后端暂时不可用时
+// 按 RetryStrategy 指数退避重试；重试耗尽仍超时的请求交给 Synthesizer 生成兜底响应。
+//
+// 可以通过管理API暂停转发，例如业务后端故障期间避免重试放大压力。暂停期间心跳照常回复，
+// 其它消息按连接缓存（总数不超过 maxHeldMessages），恢复后按到达顺序补发；
+// 连接在恢复前关闭时其缓存的消息被丢弃。
+type Forwarder struct {
+	pools   *backend.Pools
+	synth   *Synthesizer
+	routes  map[routeKey]*backend.Service
+	timeout time.Duration
+
+	initInterval time.Duration
+	maxInterval  time.Duration
+	maxRetries   int
+
+	metrics   *metrics.RPCMetrics
+	incidents *incident.Recorder
+	tracer    *tracing.Tracer
+	logger    *log.Logger
+
+	// mu 保护暂停状态和缓存的消息
+	mu       sync.Mutex
+	toggle   *subsystem.Toggle
+	held     map[*link.Link]*heldQueue
+	heldLen  int
+	rejected int64 // 最近一次暂停以来因缓存已满而拒绝的消息数
+}
+
+// heldQueue 一个连接在转发暂停期间缓存的上行消息
+type heldQueue struct {
+	msgs      []*gatewayapiv1.Message
+	replaying bool
+}
+
+func NewForwarder(i do.Injector) (*Forwarder, error) {
+	linkCfg, err := do.Invoke[config.LinkConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	rpcCfg, err := do.Invoke[config.RPCConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := do.Invoke[*backend.Pools](i)
+	if err != nil {
+		return nil, err
+	}
+	synth, err := do.Invoke[*Synthesizer](i)
+	if err != nil {
+		return nil, err
+	}
+	m, err := do.Invoke[*metrics.RPCMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := do.Invoke[*incident.Recorder](i)
+	if err != nil {
+		return nil, err
+	}
+	tracer, err := do.Invoke[*tracing.Tracer](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make(map[routeKey]*backend.Service, len(rpcCfg.Routes))
+	for _, r := range rpcCfg.Routes {
+		cmd, ok := gatewayapiv1.Message_CommandType_value[r.Cmd]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, r.Cmd)
+		}
+		svc, ok := pools.Service(r.Service)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownService, r.Service)
+		}
+		routes[routeKey{bizID: r.BizID, cmd: gatewayapiv1.Message_CommandType(cmd)}] = svc
+	}
+
+	eh := linkCfg.EventHandler
+	initInterval := time.Duration(eh.RetryStrategy.InitInterval)
+	return &Forwarder{
+		pools:        pools,
+		synth:        synth,
+		routes:       routes,
+		timeout:      time.Duration(eh.RequestTimeout),
+		initInterval: initInterval,
+		maxInterval:  max(time.Duration(eh.RetryStrategy.MaxInterval), initInterval),
+		maxRetries:   eh.RetryStrategy.MaxRetries,
+		metrics:      m,
+		incidents:    incidents,
+		tracer:       tracer,
+		logger:       logger,
+		toggle:       subsystem.NewToggle(),
+		held:         make(map[*link.Link]*heldQueue),
+	}, nil
+}
+
+// Handle 实现 link.Handler
+// 在连接的读协程中同步执行，同一连接上的消息按到达顺序依次转发；除心跳外每条消息的处理是一个 span
+func (f *Forwarder) Handle(l *link.Link, msg *gatewayapiv1.Message) {
+	if msg.GetCmd() == gatewayapiv1.Message_COMMAND_TYPE_HEARTBEAT {
+		if err := l.SendMessage(msg); err != nil {
+			f.logger.Debug("回复心跳失败", slog.String("linkId", l.ID()), slog.Any("error", err))
+		}
+		return
+	}
+	if gatewayEvent(msg.GetCmd()) {
+		f.logger.Debug("客户端发送了网关事件类型的消息，丢弃消息", slog.String("linkId", l.ID()), slog.String("cmd", msg.GetCmd().String()))
+		return
+	}
+	ctx, span := f.tracer.Start(context.Background(), "gateway.inbound", inboundAttributes(l, msg)...)
+	defer span.End()
+	if l.Session().UserInfo().Guest {
+		span.SetAttributes(tracing.AttrResult.String("guest"))
+		if msg.GetCmd() == gatewayapiv1.Message_COMMAND_TYPE_UPSTREAM_MESSAGE {
+			f.reply(l, ackOf(msg, errorBody(ErrorCodeGuestReadOnly, "访客连接只能接收消息")))
+		}
+		return
+	}
+	held, full := f.hold(l, msg)
+	switch {
+	case full:
+		span.SetAttributes(tracing.AttrResult.String("rejected"))
+		if msg.GetCmd() == gatewayapiv1.Message_COMMAND_TYPE_UPSTREAM_MESSAGE {
+			f.reply(l, ackOf(msg, errorBody(ErrorCodeUnavailable, "业务后端暂时不可用，请稍后重试")))
+		}
+	case held:
+		// 补发时另起一个 span
+		span.SetAttributes(tracing.AttrResult.String("held"))
+	default:
+		f.handle(ctx, l, msg)
+	}
+}
+
+// inboundAttributes 上行消息处理 span 的属性
+func inboundAttributes(l *link.Link, msg *gatewayapiv1.Message) []attribute.KeyValue {
+	info := l.Session().UserInfo()
+	return []attribute.KeyValue{
+		tracing.AttrLinkID.String(l.ID()),
+		tracing.AttrBizID.Int64(info.BizID),
+		tracing.AttrUserID.Int64(info.UserID),
+		tracing.AttrCmd.String(msg.GetCmd().String()),
+		tracing.AttrKey.String(msg.GetKey()),
+	}
+}
+
+// hold 转发暂停期间，或连接还有未补发完的缓存消息时，把消息追加到连接的缓存中以保持顺序
+// 缓存已满时返回 full
+func (f *Forwarder) hold(l *link.Link, msg *gatewayapiv1.Message) (held, full bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, ok := f.held[l]
+	if !ok && !f.toggle.Paused() {
+		return false, false
+	}
+	if f.heldLen >= maxHeldMessages {
+		f.rejected++
+		return false, true
+	}
+	if !ok {
+		q = &heldQueue{}
+		f.held[l] = q
+	}
+	q.msgs = append(q.msgs, msg)
+	f.heldLen++
+	return true, false
+}
+
+// Pause 暂停转发，之后收到的上行消息被缓存
+func (f *Forwarder) Pause() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.toggle.Pause() {
+		return false
+	}
+	f.rejected = 0
+	return true
+}
+
+// Resume 恢复转发，并在后台按连接补发暂停期间缓存的消息
+func (f *Forwarder) Resume() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.toggle.Resume() {
+		return false
+	}
+	for l, q := range f.held {
+		if !q.replaying {
+			q.replaying = true
+			go f.replay(l, q)
+		}
+	}
+	return true
+}
+
+// replay 按到达顺序补发连接缓存的消息，补发期间新到达的消息继续追加到缓存末尾；
+// 再次暂停时停止补发，剩余的消息在下次恢复时补发；发生 panic 时记录事故、丢弃剩余的缓存并关闭连接
+func (f *Forwarder) replay(l *link.Link, q *heldQueue) {
+	defer f.incidents.Recover("upstream.replay", l, func() {
+		f.drop(l, q)
+		_ = l.Close()
+	})
+	for {
+		f.mu.Lock()
+		if f.toggle.Paused() {
+			q.replaying = false
+			f.mu.Unlock()
+			return
+		}
+		if len(q.msgs) == 0 {
+			delete(f.held, l)
+			f.mu.Unlock()
+			return
+		}
+		msg := q.msgs[0]
+		q.msgs = q.msgs[1:]
+		f.heldLen--
+		f.mu.Unlock()
+
+		select {
+		case <-l.HasClose():
+			f.drop(l, q)
+			return
+		default:
+		}
+		ctx, span := f.tracer.Start(context.Background(), "gateway.inbound",
+			append(inboundAttributes(l, msg), attribute.Bool("gateway.replayed", true))...)
+		f.handle(ctx, l, msg)
+		span.End()
+	}
+}
+
+// drop 丢弃已关闭连接的缓存
+func (f *Forwarder) drop(l *link.Link, q *heldQueue) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heldLen -= len(q.msgs)
+	delete(f.held, l)
+}
+
+// Status 返回上行转发的运行状态
+// held 为当前缓存的消息数，rejected 为最近一次暂停以来因缓存已满而拒绝的消息数
+func (f *Forwarder) Status() subsystem.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.toggle.Status("forwarder", map[string]any{
+		"held":      f.heldLen,
+		"heldLinks": len(f.held),
+		"maxHeld":   maxHeldMessages,
+		"rejected":  f.rejected,
+	})
+}
+
+// handle 把一条上行消息转发到业务后端并回复
+func (f *Forwarder) handle(ctx context.Context, l *link.Link, msg *gatewayapiv1.Message) {
+	info := l.Session().UserInfo()
+	replies := msg.GetCmd() == gatewayapiv1.Message_COMMAND_TYPE_UPSTREAM_MESSAGE
+	svc, ok := f.route(info.BizID, msg.GetCmd())
+	if !ok {
+		f.logger.Debug("上行消息没有匹配的业务后端，丢弃消息",
+			slog.String("linkId", l.ID()), slog.Int64("bizId", info.BizID), slog.String("cmd", msg.GetCmd().String()))
+		if replies {
+			f.reply(l, ackOf(msg, errorBody(ErrorCodeNoBackend, "没有可处理该消息的业务后端")))
+		}
+		return
+	}
+
+	req := Request{
+		BizID:  info.BizID,
+		UserID: info.UserID,
+		Cmd:    msg.GetCmd(),
+		Key:    msg.GetKey(),
+		Body:   msg.GetBody(),
+	}
+	start := time.Now()
+	ctx, span := f.tracer.Start(ctx, "upstream.forward", tracing.AttrService.String(svc.Name))
+	resp, err := f.forward(ctx, l.HasClose(), svc, req)
+	tracing.End(span, err)
+	result := "ok"
+	switch {
+	case errors.Is(err, ErrBackendTimeout):
+		result = "timeout"
+	case err != nil:
+		result = "error"
+	}
+	f.metrics.Forwarded(svc.Name, result, time.Since(start))
+
+	if err != nil {
+		f.logger.Warn("转发上行消息失败",
+			slog.String("linkId", l.ID()), slog.String("service", svc.Name),
+			slog.String("cmd", msg.GetCmd().String()), slog.Any("error", err))
+	}
+	if !replies {
+		return
+	}
+	switch {
+	case err == nil:
+		if resp.Cacheable {
+			f.synth.Remember(info.BizID, cacheKey(svc, req), resp.Body)
+		}
+		f.reply(l, ackOf(msg, resp.Body))
+	case errors.Is(err, ErrBackendTimeout):
+		if ack, ok := f.synth.OnTimeout(info.BizID, msg, cacheKey(svc, req)); ok {
+			f.reply(l, ack)
+		}
+	case errors.Is(err, ErrBackendRejected):
+		f.reply(l, ackOf(msg, errorBody(ErrorCodeRejected, "业务后端拒绝了该请求")))
+	default:
+		f.reply(l, ackOf(msg, errorBody(ErrorCodeUnavailable, "业务后端暂时不可用，请稍后重试")))
+	}
+}
+
+// Notify 实现 link.Notifier，在后台把网关产生的事件转发到业务后端，不回复
+// 路由与上行消息相同，未配置该消息类型的路由时发送到业务方对应的业务后端。
+// 事件不受转发暂停影响，也不因为连接关闭而停止重试
+func (f *Forwarder) Notify(info session.UserInfo, msg *gatewayapiv1.Message) {
+	svc, ok := f.route(info.BizID, msg.GetCmd())
+	if !ok {
+		f.logger.Debug("网关事件没有匹配的业务后端，不上报",
+			slog.Int64("bizId", info.BizID), slog.String("cmd", msg.GetCmd().String()))
+		return
+	}
+	req := Request{
+		BizID:  info.BizID,
+		UserID: info.UserID,
+		Cmd:    msg.GetCmd(),
+		Key:    msg.GetKey(),
+		Body:   msg.GetBody(),
+	}
+	go func() {
+		defer f.incidents.Recover("upstream.notify", nil, nil)
+		start := time.Now()
+		ctx, span := f.tracer.Start(context.Background(), "upstream.notify",
+			tracing.AttrBizID.Int64(info.BizID),
+			tracing.AttrUserID.Int64(info.UserID),
+			tracing.AttrCmd.String(msg.GetCmd().String()),
+			tracing.AttrService.String(svc.Name))
+		_, err := f.forward(ctx, nil, svc, req)
+		tracing.End(span, err)
+		result := "ok"
+		switch {
+		case errors.Is(err, ErrBackendTimeout):
+			result = "timeout"
+		case err != nil:
+			result = "error"
+		}
+		f.metrics.Forwarded(svc.Name, result, time.Since(start))
+		if err != nil {
+			f.logger.Warn("上报网关事件失败",
+				slog.String("service", svc.Name), slog.Int64("bizId", info.BizID), slog.Int64("userId", info.UserID),
+				slog.String("cmd", msg.GetCmd().String()), slog.Any("error", err))
+		}
+	}()
+}
+
+// gatewayEvent 返回该消息类型是否为网关产生并上报业务后端的事件，客户端发送的同类型消息被丢弃，避免冒充网关上报
+func gatewayEvent(cmd gatewayapiv1.Message_CommandType) bool {
+	return cmd == gatewayapiv1.Message_COMMAND_TYPE_SESSION_TAKEOVER || cmd == gatewayapiv1.Message_COMMAND_TYPE_SESSION_TAKEN_OVER
+}
+
+// route 查找处理该消息的业务后端
+func (f *Forwarder) route(bizID int64, cmd gatewayapiv1.Message_CommandType) (*backend.Service, bool) {
+	if svc, ok := f.routes[routeKey{bizID: bizID, cmd: cmd}]; ok {
+		return svc, true
+	}
+	if svc, ok := f.routes[routeKey{cmd: cmd}]; ok {
+		return svc, true
+	}
+	if cmd == gatewayapiv1.Message_COMMAND_TYPE_UPSTREAM_MESSAGE || gatewayEvent(cmd) {
+		return f.pools.ServiceForBiz(bizID)
+	}
+	return nil, false
+}
+
+// forward 调用业务后端，可重试的错误按指数退避重试，done 关闭（例如连接关闭）后不再重试
+func (f *Forwarder) forward(ctx context.Context, done <-chan struct{}, svc *backend.Service, req Request) (Response, error) {
+	interval := f.initInterval
+	for attempt := 0; ; attempt++ {
+		resp, err := f.attempt(ctx, svc, req, attempt)
+		if err == nil || !retryable(err) || attempt >= f.maxRetries {
+			return resp, err
+		}
+		f.metrics.Retried(svc.Name)
+		timer := time.NewTimer(interval)
+		select {
+		case <-done:
+			timer.Stop()
+			return Response{}, err
+		case <-timer.C:
+		}
+		interval = min(interval*2, f.maxInterval)
+	}
+}
+
+// attempt 发起一次调用，超时时间为 requestTimeout；每次调用是一个 span，链路上下文随请求传给业务后端
+func (f *Forwarder) attempt(ctx context.Context, svc *backend.Service, req Request, attempt int) (Response, error) {
+	ctx, span := f.tracer.Start(ctx, "upstream.call",
+		attribute.String("gateway.protocol", svc.Protocol),
+		attribute.Int("gateway.attempt", attempt))
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	resp, err := call(ctx, svc, req)
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (f *Forwarder) reply(l *link.Link, msg *gatewayapiv1.Message) {
+	if err := l.SendMessage(msg); err != nil {
+		f.logger.Debug("回复上行消息失败", slog.String("linkId", l.ID()), slog.Any("error", err))
+	}
+}
+
+// cacheKey 超时兜底缓存的键：业务后端+消息体摘要，相同请求命中同一条缓存
+func cacheKey(svc *backend.Service, req Request) string {
+	sum := sha256.Sum256(req.Body)
+	return svc.Name + ":" + hex.EncodeToString(sum[:])
+}
+
+// errorBody 构造转发失败时的错误帧消息体
+func errorBody(code, message string) []byte {
+	body, _ := json.Marshal(TimeoutError{Code: code, Message: message})
+	return body
+}
@@ -0,0 +1,11 @@
+package upstream
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Upstream 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewSynthesizer),
+	do.Lazy(NewForwarder),
+)
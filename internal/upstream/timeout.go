@@ -0,0 +1,194 @@
+package upstream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+var ErrUnknownTimeoutPolicy = errors.New("未知的超时响应策略")
+
+// TimeoutPolicy 业务后端超时时的客户端响应策略
+type TimeoutPolicy string
+
+const (
+	TimeoutPolicyError   TimeoutPolicy = "error"   // 下发结构化的超时错误帧
+	TimeoutPolicyCached  TimeoutPolicy = "cached"  // 下发最近一次可缓存的成功响应，未命中时退化为错误帧
+	TimeoutPolicySilence TimeoutPolicy = "silence" // 不响应，由客户端自行超时
+)
+
+// TimeoutErrorCode 超时错误帧中的错误码
+const TimeoutErrorCode = "BACKEND_TIMEOUT"
+
+// TimeoutError 超时错误帧的消息体
+type TimeoutError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type timeoutPolicy struct {
+	policy TimeoutPolicy
+	ttl    time.Duration
+}
+
+type responseKey struct {
+	bizID    int64
+	cacheKey string
+}
+
+type cachedResponse struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+// Synthesizer 业务后端超时时代为生成客户端响应
+//
+// 转换层把可缓存的成功响应通过 Remember 交给 Synthesizer，缓存键由转换层决定（例如接口名+参数摘要），
+// 同一缓存键的请求超时时即可用最近一次成功响应兜底。
+type Synthesizer struct {
+	fallback   timeoutPolicy
+	tenants    map[int64]timeoutPolicy
+	maxEntries int
+	metrics    *metrics.RPCMetrics
+
+	mu    sync.Mutex
+	cache map[responseKey]cachedResponse
+}
+
+func NewSynthesizer(i do.Injector) (*Synthesizer, error) {
+	cfg, err := do.Invoke[config.RPCConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	m, err := do.Invoke[*metrics.RPCMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := cfg.TimeoutResponse
+	fallback, err := newTimeoutPolicy(tc.Policy, tc.CacheTTL, 0)
+	if err != nil {
+		return nil, err
+	}
+	tenants := make(map[int64]timeoutPolicy, len(tc.Tenants))
+	for _, t := range tc.Tenants {
+		p, err := newTimeoutPolicy(t.Policy, t.CacheTTL, fallback.ttl)
+		if err != nil {
+			return nil, fmt.Errorf("业务方 %d: %w", t.BizID, err)
+		}
+		tenants[t.BizID] = p
+	}
+	return &Synthesizer{
+		fallback:   fallback,
+		tenants:    tenants,
+		maxEntries: tc.CacheSize,
+		metrics:    m,
+		cache:      make(map[responseKey]cachedResponse),
+	}, nil
+}
+
+func newTimeoutPolicy(name string, ttl int64, defaultTTL time.Duration) (timeoutPolicy, error) {
+	p := TimeoutPolicy(name)
+	switch p {
+	case "":
+		p = TimeoutPolicyError
+	case TimeoutPolicyError, TimeoutPolicyCached, TimeoutPolicySilence:
+	default:
+		return timeoutPolicy{}, fmt.Errorf("%w: %s", ErrUnknownTimeoutPolicy, name)
+	}
+	d := time.Duration(ttl)
+	if d <= 0 {
+		d = defaultTTL
+	}
+	return timeoutPolicy{policy: p, ttl: d}, nil
+}
+
+func (s *Synthesizer) policyFor(bizID int64) timeoutPolicy {
+	if p, ok := s.tenants[bizID]; ok {
+		return p
+	}
+	return s.fallback
+}
+
+// Remember 记录一次可缓存的成功响应
+// 只有使用 cached 策略的业务方才会真正缓存，其它业务方调用该方法没有开销
+func (s *Synthesizer) Remember(bizID int64, cacheKey string, body []byte) {
+	p := s.policyFor(bizID)
+	if p.policy != TimeoutPolicyCached || p.ttl <= 0 || cacheKey == "" {
+		return
+	}
+	now := time.Now()
+	key := responseKey{bizID: bizID, cacheKey: cacheKey}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.cache[key]; !ok && s.maxEntries > 0 && len(s.cache) >= s.maxEntries {
+		for k, v := range s.cache {
+			if now.After(v.expiresAt) {
+				delete(s.cache, k)
+			}
+		}
+		if len(s.cache) >= s.maxEntries {
+			// 缓存已满，放弃缓存本次响应，保证内存有界
+			return
+		}
+	}
+	s.cache[key] = cachedResponse{body: append([]byte(nil), body...), expiresAt: now.Add(p.ttl)}
+}
+
+// OnTimeout 生成请求超时时下发给客户端的响应，返回 false 表示不响应
+func (s *Synthesizer) OnTimeout(bizID int64, req *gatewayapiv1.Message, cacheKey string) (*gatewayapiv1.Message, bool) {
+	p := s.policyFor(bizID)
+	switch p.policy {
+	case TimeoutPolicySilence:
+		s.metrics.Synthesized(string(p.policy), "silence")
+		return nil, false
+	case TimeoutPolicyCached:
+		if body, ok := s.lookup(bizID, cacheKey); ok {
+			s.metrics.Synthesized(string(p.policy), "cached")
+			return ackOf(req, body), true
+		}
+	}
+	s.metrics.Synthesized(string(p.policy), "error")
+	return ackOf(req, timeoutErrorBody), true
+}
+
+func (s *Synthesizer) lookup(bizID int64, cacheKey string) ([]byte, bool) {
+	if cacheKey == "" {
+		return nil, false
+	}
+	key := responseKey{bizID: bizID, cacheKey: cacheKey}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(c.expiresAt) {
+		delete(s.cache, key)
+		return nil, false
+	}
+	return c.body, true
+}
+
+// timeoutErrorBody 超时错误帧的消息体，内容固定，预先编码
+var timeoutErrorBody, _ = json.Marshal(TimeoutError{
+	Code:    TimeoutErrorCode,
+	Message: "业务后端处理超时，请稍后重试",
+})
+
+// ackOf 构造对上行请求的确认消息
+func ackOf(req *gatewayapiv1.Message, body []byte) *gatewayapiv1.Message {
+	return &gatewayapiv1.Message{
+		Cmd:  gatewayapiv1.Message_COMMAND_TYPE_UPSTREAM_ACK,
+		Key:  req.GetKey(),
+		Body: body,
+	}
+}
@@ -0,0 +1,195 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/backend"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrBackendTimeout 单次调用超过 requestTimeout，可以重试
+	ErrBackendTimeout = errors.New("业务后端处理超时")
+	// ErrBackendUnavailable 业务后端暂时不可用（网络错误、5xx、gRPC Unavailable 等），可以重试
+	ErrBackendUnavailable = errors.New("业务后端不可用")
+	// ErrBackendRejected 业务后端明确拒绝了请求（4xx、gRPC InvalidArgument 等），重试没有意义
+	ErrBackendRejected = errors.New("业务后端拒绝请求")
+)
+
+// 转发请求时携带的元数据，HTTP 后端为请求头，gRPC 后端为 metadata（小写）
+// 同时以 W3C Trace Context (traceparent/tracestate) 携带转发的链路上下文
+const (
+	HeaderBizID     = "X-Biz-Id"
+	HeaderUserID    = "X-User-Id"
+	HeaderCommand   = "X-Command"
+	HeaderCacheable = "X-Cacheable" // 业务后端响应中携带 true 时，超时兜底策略可以缓存该响应
+)
+
+// contentTypeProtobuf HTTP 后端请求体的内容类型，请求体为编码后的 OnReceiveRequest
+const contentTypeProtobuf = "application/x-protobuf"
+
+// Request 转发给业务后端的一次上行请求
+type Request struct {
+	BizID  int64
+	UserID int64
+	Cmd    gatewayapiv1.Message_CommandType
+	Key    string
+	Body   []byte
+}
+
+// Response 业务后端的响应
+type Response struct {
+	Body      []byte // 回复给客户端的消息体
+	Cacheable bool
+}
+
+// call 按业务后端的协议发起一次调用
+func call(ctx context.Context, svc *backend.Service, req Request) (Response, error) {
+	switch svc.Protocol {
+	case backend.ProtocolGRPC:
+		return callGRPC(ctx, svc, req)
+	case backend.ProtocolMock:
+		return callMock(ctx, svc, req)
+	default:
+		return callHTTP(ctx, svc, req)
+	}
+}
+
+// callHTTP 以 POST 调用 HTTP 业务后端，2xx 响应体原样作为回复的消息体
+func callHTTP(ctx context.Context, svc *backend.Service, req Request) (Response, error) {
+	body, err := proto.Marshal(&gatewayapiv1.OnReceiveRequest{Key: req.Key, Body: req.Body})
+	if err != nil {
+		return Response{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, svc.URL, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", contentTypeProtobuf)
+	httpReq.Header.Set(HeaderBizID, strconv.FormatInt(req.BizID, 10))
+	httpReq.Header.Set(HeaderUserID, strconv.FormatInt(req.UserID, 10))
+	httpReq.Header.Set(HeaderCommand, req.Cmd.String())
+	tracing.Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := svc.Client.Do(httpReq)
+	if err != nil {
+		return Response{}, classify(ctx, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, classify(ctx, err)
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		cacheable, _ := strconv.ParseBool(resp.Header.Get(HeaderCacheable))
+		return Response{Body: respBody, Cacheable: cacheable}, nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout:
+		return Response{}, fmt.Errorf("%w: 状态码 %d", ErrBackendUnavailable, resp.StatusCode)
+	default:
+		return Response{}, fmt.Errorf("%w: 状态码 %d", ErrBackendRejected, resp.StatusCode)
+	}
+}
+
+// callGRPC 调用 gRPC 业务后端的 BackendService.OnReceive，编码后的 OnReceiveResponse 作为回复的消息体
+func callGRPC(ctx context.Context, svc *backend.Service, req Request) (Response, error) {
+	ctx = tracing.OutgoingContext(metadata.AppendToOutgoingContext(ctx,
+		"x-biz-id", strconv.FormatInt(req.BizID, 10),
+		"x-user-id", strconv.FormatInt(req.UserID, 10),
+		"x-command", req.Cmd.String(),
+	))
+	var header metadata.MD
+	resp, err := gatewayapiv1.NewBackendServiceClient(svc.Conn).OnReceive(ctx,
+		&gatewayapiv1.OnReceiveRequest{Key: req.Key, Body: req.Body}, grpc.Header(&header))
+	if err != nil {
+		return Response{}, classifyGRPC(ctx, err)
+	}
+	body, err := proto.Marshal(resp)
+	if err != nil {
+		return Response{}, err
+	}
+	var cacheable bool
+	if v := header.Get("x-cacheable"); len(v) > 0 {
+		cacheable, _ = strconv.ParseBool(v[0])
+	}
+	return Response{Body: body, Cacheable: cacheable}, nil
+}
+
+// callMock 按模拟业务后端第一条匹配的规则回复：先等待规则的延迟，再注入错误或渲染响应
+// 注入的超时错误等到请求超时时间才返回，与真实后端超时的表现一致
+func callMock(ctx context.Context, svc *backend.Service, req Request) (Response, error) {
+	rule, ok := svc.Mock.Match(req.Cmd.String(), req.Body)
+	if !ok {
+		return Response{}, fmt.Errorf("%w: 没有匹配的模拟响应", ErrBackendRejected)
+	}
+	inject := rule.InjectError()
+	if rule.Delay > 0 {
+		timer := time.NewTimer(rule.Delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Response{}, classify(ctx, ctx.Err())
+		case <-timer.C:
+		}
+	}
+	switch inject {
+	case backend.MockErrorTimeout:
+		<-ctx.Done()
+		return Response{}, classify(ctx, ctx.Err())
+	case backend.MockErrorUnavailable:
+		return Response{}, fmt.Errorf("%w: 模拟业务后端注入的错误", ErrBackendUnavailable)
+	case backend.MockErrorRejected:
+		return Response{}, fmt.Errorf("%w: 模拟业务后端注入的错误", ErrBackendRejected)
+	}
+	body, err := rule.Render(backend.MockData{
+		BizID:  req.BizID,
+		UserID: req.UserID,
+		Cmd:    req.Cmd.String(),
+		Key:    req.Key,
+		Body:   string(req.Body),
+		Now:    time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return Response{}, fmt.Errorf("%w: 渲染模拟响应失败: %w", ErrBackendRejected, err)
+	}
+	return Response{Body: body}, nil
+}
+
+// classify 把HTTP客户端的错误归类为超时或不可用
+func classify(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrBackendTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrBackendUnavailable, err)
+}
+
+// classifyGRPC 按 gRPC 状态码归类错误
+func classifyGRPC(ctx context.Context, err error) error {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", ErrBackendTimeout, err)
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return classify(ctx, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrBackendRejected, err)
+	}
+}
+
+// retryable 返回错误是否值得重试
+func retryable(err error) bool {
+	return errors.Is(err, ErrBackendTimeout) || errors.Is(err, ErrBackendUnavailable)
+}
@@ -0,0 +1,318 @@
+// Package usage 按业务方统计用量并定期输出用量报告
+//
+// 每个统计周期结束时，本节点上各业务方新建的连接数、峰值并发连接数、收发的消息数和字节数汇总为一份报告，
+// 写入 Redis (各节点累加到同一个 Hash)、按天分割的 CSV 文件，或以 JSON POST 到 webhook。
+// 周期按整点对齐，所有节点的周期边界相同；停机时输出最后一个不完整周期的报告，停机前的用量不会丢失。
+package usage
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/link"
+	"github.com/YaoAzure/wsgateway/internal/webhook"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// usageKeyFormat 业务方一个周期的用量在 Redis 中的键，周期为开始时间的 Unix 秒
+	// 字段为 connections、peakConnections、messagesIn、messagesOut、bytesIn、bytesOut，由各节点累加
+	usageKeyFormat = "gateway:usage:bizId:%d:period:%d"
+	// redisTimeout 写入一份报告的超时时间
+	redisTimeout = 3 * time.Second
+
+	// defaultWebhookTimeout 未配置 usage.webhook.timeout 时单次请求的超时时间
+	defaultWebhookTimeout = 5 * time.Second
+	// webhookAttempts 发送一份报告的最大尝试次数，只重试网络错误、5xx 和 429
+	webhookAttempts = 3
+	// webhookRetryInterval 重试发送报告的间隔
+	webhookRetryInterval = time.Second
+	// maxResponseSize 读取 webhook 响应体的上限，读完响应体才能复用连接
+	maxResponseSize = 4 << 10
+)
+
+// errReportRejected webhook 拒绝了报告，重试也不会成功
+var errReportRejected = errors.New("用量报告webhook拒绝了请求")
+
+// csvHeader CSV 文件的表头
+var csvHeader = []string{"periodStart", "periodEnd", "node", "bizId", "connections", "peakConnections", "messagesIn", "messagesOut", "bytesIn", "bytesOut"}
+
+// Report 本节点一个统计周期的用量报告，也是发送给 webhook 的请求体
+//
+//	{"node":"gw-1","start":1700000000000,"end":1700003600000,"usage":[{"bizId":1,"connections":120,"peakConnections":80,"messagesIn":5000,"messagesOut":9000,"bytesIn":620000,"bytesOut":1800000}]}
+//
+// 启动后的第一个周期和停机时的最后一个周期不完整，start 和 end 为实际统计的时间范围
+type Report struct {
+	Node  string       `json:"node"`
+	Start int64        `json:"start"` // 统计开始的时间 (Unix 毫秒)
+	End   int64        `json:"end"`   // 统计结束的时间 (Unix 毫秒)
+	Usage []link.Usage `json:"usage"` // 按业务方ID排序
+}
+
+// Reporter 定期从连接管理器收集用量并输出报告
+type Reporter struct {
+	links    *link.Manager
+	rdb      redis.Cmdable // 未启用 Redis 输出时为 nil
+	ttl      time.Duration
+	csvDir   string
+	hookURL  string
+	secret   []byte
+	client   *http.Client
+	interval time.Duration
+	nodeID   string
+	logger   *log.Logger
+
+	start time.Time // 当前周期的开始时间
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	done      chan struct{}
+}
+
+func NewReporter(i do.Injector) (*Reporter, error) {
+	cfg, err := do.Invoke[config.UsageConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	links, err := do.Invoke[*link.Manager](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reporter{
+		links:   links,
+		ttl:     time.Duration(cfg.Redis.TTL),
+		csvDir:  cfg.CSV.Dir,
+		hookURL: cfg.Webhook.URL,
+		secret:  []byte(cfg.Webhook.Secret),
+		nodeID:  appCfg.InstanceID(),
+		logger:  logger,
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if cfg.Enabled {
+		r.interval = time.Duration(cfg.Interval)
+	}
+	if cfg.Enabled && cfg.Redis.Enabled {
+		if r.rdb, err = do.Invoke[redis.Cmdable](i); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Enabled && r.csvDir != "" {
+		if err := os.MkdirAll(r.csvDir, 0o755); err != nil {
+			return nil, fmt.Errorf("创建用量报告目录失败: %w", err)
+		}
+	}
+	if r.hookURL != "" {
+		timeout := time.Duration(cfg.Webhook.Timeout)
+		if timeout <= 0 {
+			timeout = defaultWebhookTimeout
+		}
+		r.client = &http.Client{Timeout: timeout}
+	}
+	return r, nil
+}
+
+// Start 在后台启动报告协程，未启用用量报告时不启动；重复调用无效
+func (r *Reporter) Start() {
+	r.startOnce.Do(func() {
+		if r.interval <= 0 {
+			close(r.done)
+			return
+		}
+		r.start = time.Now()
+		go r.run()
+	})
+}
+
+// Shutdown 停止报告协程，并输出最后一个不完整周期的报告
+// 应在连接全部关闭之后调用，否则停机过程中关闭的连接的用量不会计入报告
+func (r *Reporter) Shutdown() {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	r.startOnce.Do(func() { close(r.done) })
+	<-r.done
+}
+
+func (r *Reporter) run() {
+	defer close(r.done)
+	for {
+		// 周期按整点对齐，各节点同一周期的用量累加到 Redis 中的同一个键
+		end := r.start.Truncate(r.interval).Add(r.interval)
+		timer := time.NewTimer(time.Until(end))
+		select {
+		case <-r.stopCh:
+			timer.Stop()
+			r.report(time.Now())
+			return
+		case <-timer.C:
+			r.report(end)
+		}
+	}
+}
+
+// report 结束当前周期并输出报告，各输出方式相互独立，一种失败不影响其它
+func (r *Reporter) report(end time.Time) {
+	usage := r.links.CollectUsage()
+	start := r.start
+	r.start = end
+	if len(usage) == 0 {
+		return
+	}
+	slices.SortFunc(usage, func(a, b link.Usage) int {
+		return cmp.Compare(a.BizID, b.BizID)
+	})
+	report := Report{Node: r.nodeID, Start: start.UnixMilli(), End: end.UnixMilli(), Usage: usage}
+	if r.rdb != nil {
+		if err := r.writeRedis(start.Truncate(r.interval), usage); err != nil {
+			r.logger.Warn("写入用量报告到Redis失败", slog.Time("start", start), slog.Any("error", err))
+		}
+	}
+	if r.csvDir != "" {
+		if err := r.writeCSV(report); err != nil {
+			r.logger.Warn("写入用量报告CSV文件失败", slog.Time("start", start), slog.Any("error", err))
+		}
+	}
+	if r.hookURL != "" {
+		if err := r.post(report); err != nil {
+			r.logger.Warn("发送用量报告失败", slog.Time("start", start), slog.Any("error", err))
+		}
+	}
+}
+
+// writeRedis 把本节点的用量累加到每个业务方该周期的 Hash
+// peakConnections 为各节点峰值之和，是全集群峰值并发连接数的上界
+func (r *Reporter) writeRedis(period time.Time, usage []link.Usage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, u := range usage {
+			key := fmt.Sprintf(usageKeyFormat, u.BizID, period.Unix())
+			pipe.HIncrBy(ctx, key, "connections", u.Connections)
+			pipe.HIncrBy(ctx, key, "peakConnections", int64(u.PeakConnections))
+			pipe.HIncrBy(ctx, key, "messagesIn", u.MessagesIn)
+			pipe.HIncrBy(ctx, key, "messagesOut", u.MessagesOut)
+			pipe.HIncrBy(ctx, key, "bytesIn", u.BytesIn)
+			pipe.HIncrBy(ctx, key, "bytesOut", u.BytesOut)
+			if r.ttl > 0 {
+				pipe.PExpire(ctx, key, r.ttl)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// writeCSV 把报告追加到周期开始日期 (UTC) 的 CSV 文件 usage-YYYY-MM-DD.csv，新文件先写入表头
+func (r *Reporter) writeCSV(report Report) error {
+	start := time.UnixMilli(report.Start).UTC()
+	path := filepath.Join(r.csvDir, "usage-"+start.Format(time.DateOnly)+".csv")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w := csv.NewWriter(f)
+	if info.Size() == 0 {
+		_ = w.Write(csvHeader)
+	}
+	periodStart := start.Format(time.RFC3339Nano)
+	periodEnd := time.UnixMilli(report.End).UTC().Format(time.RFC3339Nano)
+	for _, u := range report.Usage {
+		_ = w.Write([]string{
+			periodStart,
+			periodEnd,
+			report.Node,
+			strconv.FormatInt(u.BizID, 10),
+			strconv.FormatInt(u.Connections, 10),
+			strconv.Itoa(u.PeakConnections),
+			strconv.FormatInt(u.MessagesIn, 10),
+			strconv.FormatInt(u.MessagesOut, 10),
+			strconv.FormatInt(u.BytesIn, 10),
+			strconv.FormatInt(u.BytesOut, 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// post 把报告 POST 到 webhook，网络错误和 5xx、429 响应按固定间隔重试
+// 配置了签名密钥时与生命周期事件webhook一样以 X-Gateway-Signature 请求头携带请求体签名
+func (r *Reporter) post(report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err = r.send(body)
+		if err == nil || errors.Is(err, errReportRejected) || attempt >= webhookAttempts {
+			return err
+		}
+		time.Sleep(webhookRetryInterval)
+	}
+}
+
+func (r *Reporter) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, r.hookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errReportRejected, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(r.secret) > 0 {
+		mac := hmac.New(sha256.New, r.secret)
+		mac.Write(body)
+		req.Header.Set(webhook.SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("用量报告webhook返回状态码 %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: 状态码 %d", errReportRejected, resp.StatusCode)
+	}
+}
@@ -0,0 +1,225 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/backend"
+	"github.com/YaoAzure/wsgateway/internal/revocation"
+	"github.com/YaoAzure/wsgateway/internal/upgrader"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/jwt"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// initialRetryBackoff 步骤失败后第一次重试前的等待时间
+	initialRetryBackoff = 100 * time.Millisecond
+	// maxRetryBackoff 步骤失败后重试的最长等待时间
+	maxRetryBackoff = 2 * time.Second
+)
+
+// Step 一个预热步骤，Run 返回预热的条目数
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) (int, error)
+}
+
+// Warmer 启动时预热本地缓存和到依赖服务的连接
+//
+// 节点开始接收连接后在后台并发执行各步骤，预热完成前就绪探针失败 (scaling.Monitor)，
+// 负载均衡不会把发布后的首批连接导入冷节点：
+//   - jwks：获取身份提供方的 JWKS 公钥，首批握手不会逐个触发刷新
+//   - redisPool：预先建立 Redis 连接，首批握手不会同时新建连接
+//   - redisScripts：把握手和会话使用的 Lua 脚本加载到 Redis，EVALSHA 不会因 NOSCRIPT 回退到 EVAL
+//   - backends：各业务方的 gRPC 后端立即建连并等待就绪
+//
+// 失败的步骤按指数退避重试，超过 timeout 后放弃预热照常就绪：依赖服务长时间不可用时节点仍能提供服务，
+// 缺失的缓存在首次使用时按需加载
+type Warmer struct {
+	enabled bool
+	timeout time.Duration
+	steps   []Step
+	logger  *log.Logger
+
+	ready     atomic.Bool
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewWarmer(i do.Injector) (*Warmer, error) {
+	cfg, err := do.Invoke[config.ScalingConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	w := &Warmer{
+		enabled: cfg.Warmup.Enabled,
+		timeout: time.Duration(cfg.Warmup.Timeout),
+		logger:  logger,
+		done:    make(chan struct{}),
+	}
+	if !w.enabled {
+		return w, nil
+	}
+
+	token, err := do.Invoke[*jwt.Token](i)
+	if err != nil {
+		return nil, err
+	}
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := do.Invoke[*backend.Pools](i)
+	if err != nil {
+		return nil, err
+	}
+	var scripts []*redis.Script
+	scripts = append(scripts, session.Scripts()...)
+	scripts = append(scripts, upgrader.Scripts()...)
+	scripts = append(scripts, revocation.Scripts()...)
+
+	w.steps = []Step{
+		{Name: "jwks", Run: func(context.Context) (int, error) { return token.WarmUp() }},
+		{Name: "redisPool", Run: func(ctx context.Context) (int, error) { return warmPool(ctx, rdb, cfg.Warmup.RedisConns) }},
+		{Name: "redisScripts", Run: func(ctx context.Context) (int, error) { return loadScripts(ctx, rdb, scripts) }},
+		{Name: "backends", Run: pools.WarmUp},
+	}
+	return w, nil
+}
+
+// Start 在后台执行预热，未启用时立即就绪
+func (w *Warmer) Start() {
+	w.startOnce.Do(func() {
+		if !w.enabled {
+			w.ready.Store(true)
+			close(w.done)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		w.cancel = cancel
+		go w.run(ctx)
+	})
+}
+
+// Ready 返回预热是否已经结束（完成或超时）
+func (w *Warmer) Ready() bool {
+	return w.ready.Load()
+}
+
+// Shutdown 中止尚未完成的预热
+func (w *Warmer) Shutdown() {
+	w.stopOnce.Do(func() {
+		if w.cancel != nil {
+			w.cancel()
+		}
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	w.startOnce.Do(func() { close(w.done) })
+	<-w.done
+}
+
+func (w *Warmer) run(ctx context.Context) {
+	defer close(w.done)
+	defer w.cancel()
+	start := time.Now()
+	w.logger.Info("开始预热缓存和连接", slog.Int("steps", len(w.steps)), slog.Duration("timeout", w.timeout))
+
+	var (
+		wg       sync.WaitGroup
+		finished atomic.Int32
+		failed   atomic.Int32
+	)
+	for _, step := range w.steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := w.runStep(ctx, step)
+			done := finished.Add(1)
+			if err != nil {
+				failed.Add(1)
+				w.logger.Warn("预热步骤未完成",
+					slog.String("step", step.Name),
+					slog.String("progress", fmt.Sprintf("%d/%d", done, len(w.steps))),
+					slog.Duration("elapsed", time.Since(start)),
+					slog.Any("error", err))
+				return
+			}
+			w.logger.Info("预热步骤已完成",
+				slog.String("step", step.Name),
+				slog.Int("items", n),
+				slog.String("progress", fmt.Sprintf("%d/%d", done, len(w.steps))),
+				slog.Duration("elapsed", time.Since(start)))
+		}()
+	}
+	wg.Wait()
+
+	w.ready.Store(true)
+	if n := failed.Load(); n > 0 {
+		w.logger.Warn("预热超时，未完成的缓存在首次使用时加载", slog.Int("failed", int(n)), slog.Duration("elapsed", time.Since(start)))
+		return
+	}
+	w.logger.Info("预热已完成", slog.Duration("elapsed", time.Since(start)))
+}
+
+// runStep 执行一个步骤，失败时按指数退避重试直到成功或 ctx 结束
+func (w *Warmer) runStep(ctx context.Context, step Step) (int, error) {
+	backoff := initialRetryBackoff
+	for {
+		n, err := step.Run(ctx)
+		if err == nil {
+			return n, nil
+		}
+		if ctx.Err() != nil {
+			return n, err
+		}
+		w.logger.Debug("预热步骤失败，稍后重试", slog.String("step", step.Name), slog.Duration("backoff", backoff), slog.Any("error", err))
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return n, err
+		case <-t.C:
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// warmPool 并发执行 conns 次 PING，让连接池预先建立 conns 个连接
+func warmPool(ctx context.Context, rdb redis.Cmdable, conns int) (int, error) {
+	errs := make([]error, conns)
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = rdb.Ping(ctx).Err()
+		}()
+	}
+	wg.Wait()
+	return conns, errors.Join(errs...)
+}
+
+// loadScripts 把 Lua 脚本加载到 Redis 的脚本缓存
+func loadScripts(ctx context.Context, rdb redis.Cmdable, scripts []*redis.Script) (int, error) {
+	for _, s := range scripts {
+		if err := s.Load(ctx, rdb).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return len(scripts), nil
+}
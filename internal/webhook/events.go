@@ -0,0 +1,289 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+)
+
+// 连接生命周期事件的类型
+const (
+	EventConnect    = "connect"    // 连接已建立
+	EventDisconnect = "disconnect" // 连接已关闭（空闲回收除外）
+	EventIdleClose  = "idleClose"  // 连接因空闲超时被网关关闭
+)
+
+const (
+	// SignatureHeader 配置了签名密钥时携带请求体签名的请求头，格式为 sha256=<十六进制的 HMAC-SHA256>
+	SignatureHeader = "X-Gateway-Signature"
+
+	// maxPendingEvents 等待发送的事件数上限，大面积断连时超出的事件被丢弃，保证内存有界
+	maxPendingEvents = 10000
+	// eventWorkers 并发发送事件的协程数
+	eventWorkers = 8
+	// defaultEventTimeout 未配置 link.eventHandler.requestTimeout 时单次请求的超时时间
+	defaultEventTimeout = 3 * time.Second
+	// shutdownFlushTimeout 停机时等待待发送事件送达的最长时间，停机期间不再重试
+	shutdownFlushTimeout = 5 * time.Second
+)
+
+// errEventRejected 业务方的webhook拒绝了事件，重试也不会成功
+var errEventRejected = errors.New("事件webhook拒绝了请求")
+
+// Event 连接生命周期事件，也是发送给业务方webhook的请求体
+//
+//	{"type":"disconnect","bizId":1,"userId":2,"connId":"...","node":"gw-1","code":1001,"reason":"...","duration":65000,"time":1700000000000}
+//
+// 同一连接的事件可能并发发送，业务方应按 time 和 connId 处理乱序
+type Event struct {
+	Type     string `json:"type"`
+	BizID    int64  `json:"bizId"`
+	UserID   int64  `json:"userId"`
+	ConnID   string `json:"connId"`
+	DeviceID string `json:"deviceId,omitempty"`
+	Guest    bool   `json:"guest,omitempty"` // 是否是访客连接，访客的用户ID由网关生成
+	Node     string `json:"node"`
+	IP       string `json:"ip,omitempty"`
+	Code     int    `json:"code,omitempty"`     // 关闭码，connect 事件中为 0
+	Reason   string `json:"reason,omitempty"`   // 关闭原因
+	ByPeer   bool   `json:"byPeer,omitempty"`   // 是否由客户端发起关闭（包括异常断开）
+	Duration int64  `json:"duration,omitempty"` // 连接持续的毫秒数，connect 事件中为 0
+	Time     int64  `json:"time"`               // 事件发生的时间 (Unix 毫秒)
+}
+
+type eventEndpoint struct {
+	bizID  int64 // 0 表示所有业务方
+	url    string
+	events []string // 为空时接收全部事件
+	secret []byte
+}
+
+func (ep eventEndpoint) accepts(e Event) bool {
+	return (ep.bizID == 0 || ep.bizID == e.BizID) && (len(ep.events) == 0 || slices.Contains(ep.events, e.Type))
+}
+
+type delivery struct {
+	ep    eventEndpoint
+	event Event
+	body  []byte
+}
+
+// Events 把连接的建立、关闭和空闲回收事件异步 POST 给业务方配置的webhook，业务后端不需要轮询 Redis 即可得知在线状态的变化
+//
+// 事件进入有界队列后由固定数量的协程发送，不阻塞连接的建立和回收。超时、网络错误、5xx 和 429 按
+// link.eventHandler.retryStrategy 指数退避重试，其它 4xx 视为业务方拒绝、不再重试。
+// 停机时在 shutdownFlushTimeout 内尽力发送剩余的事件（不再重试），摘流期间关闭的连接的断开事件也能送达
+type Events struct {
+	endpoints    []eventEndpoint
+	timeout      time.Duration
+	initInterval time.Duration
+	maxInterval  time.Duration
+	maxRetries   int
+	client       *http.Client
+	metrics      *metrics.WebhookMetrics
+	logger       *log.Logger
+
+	mu       sync.RWMutex // 保护 stopped 和向 queue 发送，停机时关闭 queue
+	stopped  bool
+	queue    chan delivery
+	stopOnce sync.Once
+	stopping chan struct{} // 关闭后不再重试
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewEvents(i do.Injector) (*Events, error) {
+	cfg, err := do.Invoke[config.WebhookConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	linkCfg, err := do.Invoke[config.LinkConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	e := &Events{logger: logger}
+	for _, c := range cfg.Lifecycle {
+		e.endpoints = append(e.endpoints, eventEndpoint{bizID: c.BizID, url: c.URL, events: c.Events, secret: []byte(c.Secret)})
+	}
+	if len(e.endpoints) == 0 {
+		return e, nil
+	}
+	if e.metrics, err = do.Invoke[*metrics.WebhookMetrics](i); err != nil {
+		return nil, err
+	}
+	eh := linkCfg.EventHandler
+	e.timeout = time.Duration(eh.RequestTimeout)
+	if e.timeout <= 0 {
+		e.timeout = defaultEventTimeout
+	}
+	e.initInterval = time.Duration(eh.RetryStrategy.InitInterval)
+	e.maxInterval = max(time.Duration(eh.RetryStrategy.MaxInterval), e.initInterval)
+	e.maxRetries = eh.RetryStrategy.MaxRetries
+	e.client = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	e.queue = make(chan delivery, maxPendingEvents)
+	e.stopping = make(chan struct{})
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	for range eventWorkers {
+		e.wg.Add(1)
+		go e.work()
+	}
+	return e, nil
+}
+
+// Enabled 返回是否配置了生命周期事件webhook
+func (e *Events) Enabled() bool {
+	return len(e.endpoints) > 0
+}
+
+// Notify 把事件放入所有匹配的webhook的发送队列，不阻塞；队列已满或已停机时丢弃
+func (e *Events) Notify(event Event) {
+	if !e.Enabled() {
+		return
+	}
+	var body []byte
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, ep := range e.endpoints {
+		if !ep.accepts(event) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(event); err != nil {
+				e.logger.Error("编码连接生命周期事件失败", slog.String("type", event.Type), slog.Any("error", err))
+				return
+			}
+		}
+		if e.stopped {
+			e.metrics.Sent(event.Type, metrics.EventDropped)
+			continue
+		}
+		select {
+		case e.queue <- delivery{ep: ep, event: event, body: body}:
+		default:
+			e.metrics.Sent(event.Type, metrics.EventDropped)
+			e.logger.Warn("待发送的连接生命周期事件过多，丢弃事件",
+				slog.String("type", event.Type),
+				slog.Int64("bizId", event.BizID),
+				slog.Int64("userId", event.UserID),
+				slog.String("connId", event.ConnID))
+		}
+	}
+}
+
+// Shutdown 停止接收新事件，在 shutdownFlushTimeout 内发送完队列中的事件
+func (e *Events) Shutdown() {
+	if !e.Enabled() {
+		return
+	}
+	e.stopOnce.Do(func() {
+		e.mu.Lock()
+		e.stopped = true
+		close(e.queue)
+		e.mu.Unlock()
+		close(e.stopping)
+
+		done := make(chan struct{})
+		go func() {
+			e.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(shutdownFlushTimeout):
+			e.logger.Warn("停机时仍有连接生命周期事件未送达，放弃发送", slog.Int("pending", len(e.queue)))
+			e.cancel()
+			<-done
+		}
+		e.cancel()
+		e.client.CloseIdleConnections()
+	})
+}
+
+func (e *Events) work() {
+	defer e.wg.Done()
+	for d := range e.queue {
+		if err := e.deliver(d); err != nil {
+			e.metrics.Sent(d.event.Type, metrics.EventFailed)
+			e.logger.Warn("发送连接生命周期事件失败",
+				slog.String("url", d.ep.url),
+				slog.String("type", d.event.Type),
+				slog.Int64("bizId", d.event.BizID),
+				slog.Int64("userId", d.event.UserID),
+				slog.String("connId", d.event.ConnID),
+				slog.Any("error", err))
+			continue
+		}
+		e.metrics.Sent(d.event.Type, metrics.EventDelivered)
+	}
+}
+
+// deliver 发送一个事件，可重试的错误按重试策略指数退避重试，停机期间不再重试
+func (e *Events) deliver(d delivery) error {
+	interval := e.initInterval
+	for attempt := 0; ; attempt++ {
+		err := e.post(d)
+		if err == nil || errors.Is(err, errEventRejected) || attempt >= e.maxRetries {
+			return err
+		}
+		e.metrics.Retried()
+		timer := time.NewTimer(interval)
+		select {
+		case <-e.stopping:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		interval = min(interval*2, e.maxInterval)
+	}
+}
+
+func (e *Events) post(d delivery) error {
+	ctx, cancel := context.WithTimeout(e.ctx, e.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.ep.url, bytes.NewReader(d.body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errEventRejected, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(d.ep.secret) > 0 {
+		mac := hmac.New(sha256.New, d.ep.secret)
+		mac.Write(d.body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxVetoResponseSize))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("事件webhook返回状态码 %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: 状态码 %d", errEventRejected, resp.StatusCode)
+	}
+}
@@ -0,0 +1,11 @@
+package webhook
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Webhook 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewVetoer),
+	do.Lazy(NewEvents),
+)
@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
+	"github.com/samber/do/v2"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// FailurePolicy 准入webhook调用失败（超时、网络错误、非2xx响应）时的处理策略
+type FailurePolicy string
+
+const (
+	FailOpen   FailurePolicy = "open"   // 放行连接，业务服务故障不影响用户连接
+	FailClosed FailurePolicy = "closed" // 拒绝连接，适用于必须经过业务校验才能接入的场景
+)
+
+const (
+	// defaultVetoTimeout 未配置超时时间时使用的默认值，准入webhook在握手的关键路径上，必须足够短
+	defaultVetoTimeout = 500 * time.Millisecond
+	// defaultRejectStatus 业务服务拒绝连接但没有给出合法状态码时使用的HTTP状态码
+	defaultRejectStatus = http.StatusForbidden
+	// maxVetoResponseSize 准入webhook响应体的最大读取字节数
+	maxVetoResponseSize = 16 << 10
+
+	// RejectCodeHeader 拒绝握手时携带业务拒绝码的响应头
+	RejectCodeHeader = "X-Reject-Code"
+	// RejectCodeUnavailable fail-closed 策略下准入webhook不可用时的拒绝码
+	RejectCodeUnavailable = "VETO_UNAVAILABLE"
+)
+
+var ErrUnknownFailurePolicy = errors.New("未知的准入webhook失败策略")
+
+// VetoRequest 发送给准入webhook的请求体
+type VetoRequest struct {
+	BizID     int64  `json:"bizId"`
+	UserID    int64  `json:"userId"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// Decision 准入结果，也是准入webhook的响应体格式：
+//
+//	{"allow": false, "status": 403, "code": "SUBSCRIPTION_LAPSED", "reason": "订阅已过期"}
+//
+// 拒绝时 Status 作为握手响应的HTTP状态码（只接受 4xx/5xx，否则使用 403），
+// Code 通过 X-Reject-Code 响应头返回给客户端，Reason 作为响应体
+type Decision struct {
+	Allow  bool   `json:"allow"`
+	Status int    `json:"status,omitempty"`
+	Code   string `json:"code,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type vetoEndpoint struct {
+	url     string
+	timeout time.Duration
+	policy  FailurePolicy
+}
+
+// Vetoer 连接建立前的同步准入webhook
+// 在握手完成认证之后、创建会话之前调用业务方配置的webhook，业务服务可以据此拒绝连接
+// （例如订阅已过期、设备被封禁），没有配置webhook的业务方直接放行
+type Vetoer struct {
+	endpoints map[int64]vetoEndpoint
+	client    *http.Client
+	logger    *log.Logger
+}
+
+func NewVetoer(i do.Injector) (*Vetoer, error) {
+	cfg, err := do.Invoke[config.WebhookConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make(map[int64]vetoEndpoint, len(cfg.PreAccept))
+	for _, c := range cfg.PreAccept {
+		policy := FailurePolicy(c.FailurePolicy)
+		switch policy {
+		case "":
+			policy = FailOpen
+		case FailOpen, FailClosed:
+		default:
+			return nil, fmt.Errorf("%w: bizId=%d policy=%s", ErrUnknownFailurePolicy, c.BizID, c.FailurePolicy)
+		}
+		timeout := time.Duration(c.Timeout)
+		if timeout <= 0 {
+			timeout = defaultVetoTimeout
+		}
+		endpoints[c.BizID] = vetoEndpoint{url: c.URL, timeout: timeout, policy: policy}
+	}
+	return &Vetoer{
+		endpoints: endpoints,
+		client:    &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()},
+		logger:    logger,
+	}, nil
+}
+
+// Check 调用业务方的准入webhook，返回是否允许建立连接
+func (v *Vetoer) Check(ctx context.Context, req VetoRequest) Decision {
+	ep, ok := v.endpoints[req.BizID]
+	if !ok {
+		return Decision{Allow: true}
+	}
+	ctx, cancel := context.WithTimeout(ctx, ep.timeout)
+	defer cancel()
+
+	d, err := v.call(ctx, ep.url, req)
+	if err != nil {
+		v.logger.Warn("调用准入webhook失败",
+			slog.Int64("bizId", req.BizID),
+			slog.Int64("userId", req.UserID),
+			slog.String("policy", string(ep.policy)),
+			slog.Any("error", err))
+		if ep.policy == FailClosed {
+			return Decision{Status: http.StatusServiceUnavailable, Code: RejectCodeUnavailable}
+		}
+		return Decision{Allow: true}
+	}
+	if !d.Allow && (d.Status < 400 || d.Status > 599) {
+		d.Status = defaultRejectStatus
+	}
+	return d
+}
+
+func (v *Vetoer) call(ctx context.Context, url string, req VetoRequest) (Decision, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Decision{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	// 业务方可以把准入校验的 span 接在握手的链路之后
+	tracing.Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+	resp, err := v.client.Do(httpReq)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Decision{}, fmt.Errorf("准入webhook返回状态码 %d", resp.StatusCode)
+	}
+	var d Decision
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxVetoResponseSize)).Decode(&d); err != nil {
+		return Decision{}, fmt.Errorf("解析准入webhook响应失败: %w", err)
+	}
+	return d, nil
+}
+
+// Shutdown 关闭空闲的HTTP连接
+func (v *Vetoer) Shutdown() {
+	v.client.CloseIdleConnections()
+}
@@ -0,0 +1,152 @@
+package wswrapper
+
+import (
+	"bufio"
+	"compress/flate"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// 帧缓冲区、压缩器、解压器和读取缓冲区在所有连接之间复用：
+// flate.Writer 占用数百KB、flate.Reader 占用数十KB，网关持有几十万条连接时按连接各持有一个的内存开销不可接受，
+// 而同一时刻真正在读写的连接很少，因此只在读写一条消息期间从池中取出，读写完立即归还
+
+const (
+	// maxPooledPayload 放回池中的读取缓冲区的最大容量，偶发的大消息不会让池一直占用大块内存
+	maxPooledPayload = 64 << 10
+	// minPayloadGrow 读取缓冲区每次扩容的最小字节数
+	minPayloadGrow = 512
+)
+
+// frameWriters 帧写入器池
+// wsutil.GetWriter 按不含帧头的缓冲区大小归还，大小与池的分级对不上，实际上不会复用，因此自行维护
+var frameWriters = sync.Pool{
+	New: func() any {
+		return wsutil.NewWriterBufferSize(nil, 0, 0, wsutil.DefaultWriteBuffer)
+	},
+}
+
+// compressors 按压缩级别 (flate.HuffmanOnly ~ flate.BestCompression) 划分的压缩器池
+var compressors [flate.BestCompression - flate.HuffmanOnly + 1]sync.Pool
+
+// decompressors 解压器池
+var decompressors = sync.Pool{
+	New: func() any {
+		return wsflate.NewReader(nil, func(r io.Reader) wsflate.Decompressor {
+			br := bufio.NewReader(r)
+			return decompressor{ReadCloser: flate.NewReader(br), br: br} // 使用标准库的deflate解压缩实现
+		})
+	},
+}
+
+// payloads Read 返回的消息使用的缓冲区
+var payloads = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, minPayloadGrow)
+		return &b
+	},
+}
+
+// decompressor 让标准库的deflate解压器实现 wsflate.ReadResetter，
+// 否则 wsflate.Reader 每次 Reset 都会重新创建解压器；
+// 同时复用 flate 读取非 io.ByteReader 的数据源时需要的 bufio.Reader。
+// 数据源是单条消息，读到消息末尾即返回 io.EOF，预读不会越过消息边界
+type decompressor struct {
+	io.ReadCloser
+	br *bufio.Reader
+}
+
+func (d decompressor) Reset(r io.Reader) {
+	d.br.Reset(r)
+	_ = d.ReadCloser.(flate.Resetter).Reset(d.br, nil)
+}
+
+// validLevel 返回 level 是否是 flate 支持的压缩级别
+func validLevel(level int) bool {
+	return level >= flate.HuffmanOnly && level <= flate.BestCompression
+}
+
+// getFrameWriter 取出一个输出到 dest 的帧写入器
+func getFrameWriter(dest io.Writer, state ws.State, op ws.OpCode) *wsutil.Writer {
+	fw := frameWriters.Get().(*wsutil.Writer)
+	fw.Reset(dest, state, op)
+	return fw
+}
+
+func putFrameWriter(fw *wsutil.Writer) {
+	fw.Reset(nil, 0, 0)
+	frameWriters.Put(fw)
+}
+
+// getCompressor 取出一个压缩级别为 level 的压缩器，输出到 dest
+func getCompressor(level int, dest io.Writer) *wsflate.Writer {
+	if fw, ok := compressors[level-flate.HuffmanOnly].Get().(*wsflate.Writer); ok {
+		fw.Reset(dest)
+		return fw
+	}
+	return wsflate.NewWriter(dest, func(w io.Writer) wsflate.Compressor {
+		// level 已经校验过，不会返回错误
+		f, _ := flate.NewWriter(w, level)
+		return f
+	})
+}
+
+// putCompressor 归还压缩器，不再引用输出目标，避免池中的压缩器让已关闭的连接无法回收
+func putCompressor(level int, fw *wsflate.Writer) {
+	fw.Reset(nil)
+	compressors[level-flate.HuffmanOnly].Put(fw)
+}
+
+// getDecompressor 取出一个从 src 读取压缩数据的解压器
+func getDecompressor(src io.Reader) *wsflate.Reader {
+	fr := decompressors.Get().(*wsflate.Reader)
+	fr.Reset(src)
+	return fr
+}
+
+func putDecompressor(fr *wsflate.Reader) {
+	fr.Reset(nil)
+	decompressors.Put(fr)
+}
+
+// readPayload 把 src 的剩余内容读入池化的缓冲区，limit 大于 0 时最多读取 limit+1 字节，
+// 调用方据此判断是否超过限制；出错时缓冲区已经归还
+func readPayload(src io.Reader, limit int64) ([]byte, error) {
+	bp := payloads.Get().(*[]byte)
+	b := (*bp)[:0]
+	for {
+		if len(b) == cap(b) {
+			b = slices.Grow(b, max(minPayloadGrow, len(b)))
+		}
+		buf := b[len(b):cap(b)]
+		if limit > 0 && int64(len(buf)) > limit+1-int64(len(b)) {
+			buf = buf[:limit+1-int64(len(b))]
+		}
+		n, err := src.Read(buf)
+		b = b[:len(b)+n]
+		switch {
+		case err == io.EOF:
+			return b, nil
+		case err != nil:
+			Release(b)
+			return nil, err
+		case limit > 0 && int64(len(b)) > limit:
+			return b, nil
+		}
+	}
+}
+
+// Release 归还 Reader.Read 返回的消息占用的缓冲区，供之后读取的消息复用，可以减少大量连接收发消息时的GC压力
+// 调用后不能再访问 p 及其子切片；不调用 Release 也不会泄漏，缓冲区由GC回收
+func Release(p []byte) {
+	if cap(p) == 0 || cap(p) > maxPooledPayload {
+		return
+	}
+	p = p[:0]
+	payloads.Put(&p)
+}
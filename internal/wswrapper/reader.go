@@ -1,15 +1,32 @@
 package wswrapper
 
 import (
-	"compress/flate"
+	"errors"
 	"io"
 	"net"
+	"os"
+	"time"
 
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws/wsutil"
 )
 
+var (
+	ErrMessageTooLarge  = errors.New("消息超过大小限制")       // 消息在线路上或解压后的大小超过限制
+	ErrTooManyFragments = errors.New("消息的分片数超过限制")     // 一条消息拆分成的帧过多
+	ErrFragmentTimeout  = errors.New("分片消息未在限定时间内收完") // 分片消息的后续帧迟迟不到
+)
+
+// Limits 读取一条消息时的限制，字段为 0 表示不限制
+// 消息在读取过程中逐帧检查，超过限制时立即返回错误，不会先把整条消息读入内存
+type Limits struct {
+	MaxMessageSize      int64         // 一条消息所有帧的负载之和，压缩的消息按压缩后的大小计算
+	MaxDecompressedSize int64         // 一条消息解压后的大小，防止少量压缩数据解压出大量内容；未压缩的消息同样适用
+	MaxFragments        int           // 一条消息最多拆分成的数据帧数
+	FragmentTimeout     time.Duration // 从收到分片消息的第一帧到收完最后一帧的最长时间
+}
+
 // Reader WebSocket连接读取器
 // 封装了WebSocket连接的读取功能，支持压缩数据的自动解压缩
 // 可以同时用于服务端和客户端模式
@@ -18,7 +35,13 @@ type Reader struct {
 	reader         *wsutil.Reader              // WebSocket帧读取器，负责解析WebSocket协议帧
 	controlHandler wsutil.FrameHandlerFunc     // 控制帧处理器，用于处理ping/pong/close等控制帧
 	messageState   *wsflate.MessageState       // 消息压缩状态管理器，跟踪压缩相关的状态信息
-	flateReader    *wsflate.Reader             // deflate解压缩读取器，用于解压缩接收到的数据
+	limits         Limits                      // 读取消息时的大小、分片数和分片超时限制
+	observer       CompressionObserver         // 压缩效果统计，为 nil 时不统计
+
+	// 当前消息的统计，收到消息的第一帧时重置
+	size      int64     // 已收到的帧负载之和
+	fragments int       // 已收到的数据帧数
+	deadline  time.Time // 分片消息必须收完的时间，未设置读超时时为零值
 }
 
 // NewServerSideReader 创建服务端模式的WebSocket读取器
@@ -38,9 +61,6 @@ func NewServerSideReader(conn net.Conn) *Reader {
 		},
 		controlHandler: controlHandler,
 		messageState:   messageState,
-		flateReader: wsflate.NewReader(nil, func(r io.Reader) wsflate.Decompressor {
-			return flate.NewReader(r) // 使用标准库的deflate解压缩实现
-		}),
 	}
 }
 
@@ -61,15 +81,85 @@ func NewClientSideReader(conn net.Conn) *Reader {
 		},
 		controlHandler: controlHandler,
 		messageState:   messageState,
-		flateReader: wsflate.NewReader(nil, func(r io.Reader) wsflate.Decompressor {
-			return flate.NewReader(r) // 使用标准库的deflate解压缩实现
-		}),
 	}
 }
 
+// SetLimits 设置读取消息时的限制，需要在开始读取前调用
+func (r *Reader) SetLimits(limits Limits) {
+	r.limits = limits
+	if limits.MaxMessageSize > 0 || limits.MaxFragments > 0 || limits.FragmentTimeout > 0 {
+		r.reader.OnContinuation = r.onContinuation
+	}
+}
+
+// SetCompressionObserver 设置压缩效果统计，之后读取的每条消息都会记录线路上和解压后的大小，需要在开始读取前调用
+func (r *Reader) SetCompressionObserver(o CompressionObserver) {
+	r.observer = o
+	// 分片消息线路上的大小需要累加后续帧的负载
+	r.reader.OnContinuation = r.onContinuation
+}
+
+// onContinuation 收到分片消息的后续帧时检查累计大小、分片数和超时
+func (r *Reader) onContinuation(header ws.Header, _ io.Reader) error {
+	r.fragments++
+	r.size += header.Length
+	return r.check()
+}
+
+// check 检查当前消息是否超过限制
+func (r *Reader) check() error {
+	switch {
+	case r.limits.MaxMessageSize > 0 && r.size > r.limits.MaxMessageSize:
+		return ErrMessageTooLarge
+	case r.limits.MaxFragments > 0 && r.fragments > r.limits.MaxFragments:
+		return ErrTooManyFragments
+	case !r.deadline.IsZero() && time.Now().After(r.deadline):
+		return ErrFragmentTimeout
+	}
+	return nil
+}
+
+// begin 收到一条消息的第一帧时重置统计，分片消息设置读超时，后续帧迟迟不到时读取返回超时错误
+func (r *Reader) begin(header ws.Header) error {
+	r.size, r.fragments, r.deadline = header.Length, 1, time.Time{}
+	if !header.Fin && r.limits.FragmentTimeout > 0 {
+		r.deadline = time.Now().Add(r.limits.FragmentTimeout)
+		if err := r.conn.SetReadDeadline(r.deadline); err != nil {
+			return err
+		}
+	}
+	return r.check()
+}
+
+// readAll 把消息的剩余内容读入池化的缓冲区，超过解压后的大小限制时返回 ErrMessageTooLarge
+func (r *Reader) readAll(src io.Reader) ([]byte, error) {
+	limit := r.limits.MaxDecompressedSize
+	payload, err := readPayload(src, limit)
+	if !r.deadline.IsZero() {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			err = ErrFragmentTimeout
+		}
+		// 分片消息读完后取消读超时，连接空闲时不受影响
+		if resetErr := r.conn.SetReadDeadline(time.Time{}); err == nil {
+			err = resetErr
+		}
+		r.deadline = time.Time{}
+	}
+	if err != nil {
+		Release(payload)
+		return nil, err
+	}
+	if limit > 0 && int64(len(payload)) > limit {
+		Release(payload)
+		return nil, ErrMessageTooLarge
+	}
+	return payload, nil
+}
+
 // Read 从WebSocket连接中读取一条完整的消息
 // 该方法会自动处理WebSocket协议的各种帧类型，包括控制帧和数据帧
 // 对于压缩的数据会自动进行解压缩处理
+// 返回的消息使用池化的缓冲区，使用完后可以调用 Release 归还
 func (r *Reader) Read() (payload []byte, err error) {
 	// 循环读取WebSocket帧，直到获取到数据帧
 	for {
@@ -88,13 +178,25 @@ func (r *Reader) Read() (payload []byte, err error) {
 			continue // 控制帧处理完毕，继续读取下一帧
 		}
 
+		// 数据帧：在读取负载前检查消息头声明的大小
+		if err2 := r.begin(header); err2 != nil {
+			return nil, err2
+		}
+
 		// 处理数据帧：检查消息是否被压缩
-		if r.messageState.IsCompressed() {
-			// 如果数据被压缩，使用deflate解压缩器进行解压
-			r.flateReader.Reset(r.reader)
-			return io.ReadAll(r.flateReader)
+		compressed := r.messageState.IsCompressed()
+		if compressed {
+			// 如果数据被压缩，从池中取出deflate解压缩器进行解压，读完即归还
+			fr := getDecompressor(r.reader)
+			payload, err = r.readAll(fr)
+			putDecompressor(fr)
+		} else {
+			// 如果数据未压缩，直接读取原始数据
+			payload, err = r.readAll(r.reader)
+		}
+		if err == nil && r.observer != nil {
+			r.observer.Observe(len(payload), int(r.size), compressed)
 		}
-		// 如果数据未压缩，直接读取原始数据
-		return io.ReadAll(r.reader)
+		return payload, err
 	}
 }
\ No newline at end of file
@@ -2,83 +2,218 @@ package wswrapper
 
 import (
 	"compress/flate"
+	"errors"
 	"io"
+	"time"
 
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws/wsutil"
 )
 
+// ErrInvalidOpCode 消息操作码不是文本帧或二进制帧
+var ErrInvalidOpCode = errors.New("消息操作码只能是文本帧或二进制帧")
+
+// CompressionGuard 限制压缩消耗的CPU时间
+type CompressionGuard interface {
+	// Allow 返回是否压缩下一条消息
+	Allow() bool
+	// Spend 记录一次压缩耗费的时间
+	Spend(d time.Duration)
+}
+
+// CompressionObserver 观察每条数据消息压缩前后的大小，用于统计压缩效果
+type CompressionObserver interface {
+	// Observe 记录一条消息，raw 为压缩前（解压后）的大小，wire 为线路上的负载大小，compressed 表示消息是否经过压缩
+	Observe(raw, wire int, compressed bool)
+}
+
 // Writer WebSocket连接写入器
 // 封装了WebSocket连接的写入功能，支持压缩和未压缩数据的发送
 // 与Reader不同，Writer接受io.Writer接口，提供更灵活的输出目标
+//
+// 帧缓冲区和deflate压缩器只在写入一条消息期间从池中取出，空闲的连接不占用这部分内存
 type Writer struct {
-	writer       *wsutil.Writer          // WebSocket帧写入器，负责构造和发送WebSocket协议帧
-	messageState *wsflate.MessageState   // 消息压缩状态管理器，控制是否启用压缩
-	flateWriter  *wsflate.Writer         // deflate压缩写入器，用于压缩待发送的数据（仅在压缩模式下使用）
+	dest         io.Writer                // 输出目标
+	state        ws.State                 // 服务端或客户端模式
+	messageState *wsflate.MessageState    // 消息压缩状态管理器，控制是否启用压缩
+	extensions   []wsutil.SendExtension   // 注册到帧写入器的扩展，只包含 messageState
+	opCode       ws.OpCode                // Write 使用的默认操作码
+	compressed   bool                     // Write 默认是否压缩，仅在协商了压缩时可以为 true
+	negotiated   bool                     // 握手时是否协商了压缩
+	level        int                      // deflate压缩级别
+	guard        CompressionGuard         // 压缩CPU预算，为 nil 时不限制
+	observer     CompressionObserver      // 压缩效果统计，为 nil 时不统计
+	wire         countingWriter           // 统计压缩后写入帧的字节数，只在写入一条消息期间使用
 }
 
 // NewServerSideWriter 创建服务端模式的WebSocket写入器
 // 用于服务端向客户端发送WebSocket消息，支持可选的数据压缩
+// compressed 表示握手时是否协商了 permessage-deflate，协商成功后才能按消息选择是否压缩
 func NewServerSideWriter(dest io.Writer, compressed bool) *Writer {
+	// 设置WebSocket状态：服务端模式 + 扩展支持
+	return newWriter(dest, ws.StateServerSide|ws.StateExtended, compressed)
+}
+
+// NewClientSideWriter 创建客户端模式的WebSocket写入器
+// 用于客户端向服务端发送WebSocket消息，按协议要求为每一帧加掩码，其它行为与服务端模式相同
+func NewClientSideWriter(dest io.Writer, compressed bool) *Writer {
+	// 设置WebSocket状态：客户端模式 + 扩展支持
+	return newWriter(dest, ws.StateClientSide|ws.StateExtended, compressed)
+}
+
+func newWriter(dest io.Writer, state ws.State, compressed bool) *Writer {
 	// 创建并配置消息压缩状态
 	messageState := wsflate.MessageState{}
 	messageState.SetCompressed(compressed)
 	
-	// 设置WebSocket状态：服务端模式 + 扩展支持
-	state := ws.StateServerSide | ws.StateExtended
 	// 使用二进制操作码，适合传输各种类型的数据
 	opCode := ws.OpBinary
 	
-	w := &Writer{
-		writer:       wsutil.NewWriter(dest, state, opCode), // 创建底层WebSocket写入器
+	// 将压缩状态注册到WebSocket写入器的扩展中，写入每条消息时设置到从池中取出的帧写入器
+	return &Writer{
+		dest:         dest,
+		state:        state,
 		messageState: &messageState,
+		extensions:   []wsutil.SendExtension{&messageState},
+		opCode:       opCode,
+		compressed:   compressed,
+		negotiated:   compressed,
+		level:        flate.DefaultCompression, // 默认使用标准库的默认压缩级别
 	}
-	
-	// 如果启用压缩，初始化deflate压缩写入器
-	if compressed {
-		w.flateWriter = wsflate.NewWriter(nil, func(w io.Writer) wsflate.Compressor {
-			// 使用标准库的deflate压缩器，采用默认压缩级别
-			f, _ := flate.NewWriter(w, flate.DefaultCompression)
-			return f
-		})
+}
+
+// SetOpCode 设置 Write 使用的默认操作码，只能是 ws.OpText 或 ws.OpBinary
+// 面向JSON等文本协议的客户端应使用 ws.OpText，浏览器端才能直接以字符串接收
+func (w *Writer) SetOpCode(op ws.OpCode) {
+	w.opCode = op
+}
+
+// SetCompressed 设置 Write 默认是否压缩，握手时未协商压缩时忽略
+func (w *Writer) SetCompressed(compressed bool) {
+	w.compressed = compressed && w.negotiated
+}
+
+// SetCompressionLevel 设置deflate压缩级别 (flate.HuffmanOnly ~ flate.BestCompression)，无效的级别被忽略
+func (w *Writer) SetCompressionLevel(level int) {
+	if validLevel(level) {
+		w.level = level
 	}
-	
-	// 将压缩状态注册到WebSocket写入器的扩展中
-	w.writer.SetExtensions(&messageState)
-	return w
+}
+
+// SetCompressionGuard 设置压缩CPU预算，预算耗尽时本应压缩的消息不压缩直接发送
+func (w *Writer) SetCompressionGuard(g CompressionGuard) {
+	w.guard = g
+}
+
+// SetCompressionObserver 设置压缩效果统计，之后写入的每条消息都会记录压缩前后的大小
+func (w *Writer) SetCompressionObserver(o CompressionObserver) {
+	w.observer = o
+}
+
+// CompressionEnabled 返回握手时是否协商了压缩
+func (w *Writer) CompressionEnabled() bool {
+	return w.negotiated
+}
+
+// WriteText 以文本帧写入一条完整的消息，是否压缩与 Write 相同
+func (w *Writer) WriteText(p []byte) error {
+	return w.WriteMessage(ws.OpText, p)
+}
+
+// WriteMessage 以指定操作码写入一条完整的消息，是否压缩与 Write 相同
+func (w *Writer) WriteMessage(op ws.OpCode, p []byte) error {
+	_, err := w.write(op, p, w.compressed)
+	return err
+}
+
+// WriteMessageCompress 以指定操作码写入一条完整的消息，并按消息决定是否压缩
+// 例如已经压缩过的数据（图片、压缩包）或很短的消息不值得再压缩；
+// 握手时未协商压缩时 compress 会被忽略，始终发送原始数据
+func (w *Writer) WriteMessageCompress(op ws.OpCode, p []byte, compress bool) error {
+	_, err := w.write(op, p, compress && w.negotiated)
+	return err
+}
+
+func (w *Writer) write(op ws.OpCode, p []byte, compress bool) (n int, err error) {
+	if op != ws.OpText && op != ws.OpBinary {
+		return 0, ErrInvalidOpCode
+	}
+	// 操作码和压缩标记（RSV1）都在刷新帧时才写入帧头，因此每条消息开始前设置即可
+	writer := getFrameWriter(w.dest, w.state, op)
+	defer putFrameWriter(writer)
+	writer.SetExtensions(w.extensions...)
+	if compress && w.guard != nil && !w.guard.Allow() {
+		compress = false
+	}
+	w.messageState.SetCompressed(compress)
+	if compress {
+		return w.writeCompressed(writer, p)
+	}
+	return w.writeUncompressed(writer, p)
 }
 
 // writeCompressed 写入压缩消息的内部实现
 // 使用deflate算法压缩数据后发送，可以显著减少网络传输量
-func (w *Writer) writeCompressed(p []byte) (n int, err error) {
-	// 重置deflate压缩写入器，将输出目标设置为WebSocket写入器
-	w.flateWriter.Reset(w.writer)
+func (w *Writer) writeCompressed(writer *wsutil.Writer, p []byte) (n int, err error) {
+	// 从池中取出deflate压缩写入器，将输出目标设置为WebSocket写入器，经过计数以统计压缩后的大小
+	w.wire = countingWriter{dest: writer}
+	flateWriter := getCompressor(w.level, &w.wire)
+	defer putCompressor(w.level, flateWriter)
+	start := time.Now()
 
 	// 将原始数据写入压缩器，数据会被自动压缩
-	n, err = w.flateWriter.Write(p)
+	n, err = flateWriter.Write(p)
 	if err != nil {
 		return 0, err
 	}
 
-	// 关闭deflate写入器，这会写入压缩结束标记并完成压缩流
-	err = w.flateWriter.Close()
+	// 以同步刷新结束压缩流：permessage-deflate 要求每条消息以 0x0000ffff 结尾（发送时去掉），
+	// flate.Writer.Close 写入的是最终块而不是同步标记，会被 wsflate 判定为错误的压缩流
+	err = flateWriter.Flush()
 	if err != nil {
 		return 0, err
 	}
+	if w.guard != nil {
+		// 不计入最后刷新到网络的时间，只统计压缩本身的耗时
+		w.guard.Spend(time.Since(start))
+	}
+	if w.observer != nil {
+		w.observer.Observe(n, w.wire.n, true)
+	}
 
 	// 刷新WebSocket写入器，确保压缩后的数据立即通过网络发送
-	return n, w.writer.Flush()
+	return n, writer.Flush()
 }
 
 // writeUncompressed 写入未压缩消息的内部实现
 // 直接发送原始数据，适用于已经压缩的数据或不需要压缩的场景
-func (w *Writer) writeUncompressed(p []byte) (n int, err error) {
+func (w *Writer) writeUncompressed(writer *wsutil.Writer, p []byte) (n int, err error) {
 	// 将原始数据直接写入WebSocket写入器，不进行任何压缩处理
-	n, err = w.writer.Write(p)
+	n, err = writer.Write(p)
 	if err != nil {
 		return 0, err
 	}
+	if w.observer != nil {
+		w.observer.Observe(n, n, false)
+	}
 	// 刷新WebSocket写入器，确保数据立即通过网络发送
-	return n, w.writer.Flush()
-}
\ No newline at end of file
+	return n, writer.Flush()
+}
+// Write 写入一条完整的WebSocket消息
+// 使用默认操作码（二进制，可通过 SetOpCode 修改），协商了压缩时压缩后发送，否则直接发送原始数据
+func (w *Writer) Write(p []byte) (n int, err error) {
+	return w.write(w.opCode, p, w.compressed)
+}
+
+// countingWriter 统计写入字节数的写入器
+type countingWriter struct {
+	dest io.Writer
+	n    int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.dest.Write(p)
+	c.n += n
+	return n, err
+}
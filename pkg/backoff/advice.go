@@ -0,0 +1,103 @@
+// Package backoff 定义网关在因负载原因关闭连接时下发给客户端的重连退避建议。
+//
+// 建议以紧凑的 JSON 编码在关闭帧的 reason 中（关闭帧 reason 最多 123 字节），
+// 遵守约定的客户端 SDK 解析后按建议的区间和抖动分散重连，避免摘流、限流、扩容时的重连风暴。
+package backoff
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+// StatusTryAgainLater 关闭码 4013，表示服务端暂时无法处理，客户端应稍后重连
+// 语义对应 IANA 注册的 1013 (Try Again Later)，但 gobwas/ws 等客户端实现会把规范外的 1xxx 关闭码视为协议错误，
+// 因此使用私有范围的 4013
+const StatusTryAgainLater ws.StatusCode = 4013
+
+// Reason 负载相关的关闭原因
+type Reason string
+
+const (
+	ReasonDrain     Reason = "drain"      // 节点下线或重启前摘流
+	ReasonRateLimit Reason = "rate_limit" // 触发限流
+	ReasonCapacity  Reason = "capacity"   // 节点连接数达到上限
+	ReasonBanned    Reason = "banned"     // 客户端因滥用被临时封禁
+)
+
+// maxReasonSize 关闭帧 reason 的最大字节数：控制帧负载最多 125 字节，减去 2 字节关闭码
+const maxReasonSize = 123
+
+// Advice 重连退避建议
+//
+// 客户端第 n 次（从 0 开始）重连前的等待时间为 min(Max, Min*2^n)，
+// 再在此基础上随机减少至多 Jitter 比例，使同一批被关闭的客户端错开重连
+type Advice struct {
+	Reason Reason
+	Min    time.Duration
+	Max    time.Duration
+	Jitter float64 // 取值范围 [0, 1]
+}
+
+// wireAdvice Advice 在关闭帧中的编码格式，使用短字段名和毫秒单位以节省空间
+type wireAdvice struct {
+	Reason Reason  `json:"r"`
+	Min    int64   `json:"min"`
+	Max    int64   `json:"max"`
+	Jitter float64 `json:"j,omitempty"`
+}
+
+// Encode 将建议编码为关闭帧的 reason
+func (a Advice) Encode() string {
+	b, err := json.Marshal(wireAdvice{
+		Reason: a.Reason,
+		Min:    a.Min.Milliseconds(),
+		Max:    a.Max.Milliseconds(),
+		Jitter: a.Jitter,
+	})
+	if err != nil || len(b) > maxReasonSize {
+		// 编码失败或超长时退化为只携带原因，客户端按自身默认策略退避
+		return string(a.Reason)
+	}
+	return string(b)
+}
+
+// Parse 从关闭帧的 reason 中解析重连建议，reason 不是建议格式时返回 false
+func Parse(reason string) (Advice, bool) {
+	if len(reason) == 0 || reason[0] != '{' {
+		return Advice{}, false
+	}
+	var w wireAdvice
+	if err := json.Unmarshal([]byte(reason), &w); err != nil || w.Reason == "" {
+		return Advice{}, false
+	}
+	return Advice{
+		Reason: w.Reason,
+		Min:    time.Duration(w.Min) * time.Millisecond,
+		Max:    time.Duration(w.Max) * time.Millisecond,
+		Jitter: w.Jitter,
+	}, true
+}
+
+// BanAdvice 被封禁客户端的退避建议：至少等待剩余的封禁时长，不做抖动以免提前重连再次被拒绝
+func BanAdvice(remaining time.Duration) Advice {
+	return Advice{Reason: ReasonBanned, Min: remaining, Max: remaining}
+}
+
+// Delay 计算第 attempt 次（从 0 开始）重连前应等待的时间
+func (a Advice) Delay(attempt int) time.Duration {
+	d := a.Min
+	for i := 0; i < attempt && d < a.Max; i++ {
+		d *= 2
+	}
+	if d > a.Max {
+		d = a.Max
+	}
+	jitter := min(max(a.Jitter, 0), 1)
+	if jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	return d
+}
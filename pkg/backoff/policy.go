@@ -0,0 +1,44 @@
+package backoff
+
+import (
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// Policies 各关闭原因对应的退避建议，来自配置
+type Policies struct {
+	advices map[Reason]Advice
+}
+
+func NewPolicies(i do.Injector) (*Policies, error) {
+	cfg, err := do.Invoke[config.BackoffConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Policies{
+		advices: map[Reason]Advice{
+			ReasonDrain:     toAdvice(ReasonDrain, cfg.Drain),
+			ReasonRateLimit: toAdvice(ReasonRateLimit, cfg.RateLimit),
+			ReasonCapacity:  toAdvice(ReasonCapacity, cfg.Capacity),
+		},
+	}, nil
+}
+
+// Advice 返回指定关闭原因的退避建议
+func (p *Policies) Advice(reason Reason) Advice {
+	if a, ok := p.advices[reason]; ok {
+		return a
+	}
+	return Advice{Reason: reason}
+}
+
+func toAdvice(reason Reason, cfg config.BackoffPolicyConfig) Advice {
+	return Advice{
+		Reason: reason,
+		Min:    time.Duration(cfg.Min),
+		Max:    time.Duration(cfg.Max),
+		Jitter: cfg.Jitter,
+	}
+}
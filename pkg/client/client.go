@@ -0,0 +1,509 @@
+// Package client 提供连接网关的 Go 客户端 SDK。
+//
+// 客户端按网关的握手约定发起连接（?token=、?codec=、?deviceId= 查询参数，令牌也可以通过 Authorization 请求头携带），可选协商 permessage-deflate 压缩
+// 和与 TLS 无关的消息加密，连接断开后按重连策略自动重连，重连时总是携带最新的令牌并重新协商加密密钥。
+// Send/Receive 与网关一侧的 types.Link 对应：Send 把消息放入发送缓冲区后立即返回，
+// Receive 返回按到达顺序接收消息的通道，客户端停止后该通道被关闭。
+//
+//	c, err := client.Dial(ctx, "ws://gateway:8080/", client.WithToken(token))
+//	if err != nil { ... }
+//	defer c.Close()
+//	_ = c.SendMessage(&gatewayapiv1.Message{Cmd: gatewayapiv1.Message_COMMAND_TYPE_UPSTREAM_MESSAGE, Key: key, Body: body})
+//	for payload := range c.Receive() { ... }
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/YaoAzure/wsgateway/internal/wswrapper"
+	"github.com/YaoAzure/wsgateway/pkg/backoff"
+	"github.com/YaoAzure/wsgateway/pkg/encryption"
+	"github.com/YaoAzure/wsgateway/pkg/message"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+)
+
+var (
+	ErrClosed           = errors.New("客户端已关闭")
+	ErrSendBufferIsFull = errors.New("发送缓冲区已满")
+	ErrReplaced         = errors.New("连接被同一用户的新连接取代")
+	ErrRetriesExhausted = errors.New("重连次数已用完")
+	// ErrEncryptionDeclined 表示请求了加密而网关没有对该业务方启用加密
+	ErrEncryptionDeclined = errors.New("网关未启用消息加密")
+)
+
+// StatusReplaced 网关关闭被同一用户的新连接取代的连接时使用的关闭码
+// 收到后客户端停止而不是重连，否则会与新连接相互踢下线
+const StatusReplaced ws.StatusCode = 4409
+
+// Client 网关客户端
+//
+// 同一时刻只持有一个连接：读协程把收到的消息投递到接收通道，接收通道满时阻塞，把背压传导给网关；
+// 写协程从发送缓冲区中取出消息写入连接。连接断开时正在写入的消息可能丢失，需要可靠投递的消息由业务层确认重传
+type Client struct {
+	target *url.URL
+	opts   options
+	codec  message.Codec
+
+	sendCh    chan []byte
+	receiveCh chan []byte
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{} // 客户端停止、接收通道关闭后关闭
+
+	mu          sync.Mutex
+	conn        net.Conn // 当前连接，重连期间为 nil
+	err         error    // 客户端停止的原因
+	resumeToken string   // 网关最近下发的恢复令牌，启用 WithResume 时记录
+}
+
+// Dial 连接网关，首次连接失败时直接返回错误，之后连接断开时在后台自动重连
+// target 为网关的 WebSocket 地址，例如 ws://gateway:8080/，其中的查询参数会保留
+func Dial(ctx context.Context, target string, opts ...Option) (*Client, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	codec, ok := message.Lookup(o.codec)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", message.ErrUnknownCodec, o.codec)
+	}
+	c := &Client{
+		target:    u,
+		opts:      o,
+		codec:     codec,
+		sendCh:    make(chan []byte, o.sendBuffer),
+		receiveCh: make(chan []byte, o.recvBuffer),
+		closeCh:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go c.run(cn)
+	return c, nil
+}
+
+// Codec 返回握手时选择的消息编解码器，用于解码 Receive 收到的消息
+func (c *Client) Codec() message.Codec {
+	return c.codec
+}
+
+// SendMessage 以握手时选择的编解码器编码消息后发送
+func (c *Client) SendMessage(msg *gatewayapiv1.Message) error {
+	payload, err := c.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.Send(payload)
+}
+
+// Send 把一条已编码的消息放入发送缓冲区，不会阻塞
+// 重连期间的消息同样放入缓冲区，连接恢复后按顺序发送
+func (c *Client) Send(msg []byte) error {
+	select {
+	case <-c.closeCh:
+		return ErrClosed
+	default:
+	}
+	select {
+	case c.sendCh <- msg:
+		return nil
+	default:
+		return ErrSendBufferIsFull
+	}
+}
+
+// Receive 返回接收消息的通道，客户端停止后关闭
+func (c *Client) Receive() <-chan []byte {
+	return c.receiveCh
+}
+
+// Close 以 1000 关闭当前连接并停止重连，阻塞直到客户端停止
+func (c *Client) Close() error {
+	c.shutdown(ErrClosed)
+	<-c.done
+	return nil
+}
+
+// HasClose 返回一个在客户端停止（调用 Close、被取代或重连失败）后关闭的通道
+func (c *Client) HasClose() <-chan struct{} {
+	return c.done
+}
+
+// Err 返回客户端停止的原因，客户端仍在运行时返回 nil
+// 主动调用 Close 时为 ErrClosed，被同一用户的新连接取代时为 ErrReplaced
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Connected 返回当前是否持有连接，重连期间为 false
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// shutdown 记录停止原因并关闭当前连接，只有第一次调用生效
+func (c *Client) shutdown(reason error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = reason
+		conn := c.conn
+		close(c.closeCh)
+		c.mu.Unlock()
+		if conn != nil {
+			frame := ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))
+			_ = ws.WriteFrame(conn, ws.MaskFrameInPlace(frame))
+			_ = conn.Close()
+		}
+	})
+}
+
+// setConn 设置当前连接，客户端已停止时返回 false，由调用方关闭连接
+func (c *Client) setConn(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn != nil {
+		select {
+		case <-c.closeCh:
+			return false
+		default:
+		}
+	}
+	c.conn = conn
+	return true
+}
+
+// run 持有连接直到断开，再按重连策略重连，客户端停止时关闭接收通道
+func (c *Client) run(cn *conn) {
+	defer close(c.done)
+	defer close(c.receiveCh)
+	for {
+		cause := c.serve(cn)
+		var err error
+		cn, err = c.reconnect(cause)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+	}
+}
+
+// serve 在一个连接上收发消息，阻塞直到连接断开，返回断开的原因
+func (c *Client) serve(cn *conn) error {
+	if !c.setConn(cn) {
+		_ = cn.Close()
+		return ErrClosed
+	}
+	defer c.setConn(nil)
+
+	writer := wswrapper.NewClientSideWriter(cn, cn.compressed)
+	writer.SetOpCode(c.codec.OpCode())
+	if cn.cipher != nil {
+		// 密文不是合法的 UTF-8，加密后统一使用二进制帧；密文无法压缩，不再压缩上行消息
+		writer.SetOpCode(ws.OpBinary)
+		writer.SetCompressed(false)
+	}
+	stop := make(chan struct{})
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		c.writeLoop(cn, writer, stop)
+	}()
+
+	err := c.readLoop(cn, wswrapper.NewClientSideReader(cn))
+	close(stop)
+	_ = cn.Close()
+	<-written
+	return err
+}
+
+// readLoop 读取消息并投递到接收通道，直到连接断开或客户端停止
+func (c *Client) readLoop(cn *conn, r *wswrapper.Reader) error {
+	for {
+		payload, err := r.Read()
+		if err != nil {
+			return err
+		}
+		if cn.cipher != nil {
+			if payload, err = cn.cipher.Open(payload); err != nil {
+				return err
+			}
+		}
+		if c.opts.resume {
+			c.recordResumeToken(payload)
+		}
+		select {
+		case c.receiveCh <- payload:
+		case <-c.closeCh:
+			return ErrClosed
+		}
+	}
+}
+
+// recordResumeToken 记录 RESUME 消息中的恢复令牌，其它消息忽略
+func (c *Client) recordResumeToken(payload []byte) {
+	msg := &gatewayapiv1.Message{}
+	if err := c.codec.Unmarshal(payload, msg); err != nil || msg.GetCmd() != gatewayapiv1.Message_COMMAND_TYPE_RESUME {
+		return
+	}
+	c.mu.Lock()
+	c.resumeToken = msg.GetKey()
+	c.mu.Unlock()
+}
+
+// ResumeToken 返回网关最近下发的恢复令牌，未启用 WithResume 或网关未启用会话恢复时为空
+func (c *Client) ResumeToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resumeToken
+}
+
+// writeLoop 把发送缓冲区中的消息写入连接，写入失败时关闭连接，读协程随之退出
+func (c *Client) writeLoop(cn *conn, w *wswrapper.Writer, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case msg := <-c.sendCh:
+			if c.opts.writeTimeout > 0 {
+				_ = cn.SetWriteDeadline(time.Now().Add(c.opts.writeTimeout))
+			}
+			if cn.cipher != nil {
+				msg = cn.cipher.Seal(msg)
+			}
+			if _, err := w.Write(msg); err != nil {
+				c.opts.logger.Debug("发送消息失败", slog.Any("error", err))
+				_ = cn.Close()
+				return
+			}
+		}
+	}
+}
+
+// reconnect 按重连策略重连，cause 为上一个连接断开的原因
+// 网关附带了重连退避建议时按建议的间隔重连；被取代、握手被明确拒绝或重连次数用完时返回错误
+func (c *Client) reconnect(cause error) (*conn, error) {
+	select {
+	case <-c.closeCh:
+		return nil, ErrClosed
+	default:
+	}
+	var advice backoff.Advice
+	var hasAdvice bool
+	var closed wsutil.ClosedError
+	switch {
+	case errors.As(cause, &closed) && closed.Code == StatusReplaced:
+		return nil, ErrReplaced
+	case errors.As(cause, &closed):
+		advice, hasAdvice = backoff.Parse(closed.Reason)
+	}
+
+	retry := c.opts.retry
+	for attempt := 0; retry.MaxRetries < 0 || attempt < retry.MaxRetries; attempt++ {
+		delay := c.delay(attempt)
+		if hasAdvice {
+			delay = advice.Delay(attempt)
+		}
+		c.opts.logger.Info("连接断开，准备重连",
+			slog.Int("attempt", attempt+1), slog.Duration("delay", delay), slog.Any("cause", cause))
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.closeCh:
+			timer.Stop()
+			return nil, ErrClosed
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.opts.dialTimeout)
+		cn, err := c.dial(ctx)
+		cancel()
+		if err == nil {
+			c.opts.logger.Info("重连成功", slog.Int("attempt", attempt+1))
+			return cn, nil
+		}
+		if rejected(err) {
+			return nil, err
+		}
+		cause = err
+	}
+	return nil, fmt.Errorf("%w: %w", ErrRetriesExhausted, cause)
+}
+
+// delay 计算第 attempt 次（从 0 开始）重连前的等待时间：从 initInterval 开始翻倍直到 maxInterval
+func (c *Client) delay(attempt int) time.Duration {
+	d := time.Duration(c.opts.retry.InitInterval)
+	maxInterval := max(time.Duration(c.opts.retry.MaxInterval), d)
+	for i := 0; i < attempt && d < maxInterval; i++ {
+		d *= 2
+	}
+	return min(d, maxInterval)
+}
+
+// rejected 返回握手是否被网关明确拒绝（请求参数错误、令牌无效或被吊销、未启用加密），这种情况下重连没有意义
+func rejected(err error) bool {
+	if errors.Is(err, ErrEncryptionDeclined) {
+		return true
+	}
+	var status ws.StatusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch int(status) {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+// conn 一个已建立的连接及其握手时的协商结果
+type conn struct {
+	*syncConn
+	compressed bool
+	cipher     *encryption.Cipher // 协商了加密时的加解密器，未加密时为 nil
+}
+
+// dial 按网关的握手约定建立一个连接，请求了加密时还会读取网关下发的 KEY_EXCHANGE 消息
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	u := *c.target
+	query := u.Query()
+	header := c.opts.header
+	if c.opts.token != nil {
+		token, err := c.opts.token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("获取令牌失败: %w", err)
+		}
+		if c.opts.tokenHeader {
+			header = header.Clone()
+			if header == nil {
+				header = make(http.Header)
+			}
+			header.Set("Authorization", "Bearer "+token)
+		} else {
+			query.Set("token", token)
+		}
+	}
+	query.Set("codec", c.codec.Name())
+	if c.opts.deviceID != "" {
+		query.Set("deviceId", c.opts.deviceID)
+	}
+	// 每个连接使用新的临时密钥对，重连时密钥随之轮换
+	var priv *ecdh.PrivateKey
+	if c.opts.encryption {
+		var err error
+		if priv, err = encryption.GenerateKey(); err != nil {
+			return nil, err
+		}
+		query.Set("encKey", encryption.EncodePublicKey(priv.PublicKey()))
+	}
+	if token := c.ResumeToken(); token != "" {
+		query.Set("resume", token)
+	}
+	u.RawQuery = query.Encode()
+
+	dialer := ws.Dialer{}
+	if header != nil {
+		dialer.Header = ws.HandshakeHeaderHTTP(header)
+	}
+	if c.opts.compression != nil {
+		dialer.Extensions = []httphead.Option{c.opts.compression.Option()}
+	}
+	nc, br, hs, err := dialer.Dial(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	sc := &syncConn{Conn: nc}
+	if br != nil {
+		// 网关在握手响应之后立即发送的消息可能已经读入缓冲区
+		sc.r = br
+	}
+	cn := &conn{syncConn: sc, compressed: compressionAccepted(hs)}
+	if priv != nil {
+		if cn.cipher, err = c.exchangeKey(ctx, cn, priv); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// exchangeKey 读取网关在连接建立后首先下发的 KEY_EXCHANGE 消息，以网关的公钥创建加解密器
+func (c *Client) exchangeKey(ctx context.Context, cn *conn, priv *ecdh.PrivateKey) (*encryption.Cipher, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = cn.SetReadDeadline(deadline)
+		defer cn.SetReadDeadline(time.Time{})
+	}
+	payload, err := wswrapper.NewClientSideReader(cn).Read()
+	if err != nil {
+		return nil, err
+	}
+	msg := &gatewayapiv1.Message{}
+	if err := c.codec.Unmarshal(payload, msg); err != nil {
+		return nil, err
+	}
+	if msg.GetCmd() != gatewayapiv1.Message_COMMAND_TYPE_KEY_EXCHANGE {
+		return nil, fmt.Errorf("网关未下发加密协商结果，首条消息为 %s", msg.GetCmd())
+	}
+	if len(msg.GetBody()) == 0 {
+		return nil, ErrEncryptionDeclined
+	}
+	server, err := encryption.Curve.NewPublicKey(msg.GetBody())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", encryption.ErrInvalidPublicKey, err)
+	}
+	return encryption.NewClientCipher(priv, server)
+}
+
+// compressionAccepted 返回网关是否接受了压缩扩展
+func compressionAccepted(hs ws.Handshake) bool {
+	for _, opt := range hs.Extensions {
+		if bytes.Equal(opt.Name, wsflate.ExtensionNameBytes) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncConn 串行化对连接的写入：读协程回应 ping、close 控制帧与写协程发送消息可能同时发生
+type syncConn struct {
+	net.Conn
+	r  *bufio.Reader // 握手时读入的缓冲区，为 nil 时直接读连接
+	mu sync.Mutex
+}
+
+func (c *syncConn) Read(p []byte) (int, error) {
+	if c.r != nil {
+		return c.r.Read(p)
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *syncConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.Write(p)
+}
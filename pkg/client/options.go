@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/message"
+	"github.com/gobwas/ws/wsflate"
+)
+
+const (
+	defaultSendBuffer    = 256
+	defaultReceiveBuffer = 256
+	defaultDialTimeout   = 10 * time.Second
+	defaultWriteTimeout  = 10 * time.Second
+)
+
+// DefaultRetryStrategy 默认的重连策略：间隔从 1 秒开始翻倍直到 30 秒，不限重连次数
+var DefaultRetryStrategy = config.RetryStrategyConfig{
+	InitInterval: int64(time.Second),
+	MaxInterval:  int64(30 * time.Second),
+	MaxRetries:   -1,
+}
+
+// TokenSource 每次建立连接前获取令牌，令牌会过期时使用，重连时总是携带最新的令牌
+type TokenSource func(ctx context.Context) (string, error)
+
+// Option 定制客户端的选项
+type Option func(*options)
+
+type options struct {
+	token        TokenSource
+	tokenHeader  bool
+	codec        string
+	deviceID     string
+	header       http.Header
+	compression  *wsflate.Parameters
+	encryption   bool
+	resume       bool
+	retry        config.RetryStrategyConfig
+	sendBuffer   int
+	recvBuffer   int
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+	logger       *log.Logger
+}
+
+func defaultOptions() options {
+	return options{
+		codec:        message.CodecProtobuf,
+		retry:        DefaultRetryStrategy,
+		sendBuffer:   defaultSendBuffer,
+		recvBuffer:   defaultReceiveBuffer,
+		dialTimeout:  defaultDialTimeout,
+		writeTimeout: defaultWriteTimeout,
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// WithToken 以 ?token= 查询参数携带固定的令牌
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = func(context.Context) (string, error) { return token, nil }
+	}
+}
+
+// WithTokenSource 每次建立连接前从 source 获取令牌
+func WithTokenSource(source TokenSource) Option {
+	return func(o *options) { o.token = source }
+}
+
+// WithTokenInHeader 以 Authorization: Bearer 请求头代替 ?token= 查询参数携带令牌，令牌不会出现在代理的访问日志中
+// 网关配置了 server.websocket.token.disableQuery 时必须使用
+func WithTokenInHeader() Option {
+	return func(o *options) { o.tokenHeader = true }
+}
+
+// WithCodec 以 ?codec= 查询参数选择消息编解码器，默认 protobuf
+// 编解码器需要在客户端一侧同样注册，见 message.Register
+func WithCodec(name string) Option {
+	return func(o *options) { o.codec = name }
+}
+
+// WithDeviceID 以 ?deviceId= 查询参数上报设备ID，网关按设备ID执行多连接策略
+func WithDeviceID(id string) Option {
+	return func(o *options) { o.deviceID = id }
+}
+
+// WithHeader 设置握手请求额外的HTTP请求头，例如 X-AutoClose、Origin
+func WithHeader(header http.Header) Option {
+	return func(o *options) { o.header = header }
+}
+
+// DefaultCompression 默认的压缩参数，与网关的默认压缩配置 (窗口大小 15) 匹配
+// 网关按消息独立压缩，客户端同样按消息独立压缩和解压，不依赖上下文接管
+var DefaultCompression = wsflate.Parameters{ClientMaxWindowBits: 15}
+
+// WithCompression 在握手中以 params 请求 permessage-deflate 压缩，通常使用 DefaultCompression
+// 网关未启用压缩或不接受 params 时退化为不压缩，连接照常建立
+func WithCompression(params wsflate.Parameters) Option {
+	return func(o *options) { o.compression = &params }
+}
+
+// WithEncryption 以 ?encKey= 查询参数携带临时公钥，请求与 TLS 无关的消息加密
+// 每个连接都重新生成密钥对，重连时密钥随之轮换；网关未对该业务方启用加密时连接以 ErrEncryptionDeclined 失败，不会退化为明文
+func WithEncryption() Option {
+	return func(o *options) { o.encryption = true }
+}
+
+// WithResume 重连时以 ?resume= 查询参数携带网关最近下发的恢复令牌，网关补发断线期间未确认的推送
+// 客户端需要解码每条收到的消息以记录 RESUME 消息中的令牌；推送的确认 (带 seq 的 DOWNSTREAM_ACK) 和按 seq 去重仍由业务层负责
+func WithResume() Option {
+	return func(o *options) { o.resume = true }
+}
+
+// WithRetryStrategy 设置断线重连策略，默认为 DefaultRetryStrategy
+// 间隔从 initInterval 开始翻倍直到 maxInterval；maxRetries 为连续重连失败的最大次数，0 表示不重连，负数表示不限次数。
+// 网关关闭连接时附带了重连退避建议 (关闭码 4013) 时按建议的间隔重连
+func WithRetryStrategy(cfg config.RetryStrategyConfig) Option {
+	return func(o *options) { o.retry = cfg }
+}
+
+// WithBufferSize 设置发送和接收缓冲区的大小（消息数），默认均为 256
+// 重连期间 Send 的消息缓存在发送缓冲区中，连接恢复后按顺序发送
+func WithBufferSize(send, receive int) Option {
+	return func(o *options) {
+		o.sendBuffer = send
+		o.recvBuffer = receive
+	}
+}
+
+// WithDialTimeout 设置重连时单次握手的超时时间，默认 10 秒；首次连接的超时由 Dial 的 ctx 控制
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *options) { o.dialTimeout = d }
+}
+
+// WithWriteTimeout 设置单条消息的写超时时间，默认 10 秒，0 表示不设置
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) { o.writeTimeout = d }
+}
+
+// WithLogger 设置日志组件，默认丢弃所有日志
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
@@ -1,6 +1,8 @@
 package compression
 
 import (
+	"time"
+
 	"github.com/gobwas/ws/wsflate"
 )
 
@@ -18,6 +20,15 @@ type Config struct {
 	ClientNoContext bool `yaml:"clientNoContext"`
 	// Level 压缩级别，范围1-9，1为最快速度，9为最高压缩率
 	Level int `yaml:"level"`
+	// CPUBudget 压缩下行消息的CPU预算，耗尽时暂时不压缩
+	CPUBudget CPUBudget `yaml:"cpuBudget"`
+}
+
+// CPUBudget 压缩CPU预算，每 Interval 内最多用 Budget 的时间压缩下行消息
+type CPUBudget struct {
+	Enabled  bool
+	Budget   time.Duration
+	Interval time.Duration
 }
 
 // ToParameters 将配置转换为wsflate参数
@@ -0,0 +1,106 @@
+package compression
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+)
+
+// Guard 全局的压缩CPU预算，按令牌桶限制本节点每个周期内用于压缩下行消息的时间
+//
+// 令牌是压缩耗费的时间：每个周期补充 Budget，桶容量也是 Budget。广播风暴时压缩耗尽预算后，
+// 之后的消息不压缩直接发送 (permessage-deflate 允许逐条消息决定是否压缩)，直到预算恢复到一半，
+// 把CPU留给握手和心跳等对延迟敏感的工作。压缩和解压的正确性不受影响，只是下行流量变大
+type Guard struct {
+	enabled  bool
+	rate     float64 // 每纳秒补充的预算
+	capacity float64
+	metrics  *metrics.CompressionMetrics
+	logger   *log.Logger
+
+	mu       sync.Mutex
+	tokens   float64
+	last     time.Time
+	degraded bool
+}
+
+func NewGuard(i do.Injector) (*Guard, error) {
+	cfg, err := do.Invoke[Config](i)
+	if err != nil {
+		return nil, err
+	}
+	m, err := do.Invoke[*metrics.CompressionMetrics](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	b := cfg.CPUBudget
+	g := &Guard{
+		enabled: cfg.Enabled && b.Enabled,
+		metrics: m,
+		logger:  logger,
+		last:    time.Now(),
+	}
+	if g.enabled {
+		g.capacity = float64(b.Budget)
+		g.rate = float64(b.Budget) / float64(b.Interval)
+		g.tokens = g.capacity
+	}
+	return g, nil
+}
+
+// Allow 返回是否还有预算压缩下一条消息，预算耗尽时返回 false，消息应不压缩直接发送
+func (g *Guard) Allow() bool {
+	if !g.enabled {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.refill(time.Now())
+	// 降级后预算恢复到一半才恢复压缩，避免在预算边缘反复切换
+	if g.tokens > 0 && (!g.degraded || g.tokens >= g.capacity/2) {
+		if g.degraded {
+			g.degraded = false
+			g.metrics.SetDegraded(false)
+			g.logger.Info("压缩CPU预算已恢复，恢复压缩下行消息")
+		}
+		return true
+	}
+	if !g.degraded {
+		g.degraded = true
+		g.metrics.SetDegraded(true)
+		g.logger.Warn("压缩CPU预算耗尽，暂时不压缩下行消息", slog.Duration("budget", time.Duration(g.capacity)))
+	}
+	g.metrics.Skipped()
+	return false
+}
+
+// Spend 扣除一次压缩耗费的时间，预算可以透支，透支的部分由之后的周期偿还
+func (g *Guard) Spend(d time.Duration) {
+	g.metrics.Compressed(d)
+	if !g.enabled {
+		return
+	}
+	g.mu.Lock()
+	g.tokens -= float64(d)
+	g.mu.Unlock()
+}
+
+// Degraded 返回当前是否因预算耗尽而不压缩下行消息
+func (g *Guard) Degraded() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.degraded
+}
+
+func (g *Guard) refill(now time.Time) {
+	g.tokens = min(g.tokens+float64(now.Sub(g.last))*g.rate, g.capacity)
+	g.last = now
+}
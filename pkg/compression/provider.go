@@ -0,0 +1,36 @@
+package compression
+
+import (
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 Compression 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewConfig),
+	do.Lazy(NewGuard),
+)
+
+// NewConfig 从 WebSocket 服务配置中提取压缩配置
+func NewConfig(i do.Injector) (Config, error) {
+	cfg, err := do.Invoke[config.ServerConfig](i)
+	if err != nil {
+		return Config{}, err
+	}
+	c := cfg.Websocket.Compression
+	return Config{
+		Enabled:         c.Enabled,
+		ServerMaxWindow: c.ServerMaxWindow,
+		ClientMaxWindow: c.ClientMaxWindow,
+		ServerNoContext: c.ServerNoContext,
+		ClientNoContext: c.ClientNoContext,
+		Level:           c.Level,
+		CPUBudget: CPUBudget{
+			Enabled:  c.CPUBudget.Enabled,
+			Budget:   time.Duration(c.CPUBudget.Budget),
+			Interval: time.Duration(c.CPUBudget.Interval),
+		},
+	}, nil
+}
@@ -0,0 +1,107 @@
+package compression
+
+import (
+	"sync/atomic"
+
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/gobwas/ws/wsflate"
+)
+
+// defaultWindowBits 协商时没有指定窗口大小时 permessage-deflate 使用的窗口大小
+const defaultWindowBits = 15
+
+// Stats 一个连接的压缩效果统计，下行和上行分开统计
+// 写协程和读协程分别更新两个方向的计数，管理API随时读取快照；每条消息同时计入全局的压缩效果指标
+type Stats struct {
+	params   wsflate.Parameters
+	outbound Counter
+	inbound  Counter
+}
+
+// NewStats 为一个协商了压缩的连接创建统计，params 为协商结果，out 和 in 为两个方向的全局指标
+func NewStats(params wsflate.Parameters, out, in *metrics.CompressionDirection) *Stats {
+	return &Stats{
+		params:   params,
+		outbound: Counter{metrics: out},
+		inbound:  Counter{metrics: in},
+	}
+}
+
+// Outbound 返回下行消息的计数器，设置到连接的写入器上
+func (s *Stats) Outbound() *Counter { return &s.outbound }
+
+// Inbound 返回上行消息的计数器，设置到连接的读取器上
+func (s *Stats) Inbound() *Counter { return &s.inbound }
+
+// Snapshot 连接的压缩参数和两个方向的压缩效果
+type Snapshot struct {
+	ServerMaxWindowBits     int            `json:"serverMaxWindowBits"`
+	ClientMaxWindowBits     int            `json:"clientMaxWindowBits"`
+	ServerNoContextTakeover bool           `json:"serverNoContextTakeover,omitempty"`
+	ClientNoContextTakeover bool           `json:"clientNoContextTakeover,omitempty"`
+	Outbound                DirectionStats `json:"outbound"`
+	Inbound                 DirectionStats `json:"inbound"`
+}
+
+// DirectionStats 一个方向上的压缩效果，字节数只统计经过压缩的消息
+type DirectionStats struct {
+	Messages   int64   `json:"messages"`        // 数据消息数
+	Compressed int64   `json:"compressed"`      // 其中经过压缩的消息数
+	RawBytes   int64   `json:"rawBytes"`        // 压缩消息压缩前（解压后）的字节数
+	WireBytes  int64   `json:"wireBytes"`       // 压缩消息在线路上的负载字节数
+	Ratio      float64 `json:"ratio,omitempty"` // WireBytes 与 RawBytes 之比，越小压缩效果越好，没有压缩消息时为 0
+}
+
+// Snapshot 返回当前的统计
+func (s *Stats) Snapshot() Snapshot {
+	return Snapshot{
+		ServerMaxWindowBits:     windowBits(s.params.ServerMaxWindowBits),
+		ClientMaxWindowBits:     windowBits(s.params.ClientMaxWindowBits),
+		ServerNoContextTakeover: s.params.ServerNoContextTakeover,
+		ClientNoContextTakeover: s.params.ClientNoContextTakeover,
+		Outbound:                s.outbound.snapshot(),
+		Inbound:                 s.inbound.snapshot(),
+	}
+}
+
+func windowBits(b wsflate.WindowBits) int {
+	if !b.Defined() {
+		return defaultWindowBits
+	}
+	return int(b)
+}
+
+// Counter 一个方向上的压缩效果计数，实现 wswrapper.CompressionObserver
+type Counter struct {
+	messages   atomic.Int64
+	compressed atomic.Int64
+	rawBytes   atomic.Int64
+	wireBytes  atomic.Int64
+	metrics    *metrics.CompressionDirection
+}
+
+// Observe 记录一条消息，raw 为压缩前（解压后）的大小，wire 为线路上的负载大小
+func (c *Counter) Observe(raw, wire int, compressed bool) {
+	c.messages.Add(1)
+	if compressed {
+		c.compressed.Add(1)
+		c.rawBytes.Add(int64(raw))
+		c.wireBytes.Add(int64(wire))
+	}
+	if c.metrics != nil {
+		c.metrics.Observe(raw, wire, compressed)
+	}
+}
+
+func (c *Counter) snapshot() DirectionStats {
+	s := DirectionStats{
+		Messages:   c.messages.Load(),
+		Compressed: c.compressed.Load(),
+		RawBytes:   c.rawBytes.Load(),
+		WireBytes:  c.wireBytes.Load(),
+	}
+	if s.RawBytes > 0 {
+		s.Ratio = float64(s.WireBytes) / float64(s.RawBytes)
+	}
+	return s
+}
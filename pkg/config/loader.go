@@ -1,30 +1,98 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
 const DefaultConfigPath = "./config.yaml"
 
+// EnvPrefix is the prefix of environment variables that override config keys
+const EnvPrefix = "WSGATEWAY_"
+
+// ErrUnknownKeys is returned in strict mode when the config file contains keys
+// that are not mapped to the Config struct
+var ErrUnknownKeys = errors.New("unknown config keys")
+
+// ErrInvalidOverride is returned when a command-line override is not in the
+// key=value form or names a key that does not exist
+var ErrInvalidOverride = errors.New("invalid config override")
+
 // Loader handles configuration loading
+//
+// Every key can be overridden, with the precedence flags > env > file:
+//   - environment variables: EnvPrefix followed by the key path in upper snake
+//     case, e.g. WSGATEWAY_REDIS_ADDR overrides redis.addr and
+//     WSGATEWAY_SERVER_WEBSOCKET_TOKEN_LIMITER_MAX_CAPACITY overrides
+//     server.websocket.tokenLimiter.maxCapacity
+//   - command-line overrides: key=value pairs passed with WithOverrides, e.g.
+//     -set redis.addr=redis:6379
+//
+// Values are converted to the field type: durations accept both nanoseconds and
+// strings like "30s", string lists accept comma separated values, and lists of
+// objects (api.keys, backend.services...) accept JSON.
 type Loader struct {
-	configPath string
+	configPath  string
+	strict      bool
+	overrides   []string
+	unknownKeys []string
+}
+
+// LoaderOption configures a Loader
+type LoaderOption func(*Loader)
+
+// WithStrict makes Load fail when the config file contains unknown keys,
+// catching typos like "tokenLimitter" that would otherwise silently leave zero values
+func WithStrict(strict bool) LoaderOption {
+	return func(l *Loader) {
+		l.strict = strict
+	}
+}
+
+// WithOverrides overrides config keys with key=value pairs, typically collected
+// from repeated command-line flags; they take precedence over the environment
+// and the config file
+func WithOverrides(overrides ...string) LoaderOption {
+	return func(l *Loader) {
+		l.overrides = append(l.overrides, overrides...)
+	}
 }
 
 // NewLoader creates a new configuration loader
-func NewLoader(configPath string) *Loader {
+func NewLoader(configPath string, opts ...LoaderOption) *Loader {
 	if configPath == "" {
 		configPath = DefaultConfigPath
 	}
-	return &Loader{
+	l := &Loader{
 		configPath: configPath,
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// UnknownKeys returns the keys found in the config file during the last Load
+// that are not mapped to the Config struct. In lenient mode callers should log
+// them as warnings.
+func (l *Loader) UnknownKeys() []string {
+	return l.unknownKeys
 }
 
-// Load loads the configuration from the specified file
+// Load loads the configuration from the specified file and validates it,
+// returning a *ValidationError that lists every invalid value
 func (l *Loader) Load() (Config, error) {
 	v := viper.New()
 
@@ -49,15 +117,160 @@ func (l *Loader) Load() (Config, error) {
 		return Config{}, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Unmarshal config
+	// Bind every key to its environment variable, including keys missing from
+	// the file, then apply command-line overrides on top
+	keys := Keys()
+	for _, key := range keys {
+		if err := v.BindEnv(key, EnvName(key)); err != nil {
+			return Config{}, fmt.Errorf("failed to bind env for %s: %w", key, err)
+		}
+	}
+	for _, o := range l.overrides {
+		key, value, ok := strings.Cut(o, "=")
+		if !ok || key == "" {
+			return Config{}, fmt.Errorf("%w: %q, expected key=value", ErrInvalidOverride, o)
+		}
+		if !slices.ContainsFunc(keys, func(k string) bool { return strings.EqualFold(k, key) }) {
+			return Config{}, fmt.Errorf("%w: unknown key %q", ErrInvalidOverride, key)
+		}
+		v.Set(key, value)
+	}
+
+	// Unmarshal config, collecting keys that don't map to any field
 	var config Config
-	if err := v.Unmarshal(&config); err != nil {
+	var md mapstructure.Metadata
+	if err := v.Unmarshal(&config, func(dc *mapstructure.DecoderConfig) {
+		dc.Metadata = &md
+		dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(
+			stringToJSONHookFunc(),
+			stringToNanosecondsHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		)
+	}); err != nil {
 		return Config{}, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	l.unknownKeys = slices.Sorted(slices.Values(md.Unused))
+	if l.strict && len(l.unknownKeys) > 0 {
+		return Config{}, fmt.Errorf("%w: %s", ErrUnknownKeys, strings.Join(l.unknownKeys, ", "))
+	}
+
+	// Validate after env and -set overrides so the effective values are checked
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+
 	return config, nil
 }
 
+// Keys returns the path of every config key, e.g. "server.websocket.port"
+// Lists and maps are leaves: they are overridden as a whole
+func Keys() []string {
+	var keys []string
+	collectKeys(reflect.TypeFor[Config](), "", &keys)
+	return keys
+}
+
+func collectKeys(t reflect.Type, prefix string, keys *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if field.Type.Kind() == reflect.Struct {
+			collectKeys(field.Type, key, keys)
+			continue
+		}
+		*keys = append(*keys, key)
+	}
+}
+
+// EnvName returns the environment variable that overrides a config key, e.g.
+// "server.websocket.tokenLimiter" becomes WSGATEWAY_SERVER_WEBSOCKET_TOKEN_LIMITER
+func EnvName(key string) string {
+	var b strings.Builder
+	b.WriteString(EnvPrefix)
+	for i, part := range strings.Split(key, ".") {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+		runes := []rune(part)
+		for j, r := range runes {
+			// a word boundary is a lower-to-upper transition, or the last upper
+			// of an acronym followed by a lower, e.g. "tlsCAFile" -> TLS_CA_FILE
+			if j > 0 && unicode.IsUpper(r) &&
+				(unicode.IsLower(runes[j-1]) || (j+1 < len(runes) && unicode.IsLower(runes[j+1]) && unicode.IsUpper(runes[j-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// stringToJSONHookFunc decodes JSON strings from the environment or the
+// command line into lists, maps and structs, e.g.
+// WSGATEWAY_API_KEYS='[{"name":"ops","key":"..."}]'
+func stringToJSONHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from, to reflect.Type, data any) (any, error) {
+		s, ok := data.(string)
+		if !ok || from.Kind() != reflect.String {
+			return data, nil
+		}
+		switch to.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Struct:
+		default:
+			return data, nil
+		}
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "{") {
+			return data, nil
+		}
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON value: %w", err)
+		}
+		return v, nil
+	}
+}
+
+// stringToNanosecondsHookFunc accepts duration strings like "30s" for the
+// int64 nanosecond durations used throughout the config; plain integers are
+// left to the default conversion
+func stringToNanosecondsHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from, to reflect.Type, data any) (any, error) {
+		s, ok := data.(string)
+		if !ok || from.Kind() != reflect.String || to.Kind() != reflect.Int64 {
+			return data, nil
+		}
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return data, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return data, nil
+		}
+		return int64(d), nil
+	}
+}
+
+// LookupEnvOverrides returns the environment variables currently overriding
+// config keys, useful for logging where a setting came from
+func LookupEnvOverrides() []string {
+	var names []string
+	for _, key := range Keys() {
+		if _, ok := os.LookupEnv(EnvName(key)); ok {
+			names = append(names, EnvName(key))
+		}
+	}
+	return names
+}
+
 // LoadFromPath is a convenience function to load config from a specific path
 func LoadFromPath(configPath string) (Config, error) {
 	loader := NewLoader(configPath)
@@ -15,5 +15,33 @@ func NewPackage(config Config) func(do.Injector) {
 		do.Eager(config.Log),   // Log 配置
 		do.Eager(config.Server), // Server 配置
 		do.Eager(config.Link),  // Link 配置
+		do.Eager(config.Metrics), // Metrics 配置
+		do.Eager(config.Session), // Session 配置
+		do.Eager(config.API),     // 管理API 配置
+		do.Eager(config.History), // 连接历史 配置
+		do.Eager(config.Broker),  // 消息队列 配置
+		do.Eager(config.Backoff), // 重连退避 配置
+		do.Eager(config.Backend), // 业务后端 配置
+		do.Eager(config.RPC),     // 上行RPC 配置
+		do.Eager(config.Abuse),   // 滥用检测 配置
+		do.Eager(config.Message), // 消息编解码 配置
+		do.Eager(config.Webhook), // 业务方webhook 配置
+		do.Eager(config.Cluster), // 多节点部署 配置
+		do.Eager(config.Uniques), // 去重用户统计 配置
+		do.Eager(config.Admission), // 准入控制 配置
+		do.Eager(config.Revocation), // 令牌吊销 配置
+		do.Eager(config.Scaling),    // 自动扩缩容 配置
+		do.Eager(config.Incident),   // 事故记录 配置
+		do.Eager(config.Resume),     // 会话恢复 配置
+		do.Eager(config.Offline),    // 离线消息 配置
+		do.Eager(config.Rooms),      // 房间 配置
+		do.Eager(config.Degrade),    // 慢速连接降级 配置
+		do.Eager(config.Fanout),     // 群发调度 配置
+		do.Eager(config.GeoIP),      // 地理位置解析 配置
+		do.Eager(config.Guest),      // 访客连接 配置
+		do.Eager(config.Tracing),    // 链路追踪 配置
+		do.Eager(config.Audit),      // 连接审计日志 配置
+		do.Eager(config.Usage),      // 用量报告 配置
+		do.Eager(config.Debug),      // 调试服务 配置
 	)
 }
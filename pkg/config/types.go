@@ -1,5 +1,7 @@
 package config
 
+import "os"
+
 // Config represents the application configuration
 type Config struct {
 	App    AppConfig    `yaml:"app" mapstructure:"app"`
@@ -8,24 +10,92 @@ type Config struct {
 	Log    LogConfig    `yaml:"log" mapstructure:"log"`
 	Server ServerConfig `yaml:"server" mapstructure:"server"`
 	Link   LinkConfig   `yaml:"link" mapstructure:"link"`
+	Metrics MetricsConfig `yaml:"metrics" mapstructure:"metrics"`
+	Session SessionConfig `yaml:"session" mapstructure:"session"`
+	API     APIConfig     `yaml:"api" mapstructure:"api"`
+	History HistoryConfig `yaml:"history" mapstructure:"history"`
+	Broker  BrokerConfig  `yaml:"broker" mapstructure:"broker"`
+	Backoff BackoffConfig `yaml:"backoff" mapstructure:"backoff"`
+	Backend BackendConfig `yaml:"backend" mapstructure:"backend"`
+	RPC     RPCConfig     `yaml:"rpc" mapstructure:"rpc"`
+	Abuse   AbuseConfig   `yaml:"abuse" mapstructure:"abuse"`
+	Message MessageConfig `yaml:"message" mapstructure:"message"`
+	Webhook WebhookConfig `yaml:"webhook" mapstructure:"webhook"`
+	Cluster ClusterConfig `yaml:"cluster" mapstructure:"cluster"`
+	Uniques UniquesConfig `yaml:"uniques" mapstructure:"uniques"`
+	Admission AdmissionConfig `yaml:"admission" mapstructure:"admission"`
+	Revocation RevocationConfig `yaml:"revocation" mapstructure:"revocation"`
+	Scaling    ScalingConfig    `yaml:"scaling" mapstructure:"scaling"`
+	Incident   IncidentConfig   `yaml:"incident" mapstructure:"incident"`
+	Resume     ResumeConfig     `yaml:"resume" mapstructure:"resume"`
+	Offline    OfflineConfig    `yaml:"offline" mapstructure:"offline"`
+	Rooms      RoomsConfig      `yaml:"rooms" mapstructure:"rooms"`
+	Degrade    DegradeConfig    `yaml:"degrade" mapstructure:"degrade"`
+	Fanout     FanoutConfig     `yaml:"fanout" mapstructure:"fanout"`
+	GeoIP      GeoIPConfig      `yaml:"geoip" mapstructure:"geoip"`
+	Guest      GuestConfig      `yaml:"guest" mapstructure:"guest"`
+	Tracing    TracingConfig    `yaml:"tracing" mapstructure:"tracing"`
+	Audit      AuditConfig      `yaml:"audit" mapstructure:"audit"`
+	Usage      UsageConfig      `yaml:"usage" mapstructure:"usage"`
+	Debug      DebugConfig      `yaml:"debug" mapstructure:"debug"`
 }
 
 // AppConfig represents the application-specific configuration
 type AppConfig struct {
-	Name string `yaml:"name" mapstructure:"name"`
-	Addr string `yaml:"addr" mapstructure:"addr"`
+	Name   string `yaml:"name" mapstructure:"name"`
+	Addr   string `yaml:"addr" mapstructure:"addr"`
+	NodeID string `yaml:"nodeId" mapstructure:"nodeId"`
+}
+
+// InstanceID returns the identity of this gateway node, falling back to the
+// hostname when nodeId is not configured (the pod name in Kubernetes)
+func (a AppConfig) InstanceID() string {
+	if a.NodeID != "" {
+		return a.NodeID
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return a.Name
 }
 
 type JWTConfig struct {
-	Key    string `yaml:"key" mapstructure:"key"`
-	Issuer string `yaml:"issuer" mapstructure:"issuer"`
+	Key               string `yaml:"key" mapstructure:"key"`
+	Issuer            string `yaml:"issuer" mapstructure:"issuer"`
+	DecisionCacheTTL  int64  `yaml:"decisionCacheTTL" mapstructure:"decisionCacheTTL"`
+	DecisionCacheSize int    `yaml:"decisionCacheSize" mapstructure:"decisionCacheSize"`
+	PublicKeys        []JWTPublicKeyConfig `yaml:"publicKeys" mapstructure:"publicKeys"`
+	JWKS              JWKSConfig           `yaml:"jwks" mapstructure:"jwks"`
+}
+
+// JWTPublicKeyConfig 验证非对称签名令牌的公钥，PEM 和 File 二选一
+type JWTPublicKeyConfig struct {
+	KID       string `yaml:"kid" mapstructure:"kid"`
+	Algorithm string `yaml:"algorithm" mapstructure:"algorithm"`
+	PEM       string `yaml:"pem" mapstructure:"pem"`
+	File      string `yaml:"file" mapstructure:"file"`
+}
+
+// JWKSConfig 从身份提供方的 JWKS 地址获取验证公钥
+type JWKSConfig struct {
+	URL             string `yaml:"url" mapstructure:"url"`
+	RefreshInterval int64  `yaml:"refreshInterval" mapstructure:"refreshInterval"`
+	Timeout         int64  `yaml:"timeout" mapstructure:"timeout"`
 }
 
 type RedisConfig struct {
-	Addr     string `yaml:"addr" mapstructure:"addr"`
-	Password string `yaml:"password" mapstructure:"password"`
-	DB       int    `yaml:"db" mapstructure:"db"`
-	PoolSize int    `yaml:"pool_size" mapstructure:"pool_size"`
+	Addr     string             `yaml:"addr" mapstructure:"addr"`
+	Password string             `yaml:"password" mapstructure:"password"`
+	DB       int                `yaml:"db" mapstructure:"db"`
+	PoolSize int                `yaml:"pool_size" mapstructure:"pool_size"`
+	Breaker  RedisBreakerConfig `yaml:"breaker" mapstructure:"breaker"`
+}
+
+// RedisBreakerConfig Redis客户端的熔断器，Redis持续不可用时命令直接失败而不是等待超时
+type RedisBreakerConfig struct {
+	Enabled          bool  `yaml:"enabled" mapstructure:"enabled"`
+	FailureThreshold int   `yaml:"failureThreshold" mapstructure:"failureThreshold"` // 打开熔断器的连续失败次数
+	OpenTimeout      int64 `yaml:"openTimeout" mapstructure:"openTimeout"`           // 打开后到放行探测命令的时间 (纳秒)
 }
 
 type LogConfig struct {
@@ -35,15 +105,40 @@ type LogConfig struct {
 	Output     OutputConfig   `yaml:"output" mapstructure:"output"`
 	Rotation   RotationConfig `yaml:"rotation" mapstructure:"rotation"`
 	Fields     []FieldConfig  `yaml:"fields" mapstructure:"fields"`
+	Tenants    []TenantLogConfig `yaml:"tenants" mapstructure:"tenants"`
+	Redaction  LogRedactionConfig `yaml:"redaction" mapstructure:"redaction"`
+}
+
+// LogRedactionConfig 日志脱敏规则
+type LogRedactionConfig struct {
+	Mask        string   `yaml:"mask" mapstructure:"mask"`
+	QueryParams []string `yaml:"query_params" mapstructure:"query_params"`
+	Headers     []string `yaml:"headers" mapstructure:"headers"`
+	Fields      []string `yaml:"fields" mapstructure:"fields"`
+}
+
+// TenantLogConfig 业务方专属日志配置
+type TenantLogConfig struct {
+	BizID    int64          `yaml:"biz_id" mapstructure:"biz_id"`
+	Mode     string         `yaml:"mode" mapstructure:"mode"`
+	Path     string         `yaml:"path" mapstructure:"path"`
+	Rotation RotationConfig `yaml:"rotation" mapstructure:"rotation"`
 }
 
 type ServerConfig struct {
 	Websocket WebsocketConfig `yaml:"websocket" mapstructure:"websocket"`
+	Shutdown  ShutdownConfig  `yaml:"shutdown" mapstructure:"shutdown"`
+}
+
+type ShutdownConfig struct {
+	GracePeriod int64 `yaml:"gracePeriod" mapstructure:"gracePeriod"`
 }
 
 type LinkConfig struct {
 	Timeout     TimeoutConfig     `yaml:"timeout" mapstructure:"timeout"`
 	Buffer      BufferConfig      `yaml:"buffer" mapstructure:"buffer"`
+	Batch       BatchConfig       `yaml:"batch" mapstructure:"batch"`
+	Inbound     InboundConfig     `yaml:"inbound" mapstructure:"inbound"`
 	RetryStrategy RetryStrategyConfig `yaml:"retryStrategy" mapstructure:"retryStrategy"`
 	Limit       LimitConfig       `yaml:"limit" mapstructure:"limit"`
 	EventHandler EventHandlerConfig `yaml:"eventHandler" mapstructure:"eventHandler"`
@@ -54,15 +149,78 @@ type WebsocketConfig struct {
 	Port        int               `yaml:"port" mapstructure:"port"`
 	Compression CompressionConfig `yaml:"compression" mapstructure:"compression"`
 	TokenLimiter TokenLimiterConfig `yaml:"tokenLimiter" mapstructure:"tokenLimiter"`
+	UpgradeLock UpgradeLockConfig `yaml:"upgradeLock" mapstructure:"upgradeLock"`
+	TLS         TLSConfig         `yaml:"tls" mapstructure:"tls"`
+	Origin      OriginConfig      `yaml:"origin" mapstructure:"origin"`
+	Subprotocol SubprotocolConfig `yaml:"subprotocol" mapstructure:"subprotocol"`
+	Encryption  EncryptionConfig  `yaml:"encryption" mapstructure:"encryption"`
+	Token       TokenConfig       `yaml:"token" mapstructure:"token"`
+}
+
+// EncryptionConfig 与 TLS 无关的逐连接消息加密配置
+type EncryptionConfig struct {
+	Policy      string                      `yaml:"policy" mapstructure:"policy"`
+	BizPolicies []BizEncryptionPolicyConfig `yaml:"bizPolicies" mapstructure:"bizPolicies"`
+}
+
+// BizEncryptionPolicyConfig 单个业务方的加密策略
+type BizEncryptionPolicyConfig struct {
+	BizID  int64  `yaml:"bizId" mapstructure:"bizId"`
+	Policy string `yaml:"policy" mapstructure:"policy"`
+}
+
+// OriginConfig 握手时 Origin 头部的白名单
+type OriginConfig struct {
+	Allowed       []string `yaml:"allowed" mapstructure:"allowed"`             // 为空时不校验，支持 "*" 和 https://*.example.com 形式的通配
+	RejectMissing bool     `yaml:"rejectMissing" mapstructure:"rejectMissing"` // 拒绝没有 Origin 的请求，只有浏览器客户端时开启
+}
+
+// SubprotocolConfig Sec-WebSocket-Protocol 协商配置
+type SubprotocolConfig struct {
+	Supported []string `yaml:"supported" mapstructure:"supported"` // 为空时不协商子协议
+	Required  bool     `yaml:"required" mapstructure:"required"`   // 客户端没有请求子协议时拒绝
+}
+
+// TokenConfig 握手令牌的传递方式，Authorization: Bearer 请求头总是可用
+type TokenConfig struct {
+	DisableQuery      bool   `yaml:"disableQuery" mapstructure:"disableQuery"`           // 拒绝通过 ?token= 查询参数携带令牌的握手
+	Cookie            string `yaml:"cookie" mapstructure:"cookie"`                       // 携带令牌的 Cookie 名，为空时不从 Cookie 读取
+	SubprotocolMarker string `yaml:"subprotocolMarker" mapstructure:"subprotocolMarker"` // 子协议约定的标记，为空时不从子协议读取
+}
+
+// TLSConfig WebSocket 监听端口的 TLS 配置，启用后客户端通过 wss:// 连接
+type TLSConfig struct {
+	Enabled      bool     `yaml:"enabled" mapstructure:"enabled"`
+	CertFile     string   `yaml:"certFile" mapstructure:"certFile"`
+	KeyFile      string   `yaml:"keyFile" mapstructure:"keyFile"`
+	ClientCAFile string   `yaml:"clientCAFile" mapstructure:"clientCAFile"` // 不为空时校验客户端证书 (mTLS)
+	ClientAuth   string   `yaml:"clientAuth" mapstructure:"clientAuth"`     // none/request/verifyIfGiven/require
+	MinVersion   string   `yaml:"minVersion" mapstructure:"minVersion"`     // 1.2/1.3
+	ALPN         []string `yaml:"alpn" mapstructure:"alpn"`
+	WatchInterval int64   `yaml:"watchInterval" mapstructure:"watchInterval"` // 检查证书文件变化的间隔
+}
+
+// UpgradeLockConfig 同一用户并发握手的去重配置
+type UpgradeLockConfig struct {
+	Cluster bool  `yaml:"cluster" mapstructure:"cluster"`
+	TTL     int64 `yaml:"ttl" mapstructure:"ttl"`
 }
 
 type CompressionConfig struct {
-	Enabled         bool `yaml:"enabled" mapstructure:"enabled"`
-	ServerMaxWindow int  `yaml:"serverMaxWindow" mapstructure:"serverMaxWindow"`
-	ClientMaxWindow int  `yaml:"clientMaxWindow" mapstructure:"clientMaxWindow"`
-	ServerNoContext bool `yaml:"serverNoContext" mapstructure:"serverNoContext"`
-	ClientNoContext bool `yaml:"clientNoContext" mapstructure:"clientNoContext"`
-	Level           int  `yaml:"level" mapstructure:"level"`
+	Enabled         bool                    `yaml:"enabled" mapstructure:"enabled"`
+	ServerMaxWindow int                     `yaml:"serverMaxWindow" mapstructure:"serverMaxWindow"`
+	ClientMaxWindow int                     `yaml:"clientMaxWindow" mapstructure:"clientMaxWindow"`
+	ServerNoContext bool                    `yaml:"serverNoContext" mapstructure:"serverNoContext"`
+	ClientNoContext bool                    `yaml:"clientNoContext" mapstructure:"clientNoContext"`
+	Level           int                     `yaml:"level" mapstructure:"level"`
+	CPUBudget       CompressionBudgetConfig `yaml:"cpuBudget" mapstructure:"cpuBudget"`
+}
+
+// CompressionBudgetConfig 压缩下行消息的CPU预算
+type CompressionBudgetConfig struct {
+	Enabled  bool  `yaml:"enabled" mapstructure:"enabled"`
+	Budget   int64 `yaml:"budget" mapstructure:"budget"`
+	Interval int64 `yaml:"interval" mapstructure:"interval"`
 }
 
 type TokenLimiterConfig struct {
@@ -104,11 +262,35 @@ type RotationConfig struct {
 type TimeoutConfig struct {
 	Read  int64 `yaml:"read" mapstructure:"read"`
 	Write int64 `yaml:"write" mapstructure:"write"`
+	Idle  int64 `yaml:"idle" mapstructure:"idle"`
 }
 
 type BufferConfig struct {
-	ReceiveBufferSize int `yaml:"receiveBufferSize" mapstructure:"receiveBufferSize"`
-	SendBufferSize    int `yaml:"sendBufferSize" mapstructure:"sendBufferSize"`
+	ReceiveBufferSize int    `yaml:"receiveBufferSize" mapstructure:"receiveBufferSize"`
+	SendBufferSize    int    `yaml:"sendBufferSize" mapstructure:"sendBufferSize"`
+	Overflow          string `yaml:"overflow" mapstructure:"overflow"`
+	BlockTimeout      int64  `yaml:"blockTimeout" mapstructure:"blockTimeout"`
+}
+
+// BatchConfig 下行消息的写合并配置，Window 为 0 表示不合并
+type BatchConfig struct {
+	Window      int64            `yaml:"window" mapstructure:"window"`
+	MaxBytes    int              `yaml:"maxBytes" mapstructure:"maxBytes"`
+	BizPolicies []BizBatchConfig `yaml:"bizPolicies" mapstructure:"bizPolicies"`
+}
+
+// BizBatchConfig 单个业务方的写合并窗口
+type BizBatchConfig struct {
+	BizID  int64 `yaml:"bizId" mapstructure:"bizId"`
+	Window int64 `yaml:"window" mapstructure:"window"`
+}
+
+// InboundConfig 上行消息的大小和分片限制，字段为 0 表示不限制
+type InboundConfig struct {
+	MaxMessageSize      int64 `yaml:"maxMessageSize" mapstructure:"maxMessageSize"`
+	MaxDecompressedSize int64 `yaml:"maxDecompressedSize" mapstructure:"maxDecompressedSize"`
+	MaxFragments        int   `yaml:"maxFragments" mapstructure:"maxFragments"`
+	FragmentTimeout     int64 `yaml:"fragmentTimeout" mapstructure:"fragmentTimeout"`
 }
 
 type RetryStrategyConfig struct {
@@ -118,7 +300,10 @@ type RetryStrategyConfig struct {
 }
 
 type LimitConfig struct {
-	Rate int `yaml:"rate" mapstructure:"rate"`
+	Rate    int    `yaml:"rate" mapstructure:"rate"`
+	Burst   int    `yaml:"burst" mapstructure:"burst"`
+	Policy  string `yaml:"policy" mapstructure:"policy"`
+	MaxWait int64  `yaml:"maxWait" mapstructure:"maxWait"`
 }
 
 type EventHandlerConfig struct {
@@ -131,3 +316,521 @@ type PushMessageConfig struct {
 	RetryInterval int64 `yaml:"retryInterval" mapstructure:"retryInterval"`
 	MaxRetries    int   `yaml:"maxRetries" mapstructure:"maxRetries"`
 }
+
+type MetricsConfig struct {
+	Path              string  `yaml:"path" mapstructure:"path"`
+	MessageSampleRate float64 `yaml:"messageSampleRate" mapstructure:"messageSampleRate"`
+}
+
+type SessionConfig struct {
+	Backend      string   `yaml:"backend" mapstructure:"backend"` // redis (默认)、memory 或通过 session.RegisterBackend 注册的后端
+	NotifyFields []string `yaml:"notifyFields" mapstructure:"notifyFields"`
+	TTL          int64    `yaml:"ttl" mapstructure:"ttl"`
+	Enrichment   SessionEnrichmentConfig `yaml:"enrichment" mapstructure:"enrichment"`
+	Devices      SessionDevicesConfig    `yaml:"devices" mapstructure:"devices"`
+	Pipeline     SessionPipelineConfig   `yaml:"pipeline" mapstructure:"pipeline"`
+	Loss         SessionLossConfig       `yaml:"loss" mapstructure:"loss"`
+	Degraded     SessionDegradedConfig   `yaml:"degraded" mapstructure:"degraded"`
+}
+
+// SessionDegradedConfig Redis不可用时的降级模式：新连接使用只存在于本节点内存中的会话，Redis恢复后迁移到Redis
+type SessionDegradedConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+}
+
+// SessionLossConfig 连接的会话从Redis中丢失时的处理
+type SessionLossConfig struct {
+	CheckInterval int64                  `yaml:"checkInterval" mapstructure:"checkInterval"`
+	Policy        string                 `yaml:"policy" mapstructure:"policy"` // rebuild (默认) 或 close
+	BizPolicies   []BizSessionLossConfig `yaml:"bizPolicies" mapstructure:"bizPolicies"`
+}
+
+type BizSessionLossConfig struct {
+	BizID  int64  `yaml:"bizId" mapstructure:"bizId"`
+	Policy string `yaml:"policy" mapstructure:"policy"`
+}
+
+// SessionPipelineConfig 自动合并同一连接的会话读写的配置
+type SessionPipelineConfig struct {
+	Window   int64 `yaml:"window" mapstructure:"window"`
+	MaxBatch int   `yaml:"maxBatch" mapstructure:"maxBatch"`
+}
+
+// SessionDevicesConfig 同一用户建立多个连接时的处理策略
+type SessionDevicesConfig struct {
+	Policy            string                  `yaml:"policy" mapstructure:"policy"`
+	BizPolicies       []BizDevicePolicyConfig `yaml:"bizPolicies" mapstructure:"bizPolicies"`
+	FingerprintHeader string                  `yaml:"fingerprintHeader" mapstructure:"fingerprintHeader"`
+	Limits            []DeviceLimitConfig     `yaml:"limits" mapstructure:"limits"`
+}
+
+// DeviceLimitConfig 单个业务方的同时在线设备数上限
+type DeviceLimitConfig struct {
+	BizID      int64  `yaml:"bizId" mapstructure:"bizId"`
+	MaxDevices int    `yaml:"maxDevices" mapstructure:"maxDevices"`
+	Overflow   string `yaml:"overflow" mapstructure:"overflow"`
+}
+
+// BizDevicePolicyConfig 单个业务方的多连接策略
+type BizDevicePolicyConfig struct {
+	BizID  int64  `yaml:"bizId" mapstructure:"bizId"`
+	Policy string `yaml:"policy" mapstructure:"policy"`
+}
+
+// SessionEnrichmentConfig 握手完成后异步补充会话数据的配置
+type SessionEnrichmentConfig struct {
+	Metadata       bool   `yaml:"metadata" mapstructure:"metadata"`
+	UserServiceURL string `yaml:"userServiceURL" mapstructure:"userServiceURL"`
+	Timeout        int64  `yaml:"timeout" mapstructure:"timeout"`
+}
+
+type APIConfig struct {
+	Keys        []APIKeyConfig       `yaml:"keys" mapstructure:"keys"`
+	Compression APICompressionConfig `yaml:"compression" mapstructure:"compression"`
+	GRPC        APIGRPCConfig        `yaml:"grpc" mapstructure:"grpc"`
+}
+
+// APIGRPCConfig 管理API的gRPC服务配置，与HTTP管理API使用相同的API Key
+type APIGRPCConfig struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
+	Addr    string `yaml:"addr" mapstructure:"addr"`
+}
+
+// APICompressionConfig 管理API的HTTP压缩配置
+type APICompressionConfig struct {
+	Enabled        bool     `yaml:"enabled" mapstructure:"enabled"`
+	Level          int      `yaml:"level" mapstructure:"level"`
+	MinSize        int      `yaml:"minSize" mapstructure:"minSize"`
+	MaxRequestSize int64    `yaml:"maxRequestSize" mapstructure:"maxRequestSize"`
+	ContentTypes   []string `yaml:"contentTypes" mapstructure:"contentTypes"`
+}
+
+// APIKeyConfig 管理API的访问密钥及其权限
+type APIKeyConfig struct {
+	Name          string   `yaml:"name" mapstructure:"name"`
+	Key           string   `yaml:"key" mapstructure:"key"`
+	SessionFields []string `yaml:"sessionFields" mapstructure:"sessionFields"`
+}
+
+type HistoryConfig struct {
+	Size int64 `yaml:"size" mapstructure:"size"`
+	TTL  int64 `yaml:"ttl" mapstructure:"ttl"`
+}
+
+// ResumeConfig 会话恢复的配置
+type ResumeConfig struct {
+	Enabled    bool   `yaml:"enabled" mapstructure:"enabled"`
+	Store      string `yaml:"store" mapstructure:"store"`
+	BufferSize int64  `yaml:"bufferSize" mapstructure:"bufferSize"`
+	TTL        int64  `yaml:"ttl" mapstructure:"ttl"`
+}
+
+// OfflineConfig 离线消息存储的配置
+type OfflineConfig struct {
+	Enabled     bool  `yaml:"enabled" mapstructure:"enabled"`
+	Retention   int64 `yaml:"retention" mapstructure:"retention"`
+	MaxMessages int64 `yaml:"maxMessages" mapstructure:"maxMessages"`
+}
+
+// RoomsConfig 房间成员关系的配置
+type RoomsConfig struct {
+	Enabled         bool  `yaml:"enabled" mapstructure:"enabled"`
+	ClientJoin      bool  `yaml:"clientJoin" mapstructure:"clientJoin"`
+	TTL             int64 `yaml:"ttl" mapstructure:"ttl"`
+	MaxRoomsPerLink int   `yaml:"maxRoomsPerLink" mapstructure:"maxRoomsPerLink"`
+	// Archive 房间推送的近期消息存档，供加入房间的连接回看
+	Archive RoomArchiveConfig `yaml:"archive" mapstructure:"archive"`
+}
+
+// RoomArchiveConfig 房间近期消息存档的配置，MaxLen 为 0 表示不存档
+type RoomArchiveConfig struct {
+	MaxLen      int                `yaml:"maxLen" mapstructure:"maxLen"`
+	TTL         int64              `yaml:"ttl" mapstructure:"ttl"`
+	BizPolicies []BizArchiveConfig `yaml:"bizPolicies" mapstructure:"bizPolicies"`
+}
+
+// BizArchiveConfig 单个业务方每个房间存档的消息数
+type BizArchiveConfig struct {
+	BizID  int64 `yaml:"bizId" mapstructure:"bizId"`
+	MaxLen int   `yaml:"maxLen" mapstructure:"maxLen"`
+}
+
+// DegradeConfig 慢速连接的推送降级配置
+type DegradeConfig struct {
+	Enabled       bool                  `yaml:"enabled" mapstructure:"enabled"`
+	SlowBandwidth int64                 `yaml:"slowBandwidth" mapstructure:"slowBandwidth"`
+	Policies      []DegradePolicyConfig `yaml:"policies" mapstructure:"policies"`
+}
+
+// DegradePolicyConfig 单个业务方对慢速连接的降级策略
+type DegradePolicyConfig struct {
+	BizID       int64    `yaml:"bizId" mapstructure:"bizId"`
+	DropFields  []string `yaml:"dropFields" mapstructure:"dropFields"`
+	MinInterval int64    `yaml:"minInterval" mapstructure:"minInterval"`
+}
+
+// FanoutConfig 群发推送的分时调度配置
+type FanoutConfig struct {
+	Enabled       bool                   `yaml:"enabled" mapstructure:"enabled"`
+	Slice         int64                  `yaml:"slice" mapstructure:"slice"`
+	Workers       int                    `yaml:"workers" mapstructure:"workers"`
+	Budgets       FanoutBudgetsConfig    `yaml:"budgets" mapstructure:"budgets"`
+	BizPriorities []FanoutPriorityConfig `yaml:"bizPriorities" mapstructure:"bizPriorities"`
+}
+
+// FanoutBudgetsConfig 每个优先级的群发在一个时间片内最多写入的连接数
+type FanoutBudgetsConfig struct {
+	High   int `yaml:"high" mapstructure:"high"`
+	Normal int `yaml:"normal" mapstructure:"normal"`
+	Low    int `yaml:"low" mapstructure:"low"`
+}
+
+// FanoutPriorityConfig 单个业务方群发推送的优先级
+type FanoutPriorityConfig struct {
+	BizID    int64  `yaml:"bizId" mapstructure:"bizId"`
+	Priority string `yaml:"priority" mapstructure:"priority"`
+}
+
+// GeoIPConfig 按客户端IP解析地理位置的配置
+type GeoIPConfig struct {
+	Enabled         bool              `yaml:"enabled" mapstructure:"enabled"`
+	Path            string            `yaml:"path" mapstructure:"path"`
+	ASNPath         string            `yaml:"asnPath" mapstructure:"asnPath"`
+	MetricCountries []string          `yaml:"metricCountries" mapstructure:"metricCountries"`
+	Policies        []GeoPolicyConfig `yaml:"policies" mapstructure:"policies"`
+}
+
+// GeoPolicyConfig 单个业务方按地区拒绝连接的策略
+type GeoPolicyConfig struct {
+	BizID          int64    `yaml:"bizId" mapstructure:"bizId"`
+	AllowCountries []string `yaml:"allowCountries" mapstructure:"allowCountries"`
+	DenyCountries  []string `yaml:"denyCountries" mapstructure:"denyCountries"`
+	DenyASNs       []uint32 `yaml:"denyAsns" mapstructure:"denyAsns"`
+}
+
+// GuestConfig 访客（匿名）连接的配置，只有配置了策略的业务方允许不携带令牌的访客连接
+type GuestConfig struct {
+	Policies []GuestPolicyConfig `yaml:"policies" mapstructure:"policies"`
+}
+
+// GuestPolicyConfig 单个业务方的访客策略
+type GuestPolicyConfig struct {
+	BizID          int64 `yaml:"bizId" mapstructure:"bizId"`
+	MaxConnections int   `yaml:"maxConnections" mapstructure:"maxConnections"`
+	MaxLifetime    int64 `yaml:"maxLifetime" mapstructure:"maxLifetime"`
+}
+
+// TracingConfig OpenTelemetry 链路追踪的配置
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled" mapstructure:"enabled"`
+	Exporter    string            `yaml:"exporter" mapstructure:"exporter"`
+	Endpoint    string            `yaml:"endpoint" mapstructure:"endpoint"`
+	Insecure    bool              `yaml:"insecure" mapstructure:"insecure"`
+	Headers     map[string]string `yaml:"headers" mapstructure:"headers"`
+	SampleRatio float64           `yaml:"sampleRatio" mapstructure:"sampleRatio"`
+	Timeout     int64             `yaml:"timeout" mapstructure:"timeout"`
+}
+
+// AuditConfig 连接审计日志的配置
+type AuditConfig struct {
+	Enabled     bool           `yaml:"enabled" mapstructure:"enabled"`
+	Output      string         `yaml:"output" mapstructure:"output"` // file (默认) 或 console
+	Path        string         `yaml:"path" mapstructure:"path"`
+	Rotation    RotationConfig `yaml:"rotation" mapstructure:"rotation"`
+	SampleRatio float64        `yaml:"sampleRatio" mapstructure:"sampleRatio"`
+}
+
+// UsageConfig 按业务方统计用量并定期输出用量报告的配置
+type UsageConfig struct {
+	Enabled  bool               `yaml:"enabled" mapstructure:"enabled"`
+	Interval int64              `yaml:"interval" mapstructure:"interval"` // 统计周期 (纳秒)，周期按整点对齐，各节点的周期相同
+	Redis    UsageRedisConfig   `yaml:"redis" mapstructure:"redis"`
+	CSV      UsageCSVConfig     `yaml:"csv" mapstructure:"csv"`
+	Webhook  UsageWebhookConfig `yaml:"webhook" mapstructure:"webhook"`
+}
+
+// UsageRedisConfig 把各节点的用量累加到 Redis 中每个业务方每个周期一个的 Hash
+type UsageRedisConfig struct {
+	Enabled bool  `yaml:"enabled" mapstructure:"enabled"`
+	TTL     int64 `yaml:"ttl" mapstructure:"ttl"` // 用量记录的保留时长 (纳秒)，0 表示不过期
+}
+
+// UsageCSVConfig 把本节点的用量报告追加到按天分割的 CSV 文件
+type UsageCSVConfig struct {
+	Dir string `yaml:"dir" mapstructure:"dir"` // 为空时不写 CSV 文件
+}
+
+// UsageWebhookConfig 把本节点的用量报告以 JSON POST 到 webhook
+type UsageWebhookConfig struct {
+	URL     string `yaml:"url" mapstructure:"url"`         // 为空时不发送
+	Secret  string `yaml:"secret" mapstructure:"secret"`   // 不为空时对请求体签名
+	Timeout int64  `yaml:"timeout" mapstructure:"timeout"` // 单次请求的超时时间 (纳秒)
+}
+
+// DebugConfig 调试服务的配置，pprof、expvar 和连接状态转储监听独立的地址
+type DebugConfig struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
+	Addr    string `yaml:"addr" mapstructure:"addr"`
+}
+
+// IncidentConfig 事故记录的配置
+type IncidentConfig struct {
+	Enabled      bool   `yaml:"enabled" mapstructure:"enabled"`
+	Dir          string `yaml:"dir" mapstructure:"dir"`
+	MaxFiles     int    `yaml:"maxFiles" mapstructure:"maxFiles"`
+	MaxPerMinute int    `yaml:"maxPerMinute" mapstructure:"maxPerMinute"`
+	TrailSize    int    `yaml:"trailSize" mapstructure:"trailSize"`
+}
+
+type BrokerConfig struct {
+	DefaultKeyStrategy string               `yaml:"defaultKeyStrategy" mapstructure:"defaultKeyStrategy"`
+	Topics             []BrokerTopicConfig  `yaml:"topics" mapstructure:"topics"`
+	Consumer           BrokerConsumerConfig `yaml:"consumer" mapstructure:"consumer"`
+}
+
+// BrokerConsumerConfig 从 Kafka 消费下行推送的配置
+type BrokerConsumerConfig struct {
+	Enabled         bool     `yaml:"enabled" mapstructure:"enabled"`
+	Brokers         []string `yaml:"brokers" mapstructure:"brokers"`
+	Topic           string   `yaml:"topic" mapstructure:"topic"`
+	GroupID         string   `yaml:"groupId" mapstructure:"groupId"`
+	Encoding        string   `yaml:"encoding" mapstructure:"encoding"`
+	DeadLetterTopic string   `yaml:"deadLetterTopic" mapstructure:"deadLetterTopic"`
+	MaxRetryBackoff int64    `yaml:"maxRetryBackoff" mapstructure:"maxRetryBackoff"`
+}
+
+type BrokerTopicConfig struct {
+	Name        string `yaml:"name" mapstructure:"name"`
+	KeyStrategy string `yaml:"keyStrategy" mapstructure:"keyStrategy"`
+}
+
+// BackoffConfig 因负载原因关闭连接时下发给客户端的重连退避建议
+type BackoffConfig struct {
+	Drain     BackoffPolicyConfig `yaml:"drain" mapstructure:"drain"`
+	RateLimit BackoffPolicyConfig `yaml:"rateLimit" mapstructure:"rateLimit"`
+	Capacity  BackoffPolicyConfig `yaml:"capacity" mapstructure:"capacity"`
+}
+
+type BackoffPolicyConfig struct {
+	Min    int64   `yaml:"min" mapstructure:"min"`
+	Max    int64   `yaml:"max" mapstructure:"max"`
+	Jitter float64 `yaml:"jitter" mapstructure:"jitter"`
+}
+
+// BackendConfig 业务后端配置
+type BackendConfig struct {
+	Pool     HTTPPoolConfig         `yaml:"pool" mapstructure:"pool"`
+	Services []BackendServiceConfig `yaml:"services" mapstructure:"services"`
+}
+
+type BackendServiceConfig struct {
+	Name     string            `yaml:"name" mapstructure:"name"`
+	BizID    int64             `yaml:"bizId" mapstructure:"bizId"`
+	URL      string            `yaml:"url" mapstructure:"url"`
+	Protocol string            `yaml:"protocol" mapstructure:"protocol"` // http (默认)、grpc 或 mock，grpc 时 URL 为拨号目标，mock 时不需要 URL
+	Pool     HTTPPoolConfig    `yaml:"pool" mapstructure:"pool"`
+	Mock     MockBackendConfig `yaml:"mock" mapstructure:"mock"` // 仅 mock 后端有效
+}
+
+// MockBackendConfig 模拟业务后端，按规则以预设的响应回复转发的消息，不发起网络调用
+// 用于业务后端就绪前的前端开发和不依赖真实服务的集成测试
+type MockBackendConfig struct {
+	Rules []MockRuleConfig `yaml:"rules" mapstructure:"rules"` // 按顺序匹配，使用第一条匹配的规则；都不匹配时按业务后端拒绝请求处理
+}
+
+// MockRuleConfig 模拟业务后端的一条响应规则
+type MockRuleConfig struct {
+	Cmd        string  `yaml:"cmd" mapstructure:"cmd"`               // 匹配的消息类型，为空时匹配所有类型
+	Match      string  `yaml:"match" mapstructure:"match"`           // 匹配消息体的正则表达式，为空时匹配所有消息体
+	Response   string  `yaml:"response" mapstructure:"response"`     // 响应消息体的 text/template 模板
+	Delay      int64   `yaml:"delay" mapstructure:"delay"`           // 响应前的延迟 (纳秒)，超过请求超时时间时按超时处理
+	Error      string  `yaml:"error" mapstructure:"error"`           // 注入的错误: timeout、unavailable 或 rejected，为空时不注入
+	ErrorRatio float64 `yaml:"errorRatio" mapstructure:"errorRatio"` // 注入错误的概率，0 表示总是注入
+}
+
+// HTTPPoolConfig HTTP连接池配置，业务后端未配置的项沿用全局配置
+type HTTPPoolConfig struct {
+	MaxIdleConns        int   `yaml:"maxIdleConns" mapstructure:"maxIdleConns"`
+	MaxIdleConnsPerHost int   `yaml:"maxIdleConnsPerHost" mapstructure:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int   `yaml:"maxConnsPerHost" mapstructure:"maxConnsPerHost"`
+	IdleConnTimeout     int64 `yaml:"idleConnTimeout" mapstructure:"idleConnTimeout"`
+	DialTimeout         int64 `yaml:"dialTimeout" mapstructure:"dialTimeout"`
+	KeepAlive           int64 `yaml:"keepAlive" mapstructure:"keepAlive"`
+	TLSHandshakeTimeout int64 `yaml:"tlsHandshakeTimeout" mapstructure:"tlsHandshakeTimeout"`
+	TLSSessionCacheSize int   `yaml:"tlsSessionCacheSize" mapstructure:"tlsSessionCacheSize"`
+	ForceHTTP2          *bool `yaml:"forceHTTP2" mapstructure:"forceHTTP2"`
+}
+
+// RPCConfig 上行RPC请求配置
+type RPCConfig struct {
+	TimeoutResponse TimeoutResponseConfig `yaml:"timeoutResponse" mapstructure:"timeoutResponse"`
+	Routes          []RouteConfig         `yaml:"routes" mapstructure:"routes"`
+}
+
+// RouteConfig 上行消息路由规则，按消息类型把消息转发到指定的业务后端
+type RouteConfig struct {
+	BizID   int64  `yaml:"bizId" mapstructure:"bizId"` // 0 表示对所有业务方生效
+	Cmd     string `yaml:"cmd" mapstructure:"cmd"`     // 消息类型，例如 COMMAND_TYPE_UPSTREAM_MESSAGE
+	Service string `yaml:"service" mapstructure:"service"`
+}
+
+// TimeoutResponseConfig 业务后端超时时网关代为生成的客户端响应
+type TimeoutResponseConfig struct {
+	Policy    string                        `yaml:"policy" mapstructure:"policy"`
+	CacheTTL  int64                         `yaml:"cacheTTL" mapstructure:"cacheTTL"`
+	CacheSize int                           `yaml:"cacheSize" mapstructure:"cacheSize"`
+	Tenants   []TenantTimeoutResponseConfig `yaml:"tenants" mapstructure:"tenants"`
+}
+
+type TenantTimeoutResponseConfig struct {
+	BizID    int64  `yaml:"bizId" mapstructure:"bizId"`
+	Policy   string `yaml:"policy" mapstructure:"policy"`
+	CacheTTL int64  `yaml:"cacheTTL" mapstructure:"cacheTTL"`
+}
+
+// AbuseConfig 客户端滥用检测配置
+type AbuseConfig struct {
+	Enabled   bool                 `yaml:"enabled" mapstructure:"enabled"`
+	Window    int64                `yaml:"window" mapstructure:"window"`
+	Threshold int64                `yaml:"threshold" mapstructure:"threshold"`
+	Weights   AbuseWeightsConfig   `yaml:"weights" mapstructure:"weights"`
+	Ban       AbuseBanConfig       `yaml:"ban" mapstructure:"ban"`
+	Handshake AbuseHandshakeConfig `yaml:"handshake" mapstructure:"handshake"`
+}
+
+type AbuseWeightsConfig struct {
+	RateLimit     int64 `yaml:"rateLimit" mapstructure:"rateLimit"`
+	ProtocolError int64 `yaml:"protocolError" mapstructure:"protocolError"`
+	Oversized     int64 `yaml:"oversized" mapstructure:"oversized"`
+	AuthFailure   int64 `yaml:"authFailure" mapstructure:"authFailure"`
+}
+
+type AbuseBanConfig struct {
+	Base     int64 `yaml:"base" mapstructure:"base"`
+	Max      int64 `yaml:"max" mapstructure:"max"`
+	LevelTTL int64 `yaml:"levelTTL" mapstructure:"levelTTL"`
+}
+
+// AbuseHandshakeConfig 按客户端IP限制握手速率的配置，Rate 为每秒允许的握手数
+type AbuseHandshakeConfig struct {
+	Rate  int `yaml:"rate" mapstructure:"rate"`
+	Burst int `yaml:"burst" mapstructure:"burst"`
+}
+
+// MessageConfig 消息信封编解码配置
+type MessageConfig struct {
+	DefaultCodec string `yaml:"defaultCodec" mapstructure:"defaultCodec"`
+}
+
+// WebhookConfig 业务方webhook配置
+type WebhookConfig struct {
+	PreAccept []PreAcceptWebhookConfig `yaml:"preAccept" mapstructure:"preAccept"`
+	Inbound   InboundWebhookConfig     `yaml:"inbound" mapstructure:"inbound"`
+	Lifecycle []LifecycleWebhookConfig `yaml:"lifecycle" mapstructure:"lifecycle"`
+}
+
+// LifecycleWebhookConfig 连接生命周期事件webhook，连接建立、关闭和空闲回收时异步 POST 事件
+type LifecycleWebhookConfig struct {
+	BizID  int64    `yaml:"bizId" mapstructure:"bizId"`   // 0 表示接收所有业务方的事件
+	URL    string   `yaml:"url" mapstructure:"url"`
+	Events []string `yaml:"events" mapstructure:"events"` // 为空时接收全部事件
+	Secret string   `yaml:"secret" mapstructure:"secret"` // 不为空时对请求体签名
+}
+
+// InboundWebhookConfig 第三方服务调用的入站webhook，收到的JSON经模板映射为推送
+type InboundWebhookConfig struct {
+	Path  string              `yaml:"path" mapstructure:"path"`
+	Hooks []InboundHookConfig `yaml:"hooks" mapstructure:"hooks"`
+}
+
+// InboundHookConfig 一个入站webhook，通过 POST {path}/{name} 调用
+// 除 Name、Secret、SignatureHeader、BizID、Target 外的字段都是 text/template 模板
+type InboundHookConfig struct {
+	Name            string `yaml:"name" mapstructure:"name"`
+	Secret          string `yaml:"secret" mapstructure:"secret"`
+	SignatureHeader string `yaml:"signatureHeader" mapstructure:"signatureHeader"`
+	BizID           int64  `yaml:"bizId" mapstructure:"bizId"`
+	Target          string `yaml:"target" mapstructure:"target"`
+	UserID          string `yaml:"userId" mapstructure:"userId"`
+	UserIDs         string `yaml:"userIds" mapstructure:"userIds"`
+	Group           string `yaml:"group" mapstructure:"group"`
+	Room            string `yaml:"room" mapstructure:"room"`
+	Key             string `yaml:"key" mapstructure:"key"`
+	CollapseKey     string `yaml:"collapseKey" mapstructure:"collapseKey"`
+	Body            string `yaml:"body" mapstructure:"body"`
+}
+
+// PreAcceptWebhookConfig 连接建立前的同步准入webhook，每个业务方至多一个
+type PreAcceptWebhookConfig struct {
+	BizID         int64  `yaml:"bizId" mapstructure:"bizId"`
+	URL           string `yaml:"url" mapstructure:"url"`
+	Timeout       int64  `yaml:"timeout" mapstructure:"timeout"`
+	FailurePolicy string `yaml:"failurePolicy" mapstructure:"failurePolicy"`
+}
+
+// ClusterConfig 多节点部署配置
+type ClusterConfig struct {
+	Enabled       bool            `yaml:"enabled" mapstructure:"enabled"`
+	ChannelPrefix string          `yaml:"channelPrefix" mapstructure:"channelPrefix"`
+	Placement     PlacementConfig `yaml:"placement" mapstructure:"placement"`
+}
+
+// PlacementConfig 用户到首选节点的一致性哈希放置配置
+type PlacementConfig struct {
+	Enabled         bool   `yaml:"enabled" mapstructure:"enabled"`
+	Replicas        int    `yaml:"replicas" mapstructure:"replicas"`
+	RefreshInterval int64  `yaml:"refreshInterval" mapstructure:"refreshInterval"`
+	Path            string `yaml:"path" mapstructure:"path"`
+}
+
+// UniquesConfig 按业务方统计去重用户数的配置
+type UniquesConfig struct {
+	Enabled         bool  `yaml:"enabled" mapstructure:"enabled"`
+	HourlyRetention int64 `yaml:"hourlyRetention" mapstructure:"hourlyRetention"`
+	DailyRetention  int64 `yaml:"dailyRetention" mapstructure:"dailyRetention"`
+}
+
+// AdmissionConfig 新连接准入控制的配置
+type AdmissionConfig struct {
+	MemoryLimit      int64            `yaml:"memoryLimit" mapstructure:"memoryLimit"`
+	QueueTimeout     int64            `yaml:"queueTimeout" mapstructure:"queueTimeout"`
+	QueueInterval    int64            `yaml:"queueInterval" mapstructure:"queueInterval"`
+	DefaultBizQuota  int              `yaml:"defaultBizQuota" mapstructure:"defaultBizQuota"`
+	DefaultUserQuota int              `yaml:"defaultUserQuota" mapstructure:"defaultUserQuota"`
+	BizQuotas        []BizQuotaConfig `yaml:"bizQuotas" mapstructure:"bizQuotas"`
+}
+
+// BizQuotaConfig 单个业务方在本节点上的最大连接数和单个用户的最大连接数
+type BizQuotaConfig struct {
+	BizID              int64 `yaml:"bizId" mapstructure:"bizId"`
+	MaxConnections     int   `yaml:"maxConnections" mapstructure:"maxConnections"`
+	MaxUserConnections int   `yaml:"maxUserConnections" mapstructure:"maxUserConnections"`
+}
+
+// RevocationConfig 令牌吊销的配置
+type RevocationConfig struct {
+	Enabled          bool  `yaml:"enabled" mapstructure:"enabled"`
+	MaxTokenLifetime int64 `yaml:"maxTokenLifetime" mapstructure:"maxTokenLifetime"`
+}
+
+// ScalingConfig 自动扩缩容相关的配置
+type ScalingConfig struct {
+	Path             string        `yaml:"path" mapstructure:"path"`
+	SampleInterval   int64         `yaml:"sampleInterval" mapstructure:"sampleInterval"`
+	RateWindow       int64         `yaml:"rateWindow" mapstructure:"rateWindow"`
+	MinReadyCapacity int64         `yaml:"minReadyCapacity" mapstructure:"minReadyCapacity"`
+	PreStop          PreStopConfig `yaml:"preStop" mapstructure:"preStop"`
+	Warmup           WarmupConfig  `yaml:"warmup" mapstructure:"warmup"`
+}
+
+// PreStopConfig Kubernetes preStop 钩子触发摘流的配置
+type PreStopConfig struct {
+	Delay int64 `yaml:"delay" mapstructure:"delay"`
+}
+
+// WarmupConfig 启动时预热本地缓存和连接的配置
+type WarmupConfig struct {
+	Enabled    bool  `yaml:"enabled" mapstructure:"enabled"`
+	Timeout    int64 `yaml:"timeout" mapstructure:"timeout"`
+	RedisConns int   `yaml:"redisConns" mapstructure:"redisConns"`
+}
@@ -0,0 +1,859 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidConfig is matched by the error returned from Validate
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Problem is a single invalid value, identified by its key path
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// ValidationError lists every problem found by Validate, so that all of them
+// can be fixed in one go instead of one restart at a time
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d problem(s)", ErrInvalidConfig, len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// validator collects problems while walking the config
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) addf(path, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf(path, "must not be empty")
+	}
+}
+
+func (v *validator) oneOf(path, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf(path, "must be one of %s, got %q", quoteAll(allowed), value)
+}
+
+func (v *validator) between(path string, value, lo, hi int64) {
+	if value < lo || value > hi {
+		v.addf(path, "must be between %d and %d, got %d", lo, hi, value)
+	}
+}
+
+func (v *validator) nonNegative(path string, value int64) {
+	if value < 0 {
+		v.addf(path, "must not be negative, got %d", value)
+	}
+}
+
+func (v *validator) positive(path string, value int64) {
+	if value <= 0 {
+		v.addf(path, "must be positive, got %d", value)
+	}
+}
+
+func (v *validator) ratio(path string, value float64) {
+	if value < 0 || value > 1 {
+		v.addf(path, "must be between 0 and 1, got %g", value)
+	}
+}
+
+func (v *validator) httpPath(path, value string) {
+	if value != "" && !strings.HasPrefix(value, "/") {
+		v.addf(path, "must start with \"/\", got %q", value)
+	}
+}
+
+func (v *validator) hostPort(path, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		v.addf(path, "must be host:port, got %q", value)
+		return
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		v.addf(path, "port must be between 0 and 65535, got %q", port)
+	}
+}
+
+func (v *validator) absoluteURL(path, value string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		v.addf(path, "must be an absolute URL, got %q", value)
+	}
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, s := range values {
+		quoted[i] = strconv.Quote(s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Validate checks the whole config and returns a *ValidationError listing
+// every problem with its key path, or nil when the config is usable.
+// Empty values that have a documented default are accepted.
+func (c Config) Validate() error {
+	v := &validator{}
+	c.validateApp(v)
+	c.validateJWT(v)
+	c.validateRedis(v)
+	c.validateLog(v)
+	c.validateServer(v)
+	c.validateLink(v)
+	c.validateMetrics(v)
+	c.validateSession(v)
+	c.validateAPI(v)
+	c.validateBroker(v)
+	c.validateBackoff(v)
+	c.validateBackend(v)
+	c.validateAbuse(v)
+	c.validateWebhook(v)
+	c.validateAdmission(v)
+	c.validateScaling(v)
+	c.validateIncident(v)
+	c.validateResume(v)
+	c.validateCluster(v)
+	c.validateOffline(v)
+	c.validateRooms(v)
+	c.validateDegrade(v)
+	c.validateFanout(v)
+	c.validateGeoIP(v)
+	c.validateGuest(v)
+	c.validateTracing(v)
+	c.validateAudit(v)
+	c.validateUsage(v)
+	c.validateDebug(v)
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+func (c Config) validateApp(v *validator) {
+	v.required("app.name", c.App.Name)
+	v.hostPort("app.addr", c.App.Addr)
+}
+
+func (c Config) validateJWT(v *validator) {
+	v.required("jwt.key", c.JWT.Key)
+	v.nonNegative("jwt.decisionCacheTTL", c.JWT.DecisionCacheTTL)
+	v.nonNegative("jwt.decisionCacheSize", int64(c.JWT.DecisionCacheSize))
+	for i, k := range c.JWT.PublicKeys {
+		path := fmt.Sprintf("jwt.publicKeys[%d]", i)
+		if (k.PEM == "") == (k.File == "") {
+			v.addf(path, "exactly one of pem and file must be set")
+		}
+	}
+	if c.JWT.JWKS.URL != "" {
+		v.absoluteURL("jwt.jwks.url", c.JWT.JWKS.URL)
+		v.nonNegative("jwt.jwks.refreshInterval", c.JWT.JWKS.RefreshInterval)
+		v.nonNegative("jwt.jwks.timeout", c.JWT.JWKS.Timeout)
+	}
+}
+
+func (c Config) validateRedis(v *validator) {
+	v.required("redis.addr", c.Redis.Addr)
+	v.between("redis.db", int64(c.Redis.DB), 0, 15)
+	v.nonNegative("redis.pool_size", int64(c.Redis.PoolSize))
+	if c.Redis.Breaker.Enabled {
+		v.nonNegative("redis.breaker.failureThreshold", int64(c.Redis.Breaker.FailureThreshold))
+		v.nonNegative("redis.breaker.openTimeout", c.Redis.Breaker.OpenTimeout)
+	}
+}
+
+func (c Config) validateLog(v *validator) {
+	v.oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+	// console is accepted for compatibility with older configs and behaves like text
+	v.oneOf("log.format", c.Log.Format, "json", "text", "console")
+	switch c.Log.Output.Type {
+	case "", "console":
+	case "file", "multi":
+		v.required("log.output.path", c.Log.Output.Path)
+	default:
+		v.addf("log.output.type", "must be one of %s, got %q", quoteAll([]string{"console", "file", "multi"}), c.Log.Output.Type)
+	}
+	for i, t := range c.Log.Tenants {
+		path := fmt.Sprintf("log.tenants[%d]", i)
+		v.positive(path+".biz_id", t.BizID)
+	}
+	r := c.Log.Redaction
+	for i, p := range r.QueryParams {
+		if p == "" || strings.ContainsAny(p, "=&?#") {
+			v.addf(fmt.Sprintf("log.redaction.query_params[%d]", i), "must be a query parameter name, got %q", p)
+		}
+	}
+	for i, h := range r.Headers {
+		v.required(fmt.Sprintf("log.redaction.headers[%d]", i), h)
+	}
+	for i, f := range r.Fields {
+		if slices.Contains(strings.Split(f, "."), "") {
+			v.addf(fmt.Sprintf("log.redaction.fields[%d]", i), "must be a dot-separated JSON path, got %q", f)
+		}
+	}
+}
+
+func (c Config) validateServer(v *validator) {
+	ws := c.Server.Websocket
+	// 0 listens on an ephemeral port, WebsocketServer.Addr reports the actual one
+	v.between("server.websocket.port", int64(ws.Port), 0, 65535)
+	if ws.Compression.Enabled {
+		v.between("server.websocket.compression.serverMaxWindow", int64(ws.Compression.ServerMaxWindow), 8, 15)
+		v.between("server.websocket.compression.clientMaxWindow", int64(ws.Compression.ClientMaxWindow), 8, 15)
+		v.between("server.websocket.compression.level", int64(ws.Compression.Level), 1, 9)
+		if b := ws.Compression.CPUBudget; b.Enabled {
+			v.positive("server.websocket.compression.cpuBudget.budget", b.Budget)
+			v.positive("server.websocket.compression.cpuBudget.interval", b.Interval)
+		}
+	}
+	tl := ws.TokenLimiter
+	v.positive("server.websocket.tokenLimiter.maxCapacity", tl.MaxCapacity)
+	v.nonNegative("server.websocket.tokenLimiter.initialCapacity", tl.InitialCapacity)
+	if tl.InitialCapacity > tl.MaxCapacity {
+		v.addf("server.websocket.tokenLimiter.initialCapacity", "must not exceed maxCapacity (%d), got %d", tl.MaxCapacity, tl.InitialCapacity)
+	}
+	v.positive("server.websocket.tokenLimiter.increaseStep", tl.IncreaseStep)
+	v.positive("server.websocket.tokenLimiter.increaseInterval", tl.IncreaseInterval)
+	v.nonNegative("server.websocket.upgradeLock.ttl", ws.UpgradeLock.TTL)
+	if t := ws.TLS; t.Enabled {
+		v.required("server.websocket.tls.certFile", t.CertFile)
+		v.required("server.websocket.tls.keyFile", t.KeyFile)
+		if t.ClientAuth != "" {
+			v.oneOf("server.websocket.tls.clientAuth", t.ClientAuth, "none", "request", "verifyIfGiven", "require")
+		}
+		if (t.ClientAuth == "verifyIfGiven" || t.ClientAuth == "require") && t.ClientCAFile == "" {
+			v.addf("server.websocket.tls.clientCAFile", "must be set when clientAuth is %q", t.ClientAuth)
+		}
+		if t.MinVersion != "" {
+			v.oneOf("server.websocket.tls.minVersion", t.MinVersion, "1.2", "1.3")
+		}
+		v.nonNegative("server.websocket.tls.watchInterval", t.WatchInterval)
+	}
+	for i, o := range ws.Origin.Allowed {
+		validateOrigin(v, fmt.Sprintf("server.websocket.origin.allowed[%d]", i), o)
+	}
+	for i, p := range ws.Subprotocol.Supported {
+		if p == "" || strings.ContainsAny(p, " ,;\t\"") {
+			v.addf(fmt.Sprintf("server.websocket.subprotocol.supported[%d]", i), "must be a non-empty token without separators, got %q", p)
+		}
+	}
+	if ws.Subprotocol.Required && len(ws.Subprotocol.Supported) == 0 {
+		v.addf("server.websocket.subprotocol.required", "requires at least one supported subprotocol")
+	}
+	if m := ws.Token.SubprotocolMarker; m != "" {
+		if strings.ContainsAny(m, " ,;\t\"") {
+			v.addf("server.websocket.token.subprotocolMarker", "must be a token without separators, got %q", m)
+		}
+		if slices.Contains(ws.Subprotocol.Supported, m) {
+			v.addf("server.websocket.token.subprotocolMarker", "must not be one of subprotocol.supported, got %q", m)
+		}
+	}
+	if c := ws.Token.Cookie; c != "" && strings.ContainsAny(c, " ,;=\t\"") {
+		v.addf("server.websocket.token.cookie", "must be a valid cookie name, got %q", c)
+	}
+	encryption := []string{"disabled", "optional", "required"}
+	if ws.Encryption.Policy != "" {
+		v.oneOf("server.websocket.encryption.policy", ws.Encryption.Policy, encryption...)
+	}
+	for i, p := range ws.Encryption.BizPolicies {
+		v.oneOf(fmt.Sprintf("server.websocket.encryption.bizPolicies[%d].policy", i), p.Policy, encryption...)
+	}
+	v.nonNegative("server.shutdown.gracePeriod", c.Server.Shutdown.GracePeriod)
+}
+
+// validateOrigin accepts "*", scheme://host[:port] and scheme://*.domain[:port]
+func validateOrigin(v *validator, path, origin string) {
+	if origin == "*" {
+		return
+	}
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || scheme == "" || host == "" || strings.ContainsAny(strings.TrimSuffix(host, "/"), "/?#") {
+		v.addf(path, "must be \"*\" or scheme://host[:port], got %q", origin)
+		return
+	}
+	if strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		v.addf(path, "wildcard is only allowed as the leading label, e.g. https://*.example.com, got %q", origin)
+	}
+}
+
+func (c Config) validateLink(v *validator) {
+	l := c.Link
+	v.nonNegative("link.timeout.read", l.Timeout.Read)
+	v.nonNegative("link.timeout.write", l.Timeout.Write)
+	v.nonNegative("link.timeout.idle", l.Timeout.Idle)
+	v.nonNegative("link.buffer.receiveBufferSize", int64(l.Buffer.ReceiveBufferSize))
+	v.nonNegative("link.buffer.sendBufferSize", int64(l.Buffer.SendBufferSize))
+	if l.Buffer.Overflow != "" {
+		v.oneOf("link.buffer.overflow", l.Buffer.Overflow, "dropNewest", "dropOldest", "block", "close")
+	}
+	v.nonNegative("link.buffer.blockTimeout", l.Buffer.BlockTimeout)
+	v.nonNegative("link.batch.window", l.Batch.Window)
+	v.nonNegative("link.batch.maxBytes", int64(l.Batch.MaxBytes))
+	seen := make(map[int64]bool, len(l.Batch.BizPolicies))
+	for i, p := range l.Batch.BizPolicies {
+		path := fmt.Sprintf("link.batch.bizPolicies[%d]", i)
+		v.positive(path+".bizId", p.BizID)
+		if seen[p.BizID] {
+			v.addf(path+".bizId", "duplicates another policy for bizId %d", p.BizID)
+		}
+		seen[p.BizID] = true
+		v.nonNegative(path+".window", p.Window)
+	}
+	v.nonNegative("link.inbound.maxMessageSize", l.Inbound.MaxMessageSize)
+	v.nonNegative("link.inbound.maxDecompressedSize", l.Inbound.MaxDecompressedSize)
+	v.nonNegative("link.inbound.maxFragments", int64(l.Inbound.MaxFragments))
+	v.nonNegative("link.inbound.fragmentTimeout", l.Inbound.FragmentTimeout)
+	v.nonNegative("link.limit.rate", int64(l.Limit.Rate))
+	v.nonNegative("link.limit.burst", int64(l.Limit.Burst))
+	v.nonNegative("link.limit.maxWait", l.Limit.MaxWait)
+	if l.Limit.Policy != "" {
+		v.oneOf("link.limit.policy", l.Limit.Policy, "drop", "queue", "close")
+	}
+	validateRetry(v, "link.retryStrategy", l.RetryStrategy)
+	validateRetry(v, "link.eventHandler.retryStrategy", l.EventHandler.RetryStrategy)
+	v.nonNegative("link.eventHandler.requestTimeout", l.EventHandler.RequestTimeout)
+	v.nonNegative("link.eventHandler.pushMessage.retryInterval", l.EventHandler.PushMessage.RetryInterval)
+	v.nonNegative("link.eventHandler.pushMessage.maxRetries", int64(l.EventHandler.PushMessage.MaxRetries))
+}
+
+func validateRetry(v *validator, path string, r RetryStrategyConfig) {
+	v.nonNegative(path+".initInterval", r.InitInterval)
+	v.nonNegative(path+".maxRetries", int64(r.MaxRetries))
+	if r.MaxInterval != 0 && r.MaxInterval < r.InitInterval {
+		v.addf(path+".maxInterval", "must not be less than initInterval (%d), got %d", r.InitInterval, r.MaxInterval)
+	}
+}
+
+func (c Config) validateMetrics(v *validator) {
+	v.httpPath("metrics.path", c.Metrics.Path)
+	v.ratio("metrics.messageSampleRate", c.Metrics.MessageSampleRate)
+}
+
+func (c Config) validateSession(v *validator) {
+	v.nonNegative("session.ttl", c.Session.TTL)
+	v.nonNegative("session.enrichment.timeout", c.Session.Enrichment.Timeout)
+	if c.Session.Enrichment.UserServiceURL != "" {
+		v.absoluteURL("session.enrichment.userServiceURL", c.Session.Enrichment.UserServiceURL)
+	}
+	policies := []string{"allowMulti", "kickOld", "rejectNew", "takeover"}
+	if c.Session.Devices.Policy != "" {
+		v.oneOf("session.devices.policy", c.Session.Devices.Policy, policies...)
+	}
+	for i, p := range c.Session.Devices.BizPolicies {
+		v.oneOf(fmt.Sprintf("session.devices.bizPolicies[%d].policy", i), p.Policy, policies...)
+	}
+	seen := make(map[int64]bool, len(c.Session.Devices.Limits))
+	for i, l := range c.Session.Devices.Limits {
+		path := fmt.Sprintf("session.devices.limits[%d]", i)
+		v.positive(path+".bizId", l.BizID)
+		if seen[l.BizID] {
+			v.addf(path+".bizId", "duplicates another limit for bizId %d", l.BizID)
+		}
+		seen[l.BizID] = true
+		v.positive(path+".maxDevices", int64(l.MaxDevices))
+		if l.Overflow != "" {
+			v.oneOf(path+".overflow", l.Overflow, "reject", "kickOldest")
+		}
+	}
+	v.nonNegative("session.pipeline.window", c.Session.Pipeline.Window)
+	if c.Session.Pipeline.Window > 0 {
+		v.positive("session.pipeline.maxBatch", int64(c.Session.Pipeline.MaxBatch))
+	}
+	loss := c.Session.Loss
+	v.nonNegative("session.loss.checkInterval", loss.CheckInterval)
+	if loss.Policy != "" {
+		v.oneOf("session.loss.policy", loss.Policy, "rebuild", "close")
+	}
+	seen = make(map[int64]bool, len(loss.BizPolicies))
+	for i, p := range loss.BizPolicies {
+		path := fmt.Sprintf("session.loss.bizPolicies[%d]", i)
+		v.positive(path+".bizId", p.BizID)
+		if seen[p.BizID] {
+			v.addf(path+".bizId", "duplicates another policy for bizId %d", p.BizID)
+		}
+		seen[p.BizID] = true
+		v.oneOf(path+".policy", p.Policy, "rebuild", "close")
+	}
+	if c.Session.Degraded.Enabled {
+		if c.Session.Backend == "memory" {
+			v.addf("session.degraded.enabled", "has no effect with the memory session backend")
+		}
+		if loss.CheckInterval <= 0 {
+			v.addf("session.degraded.enabled", "requires session.loss.checkInterval to reconcile degraded sessions")
+		}
+	}
+}
+
+func (c Config) validateAPI(v *validator) {
+	seen := make(map[string]bool, len(c.API.Keys))
+	for i, k := range c.API.Keys {
+		path := fmt.Sprintf("api.keys[%d]", i)
+		v.required(path+".name", k.Name)
+		v.required(path+".key", k.Key)
+		if k.Key != "" && seen[k.Key] {
+			v.addf(path+".key", "duplicates another API key")
+		}
+		seen[k.Key] = true
+	}
+	cp := c.API.Compression
+	if cp.Enabled {
+		v.between("api.compression.level", int64(cp.Level), 0, 9)
+		v.nonNegative("api.compression.minSize", int64(cp.MinSize))
+		v.nonNegative("api.compression.maxRequestSize", cp.MaxRequestSize)
+	}
+	if c.API.GRPC.Enabled {
+		v.hostPort("api.grpc.addr", c.API.GRPC.Addr)
+	}
+}
+
+func (c Config) validateBroker(v *validator) {
+	strategies := []string{"userId", "bizId", "room", "roundRobin"}
+	if c.Broker.DefaultKeyStrategy != "" {
+		v.oneOf("broker.defaultKeyStrategy", c.Broker.DefaultKeyStrategy, strategies...)
+	}
+	for i, t := range c.Broker.Topics {
+		path := fmt.Sprintf("broker.topics[%d]", i)
+		v.required(path+".name", t.Name)
+		if t.KeyStrategy != "" {
+			v.oneOf(path+".keyStrategy", t.KeyStrategy, strategies...)
+		}
+	}
+	if cc := c.Broker.Consumer; cc.Enabled {
+		if len(cc.Brokers) == 0 {
+			v.addf("broker.consumer.brokers", "must not be empty")
+		}
+		for i, b := range cc.Brokers {
+			v.hostPort(fmt.Sprintf("broker.consumer.brokers[%d]", i), b)
+		}
+		v.required("broker.consumer.topic", cc.Topic)
+		v.required("broker.consumer.groupId", cc.GroupID)
+		if cc.Encoding != "" {
+			v.oneOf("broker.consumer.encoding", cc.Encoding, "proto", "json")
+		}
+		if cc.DeadLetterTopic != "" && cc.DeadLetterTopic == cc.Topic {
+			v.addf("broker.consumer.deadLetterTopic", "must differ from broker.consumer.topic")
+		}
+		v.positive("broker.consumer.maxRetryBackoff", cc.MaxRetryBackoff)
+	}
+}
+
+func (c Config) validateBackoff(v *validator) {
+	for _, b := range []struct {
+		name   string
+		policy BackoffPolicyConfig
+	}{
+		{"drain", c.Backoff.Drain},
+		{"rateLimit", c.Backoff.RateLimit},
+		{"capacity", c.Backoff.Capacity},
+	} {
+		path, p := "backoff."+b.name, b.policy
+		v.nonNegative(path+".min", p.Min)
+		if p.Max != 0 && p.Max < p.Min {
+			v.addf(path+".max", "must not be less than min (%d), got %d", p.Min, p.Max)
+		}
+		v.ratio(path+".jitter", p.Jitter)
+	}
+}
+
+func (c Config) validateBackend(v *validator) {
+	services := make(map[string]bool, len(c.Backend.Services))
+	for i, s := range c.Backend.Services {
+		path := fmt.Sprintf("backend.services[%d]", i)
+		v.required(path+".name", s.Name)
+		if s.Name != "" && services[s.Name] {
+			v.addf(path+".name", "duplicates another service %q", s.Name)
+		}
+		services[s.Name] = true
+		if s.Protocol != "" {
+			v.oneOf(path+".protocol", s.Protocol, "http", "grpc", "mock")
+		}
+		if s.Protocol == "mock" {
+			validateMock(v, path+".mock", s.Mock)
+		} else {
+			v.required(path+".url", s.URL)
+		}
+	}
+	for i, r := range c.RPC.Routes {
+		path := fmt.Sprintf("rpc.routes[%d]", i)
+		v.required(path+".cmd", r.Cmd)
+		if !services[r.Service] {
+			v.addf(path+".service", "references unknown backend service %q", r.Service)
+		}
+	}
+	policies := []string{"error", "cached", "silence"}
+	if p := c.RPC.TimeoutResponse.Policy; p != "" {
+		v.oneOf("rpc.timeoutResponse.policy", p, policies...)
+	}
+	for i, t := range c.RPC.TimeoutResponse.Tenants {
+		if t.Policy != "" {
+			v.oneOf(fmt.Sprintf("rpc.timeoutResponse.tenants[%d].policy", i), t.Policy, policies...)
+		}
+	}
+}
+
+func validateMock(v *validator, path string, m MockBackendConfig) {
+	if len(m.Rules) == 0 {
+		v.addf(path+".rules", "must not be empty for a mock service")
+	}
+	for i, r := range m.Rules {
+		rp := fmt.Sprintf("%s.rules[%d]", path, i)
+		if _, err := regexp.Compile(r.Match); err != nil {
+			v.addf(rp+".match", "must be a valid regular expression: %v", err)
+		}
+		v.nonNegative(rp+".delay", r.Delay)
+		if r.Error != "" {
+			v.oneOf(rp+".error", r.Error, "timeout", "unavailable", "rejected")
+		}
+		v.ratio(rp+".errorRatio", r.ErrorRatio)
+	}
+}
+
+func (c Config) validateAbuse(v *validator) {
+	// 握手限流只在本节点内存中计数，不依赖 abuse.enabled
+	v.nonNegative("abuse.handshake.rate", int64(c.Abuse.Handshake.Rate))
+	v.nonNegative("abuse.handshake.burst", int64(c.Abuse.Handshake.Burst))
+	if !c.Abuse.Enabled {
+		return
+	}
+	v.positive("abuse.window", c.Abuse.Window)
+	v.positive("abuse.threshold", c.Abuse.Threshold)
+	v.nonNegative("abuse.ban.base", c.Abuse.Ban.Base)
+	if c.Abuse.Ban.Max != 0 && c.Abuse.Ban.Max < c.Abuse.Ban.Base {
+		v.addf("abuse.ban.max", "must not be less than ban.base (%d), got %d", c.Abuse.Ban.Base, c.Abuse.Ban.Max)
+	}
+}
+
+func (c Config) validateWebhook(v *validator) {
+	seen := make(map[int64]bool, len(c.Webhook.PreAccept))
+	for i, w := range c.Webhook.PreAccept {
+		path := fmt.Sprintf("webhook.preAccept[%d]", i)
+		if seen[w.BizID] {
+			v.addf(path+".bizId", "at most one preAccept webhook per bizId, %d is repeated", w.BizID)
+		}
+		seen[w.BizID] = true
+		v.absoluteURL(path+".url", w.URL)
+		v.nonNegative(path+".timeout", w.Timeout)
+		if w.FailurePolicy != "" {
+			v.oneOf(path+".failurePolicy", w.FailurePolicy, "open", "closed")
+		}
+	}
+
+	in := c.Webhook.Inbound
+	if len(in.Hooks) > 0 {
+		v.required("webhook.inbound.path", in.Path)
+		v.httpPath("webhook.inbound.path", in.Path)
+	}
+	names := make(map[string]bool, len(in.Hooks))
+	for i, h := range in.Hooks {
+		path := fmt.Sprintf("webhook.inbound.hooks[%d]", i)
+		if h.Name == "" || strings.ContainsAny(h.Name, "/?#% ") {
+			v.addf(path+".name", "must be a non-empty URL path segment, got %q", h.Name)
+		}
+		if names[h.Name] {
+			v.addf(path+".name", "inbound webhook names must be unique, %q is repeated", h.Name)
+		}
+		names[h.Name] = true
+		v.required(path+".secret", h.Secret)
+		v.positive(path+".bizId", h.BizID)
+		v.oneOf(path+".target", h.Target, "user", "users", "group", "room", "broadcast")
+		switch h.Target {
+		case "user":
+			v.required(path+".userId", h.UserID)
+		case "users":
+			v.required(path+".userIds", h.UserIDs)
+		case "group":
+			v.required(path+".group", h.Group)
+		case "room":
+			v.required(path+".room", h.Room)
+		}
+	}
+
+	for i, w := range c.Webhook.Lifecycle {
+		path := fmt.Sprintf("webhook.lifecycle[%d]", i)
+		v.nonNegative(path+".bizId", w.BizID)
+		v.absoluteURL(path+".url", w.URL)
+		for j, e := range w.Events {
+			v.oneOf(fmt.Sprintf("%s.events[%d]", path, j), e, "connect", "disconnect", "idleClose")
+		}
+	}
+}
+
+func (c Config) validateAdmission(v *validator) {
+	a := c.Admission
+	v.nonNegative("admission.memoryLimit", a.MemoryLimit)
+	v.nonNegative("admission.queueTimeout", a.QueueTimeout)
+	if a.QueueTimeout > 0 {
+		v.positive("admission.queueInterval", a.QueueInterval)
+	}
+	v.nonNegative("admission.defaultBizQuota", int64(a.DefaultBizQuota))
+	v.nonNegative("admission.defaultUserQuota", int64(a.DefaultUserQuota))
+	for i, q := range a.BizQuotas {
+		path := fmt.Sprintf("admission.bizQuotas[%d]", i)
+		v.positive(path+".bizId", q.BizID)
+		v.nonNegative(path+".maxConnections", int64(q.MaxConnections))
+		v.nonNegative(path+".maxUserConnections", int64(q.MaxUserConnections))
+	}
+}
+
+func (c Config) validateScaling(v *validator) {
+	s := c.Scaling
+	v.httpPath("scaling.path", s.Path)
+	v.nonNegative("scaling.sampleInterval", s.SampleInterval)
+	v.nonNegative("scaling.rateWindow", s.RateWindow)
+	v.nonNegative("scaling.minReadyCapacity", s.MinReadyCapacity)
+	if s.MinReadyCapacity > c.Server.Websocket.TokenLimiter.MaxCapacity {
+		v.addf("scaling.minReadyCapacity", "must not exceed server.websocket.tokenLimiter.maxCapacity (%d), got %d",
+			c.Server.Websocket.TokenLimiter.MaxCapacity, s.MinReadyCapacity)
+	}
+	v.nonNegative("scaling.preStop.delay", s.PreStop.Delay)
+	if s.Warmup.Enabled {
+		v.positive("scaling.warmup.timeout", s.Warmup.Timeout)
+		v.nonNegative("scaling.warmup.redisConns", int64(s.Warmup.RedisConns))
+		if c.Redis.PoolSize > 0 && s.Warmup.RedisConns > c.Redis.PoolSize {
+			v.addf("scaling.warmup.redisConns", "must not exceed redis.pool_size (%d), got %d", c.Redis.PoolSize, s.Warmup.RedisConns)
+		}
+	}
+}
+
+func (c Config) validateIncident(v *validator) {
+	inc := c.Incident
+	if !inc.Enabled {
+		return
+	}
+	v.required("incident.dir", inc.Dir)
+	v.nonNegative("incident.maxFiles", int64(inc.MaxFiles))
+	v.nonNegative("incident.maxPerMinute", int64(inc.MaxPerMinute))
+	v.nonNegative("incident.trailSize", int64(inc.TrailSize))
+}
+
+func (c Config) validateResume(v *validator) {
+	r := c.Resume
+	if !r.Enabled {
+		return
+	}
+	v.oneOf("resume.store", r.Store, "redis", "memory")
+	v.positive("resume.bufferSize", r.BufferSize)
+	v.positive("resume.ttl", r.TTL)
+}
+
+func (c Config) validateCluster(v *validator) {
+	p := c.Cluster.Placement
+	if !p.Enabled {
+		return
+	}
+	if !c.Cluster.Enabled {
+		v.addf("cluster.placement.enabled", "requires cluster.enabled")
+	}
+	v.nonNegative("cluster.placement.replicas", int64(p.Replicas))
+	v.positive("cluster.placement.refreshInterval", p.RefreshInterval)
+	v.httpPath("cluster.placement.path", p.Path)
+}
+
+func (c Config) validateOffline(v *validator) {
+	o := c.Offline
+	if !o.Enabled {
+		return
+	}
+	v.positive("offline.retention", o.Retention)
+	v.positive("offline.maxMessages", o.MaxMessages)
+}
+
+func (c Config) validateRooms(v *validator) {
+	r := c.Rooms
+	if !r.Enabled {
+		return
+	}
+	v.positive("rooms.ttl", r.TTL)
+	v.positive("rooms.maxRoomsPerLink", int64(r.MaxRoomsPerLink))
+	v.nonNegative("rooms.archive.maxLen", int64(r.Archive.MaxLen))
+	v.nonNegative("rooms.archive.ttl", r.Archive.TTL)
+	seen := make(map[int64]bool, len(r.Archive.BizPolicies))
+	for i, p := range r.Archive.BizPolicies {
+		path := fmt.Sprintf("rooms.archive.bizPolicies[%d]", i)
+		v.positive(path+".bizId", p.BizID)
+		if seen[p.BizID] {
+			v.addf(path+".bizId", "duplicates another policy for bizId %d", p.BizID)
+		}
+		seen[p.BizID] = true
+		v.nonNegative(path+".maxLen", int64(p.MaxLen))
+	}
+}
+
+func (c Config) validateGeoIP(v *validator) {
+	g := c.GeoIP
+	if !g.Enabled {
+		return
+	}
+	v.required("geoip.path", g.Path)
+	countryCodes := func(path string, codes []string) {
+		for i, code := range codes {
+			if len(code) != 2 || strings.ToUpper(code) != code {
+				v.addf(fmt.Sprintf("%s[%d]", path, i), "must be an uppercase ISO 3166-1 alpha-2 country code, got %q", code)
+			}
+		}
+	}
+	countryCodes("geoip.metricCountries", g.MetricCountries)
+	seen := make(map[int64]bool, len(g.Policies))
+	for i, p := range g.Policies {
+		path := fmt.Sprintf("geoip.policies[%d]", i)
+		v.positive(path+".bizId", p.BizID)
+		if seen[p.BizID] {
+			v.addf(path+".bizId", "duplicates another policy for bizId %d", p.BizID)
+		}
+		seen[p.BizID] = true
+		if len(p.AllowCountries) > 0 && len(p.DenyCountries) > 0 {
+			v.addf(path, "allowCountries and denyCountries are mutually exclusive")
+		}
+		if len(p.DenyASNs) > 0 && g.ASNPath == "" {
+			v.addf(path+".denyAsns", "requires geoip.asnPath")
+		}
+		countryCodes(path+".allowCountries", p.AllowCountries)
+		countryCodes(path+".denyCountries", p.DenyCountries)
+	}
+}
+
+func (c Config) validateGuest(v *validator) {
+	seen := make(map[int64]bool, len(c.Guest.Policies))
+	for i, p := range c.Guest.Policies {
+		path := fmt.Sprintf("guest.policies[%d]", i)
+		v.positive(path+".bizId", p.BizID)
+		if seen[p.BizID] {
+			v.addf(path+".bizId", "duplicates another policy for bizId %d", p.BizID)
+		}
+		seen[p.BizID] = true
+		v.nonNegative(path+".maxConnections", int64(p.MaxConnections))
+		v.nonNegative(path+".maxLifetime", p.MaxLifetime)
+	}
+}
+
+func (c Config) validateTracing(v *validator) {
+	t := c.Tracing
+	if !t.Enabled {
+		return
+	}
+	v.oneOf("tracing.exporter", t.Exporter, "otlp", "stdout")
+	if t.Exporter == "otlp" {
+		v.hostPort("tracing.endpoint", t.Endpoint)
+	}
+	v.ratio("tracing.sampleRatio", t.SampleRatio)
+	v.nonNegative("tracing.timeout", t.Timeout)
+}
+
+func (c Config) validateAudit(v *validator) {
+	a := c.Audit
+	if !a.Enabled {
+		return
+	}
+	if a.Output != "" {
+		v.oneOf("audit.output", a.Output, "file", "console")
+	}
+	if a.Output != "console" {
+		v.required("audit.path", a.Path)
+	}
+	v.ratio("audit.sampleRatio", a.SampleRatio)
+}
+
+func (c Config) validateUsage(v *validator) {
+	u := c.Usage
+	if !u.Enabled {
+		return
+	}
+	v.positive("usage.interval", u.Interval)
+	if !u.Redis.Enabled && u.CSV.Dir == "" && u.Webhook.URL == "" {
+		v.addf("usage", "at least one of redis.enabled, csv.dir or webhook.url must be set")
+	}
+	v.nonNegative("usage.redis.ttl", u.Redis.TTL)
+	if u.Webhook.URL != "" {
+		v.absoluteURL("usage.webhook.url", u.Webhook.URL)
+	}
+	v.nonNegative("usage.webhook.timeout", u.Webhook.Timeout)
+}
+
+func (c Config) validateDebug(v *validator) {
+	if c.Debug.Enabled {
+		v.hostPort("debug.addr", c.Debug.Addr)
+	}
+}
+
+func (c Config) validateFanout(v *validator) {
+	f := c.Fanout
+	if !f.Enabled {
+		return
+	}
+	v.positive("fanout.slice", f.Slice)
+	v.nonNegative("fanout.workers", int64(f.Workers))
+	v.positive("fanout.budgets.high", int64(f.Budgets.High))
+	v.positive("fanout.budgets.normal", int64(f.Budgets.Normal))
+	v.positive("fanout.budgets.low", int64(f.Budgets.Low))
+	seen := make(map[int64]bool, len(f.BizPriorities))
+	for i, p := range f.BizPriorities {
+		path := fmt.Sprintf("fanout.bizPriorities[%d]", i)
+		v.positive(path+".bizId", p.BizID)
+		if seen[p.BizID] {
+			v.addf(path+".bizId", "duplicates another priority for bizId %d", p.BizID)
+		}
+		seen[p.BizID] = true
+		v.oneOf(path+".priority", p.Priority, "high", "normal", "low")
+	}
+}
+
+func (c Config) validateDegrade(v *validator) {
+	d := c.Degrade
+	if !d.Enabled {
+		return
+	}
+	v.positive("degrade.slowBandwidth", d.SlowBandwidth)
+	seen := make(map[int64]bool, len(d.Policies))
+	for i, p := range d.Policies {
+		path := fmt.Sprintf("degrade.policies[%d]", i)
+		v.positive(path+".bizId", p.BizID)
+		if seen[p.BizID] {
+			v.addf(path+".bizId", "duplicates another policy for bizId %d", p.BizID)
+		}
+		seen[p.BizID] = true
+		v.nonNegative(path+".minInterval", p.MinInterval)
+		if len(p.DropFields) == 0 && p.MinInterval == 0 {
+			v.addf(path, "at least one of dropFields and minInterval must be set")
+		}
+		for j, f := range p.DropFields {
+			if f == "" || strings.HasPrefix(f, ".") || strings.HasSuffix(f, ".") || strings.Contains(f, "..") {
+				v.addf(fmt.Sprintf("%s.dropFields[%d]", path, j), "must be a dot-separated field path, got %q", f)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidPublicKey = errors.New("无效的加密公钥")
+	ErrDecrypt          = errors.New("消息解密失败")
+)
+
+const (
+	// keySize AES-256 的密钥长度
+	keySize = 32
+
+	// 两个方向使用不同的密钥，计数器形式的 nonce 才不会在两个方向上重复
+	infoClientToServer = "wsgateway encryption c2s"
+	infoServerToClient = "wsgateway encryption s2c"
+)
+
+// Curve 密钥协商使用的椭圆曲线，选择 P-256 是因为浏览器的 WebCrypto 同样支持
+var Curve = ecdh.P256()
+
+// GenerateKey 生成一个连接使用的临时密钥对
+func GenerateKey() (*ecdh.PrivateKey, error) {
+	return Curve.GenerateKey(rand.Reader)
+}
+
+// EncodePublicKey 把公钥编码为 ?encKey= 查询参数的值：未压缩点的 base64url 编码，不带填充
+func EncodePublicKey(key *ecdh.PublicKey) string {
+	return base64.RawURLEncoding.EncodeToString(key.Bytes())
+}
+
+// ParsePublicKey 解析 ?encKey= 查询参数携带的公钥
+func ParsePublicKey(s string) (*ecdh.PublicKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPublicKey, err)
+	}
+	key, err := Curve.NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPublicKey, err)
+	}
+	return key, nil
+}
+
+// Cipher 一个连接上的 AES-256-GCM 加解密器
+//
+// 密钥由 ECDH 共享密钥经 HKDF-SHA256 导出，salt 为客户端公钥与网关公钥的拼接，两个方向各用一个密钥。
+// nonce 为 4 字节 0 加上 8 字节大端序的消息序号，双方各自从 0 开始计数，不随消息发送；
+// WebSocket 保证消息有序，重放、丢弃或调换顺序的消息都无法解密。
+// Seal 和 Open 各自只能被一个协程调用，Link 的写协程和读协程正好满足这一点
+type Cipher struct {
+	seal      cipher.AEAD
+	open      cipher.AEAD
+	sealCount uint64
+	openCount uint64
+}
+
+// NewServerCipher 以网关的私钥和客户端的公钥创建网关一侧的加解密器
+func NewServerCipher(priv *ecdh.PrivateKey, client *ecdh.PublicKey) (*Cipher, error) {
+	return newCipher(priv, client, client, priv.PublicKey(), infoServerToClient, infoClientToServer)
+}
+
+// NewClientCipher 以客户端的私钥和网关的公钥创建客户端一侧的加解密器
+func NewClientCipher(priv *ecdh.PrivateKey, server *ecdh.PublicKey) (*Cipher, error) {
+	return newCipher(priv, server, priv.PublicKey(), server, infoClientToServer, infoServerToClient)
+}
+
+func newCipher(priv *ecdh.PrivateKey, peer, client, server *ecdh.PublicKey, sealInfo, openInfo string) (*Cipher, error) {
+	secret, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPublicKey, err)
+	}
+	salt := append(client.Bytes(), server.Bytes()...)
+	seal, err := newAEAD(secret, salt, sealInfo)
+	if err != nil {
+		return nil, err
+	}
+	open, err := newAEAD(secret, salt, openInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{seal: seal, open: open}, nil
+}
+
+func newAEAD(secret, salt []byte, info string) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, secret, salt, info, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal 加密一条发送的消息，返回密文和 16 字节的认证标签
+func (c *Cipher) Seal(plaintext []byte) []byte {
+	nonce := counterNonce(c.sealCount)
+	c.sealCount++
+	return c.seal.Seal(nil, nonce, plaintext, nil)
+}
+
+// Open 解密一条收到的消息，密文被篡改或顺序不符时返回 ErrDecrypt
+func (c *Cipher) Open(ciphertext []byte) ([]byte, error) {
+	nonce := counterNonce(c.openCount)
+	plaintext, err := c.open.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	c.openCount++
+	return plaintext, nil
+}
+
+func counterNonce(n uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], n)
+	return nonce
+}
@@ -0,0 +1,122 @@
+// Package encryption 实现与 TLS 无关的逐连接消息加密
+//
+// 客户端在握手请求中以 ?encKey= 查询参数携带临时公钥，网关按业务方的策略决定是否加密，
+// 在连接建立后首先下发不加密的 KEY_EXCHANGE 消息告知协商结果，之后双方的数据帧都以协商出的密钥加密。
+// 双方的密钥对只对一个连接有效，重连时重新生成，因此每次重连都会轮换密钥。
+package encryption
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// Policy 业务方的加密策略
+type Policy string
+
+const (
+	// PolicyDisabled 不加密，客户端携带公钥时回应空的 KEY_EXCHANGE
+	PolicyDisabled Policy = "disabled"
+	// PolicyOptional 客户端携带公钥时加密
+	PolicyOptional Policy = "optional"
+	// PolicyRequired 客户端必须携带公钥，否则拒绝握手
+	PolicyRequired Policy = "required"
+)
+
+var (
+	// ErrUnknownPolicy 表示配置了不支持的加密策略
+	ErrUnknownPolicy = errors.New("未知的加密策略")
+	// ErrEncryptionRequired 表示业务方要求加密而客户端没有携带公钥
+	ErrEncryptionRequired = errors.New("业务方要求加密，握手请求缺少公钥")
+)
+
+// State 一次握手的加密协商结果，客户端没有携带公钥时为 nil
+type State struct {
+	// Enabled 是否加密，客户端携带了公钥但业务方未启用加密时为 false
+	Enabled bool
+	// PublicKey 网关的临时公钥（未压缩点），作为 KEY_EXCHANGE 消息的 body 下发，未加密时为空
+	PublicKey []byte
+	// Cipher 连接使用的加解密器，未加密时为 nil
+	Cipher *Cipher
+}
+
+// Negotiator 按业务方的加密策略协商连接的加密
+type Negotiator struct {
+	defaultPolicy Policy
+	biz           map[int64]Policy
+}
+
+func NewNegotiator(i do.Injector) (*Negotiator, error) {
+	cfg, err := do.Invoke[config.ServerConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	return newNegotiator(cfg.Websocket.Encryption)
+}
+
+func newNegotiator(cfg config.EncryptionConfig) (*Negotiator, error) {
+	n := &Negotiator{defaultPolicy: PolicyOptional, biz: make(map[int64]Policy, len(cfg.BizPolicies))}
+	if cfg.Policy != "" {
+		policy, err := parsePolicy(cfg.Policy)
+		if err != nil {
+			return nil, err
+		}
+		n.defaultPolicy = policy
+	}
+	for _, b := range cfg.BizPolicies {
+		policy, err := parsePolicy(b.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("bizId=%d: %w", b.BizID, err)
+		}
+		n.biz[b.BizID] = policy
+	}
+	return n, nil
+}
+
+func parsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyDisabled, PolicyOptional, PolicyRequired:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownPolicy, s)
+	}
+}
+
+// Policy 返回业务方的加密策略
+func (n *Negotiator) Policy(bizID int64) Policy {
+	if policy, ok := n.biz[bizID]; ok {
+		return policy
+	}
+	return n.defaultPolicy
+}
+
+// Negotiate 以客户端携带的公钥协商加密，clientKey 为 ?encKey= 查询参数的值
+// 客户端没有携带公钥时返回 nil，业务方要求加密时返回 ErrEncryptionRequired；
+// 网关的密钥对每次协商都重新生成
+func (n *Negotiator) Negotiate(bizID int64, clientKey string) (*State, error) {
+	policy := n.Policy(bizID)
+	if clientKey == "" {
+		if policy == PolicyRequired {
+			return nil, ErrEncryptionRequired
+		}
+		return nil, nil
+	}
+	peer, err := ParsePublicKey(clientKey)
+	if err != nil {
+		return nil, err
+	}
+	if policy == PolicyDisabled {
+		return &State{}, nil
+	}
+	priv, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewServerCipher(priv, peer)
+	if err != nil {
+		return nil, err
+	}
+	return &State{Enabled: true, PublicKey: priv.PublicKey().Bytes(), Cipher: c}, nil
+}
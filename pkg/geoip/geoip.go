@@ -0,0 +1,186 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/samber/do/v2"
+)
+
+// 指标标签中的特殊取值
+const (
+	LabelUnknown = "unknown" // 未启用解析或IP不在数据库中（内网地址等）
+	LabelOther   = "other"   // 不在 geoip.metricCountries 中的国家
+)
+
+// ErrBlocked 表示客户端所在的国家或自治系统被业务方的策略拒绝
+var ErrBlocked = errors.New("客户端所在地区不允许连接")
+
+// Location 客户端IP解析得到的地理位置，未解析出的字段为零值
+type Location struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 国家代码，例如 CN、US
+	Region  string `json:"region,omitempty"`  // ISO 3166-2 一级行政区代码（不含国家前缀），只有 City 数据库才有
+	ASN     uint   `json:"asn,omitempty"`     // 自治系统号，配置了 ASN 数据库时才有
+	ASOrg   string `json:"asOrg,omitempty"`   // 自治系统所属的组织
+}
+
+// Known 返回是否解析出了任何位置信息
+func (l Location) Known() bool {
+	return l.Country != "" || l.ASN != 0
+}
+
+// Fields 返回写入会话的字段，没有解析出的字段不写入
+func (l Location) Fields() map[string]string {
+	fields := make(map[string]string, 3)
+	if l.Country != "" {
+		fields["country"] = l.Country
+	}
+	if l.Region != "" {
+		fields["region"] = l.Region
+	}
+	if l.ASN != 0 {
+		fields["asn"] = strconv.FormatUint(uint64(l.ASN), 10)
+	}
+	return fields
+}
+
+// countryRecord GeoIP2/GeoLite2 Country 和 City 数据库中用到的字段
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+// asnRecord GeoLite2 ASN 数据库的记录
+type asnRecord struct {
+	Number uint   `maxminddb:"autonomous_system_number"`
+	Org    string `maxminddb:"autonomous_system_organization"`
+}
+
+// policy 单个业务方的地区准入策略
+type policy struct {
+	allow    []string // 不为空时只允许这些国家，未解析出国家的连接也被拒绝
+	deny     []string
+	denyASNs []uint
+}
+
+// Resolver 在握手时按客户端IP解析国家、行政区和自治系统 (MaxMind DB)，并按业务方的策略拒绝特定地区的连接
+//
+// 解析结果随握手上下文传给连接 (Link.Geo)，写入会话元数据，并作为低基数的指标标签：
+// 只有 metricCountries 中的国家使用国家代码，其余归为 other，避免每个国家一个时间序列
+type Resolver struct {
+	country  *maxminddb.Reader // 未启用时为 nil
+	asn      *maxminddb.Reader // 未配置 ASN 数据库时为 nil
+	labels   []string
+	policies map[int64]policy
+}
+
+func NewResolver(i do.Injector) (*Resolver, error) {
+	cfg, err := do.Invoke[config.GeoIPConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	r := &Resolver{}
+	if !cfg.Enabled {
+		return r, nil
+	}
+	if r.country, err = maxminddb.Open(cfg.Path); err != nil {
+		return nil, fmt.Errorf("打开 GeoIP 数据库 %s 失败: %w", cfg.Path, err)
+	}
+	if cfg.ASNPath != "" {
+		if r.asn, err = maxminddb.Open(cfg.ASNPath); err != nil {
+			_ = r.country.Close()
+			return nil, fmt.Errorf("打开 ASN 数据库 %s 失败: %w", cfg.ASNPath, err)
+		}
+	}
+	r.labels = cfg.MetricCountries
+	r.policies = make(map[int64]policy, len(cfg.Policies))
+	for _, p := range cfg.Policies {
+		denyASNs := make([]uint, len(p.DenyASNs))
+		for i, n := range p.DenyASNs {
+			denyASNs[i] = uint(n)
+		}
+		r.policies[p.BizID] = policy{allow: p.AllowCountries, deny: p.DenyCountries, denyASNs: denyASNs}
+	}
+	return r, nil
+}
+
+// Enabled 返回是否启用了地理位置解析
+func (r *Resolver) Enabled() bool {
+	return r.country != nil
+}
+
+// Lookup 解析IP的地理位置，未启用、IP无效或不在数据库中时返回零值
+func (r *Resolver) Lookup(ip string) Location {
+	var loc Location
+	if !r.Enabled() {
+		return loc
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return loc
+	}
+	var rec countryRecord
+	if err := r.country.Lookup(addr, &rec); err == nil {
+		loc.Country = rec.Country.ISOCode
+		if len(rec.Subdivisions) > 0 {
+			loc.Region = rec.Subdivisions[0].ISOCode
+		}
+	}
+	if r.asn != nil {
+		var as asnRecord
+		if err := r.asn.Lookup(addr, &as); err == nil {
+			loc.ASN, loc.ASOrg = as.Number, as.Org
+		}
+	}
+	return loc
+}
+
+// Label 返回位置对应的指标标签
+func (r *Resolver) Label(loc Location) string {
+	switch {
+	case loc.Country == "":
+		return LabelUnknown
+	case slices.Contains(r.labels, loc.Country):
+		return loc.Country
+	default:
+		return LabelOther
+	}
+}
+
+// Check 按业务方的策略检查是否允许来自 loc 的连接，被拒绝时返回 ErrBlocked
+func (r *Resolver) Check(bizID int64, loc Location) error {
+	p, ok := r.policies[bizID]
+	if !ok {
+		return nil
+	}
+	switch {
+	case len(p.allow) > 0 && !slices.Contains(p.allow, loc.Country):
+		return fmt.Errorf("%w: country=%q", ErrBlocked, loc.Country)
+	case loc.Country != "" && slices.Contains(p.deny, loc.Country):
+		return fmt.Errorf("%w: country=%s", ErrBlocked, loc.Country)
+	case loc.ASN != 0 && slices.Contains(p.denyASNs, loc.ASN):
+		return fmt.Errorf("%w: asn=%d", ErrBlocked, loc.ASN)
+	}
+	return nil
+}
+
+// Shutdown 关闭数据库文件
+func (r *Resolver) Shutdown() error {
+	var errs []error
+	if r.country != nil {
+		errs = append(errs, r.country.Close())
+	}
+	if r.asn != nil {
+		errs = append(errs, r.asn.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,10 @@
+package geoip
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package 定义 GeoIP 包的服务包，使用 Package Loading 模式
+var Package = do.Package(
+	do.Lazy(NewResolver),
+)
@@ -0,0 +1,229 @@
+// Package hashring 实现带虚拟节点的一致性哈希环，用于把用户确定性地映射到首选节点
+//
+// 每个节点在环上放置 replicas 个虚拟节点，键落在顺时针方向的第一个虚拟节点所属的节点上。
+// 节点集合变化时只有落在增减节点上的键会改变归属，约为键总数的 1/节点数，其余键的首选节点保持不变。
+//
+//	r := hashring.New(hashring.DefaultReplicas)
+//	r.Set("node-a", "node-b", "node-c")
+//	node, ok := r.Get("1:10086")
+package hashring
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// DefaultReplicas 每个节点默认的虚拟节点数，节点数较少时也能让键分布得比较均匀
+const DefaultReplicas = 160
+
+// Ring 一致性哈希环，并发安全
+type Ring struct {
+	replicas int
+
+	mu     sync.RWMutex
+	hashes []uint64          // 虚拟节点的哈希值，升序排列
+	owners map[uint64]string // 虚拟节点哈希值所属的节点
+	nodes  []string          // 节点，按名称排序
+}
+
+// New 创建空的哈希环，replicas 不大于 0 时使用 DefaultReplicas
+func New(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &Ring{replicas: replicas, owners: make(map[uint64]string)}
+}
+
+// Change 一次节点集合变化的结果
+type Change struct {
+	Added   []string
+	Removed []string
+	// Moved 改变了首选节点的哈希空间占比 (0~1)，即预计需要迁移的键的比例
+	Moved float64
+}
+
+// Changed 返回节点集合是否发生了变化
+func (c Change) Changed() bool {
+	return len(c.Added) > 0 || len(c.Removed) > 0
+}
+
+// Set 把节点集合替换为 nodes（忽略空字符串和重复的节点），返回增减的节点和迁移的哈希空间占比
+func (r *Ring) Set(nodes ...string) Change {
+	next := normalize(nodes)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change := Change{Added: difference(next, r.nodes), Removed: difference(r.nodes, next)}
+	if !change.Changed() {
+		return change
+	}
+	before := r.snapshot()
+	r.rebuild(next)
+	change.Moved = moved(before, r.snapshot())
+	return change
+}
+
+// Add 向环中加入节点，已存在的节点忽略
+func (r *Ring) Add(nodes ...string) Change {
+	return r.Set(append(r.Nodes(), nodes...)...)
+}
+
+// Remove 从环中移除节点，不存在的节点忽略
+func (r *Ring) Remove(nodes ...string) Change {
+	current := r.Nodes()
+	return r.Set(slices.DeleteFunc(current, func(n string) bool { return slices.Contains(nodes, n) })...)
+}
+
+// Get 返回键的首选节点，环为空时返回 false
+func (r *Ring) Get(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.hashes) == 0 {
+		return "", false
+	}
+	return r.owners[r.hashes[r.search(hash(key))]], true
+}
+
+// GetN 按优先级返回键的前 n 个不同的节点，首选节点不可用时可以依次尝试后面的节点
+// 节点数不足 n 时返回全部节点
+func (r *Ring) GetN(key string, n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.hashes) == 0 || n <= 0 {
+		return nil
+	}
+	n = min(n, len(r.nodes))
+	out := make([]string, 0, n)
+	for i, start := 0, r.search(hash(key)); i < len(r.hashes) && len(out) < n; i++ {
+		node := r.owners[r.hashes[(start+i)%len(r.hashes)]]
+		if !slices.Contains(out, node) {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+// Nodes 返回环中的节点，按名称排序
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.nodes)
+}
+
+// Len 返回环中的节点数
+func (r *Ring) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.nodes)
+}
+
+// search 返回顺时针方向第一个不小于 h 的虚拟节点的下标，越过末尾时回到 0；调用方持有锁
+func (r *Ring) search(h uint64) int {
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		return 0
+	}
+	return i
+}
+
+// rebuild 以 nodes 重建环；调用方持有写锁
+// 不同节点的虚拟节点哈希冲突时归属名称较小的节点，保证所有网关节点构建出的环完全相同
+func (r *Ring) rebuild(nodes []string) {
+	r.nodes = nodes
+	r.hashes = r.hashes[:0]
+	r.owners = make(map[uint64]string, len(nodes)*r.replicas)
+	for _, node := range nodes {
+		for i := range r.replicas {
+			h := hash(node + "#" + strconv.Itoa(i))
+			if _, ok := r.owners[h]; ok {
+				continue
+			}
+			r.owners[h] = node
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	slices.Sort(r.hashes)
+}
+
+// segment 哈希空间中 (start, end] 区间归属的节点
+type segment struct {
+	end   uint64
+	owner string
+}
+
+// snapshot 返回当前环的区间划分；调用方持有锁
+func (r *Ring) snapshot() []segment {
+	segs := make([]segment, len(r.hashes))
+	for i, h := range r.hashes {
+		segs[i] = segment{end: h, owner: r.owners[h]}
+	}
+	return segs
+}
+
+// moved 计算两次划分之间改变了归属的哈希空间占比
+func moved(before, after []segment) float64 {
+	if len(before) == 0 || len(after) == 0 {
+		if len(before) == len(after) {
+			return 0
+		}
+		return 1
+	}
+	// 合并两次划分的区间端点，逐段比较归属
+	bounds := make([]uint64, 0, len(before)+len(after))
+	for _, s := range before {
+		bounds = append(bounds, s.end)
+	}
+	for _, s := range after {
+		bounds = append(bounds, s.end)
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+	var changed float64
+	prev := bounds[len(bounds)-1]
+	for _, end := range bounds {
+		// (prev, end] 区间内的键都归属 end 所在区间的节点，prev 为上一个端点，首段跨越环的起点
+		if owner(before, end) != owner(after, end) {
+			changed += float64(end - prev) // 无符号减法在首段自然回绕
+		}
+		prev = end
+	}
+	if len(bounds) == 1 && owner(before, bounds[0]) != owner(after, bounds[0]) {
+		return 1
+	}
+	return changed / (1 << 64)
+}
+
+// owner 返回哈希值 h 在划分 segs 中归属的节点
+func owner(segs []segment, h uint64) string {
+	i := sort.Search(len(segs), func(i int) bool { return segs[i].end >= h })
+	if i == len(segs) {
+		i = 0
+	}
+	return segs[i].owner
+}
+
+// hash 使用 xxhash，名称相近的虚拟节点 (node#0、node#1...) 也能均匀地分散在环上
+func hash(key string) uint64 {
+	return xxhash.Sum64String(key)
+}
+
+// normalize 去掉空字符串和重复的节点并排序
+func normalize(nodes []string) []string {
+	out := slices.DeleteFunc(slices.Clone(nodes), func(n string) bool { return n == "" })
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// difference 返回在 a 中而不在 b 中的节点，a、b 均已排序
+func difference(a, b []string) []string {
+	var out []string
+	for _, n := range a {
+		if _, ok := slices.BinarySearch(b, n); !ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// tokenHash 令牌的SHA-256摘要，作为缓存键，避免在内存中长期保存令牌原文
+type tokenHash [sha256.Size]byte
+
+type decisionEntry struct {
+	claims    UserClaims
+	expiresAt time.Time
+}
+
+// DecisionCache 认证结果缓存
+// 只缓存验证成功的结果，键为令牌摘要，有效期取配置TTL和令牌剩余有效期中的较小值。
+// 连接风暴时同一个令牌往往会被反复验证（客户端快速重试），短TTL缓存可以显著减少验签开销。
+type DecisionCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[tokenHash]decisionEntry
+}
+
+// NewDecisionCache 创建认证结果缓存，ttl<=0 时返回 nil 表示禁用缓存
+func NewDecisionCache(ttl time.Duration, maxEntries int) *DecisionCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &DecisionCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[tokenHash]decisionEntry),
+	}
+}
+
+// Get 查找令牌对应的认证结果，未命中或已过期时返回 false
+func (c *DecisionCache) Get(tokenString string) (UserClaims, bool) {
+	if c == nil {
+		return UserClaims{}, false
+	}
+	h := sha256.Sum256([]byte(tokenString))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[h]
+	if !ok {
+		return UserClaims{}, false
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(c.entries, h)
+		return UserClaims{}, false
+	}
+	return e.claims, true
+}
+
+// Put 缓存一次验证成功的结果
+func (c *DecisionCache) Put(tokenString string, claims UserClaims) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	expiresAt := now.Add(c.ttl)
+	// 不能让缓存结果比令牌本身活得更久
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if !expiresAt.After(now) {
+		return
+	}
+
+	h := sha256.Sum256([]byte(tokenString))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		c.evictExpiredLocked(now)
+		if len(c.entries) >= c.maxEntries {
+			// 缓存已满且没有可淘汰的过期项，放弃缓存本次结果，保证内存有界
+			return
+		}
+	}
+	c.entries[h] = decisionEntry{claims: claims, expiresAt: expiresAt}
+}
+
+// Invalidate 使指定令牌的缓存结果失效
+func (c *DecisionCache) Invalidate(tokenString string) {
+	if c == nil {
+		return
+	}
+	h := sha256.Sum256([]byte(tokenString))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, h)
+}
+
+// InvalidateFunc 使所有满足条件的缓存结果失效，返回失效的条目数
+// 用于响应吊销事件，例如吊销某个用户在某时间点之前签发的全部令牌
+func (c *DecisionCache) InvalidateFunc(match func(UserClaims) bool) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for h, e := range c.entries {
+		if match(e.claims) {
+			delete(c.entries, h)
+			n++
+		}
+	}
+	return n
+}
+
+// Purge 清空所有缓存结果
+func (c *DecisionCache) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+func (c *DecisionCache) evictExpiredLocked(now time.Time) {
+	for h, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, h)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// defaultJWKSRefreshInterval 未配置时定期刷新 JWKS 的间隔
+	defaultJWKSRefreshInterval = 10 * time.Minute
+	// defaultJWKSTimeout 未配置时请求 JWKS 的超时时间
+	defaultJWKSTimeout = 5 * time.Second
+	// minJWKSRefreshInterval 遇到未知 kid 时提前刷新的最小间隔，防止伪造 kid 的令牌把请求放大到身份提供方
+	minJWKSRefreshInterval = 10 * time.Second
+	// maxJWKSSize JWKS 响应的最大字节数
+	maxJWKSSize = 1 << 20
+)
+
+var ErrFetchJWKS = errors.New("获取JWKS失败")
+
+// jwk JWKS 中的一个公钥，只解析验证签名需要的字段
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwks 从身份提供方的 JWKS 地址获取公钥并缓存
+// 后台定期刷新以跟上身份提供方的密钥轮换，遇到未知 kid 时限频地提前刷新，刷新失败时继续使用上一次获取的公钥
+type jwks struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+
+	mu          sync.RWMutex
+	keys        []publicKey
+	lastErr     error
+	lastAttempt time.Time
+
+	refreshMu sync.Mutex // 串行化刷新，并发遇到未知 kid 时只请求一次
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func newJWKS(cfg config.JWKSConfig) *jwks {
+	j := &jwks{
+		url:      cfg.URL,
+		interval: time.Duration(cfg.RefreshInterval),
+		client:   &http.Client{Timeout: time.Duration(cfg.Timeout)},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if j.interval <= 0 {
+		j.interval = defaultJWKSRefreshInterval
+	}
+	if j.client.Timeout <= 0 {
+		j.client.Timeout = defaultJWKSTimeout
+	}
+	return j
+}
+
+// start 获取一次公钥并在后台定期刷新
+// 首次获取失败不影响启动，验证令牌时会重试
+func (j *jwks) start() {
+	_ = j.refresh()
+	go j.run()
+}
+
+func (j *jwks) run() {
+	defer close(j.done)
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+			_ = j.refresh()
+		}
+	}
+}
+
+// close 停止后台刷新
+func (j *jwks) close() {
+	j.stopOnce.Do(func() { close(j.stop) })
+	<-j.done
+}
+
+// warmUp 还没有获取到公钥时刷新一次，返回缓存的公钥数
+func (j *jwks) warmUp() (int, error) {
+	j.mu.RLock()
+	n := len(j.keys)
+	j.mu.RUnlock()
+	if n > 0 {
+		return n, nil
+	}
+	if err := j.refresh(); err != nil {
+		return 0, err
+	}
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return len(j.keys), nil
+}
+
+// key 查找能验证令牌的公钥，找不到时限频刷新一次后再查找
+func (j *jwks) key(kid string, method jwt.SigningMethod) (any, error) {
+	j.mu.RLock()
+	keys, lastAttempt := j.keys, j.lastAttempt
+	j.mu.RUnlock()
+	key, err := findKey(keys, kid, method)
+	if !errors.Is(err, ErrUnknownKey) || time.Since(lastAttempt) < minJWKSRefreshInterval {
+		return key, j.withLastErr(err)
+	}
+	_ = j.refresh()
+	j.mu.RLock()
+	keys = j.keys
+	j.mu.RUnlock()
+	key, err = findKey(keys, kid, method)
+	return key, j.withLastErr(err)
+}
+
+// withLastErr 查找公钥失败时附上最近一次刷新的错误，便于排查身份提供方不可用的问题
+func (j *jwks) withLastErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.lastErr != nil {
+		return fmt.Errorf("%w (%w)", err, j.lastErr)
+	}
+	return err
+}
+
+// refresh 请求 JWKS 并替换缓存的公钥，失败时保留原有公钥
+func (j *jwks) refresh() error {
+	j.refreshMu.Lock()
+	defer j.refreshMu.Unlock()
+	keys, err := j.fetch()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.lastAttempt = time.Now()
+	j.lastErr = err
+	if err == nil {
+		j.keys = keys
+	}
+	return err
+}
+
+func (j *jwks) fetch() ([]publicKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), j.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchJWKS, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchJWKS, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HTTP %d", ErrFetchJWKS, resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchJWKS, err)
+	}
+
+	keys := make([]publicKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// 身份提供方可能发布网关不支持的密钥类型，跳过而不是让整个 JWKS 失效
+			continue
+		}
+		keys = append(keys, publicKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	return keys, nil
+}
+
+// publicKey 把 JWK 转换为公钥
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 || exp.Int64() < 3 {
+			return nil, fmt.Errorf("%w: RSA 指数无效", ErrInvalidKey)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: 不支持的曲线 %s", ErrInvalidKey, k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, fmt.Errorf("%w: EC 坐标长度无效", ErrInvalidKey)
+		}
+		// 未压缩点格式 0x04 || X || Y，坐标按曲线长度左侧补零
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	default:
+		return nil, fmt.Errorf("%w: 不支持的密钥类型 %s", ErrInvalidKey, k.Kty)
+	}
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	return b, nil
+}
@@ -21,17 +21,47 @@ var (
 type MapClaims jwt.MapClaims
 
 // Token JWT令牌处理器，封装了JWT的编码和解码功能
+// 签发只支持 HMAC；验证支持 HMAC 以及配置的 RSA/ECDSA 公钥和 JWKS，
+// 后者用于验证外部身份提供方签发的令牌，网关不需要与其共享密钥
 type Token struct {
-	key    string // JWT 密钥，生成和验证 JWT Token 签名时使用
-	issuer string // JWT 令牌的签发者，通常是应用服务名
+	key        string      // JWT 密钥，生成和验证 HMAC 签名时使用，为空时不接受 HMAC 签名的令牌
+	issuer     string      // JWT 令牌的签发者，通常是应用服务名
+	publicKeys []publicKey // 配置的非对称签名公钥
+	jwks       *jwks       // 未配置 JWKS 地址时为 nil
 }
 
 func NewToken(i do.Injector) (*Token, error) {
 	jwtConfig := do.MustInvoke[config.JWTConfig](i)
-	return &Token{
-		key:    jwtConfig.Key,
-		issuer: jwtConfig.Issuer,
-	}, nil
+	publicKeys, err := loadPublicKeys(jwtConfig.PublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	t := &Token{
+		key:        jwtConfig.Key,
+		issuer:     jwtConfig.Issuer,
+		publicKeys: publicKeys,
+	}
+	if jwtConfig.JWKS.URL != "" {
+		t.jwks = newJWKS(jwtConfig.JWKS)
+		t.jwks.start()
+	}
+	return t, nil
+}
+
+// Shutdown 停止后台刷新 JWKS
+func (t *Token) Shutdown() {
+	if t.jwks != nil {
+		t.jwks.close()
+	}
+}
+
+// WarmUp 确保已经获取到 JWKS 公钥，返回缓存的公钥数；未配置 JWKS 时不做任何事
+// NewToken 只尝试获取一次，启动预热时反复调用直到身份提供方可用，首批连接不再逐个触发刷新
+func (t *Token) WarmUp() (int, error) {
+	if t.jwks == nil {
+		return 0, nil
+	}
+	return t.jwks.warmUp()
 }
 
 // Encode 生成 JWT Token，支持自定义声明和自动添加标准声明
@@ -63,13 +93,8 @@ func (t *Token) Decode(tokenString string) (MapClaims, error) {
 	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 
 	// 解析 JWT 令牌
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		// 验证签名算法是否为 HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("%w: %v", ErrSupportedSignAlgorithm, token.Header["alg"])
-		}
-		return []byte(t.key), nil
-	})
+	// 数值声明按 json.Number 解析，保留大整数 ID 的精度
+	token, err := jwt.Parse(tokenString, t.keyFunc, jwt.WithJSONNumber())
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrDecodeJWTTokenFailed, err)
 	}
@@ -79,3 +104,24 @@ func (t *Token) Decode(tokenString string) (MapClaims, error) {
 	}
 	return nil, fmt.Errorf("%w", ErrInvalidJWTToken)
 }
+
+// keyFunc 按令牌的签名算法选择验证密钥
+// 按算法族区分密钥类型，HMAC 令牌只用共享密钥验证，防止把公钥当作 HMAC 密钥的算法混淆攻击
+func (t *Token) keyFunc(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if t.key == "" {
+			return nil, fmt.Errorf("%w: %v", ErrSupportedSignAlgorithm, token.Header["alg"])
+		}
+		return []byte(t.key), nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		kid, _ := token.Header["kid"].(string)
+		key, err := findKey(t.publicKeys, kid, token.Method)
+		if t.jwks == nil || !errors.Is(err, ErrUnknownKey) {
+			return key, err
+		}
+		return t.jwks.key(kid, token.Method)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrSupportedSignAlgorithm, token.Header["alg"])
+	}
+}
@@ -0,0 +1,111 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey     = errors.New("找不到验证令牌的公钥")
+	ErrInvalidKey     = errors.New("无效的公钥配置")
+	ErrKeyAlgMismatch = errors.New("令牌的签名算法与公钥不匹配")
+)
+
+// publicKey 一个验证非对称签名的公钥
+type publicKey struct {
+	kid string
+	alg string // 为空时接受与密钥类型匹配的任意算法
+	key any    // *rsa.PublicKey 或 *ecdsa.PublicKey
+}
+
+// accepts 返回公钥能否验证使用 method 签名的令牌
+// 同时校验密钥类型，防止用 RSA 公钥验证伪造的 ECDSA 签名之类的算法混淆
+func (k publicKey) accepts(method jwt.SigningMethod) bool {
+	if k.alg != "" && k.alg != method.Alg() {
+		return false
+	}
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := k.key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		_, ok := k.key.(*ecdsa.PublicKey)
+		return ok
+	default:
+		return false
+	}
+}
+
+// loadPublicKeys 加载配置中的公钥
+func loadPublicKeys(cfgs []config.JWTPublicKeyConfig) ([]publicKey, error) {
+	keys := make([]publicKey, 0, len(cfgs))
+	for _, c := range cfgs {
+		data := []byte(c.PEM)
+		if c.File != "" {
+			b, err := os.ReadFile(c.File)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 读取 %s 失败: %w", ErrInvalidKey, c.File, err)
+			}
+			data = b
+		}
+		key, err := parsePublicKeyPEM(c.Algorithm, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: kid=%q: %w", ErrInvalidKey, c.KID, err)
+		}
+		keys = append(keys, publicKey{kid: c.KID, alg: c.Algorithm, key: key})
+	}
+	return keys, nil
+}
+
+// parsePublicKeyPEM 按算法解析PEM格式的公钥，未指定算法时依次尝试 RSA 和 ECDSA
+func parsePublicKeyPEM(alg string, data []byte) (any, error) {
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		return jwt.ParseRSAPublicKeyFromPEM(data)
+	case strings.HasPrefix(alg, "ES"):
+		return jwt.ParseECPublicKeyFromPEM(data)
+	case alg == "":
+		if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+			return key, nil
+		}
+		return jwt.ParseECPublicKeyFromPEM(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrSupportedSignAlgorithm, alg)
+	}
+}
+
+// findKey 在公钥列表中查找能验证令牌的公钥
+// 令牌带 kid 时按 kid 精确匹配；不带 kid 时只有唯一一个公钥匹配签名算法才使用，避免歧义
+func findKey(keys []publicKey, kid string, method jwt.SigningMethod) (any, error) {
+	var found *publicKey
+	for i := range keys {
+		k := &keys[i]
+		if kid != "" {
+			if k.kid != kid {
+				continue
+			}
+			if !k.accepts(method) {
+				return nil, fmt.Errorf("%w: kid=%q alg=%s", ErrKeyAlgMismatch, kid, method.Alg())
+			}
+			return k.key, nil
+		}
+		if !k.accepts(method) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: 令牌未指定 kid 且有多个 %s 公钥", ErrUnknownKey, method.Alg())
+		}
+		found = k
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: kid=%q alg=%s", ErrUnknownKey, kid, method.Alg())
+	}
+	return found.key, nil
+}
@@ -1,21 +1,26 @@
 package jwt
 
 import (
+	"encoding/json"
+	"math"
 	"time"
 
+	"github.com/YaoAzure/wsgateway/pkg/config"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/samber/do/v2"
 )
 
 // UserClaims 用户JWT声明结构体，包含用户特定的业务信息
 type UserClaims struct {
-	UserID               int64 // 用户ID，唯一标识用户身份
-	BizID                int64 // 业务ID，标识用户所属的业务域或租户
-	jwt.RegisteredClaims       // 嵌入标准JWT声明（iat、exp、iss等）
+	UserID               int64  // 用户ID，唯一标识用户身份
+	BizID                int64  // 业务ID，标识用户所属的业务域或租户
+	Fingerprint          string // 设备指纹，可选，由签发令牌的认证服务写入时优先于客户端上报的指纹
+	jwt.RegisteredClaims        // 嵌入标准JWT声明（iat、exp、iss等）
 }
 
 type UserToken struct {
 	token *Token
+	cache *DecisionCache // 认证结果缓存，为 nil 时不缓存
 }
 
 func NewUserToken(i do.Injector) (*UserToken, error) {
@@ -23,11 +28,22 @@ func NewUserToken(i do.Injector) (*UserToken, error) {
 	if err != nil {
 		return nil, err
 	}
+	jwtConfig, err := do.Invoke[config.JWTConfig](i)
+	if err != nil {
+		return nil, err
+	}
 	return &UserToken{
 		token: token,
+		cache: NewDecisionCache(time.Duration(jwtConfig.DecisionCacheTTL), jwtConfig.DecisionCacheSize),
 	}, nil
 }
 
+// Cache 返回认证结果缓存，用于在令牌吊销时使缓存失效；未启用缓存时返回 nil
+// DecisionCache 的方法都可以在 nil 接收者上安全调用
+func (t *UserToken) Cache() *DecisionCache {
+	return t.cache
+}
+
 // Encode 生成用户JWT令牌，支持自定义声明和自动添加标准声明
 // uc: 包含用户信息的声明结构体
 func (t *UserToken) Encode(uc UserClaims) (string, error) {
@@ -42,9 +58,15 @@ func (t *UserToken) Encode(uc UserClaims) (string, error) {
 	if uc.ExpiresAt != nil {
 		claims["exp"] = uc.ExpiresAt.Unix()
 	}
+	if uc.Fingerprint != "" {
+		claims["fingerprint"] = uc.Fingerprint
+	}
 	if uc.Issuer != "" {
 		claims["iss"] = uc.Issuer
 	}
+	if uc.ID != "" {
+		claims["jti"] = uc.ID
+	}
 
 	// 自动处理过期时间
 	const day = 24 * time.Hour
@@ -54,27 +76,77 @@ func (t *UserToken) Encode(uc UserClaims) (string, error) {
 	return t.token.Encode(claims)
 }
 
+// Decode 解码并验证用户JWT令牌
+// 启用认证结果缓存时，命中缓存的令牌不再重复验签
 func (t *UserToken) Decode(tokenString string) (UserClaims, error) {
+	if claims, ok := t.cache.Get(tokenString); ok {
+		return claims, nil
+	}
+	claims, err := t.decode(tokenString)
+	if err != nil {
+		return UserClaims{}, err
+	}
+	t.cache.Put(tokenString, claims)
+	return claims, nil
+}
+
+func (t *UserToken) decode(tokenString string) (UserClaims, error) {
 	mapClaims, err := t.token.Decode(tokenString)
 	if err != nil {
 		return UserClaims{}, err
 	}
+	return claimsFromMap(mapClaims), nil
+}
+
+// claimsFromMap 把令牌中的声明映射为 UserClaims，类型不符或超出范围的声明视为未设置
+func claimsFromMap(mapClaims MapClaims) UserClaims {
 	claims := UserClaims{}
-	if id, ok := mapClaims["user_id"].(float64); ok {
-		claims.UserID = int64(id)
+	if id, ok := int64Claim(mapClaims["user_id"]); ok {
+		claims.UserID = id
+	}
+	if bizID, ok := int64Claim(mapClaims["biz_id"]); ok {
+		claims.BizID = bizID
 	}
-	if bizID, ok := mapClaims["biz_id"].(float64); ok {
-		claims.BizID = int64(bizID)
+	if fingerprint, ok := mapClaims["fingerprint"].(string); ok {
+		claims.Fingerprint = fingerprint
 	}
 	// 处理标准声明
-	if iat, ok := mapClaims["iat"].(float64); ok {
-		claims.IssuedAt = jwt.NewNumericDate(time.Unix(int64(iat), 0))
+	if iat, ok := int64Claim(mapClaims["iat"]); ok {
+		claims.IssuedAt = jwt.NewNumericDate(time.Unix(iat, 0))
 	}
-	if exp, ok := mapClaims["exp"].(float64); ok {
-		claims.ExpiresAt = jwt.NewNumericDate(time.Unix(int64(exp), 0))
+	if exp, ok := int64Claim(mapClaims["exp"]); ok {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Unix(exp, 0))
 	}
 	if iss, ok := mapClaims["iss"].(string); ok {
 		claims.Issuer = iss
 	}
-	return claims, nil
+	if jti, ok := mapClaims["jti"].(string); ok {
+		claims.ID = jti
+	}
+	return claims
+}
+
+// int64Claim 读取整数声明
+// 令牌按 json.Number 解析，超过 2^53 的 ID 不会因为转成 float64 丢失精度而映射到其他用户；
+// 带小数的数值截断为整数，超出 int64 范围的数值视为无效
+func int64Claim(v any) (int64, bool) {
+	var f float64
+	switch n := v.(type) {
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		var err error
+		if f, err = n.Float64(); err != nil {
+			return 0, false
+		}
+	case float64:
+		f = n
+	default:
+		return 0, false
+	}
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
 }
@@ -0,0 +1,286 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// Key 保存所有键值的Redis哈希
+	Key = "gateway:kv"
+	// Channel 广播键值变更的Redis Pub/Sub频道
+	Channel = "gateway:kv:changes"
+
+	// resyncInterval 全量比对本地缓存与Redis的间隔，补上订阅断线期间丢失的变更
+	resyncInterval = 30 * time.Second
+)
+
+// ErrNotFound 键不存在
+var ErrNotFound = errors.New("键不存在")
+
+// Event 一次键值变更
+type Event struct {
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// WatchFunc 处理键值变更的回调，同一个 Watch 的回调按变更顺序串行调用，回调中不能阻塞太久
+type WatchFunc func(e Event)
+
+type watch struct {
+	prefix string
+	fn     WatchFunc
+	mu     sync.Mutex // 保证注册时的初始值先于之后的变更送达
+}
+
+// Store 网关节点之间共享的键值存储，用于摘流标记、功能开关、业务方配置失效通知等集群范围的协调
+//
+// 键值保存在一个Redis哈希中，写入和变更广播在同一个事务中执行；每个节点订阅变更频道，
+// 在本地维护完整的缓存并回调匹配的 Watch。Pub/Sub 断线期间丢失的变更由定期全量比对补上，
+// 因此 Watch 看到的是最终一致的状态，适合传递少量低频变更的标记和开关，不适合作为消息队列。
+//
+// 键建议以 "模块/名称" 的形式分层，例如 "drain/gw-1"、"feature/rooms"，Watch 按前缀匹配
+type Store struct {
+	rdb    redis.UniversalClient
+	logger *log.Logger
+
+	mu      sync.RWMutex
+	values  map[string]string
+	watches map[*watch]struct{}
+	loaded  bool
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func NewStore(i do.Injector) (*Store, error) {
+	rdb, err := do.Invoke[redis.UniversalClient](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		rdb:     rdb,
+		logger:  logger,
+		values:  make(map[string]string),
+		watches: make(map[*watch]struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Get 从Redis读取键的当前值，键不存在时返回 ErrNotFound
+func (s *Store) Get(ctx context.Context, key string) (string, error) {
+	v, err := s.rdb.HGet(ctx, Key, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return v, err
+}
+
+// Cached 返回本地缓存中键的值，不访问Redis，适合在热路径上读取开关；Start 之前始终返回 false
+func (s *Store) Cached(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// List 返回本地缓存中以 prefix 开头的所有键值
+func (s *Store) List(prefix string) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make(map[string]string)
+	for k, v := range s.values {
+		if strings.HasPrefix(k, prefix) {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// Set 写入键值并通知所有节点
+func (s *Store) Set(ctx context.Context, key, value string) error {
+	return s.publish(ctx, Event{Key: key, Value: value}, func(pipe redis.Pipeliner) {
+		pipe.HSet(ctx, Key, key, value)
+	})
+}
+
+// Delete 删除键并通知所有节点，键不存在时同样成功
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.publish(ctx, Event{Key: key, Deleted: true}, func(pipe redis.Pipeliner) {
+		pipe.HDel(ctx, Key, key)
+	})
+}
+
+func (s *Store) publish(ctx context.Context, e Event, write func(pipe redis.Pipeliner)) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		write(pipe)
+		pipe.Publish(ctx, Channel, payload)
+		return nil
+	})
+	return err
+}
+
+// Watch 订阅以 prefix 开头的键的变更，prefix 为空时订阅所有键，返回取消订阅的函数
+// 已经启动时先在调用方协程中以当前值回调一次匹配的键，之后的变更在后台协程中回调；
+// 在 Start 之前注册的 Watch 在启动加载时收到初始值
+func (s *Store) Watch(prefix string, fn WatchFunc) (cancel func()) {
+	w := &watch{prefix: prefix, fn: fn}
+	w.mu.Lock()
+	s.mu.Lock()
+	s.watches[w] = struct{}{}
+	var initial []Event
+	if s.loaded {
+		for k, v := range s.values {
+			if strings.HasPrefix(k, prefix) {
+				initial = append(initial, Event{Key: k, Value: v})
+			}
+		}
+	}
+	s.mu.Unlock()
+	for _, e := range initial {
+		fn(e)
+	}
+	w.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		delete(s.watches, w)
+		s.mu.Unlock()
+	}
+}
+
+// Start 订阅变更频道、加载所有键值并在后台保持同步；重复调用无效
+// 订阅确认或首次加载失败时返回错误
+func (s *Store) Start() error {
+	var err error
+	s.startOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		pubsub := s.rdb.Subscribe(ctx, Channel)
+		// 先订阅再加载，加载期间发生的变更不会丢失
+		if _, err = pubsub.Receive(ctx); err == nil {
+			err = s.resync(ctx)
+		}
+		if err != nil {
+			cancel()
+			_ = pubsub.Close()
+			close(s.done)
+			return
+		}
+		s.cancel = cancel
+		go s.run(ctx, pubsub)
+	})
+	return err
+}
+
+// Shutdown 取消订阅并等待后台协程退出
+func (s *Store) Shutdown() {
+	s.stopOnce.Do(func() {
+		if s.cancel != nil {
+			s.cancel()
+		}
+	})
+	// 未启动时 done 不会被关闭，这里不能等待
+	s.startOnce.Do(func() { close(s.done) })
+	<-s.done
+}
+
+func (s *Store) run(ctx context.Context, pubsub *redis.PubSub) {
+	defer close(s.done)
+	defer pubsub.Close()
+	ticker := time.NewTicker(resyncInterval)
+	defer ticker.Stop()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.resync(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Warn("同步集群键值失败", slog.Any("error", err))
+			}
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(m.Payload), &e); err != nil {
+				s.logger.Warn("无法解析键值变更", slog.String("payload", m.Payload), slog.Any("error", err))
+				continue
+			}
+			s.apply([]Event{e})
+		}
+	}
+}
+
+// resync 从Redis加载所有键值，与本地缓存不一致的键作为变更回调
+func (s *Store) resync(ctx context.Context) error {
+	values, err := s.rdb.HGetAll(ctx, Key).Result()
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	var events []Event
+	for k, v := range values {
+		if old, ok := s.values[k]; !ok || old != v {
+			events = append(events, Event{Key: k, Value: v})
+		}
+	}
+	for k := range s.values {
+		if _, ok := values[k]; !ok {
+			events = append(events, Event{Key: k, Deleted: true})
+		}
+	}
+	s.mu.RUnlock()
+	s.apply(events)
+	s.mu.Lock()
+	s.loaded = true
+	s.mu.Unlock()
+	return nil
+}
+
+// apply 更新本地缓存并回调匹配的 Watch，值没有变化的变更不回调
+func (s *Store) apply(events []Event) {
+	for _, e := range events {
+		s.mu.Lock()
+		old, existed := s.values[e.Key]
+		if e.Deleted {
+			delete(s.values, e.Key)
+		} else {
+			s.values[e.Key] = e.Value
+		}
+		changed := existed != !e.Deleted || old != e.Value
+		var matched []*watch
+		if changed {
+			for w := range s.watches {
+				if strings.HasPrefix(e.Key, w.prefix) {
+					matched = append(matched, w)
+				}
+			}
+		}
+		s.mu.Unlock()
+		for _, w := range matched {
+			w.mu.Lock()
+			w.fn(e)
+			w.mu.Unlock()
+		}
+	}
+}
@@ -12,29 +12,60 @@ import (
 
 type Logger = slog.Logger
 
+// Level 可在运行时调整的日志级别，所有日志（包括业务方专属日志）共享同一个级别
+type Level = slog.LevelVar
+
 var Package = do.Package(
+	do.Lazy(NewLevel),
+	do.Lazy(NewRedactor),
 	do.Lazy(NewLogger),
 )
 
-func NewLogger(i do.Injector) (*Logger, error) {
+// NewLevel 按配置创建日志级别，未配置或无法识别时为 info
+func NewLevel(i do.Injector) (*Level, error) {
 	logConfig, err := do.Invoke[config.LogConfig](i)
 	if err != nil {
 		return nil, err
 	}
+	level := new(Level)
+	if l, ok := ParseLevel(logConfig.Level); ok {
+		level.Set(l)
+	}
+	return level, nil
+}
 
-	// 1. 设置日志级别
-	var level slog.Level
-	switch logConfig.Level {
+// ParseLevel 解析配置中的日志级别名称：debug、info、warn、error
+func ParseLevel(s string) (slog.Level, bool) {
+	switch s {
 	case "debug":
-		level = slog.LevelDebug
+		return slog.LevelDebug, true
 	case "info":
-		level = slog.LevelInfo
+		return slog.LevelInfo, true
 	case "warn":
-		level = slog.LevelWarn
+		return slog.LevelWarn, true
 	case "error":
-		level = slog.LevelError
+		return slog.LevelError, true
 	default:
-		level = slog.LevelInfo
+		return slog.LevelInfo, false
+	}
+}
+
+func NewLogger(i do.Injector) (*Logger, error) {
+	logConfig, err := do.Invoke[config.LogConfig](i)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 设置日志级别，可以通过管理API在运行时调整
+	level, err := do.Invoke[*Level](i)
+	if err != nil {
+		return nil, err
+	}
+
+	// 敏感内容在写出前脱敏，规则可以通过管理API在运行时调整
+	redactor, err := do.Invoke[*Redactor](i)
+	if err != nil {
+		return nil, err
 	}
 
 	// 2. 设置输出位置 (Writer)
@@ -60,15 +91,20 @@ func NewLogger(i do.Injector) (*Logger, error) {
 
 	// 3. 创建 Handler
 	handlerOpts := &slog.HandlerOptions{
-		AddSource: logConfig.ShowCaller,
-		Level:     level,
+		AddSource:   logConfig.ShowCaller,
+		Level:       level,
+		ReplaceAttr: redactor.ReplaceAttr,
 	}
 
-	var handler slog.Handler
-	if logConfig.Format == "json" {
-		handler = slog.NewJSONHandler(writer, handlerOpts)
-	} else {
-		handler = slog.NewTextHandler(writer, handlerOpts)
+	newHandler := func(w io.Writer) slog.Handler {
+		if logConfig.Format == "json" {
+			return slog.NewJSONHandler(w, handlerOpts)
+		}
+		return slog.NewTextHandler(w, handlerOpts)
+	}
+	handler, err := newTenantHandler(newHandler(writer), logConfig, newHandler)
+	if err != nil {
+		return nil, err
 	}
 
 	// 4. 添加全局字段
@@ -0,0 +1,248 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// DefaultMask 未配置时替换敏感内容的字符串
+const DefaultMask = "***"
+
+var ErrInvalidRedactionRule = errors.New("无效的日志脱敏规则")
+
+// Redactor 日志脱敏，在日志写出之前遮盖敏感内容，规则可以在运行时替换
+//
+// 作为所有日志 Handler（包括业务方专属日志）的 ReplaceAttr 生效，按以下规则处理每个字段：
+//   - headers：字段名（不区分大小写）匹配的值整体遮盖，Authorization 保留认证方案（Bearer ***），
+//     Cookie 和 Set-Cookie 保留 Cookie 名只遮盖值
+//   - query_params：字符串值（以及 error 的错误信息）中 URI 查询参数的值被遮盖，例如 /?token=*** 中的 JWT
+//   - fields：值为 JSON 对象的字符串或字节切片中按路径匹配的字段被遮盖，例如 user.phone
+//
+// 规则替换只影响之后写出的日志，通过 Logger.With 预先绑定的字段在绑定时已经处理过
+type Redactor struct {
+	rules atomic.Pointer[redactRules]
+}
+
+// redactRules 编译后的脱敏规则，替换时整体更换，不会被修改
+type redactRules struct {
+	cfg         config.LogRedactionConfig
+	mask        string
+	queryParams []string
+	headers     []string
+	fields      [][]string
+}
+
+// NewRedactor 按配置创建日志脱敏
+func NewRedactor(i do.Injector) (*Redactor, error) {
+	logConfig, err := do.Invoke[config.LogConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	r := &Redactor{}
+	if err := r.Set(logConfig.Redaction); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Rules 返回当前生效的规则
+func (r *Redactor) Rules() config.LogRedactionConfig {
+	return r.rules.Load().cfg
+}
+
+// Set 替换脱敏规则，规则无效时返回 ErrInvalidRedactionRule 并保留原规则
+func (r *Redactor) Set(cfg config.LogRedactionConfig) error {
+	rules := &redactRules{cfg: cfg, mask: cfg.Mask}
+	if rules.mask == "" {
+		rules.mask = DefaultMask
+	}
+	for _, p := range cfg.QueryParams {
+		if p == "" || strings.ContainsAny(p, "=&?#") {
+			return fmt.Errorf("%w: query_params 中的参数名无效: %q", ErrInvalidRedactionRule, p)
+		}
+		rules.queryParams = append(rules.queryParams, p)
+	}
+	for _, h := range cfg.Headers {
+		if h == "" {
+			return fmt.Errorf("%w: headers 中不能有空字段名", ErrInvalidRedactionRule)
+		}
+		rules.headers = append(rules.headers, h)
+	}
+	for _, f := range cfg.Fields {
+		path := strings.Split(f, ".")
+		for _, name := range path {
+			if name == "" {
+				return fmt.Errorf("%w: fields 中的路径无效: %q", ErrInvalidRedactionRule, f)
+			}
+		}
+		rules.fields = append(rules.fields, path)
+	}
+	r.rules.Store(rules)
+	return nil
+}
+
+// ReplaceAttr 实现 slog.HandlerOptions.ReplaceAttr
+func (r *Redactor) ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	rules := r.rules.Load()
+	if rules.empty() {
+		return a
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		if s, ok := rules.redactString(a.Key, v.String()); ok {
+			return slog.String(a.Key, s)
+		}
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case []byte:
+			if b, ok := rules.redactJSON(x); ok {
+				return slog.String(a.Key, string(b))
+			}
+		case error:
+			if s, ok := rules.redactString(a.Key, x.Error()); ok {
+				return slog.String(a.Key, s)
+			}
+		}
+	}
+	return a
+}
+
+func (rules *redactRules) empty() bool {
+	return len(rules.queryParams) == 0 && len(rules.headers) == 0 && len(rules.fields) == 0
+}
+
+// redactString 按字段名和内容遮盖字符串值，没有需要遮盖的内容时返回 false
+func (rules *redactRules) redactString(key, s string) (string, bool) {
+	for _, h := range rules.headers {
+		if strings.EqualFold(key, h) {
+			return rules.redactHeader(key, s), true
+		}
+	}
+	b, changed := rules.redactJSON([]byte(s))
+	if changed {
+		s = string(b)
+	}
+	if q, ok := rules.redactQuery(s); ok {
+		return q, true
+	}
+	return s, changed
+}
+
+// redactHeader 遮盖请求头的值，保留认证方案和 Cookie 名，便于排查问题
+func (rules *redactRules) redactHeader(key, value string) string {
+	if value == "" {
+		return value
+	}
+	switch {
+	case strings.EqualFold(key, "cookie"), strings.EqualFold(key, "set-cookie"):
+		parts := strings.Split(value, ";")
+		for i, part := range parts {
+			if name, _, ok := strings.Cut(part, "="); ok {
+				parts[i] = name + "=" + rules.mask
+			}
+		}
+		return strings.Join(parts, ";")
+	default:
+		if scheme, _, ok := strings.Cut(value, " "); ok && !strings.ContainsAny(scheme, "=,") {
+			return scheme + " " + rules.mask
+		}
+		return rules.mask
+	}
+}
+
+// redactQuery 遮盖字符串中所有配置的查询参数的值，参数以 ? 或 & 开头、以 &、#、空白或字符串结尾结束
+func (rules *redactRules) redactQuery(s string) (string, bool) {
+	if len(rules.queryParams) == 0 || !strings.Contains(s, "=") {
+		return s, false
+	}
+	var b strings.Builder
+	last, changed := 0, false
+	for i := 0; i < len(s); i++ {
+		if s[i] != '?' && s[i] != '&' {
+			continue
+		}
+		name, rest, ok := strings.Cut(s[i+1:], "=")
+		if !ok || !slices.Contains(rules.queryParams, name) {
+			continue
+		}
+		start := i + 1 + len(name) + 1
+		end := start + strings.IndexFunc(rest, func(r rune) bool {
+			return r == '&' || r == '#' || r == ' ' || r == '\t' || r == '\n' || r == '"'
+		})
+		if end < start {
+			end = len(s)
+		}
+		if end == start {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(rules.mask)
+		last, changed = end, true
+		i = end - 1
+	}
+	if !changed {
+		return s, false
+	}
+	b.WriteString(s[last:])
+	return b.String(), true
+}
+
+// redactJSON 遮盖 JSON 对象中按路径匹配的字段，不是 JSON 对象或没有匹配的字段时返回 false
+func (rules *redactRules) redactJSON(body []byte) ([]byte, bool) {
+	if len(rules.fields) == 0 {
+		return nil, false
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '{' {
+		return nil, false
+	}
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, false
+	}
+	changed := false
+	for _, path := range rules.fields {
+		if mask(obj, path, rules.mask) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(obj); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// mask 把 obj 中 path 指向的字段替换为 m，返回字段是否存在
+func mask(obj map[string]any, path []string, m string) bool {
+	for _, name := range path[:len(path)-1] {
+		next, ok := obj[name].(map[string]any)
+		if !ok {
+			return false
+		}
+		obj = next
+	}
+	last := path[len(path)-1]
+	if _, ok := obj[last]; !ok {
+		return false
+	}
+	obj[last] = m
+	return true
+}
@@ -0,0 +1,153 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// TenantKey 日志中标识业务方的字段名
+const TenantKey = "bizId"
+
+// 业务方日志的路由模式
+const (
+	TenantModeDuplicate = "duplicate" // 同时写入全局日志和业务方日志
+	TenantModeRedirect  = "redirect"  // 只写入业务方日志
+)
+
+type tenantSink struct {
+	handler  slog.Handler
+	redirect bool
+}
+
+// tenantHandler 按业务方路由日志的 slog.Handler
+//
+// 日志记录（或通过 Logger.With 预先绑定的字段）中带有 bizId 字段且该业务方配置了专属日志时，
+// 记录会被额外写入或只写入业务方的日志文件。只识别顶层的 bizId 字段，分组内的同名字段不参与路由。
+type tenantHandler struct {
+	base    slog.Handler
+	tenants map[int64]tenantSink
+
+	// bizID 通过 WithAttrs 绑定的业务方，绑定后无需再逐条扫描记录
+	bizID   int64
+	bound   bool
+	grouped bool // 已进入分组，之后绑定的字段都不在顶层
+}
+
+// newTenantHandler 为配置的业务方创建专属日志输出，未配置业务方时直接返回 base
+func newTenantHandler(base slog.Handler, cfg config.LogConfig, newHandler func(w io.Writer) slog.Handler) (slog.Handler, error) {
+	if len(cfg.Tenants) == 0 {
+		return base, nil
+	}
+	tenants := make(map[int64]tenantSink, len(cfg.Tenants))
+	for _, t := range cfg.Tenants {
+		var redirect bool
+		switch t.Mode {
+		case TenantModeDuplicate, "":
+		case TenantModeRedirect:
+			redirect = true
+		default:
+			return nil, fmt.Errorf("业务方 %d 的日志模式无效: %s", t.BizID, t.Mode)
+		}
+		if t.Path == "" {
+			return nil, fmt.Errorf("业务方 %d 未配置日志文件路径", t.BizID)
+		}
+		rotation := t.Rotation
+		if rotation.MaxSize == 0 {
+			rotation.MaxSize = cfg.Rotation.MaxSize
+		}
+		if rotation.MaxAge == 0 {
+			rotation.MaxAge = cfg.Rotation.MaxAge
+		}
+		if rotation.MaxBackups == 0 {
+			rotation.MaxBackups = cfg.Rotation.MaxBackups
+		}
+		w := &lumberjack.Logger{
+			Filename:   t.Path,
+			MaxSize:    rotation.MaxSize,
+			MaxBackups: rotation.MaxBackups,
+			MaxAge:     rotation.MaxAge,
+			Compress:   rotation.Compress || cfg.Rotation.Compress,
+		}
+		tenants[t.BizID] = tenantSink{handler: newHandler(w), redirect: redirect}
+	}
+	return &tenantHandler{base: base, tenants: tenants}, nil
+}
+
+func (h *tenantHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.base.Enabled(ctx, level)
+}
+
+func (h *tenantHandler) Handle(ctx context.Context, r slog.Record) error {
+	bizID, ok := h.bizID, h.bound
+	if !ok && !h.grouped {
+		bizID, ok = recordBizID(r)
+	}
+	if ok {
+		if sink, found := h.tenants[bizID]; found {
+			err := sink.handler.Handle(ctx, r.Clone())
+			if sink.redirect {
+				return err
+			}
+		}
+	}
+	return h.base.Handle(ctx, r)
+}
+
+func (h *tenantHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.base = h.base.WithAttrs(attrs)
+	next.tenants = make(map[int64]tenantSink, len(h.tenants))
+	for id, sink := range h.tenants {
+		next.tenants[id] = tenantSink{handler: sink.handler.WithAttrs(attrs), redirect: sink.redirect}
+	}
+	if !h.grouped {
+		for _, a := range attrs {
+			if id, ok := attrBizID(a); ok {
+				next.bizID, next.bound = id, true
+			}
+		}
+	}
+	return &next
+}
+
+func (h *tenantHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.base = h.base.WithGroup(name)
+	next.tenants = make(map[int64]tenantSink, len(h.tenants))
+	for id, sink := range h.tenants {
+		next.tenants[id] = tenantSink{handler: sink.handler.WithGroup(name), redirect: sink.redirect}
+	}
+	next.grouped = true
+	return &next
+}
+
+func recordBizID(r slog.Record) (bizID int64, ok bool) {
+	r.Attrs(func(a slog.Attr) bool {
+		bizID, ok = attrBizID(a)
+		return !ok
+	})
+	return bizID, ok
+}
+
+func attrBizID(a slog.Attr) (int64, bool) {
+	if a.Key != TenantKey {
+		return 0, false
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindInt64:
+		return v.Int64(), true
+	case slog.KindUint64:
+		return int64(v.Uint64()), true
+	default:
+		return 0, false
+	}
+}
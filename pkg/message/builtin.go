@@ -0,0 +1,39 @@
+package message
+
+import (
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/gobwas/ws"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string      { return CodecProtobuf }
+func (protobufCodec) OpCode() ws.OpCode { return ws.OpBinary }
+
+func (protobufCodec) Marshal(msg *gatewayapiv1.Message) ([]byte, error) {
+	return proto.Marshal(msg)
+}
+
+func (protobufCodec) Unmarshal(data []byte, msg *gatewayapiv1.Message) error {
+	return proto.Unmarshal(data, msg)
+}
+
+// jsonCodec 与 protojson 格式兼容的编解码器
+// 枚举编码为名称（如 COMMAND_TYPE_HEARTBEAT），解码时也接受数字；body 按 base64 编码；忽略未知字段
+// 编码在推送热路径上，使用手写的追加编码和池化缓冲区；解码仍使用 protojson 以完整支持 JSON 语法
+type jsonCodec struct{}
+
+var jsonUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+func (jsonCodec) Name() string      { return CodecJSON }
+func (jsonCodec) OpCode() ws.OpCode { return ws.OpText }
+
+func (jsonCodec) Marshal(msg *gatewayapiv1.Message) ([]byte, error) {
+	return marshalPooled(msg, appendJSON)
+}
+
+func (jsonCodec) Unmarshal(data []byte, msg *gatewayapiv1.Message) error {
+	return jsonUnmarshal.Unmarshal(data, msg)
+}
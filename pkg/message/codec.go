@@ -0,0 +1,81 @@
+// Package message 定义网关与客户端之间消息信封的编解码器。
+//
+// 编解码器按名称注册，客户端在握手时通过 ?codec= 查询参数选择，未指定时使用配置的默认编解码器。
+// 内置 protobuf、json、msgpack 三种编解码器，嵌入方可以通过 Register 注册自定义编解码器，
+// 也可以在运行期间替换同名编解码器，之后建立的连接立即使用新的实现，已建立的连接不受影响。
+package message
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/gobwas/ws"
+)
+
+var ErrUnknownCodec = errors.New("未知的消息编解码器")
+
+// 内置的编解码器名称
+const (
+	CodecProtobuf = "protobuf" // protobuf 二进制格式，默认编解码器
+	CodecJSON     = "json"     // protojson 格式，以文本帧发送，便于浏览器和调试工具直接使用
+	CodecMsgpack  = "msgpack"  // msgpack 格式，键名与 json 相同
+)
+
+// Codec 消息信封编解码器
+// 实现必须是并发安全的，同一个实例会被所有选择它的连接共享
+type Codec interface {
+	// Name 返回编解码器名称，客户端握手时按该名称选择
+	Name() string
+	// OpCode 返回发送编码结果时使用的帧类型，ws.OpText 或 ws.OpBinary
+	OpCode() ws.OpCode
+	// Marshal 编码消息信封
+	Marshal(msg *gatewayapiv1.Message) ([]byte, error)
+	// Unmarshal 把 data 解码到 msg 中，data 来自客户端，实现必须能安全处理任意输入
+	Unmarshal(data []byte, msg *gatewayapiv1.Message) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		CodecProtobuf: protobufCodec{},
+		CodecJSON:     jsonCodec{},
+		CodecMsgpack:  msgpackCodec{},
+	}
+)
+
+// Register 注册编解码器，同名编解码器会被替换
+// 可以在启动前或运行期间调用，替换只影响之后建立的连接
+func Register(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+// Unregister 注销编解码器，之后客户端无法再选择它，已建立的连接不受影响
+func Unregister(name string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	delete(codecs, name)
+}
+
+// Lookup 按名称查找编解码器
+func Lookup(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// Names 返回已注册的编解码器名称，按字典序排列
+func Names() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
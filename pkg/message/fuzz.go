@@ -0,0 +1,46 @@
+//go:build gofuzz
+
+package message
+
+import (
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"google.golang.org/protobuf/proto"
+)
+
+// 以下函数供 go-fuzz 使用，每个内置编解码器一个入口，种子语料位于 testdata/fuzz/<函数名>，例如：
+//
+//	go-fuzz-build -func FuzzJSON ./pkg/message
+//	go-fuzz -bin message-fuzz.zip -func FuzzJSON -workdir pkg/message/testdata/fuzz/FuzzJSON
+//
+// 解码任意输入不能 panic；解码成功的消息重新编码再解码后必须与第一次解码的结果一致。
+
+func FuzzProtobuf(data []byte) int {
+	return fuzzCodec(protobufCodec{}, data)
+}
+
+func FuzzJSON(data []byte) int {
+	return fuzzCodec(jsonCodec{}, data)
+}
+
+func FuzzMsgpack(data []byte) int {
+	return fuzzCodec(msgpackCodec{}, data)
+}
+
+func fuzzCodec(c Codec, data []byte) int {
+	var msg gatewayapiv1.Message
+	if err := c.Unmarshal(data, &msg); err != nil {
+		return 0
+	}
+	encoded, err := c.Marshal(&msg)
+	if err != nil {
+		panic(err)
+	}
+	var again gatewayapiv1.Message
+	if err := c.Unmarshal(encoded, &again); err != nil {
+		panic(err)
+	}
+	if !proto.Equal(&msg, &again) {
+		panic("编解码往返结果不一致: " + c.Name())
+	}
+	return 1
+}
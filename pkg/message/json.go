@@ -0,0 +1,126 @@
+package message
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+)
+
+var errInvalidUTF8 = errors.New("key 不是合法的 UTF-8 字符串")
+
+// maxPooledBuffer 放回池中的缓冲区的最大容量，偶发的大消息不会让池一直占用大块内存
+const maxPooledBuffer = 64 << 10
+
+// bufferPool 编码时使用的临时缓冲区
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// marshalPooled 在池化的缓冲区中编码，返回长度恰好的副本
+// 编码结果会被多个连接共享并在发送队列中停留到写完，无法确定归还时机，因此不直接返回池中的缓冲区；
+// 这样每条消息只有一次恰好大小的分配，而不是编码过程中反复扩容
+func marshalPooled(msg *gatewayapiv1.Message, appendFn func([]byte, *gatewayapiv1.Message) ([]byte, error)) ([]byte, error) {
+	bp := bufferPool.Get().(*[]byte)
+	b, err := appendFn((*bp)[:0], msg)
+	var out []byte
+	if err == nil {
+		out = make([]byte, len(b))
+		copy(out, b)
+	}
+	if cap(b) <= maxPooledBuffer {
+		*bp = b
+		bufferPool.Put(bp)
+	}
+	return out, err
+}
+
+// appendJSON 把消息信封按 protojson 的规则追加编码到 dst：
+// 省略零值字段，已知枚举编码为名称、未知枚举编码为数字，body 按标准 base64 编码，seq 编码为字符串，key 必须是合法的 UTF-8。
+// 信封只有四个字段，手写编码避免 protojson 基于反射的逐字段分配
+func appendJSON(dst []byte, msg *gatewayapiv1.Message) ([]byte, error) {
+	key, body := msg.GetKey(), msg.GetBody()
+	if !utf8.ValidString(key) {
+		return dst, errInvalidUTF8
+	}
+	dst = append(dst, '{')
+	sep := false
+	if cmd := msg.GetCmd(); cmd != 0 {
+		dst = append(dst, `"cmd":`...)
+		if name, ok := gatewayapiv1.Message_CommandType_name[int32(cmd)]; ok {
+			dst = append(dst, '"')
+			dst = append(dst, name...)
+			dst = append(dst, '"')
+		} else {
+			dst = strconv.AppendInt(dst, int64(cmd), 10)
+		}
+		sep = true
+	}
+	if key != "" {
+		if sep {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `"key":`...)
+		dst = appendJSONString(dst, key)
+		sep = true
+	}
+	if len(body) > 0 {
+		if sep {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `"body":"`...)
+		dst = base64.StdEncoding.AppendEncode(dst, body)
+		dst = append(dst, '"')
+		sep = true
+	}
+	if seq := msg.GetSeq(); seq != 0 {
+		if sep {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `"seq":"`...)
+		dst = strconv.AppendUint(dst, seq, 10)
+		dst = append(dst, '"')
+	}
+	return append(dst, '}'), nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString 追加带引号的 JSON 字符串，s 必须是合法的 UTF-8
+// 只转义 JSON 要求转义的字符，与 protojson 一致
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			continue
+		}
+		dst = append(dst, s[start:i]...)
+		switch c {
+		case '"', '\\':
+			dst = append(dst, '\\', c)
+		case '\b':
+			dst = append(dst, '\\', 'b')
+		case '\f':
+			dst = append(dst, '\\', 'f')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+		}
+		start = i + 1
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
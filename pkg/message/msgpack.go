@@ -0,0 +1,85 @@
+package message
+
+import (
+	"fmt"
+
+	gatewayapiv1 "github.com/YaoAzure/wsgateway/api/proto/gen/v1/gatewayapi"
+	"github.com/gobwas/ws"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// msgpack 编码中的键名，与 json 编解码器保持一致
+const (
+	msgpackKeyCmd  = "cmd"
+	msgpackKeyKey  = "key"
+	msgpackKeyBody = "body"
+	msgpackKeySeq  = "seq"
+)
+
+// msgpackCodec 把消息信封编码为 msgpack map：{"cmd": int, "key": str, "body": bin, "seq": uint}
+// seq 为零时省略，未启用会话恢复时与只有三个键的旧格式完全相同
+// 信封字段很少，直接使用 msgp 的底层追加/读取函数，不依赖代码生成；解码时跳过未知键
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string      { return CodecMsgpack }
+func (msgpackCodec) OpCode() ws.OpCode { return ws.OpBinary }
+
+func (msgpackCodec) Marshal(msg *gatewayapiv1.Message) ([]byte, error) {
+	return marshalPooled(msg, appendMsgpack)
+}
+
+func appendMsgpack(b []byte, msg *gatewayapiv1.Message) ([]byte, error) {
+	seq := msg.GetSeq()
+	if seq != 0 {
+		b = msgp.AppendMapHeader(b, 4)
+	} else {
+		b = msgp.AppendMapHeader(b, 3)
+	}
+	b = msgp.AppendString(b, msgpackKeyCmd)
+	b = msgp.AppendInt32(b, int32(msg.GetCmd()))
+	b = msgp.AppendString(b, msgpackKeyKey)
+	b = msgp.AppendString(b, msg.GetKey())
+	b = msgp.AppendString(b, msgpackKeyBody)
+	b = msgp.AppendBytes(b, msg.GetBody())
+	if seq != 0 {
+		b = msgp.AppendString(b, msgpackKeySeq)
+		b = msgp.AppendUint64(b, seq)
+	}
+	return b, nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, msg *gatewayapiv1.Message) error {
+	n, b, err := msgp.ReadMapHeaderBytes(data)
+	if err != nil {
+		return err
+	}
+	msg.Reset()
+	for range n {
+		var key []byte
+		key, b, err = msgp.ReadMapKeyZC(b)
+		if err != nil {
+			return err
+		}
+		switch string(key) {
+		case msgpackKeyCmd:
+			var cmd int32
+			cmd, b, err = msgp.ReadInt32Bytes(b)
+			msg.Cmd = gatewayapiv1.Message_CommandType(cmd)
+		case msgpackKeyKey:
+			msg.Key, b, err = msgp.ReadStringBytes(b)
+		case msgpackKeyBody:
+			msg.Body, b, err = msgp.ReadBytesBytes(b, nil)
+		case msgpackKeySeq:
+			msg.Seq, b, err = msgp.ReadUint64Bytes(b)
+		default:
+			b, err = msgp.Skip(b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(b) > 0 {
+		return fmt.Errorf("msgpack 消息末尾有 %d 字节多余数据", len(b))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package message
+
+import (
+	"fmt"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// Negotiator 在握手时为连接选择编解码器
+// 每次都从注册表中查找，因此运行期间注册或替换的编解码器对之后的连接立即生效
+type Negotiator struct {
+	defaultCodec string
+}
+
+func NewNegotiator(i do.Injector) (*Negotiator, error) {
+	cfg, err := do.Invoke[config.MessageConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	name := cfg.DefaultCodec
+	if name == "" {
+		name = CodecProtobuf
+	}
+	if _, ok := Lookup(name); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+	}
+	return &Negotiator{defaultCodec: name}, nil
+}
+
+// Negotiate 按客户端请求的名称选择编解码器，name 为空时使用默认编解码器
+func (n *Negotiator) Negotiate(name string) (Codec, error) {
+	if name == "" {
+		name = n.defaultCodec
+	}
+	c, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+	}
+	return c, nil
+}
@@ -0,0 +1,193 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen 表示Redis熔断器处于打开状态，命令没有发送到Redis就直接失败
+var ErrCircuitOpen = errors.New("redis熔断器已打开")
+
+const (
+	// defaultFailureThreshold 未配置 redis.breaker.failureThreshold 时打开熔断器的连续失败次数
+	defaultFailureThreshold = 5
+	// defaultOpenTimeout 未配置 redis.breaker.openTimeout 时熔断器打开后到放行探测命令的时间
+	defaultOpenTimeout = 5 * time.Second
+)
+
+// 熔断器的状态
+const (
+	StateClosed   = "closed"   // 正常放行所有命令
+	StateOpen     = "open"     // 所有命令直接以 ErrCircuitOpen 失败
+	StateHalfOpen = "halfOpen" // 只放行一个探测命令，成功后关闭熔断器，失败后重新打开
+)
+
+// Breaker Redis客户端的熔断器，以 go-redis 钩子的形式作用于所有命令和流水线
+//
+// Redis不可用时每个命令都要等到拨号或读写超时才失败，握手和消息处理被大量阻塞。
+// 连续失败达到阈值后熔断器打开，此后的命令直接失败，调用方可以立即走降级逻辑；
+// 打开一段时间后放行一个探测命令，探测成功即恢复。
+// 只有网络错误和超时计为失败，redis.Nil 和 Redis 返回的命令错误 (如 WRONGTYPE) 说明Redis是可用的。
+type Breaker struct {
+	threshold   int
+	openTimeout time.Duration
+	logger      *log.Logger
+
+	mu       sync.Mutex
+	state    string
+	failures int       // 关闭状态下的连续失败次数
+	openedAt time.Time // 最近一次打开的时间
+	probing  bool      // 半开状态下是否已有探测命令在执行
+}
+
+func newBreaker(cfg config.RedisBreakerConfig, logger *log.Logger) *Breaker {
+	b := &Breaker{
+		threshold:   cfg.FailureThreshold,
+		openTimeout: time.Duration(cfg.OpenTimeout),
+		logger:      logger,
+		state:       StateClosed,
+	}
+	if b.threshold <= 0 {
+		b.threshold = defaultFailureThreshold
+	}
+	if b.openTimeout <= 0 {
+		b.openTimeout = defaultOpenTimeout
+	}
+	return b
+}
+
+// State 返回熔断器当前的状态
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.openTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// allow 判断是否放行一个命令，返回的 probe 表示该命令是半开状态下的探测命令
+func (b *Breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateClosed:
+		return false, nil
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false, ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		fallthrough
+	default:
+		if b.probing {
+			return false, ErrCircuitOpen
+		}
+		b.probing = true
+		return true, nil
+	}
+}
+
+// record 记录命令的执行结果
+func (b *Breaker) record(probe bool, err error) {
+	failed := isFailure(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+		if failed {
+			b.state = StateOpen
+			b.openedAt = time.Now()
+			return
+		}
+		b.state = StateClosed
+		b.failures = 0
+		b.logger.Info("Redis已恢复，熔断器关闭")
+		return
+	}
+	if b.state != StateClosed {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+		b.failures = 0
+		b.logger.Warn("Redis连续访问失败，熔断器打开",
+			slog.Int("failures", b.threshold),
+			slog.Duration("openTimeout", b.openTimeout),
+			slog.Any("error", err))
+	}
+}
+
+// isFailure 判断命令的错误是否说明Redis不可用
+func isFailure(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	// Redis 返回的命令错误说明连接和服务都是正常的
+	var rerr redis.Error
+	return !errors.As(err, &rerr)
+}
+
+// initCommands go-redis 建立新连接时在连接上执行的命令，同样经过钩子
+// 探测命令需要建立新连接，这些命令不受熔断器限制，也不计入结果，由触发建立连接的命令计入
+var initCommands = map[string]bool{"hello": true, "auth": true, "select": true, "client": true, "readonly": true}
+
+func isInitCommands(cmds ...redis.Cmder) bool {
+	for _, cmd := range cmds {
+		if !initCommands[cmd.Name()] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Breaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (b *Breaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if isInitCommands(cmd) {
+			return next(ctx, cmd)
+		}
+		probe, err := b.allow()
+		if err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err = next(ctx, cmd)
+		b.record(probe, err)
+		return err
+	}
+}
+
+func (b *Breaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if isInitCommands(cmds...) {
+			return next(ctx, cmds)
+		}
+		probe, err := b.allow()
+		if err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err = next(ctx, cmds)
+		b.record(probe, err)
+		return err
+	}
+}
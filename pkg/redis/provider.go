@@ -2,6 +2,7 @@ package redis
 
 import (
 	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
 	"github.com/redis/go-redis/v9"
 	"github.com/samber/do/v2"
 )
@@ -9,11 +10,13 @@ import (
 // Package 定义 Redis 包的服务包，使用 Package Loading 模式
 var Package = do.Package(
 	// Redis 客户端使用懒加载
+	do.Lazy(NewUniversalClient),
 	do.Lazy(NewRedisClient),
 )
 
-// NewRedisClient 创建 Redis 客户端
-func NewRedisClient(i do.Injector) (redis.Cmdable, error) {
+// NewUniversalClient 创建 Redis 客户端
+// 除普通命令外还提供 Pub/Sub 等能力，命令和订阅共享同一个连接池
+func NewUniversalClient(i do.Injector) (redis.UniversalClient, error) {
 	// 从依赖注入容器中获取 Redis 配置
 	redisConfig, err := do.Invoke[config.RedisConfig](i)
 	if err != nil {
@@ -26,6 +29,19 @@ func NewRedisClient(i do.Injector) (redis.Cmdable, error) {
 		DB:       redisConfig.DB,
 		PoolSize: redisConfig.PoolSize,
 	})
+	if redisConfig.Breaker.Enabled {
+		logger, err := do.Invoke[*log.Logger](i)
+		if err != nil {
+			return nil, err
+		}
+		rdb.AddHook(newBreaker(redisConfig.Breaker, logger))
+	}
 
 	return rdb, nil
 }
+
+// NewRedisClient 创建 Redis 命令客户端
+// 只需要执行命令的组件依赖 redis.Cmdable，便于替换和模拟
+func NewRedisClient(i do.Injector) (redis.Cmdable, error) {
+	return do.Invoke[redis.UniversalClient](i)
+}
@@ -0,0 +1,214 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/YaoAzure/wsgateway/pkg/log"
+)
+
+// degradedStore Redis不可用时的降级模式，包装Redis存储后端
+//
+// 创建会话因存储不可用失败时改用内存中的会话，握手照常完成，已有连接也不受影响。
+// Redis恢复后，会话丢失检查 (Checker.Missing) 把这些用户报告为会话已丢失，
+// 连接层按会话丢失的策略调用 Restore：降级会话在Redis中重新创建、合并降级期间写入的字段后切换到Redis，
+// 此时多连接策略重新生效，与其它连接冲突时和会话丢失后重建一样关闭连接。
+type degradedStore struct {
+	Store
+	memory *MemoryStore
+	logger *log.Logger
+
+	mu       sync.Mutex
+	degraded map[*degradedSession]struct{} // 尚未迁移到Redis的降级会话
+}
+
+func newDegradedStore(primary Store, logger *log.Logger) *degradedStore {
+	return &degradedStore{
+		Store:    primary,
+		memory:   NewMemoryStore(),
+		logger:   logger,
+		degraded: make(map[*degradedSession]struct{}),
+	}
+}
+
+// storeUnavailable 判断创建或查找会话的错误是否说明存储不可用，多连接策略拒绝连接的错误不降级
+func storeUnavailable(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == nil &&
+		!errors.Is(err, ErrDeviceConflict) && !errors.Is(err, ErrDeviceLimit) && !errors.Is(err, ErrSessionNotFound)
+}
+
+func (d *degradedStore) Build(ctx context.Context, info UserInfo) (Session, bool, error) {
+	s, isNew, err := d.Store.Build(ctx, info)
+	if !storeUnavailable(ctx, err) {
+		return s, isNew, err
+	}
+	ms, isNew, _ := d.memory.Build(ctx, info)
+	ds := &degradedSession{store: d, current: ms, memory: ms}
+	d.mu.Lock()
+	d.degraded[ds] = struct{}{}
+	n := len(d.degraded)
+	d.mu.Unlock()
+	info = ms.UserInfo()
+	d.logger.Warn("创建会话失败，降级为内存会话",
+		slog.Int64("bizId", info.BizID),
+		slog.Int64("userId", info.UserID),
+		slog.Int("degraded", n),
+		slog.Any("error", err))
+	return ds, isNew, nil
+}
+
+func (d *degradedStore) Find(ctx context.Context, bizID, userID int64) (Session, error) {
+	s, err := d.Store.Find(ctx, bizID, userID)
+	if !storeUnavailable(ctx, err) {
+		return s, err
+	}
+	if ms, merr := d.memory.Find(ctx, bizID, userID); merr == nil {
+		return ms, nil
+	}
+	return nil, err
+}
+
+// Missing Redis可以访问时，把仍有降级会话的用户一并报告为会话已丢失，由连接层调用 Restore 迁移到Redis
+func (d *degradedStore) Missing(ctx context.Context, users []UserInfo) ([]UserInfo, error) {
+	missing, err := d.Store.Missing(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.degraded) == 0 {
+		return missing, nil
+	}
+	pending := make(map[[2]int64]struct{}, len(d.degraded))
+	for ds := range d.degraded {
+		info := ds.memory.UserInfo()
+		pending[[2]int64{info.BizID, info.UserID}] = struct{}{}
+	}
+	for _, u := range missing {
+		delete(pending, [2]int64{u.BizID, u.UserID})
+	}
+	for _, u := range users {
+		if _, ok := pending[[2]int64{u.BizID, u.UserID}]; ok {
+			missing = append(missing, u)
+		}
+	}
+	return missing, nil
+}
+
+// forget 降级会话已迁移到Redis或连接已关闭，移除连接对内存中会话的引用，同一用户没有其它降级会话时会话随之删除
+func (d *degradedStore) forget(ctx context.Context, ds *degradedSession) {
+	d.mu.Lock()
+	delete(d.degraded, ds)
+	d.mu.Unlock()
+	_ = ds.memory.Destroy(ctx)
+}
+
+// degradedSession 降级期间创建的会话，迁移到Redis之前读写内存中的会话，之后读写Redis中的会话
+type degradedSession struct {
+	store  *degradedStore
+	memory Session
+
+	mu      sync.RWMutex
+	current Session
+}
+
+func (s *degradedSession) session() Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// migrated 返回是否已迁移到Redis
+func (s *degradedSession) migrated() bool {
+	return s.session() != s.memory
+}
+
+func (s *degradedSession) UserInfo() UserInfo { return s.memory.UserInfo() }
+
+func (s *degradedSession) Get(ctx context.Context, key string) (string, error) {
+	return s.session().Get(ctx, key)
+}
+
+func (s *degradedSession) Set(ctx context.Context, key, value string) error {
+	return s.session().Set(ctx, key, value)
+}
+
+func (s *degradedSession) GetAll(ctx context.Context) (map[string]string, error) {
+	return s.session().GetAll(ctx)
+}
+
+func (s *degradedSession) SetMulti(ctx context.Context, fields map[string]string) error {
+	return s.session().SetMulti(ctx, fields)
+}
+
+func (s *degradedSession) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	return s.session().Incr(ctx, key, delta)
+}
+
+func (s *degradedSession) CompareAndSet(ctx context.Context, key, old, value string) (bool, error) {
+	return s.session().CompareAndSet(ctx, key, old, value)
+}
+
+func (s *degradedSession) Update(ctx context.Context, fields map[string]string) error {
+	return s.session().Update(ctx, fields)
+}
+
+func (s *degradedSession) Touch(ctx context.Context) error {
+	return s.session().Touch(ctx)
+}
+
+func (s *degradedSession) TakenOver() string {
+	return s.session().TakenOver()
+}
+
+func (s *degradedSession) Pipeline() *Pipeline {
+	return s.session().Pipeline()
+}
+
+func (s *degradedSession) Release(ctx context.Context) error {
+	if !s.migrated() {
+		s.store.forget(ctx, s)
+		return nil
+	}
+	return s.session().Release(ctx)
+}
+
+func (s *degradedSession) Destroy(ctx context.Context) error {
+	if !s.migrated() {
+		s.store.forget(ctx, s)
+		return nil
+	}
+	return s.session().Destroy(ctx)
+}
+
+// Restore 尚未迁移时在Redis中创建会话并按多连接策略占用槽位，合并降级期间写入的字段后切换到Redis中的会话；
+// 已迁移时与Redis会话的 Restore 相同。Redis仍不可用时返回错误，留给下一次检查
+func (s *degradedSession) Restore(ctx context.Context) (bool, error) {
+	if s.migrated() {
+		return s.session().Restore(ctx)
+	}
+	fields, err := s.memory.GetAll(ctx)
+	if err != nil {
+		return false, err
+	}
+	rs, isNew, err := s.store.Store.Build(ctx, s.memory.UserInfo())
+	if err != nil {
+		return false, err
+	}
+	// 会话在降级期间已由其它节点的连接创建时保留原有的登录时间
+	if !isNew {
+		delete(fields, LoginTimeField)
+	}
+	if err := rs.SetMulti(ctx, fields); err != nil {
+		// 释放刚占用的槽位，下一次检查重新 Build 时不会与自己残留的占用冲突 (rejectNew 策略)
+		_ = rs.Release(ctx)
+		return false, err
+	}
+	s.mu.Lock()
+	s.current = rs
+	s.mu.Unlock()
+	s.store.forget(ctx, s)
+	return true, nil
+}
@@ -0,0 +1,236 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// DevicePolicy 同一用户再次建立连接时的处理策略
+type DevicePolicy string
+
+const (
+	// PolicyAllowMulti 每个设备一个连接，同一设备重连时取代旧连接
+	PolicyAllowMulti DevicePolicy = "allowMulti"
+	// PolicyKickOld 只保留一个连接，新连接取代旧连接
+	PolicyKickOld DevicePolicy = "kickOld"
+	// PolicyRejectNew 只保留一个连接，已有连接时拒绝新连接
+	PolicyRejectNew DevicePolicy = "rejectNew"
+	// PolicyTakeover 只保留一个连接，新连接接管会话：旧连接先收到接管通知再被关闭，接管事件上报业务后端
+	PolicyTakeover DevicePolicy = "takeover"
+)
+
+const (
+	// connField 单连接策略下会话中记录当前连接ID的字段
+	connField = "conn"
+	// deviceFieldPrefix 多设备策略下会话中记录每个设备当前连接ID的字段前缀
+	deviceFieldPrefix = "device:"
+)
+
+var (
+	// ErrDeviceConflict 表示 rejectNew 策略下用户已经有连接。
+	ErrDeviceConflict = errors.New("用户已在其它连接上登录")
+
+	// ErrUnknownDevicePolicy 表示配置了不支持的多连接策略。
+	ErrUnknownDevicePolicy = errors.New("未知的多连接策略")
+
+	// luaClaimConn 脚本在会话中为当前连接占用槽位，占用成功时同时写入连接记录 (KEYS[3])。
+	// 槽位已被当前连接占用时视为占用成功，重试占用（例如降级会话迁移回Redis失败后再次迁移）不会与自己冲突。
+	// ARGV[1] 为连接ID，ARGV[2] 为 reject 时槽位已被占用则不覆盖，ARGV[3] 为会话的过期时间（毫秒），ARGV[4] 为连接记录。
+	// ARGV[5] 为设备指纹，ARGV[6] 为同时在线的设备数上限 (0 表示不限制)，ARGV[7] 为超过上限时的处理方式，ARGV[8] 为连接记录的字段前缀；
+	// 统计设备数时不计入被当前连接取代的旧连接，kickOldest 时删除被踢下线的设备的连接记录。
+	// 返回 {是否占用成功, 之前占用槽位的连接ID, 被踢下线的设备指纹...}，设备数超过上限被拒绝时为 {-1, ''}。
+	luaClaimConn = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], KEYS[2])
+if current and current ~= ARGV[1] and ARGV[2] == 'reject' then
+    return {0, current}
+end
+local res = {1, current or ''}
+local limit = tonumber(ARGV[6])
+if limit > 0 then
+    local replaced = ARGV[8] .. (current or '')
+    local since, records, online = {}, {}, false
+    local fields = redis.call('HGETALL', KEYS[1])
+    for i = 1, #fields, 2 do
+        local field = fields[i]
+        if string.sub(field, 1, #ARGV[8]) == ARGV[8] and field ~= replaced then
+            local ok, rec = pcall(cjson.decode, fields[i + 1])
+            if ok and type(rec) == 'table' then
+                local fp = rec.fingerprint or ''
+                local t = tonumber(rec.since) or 0
+                if fp == ARGV[5] then
+                    online = true
+                elseif since[fp] == nil then
+                    since[fp], records[fp] = t, {field}
+                else
+                    since[fp] = math.min(since[fp], t)
+                    table.insert(records[fp], field)
+                end
+            end
+        end
+    end
+    local others = {}
+    for fp in pairs(since) do
+        table.insert(others, fp)
+    end
+    if not online and #others >= limit then
+        if ARGV[7] ~= 'kickOldest' then
+            return {-1, ''}
+        end
+        table.sort(others, function(a, b) return since[a] < since[b] end)
+        for i = 1, #others - limit + 1 do
+            redis.call('HDEL', KEYS[1], unpack(records[others[i]]))
+            table.insert(res, others[i])
+        end
+    end
+end
+redis.call('HSET', KEYS[1], KEYS[2], ARGV[1], KEYS[3], ARGV[4])
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+    redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return res
+`)
+
+	// luaReleaseConn 脚本删除当前连接的记录 (KEYS[3])，槽位只在仍被当前连接占用时释放，避免删除取代它的新连接的记录。
+	luaReleaseConn = redis.NewScript(`
+redis.call('HDEL', KEYS[1], KEYS[3])
+if redis.call('HGET', KEYS[1], KEYS[2]) == ARGV[1] then
+    return redis.call('HDEL', KEYS[1], KEYS[2])
+end
+return 0
+`)
+)
+
+// devicePolicies 按业务方解析多连接策略
+type devicePolicies struct {
+	defaultPolicy DevicePolicy
+	biz           map[int64]DevicePolicy
+}
+
+func newDevicePolicies(cfg config.SessionDevicesConfig) (devicePolicies, error) {
+	p := devicePolicies{defaultPolicy: PolicyAllowMulti, biz: make(map[int64]DevicePolicy, len(cfg.BizPolicies))}
+	if cfg.Policy != "" {
+		policy, err := parseDevicePolicy(cfg.Policy)
+		if err != nil {
+			return devicePolicies{}, err
+		}
+		p.defaultPolicy = policy
+	}
+	for _, b := range cfg.BizPolicies {
+		policy, err := parseDevicePolicy(b.Policy)
+		if err != nil {
+			return devicePolicies{}, fmt.Errorf("bizId=%d: %w", b.BizID, err)
+		}
+		p.biz[b.BizID] = policy
+	}
+	return p, nil
+}
+
+func parseDevicePolicy(s string) (DevicePolicy, error) {
+	switch p := DevicePolicy(s); p {
+	case PolicyAllowMulti, PolicyKickOld, PolicyRejectNew, PolicyTakeover:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownDevicePolicy, s)
+	}
+}
+
+func (p devicePolicies) of(bizID int64) DevicePolicy {
+	if policy, ok := p.biz[bizID]; ok {
+		return policy
+	}
+	return p.defaultPolicy
+}
+
+// claimField 返回连接在会话中占用的槽位字段
+func claimField(policy DevicePolicy, info UserInfo) string {
+	if policy == PolicyAllowMulti {
+		return deviceFieldPrefix + info.DeviceID
+	}
+	return connField
+}
+
+// claim 为连接占用会话中的槽位，取代了其它连接时发布槽位变更，由持有被取代连接的节点将其踢下线
+// 用户同时在线的设备数超过上限时按 limit 的处理方式拒绝当前连接或踢下线最早上线的设备
+func (s *redisSession) claim(ctx context.Context, policy DevicePolicy, limit deviceLimit) error {
+	mode := "replace"
+	if policy == PolicyRejectNew {
+		mode = "reject"
+	}
+	recordField, record := s.connRecord()
+	res, err := luaClaimConn.Run(ctx, s.rdb, []string{s.key, s.claimField, recordField},
+		s.userInfo.ConnID, mode, s.ttl.Milliseconds(), record,
+		s.userInfo.Fingerprint, limit.max, string(limit.overflow), connRecordPrefix).Slice()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCreateSessionFailed, err)
+	}
+	if len(res) < 2 {
+		return fmt.Errorf("%w: 未知的脚本结果: %v", ErrCreateSessionFailed, res)
+	}
+	previous, _ := res[1].(string)
+	switch claimed, _ := res[0].(int64); claimed {
+	case 1:
+	case -1:
+		return fmt.Errorf("%w: max=%d", ErrDeviceLimit, limit.max)
+	default:
+		return fmt.Errorf("%w: conn=%s", ErrDeviceConflict, previous)
+	}
+	for _, v := range res[2:] {
+		fingerprint, _ := v.(string)
+		if err := publishChange(ctx, s.rdb, FieldChange{
+			BizID:    s.userInfo.BizID,
+			UserID:   s.userInfo.UserID,
+			Key:      fingerprintFieldPrefix + fingerprint,
+			Value:    s.userInfo.ConnID,
+			DeviceID: s.userInfo.DeviceID,
+		}); err != nil {
+			return err
+		}
+	}
+	if previous == "" || previous == s.userInfo.ConnID {
+		return nil
+	}
+	takeover := policy == PolicyTakeover
+	if takeover {
+		s.takenOver = previous
+	}
+	return publishChange(ctx, s.rdb, FieldChange{
+		BizID:    s.userInfo.BizID,
+		UserID:   s.userInfo.UserID,
+		Key:      s.claimField,
+		Value:    s.userInfo.ConnID,
+		DeviceID: s.userInfo.DeviceID,
+		Takeover: takeover,
+	})
+}
+
+func (s *redisSession) TakenOver() string {
+	return s.takenOver
+}
+
+func (s *redisSession) Release(ctx context.Context) error {
+	if s.claimField == "" {
+		return nil
+	}
+	return luaReleaseConn.Run(ctx, s.rdb, []string{s.key, s.claimField, connRecordPrefix + s.userInfo.ConnID}, s.userInfo.ConnID).Err()
+}
+
+// Supersedes 返回这次变更是否表示 info 对应的连接已被同一用户的新连接取代
+func (c FieldChange) Supersedes(info UserInfo) bool {
+	if c.BizID != info.BizID || c.UserID != info.UserID || c.Value == info.ConnID {
+		return false
+	}
+	if c.Key == connField {
+		return true
+	}
+	if fingerprint, ok := strings.CutPrefix(c.Key, fingerprintFieldPrefix); ok {
+		return fingerprint == info.Fingerprint
+	}
+	deviceID, ok := strings.CutPrefix(c.Key, deviceFieldPrefix)
+	return ok && deviceID == info.DeviceID
+}
@@ -0,0 +1,136 @@
+package session
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// luaCompareAndSet 脚本只在字段的当前值等于期望值时写入新值，字段不存在视为空字符串。
+// ARGV[1] 为字段，ARGV[2] 为期望值，ARGV[3] 为新值，ARGV[4] 为会话的过期时间（毫秒），大于0时写入成功后续期。
+// 返回1表示写入成功，返回0表示当前值与期望值不同。
+var luaCompareAndSet = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if (current or '') ~= ARGV[2] then
+    return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+local ttl = tonumber(ARGV[4])
+if ttl > 0 then
+    redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// internalField 判断字段是否是网关内部使用的字段（连接槽位、连接记录和节点记录），GetAll 不返回这些字段
+func internalField(field string) bool {
+	return field == connField ||
+		strings.HasPrefix(field, deviceFieldPrefix) ||
+		strings.HasPrefix(field, connRecordPrefix) ||
+		strings.HasPrefix(field, nodeFieldPrefix)
+}
+
+// ReservedField 判断字段是否由网关自己维护：连接槽位、连接记录、节点记录、登录时间和访客标记
+// 这些字段被外部修改会破坏多连接策略和推送路由，管理API不允许读写
+func ReservedField(field string) bool {
+	return internalField(field) || field == LoginTimeField || field == GuestField
+}
+
+func (s *redisSession) GetAll(ctx context.Context) (map[string]string, error) {
+	fields, err := s.rdb.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	for k := range fields {
+		if internalField(k) {
+			delete(fields, k)
+		}
+	}
+	return fields, nil
+}
+
+func (s *redisSession) SetMulti(ctx context.Context, fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	values := make([]any, 0, 2*len(fields))
+	for k, v := range fields {
+		values = append(values, k, v)
+	}
+	// 写入、续期和变更通知放在同一个事务中，与 Set 一样保证通知不会早于写入生效
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.key, values...)
+		if s.ttl > 0 {
+			pipe.PExpire(ctx, s.key, s.ttl)
+		}
+		for k, v := range fields {
+			if _, ok := s.notifyFields[k]; !ok {
+				continue
+			}
+			if err := publishChange(ctx, pipe, FieldChange{
+				BizID:  s.userInfo.BizID,
+				UserID: s.userInfo.UserID,
+				Key:    k,
+				Value:  v,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *redisSession) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	_, notify := s.notifyFields[key]
+	if !notify && s.ttl <= 0 {
+		return s.rdb.HIncrBy(ctx, s.key, key, delta).Result()
+	}
+	var incr *redis.IntCmd
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.HIncrBy(ctx, s.key, key, delta)
+		if s.ttl > 0 {
+			pipe.PExpire(ctx, s.key, s.ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	val := incr.Val()
+	if notify {
+		// 新值在事务执行后才知道，变更通知在写入之后单独发布
+		if err := publishChange(ctx, s.rdb, FieldChange{
+			BizID:  s.userInfo.BizID,
+			UserID: s.userInfo.UserID,
+			Key:    key,
+			Value:  strconv.FormatInt(val, 10),
+		}); err != nil {
+			return val, err
+		}
+	}
+	return val, nil
+}
+
+func (s *redisSession) CompareAndSet(ctx context.Context, key, old, value string) (bool, error) {
+	swapped, err := luaCompareAndSet.Run(ctx, s.rdb, []string{s.key}, key, old, value, s.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	if swapped == 0 {
+		return false, nil
+	}
+	if _, ok := s.notifyFields[key]; ok {
+		if err := publishChange(ctx, s.rdb, FieldChange{
+			BizID:  s.userInfo.BizID,
+			UserID: s.userInfo.UserID,
+			Key:    key,
+			Value:  value,
+		}); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
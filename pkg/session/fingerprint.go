@@ -0,0 +1,161 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// DeviceOverflow 同一用户同时在线的设备数超过上限时的处理方式
+type DeviceOverflow string
+
+const (
+	// OverflowReject 拒绝新设备的连接
+	OverflowReject DeviceOverflow = "reject"
+	// OverflowKickOldest 踢下线最早上线的设备上的所有连接，为新设备腾出位置
+	OverflowKickOldest DeviceOverflow = "kickOldest"
+)
+
+// fingerprintFieldPrefix 设备被踢下线或被移除时发布的变更通知中的字段前缀，不写入会话
+const fingerprintFieldPrefix = "fingerprint:"
+
+var (
+	// ErrDeviceLimit 表示 reject 处理方式下用户同时在线的设备数已达上限。
+	ErrDeviceLimit = errors.New("用户同时在线的设备数已达上限")
+
+	// luaRevokeDevice 脚本删除会话中指纹为 ARGV[2] 的设备的所有连接记录，ARGV[1] 为连接记录的字段前缀，返回删除的记录数。
+	luaRevokeDevice = redis.NewScript(`
+local fields = redis.call('HGETALL', KEYS[1])
+local removed = 0
+for i = 1, #fields, 2 do
+    if string.sub(fields[i], 1, #ARGV[1]) == ARGV[1] then
+        local ok, rec = pcall(cjson.decode, fields[i + 1])
+        if ok and type(rec) == 'table' and (rec.fingerprint or '') == ARGV[2] then
+            removed = removed + redis.call('HDEL', KEYS[1], fields[i])
+        end
+    end
+end
+return removed
+`)
+)
+
+// deviceLimit 一个业务方的同时在线设备数上限，max 为 0 表示不限制
+type deviceLimit struct {
+	max      int
+	overflow DeviceOverflow
+}
+
+// deviceLimits 按业务方解析同时在线设备数上限
+type deviceLimits map[int64]deviceLimit
+
+func newDeviceLimits(cfg config.SessionDevicesConfig) deviceLimits {
+	limits := make(deviceLimits, len(cfg.Limits))
+	for _, l := range cfg.Limits {
+		overflow := OverflowReject
+		if l.Overflow != "" {
+			overflow = DeviceOverflow(l.Overflow)
+		}
+		limits[l.BizID] = deviceLimit{max: l.MaxDevices, overflow: overflow}
+	}
+	return limits
+}
+
+// of 返回用户的设备数上限，访客每次连接都是新用户，不受限制
+func (l deviceLimits) of(info UserInfo) deviceLimit {
+	if info.Guest {
+		return deviceLimit{}
+	}
+	return l[info.BizID]
+}
+
+// DeviceEvicted 返回这次变更是否表示设备因同时在线的设备数超过上限被踢下线
+func (c FieldChange) DeviceEvicted() bool {
+	return strings.HasPrefix(c.Key, fingerprintFieldPrefix) && c.Value != ""
+}
+
+// DeviceRevoked 返回这次变更是否表示设备通过 DeviceManager.Revoke 被移除
+func (c FieldChange) DeviceRevoked() bool {
+	return strings.HasPrefix(c.Key, fingerprintFieldPrefix) && c.Value == ""
+}
+
+// Device 用户的一个在线设备，由会话中指纹相同的连接记录汇总得到
+type Device struct {
+	Fingerprint string   `json:"fingerprint"`
+	DeviceIDs   []string `json:"deviceIds"` // 设备上的连接上报的设备ID
+	Nodes       []string `json:"nodes"`     // 持有设备连接的节点
+	Conns       int      `json:"conns"`     // 设备上的连接数
+	Since       int64    `json:"since"`     // 设备最早的在线连接建立的时间 (Unix 毫秒)
+}
+
+// DeviceManager 查询和移除用户的在线设备
+type DeviceManager interface {
+	// Devices 返回用户的在线设备，按上线时间排序；会话不存在时返回空列表
+	Devices(ctx context.Context, bizID, userID int64) ([]Device, error)
+	// Revoke 移除用户的一个设备：删除它的连接记录，并通知持有它的连接的节点将其踢下线，返回删除的连接记录数。
+	// 设备之后仍然可以用有效的令牌重新连接，需要禁止时同时吊销令牌
+	Revoke(ctx context.Context, bizID, userID int64, fingerprint string) (int, error)
+}
+
+// RedisDeviceManager 是 DeviceManager 接口的Redis实现，设备由会话哈希中的连接记录推导
+type RedisDeviceManager struct {
+	rdb redis.Cmdable
+}
+
+func NewRedisDeviceManager(i do.Injector) (DeviceManager, error) {
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisDeviceManager{rdb: rdb}, nil
+}
+
+func (m *RedisDeviceManager) Devices(ctx context.Context, bizID, userID int64) ([]Device, error) {
+	fields, err := m.rdb.HGetAll(ctx, fmt.Sprintf(keyFormat, bizID, userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return devicesOf(presenceOf(bizID, userID, fields).Conns), nil
+}
+
+func (m *RedisDeviceManager) Revoke(ctx context.Context, bizID, userID int64, fingerprint string) (int, error) {
+	removed, err := luaRevokeDevice.Run(ctx, m.rdb, []string{fmt.Sprintf(keyFormat, bizID, userID)}, connRecordPrefix, fingerprint).Int()
+	if err != nil {
+		return 0, err
+	}
+	// 没有删除任何记录时也发布通知，连接记录写入失败或已被删除的连接同样会被踢下线
+	err = publishChange(ctx, m.rdb, FieldChange{
+		BizID:  bizID,
+		UserID: userID,
+		Key:    fingerprintFieldPrefix + fingerprint,
+	})
+	return removed, err
+}
+
+// devicesOf 按指纹汇总按建立时间排序的连接记录，得到的设备也按上线时间排序
+func devicesOf(conns []ConnRecord) []Device {
+	devices := make([]Device, 0, len(conns))
+	index := make(map[string]int, len(conns))
+	for _, rec := range conns {
+		i, ok := index[rec.Fingerprint]
+		if !ok {
+			i = len(devices)
+			index[rec.Fingerprint] = i
+			devices = append(devices, Device{Fingerprint: rec.Fingerprint, DeviceIDs: []string{}, Nodes: []string{}, Since: rec.Since})
+		}
+		d := &devices[i]
+		d.Conns++
+		if rec.DeviceID != "" && !slices.Contains(d.DeviceIDs, rec.DeviceID) {
+			d.DeviceIDs = append(d.DeviceIDs, rec.DeviceID)
+		}
+		if rec.Node != "" && !slices.Contains(d.Nodes, rec.Node) {
+			d.Nodes = append(d.Nodes, rec.Node)
+		}
+	}
+	return devices
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// nodeFieldPrefix 会话中记录连接所在节点的字段前缀，字段值为该用户在该节点上的连接数
+const nodeFieldPrefix = "node:"
+
+// luaDetachNode 原子性地减少节点上的连接数，减到 0 时删除该字段
+// 会话已被删除时 HINCRBY 会得到 -1，随后 HDEL 删除字段，空哈希由Redis自动删除，不会残留
+var luaDetachNode = redis.NewScript(`
+local n = redis.call('HINCRBY', KEYS[1], ARGV[1], -1)
+if n <= 0 then
+    redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// Locator 在会话中记录用户的连接分布在哪些网关节点上，用于多节点部署时把推送路由到持有连接的节点。
+// 每个连接建立时 Attach、关闭时 Detach，按连接计数而不是按用户记录，
+// 同一用户在同一节点上的连接并发建立和关闭时也不会误删节点记录。
+type Locator interface {
+	// Attach 记录用户在 nodeID 节点上新建立了一个连接
+	Attach(ctx context.Context, bizID, userID int64, nodeID string) error
+	// Detach 记录用户在 nodeID 节点上关闭了一个连接
+	Detach(ctx context.Context, bizID, userID int64, nodeID string) error
+	// Nodes 返回持有用户连接的所有节点，用户不在线时返回空列表
+	Nodes(ctx context.Context, bizID, userID int64) ([]string, error)
+}
+
+// RedisLocator 是 Locator 接口的Redis实现，节点记录与会话字段存储在同一个哈希中，
+// 还有节点记录时连接关闭销毁会话只删除自己的字段，不会删除整个哈希
+type RedisLocator struct {
+	rdb redis.Cmdable
+}
+
+func NewRedisLocator(i do.Injector) (Locator, error) {
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisLocator{rdb: rdb}, nil
+}
+
+func (r *RedisLocator) Attach(ctx context.Context, bizID, userID int64, nodeID string) error {
+	return r.rdb.HIncrBy(ctx, fmt.Sprintf(keyFormat, bizID, userID), nodeFieldPrefix+nodeID, 1).Err()
+}
+
+func (r *RedisLocator) Detach(ctx context.Context, bizID, userID int64, nodeID string) error {
+	key := fmt.Sprintf(keyFormat, bizID, userID)
+	return luaDetachNode.Run(ctx, r.rdb, []string{key}, nodeFieldPrefix+nodeID).Err()
+}
+
+func (r *RedisLocator) Nodes(ctx context.Context, bizID, userID int64) ([]string, error) {
+	fields, err := r.rdb.HKeys(ctx, fmt.Sprintf(keyFormat, bizID, userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	var nodes []string
+	for _, f := range fields {
+		if nodeID, ok := strings.CutPrefix(f, nodeFieldPrefix); ok {
+			nodes = append(nodes, nodeID)
+		}
+	}
+	return nodes, nil
+}
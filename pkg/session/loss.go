@@ -0,0 +1,84 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// Checker 批量检查会话是否仍然存在
+// 会话可能在连接存续期间从Redis中消失：被手动删除、过期，或者Redis故障切换时丢失数据，
+// 持有连接的节点需要定期发现这种情况，按业务方的策略重建会话或关闭连接
+type Checker interface {
+	// Missing 返回 users 中会话已不存在的用户，同一用户只需要传入一次
+	Missing(ctx context.Context, users []UserInfo) ([]UserInfo, error)
+}
+
+// RedisSessionChecker 是 Checker 接口的Redis实现，一次检查只使用一个流水线
+type RedisSessionChecker struct {
+	rdb redis.Cmdable
+}
+
+func NewRedisSessionChecker(i do.Injector) (Checker, error) {
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisSessionChecker{rdb: rdb}, nil
+}
+
+func (c *RedisSessionChecker) Missing(ctx context.Context, users []UserInfo) ([]UserInfo, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	cmds := make([]*redis.IntCmd, len(users))
+	_, err := c.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, u := range users {
+			cmds[i] = pipe.Exists(ctx, fmt.Sprintf(keyFormat, u.BizID, u.UserID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var missing []UserInfo
+	for i, cmd := range cmds {
+		if cmd.Val() == 0 {
+			missing = append(missing, users[i])
+		}
+	}
+	return missing, nil
+}
+
+func (s *redisSession) Restore(ctx context.Context) (bool, error) {
+	created := true
+	if err := s.initialize(ctx); err != nil {
+		if !errors.Is(err, ErrSessionExisted) {
+			return false, err
+		}
+		// 同一用户在其它节点上的连接可能已经重建了会话
+		created = false
+	}
+	if s.claimField == "" {
+		return created, nil
+	}
+	if !created {
+		current, err := s.rdb.HGet(ctx, s.key, s.claimField).Result()
+		switch {
+		case err == nil && current == s.userInfo.ConnID:
+			return false, nil
+		case err == nil:
+			return false, fmt.Errorf("%w: conn=%s", ErrDeviceConflict, current)
+		case !errors.Is(err, redis.Nil):
+			return false, err
+		}
+	}
+	// 重建期间用户可能已经建立了新连接，只占用空闲的槽位，不取代新连接
+	if err := s.claim(ctx, PolicyRejectNew, s.limit); err != nil {
+		return created, err
+	}
+	return true, nil
+}
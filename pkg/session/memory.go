@@ -0,0 +1,278 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+var _ Store = &MemoryStore{}
+
+// MemoryStore 基于内存的会话存储，语义与Redis实现一致：同一用户的第二次 Build 返回 isNew=false，
+// 同一用户的各个会话共享字段，但各自持有 Build 时传入的 UserInfo（例如每个连接协商的编解码器不同）。
+// 会话只存在于本进程中，用户的最后一个连接释放或销毁会话时即被删除，不按TTL过期，也不按多连接策略占用槽位；
+// 需要通知的字段变更直接分发给本进程的 ChangeWatcher。只适用于单节点部署、本地开发和测试
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[[2]int64]*memoryFields
+
+	notifyFields map[string]struct{} // 变更时需要通知在线连接的字段集合
+	watcher      *ChangeWatcher      // 没有需要通知的字段时为 nil
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[[2]int64]*memoryFields)}
+}
+
+// newMemoryBackend 按 session.notifyFields 配置创建内存后端，字段变更在写入的协程中同步分发
+func newMemoryBackend(i do.Injector) (Store, error) {
+	cfg, err := do.Invoke[config.SessionConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	store := NewMemoryStore()
+	if len(cfg.NotifyFields) == 0 {
+		return store, nil
+	}
+	if store.watcher, err = do.Invoke[*ChangeWatcher](i); err != nil {
+		return nil, err
+	}
+	store.notifyFields = make(map[string]struct{}, len(cfg.NotifyFields))
+	for _, field := range cfg.NotifyFields {
+		store.notifyFields[field] = struct{}{}
+	}
+	return store, nil
+}
+
+func (b *MemoryStore) Build(_ context.Context, info UserInfo) (Session, bool, error) {
+	if info.ConnID == "" {
+		info.ConnID = uuid.NewString()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := [2]int64{info.BizID, info.UserID}
+	if f, ok := b.sessions[key]; ok {
+		f.conns[info.ConnID] = struct{}{}
+		return &memorySession{info: info, builder: b, memoryFields: f}, false, nil
+	}
+	// 与Redis实现一样写入登录时间和访客标记
+	f := &memoryFields{
+		fields: map[string]string{LoginTimeField: time.Now().Format(time.RFC3339Nano)},
+		conns:  map[string]struct{}{info.ConnID: {}},
+	}
+	if info.Guest {
+		f.fields[GuestField] = "1"
+	}
+	b.sessions[key] = f
+	return &memorySession{info: info, builder: b, memoryFields: f}, true, nil
+}
+
+func (b *MemoryStore) Find(_ context.Context, bizID, userID int64) (Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, ok := b.sessions[[2]int64{bizID, userID}]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &memorySession{info: UserInfo{BizID: bizID, UserID: userID}, builder: b, memoryFields: f}, nil
+}
+
+func (b *MemoryStore) Missing(_ context.Context, users []UserInfo) ([]UserInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var missing []UserInfo
+	for _, u := range users {
+		if _, ok := b.sessions[[2]int64{u.BizID, u.UserID}]; !ok {
+			missing = append(missing, u)
+		}
+	}
+	return missing, nil
+}
+
+// Len 返回当前存在的会话数量
+func (b *MemoryStore) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.sessions)
+}
+
+// detach 移除连接对会话的引用，用户已经没有连接时删除会话
+func (b *MemoryStore) detach(s *memorySession) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(s.conns, s.info.ConnID)
+	key := [2]int64{s.info.BizID, s.info.UserID}
+	if len(s.conns) == 0 && b.sessions[key] == s.memoryFields {
+		delete(b.sessions, key)
+	}
+}
+
+// notify 把需要通知的字段变更分发给 ChangeWatcher 的处理器，调用方不能持有会话的锁
+func (b *MemoryStore) notify(ctx context.Context, info UserInfo, fields map[string]string) {
+	if b.watcher == nil {
+		return
+	}
+	for k, v := range fields {
+		if _, ok := b.notifyFields[k]; ok {
+			b.watcher.dispatch(ctx, FieldChange{BizID: info.BizID, UserID: info.UserID, Key: k, Value: v})
+		}
+	}
+}
+
+// memoryFields 同一用户的会话字段，相当于Redis中的会话哈希
+type memoryFields struct {
+	mu     sync.RWMutex
+	fields map[string]string
+	conns  map[string]struct{} // 引用会话的连接ID，相当于Redis中的连接记录，由 MemoryStore.mu 保护
+}
+
+type memorySession struct {
+	*memoryFields
+	info    UserInfo
+	builder *MemoryStore
+}
+
+func (s *memorySession) UserInfo() UserInfo { return s.info }
+
+func (s *memorySession) Get(_ context.Context, key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.fields[key]
+	if !ok {
+		return "", redis.Nil
+	}
+	return v, nil
+}
+
+func (s *memorySession) Set(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	s.fields[key] = value
+	s.mu.Unlock()
+	s.builder.notify(ctx, s.info, map[string]string{key: value})
+	return nil
+}
+
+func (s *memorySession) GetAll(_ context.Context) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.fields), nil
+}
+
+func (s *memorySession) SetMulti(ctx context.Context, fields map[string]string) error {
+	s.mu.Lock()
+	maps.Copy(s.fields, fields)
+	s.mu.Unlock()
+	s.builder.notify(ctx, s.info, fields)
+	return nil
+}
+
+func (s *memorySession) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	var n int64
+	if v, ok := s.fields[key]; ok {
+		var err error
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			s.mu.Unlock()
+			return 0, fmt.Errorf("字段 %s 的值不是整数: %w", key, err)
+		}
+	}
+	n += delta
+	val := strconv.FormatInt(n, 10)
+	s.fields[key] = val
+	s.mu.Unlock()
+	s.builder.notify(ctx, s.info, map[string]string{key: val})
+	return n, nil
+}
+
+func (s *memorySession) CompareAndSet(ctx context.Context, key, old, value string) (bool, error) {
+	s.mu.Lock()
+	if s.fields[key] != old {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.fields[key] = value
+	s.mu.Unlock()
+	s.builder.notify(ctx, s.info, map[string]string{key: value})
+	return true, nil
+}
+
+func (s *memorySession) Update(ctx context.Context, fields map[string]string) error {
+	s.builder.mu.Lock()
+	_, ok := s.builder.sessions[[2]int64{s.info.BizID, s.info.UserID}]
+	s.builder.mu.Unlock()
+	if !ok {
+		return ErrSessionNotFound
+	}
+	s.mu.Lock()
+	maps.Copy(s.fields, fields)
+	s.mu.Unlock()
+	s.builder.notify(ctx, s.info, fields)
+	return nil
+}
+
+// Touch 内存会话不会过期，会话已被删除时返回 ErrSessionNotFound
+func (s *memorySession) Touch(_ context.Context) error {
+	s.builder.mu.Lock()
+	defer s.builder.mu.Unlock()
+	if _, ok := s.builder.sessions[[2]int64{s.info.BizID, s.info.UserID}]; !ok {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Release 释放连接对会话的引用，用户在本进程中已经没有连接时删除会话，避免会话随连接过的用户数无限增长
+// 内存会话不按多连接策略占用槽位
+func (s *memorySession) Release(_ context.Context) error {
+	if s.info.ConnID != "" {
+		s.builder.detach(s)
+	}
+	return nil
+}
+
+// Restore 会话已被删除时以空字段重建，内存会话不按多连接策略占用槽位
+func (s *memorySession) Restore(_ context.Context) (bool, error) {
+	s.builder.mu.Lock()
+	defer s.builder.mu.Unlock()
+	key := [2]int64{s.info.BizID, s.info.UserID}
+	if f, ok := s.builder.sessions[key]; ok {
+		if f != s.memoryFields {
+			return false, nil
+		}
+		_, attached := f.conns[s.info.ConnID]
+		f.conns[s.info.ConnID] = struct{}{}
+		return !attached, nil
+	}
+	s.mu.Lock()
+	s.fields = make(map[string]string)
+	s.mu.Unlock()
+	s.conns = map[string]struct{}{s.info.ConnID: {}}
+	s.builder.sessions[key] = s.memoryFields
+	return true, nil
+}
+
+// TakenOver 内存会话不会接管其它连接
+func (s *memorySession) TakenOver() string { return "" }
+
+// Pipeline 内存会话没有往返开销，逐个执行读写
+func (s *memorySession) Pipeline() *Pipeline { return NewPipeline(s) }
+
+// Destroy 与Redis实现一样，连接持有的会话只移除当前连接的引用，用户还有其它连接时保留会话；
+// Find 查找的会话不属于任何连接，删除整个会话
+func (s *memorySession) Destroy(_ context.Context) error {
+	if s.info.ConnID != "" {
+		s.builder.detach(s)
+		return nil
+	}
+	s.builder.mu.Lock()
+	defer s.builder.mu.Unlock()
+	delete(s.builder.sessions, [2]int64{s.info.BizID, s.info.UserID})
+	return nil
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// ChangeChannel 会话字段变更通知使用的Redis Pub/Sub频道
+// 所有网关节点都订阅该频道，由持有用户连接的节点负责把变更下发给客户端
+const ChangeChannel = "gateway:session:changes"
+
+// FieldChange 描述一次会话字段变更
+type FieldChange struct {
+	BizID  int64  `json:"bizId"`
+	UserID int64  `json:"userId"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	// 以下字段只在连接槽位变更时设置
+	DeviceID string `json:"deviceId,omitempty"` // 取代旧连接的新连接的设备ID
+	Takeover bool   `json:"takeover,omitempty"` // takeover 策略下的接管，旧连接需要先收到接管通知
+}
+
+// ChangeHandler 处理会话字段变更的回调函数
+type ChangeHandler func(ctx context.Context, change FieldChange)
+
+// publishChange 在给定的命令执行器上发布一次字段变更
+func publishChange(ctx context.Context, rdb redis.Cmdable, change FieldChange) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return rdb.Publish(ctx, ChangeChannel, payload).Err()
+}
+
+// ChangeWatcher 订阅会话字段变更通知并分发给已注册的处理器
+// 变更可能来自任意节点（后端通过API修改或直接调用Session.Set），因此通过Redis Pub/Sub跨节点传播
+type ChangeWatcher struct {
+	rdb    redis.UniversalClient
+	logger *log.Logger
+
+	mu       sync.RWMutex
+	handlers []ChangeHandler
+}
+
+func NewChangeWatcher(i do.Injector) (*ChangeWatcher, error) {
+	rdb, err := do.Invoke[redis.UniversalClient](i)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeWatcher{
+		rdb:    rdb,
+		logger: logger,
+	}, nil
+}
+
+// OnChange 注册一个变更处理器，处理器按注册顺序被调用
+func (w *ChangeWatcher) OnChange(h ChangeHandler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, h)
+}
+
+// Run 订阅变更频道并持续分发变更事件，直到ctx被取消
+// 此方法会阻塞运行，需要在独立的goroutine中调用
+func (w *ChangeWatcher) Run(ctx context.Context) error {
+	pubsub := w.rdb.Subscribe(ctx, ChangeChannel)
+	defer pubsub.Close()
+
+	// 等待订阅确认，确保订阅建立失败时能及时返回错误
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var change FieldChange
+			if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+				w.logger.Warn("无法解析会话变更通知", slog.String("payload", msg.Payload), slog.Any("error", err))
+				continue
+			}
+			w.dispatch(ctx, change)
+		}
+	}
+}
+
+func (w *ChangeWatcher) dispatch(ctx context.Context, change FieldChange) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, h := range w.handlers {
+		h(ctx, change)
+	}
+}
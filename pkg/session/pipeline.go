@@ -0,0 +1,283 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cmd 流水线中一次会话读写的结果，所属的流水线执行之后可用
+type Cmd struct {
+	key   string
+	value string
+	set   bool
+	val   string
+	err   error
+
+	// 以下字段只用于自动合并的读写
+	ctx  context.Context
+	done chan struct{}
+}
+
+// Val 返回 Get 读到的值
+func (c *Cmd) Val() string { return c.val }
+
+// Err 返回读写的错误，Get 的字段不存在时返回 redis.Nil
+func (c *Cmd) Err() error { return c.err }
+
+// Result 返回 Get 读到的值和错误
+func (c *Cmd) Result() (string, error) { return c.val, c.err }
+
+// Pipeline 把对同一会话的多次读写合并为一次Redis往返，按加入的顺序执行
+//
+//	p := ss.Pipeline()
+//	role := p.Get("role")
+//	p.Set("lastCmd", "upstream")
+//	if err := p.Exec(ctx); err != nil { ... }
+//	v, err := role.Result()
+//
+// Pipeline 不是并发安全的，同一个 Pipeline 只能执行一次；多个协程可以各自创建 Pipeline
+type Pipeline struct {
+	exec func(ctx context.Context, cmds []*Cmd) error
+	cmds []*Cmd
+}
+
+// NewPipeline 创建逐个调用 s.Get 和 s.Set 的流水线，供不支持批量执行的 Session 实现使用
+func NewPipeline(s Session) *Pipeline {
+	return &Pipeline{exec: func(ctx context.Context, cmds []*Cmd) error {
+		var first error
+		for _, c := range cmds {
+			if c.set {
+				c.err = s.Set(ctx, c.key, c.value)
+			} else {
+				c.val, c.err = s.Get(ctx, c.key)
+			}
+			if first == nil && c.err != nil && !errors.Is(c.err, redis.Nil) {
+				first = c.err
+			}
+		}
+		return first
+	}}
+}
+
+// Get 加入一次字段读取
+func (p *Pipeline) Get(key string) *Cmd {
+	c := &Cmd{key: key}
+	p.cmds = append(p.cmds, c)
+	return c
+}
+
+// Set 加入一次字段写入，与 Session.Set 一样续期会话并发布需要通知的字段变更
+func (p *Pipeline) Set(key, value string) *Cmd {
+	c := &Cmd{key: key, value: value, set: true}
+	p.cmds = append(p.cmds, c)
+	return c
+}
+
+// Len 返回已加入的读写数
+func (p *Pipeline) Len() int { return len(p.cmds) }
+
+// Exec 执行所有读写，返回第一个失败的读写的错误；Get 的字段不存在不视为失败，通过 Cmd.Err 判断
+func (p *Pipeline) Exec(ctx context.Context) error {
+	if len(p.cmds) == 0 {
+		return nil
+	}
+	return p.exec(ctx, p.cmds)
+}
+
+// Pipeline 实现 Session 接口
+func (s *redisSession) Pipeline() *Pipeline {
+	return &Pipeline{exec: s.exec}
+}
+
+// exec 在一次Redis往返中执行 cmds，包含写入时以事务执行，保证变更通知不会早于写入生效
+func (s *redisSession) exec(ctx context.Context, cmds []*Cmd) error {
+	writes, notify := false, false
+	for _, c := range cmds {
+		if c.set {
+			writes = true
+			_, ok := s.notifyFields[c.key]
+			notify = notify || ok
+		}
+	}
+	run := s.rdb.Pipelined
+	if notify || (writes && s.ttl > 0) {
+		run = s.rdb.TxPipelined
+	}
+
+	results := make([]redis.Cmder, len(cmds))
+	var buildErr error
+	_, err := run(ctx, func(pipe redis.Pipeliner) error {
+		for i, c := range cmds {
+			if c.set {
+				results[i] = pipe.HSet(ctx, s.key, c.key, c.value)
+			} else {
+				// 流水线中第一个命令返回 redis.Nil 时 go-redis 会把它设置为其余成功命令的错误，
+				// 因此用单个字段的 HMGET 代替 HGET，字段不存在时返回 nil 而不是错误
+				results[i] = pipe.HMGet(ctx, s.key, c.key)
+			}
+		}
+		if writes && s.ttl > 0 {
+			pipe.PExpire(ctx, s.key, s.ttl)
+		}
+		for _, c := range cmds {
+			if _, ok := s.notifyFields[c.key]; !ok || !c.set {
+				continue
+			}
+			if buildErr = publishChange(ctx, pipe, FieldChange{
+				BizID:  s.userInfo.BizID,
+				UserID: s.userInfo.UserID,
+				Key:    c.key,
+				Value:  c.value,
+			}); buildErr != nil {
+				return buildErr
+			}
+		}
+		return nil
+	})
+	if buildErr != nil {
+		// 构造流水线时失败，所有读写都没有执行
+		for _, c := range cmds {
+			c.err = buildErr
+		}
+		return buildErr
+	}
+
+	var first error
+	for i, c := range cmds {
+		switch r := results[i].(type) {
+		case *redis.SliceCmd:
+			c.val, c.err = hmgetValue(r)
+		case *redis.IntCmd:
+			c.err = r.Err()
+		}
+		if first == nil && c.err != nil && !errors.Is(c.err, redis.Nil) {
+			first = c.err
+		}
+	}
+	if first == nil && err != nil && !errors.Is(err, redis.Nil) {
+		first = err
+	}
+	return first
+}
+
+// hmgetValue 把单个字段的 HMGET 结果转换为 HGET 的语义
+func hmgetValue(cmd *redis.SliceCmd) (string, error) {
+	vals, err := cmd.Result()
+	if err != nil {
+		return "", err
+	}
+	if len(vals) == 0 || vals[0] == nil {
+		return "", redis.Nil
+	}
+	v, _ := vals[0].(string)
+	return v, nil
+}
+
+// batcher 把同一会话在 window 内由多个协程（中间件、转发器、业务钩子等）各自发起的 Get 和 Set
+// 自动合并为一个流水线，同一条上行消息触发的多次会话读写只需要一次Redis往返
+//
+// 第一个读写开始计时，window 到期或累计 maxBatch 个读写时执行；每个读写最多多等待 window
+type batcher struct {
+	s        *redisSession
+	window   time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending []*Cmd
+	timer   *time.Timer
+}
+
+func newBatcher(s *redisSession, window time.Duration, maxBatch int) *batcher {
+	return &batcher{s: s, window: window, maxBatch: maxBatch}
+}
+
+// do 把一次读写加入当前批次并等待执行结果，ctx 结束时立即返回，尚未执行的读写不再执行
+func (b *batcher) do(ctx context.Context, c *Cmd) (string, error) {
+	c.ctx = ctx
+	c.done = make(chan struct{})
+
+	b.mu.Lock()
+	b.pending = append(b.pending, c)
+	var full []*Cmd
+	switch {
+	case len(b.pending) >= b.maxBatch:
+		full = b.take()
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	b.mu.Unlock()
+	if full != nil {
+		// 凑满批次的协程直接执行，不再等待计时器
+		b.run(full)
+	}
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// take 取出当前批次，调用方需要持有 mu
+func (b *batcher) take() []*Cmd {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	cmds := b.pending
+	b.pending = nil
+	return cmds
+}
+
+func (b *batcher) flush() {
+	b.mu.Lock()
+	cmds := b.take()
+	b.mu.Unlock()
+	if len(cmds) > 0 {
+		b.run(cmds)
+	}
+}
+
+// run 执行一个批次，跳过调用方已经放弃的读写
+func (b *batcher) run(cmds []*Cmd) {
+	live := cmds[:0:0]
+	for _, c := range cmds {
+		if err := c.ctx.Err(); err != nil {
+			c.err = err
+			close(c.done)
+			continue
+		}
+		live = append(live, c)
+	}
+	if len(live) == 0 {
+		return
+	}
+	ctx, cancel := batchContext(live)
+	defer cancel()
+	_ = b.s.exec(ctx, live)
+	for _, c := range live {
+		close(c.done)
+	}
+}
+
+// batchContext 返回执行批次使用的 ctx：保留第一个读写的 ctx 中的值（链路追踪等），
+// 所有读写都设置了截止时间时以最晚的截止时间为准，不会因为其中一个调用方放弃而中止整个批次
+func batchContext(cmds []*Cmd) (context.Context, context.CancelFunc) {
+	ctx := context.WithoutCancel(cmds[0].ctx)
+	var latest time.Time
+	for _, c := range cmds {
+		d, ok := c.ctx.Deadline()
+		if !ok {
+			return context.WithCancel(ctx)
+		}
+		if d.After(latest) {
+			latest = d
+		}
+	}
+	return context.WithDeadline(ctx, latest)
+}
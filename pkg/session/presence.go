@@ -0,0 +1,136 @@
+package session
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// connRecordPrefix 会话中记录每个连接的元数据的字段前缀，字段值为 ConnRecord 的 JSON
+// 连接建立时与槽位一起写入，连接关闭释放槽位时删除
+const connRecordPrefix = "connection:"
+
+// ConnRecord 会话中记录的一个在线连接
+type ConnRecord struct {
+	ConnID   string `json:"connId"`
+	Node     string `json:"node"`               // 持有连接的网关节点
+	DeviceID string `json:"deviceId,omitempty"` // 客户端上报的设备ID
+	Since    int64  `json:"since"`              // 连接建立的时间 (Unix 毫秒)
+	// Fingerprint 设备指纹，按指纹统计同时在线的设备数
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Presence 由会话记录得到的用户在线状态
+//
+// 节点异常退出时来不及删除的连接记录会保留到会话过期，
+// 需要精确判断时可以结合 Nodes 中的节点是否存活
+type Presence struct {
+	BizID   int64        `json:"bizId"`
+	UserID  int64        `json:"userId"`
+	Online  bool         `json:"online"`
+	Since   int64        `json:"since,omitempty"` // 最早的在线连接建立的时间 (Unix 毫秒)，离线时为 0
+	Nodes   []string     `json:"nodes"`           // 持有用户连接的节点
+	Devices int          `json:"devices"`         // 在线的设备数，不上报设备ID的连接视为同一个设备
+	Conns   []ConnRecord `json:"conns"`           // 在线的连接，按建立时间排序
+}
+
+// PresenceReader 查询用户的在线状态，不会创建或续期会话
+type PresenceReader interface {
+	// Presence 返回一个用户的在线状态，会话不存在时返回离线状态而不是错误
+	Presence(ctx context.Context, bizID, userID int64) (Presence, error)
+	// Presences 在一次Redis往返中查询同一业务方的多个用户，结果与 userIDs 一一对应
+	Presences(ctx context.Context, bizID int64, userIDs []int64) ([]Presence, error)
+}
+
+// RedisPresenceReader 是 PresenceReader 接口的Redis实现，从会话哈希中的连接记录和节点记录推导在线状态
+type RedisPresenceReader struct {
+	rdb redis.Cmdable
+}
+
+func NewRedisPresenceReader(i do.Injector) (PresenceReader, error) {
+	rdb, err := do.Invoke[redis.Cmdable](i)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisPresenceReader{rdb: rdb}, nil
+}
+
+func (r *RedisPresenceReader) Presence(ctx context.Context, bizID, userID int64) (Presence, error) {
+	fields, err := r.rdb.HGetAll(ctx, fmt.Sprintf(keyFormat, bizID, userID)).Result()
+	if err != nil {
+		return Presence{}, err
+	}
+	return presenceOf(bizID, userID, fields), nil
+}
+
+func (r *RedisPresenceReader) Presences(ctx context.Context, bizID int64, userIDs []int64) ([]Presence, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	cmds := make([]*redis.MapStringStringCmd, len(userIDs))
+	if _, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, userID := range userIDs {
+			cmds[i] = pipe.HGetAll(ctx, fmt.Sprintf(keyFormat, bizID, userID))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	res := make([]Presence, len(userIDs))
+	for i, userID := range userIDs {
+		res[i] = presenceOf(bizID, userID, cmds[i].Val())
+	}
+	return res, nil
+}
+
+// presenceOf 从会话哈希的全部字段推导在线状态
+func presenceOf(bizID, userID int64, fields map[string]string) Presence {
+	p := Presence{BizID: bizID, UserID: userID, Nodes: []string{}, Conns: []ConnRecord{}}
+	devices := make(map[string]struct{})
+	for k, v := range fields {
+		switch {
+		case strings.HasPrefix(k, connRecordPrefix):
+			var rec ConnRecord
+			if json.Unmarshal([]byte(v), &rec) != nil {
+				continue
+			}
+			p.Conns = append(p.Conns, rec)
+			devices[rec.DeviceID] = struct{}{}
+			if rec.Node != "" && !slices.Contains(p.Nodes, rec.Node) {
+				p.Nodes = append(p.Nodes, rec.Node)
+			}
+		case strings.HasPrefix(k, nodeFieldPrefix):
+			// 多节点部署时按连接计数的节点记录，与连接记录互为补充
+			if node := strings.TrimPrefix(k, nodeFieldPrefix); !slices.Contains(p.Nodes, node) {
+				p.Nodes = append(p.Nodes, node)
+			}
+		}
+	}
+	slices.SortFunc(p.Conns, func(a, b ConnRecord) int { return cmp.Compare(a.Since, b.Since) })
+	slices.Sort(p.Nodes)
+	p.Online = len(p.Conns) > 0 || len(p.Nodes) > 0
+	p.Devices = len(devices)
+	if len(p.Conns) > 0 {
+		p.Since = p.Conns[0].Since
+	}
+	return p
+}
+
+// connRecord 返回连接在会话中的记录字段和值
+func (s *redisSession) connRecord() (string, string) {
+	b, _ := json.Marshal(ConnRecord{
+		ConnID:      s.userInfo.ConnID,
+		Node:        s.nodeID,
+		DeviceID:    s.userInfo.DeviceID,
+		Since:       time.Now().UnixMilli(),
+		Fingerprint: s.userInfo.Fingerprint,
+	})
+	return connRecordPrefix + s.userInfo.ConnID, string(b)
+}
@@ -6,7 +6,20 @@ import (
 
 // Package 定义 Session 包的服务包，使用 Package Loading 模式
 var Package = do.Package(
-	// Session Builder 使用懒加载，因为它依赖于 Redis 客户端
-	// 只有在需要创建 session 时才初始化
-	do.Lazy(NewRedisSessionBuilder),
+	// 会话存储后端，按 session.backend 配置选用 Redis、内存或自定义的后端
+	do.Lazy(NewStore),
+	// Session Builder 使用懒加载，只有在需要创建 session 时才初始化存储后端
+	do.Lazy(NewBuilder),
+	// Session Finder 只查找已存在的会话，供管理API使用
+	do.Lazy(NewFinder),
+	// 批量检查会话是否丢失，供连接层定期检查本节点连接的会话
+	do.Lazy(NewChecker),
+	// 会话字段变更订阅器，由连接层注册处理器后启动
+	do.Lazy(NewChangeWatcher),
+	// 记录用户连接所在的节点，用于跨节点推送路由
+	do.Lazy(NewRedisLocator),
+	// 在线状态查询，从会话中的连接记录推导
+	do.Lazy(NewRedisPresenceReader),
+	// 在线设备查询和移除，同样从会话中的连接记录推导
+	do.Lazy(NewRedisDeviceManager),
 )
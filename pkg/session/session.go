@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/samber/do/v2"
 )
@@ -13,6 +15,12 @@ import (
 const (
 	// keyFormat 定义了Session在Redis中的存储键格式，设为常量以方便管理和复用。
 	keyFormat = "gateway:session:bizId:%d:userId:%d"
+
+	// GuestField 访客会话创建时写入的字段，值为 "1"，业务方据此区分匿名的访客和已认证的用户
+	GuestField = "guest"
+
+	// LoginTimeField 会话创建时写入的登录时间字段，RFC3339Nano 格式
+	LoginTimeField = "loginTime"
 )
 
 var (
@@ -27,20 +35,73 @@ var (
 	// ErrDestroySessionFailed 表示销毁Session时发生错误。
 	ErrDestroySessionFailed = errors.New("销毁session失败")
 
+	// ErrSessionNotFound 表示要查找的Session不存在。
+	ErrSessionNotFound = errors.New("session不存在")
+
 	// luaSetSessionIfNotExist 脚本用于原子性地创建Session。
 	// 只有当Key不存在时，才会执行HSET操作。
+	// ARGV[1] 为会话的过期时间（毫秒），大于0时无论是否新建都会重置过期时间，其余参数为初始字段。
 	// 返回1表示创建成功，返回0表示Key已存在。
-	// 使用 unpack(ARGV) 需要 Redis 4.0.0+，性能优于循环HSET。
-	luaSetSessionIfNotExist = redis.NewScript(`
+	// 使用 unpack(ARGV, 2) 需要 Redis 4.0.0+，性能优于循环HSET。
+	// luaUpdateIfExist 脚本只在Key存在时执行HSET，ARGV[1] 为过期时间（毫秒），大于0时同时续期。
+	// 返回1表示写入成功，返回0表示Key不存在。
+	luaUpdateIfExist = redis.NewScript(`
 if redis.call('EXISTS', KEYS[1]) == 0 then
-    redis.call('HSET', KEYS[1], unpack(ARGV))
-    return 1
-else
     return 0
 end
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+local ttl = tonumber(ARGV[1])
+if ttl > 0 then
+    redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+	// luaDestroySession 脚本删除连接持有的会话：先像 luaReleaseConn 一样删除当前连接的记录 (KEYS[3]) 并释放它占用的槽位 (KEYS[2])，
+	// 会话中还有其它连接的槽位 (ARGV[2] 字段或 ARGV[3] 前缀)、连接记录 (ARGV[4] 前缀) 或节点记录 (ARGV[5] 前缀) 时保留会话，
+	// 否则删除整个会话。用户在其它节点上仍在线时，一个节点下线或关闭空闲连接不会删除这些连接的槽位和推送路由。
+	// 返回1表示会话被删除，返回0表示会话被保留。
+	luaDestroySession = redis.NewScript(`
+redis.call('HDEL', KEYS[1], KEYS[3])
+if redis.call('HGET', KEYS[1], KEYS[2]) == ARGV[1] then
+    redis.call('HDEL', KEYS[1], KEYS[2])
+end
+local fields = redis.call('HKEYS', KEYS[1])
+for _, field in ipairs(fields) do
+    if field == ARGV[2] then
+        return 0
+    end
+    for i = 3, 5 do
+        if string.sub(field, 1, #ARGV[i]) == ARGV[i] then
+            return 0
+        end
+    end
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+	luaSetSessionIfNotExist = redis.NewScript(`
+local created = 0
+if redis.call('EXISTS', KEYS[1]) == 0 then
+    redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+    created = 1
+end
+local ttl = tonumber(ARGV[1])
+if ttl > 0 then
+    redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return created
 `)
 )
 
+// Scripts 返回会话使用的 Lua 脚本，启动预热时提前加载到 Redis，
+// 避免新节点上的首批握手因 NOSCRIPT 从 EVALSHA 回退到 EVAL
+func Scripts() []*redis.Script {
+	return []*redis.Script{luaSetSessionIfNotExist, luaUpdateIfExist, luaClaimConn, luaReleaseConn, luaDestroySession, luaDetachNode, luaCompareAndSet}
+}
+
+// Session 用户会话，所有方法都可以被多个协程并发调用
 type Session interface {
 	// UserInfo 返回当前Session关联的用户身份信息。
 	UserInfo() UserInfo
@@ -50,30 +111,71 @@ type Session interface {
 	Get(ctx context.Context, key string) (string, error)
 	// Set 向Session中设置一个字段键值对。
 	Set(ctx context.Context, key, value string) error
+	// GetAll 在一次往返中读取Session的全部业务字段，不包括网关内部使用的连接槽位和连接记录。
+	// Session不存在时返回空map。
+	GetAll(ctx context.Context) (map[string]string, error)
+	// SetMulti 在一次往返中原子地写入多个字段，Session不存在时与 Set 一样会创建它。
+	SetMulti(ctx context.Context, fields map[string]string) error
+	// Incr 把字段的整数值原子地加上 delta 并返回新值，字段不存在时视为0；
+	// 字段值不是整数时返回Redis的错误。用于未读数、最后确认的序号等计数器。
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
+	// CompareAndSet 只在字段的当前值等于 old 时写入 value，返回是否写入；字段不存在视为空字符串。
+	CompareAndSet(ctx context.Context, key, old, value string) (bool, error)
 	// Destroy 销毁整个Session。
+	// 连接持有的Session只释放当前连接的槽位和记录，用户还有其它连接（包括其它节点上的连接）时保留Session。
 	Destroy(ctx context.Context) error
+	// Update 只在Session存在时写入多个字段，Session已被删除时返回 ErrSessionNotFound 而不会重新创建它。
+	// 用于握手之后异步补充会话数据，避免连接已经关闭、会话已被删除后又被写回。
+	Update(ctx context.Context, fields map[string]string) error
+	// Touch 续期Session，重置其过期时间。
+	// 未配置过期时间时不做任何事；Session已过期或被删除时返回 ErrSessionNotFound。
+	Touch(ctx context.Context) error
+	// Release 连接关闭时释放它在Session中占用的槽位，槽位已被新连接取代时不做任何事。
+	Release(ctx context.Context) error
+	// Restore 在Session丢失（被删除、过期或故障切换时丢失数据）后重建它，并重新为连接占用槽位。
+	// Session和连接的记录都存在时不做任何事并返回 false；槽位已被其它连接占用时返回 ErrDeviceConflict，不会取代对方。
+	Restore(ctx context.Context) (bool, error)
+	// TakenOver 返回建立会话时在 takeover 策略下被当前连接接管的旧连接ID，没有接管其它连接时返回空字符串。
+	TakenOver() string
+	// Pipeline 创建一个流水线，把多次 Get 和 Set 合并为一次Redis往返执行。
+	Pipeline() *Pipeline
 }
 
 // UserInfo 结构体定义了用户会话信息。
 type UserInfo struct {
-	BizID     int64 `json:"bizId"`     // 业务域或者是租户ID
-	UserID    int64 `json:"userId"`    // 用户ID
-	AutoClose bool  `json:"autoClose"` // 是否允许空闲时自动关闭连接
+	BizID     int64  `json:"bizId"`              // 业务域或者是租户ID
+	UserID    int64  `json:"userId"`             // 用户ID
+	AutoClose bool   `json:"autoClose"`          // 是否允许空闲时自动关闭连接
+	Codec     string `json:"codec,omitempty"`    // 握手时协商的消息编解码器名称，只对当前连接有效
+	DeviceID  string `json:"deviceId,omitempty"` // 客户端上报的设备ID，多设备策略下同一设备只保留一个连接
+	ConnID    string `json:"connId,omitempty"`   // 连接ID，创建Session时生成，与连接的 Link ID 一致
+	Guest     bool   `json:"guest,omitempty"`    // 是否是未认证的访客，访客的用户ID由网关生成
+	// Fingerprint 设备指纹，用于统计同时在线的设备数，握手时取自令牌、请求头或设备ID
+	Fingerprint string `json:"fingerprint,omitempty"`
 }
 
 // redisSession 是 Session 接口的Redis实现。
 type redisSession struct {
-	userInfo UserInfo
-	rdb      redis.Cmdable // Redis客户端的抽象接口
-	key      string
+	userInfo     UserInfo
+	rdb          redis.Cmdable // Redis客户端的抽象接口
+	key          string
+	notifyFields map[string]struct{} // 变更时需要发布通知的字段集合，由Builder共享
+	ttl          time.Duration       // 会话的过期时间，0 表示永不过期
+	claimField   string              // 连接在会话中占用的槽位字段，Finder 查找的会话为空
+	limit        deviceLimit         // 业务方的同时在线设备数上限，重建会话时重新占用槽位使用
+	takenOver    string              // takeover 策略下被当前连接接管的旧连接ID
+	nodeID       string              // 持有连接的网关节点，写入连接记录
+	batcher      *batcher            // 自动合并并发的 Get 和 Set，未启用时为 nil
 }
 
 // newRedisSession 创建一个新的Redis会话实例。
-func newRedisSession(userInfo UserInfo, rdb redis.Cmdable) *redisSession {
+func newRedisSession(userInfo UserInfo, rdb redis.Cmdable, notifyFields map[string]struct{}, ttl time.Duration) *redisSession {
 	return &redisSession{
-		userInfo: userInfo,                                                // 保存用户信息
-		rdb:      rdb,                                                     // 保存Redis客户端
-		key:      fmt.Sprintf(keyFormat, userInfo.BizID, userInfo.UserID), // 根据业务ID和用户ID生成唯一的Redis键
+		userInfo:     userInfo,                                                // 保存用户信息
+		rdb:          rdb,                                                     // 保存Redis客户端
+		key:          fmt.Sprintf(keyFormat, userInfo.BizID, userInfo.UserID), // 根据业务ID和用户ID生成唯一的Redis键
+		notifyFields: notifyFields,
+		ttl:          ttl,
 	}
 }
 
@@ -83,7 +185,11 @@ func (s *redisSession) initialize(ctx context.Context) error {
 	// bizId和userId已在key中，这里不再冗余存储。
 	// 使用RFC3339Nano格式存储时间，确保一致性。
 	args := []any{
-		"loginTime", time.Now().Format(time.RFC3339Nano),
+		s.ttl.Milliseconds(),
+		LoginTimeField, time.Now().Format(time.RFC3339Nano),
+	}
+	if s.userInfo.Guest {
+		args = append(args, GuestField, "1")
 	}
 	// 执行Lua脚本
 	res, err := luaSetSessionIfNotExist.Run(ctx, s.rdb, []string{s.key}, args...).Result()
@@ -108,6 +214,9 @@ func (s *redisSession) initialize(ctx context.Context) error {
 func (s *redisSession) UserInfo() UserInfo { return s.userInfo }
 
 func (s *redisSession) Get(ctx context.Context, key string) (string, error) {
+	if s.batcher != nil {
+		return s.batcher.do(ctx, &Cmd{key: key})
+	}
 	// 如果没有对应的 key，返回 Redis Nil 错误
 	return s.rdb.HGet(ctx, s.key, key).Result()
 }
@@ -117,11 +226,43 @@ func (s *redisSession) Set(ctx context.Context, key, value string) error {
 	// 但传入结构体时它会被 go-redis 序列化成一种默认的字符串格式，这可能不是你期望的。反序列化时会遇到麻烦
 	// 因此这里明确使用string类型，确保数据的可预测性
 	// 返回HSet的原始错误，让调用方处理具体的错误情况
-	return s.rdb.HSet(ctx, s.key, key, value).Err()
+	// 写入视为会话活跃，同时续期
+	if s.batcher != nil {
+		_, err := s.batcher.do(ctx, &Cmd{key: key, value: value, set: true})
+		return err
+	}
+	_, notify := s.notifyFields[key]
+	if !notify && s.ttl <= 0 {
+		return s.rdb.HSet(ctx, s.key, key, value).Err()
+	}
+	// 写入、续期和变更通知放在同一个事务中，保证通知不会早于写入生效
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.key, key, value)
+		if s.ttl > 0 {
+			pipe.PExpire(ctx, s.key, s.ttl)
+		}
+		if !notify {
+			return nil
+		}
+		return publishChange(ctx, pipe, FieldChange{
+			BizID:  s.userInfo.BizID,
+			UserID: s.userInfo.UserID,
+			Key:    key,
+			Value:  value,
+		})
+	})
+	return err
 }
 
 func (s *redisSession) Destroy(ctx context.Context) error {
-	err := s.rdb.Del(ctx, s.key).Err()
+	var err error
+	if s.claimField == "" {
+		// Finder 查找的会话不属于任何连接，删除整个会话
+		err = s.rdb.Del(ctx, s.key).Err()
+	} else {
+		err = luaDestroySession.Run(ctx, s.rdb, []string{s.key, s.claimField, connRecordPrefix + s.userInfo.ConnID},
+			s.userInfo.ConnID, connField, deviceFieldPrefix, connRecordPrefix, nodeFieldPrefix).Err()
+	}
 	if err != nil {
 		// 包装底层错误，提供更清晰的错误链，便于上层调用者识别错误类型
 		return fmt.Errorf("%w: %w", ErrDestroySessionFailed, err)
@@ -129,6 +270,53 @@ func (s *redisSession) Destroy(ctx context.Context) error {
 	return nil
 }
 
+func (s *redisSession) Update(ctx context.Context, fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	args := make([]any, 0, 1+2*len(fields))
+	args = append(args, s.ttl.Milliseconds())
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+	updated, err := luaUpdateIfExist.Run(ctx, s.rdb, []string{s.key}, args...).Int64()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrSessionNotFound
+	}
+	// 写入成功后再发布需要通知的字段变更，保证通知不会早于写入生效
+	for k, v := range fields {
+		if _, ok := s.notifyFields[k]; !ok {
+			continue
+		}
+		if err := publishChange(ctx, s.rdb, FieldChange{
+			BizID:  s.userInfo.BizID,
+			UserID: s.userInfo.UserID,
+			Key:    k,
+			Value:  v,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *redisSession) Touch(ctx context.Context) error {
+	if s.ttl <= 0 {
+		return nil
+	}
+	ok, err := s.rdb.PExpire(ctx, s.key, s.ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
 type Builder interface {
 	// Build 获取或创建一个Session。
 	// 无论Session是新创建的还是已存在的，都会返回一个可用的Session实例。
@@ -136,36 +324,120 @@ type Builder interface {
 	Build(ctx context.Context, info UserInfo) (session Session, isNew bool, err error)
 }
 
-// RedisSessionBuilder 是 Builder 接口的Redis实现。
+// Finder 用于查找已存在的Session，与Builder不同，它永远不会创建新的Session。
+// 主要供管理API等不持有用户连接的组件读写会话使用。
+type Finder interface {
+	// Find 查找指定用户的Session，Session不存在时返回 ErrSessionNotFound。
+	Find(ctx context.Context, bizID, userID int64) (Session, error)
+}
+
+// RedisSessionBuilder 是 Builder 和 Finder 接口的Redis实现。
 // 负责创建和管理Redis会话实例
 type RedisSessionBuilder struct {
-	rdb redis.Cmdable // Redis客户端接口，用于执行Redis命令
+	rdb          redis.Cmdable       // Redis客户端接口，用于执行Redis命令
+	notifyFields map[string]struct{} // 变更时需要通知在线连接的字段集合
+	ttl          time.Duration       // 会话的过期时间，0 表示永不过期
+	policies     devicePolicies      // 按业务方的多连接策略
+	limits       deviceLimits        // 按业务方的同时在线设备数上限
+	window       time.Duration       // 自动合并 Get 和 Set 的时间窗口，0 表示不合并
+	maxBatch     int                 // 一次合并的最大读写数
+	nodeID       string              // 本节点ID，写入连接记录供在线状态查询
 }
 
 func NewRedisSessionBuilder(i do.Injector) (Builder, error) {
+	b, err := newRedisSessionBuilder(i)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func NewRedisSessionFinder(i do.Injector) (Finder, error) {
+	b, err := newRedisSessionBuilder(i)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func newRedisSessionBuilder(i do.Injector) (*RedisSessionBuilder, error) {
 	rdb, err := do.Invoke[redis.Cmdable](i)
 	if err != nil {
 		return nil, err
 	}
+	cfg, err := do.Invoke[config.SessionConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := newDevicePolicies(cfg.Devices)
+	if err != nil {
+		return nil, err
+	}
+	notifyFields := make(map[string]struct{}, len(cfg.NotifyFields))
+	for _, field := range cfg.NotifyFields {
+		notifyFields[field] = struct{}{}
+	}
 	return &RedisSessionBuilder{
-		rdb: rdb,
+		rdb:          rdb,
+		notifyFields: notifyFields,
+		ttl:          time.Duration(cfg.TTL),
+		policies:     policies,
+		limits:       newDeviceLimits(cfg.Devices),
+		window:       time.Duration(cfg.Pipeline.Window),
+		maxBatch:     cfg.Pipeline.MaxBatch,
+		nodeID:       appCfg.InstanceID(),
 	}, nil
 }
 
 // Build 实现 "GetOrCreate" 语义，获取或创建一个会话。
 // 如果会话不存在则创建新会话，如果已存在则返回现有会话。
+// 随后按业务方的多连接策略为连接占用槽位：rejectNew 策略下已有连接时返回 ErrDeviceConflict，
+// 其它策略下取代同一槽位上的旧连接，并通知持有旧连接的节点将其踢下线 (takeover 策略下先下发接管通知)。
+// 业务方限制了同时在线的设备数时，新设备超过上限按配置返回 ErrDeviceLimit 或踢下线最早上线的设备。
 func (r *RedisSessionBuilder) Build(ctx context.Context, userInfo UserInfo) (session Session, isNew bool, err error) {
-	s := newRedisSession(userInfo, r.rdb)
+	if userInfo.ConnID == "" {
+		userInfo.ConnID = uuid.NewString()
+	}
+	policy := r.policies.of(userInfo.BizID)
+	s := newRedisSession(userInfo, r.rdb, r.notifyFields, r.ttl)
+	s.nodeID = r.nodeID
 	err = s.initialize(ctx)
 	switch {
 	case err == nil:
 		// 没有错误，表示会话是新创建的
-		return s, true, nil
+		isNew = true
 	case errors.Is(err, ErrSessionExisted):
 		// 如果错误是 ErrSessionExisted，这不是一个失败，返回现有的session实例
-		return s, false, nil
+		isNew = false
 	default:
 		// 其他所有错误（如redis连接失败、权限错误等）都是真正的失败
 		return nil, false, err
 	}
+	s.claimField = claimField(policy, userInfo)
+	s.limit = r.limits.of(userInfo)
+	if err := s.claim(ctx, policy, s.limit); err != nil {
+		return nil, false, err
+	}
+	// 只有连接持有的会话会被多个组件并发读写，Finder 查找的会话通过 Pipeline 显式合并
+	if r.window > 0 {
+		s.batcher = newBatcher(s, r.window, max(r.maxBatch, 1))
+	}
+	return s, isNew, nil
+}
+
+// Find 查找一个已存在的会话，不会创建新会话。
+func (r *RedisSessionBuilder) Find(ctx context.Context, bizID, userID int64) (Session, error) {
+	s := newRedisSession(UserInfo{BizID: bizID, UserID: userID}, r.rdb, r.notifyFields, r.ttl)
+	n, err := r.rdb.Exists(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return s, nil
 }
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/samber/do/v2"
+)
+
+// 内置的会话存储后端名称
+const (
+	BackendRedis  = "redis"  // 会话存储在Redis中，多节点部署时必须使用
+	BackendMemory = "memory" // 会话只存储在本进程的内存中，用于单节点部署、本地开发和测试
+)
+
+var ErrUnknownBackend = errors.New("未知的会话存储后端")
+
+// Store 会话存储后端，同时提供创建、查找会话和检查会话是否丢失
+// 容器中的 Builder、Finder 和 Checker 都由同一个后端实例提供
+type Store interface {
+	Builder
+	Finder
+	Checker
+}
+
+// StoreFactory 创建会话存储后端的工厂函数，可以从容器中获取后端需要的依赖
+type StoreFactory func(i do.Injector) (Store, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]StoreFactory{
+		BackendRedis: func(i do.Injector) (Store, error) {
+			b, err := newRedisSessionBuilder(i)
+			if err != nil {
+				return nil, err
+			}
+			return redisStore{RedisSessionBuilder: b, RedisSessionChecker: &RedisSessionChecker{rdb: b.rdb}}, nil
+		},
+		BackendMemory: newMemoryBackend,
+	}
+)
+
+// redisStore 组合Redis的会话构建器和会话检查器
+type redisStore struct {
+	*RedisSessionBuilder
+	*RedisSessionChecker
+}
+
+// RegisterBackend 注册自定义会话存储后端，同名后端会被覆盖
+// 嵌入方可以在创建容器前注册自己的后端，然后通过 session.backend 配置按名称选用
+func RegisterBackend(name string, factory StoreFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = factory
+}
+
+// NewStore 按 session.backend 配置创建会话存储后端，未配置时使用Redis
+// 启用 session.degraded 时包装为降级模式，存储不可用时新连接改用内存中的会话
+func NewStore(i do.Injector) (Store, error) {
+	cfg, err := do.Invoke[config.SessionConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	name := cfg.Backend
+	if name == "" {
+		name = BackendRedis
+	}
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
+	}
+	store, err := factory(i)
+	if err != nil || !cfg.Degraded.Enabled || name == BackendMemory {
+		return store, err
+	}
+	logger, err := do.Invoke[*log.Logger](i)
+	if err != nil {
+		return nil, err
+	}
+	return newDegradedStore(store, logger), nil
+}
+
+func NewBuilder(i do.Injector) (Builder, error) {
+	return do.Invoke[Store](i)
+}
+
+func NewFinder(i do.Injector) (Finder, error) {
+	return do.Invoke[Store](i)
+}
+
+func NewChecker(i do.Injector) (Checker, error) {
+	return do.Invoke[Store](i)
+}
@@ -0,0 +1,178 @@
+// Package tracing 网关的 OpenTelemetry 链路追踪
+//
+// 握手、上行消息的处理和转发、下行推送的投递各自生成 span；调用业务后端和业务方webhook时以 W3C Trace Context
+// 传递链路上下文，HTTP 为 traceparent/tracestate 请求头，gRPC 为同名的 metadata。
+// 未启用时 Tracer 生成的 span 不记录任何数据，只透传调用方已有的链路上下文，开销可以忽略。
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/samber/do/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// instrumentationName 网关生成的 span 所属的 instrumentation scope
+const instrumentationName = "github.com/YaoAzure/wsgateway"
+
+// 网关 span 的常用属性
+const (
+	AttrBizID    = attribute.Key("gateway.biz_id")
+	AttrUserID   = attribute.Key("gateway.user_id")
+	AttrDeviceID = attribute.Key("gateway.device_id")
+	AttrLinkID   = attribute.Key("gateway.link_id")
+	AttrCmd      = attribute.Key("gateway.cmd")
+	AttrKey      = attribute.Key("gateway.key")
+	AttrService  = attribute.Key("gateway.service")
+	AttrResult   = attribute.Key("gateway.result")
+)
+
+// propagator 传递链路上下文使用的格式，与是否启用链路追踪无关
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// noopTracer 未启用链路追踪或 Tracer 为 nil 时使用
+var noopTracer = noop.NewTracerProvider().Tracer(instrumentationName)
+
+// Tracer 生成网关的 span，方法都可以在 nil 接收者上安全调用，此时不记录任何数据
+type Tracer struct {
+	tracer   trace.Tracer
+	provider *sdktrace.TracerProvider // 未启用时为 nil
+}
+
+func NewTracer(i do.Injector) (*Tracer, error) {
+	cfg, err := do.Invoke[config.TracingConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return &Tracer{tracer: noopTracer}, nil
+	}
+	appCfg, err := do.Invoke[config.AppConfig](i)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(appCfg.Name),
+		semconv.ServiceInstanceID(appCfg.InstanceID()),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// 上游已经做出采样决定时沿用，否则按采样率采样
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	return &Tracer{tracer: provider.Tracer(instrumentationName), provider: provider}, nil
+}
+
+func newExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(cfg.Headers))
+		}
+		if cfg.Timeout > 0 {
+			opts = append(opts, otlptracegrpc.WithTimeout(time.Duration(cfg.Timeout)))
+		}
+		// 不等待连接建立，Collector 暂时不可用时导出失败的 span 被丢弃，不影响启动
+		return otlptracegrpc.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("未知的链路追踪导出方式: %s", cfg.Exporter)
+	}
+}
+
+// Start 以 ctx 中的 span 为父 span 开始一个新的 span，ctx 中没有 span 时开始一条新的链路
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := noopTracer
+	if t != nil {
+		tracer = t.tracer
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Shutdown 导出缓冲中的 span 并关闭导出器
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil || t.provider == nil {
+		return nil
+	}
+	return t.provider.Shutdown(ctx)
+}
+
+// End 结束 span，err 不为 nil 时把 span 标记为失败并记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject 把 ctx 中的链路上下文写入 carrier，例如 HTTP 请求头 (propagation.HeaderCarrier)
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	propagator.Inject(ctx, carrier)
+}
+
+// OutgoingContext 把 ctx 中的链路上下文追加到 gRPC 请求的 metadata
+func OutgoingContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// UnaryServerInterceptor 从 gRPC 请求的 metadata 中提取调用方的链路上下文，处理请求时生成的 span 接在调用方的链路之后
+func UnaryServerInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = propagator.Extract(ctx, metadataCarrier(md))
+	}
+	return handler(ctx, req)
+}
+
+// metadataCarrier 以 gRPC metadata 作为 propagation.TextMapCarrier，metadata 的键都是小写
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
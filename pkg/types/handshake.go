@@ -0,0 +1,73 @@
+package types
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/YaoAzure/wsgateway/pkg/encryption"
+	"github.com/YaoAzure/wsgateway/pkg/geoip"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+)
+
+// HandshakeContext 一次 WebSocket 握手过程中收集的连接数据
+//
+// Upgrader 在握手的各个回调（OnRequest、OnHeader、OnBeforeUpgrade）之间传递同一个 HandshakeContext，
+// 升级成功后与会话一起返回。需要在握手中附带新数据的功能通过 Set/Value 挂载，不必修改升级流程。
+type HandshakeContext struct {
+	Conn        net.Conn
+	RemoteIP    string             // 客户端IP，不含端口
+	URI         string             // 升级请求的URI，含查询参数
+	Header      http.Header        // 升级请求的HTTP头部
+	UserInfo    session.UserInfo   // 认证得到的用户信息
+	Token       string             // 认证使用的令牌，来自查询参数、请求头、Cookie或子协议，访客握手为空；握手扩展可以从中解析自定义声明
+	UserAgent   string             // 客户端 User-Agent
+	Origin      string             // 浏览器页面的来源，非浏览器客户端通常为空
+	Subprotocol string             // 协商出的 WebSocket 子协议，未协商时为空
+	Compression *compression.State // 升级成功且压缩协商成功时的压缩状态
+	Encryption  *encryption.State  // 加密协商结果，客户端没有携带公钥时为 nil
+	Geo         geoip.Location     // 客户端IP解析得到的地理位置，未启用解析时为零值
+
+	ctx    context.Context
+	values map[any]any
+}
+
+// NewHandshakeContext 为一个新连接创建握手上下文
+func NewHandshakeContext(conn net.Conn) *HandshakeContext {
+	ip := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return &HandshakeContext{
+		Conn:     conn,
+		RemoteIP: ip,
+		Header:   make(http.Header),
+	}
+}
+
+// Context 返回握手的上下文，其中带有握手的 span；未设置时返回 context.Background()
+func (h *HandshakeContext) Context() context.Context {
+	if h.ctx == nil {
+		return context.Background()
+	}
+	return h.ctx
+}
+
+// SetContext 设置握手的上下文，握手各步骤的 span 以其中的 span 为父 span
+func (h *HandshakeContext) SetContext(ctx context.Context) {
+	h.ctx = ctx
+}
+
+// Set 在握手上下文中挂载自定义数据，key 建议使用包内未导出的类型以免冲突
+func (h *HandshakeContext) Set(key, value any) {
+	if h.values == nil {
+		h.values = make(map[any]any)
+	}
+	h.values[key] = value
+}
+
+// Value 返回通过 Set 挂载的数据，不存在时返回 nil
+func (h *HandshakeContext) Value(key any) any {
+	return h.values[key]
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/samber/do/v2"
+)
+
+// HookStage 握手扩展点所在的阶段
+type HookStage int
+
+const (
+	// BeforeAuth 收到升级请求、认证之前，只有 RemoteIP 和 URI 可用，请求头尚未解析
+	BeforeAuth HookStage = iota
+	// AfterAuth 认证成功之后，UserInfo 和 Token 可用
+	// 令牌通过 ?token= 携带和访客握手时请求头尚未解析，通过请求头、Cookie或子协议携带时请求头已全部解析
+	AfterAuth
+	// BeforeSession 请求头全部解析完、内置的准入检查都通过之后，创建会话之前
+	BeforeSession
+	// AfterUpgrade 升级成功之后，只用于观察，返回的错误只记录日志，不影响连接
+	AfterUpgrade
+)
+
+func (s HookStage) String() string {
+	switch s {
+	case BeforeAuth:
+		return "beforeAuth"
+	case AfterAuth:
+		return "afterAuth"
+	case BeforeSession:
+		return "beforeSession"
+	case AfterUpgrade:
+		return "afterUpgrade"
+	default:
+		return fmt.Sprintf("HookStage(%d)", int(s))
+	}
+}
+
+// HandshakeHook 握手扩展点上执行的自定义检查，例如IP白名单、自定义令牌声明校验、租户配额
+// 返回错误时拒绝握手：ws.RejectConnectionError 按其中的状态码和原因拒绝，其它错误以 403 拒绝
+type HandshakeHook func(hc *HandshakeContext) error
+
+// HookError 握手扩展拒绝握手的错误，记录拒绝的扩展和阶段
+type HookError struct {
+	Stage HookStage
+	Name  string
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("握手扩展 %s 在 %s 阶段拒绝握手: %v", e.Name, e.Stage, e.Err)
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+// Rejection 返回拒绝握手时响应给客户端的错误：扩展返回的 ws.RejectConnectionError 原样返回，其它错误以 403 拒绝
+func (e *HookError) Rejection() error {
+	var rejected *ws.ConnectionRejectedError
+	if errors.As(e.Err, &rejected) {
+		return rejected
+	}
+	return ws.RejectConnectionError(ws.RejectionStatus(http.StatusForbidden), ws.RejectionReason(e.Err.Error()))
+}
+
+type namedHook struct {
+	name  string
+	order int
+	hook  HandshakeHook
+}
+
+// HandshakeHooks 握手扩展点的注册表，由容器提供
+//
+// 第三方代码在服务开始接受连接之前从容器中取出注册表并注册扩展，不必修改升级流程：
+//
+//	hooks := do.MustInvoke[*types.HandshakeHooks](injector)
+//	hooks.Register(types.BeforeAuth, "ipAllowlist", 0, func(hc *types.HandshakeContext) error { ... })
+//
+// 同一阶段的扩展按 order 从小到大执行，order 相同时按注册顺序；第一个返回错误的扩展拒绝握手，其后的扩展不再执行
+type HandshakeHooks struct {
+	mu    sync.RWMutex
+	hooks map[HookStage][]namedHook
+}
+
+func NewHandshakeHooks(do.Injector) (*HandshakeHooks, error) {
+	return &HandshakeHooks{hooks: make(map[HookStage][]namedHook)}, nil
+}
+
+// Register 在指定阶段注册一个扩展，name 用于日志和 HookError
+func (h *HandshakeHooks) Register(stage HookStage, name string, order int, hook HandshakeHook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hooks := append(h.hooks[stage], namedHook{name: name, order: order, hook: hook})
+	slices.SortStableFunc(hooks, func(a, b namedHook) int {
+		return cmp.Compare(a.order, b.order)
+	})
+	h.hooks[stage] = hooks
+}
+
+// Len 返回指定阶段注册的扩展数
+func (h *HandshakeHooks) Len(stage HookStage) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.hooks[stage])
+}
+
+// Run 依次执行指定阶段的扩展，返回第一个扩展返回的错误，包装为 *HookError
+func (h *HandshakeHooks) Run(stage HookStage, hc *HandshakeContext) error {
+	h.mu.RLock()
+	hooks := h.hooks[stage]
+	h.mu.RUnlock()
+	for _, nh := range hooks {
+		if err := nh.hook(hc); err != nil {
+			return &HookError{Stage: stage, Name: nh.name, Err: err}
+		}
+	}
+	return nil
+}
@@ -5,9 +5,16 @@ import (
 	"time"
 
 	"github.com/YaoAzure/wsgateway/pkg/session"
-	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/gobwas/ws"
 )
 
+// CloseInfo 连接关闭信息，用于区分关闭的发起方和原因，例如空闲回收、踢下线、违反策略和服务端停机
+type CloseInfo struct {
+	ByPeer bool          // 是否由客户端发起关闭（包括客户端发送关闭帧和连接异常断开）
+	Code   ws.StatusCode // 关闭码，客户端异常断开时为 1006
+	Reason string        // 关闭原因
+}
+
 // Link 表示一个抽象的用户连接，它封装了底层的网络连接（如 WebSocket、TCP），
 // 并与一个用户会话 (Session) 绑定。它提供了面向业务的、统一的连接操作接口。
 type Link interface {
@@ -23,6 +30,13 @@ type Link interface {
 	Receive() <-chan []byte
 	// Close 主动关闭此连接，并释放相关资源。
 	Close() error
+	// CloseWithReason 以指定的关闭码和原因主动关闭此连接，关闭码和原因随关闭帧发送给客户端。
+	// 关闭码不能出现在关闭帧中（例如 1005、1006）时返回错误；原因超过关闭帧的长度限制时被截断。
+	// 连接已关闭时不做任何事，先发生的关闭生效。
+	CloseWithReason(code ws.StatusCode, reason string) error
+	// CloseInfo 返回连接的关闭信息，连接未关闭时返回零值。
+	// 由客户端发起关闭时为客户端关闭帧中的关闭码和原因，否则为网关发送的关闭码和原因。
+	CloseInfo() CloseInfo
 	// HasClosed 返回一个只读通道，该通道在连接被关闭时会关闭（手动关闭）。
 	// 这是一种非阻塞的、事件驱动的机制，用于监听连接的关闭事件。
 	// 例如： `select { case <-link.HasClosed(): ... }`
@@ -40,5 +54,6 @@ type Link interface {
 // 需要在升级过程中集成复杂的业务逻辑 （认证、会话、压缩等）
 type Upgrader interface {
 	Name() string
-    Upgrade(conn net.Conn) (session.Session, *compression.State,error)
+    // Upgrade 完成握手并返回会话，握手过程中收集的数据（含压缩协商结果）保存在 HandshakeContext 中
+    Upgrade(conn net.Conn) (session.Session, *HandshakeContext, error)
 }
\ No newline at end of file
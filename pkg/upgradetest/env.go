@@ -0,0 +1,166 @@
+package upgradetest
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/YaoAzure/wsgateway/internal/abuse"
+	"github.com/YaoAzure/wsgateway/internal/admission"
+	"github.com/YaoAzure/wsgateway/internal/guest"
+	"github.com/YaoAzure/wsgateway/internal/metrics"
+	"github.com/YaoAzure/wsgateway/internal/revocation"
+	"github.com/YaoAzure/wsgateway/internal/upgrader"
+	"github.com/YaoAzure/wsgateway/internal/webhook"
+	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/YaoAzure/wsgateway/pkg/config"
+	"github.com/YaoAzure/wsgateway/pkg/encryption"
+	"github.com/YaoAzure/wsgateway/pkg/geoip"
+	"github.com/YaoAzure/wsgateway/pkg/jwt"
+	"github.com/YaoAzure/wsgateway/pkg/log"
+	"github.com/YaoAzure/wsgateway/pkg/message"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/YaoAzure/wsgateway/pkg/tracing"
+	"github.com/YaoAzure/wsgateway/pkg/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/samber/do/v2"
+)
+
+// TestKey 测试环境中签发和验证JWT使用的密钥
+const TestKey = "upgradetest-secret-key"
+
+// Option 定制测试环境的选项
+type Option func(*options)
+
+type options struct {
+	compression compression.Config
+	builder     session.Builder
+	logger      *log.Logger
+	jwtConfig   config.JWTConfig
+	webhook     config.WebhookConfig
+}
+
+// WithCompression 设置服务端的压缩配置
+func WithCompression(cfg compression.Config) Option {
+	return func(o *options) { o.compression = cfg }
+}
+
+// WithSessionBuilder 替换默认的内存会话构建器
+func WithSessionBuilder(b session.Builder) Option {
+	return func(o *options) { o.builder = b }
+}
+
+// WithLogger 设置日志组件，默认丢弃所有日志
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithJWTConfig 替换默认的JWT配置
+func WithJWTConfig(cfg config.JWTConfig) Option {
+	return func(o *options) { o.jwtConfig = cfg }
+}
+
+// WithWebhookConfig 设置准入webhook配置，默认不配置任何webhook
+func WithWebhookConfig(cfg config.WebhookConfig) Option {
+	return func(o *options) { o.webhook = cfg }
+}
+
+// Env 基于真实 Upgrader 的内存测试环境
+// 除Redis客户端只是占位（Upgrader不会直接访问Redis）外，所有依赖都在内存中，不需要任何外部服务
+type Env struct {
+	Injector do.Injector
+	Upgrader *upgrader.Upgrader
+	Sessions *MemoryBuilder // 默认的内存会话构建器，使用 WithSessionBuilder 替换时为 nil
+
+	token *jwt.UserToken
+}
+
+// NewEnv 创建测试环境
+func NewEnv(opts ...Option) (*Env, error) {
+	o := options{
+		jwtConfig: config.JWTConfig{Key: TestKey, Issuer: "upgradetest"},
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var sessions *MemoryBuilder
+	if o.builder == nil {
+		sessions = NewMemoryBuilder()
+		o.builder = sessions
+	}
+
+	injector := do.New(
+		jwt.Package,
+		message.Package,
+		webhook.Package,
+		// 默认的 optional 策略下只有客户端请求时才协商加密
+		encryption.Package,
+		// 不解析客户端的地理位置，地区策略拒绝的握手计入独立的指标注册表
+		geoip.Package,
+		do.Eager(config.GeoIPConfig{}),
+		do.Lazy(metrics.NewGeoMetrics),
+		do.Eager(prometheus.NewRegistry()),
+		// 不允许访客握手，不读取客户端上报的设备指纹，指纹即设备ID
+		guest.Package,
+		do.Eager(config.GuestConfig{}),
+		do.Eager(config.SessionConfig{}),
+		// 不启用链路追踪，握手的 span 不导出
+		tracing.Package,
+		do.Eager(config.TracingConfig{}),
+		do.Eager(o.jwtConfig),
+		do.Eager(config.MessageConfig{}),
+		// 不启用集群握手锁，同一用户的并发握手只在进程内去重
+		do.Eager(config.ServerConfig{}),
+		do.Eager(o.webhook),
+		do.Eager(o.compression),
+		do.Eager(o.logger),
+		do.Eager(o.builder),
+		// 测试环境不做节点级准入控制
+		do.Eager[admission.Admitter](admission.AcceptAll{}),
+		// 测试环境不做滥用检测，不检查用户是否被封禁
+		abuse.Package,
+		do.Eager(config.AbuseConfig{}),
+		// 测试环境不检查令牌吊销
+		do.Eager[revocation.Checker](revocation.Disabled{}),
+		do.Lazy(types.NewHandshakeHooks),
+		// 占位的Redis客户端，go-redis 只在首次执行命令时才会建立连接
+		do.Eager[redis.Cmdable](redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})),
+	)
+	u, err := upgrader.New(injector)
+	if err != nil {
+		return nil, err
+	}
+	token, err := do.Invoke[*jwt.UserToken](injector)
+	if err != nil {
+		return nil, err
+	}
+	return &Env{
+		Injector: injector,
+		Upgrader: u,
+		Sessions: sessions,
+		token:    token,
+	}, nil
+}
+
+// Token 为指定用户签发一个有效的测试令牌
+func (e *Env) Token(bizID, userID int64) string {
+	t, err := e.token.Encode(jwt.UserClaims{BizID: bizID, UserID: userID})
+	if err != nil {
+		// 使用固定的HMAC密钥签名不会失败
+		panic(err)
+	}
+	return t
+}
+
+// Run 在测试环境的 Upgrader 上驱动一次握手
+func (e *Env) Run(hs Handshake) Result {
+	return Run(e.Upgrader, hs)
+}
+
+// MemoryBuilder 基于内存的会话构建器，即 session 包的内存存储后端
+type MemoryBuilder = session.MemoryStore
+
+func NewMemoryBuilder() *MemoryBuilder {
+	return session.NewMemoryStore()
+}
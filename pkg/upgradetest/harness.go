@@ -0,0 +1,225 @@
+// Package upgradetest 提供驱动 WebSocket 升级流程的测试工具。
+//
+// 它通过 net.Pipe 在内存中模拟客户端与网关之间的握手，无需监听真实端口，
+// 可以编排各种客户端握手（有效/无效token、压缩协商、异常头部、畸形请求），
+// 方便贡献者和下游使用者测试自定义的认证逻辑和升级中间件。
+package upgradetest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/YaoAzure/wsgateway/pkg/compression"
+	"github.com/YaoAzure/wsgateway/pkg/session"
+	"github.com/YaoAzure/wsgateway/pkg/types"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+)
+
+const (
+	// DefaultTimeout 单次握手的默认超时时间，防止被测代码阻塞导致测试挂起
+	DefaultTimeout = 5 * time.Second
+	// defaultHost 模拟请求使用的主机名
+	defaultHost = "gateway.test"
+)
+
+// Handshake 描述一次可编排的客户端握手
+type Handshake struct {
+	// Path 请求路径，默认为 "/"
+	Path string
+	// Token 非空时以 ?token= 查询参数携带
+	Token string
+	// Query 额外的查询参数
+	Query url.Values
+	// Header 额外的HTTP请求头，例如 X-AutoClose、Origin
+	Header http.Header
+	// Compression 非 nil 时在握手中发送 permessage-deflate 扩展请求
+	Compression *wsflate.Parameters
+	// Protocols 请求的子协议列表
+	Protocols []string
+	// Raw 非空时忽略以上所有字段，直接把 Raw 作为原始请求字节发送，用于构造畸形请求
+	Raw []byte
+	// Timeout 本次握手的超时时间，默认 DefaultTimeout
+	Timeout time.Duration
+}
+
+// Result 一次握手的结果
+type Result struct {
+	// Session 升级成功时 Upgrader 返回的会话
+	Session session.Session
+	// Compression 升级成功且压缩协商成功时的压缩状态
+	Compression *compression.State
+	// Context 服务端的握手上下文，握手在认证前失败时部分字段为空
+	Context *types.HandshakeContext
+	// ServerErr Upgrader.Upgrade 返回的错误
+	ServerErr error
+	// ClientErr 客户端握手错误，被服务端拒绝时为 ws.StatusError
+	ClientErr error
+	// Status 服务端返回的HTTP状态码，升级成功时为 101
+	Status int
+	// Handshake 客户端视角的协商结果（子协议、扩展）
+	Handshake ws.Handshake
+	// Client 客户端一侧的连接，仅在升级成功时有效，由调用方负责关闭
+	Client net.Conn
+	// Server 服务端一侧的连接，仅在升级成功时有效，由调用方负责关闭
+	Server net.Conn
+}
+
+// Accepted 返回握手是否被服务端接受
+func (r Result) Accepted() bool {
+	return r.ServerErr == nil && r.ClientErr == nil
+}
+
+// Close 关闭握手成功后两端的连接
+func (r Result) Close() {
+	if r.Client != nil {
+		_ = r.Client.Close()
+	}
+	if r.Server != nil {
+		_ = r.Server.Close()
+	}
+}
+
+type serverResult struct {
+	session session.Session
+	context *types.HandshakeContext
+	err     error
+}
+
+// Run 使用 net.Pipe 驱动一次完整的升级握手
+func Run(u types.Upgrader, hs Handshake) Result {
+	timeout := hs.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	client, server := net.Pipe()
+	deadline := time.Now().Add(timeout)
+	_ = client.SetDeadline(deadline)
+	_ = server.SetDeadline(deadline)
+
+	done := make(chan serverResult, 1)
+	go func() {
+		ss, hc, err := u.Upgrade(server)
+		if err != nil {
+			// 关闭服务端连接，避免客户端一直阻塞在读写上
+			_ = server.Close()
+		}
+		done <- serverResult{session: ss, context: hc, err: err}
+	}()
+
+	var res Result
+	if hs.Raw != nil {
+		res = runRaw(client, hs.Raw)
+	} else {
+		res = runDialer(client, hs)
+	}
+	sr := <-done
+
+	res.Session = sr.session
+	res.Context = sr.context
+	if sr.context != nil {
+		res.Compression = sr.context.Compression
+	}
+	res.ServerErr = sr.err
+	if !res.Accepted() {
+		_ = client.Close()
+		_ = server.Close()
+		return res
+	}
+	// 握手完成后清除超时设置，交给调用方继续使用连接
+	_ = client.SetDeadline(time.Time{})
+	_ = server.SetDeadline(time.Time{})
+	res.Client = client
+	res.Server = server
+	return res
+}
+
+// runDialer 使用 gobwas/ws 的客户端实现发起标准握手
+func runDialer(conn net.Conn, hs Handshake) Result {
+	path := hs.Path
+	if path == "" {
+		path = "/"
+	}
+	query := url.Values{}
+	for k, vs := range hs.Query {
+		query[k] = append([]string(nil), vs...)
+	}
+	if hs.Token != "" {
+		query.Set("token", hs.Token)
+	}
+	u := &url.URL{Scheme: "ws", Host: defaultHost, Path: path, RawQuery: query.Encode()}
+
+	var res Result
+	dialer := ws.Dialer{
+		Protocols: hs.Protocols,
+		OnStatusError: func(status int, _ []byte, _ io.Reader) {
+			res.Status = status
+		},
+	}
+	if hs.Header != nil {
+		dialer.Header = ws.HandshakeHeaderHTTP(hs.Header)
+	}
+	if hs.Compression != nil {
+		dialer.Extensions = []httphead.Option{hs.Compression.Option()}
+	}
+
+	_, handshake, err := dialer.Upgrade(conn, u)
+	if err != nil {
+		res.ClientErr = err
+		var statusErr ws.StatusError
+		if errors.As(err, &statusErr) && res.Status == 0 {
+			res.Status = int(statusErr)
+		}
+		return res
+	}
+	res.Status = http.StatusSwitchingProtocols
+	res.Handshake = handshake
+	return res
+}
+
+// runRaw 发送原始请求字节并解析服务端的HTTP响应
+func runRaw(conn net.Conn, raw []byte) Result {
+	// net.Pipe 是同步的：服务端可能读到一半就开始写错误响应，
+	// 因此写请求必须与读响应并发进行，否则双方会互相阻塞
+	go func() {
+		_, _ = conn.Write(raw)
+	}()
+
+	var res Result
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		res.ClientErr = err
+		return res
+	}
+	defer resp.Body.Close()
+	res.Status = resp.StatusCode
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		res.ClientErr = ws.StatusError(resp.StatusCode)
+	}
+	return res
+}
+
+// BuildRequest 构造一个原始的升级请求，便于在此基础上修改出各种畸形请求
+func BuildRequest(target string, header http.Header) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("GET " + target + " HTTP/1.1\r\n")
+	buf.WriteString("Host: " + defaultHost + "\r\n")
+	buf.WriteString("Upgrade: websocket\r\n")
+	buf.WriteString("Connection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Version: 13\r\n")
+	buf.WriteString("Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n")
+	for k, vs := range header {
+		for _, v := range vs {
+			buf.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}